@@ -0,0 +1,74 @@
+// Copyright (C) 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package diff registers and implements the "diff" apic command.
+//
+// The diff command compares two versions of an API, reporting the commands,
+// parameters, types and annotations that were added, removed or changed.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/google/gapid/core/app"
+	"github.com/google/gapid/core/os/file"
+	"github.com/google/gapid/gapil/apidiff"
+	"github.com/google/gapid/gapil/resolver"
+)
+
+func init() {
+	app.AddVerb(&app.Verb{
+		Name:      "diff",
+		ShortHelp: "Reports the semantic differences between two versions of an api file",
+		Action:    &diffVerb{},
+	})
+}
+
+type diffVerb struct {
+	JSON   bool          `help:"Output the changes as json"`
+	Search file.PathList `help:"The set of paths to search for includes"`
+}
+
+func (v *diffVerb) Run(ctx context.Context, flags flag.FlagSet) error {
+	args := flags.Args()
+	if len(args) != 2 {
+		app.Usage(ctx, "Expected the old and new api files")
+		return nil
+	}
+	// Each version is resolved separately, as the two may share import paths.
+	old, _, err := resolve(ctx, args[0:1], v.Search, resolver.Options{})
+	if err != nil {
+		return err
+	}
+	new, _, err := resolve(ctx, args[1:2], v.Search, resolver.Options{})
+	if err != nil {
+		return err
+	}
+	changes := apidiff.Compare(old[0], new[0])
+
+	if v.JSON {
+		e := json.NewEncoder(os.Stdout)
+		e.SetIndent("", "  ")
+		return e.Encode(changes)
+	}
+	if len(changes) == 0 {
+		return nil
+	}
+	fmt.Fprintf(os.Stdout, "--- %v\n+++ %v\n%v\n", args[0], args[1], changes)
+	return nil
+}
@@ -38,7 +38,14 @@ func init() {
 	})
 }
 
-type validateVerb struct{}
+type validateVerb struct {
+	Check struct {
+		MapIndices     bool `help:"Report map indices not guarded by a check for the key"`
+		Uninitialized  bool `help:"Report field accesses through possibly uninitialized references"`
+		NarrowingCasts bool `help:"Report integer casts that may overflow"`
+		PointerParams  bool `help:"Report command pointer parameters that are never used"`
+	}
+}
 
 func (v *validateVerb) Run(ctx context.Context, flags flag.FlagSet) error {
 	args := flags.Args()
@@ -46,6 +53,13 @@ func (v *validateVerb) Run(ctx context.Context, flags flag.FlagSet) error {
 		app.Usage(ctx, "Missing api file")
 		return nil
 	}
+	options := validate.Options{
+		CheckUnused:         true,
+		CheckMapIndices:     v.Check.MapIndices,
+		CheckUninitialized:  v.Check.Uninitialized,
+		CheckNarrowingCasts: v.Check.NarrowingCasts,
+		CheckPointerParams:  v.Check.PointerParams,
+	}
 	for _, apiName := range args {
 		processor := gapil.NewProcessor()
 		compiled, errs := processor.Resolve(apiName)
@@ -53,7 +67,7 @@ func (v *validateVerb) Run(ctx context.Context, flags flag.FlagSet) error {
 			return err
 		}
 		log.I(ctx, "Validating %v", apiName)
-		issues := validate.Validate(compiled, processor.Mappings, nil)
+		issues := validate.Validate(compiled, processor.Mappings, &options)
 		fmt.Fprintf(os.Stderr, "%v\n", issues)
 		if c := len(issues); c > 0 {
 			return fmt.Errorf("%d issues found", c)
@@ -46,6 +46,7 @@ import (
 	"github.com/google/gapid/gapis/trace"
 
 	// Extensions
+	"github.com/google/gapid/gapis/extensions/engine"
 	_ "github.com/google/gapid/gapis/extensions/unity"
 )
 
@@ -62,6 +63,7 @@ var (
 	adbPath          = flag.String("adb", "", "Path to the adb executable; leave empty to search the environment")
 	enableLocalFiles = flag.Bool("enable-local-files", false, "Allow clients to access local .gfxtrace files by path")
 	remoteSSHConfig  = flag.String("ssh-config", "", "_Path to an ssh config file for remote devices")
+	engineRules      = flag.String("engine-rules", "", "Path to a JSON file of engine command sequences to group")
 )
 
 func main() {
@@ -97,6 +99,12 @@ func run(ctx context.Context) error {
 		adb.ADB = file.Abs(*adbPath)
 	}
 
+	if *engineRules != "" {
+		if err := engine.LoadFile(*engineRules); err != nil {
+			return log.Errf(ctx, err, "Failed to load the engine rules")
+		}
+	}
+
 	r := bind.NewRegistry()
 	ctx = bind.PutRegistry(ctx, r)
 	m := replay.New(ctx)
@@ -0,0 +1,70 @@
+// Copyright (C) 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"io/ioutil"
+	"os"
+
+	"github.com/google/gapid/core/app"
+	"github.com/google/gapid/core/log"
+	"github.com/google/gapid/core/os/android/apk"
+)
+
+type apkInfoVerb struct{ ApkInfoFlags }
+
+func init() {
+	verb := &apkInfoVerb{}
+	app.AddVerb(&app.Verb{
+		Name:       "apk-info",
+		ShortHelp:  "Prints an analysis of an APK file as JSON",
+		ShortUsage: "<apk>",
+		Action:     verb,
+	})
+}
+
+func (verb *apkInfoVerb) Run(ctx context.Context, flags flag.FlagSet) error {
+	if flags.NArg() != 1 {
+		app.Usage(ctx, "Exactly one APK file expected, got %d", flags.NArg())
+		return nil
+	}
+
+	data, err := ioutil.ReadFile(flags.Arg(0))
+	if err != nil {
+		return log.Errf(ctx, err, "Couldn't read APK file '%v'", flags.Arg(0))
+	}
+	report, err := apk.AnalyzeReport(ctx, data)
+	if err != nil {
+		return log.Err(ctx, err, "Analyzing APK")
+	}
+
+	out, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return log.Err(ctx, err, "Encoding report")
+	}
+	out = append(out, '\n')
+
+	if verb.Out == "" {
+		_, err = os.Stdout.Write(out)
+		return err
+	}
+	if err := ioutil.WriteFile(verb.Out, out, 0666); err != nil {
+		return log.Errf(ctx, err, "Couldn't write report to '%v'", verb.Out)
+	}
+	return nil
+}
@@ -81,6 +81,8 @@ func (verb *commandsVerb) Run(ctx context.Context, flags flag.FlagSet) error {
 	treePath.GroupByDrawCall = verb.GroupByDrawCall
 	treePath.GroupByFrame = verb.GroupByFrame
 	treePath.GroupByUserMarkers = verb.GroupByUserMarkers
+	treePath.GroupByRenderPass = verb.GroupByRenderPass
+	treePath.GroupByPipeline = verb.GroupByPipeline
 	treePath.IncludeNoContextGroups = verb.IncludeNoContextGroups
 	treePath.AllowIncompleteFrame = verb.AllowIncompleteFrame
 
@@ -152,6 +152,11 @@ func getGapis(ctx context.Context, gapisFlags GapisFlags, gapirFlags GapirFlags)
 		crash.Go(func() { client.GetLogStream(ctx, h) })
 	}
 
+	if gapisFlags.Status {
+		p := newStatusPrinter(os.Stderr)
+		crash.Go(func() { client.GetStatus(ctx, 0, statusUpdateFrequency, p.handle) })
+	}
+
 	return clientCloser{client, close}, nil
 }
 
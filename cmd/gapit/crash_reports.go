@@ -0,0 +1,115 @@
+// Copyright (C) 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/google/gapid/core/app"
+	"github.com/google/gapid/core/app/crash/reporting"
+	"github.com/google/gapid/core/log"
+)
+
+type crashReportsVerb struct{ CrashReportsFlags }
+
+func init() {
+	verb := &crashReportsVerb{}
+	app.AddVerb(&app.Verb{
+		Name:       "crash_reports",
+		ShortHelp:  "Lists, inspects and uploads spooled crash reports",
+		ShortUsage: "[<report-id>...]",
+		Action:     verb,
+	})
+}
+
+func (verb *crashReportsVerb) Run(ctx context.Context, flags flag.FlagSet) error {
+	if verb.Dir == "" {
+		app.Usage(ctx, "The crash report directory must be specified with -dir")
+		return nil
+	}
+	if verb.Upload && verb.Delete {
+		app.Usage(ctx, "Only one of -upload and -delete can be specified")
+		return nil
+	}
+
+	spool := reporting.Spool{Dir: verb.Dir}
+	reports := []*reporting.Report{}
+	if flags.NArg() == 0 {
+		all, err := spool.Reports()
+		if err != nil {
+			return log.Err(ctx, err, "Couldn't list the crash reports")
+		}
+		reports = all
+	} else {
+		for _, id := range flags.Args() {
+			r, err := spool.Get(id)
+			if err != nil {
+				return log.Errf(ctx, err, "Couldn't load crash report '%v'", id)
+			}
+			reports = append(reports, r)
+		}
+	}
+
+	switch {
+	case verb.Upload:
+		return spool.Upload(ctx, verb.Endpoint, reports...)
+	case verb.Delete:
+		for _, r := range reports {
+			if err := spool.Remove(r.ID); err != nil {
+				return log.Errf(ctx, err, "Couldn't remove crash report '%v'", r.ID)
+			}
+		}
+		return nil
+	}
+
+	if flags.NArg() == 0 && !verb.Symbolize {
+		// Summary of all the reports.
+		for _, r := range reports {
+			kind := "stacktrace"
+			if r.Minidump != "" {
+				kind = "minidump " + r.Minidump
+			}
+			fmt.Fprintf(os.Stdout, "%v  %v %v  %v\n", r.ID, r.Reporter.AppName, r.Reporter.AppVersion, kind)
+		}
+		return nil
+	}
+
+	for _, r := range reports {
+		fmt.Fprintf(os.Stdout, "Report:  %v\n", r.ID)
+		fmt.Fprintf(os.Stdout, "Time:    %v\n", r.Time)
+		fmt.Fprintf(os.Stdout, "App:     %v %v\n", r.Reporter.AppName, r.Reporter.AppVersion)
+		fmt.Fprintf(os.Stdout, "OS:      %v %v\n", r.Reporter.OSName, r.Reporter.OSVersion)
+		if r.Minidump != "" {
+			fmt.Fprintf(os.Stdout, "Minidump: %v\n", r.Minidump)
+		}
+		stack := r.Stacktrace
+		if verb.Symbolize && len(r.Callstack) > 0 {
+			if v := app.Version.String(); r.Reporter.AppVersion != v {
+				log.W(ctx, "Report '%v' is from version %v, but this is version %v. The symbols will be wrong.",
+					r.ID, r.Reporter.AppVersion, v)
+			}
+			stack = r.Symbolize().String()
+		}
+		if stack != "" {
+			fmt.Fprintf(os.Stdout, "Stacktrace:\n  %v\n", strings.Replace(stack, "\n", "\n  ", -1))
+		}
+		fmt.Fprintln(os.Stdout)
+	}
+	return nil
+}
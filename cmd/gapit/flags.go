@@ -51,6 +51,25 @@ func (v VideoType) String() string {
 	return videoTypeNames[v]
 }
 
+const (
+	GltfMesh MeshFormat = iota
+	ObjMesh
+)
+
+type MeshFormat uint8
+
+var meshFormatNames = map[MeshFormat]string{
+	GltfMesh: "gltf",
+	ObjMesh:  "obj",
+}
+
+func (v *MeshFormat) Choose(c interface{}) {
+	*v = c.(MeshFormat)
+}
+func (v MeshFormat) String() string {
+	return meshFormatNames[v]
+}
+
 type PackagesOutput uint8
 
 var packagesOutputNames = map[PackagesOutput]string{
@@ -100,6 +119,7 @@ type (
 		Port    int    `help:"gapis tcp port to connect to, 0 means start new instance."`
 		Args    string `help:"_The arguments to be passed to gapis"`
 		Token   string `help:"_The auth token to use when connecting to an existing server."`
+		Status  bool   `help:"display the progress of long running server tasks"`
 	}
 	GapirFlags struct {
 		DeviceFlags
@@ -113,6 +133,7 @@ type (
 		Gapir            GapirFlags
 		Out              string `help:"output report path"`
 		DisplayToSurface bool   `help:"display the frames rendered in the replay back to the surface"`
+		Lint             bool   `help:"include performance warnings found without replaying the capture"`
 		CommandFilterFlags
 		CaptureFileFlags
 	}
@@ -180,6 +201,8 @@ type (
 		GroupByDrawCall        bool   `help:"Group commands by draw call"`
 		GroupByFrame           bool   `help:"Group commands by frame"`
 		GroupByUserMarkers     bool   `help:"Group commands by user markers"`
+		GroupByRenderPass      bool   `help:"Group commands by render pass or framebuffer"`
+		GroupByPipeline        bool   `help:"Group commands by pipeline or program"`
 		IncludeNoContextGroups bool   `help:"_Include no context groups"`
 		AllowIncompleteFrame   bool   `help:"_Make a group for incomplete frames"`
 		Observations           ObservationFlags
@@ -192,6 +215,7 @@ type (
 		ResourcePath         string `help:"file path for the new resource"`
 		At                   int    `help:"command index to replace the resource(s) at"`
 		UpdateResourceBinary string `help:"shaders only. binary to run for every shader; consumes resource data from standard input and writes to standard output"`
+		Manifest             string `help:"JSON file listing the handles of the shaders, textures and buffers to replace with a file or the output of a command. textures must be uncompressed 2D textures uploaded with glTexImage2D or glTexSubImage2D. buffers must keep their size"`
 		OutputTraceFile      string `help:"file name for the updated trace"`
 		SkipOutput           bool   `help:"skip writing the modified trace to a file"`
 		CaptureFileFlags
@@ -200,6 +224,7 @@ type (
 		Gapis  GapisFlags
 		Gapir  GapirFlags
 		At     flags.U64Slice    `help:"command/subcommand index to get the state after. Empty for last"`
+		Diff   flags.U64Slice    `help:"command/subcommand index to compare the state after -at with. Prints only the changed nodes"`
 		Depth  int               `help:"How many nodes deep should the state tree be displayed. -1 for all"`
 		Filter flags.StringSlice `help:"Which path through the tree should we filter to, default All"`
 		CaptureFileFlags
@@ -302,6 +327,21 @@ type (
 		}
 		CaptureFileFlags
 	}
+	ResourceUsageFlags struct {
+		Gapis      GapisFlags
+		Type       string `help:"only show resources of this type (Texture, Shader, Program or Pipeline). Empty for all"`
+		NeverRead  bool   `help:"only show resources that are never read by a draw call"`
+		EveryFrame bool   `help:"only show resources that are modified in every frame they are alive for"`
+		Uses       bool   `help:"print every use of each resource"`
+		CaptureFileFlags
+	}
+	MemoryReportFlags struct {
+		Gapis       GapisFlags
+		Granularity uint64 `help:"size in bytes of the address ranges of the heatmap. 0 for 4096"`
+		CSV         string `help:"file to write the per-frame heatmap to as CSV"`
+		PNG         string `help:"file to write the per-frame heatmap to as a PNG image with a column per frame and a row per address range"`
+		CaptureFileFlags
+	}
 	MemoryFlags struct {
 		Gapis GapisFlags
 		At    flags.U64Slice `help:"command/subcommand index to get the memory after. Empty for last"`
@@ -339,4 +379,32 @@ type (
 		Gapis GapisFlags
 		Out   string `help:"path to save graph visualization"`
 	}
+	CrashReportsFlags struct {
+		Dir       string `help:"the directory holding the spooled crash reports"`
+		Symbolize bool   `help:"symbolize the stacktraces using this executable (must be the same build)"`
+		Upload    bool   `help:"upload the reports, removing them from the directory once sent"`
+		Endpoint  string `help:"the crash server to upload to (default: the GAPID crash server)"`
+		Delete    bool   `help:"remove the reports from the directory without uploading them"`
+	}
+	JdwpFlags struct {
+		Serial   string `help:"serial of the device running the application"`
+		Pid      int    `help:"pid of the process to attach to (default: the package's newest process)"`
+		Launch   bool   `help:"launch the package's main activity in debug mode before attaching"`
+		Script   string `help:"the debugging script to run (default: read from stdin)"`
+		Port     int    `help:"attach to a JDWP server already listening on this local port"`
+		Commands bool   `help:"print the commands understood by the debugging script and exit"`
+	}
+	MeshFlags struct {
+		Gapis     GapisFlags
+		At        flags.U64Slice    `help:"command/subcommand index of the draw call to export. Empty for last"`
+		Node      flags.U64Slice    `help:"command tree node indices, in the tree grouped by frame and draw call, of the group whose last draw call to export. Overrides -at"`
+		Format    MeshFormat        `help:"the file format to export the mesh as"`
+		Faceted   bool              `help:"if true then normals are calculated from each face"`
+		Semantics flags.StringSlice `help:"vertex stream semantic hints as [name:semantic,...], overriding the guessed ones"`
+		Out       string            `help:"output file (default 'mesh.gltf' or 'mesh.obj')"`
+		CaptureFileFlags
+	}
+	ApkInfoFlags struct {
+		Out string `help:"output file, standard output if none"`
+	}
 )
@@ -0,0 +1,165 @@
+// Copyright (C) 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"time"
+
+	"github.com/google/gapid/core/app"
+	"github.com/google/gapid/core/event/task"
+	"github.com/google/gapid/core/java/debugger"
+	"github.com/google/gapid/core/java/jdwp"
+	"github.com/google/gapid/core/log"
+	"github.com/google/gapid/core/os/android"
+	"github.com/google/gapid/core/os/android/adb"
+)
+
+const (
+	jdwpConnectAttempts = 10
+	jdwpConnectDelay    = time.Second
+	jdwpPidRetries      = 5
+)
+
+type jdwpVerb struct{ JdwpFlags }
+
+func init() {
+	verb := &jdwpVerb{}
+	app.AddVerb(&app.Verb{
+		Name:       "jdwp",
+		ShortHelp:  "Runs a debugging script on a debuggable Android application",
+		ShortUsage: "<package>",
+		Action:     verb,
+	})
+}
+
+func (verb *jdwpVerb) Run(ctx context.Context, flags flag.FlagSet) error {
+	if verb.Commands {
+		fmt.Fprintln(os.Stdout, debugger.ScriptHelp)
+		return nil
+	}
+	if verb.Port == 0 && flags.NArg() != 1 {
+		app.Usage(ctx, "Exactly one package name expected, got %d", flags.NArg())
+		return nil
+	}
+
+	script := io.Reader(os.Stdin)
+	if verb.Script != "" {
+		f, err := os.Open(verb.Script)
+		if err != nil {
+			return log.Errf(ctx, err, "Couldn't open script '%v'", verb.Script)
+		}
+		defer f.Close()
+		script = f
+	}
+
+	port := verb.Port
+	if port == 0 {
+		p, cleanup, err := verb.forward(ctx, flags.Arg(0))
+		if err != nil {
+			return err
+		}
+		defer cleanup()
+		port = p
+	}
+	ctx = log.V{"jdwpPort": port}.Bind(ctx)
+
+	ctx, stop := task.WithCancel(ctx)
+	defer stop()
+
+	var sock net.Conn
+	var conn *jdwp.Connection
+	err := task.Retry(ctx, jdwpConnectAttempts, jdwpConnectDelay, func(ctx context.Context) (bool, error) {
+		var err error
+		if sock, err = net.Dial("tcp", fmt.Sprintf("localhost:%v", port)); err != nil {
+			return false, err
+		}
+		if conn, err = jdwp.Open(ctx, sock); err != nil {
+			sock.Close()
+			log.I(ctx, "Failed to connect to the application: %v. Retrying...", err)
+			return false, err
+		}
+		return true, nil
+	})
+	if err != nil {
+		return log.Err(ctx, err, "Connecting to JDWP")
+	}
+	defer sock.Close()
+
+	return debugger.NewSession(conn).Run(ctx, script, os.Stdout)
+}
+
+// forward sets up the adb forwarding of a local port to the JDWP port of the
+// package's process, optionally launching the package first.
+func (verb *jdwpVerb) forward(ctx context.Context, name string) (int, func(), error) {
+	d, err := getADBDevice(ctx, verb.Serial)
+	if err != nil {
+		return 0, nil, log.Err(ctx, err, "Getting device")
+	}
+	ctx = log.V{"package": name}.Bind(ctx)
+
+	pkg, err := d.InstalledPackage(ctx, name)
+	if err != nil {
+		return 0, nil, log.Err(ctx, err, "Finding package")
+	}
+	if !pkg.Debuggable {
+		log.W(ctx, "Package is not debuggable, the connection will most likely fail")
+	}
+
+	if verb.Launch {
+		var action *android.ActivityAction
+		for _, a := range pkg.ActivityActions {
+			if a.Name == "android.intent.action.MAIN" {
+				action = a
+				break
+			}
+		}
+		if action == nil {
+			return 0, nil, log.Err(ctx, nil, "Package has no launchable activity")
+		}
+		log.I(ctx, "Starting activity %v in debug mode", action.Activity)
+		if err := d.StartActivityForDebug(ctx, *action); err != nil {
+			return 0, nil, log.Err(ctx, err, "Starting activity in debug mode")
+		}
+	}
+
+	pid := verb.Pid
+	if pid == 0 {
+		err = android.ErrProcessNotFound
+		for attempt := 0; attempt <= jdwpPidRetries && err == android.ErrProcessNotFound; attempt++ {
+			time.Sleep(time.Duration(attempt*100) * time.Millisecond)
+			pid, err = pkg.Pid(ctx)
+		}
+		if err != nil {
+			return 0, nil, log.Err(ctx, err, "Getting pid")
+		}
+	}
+	ctx = log.V{"pid": pid}.Bind(ctx)
+
+	port, err := adb.LocalFreeTCPPort()
+	if err != nil {
+		return 0, nil, log.Err(ctx, err, "Finding free port")
+	}
+	log.I(ctx, "Forwarding TCP port %v -> JDWP pid %v", port, pid)
+	if err := d.Forward(ctx, port, adb.Jdwp(pid)); err != nil {
+		return 0, nil, log.Err(ctx, err, "Setting up JDWP port forwarding")
+	}
+	return int(port), func() { d.RemoveForward(ctx, port) }, nil
+}
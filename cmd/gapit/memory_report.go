@@ -0,0 +1,190 @@
+// Copyright (C) 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"os"
+	"sort"
+	"text/tabwriter"
+
+	"github.com/google/gapid/core/app"
+	img "github.com/google/gapid/core/image"
+	"github.com/google/gapid/core/log"
+	"github.com/google/gapid/gapis/service"
+)
+
+// maxUnreadRanges is the number of largest unread ranges printed per pool.
+const maxUnreadRanges = 10
+
+type memoryReportVerb struct{ MemoryReportFlags }
+
+func init() {
+	verb := &memoryReportVerb{}
+	app.AddVerb(&app.Verb{
+		Name:      "memory-report",
+		ShortHelp: "Prints the memory observations, unread writes and unfreed allocations of a capture",
+		Action:    verb,
+	})
+}
+
+func (verb *memoryReportVerb) Run(ctx context.Context, flags flag.FlagSet) error {
+	if flags.NArg() != 1 {
+		app.Usage(ctx, "Exactly one gfx trace file expected, got %d", flags.NArg())
+		return nil
+	}
+
+	client, capture, err := getGapisAndLoadCapture(ctx, verb.Gapis, GapirFlags{}, flags.Arg(0), verb.CaptureFileFlags)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	boxedReport, err := client.Get(ctx, capture.MemoryReport(verb.Granularity).Path(), nil)
+	if err != nil {
+		return log.Err(ctx, err, "Couldn't get the memory report")
+	}
+	report := boxedReport.(*service.MemoryReport)
+
+	w := tabwriter.NewWriter(os.Stdout, 4, 4, 1, ' ', 0)
+	fmt.Fprintf(w, "%v frames, %v byte address ranges\n", report.Frames, report.Granularity)
+	fmt.Fprintln(w, "Pool\tRead bytes\tWritten bytes\tUnread bytes\tAddress ranges")
+	for _, p := range report.Pools {
+		fmt.Fprintf(w, "%v\t%v\t%v\t%v\t%v\n",
+			p.Pool, p.ReadBytes, p.WrittenBytes, rangesSize(p.Unread), len(p.Heatmap))
+	}
+
+	for _, p := range report.Pools {
+		if len(p.Unread) == 0 {
+			continue
+		}
+		unread := append([]*service.MemoryRange{}, p.Unread...)
+		sort.SliceStable(unread, func(i, j int) bool { return unread[i].Size > unread[j].Size })
+		if len(unread) > maxUnreadRanges {
+			unread = unread[:maxUnreadRanges]
+		}
+		fmt.Fprintf(w, "\nLargest ranges of pool %v written but never read:\n", p.Pool)
+		for _, r := range unread {
+			fmt.Fprintf(w, "\t0x%x\t%v bytes\n", r.Base, r.Size)
+		}
+	}
+
+	fmt.Fprintf(w, "\n%v allocations never freed\n", len(report.Unfreed))
+	for _, a := range report.Unfreed {
+		fmt.Fprintf(w, "\t%v\t%v bytes\n", a.Name, a.Size)
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	if verb.CSV != "" {
+		if err := writeMemoryHeatmapCSV(report, verb.CSV); err != nil {
+			return log.Errf(ctx, err, "Failed to write the heatmap to %v", verb.CSV)
+		}
+	}
+	if verb.PNG != "" {
+		if err := writeMemoryHeatmapPNG(report, verb.PNG); err != nil {
+			return log.Errf(ctx, err, "Failed to write the heatmap to %v", verb.PNG)
+		}
+	}
+	return nil
+}
+
+func rangesSize(ranges []*service.MemoryRange) uint64 {
+	size := uint64(0)
+	for _, r := range ranges {
+		size += r.Size
+	}
+	return size
+}
+
+// writeMemoryHeatmapCSV writes a line for every address range and frame with
+// observations to the file.
+func writeMemoryHeatmapCSV(report *service.MemoryReport, filename string) error {
+	f, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	w := csv.NewWriter(f)
+	w.Write([]string{"pool", "address", "frame", "read bytes", "written bytes"})
+	for _, p := range report.Pools {
+		for _, h := range p.Heatmap {
+			for frame := range h.Reads {
+				if h.Reads[frame] == 0 && h.Writes[frame] == 0 {
+					continue
+				}
+				w.Write([]string{
+					fmt.Sprint(p.Pool),
+					fmt.Sprintf("0x%x", h.Base),
+					fmt.Sprint(frame),
+					fmt.Sprint(h.Reads[frame]),
+					fmt.Sprint(h.Writes[frame]),
+				})
+			}
+		}
+	}
+	w.Flush()
+	return w.Error()
+}
+
+// writeMemoryHeatmapPNG writes the heatmap as an image with a column per frame
+// and a row per address range, starting with the first pool. The green and red
+// channels hold the amount of bytes read and written, on a logarithmic scale.
+func writeMemoryHeatmapPNG(report *service.MemoryReport, filename string) error {
+	rows := []*service.MemoryHeat{}
+	max := uint64(0)
+	for _, p := range report.Pools {
+		for _, h := range p.Heatmap {
+			rows = append(rows, h)
+			for frame := range h.Reads {
+				if h.Reads[frame] > max {
+					max = h.Reads[frame]
+				}
+				if h.Writes[frame] > max {
+					max = h.Writes[frame]
+				}
+			}
+		}
+	}
+	width, height := int(report.Frames), len(rows)
+	if width == 0 || height == 0 {
+		return fmt.Errorf("The capture has no memory observations")
+	}
+
+	scale := func(v uint64) byte {
+		return byte(255 * math.Log1p(float64(v)) / math.Log1p(float64(max)))
+	}
+	data := make([]byte, width*height*4)
+	for y, h := range rows {
+		for x := 0; x < width; x++ {
+			i := (y*width + x) * 4
+			data[i+0] = scale(h.Writes[x])
+			data[i+1] = scale(h.Reads[x])
+			data[i+3] = 255
+		}
+	}
+	data, err := img.Convert(data, width, height, 1, img.RGBA_U8_NORM, img.PNG)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(filename, data, 0666)
+}
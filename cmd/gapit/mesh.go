@@ -0,0 +1,135 @@
+// Copyright (C) 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"strings"
+
+	"github.com/google/gapid/core/app"
+	"github.com/google/gapid/core/log"
+	"github.com/google/gapid/gapis/client"
+	"github.com/google/gapid/gapis/service"
+	"github.com/google/gapid/gapis/service/path"
+	"github.com/google/gapid/gapis/vertex"
+)
+
+type meshVerb struct{ MeshFlags }
+
+func init() {
+	verb := &meshVerb{}
+	app.AddVerb(&app.Verb{
+		Name:      "mesh",
+		ShortHelp: "Exports the mesh of a draw call in a .gfxtrace file as glTF or OBJ",
+		Action:    verb,
+	})
+}
+
+func (verb *meshVerb) Run(ctx context.Context, flags flag.FlagSet) error {
+	if flags.NArg() != 1 {
+		app.Usage(ctx, "Exactly one gfx trace file expected, got %d", flags.NArg())
+		return nil
+	}
+
+	options := path.NewMeshOptions(verb.Faceted)
+	for _, s := range verb.Semantics {
+		hint, err := parseSemanticHint(s)
+		if err != nil {
+			app.Usage(ctx, "%v", err)
+			return nil
+		}
+		options.VertexSemantics = append(options.VertexSemantics, hint)
+	}
+
+	client, c, err := getGapisAndLoadCapture(ctx, verb.Gapis, GapirFlags{}, flags.Arg(0), verb.CaptureFileFlags)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	var mesh *path.Mesh
+	if len(verb.Node) > 0 {
+		mesh, err = verb.nodeMesh(ctx, client, c, options)
+		if err != nil {
+			return err
+		}
+	} else {
+		if len(verb.At) == 0 {
+			boxedCapture, err := client.Get(ctx, c.Path(), nil)
+			if err != nil {
+				return log.Err(ctx, err, "Failed to load the capture")
+			}
+			verb.At = []uint64{uint64(boxedCapture.(*service.Capture).NumCommands) - 1}
+		}
+		mesh = c.Command(verb.At[0], verb.At[1:]...).Mesh(options)
+	}
+
+	format, out := path.MeshExportFormat_GLTF, "mesh.gltf"
+	if verb.Format == ObjMesh {
+		format, out = path.MeshExportFormat_OBJ, "mesh.obj"
+	}
+	if verb.Out != "" {
+		out = verb.Out
+	}
+
+	ctx = log.V{"mesh": mesh}.Bind(ctx)
+	data, err := client.Get(ctx, mesh.As(format).Path(), nil)
+	if err != nil {
+		return log.Err(ctx, err, "Failed to export the mesh")
+	}
+
+	if err := ioutil.WriteFile(out, data.([]byte), 0666); err != nil {
+		return log.Errf(ctx, err, "Failed to write the mesh to '%v'", out)
+	}
+	log.I(ctx, "Mesh written to %v", out)
+	return nil
+}
+
+// nodeMesh returns the path to the mesh of the last draw call of the command
+// tree node at the indices verb.Node, in the command tree grouped by frame and
+// draw call.
+func (verb *meshVerb) nodeMesh(ctx context.Context, client client.Client, c *path.Capture, options *path.MeshOptions) (*path.Mesh, error) {
+	treePath := c.CommandTree(nil)
+	treePath.GroupByFrame = true
+	treePath.GroupByDrawCall = true
+	treePath.AllowIncompleteFrame = true
+
+	boxedTree, err := client.Get(ctx, treePath.Path(), nil)
+	if err != nil {
+		return nil, log.Err(ctx, err, "Failed to load the command tree")
+	}
+	node := boxedTree.(*service.CommandTree).Root
+	for _, i := range verb.Node {
+		node = node.Child(i)
+	}
+	return node.Mesh(options), nil
+}
+
+// parseSemanticHint parses a vertex semantic hint in the form name:semantic.
+func parseSemanticHint(s string) (*path.MeshOptions_SemanticHint, error) {
+	parts := strings.Split(s, ":")
+	if len(parts) != 2 || parts[0] == "" {
+		return nil, fmt.Errorf("Invalid semantic hint '%v', expected name:semantic", s)
+	}
+	for name, value := range vertex.Semantic_Type_value {
+		if strings.EqualFold(name, parts[1]) {
+			return &path.MeshOptions_SemanticHint{Name: parts[0], Type: vertex.Semantic_Type(value)}, nil
+		}
+	}
+	return nil, fmt.Errorf("Unknown vertex semantic '%v' in hint '%v'", parts[1], s)
+}
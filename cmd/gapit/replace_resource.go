@@ -17,13 +17,16 @@ package main
 import (
 	"bytes"
 	"context"
+	"encoding/json"
 	"flag"
 	"fmt"
+	"image/png"
 	"io/ioutil"
 	"path/filepath"
 	"strings"
 
 	"github.com/google/gapid/core/app"
+	img "github.com/google/gapid/core/image"
 	"github.com/google/gapid/core/log"
 	"github.com/google/gapid/core/os/shell"
 	"github.com/google/gapid/gapis/api"
@@ -53,8 +56,14 @@ func (verb *replaceResourceVerb) Run(ctx context.Context, flags flag.FlagSet) er
 		return nil
 	}
 
-	if (verb.Handle == "") == (verb.UpdateResourceBinary == "") {
-		app.Usage(ctx, "only one of -handle or -updateresourcebinary arguments is required")
+	modes := 0
+	for _, arg := range []string{verb.Handle, verb.UpdateResourceBinary, verb.Manifest} {
+		if arg != "" {
+			modes++
+		}
+	}
+	if modes != 1 {
+		app.Usage(ctx, "only one of -handle, -updateresourcebinary or -manifest arguments is required")
 		return nil
 	}
 
@@ -135,6 +144,13 @@ func (verb *replaceResourceVerb) Run(ctx context.Context, flags flag.FlagSet) er
 		}
 		resourceData = api.NewMultiResourceData(resourcesSource)
 		resourcePath = capture.Command(uint64(verb.At)).ResourcesAfter(ids).Path()
+	case verb.Manifest != "":
+		ids, data, err := verb.manifestResourceData(ctx, client, capture.Command(uint64(verb.At)), resources)
+		if err != nil {
+			return err
+		}
+		resourceData = api.NewMultiResourceData(data)
+		resourcePath = capture.Command(uint64(verb.At)).ResourcesAfter(ids).Path()
 	}
 
 	newResourcePath, err := client.Set(ctx, resourcePath, resourceData, nil)
@@ -164,17 +180,212 @@ func (verb *replaceResourceVerb) Run(ctx context.Context, flags flag.FlagSet) er
 // getNewResourceData runs the update resource binary on the old resource data
 // and returns the newly generated resource data
 func (verb *replaceResourceVerb) getNewResourceData(ctx context.Context, resourceData string) (string, error) {
+	out, err := runResourceCommand(ctx, shell.Cmd{Name: verb.UpdateResourceBinary}, []byte(resourceData))
+	return string(out), err
+}
+
+// runResourceCommand runs cmd with input as standard input and returns its
+// standard output.
+func runResourceCommand(ctx context.Context, cmd shell.Cmd, input []byte) ([]byte, error) {
 	stdout, stderr := bytes.Buffer{}, bytes.Buffer{}
-	cmd := shell.Cmd{Name: verb.UpdateResourceBinary}.
-		Read(bytes.NewBufferString(resourceData)).
-		Capture(&stdout, &stderr)
+	cmd = cmd.Read(bytes.NewReader(input)).Capture(&stdout, &stderr)
 
 	if err := cmd.Run(ctx); err != nil {
-		msg := fmt.Sprintf("Command '%v' returned error", verb.UpdateResourceBinary)
+		msg := fmt.Sprintf("Command '%v' returned error", cmd.Name)
 		if stderr.Len() > 0 {
 			msg += fmt.Sprintf(": %v", stderr.String())
 		}
-		return "", log.Errf(ctx, err, msg)
+		return nil, log.Errf(ctx, err, msg)
+	}
+	return stdout.Bytes(), nil
+}
+
+// manifestEntry is a single replacement listed in a -manifest file, which
+// holds a JSON array of entries. For example:
+//
+//	[
+//	  {"handle": "Shader<12>", "file": "shaders/12.frag"},
+//	  {"handle": "Texture<*>", "command": "convert - -negate -"}
+//	]
+//
+// Shader data is the shader source. Texture data is a PNG image of the top
+// mip-map level of a 2D texture, which must have been uploaded with
+// glTexImage2D, or with glTexSubImage2D after glTexStorage2D. Compressed
+// textures and other kinds of textures cannot be replaced. Buffer data is the
+// raw contents of the buffer, which must keep its size and must have been
+// filled whole with glBufferData, glBufferSubData or vkCmdUpdateBuffer.
+type manifestEntry struct {
+	// Handle is the handle of the resources to replace, or a pattern
+	// matching the handles using the filepath.Match syntax.
+	Handle string `json:"handle"`
+	// File is the path of the file holding the new resource data, relative
+	// to the manifest.
+	File string `json:"file"`
+	// Command is run for every matching resource, consuming the current
+	// resource data from standard input and writing the new data to standard
+	// output. The command is split into arguments on white space, without
+	// any quoting.
+	Command string `json:"command"`
+}
+
+func readManifest(ctx context.Context, filename string) ([]manifestEntry, error) {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, log.Errf(ctx, err, "Could not read manifest %s", filename)
+	}
+	entries := []manifestEntry{}
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return nil, log.Errf(ctx, err, "Could not parse manifest %s", filename)
+	}
+	for i, e := range entries {
+		if e.Handle == "" {
+			return nil, log.Errf(ctx, nil, "Manifest entry %d has no handle", i)
+		}
+		if _, err := filepath.Match(e.Handle, ""); err != nil {
+			return nil, log.Errf(ctx, err, "Manifest entry %d has an invalid handle '%s'", i, e.Handle)
+		}
+		if (e.File == "") == (strings.TrimSpace(e.Command) == "") {
+			return nil, log.Errf(ctx, nil, "Manifest entry %d requires exactly one of file or command", i)
+		}
+		if e.File != "" && !filepath.IsAbs(e.File) {
+			entries[i].File = filepath.Join(filepath.Dir(filename), e.File)
+		}
+	}
+	return entries, nil
+}
+
+// manifestResourceData returns the identifiers and new data of all the
+// resources matched by the entries of the manifest. Each resource is replaced
+// using the first entry matching its handle.
+func (verb *replaceResourceVerb) manifestResourceData(ctx context.Context, client service.Service, after *path.Command, resources *service.Resources) ([]*path.ID, []*api.ResourceData, error) {
+	entries, err := readManifest(ctx, verb.Manifest)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	used := make([]bool, len(entries))
+	ids := []*path.ID{}
+	data := []*api.ResourceData{}
+	for _, types := range resources.Types {
+		for _, r := range types.Resources {
+			for i, e := range entries {
+				if match, _ := filepath.Match(e.Handle, r.Handle); !match {
+					continue
+				}
+				used[i] = true
+				ctx := log.V{"handle": r.Handle}.Bind(ctx)
+				rd, err := verb.manifestEntryData(ctx, client, after.ResourceAfter(r.ID), types.Type, e)
+				if err != nil {
+					return nil, nil, err
+				}
+				ids = append(ids, r.ID)
+				data = append(data, rd)
+				break
+			}
+		}
+	}
+
+	for i, e := range entries {
+		if !used[i] {
+			return nil, nil, log.Errf(ctx, nil, "No resource matches the handle '%s' of manifest entry %d", e.Handle, i)
+		}
+	}
+	return ids, data, nil
+}
+
+func (verb *replaceResourceVerb) manifestEntryData(ctx context.Context, client service.Service, p *path.ResourceData, ty api.ResourceType, e manifestEntry) (*api.ResourceData, error) {
+	boxed, err := client.Get(ctx, p.Path(), nil)
+	if err != nil {
+		return nil, log.Err(ctx, err, "Could not get the resource data")
+	}
+	old := boxed.(*api.ResourceData)
+
+	// newData returns the replacement data, running the command on the
+	// current data returned by current if the entry has no file.
+	newData := func(current func() ([]byte, error)) ([]byte, error) {
+		if e.File != "" {
+			data, err := ioutil.ReadFile(e.File)
+			if err != nil {
+				return nil, log.Errf(ctx, err, "Could not read resource file %s", e.File)
+			}
+			return data, nil
+		}
+		data, err := current()
+		if err != nil {
+			return nil, err
+		}
+		args := strings.Fields(e.Command)
+		return runResourceCommand(ctx, shell.Cmd{Name: args[0], Args: args[1:]}, data)
+	}
+
+	switch ty {
+	case api.ResourceType_ShaderResource:
+		shader := old.GetShader()
+		source, err := newData(func() ([]byte, error) { return []byte(shader.GetSource()), nil })
+		if err != nil {
+			return nil, err
+		}
+		return api.NewResourceData(&api.Shader{Type: shader.GetType(), Source: string(source)}), nil
+
+	case api.ResourceType_TextureResource:
+		texture := old.GetTexture().GetTexture_2D()
+		if texture == nil || len(texture.Levels) == 0 {
+			return nil, log.Err(ctx, nil, "Only 2D textures can be replaced")
+		}
+		level := texture.Levels[0]
+		data, err := newData(func() ([]byte, error) {
+			boxed, err := client.Get(ctx, path.NewBlob(level.Bytes.ID()).Path(), nil)
+			if err != nil {
+				return nil, log.Err(ctx, err, "Could not get the texture data")
+			}
+			data, err := img.Convert(boxed.([]byte), int(level.Width), int(level.Height), int(level.Depth), level.Format, img.PNG)
+			if err != nil {
+				return nil, log.Err(ctx, err, "Could not convert the texture to PNG")
+			}
+			return data, nil
+		})
+		if err != nil {
+			return nil, err
+		}
+		cfg, err := png.DecodeConfig(bytes.NewReader(data))
+		if err != nil {
+			return nil, log.Err(ctx, err, "The new texture data is not a PNG image")
+		}
+		// Upload the image so the new level can reference it.
+		blob, err := client.UploadBlob(ctx, data)
+		if err != nil {
+			return nil, log.Err(ctx, err, "Could not upload the texture data")
+		}
+		return api.NewResourceData(api.NewTexture(&api.Texture2D{
+			Levels: []*img.Info{{
+				Format: img.PNG,
+				Width:  uint32(cfg.Width),
+				Height: uint32(cfg.Height),
+				Depth:  1,
+				Bytes:  img.NewID(blob.ID.ID()),
+			}},
+		})), nil
+
+	case api.ResourceType_BufferResource:
+		buffer := old.GetBuffer()
+		data, err := newData(func() ([]byte, error) {
+			if buffer.GetData() == nil {
+				return make([]byte, buffer.GetSize()), nil
+			}
+			boxed, err := client.Get(ctx, path.NewBlob(buffer.Data.ID()).Path(), nil)
+			if err != nil {
+				return nil, log.Err(ctx, err, "Could not get the buffer data")
+			}
+			return boxed.([]byte), nil
+		})
+		if err != nil {
+			return nil, err
+		}
+		blob, err := client.UploadBlob(ctx, data)
+		if err != nil {
+			return nil, log.Err(ctx, err, "Could not upload the buffer data")
+		}
+		return api.NewResourceData(&api.Buffer{Size: uint64(len(data)), Data: blob.ID}), nil
 	}
-	return stdout.String(), nil
+	return nil, log.Errf(ctx, nil, "Replacing %v resources is not supported", ty)
 }
@@ -98,7 +98,9 @@ func (verb *reportVerb) Run(ctx context.Context, flags flag.FlagSet) error {
 	}
 	commands := boxedCommands.(*service.Commands).List
 
-	boxedReport, err := client.Get(ctx, capturePath.Report(device, filter, verb.DisplayToSurface).Path(), nil)
+	reportPath := capturePath.Report(device, filter, verb.DisplayToSurface)
+	reportPath.Lint = verb.Lint
+	boxedReport, err := client.Get(ctx, reportPath.Path(), nil)
 	if err != nil {
 		return log.Err(ctx, err, "Failed to acquire the capture's report")
 	}
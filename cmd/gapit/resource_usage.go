@@ -0,0 +1,130 @@
+// Copyright (C) 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/google/gapid/core/app"
+	"github.com/google/gapid/core/log"
+	"github.com/google/gapid/gapis/service"
+	"github.com/google/gapid/gapis/service/path"
+)
+
+type resourceUsageVerb struct{ ResourceUsageFlags }
+
+func init() {
+	verb := &resourceUsageVerb{}
+	app.AddVerb(&app.Verb{
+		Name:      "resource-usage",
+		ShortHelp: "Prints the commands that create, modify, bind, read and delete each resource",
+		Action:    verb,
+	})
+}
+
+func (verb *resourceUsageVerb) Run(ctx context.Context, flags flag.FlagSet) error {
+	if flags.NArg() != 1 {
+		app.Usage(ctx, "Exactly one gfx trace file expected, got %d", flags.NArg())
+		return nil
+	}
+
+	client, capture, err := getGapisAndLoadCapture(ctx, verb.Gapis, GapirFlags{}, flags.Arg(0), verb.CaptureFileFlags)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	boxedUsage, err := client.Get(ctx, capture.ResourceUsage().Path(), nil)
+	if err != nil {
+		return log.Err(ctx, err, "Couldn't get the resource usage")
+	}
+	resources := boxedUsage.(*service.ResourceUsages).Resources
+
+	events, err := getEvents(ctx, client, &path.Events{
+		Capture:      capture,
+		FirstInFrame: true,
+	})
+	if err != nil {
+		return err
+	}
+	frameStarts := make([]uint64, len(events))
+	for i, e := range events {
+		frameStarts[i] = e.Command.Indices[0]
+	}
+	// frame returns the index of the frame containing the command at index cmd.
+	frame := func(cmd uint64) int {
+		return sort.Search(len(frameStarts), func(i int) bool { return frameStarts[i] > cmd }) - 1
+	}
+	lastFrame := len(frameStarts) - 1
+
+	sort.SliceStable(resources, func(i, j int) bool {
+		return resources[i].Type < resources[j].Type
+	})
+
+	w := tabwriter.NewWriter(os.Stdout, 4, 4, 1, ' ', 0)
+	fmt.Fprintln(w, "Type\tHandle\tLabel\tCreated\tDeleted\tModifies\tBinds\tReads\tFrames modified")
+	for _, r := range resources {
+		typ := strings.TrimSuffix(r.Type.String(), "Resource")
+		if verb.Type != "" && !strings.EqualFold(verb.Type, typ) {
+			continue
+		}
+
+		counts := map[service.ResourceUseKind]int{}
+		created, deleted := "-", "-"
+		modifiedFrames := map[int]bool{}
+		firstFrame, endFrame := 0, lastFrame
+		for _, u := range r.Uses {
+			counts[u.Kind]++
+			cmd := u.Command.Indices[0]
+			switch u.Kind {
+			case service.ResourceUseKind_Create:
+				created, firstFrame = fmt.Sprint(cmd), frame(cmd)
+				modifiedFrames[frame(cmd)] = true
+			case service.ResourceUseKind_Modify:
+				modifiedFrames[frame(cmd)] = true
+			case service.ResourceUseKind_Delete:
+				deleted, endFrame = fmt.Sprint(cmd), frame(cmd)
+			}
+		}
+		aliveFrames := endFrame - firstFrame + 1
+
+		if verb.NeverRead && counts[service.ResourceUseKind_Read] > 0 {
+			continue
+		}
+		if verb.EveryFrame && (aliveFrames < 2 || len(modifiedFrames) < aliveFrames) {
+			continue
+		}
+
+		fmt.Fprintf(w, "%v\t%v\t%v\t%v\t%v\t%v\t%v\t%v\t%v/%v\n",
+			typ, r.Handle, r.Label, created, deleted,
+			counts[service.ResourceUseKind_Modify],
+			counts[service.ResourceUseKind_Bind],
+			counts[service.ResourceUseKind_Read],
+			len(modifiedFrames), aliveFrames)
+
+		if verb.Uses {
+			for _, u := range r.Uses {
+				fmt.Fprintf(w, "\t\t%v\t%v\n", u.Command.Indices, u.Kind)
+			}
+		}
+	}
+	return w.Flush()
+}
@@ -19,6 +19,7 @@ import (
 	"flag"
 	"fmt"
 	"os"
+	"strings"
 
 	"github.com/google/gapid/core/app"
 	"github.com/google/gapid/core/app/flags"
@@ -26,6 +27,7 @@ import (
 	"github.com/google/gapid/core/log"
 	"github.com/google/gapid/gapis/client"
 	"github.com/google/gapid/gapis/service"
+	"github.com/google/gapid/gapis/service/box"
 	"github.com/google/gapid/gapis/service/path"
 )
 
@@ -35,6 +37,7 @@ func init() {
 	verb := &stateVerb{
 		StateFlags{
 			At:     flags.U64Slice{},
+			Diff:   flags.U64Slice{},
 			Depth:  -1,
 			Filter: flags.StringSlice{},
 		},
@@ -67,6 +70,10 @@ func (verb *stateVerb) Run(ctx context.Context, flags flag.FlagSet) error {
 		verb.At = []uint64{uint64(boxedCapture.(*service.Capture).NumCommands) - 1}
 	}
 
+	if len(verb.Diff) > 0 {
+		return verb.printDiff(ctx, client, c)
+	}
+
 	boxedTree, err := client.Get(ctx, c.Command(uint64(verb.At[0]), verb.At[1:]...).StateAfter().Tree().Path(), nil)
 	if err != nil {
 		return log.Err(ctx, err, "Failed to load the command tree")
@@ -93,6 +100,65 @@ func (verb *stateVerb) Run(ctx context.Context, flags flag.FlagSet) error {
 	}, "", true)
 }
 
+func (verb *stateVerb) printDiff(ctx context.Context, client client.Client, c *path.Capture) error {
+	// The state tree filter starts with the root node, the diff filter with its
+	// children.
+	filter := []string(verb.Filter)
+	if len(filter) > 0 {
+		if filter[0] != "root" && filter[0] != "*" {
+			return nil
+		}
+		filter = filter[1:]
+	}
+	depth := verb.Depth
+	switch {
+	case depth == 0:
+		return nil
+	case depth < 0:
+		depth = 0
+	}
+
+	from := c.Command(uint64(verb.Diff[0]), verb.Diff[1:]...).StateAfter()
+	to := c.Command(uint64(verb.At[0]), verb.At[1:]...).StateAfter()
+	p := to.TreeDiff(from)
+	p.Depth, p.Filter = int32(depth), filter
+
+	boxedDiff, err := client.Get(ctx, p.Path(), nil)
+	if err != nil {
+		return log.Err(ctx, err, "Failed to load the state tree diff")
+	}
+
+	for _, d := range boxedDiff.(*service.StateTreeDiff).Changes {
+		var constants *service.ConstantSet
+		if d.Constants != nil {
+			if constants, err = getConstantSet(ctx, client, d.Constants); err != nil {
+				return log.Err(ctx, err, "Couldn't fetch constant set")
+			}
+		}
+		value := func(v *box.Value) interface{} {
+			switch {
+			case v == nil:
+				return "<none>"
+			case constants != nil:
+				return constants.Sprint(v.Get())
+			default:
+				return v.Get()
+			}
+		}
+		before, after := value(d.OldValue), value(d.NewValue)
+		name := strings.Join(d.Names, ".") + ":"
+		switch d.Change {
+		case service.StateTreeChange_Added:
+			fmt.Fprintln(os.Stdout, "+", name, after)
+		case service.StateTreeChange_Removed:
+			fmt.Fprintln(os.Stdout, "-", name, before)
+		default:
+			fmt.Fprintln(os.Stdout, "~", name, before, "->", after)
+		}
+	}
+	return nil
+}
+
 func traverseStateTree(
 	ctx context.Context,
 	c client.Client,
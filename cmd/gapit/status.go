@@ -0,0 +1,92 @@
+// Copyright (C) 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/gapid/gapis/service"
+)
+
+const (
+	// statusUpdateFrequency is the minimum time between progress updates of
+	// a task requested from gapis.
+	statusUpdateFrequency = 100 * time.Millisecond
+
+	progressBarWidth = 30
+	maxTaskNameWidth = 60
+)
+
+// statusPrinter displays a progress bar for the most recently updated task
+// reported by gapis.
+type statusPrinter struct {
+	out   io.Writer
+	mutex sync.Mutex
+	tasks map[uint64]*service.TaskUpdate
+	last  uint64
+	shown bool
+}
+
+func newStatusPrinter(out io.Writer) *statusPrinter {
+	return &statusPrinter{out: out, tasks: map[uint64]*service.TaskUpdate{}}
+}
+
+func (p *statusPrinter) handle(r *service.ServerStatusResponse) error {
+	t := r.GetTask()
+	if t == nil {
+		return nil
+	}
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	switch t.Status {
+	case service.TaskStatus_STARTING, service.TaskStatus_PROGRESS:
+		p.tasks[t.Id] = t
+		p.last = t.Id
+	case service.TaskStatus_FINISHED:
+		delete(p.tasks, t.Id)
+		if p.last == t.Id {
+			// Fall back to the parent, if it is still running.
+			p.last = t.Parent
+		}
+	}
+
+	current, ok := p.tasks[p.last]
+	if !ok {
+		if p.shown {
+			fmt.Fprint(p.out, "\r\033[K")
+			p.shown = false
+		}
+		return nil
+	}
+
+	done := int(current.CompletePercent) * progressBarWidth / 100
+	if done > progressBarWidth {
+		done = progressBarWidth
+	}
+	name := current.Name
+	if len(name) > maxTaskNameWidth {
+		name = "..." + name[len(name)-maxTaskNameWidth+3:]
+	}
+	fmt.Fprintf(p.out, "\r\033[K[%s%s] %3d%% %s",
+		strings.Repeat("=", done), strings.Repeat(" ", progressBarWidth-done),
+		current.CompletePercent, name)
+	p.shown = true
+	return nil
+}
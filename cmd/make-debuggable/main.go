@@ -28,22 +28,26 @@ import (
 )
 
 var (
-	jarSignCmd     = flag.String("jarsigner", "jarsigner", "path to jarsigner")
-	zipAlignCmd    = flag.String("zipalign", "zipalign", "path to zipalign")
+	jarSignCmd     = flag.String("jarsigner", "", "path to jarsigner to sign with instead of signing natively")
+	zipAlignCmd    = flag.String("zipalign", "", "path to zipalign to align with after signing with jarsigner")
 	keyPass        = flag.String("keypass", "android", "key passphrase")
 	keyAlias       = flag.String("keyalias", "androiddebugkey", "key alias")
 	storePass      = flag.String("storepass", "android", "key store passphrase")
 	keyStore       = flag.String("keystore", "~/.android/debug.keystore", "key store location")
 	forceOverwrite = flag.Bool("y", false, "overwrite existing destination")
+	verify         = flag.Bool("verify", false, "verify the signatures of the source and exit")
 )
 
 func main() {
 	app.ShortHelp = "make an apk debuggable and re-sign"
-	app.ShortUsage = " <source> <destination>"
+	app.ShortUsage = " <source> <destination> | -verify <source>"
 	app.Run(run)
 }
 
 func run(ctx context.Context) error {
+	if *verify && len(flag.Args()) == 1 {
+		return verifySignatures(ctx, flag.Arg(0))
+	}
 	if len(flag.Args()) != 2 {
 		app.Usage(ctx, "")
 	}
@@ -64,7 +68,7 @@ func run(ctx context.Context) error {
 		return file.Copy(ctx, file.Abs(dst), file.Abs(src))
 	}
 
-	return apk.ApkDebugifier{
+	err = apk.ApkDebugifier{
 		JarSignCmd:   *jarSignCmd,
 		ZipAlignCmd:  *zipAlignCmd,
 		KeyPass:      *keyPass,
@@ -72,4 +76,25 @@ func run(ctx context.Context) error {
 		StorePass:    *storePass,
 		KeyStorePath: *keyStore,
 	}.Run(ctx, src, dst)
+	if err != nil {
+		return err
+	}
+	return verifySignatures(ctx, dst)
+}
+
+func verifySignatures(ctx context.Context, path string) error {
+	sigs, err := apk.VerifySignatures(ctx, path)
+	if err != nil {
+		return err
+	}
+	if sigs.V1 == nil && sigs.V2 == nil {
+		return fmt.Errorf("%s is not signed", path)
+	}
+	if sigs.V1 != nil {
+		log.I(ctx, "%s has a valid JAR signature by %v", path, sigs.V1.Subject)
+	}
+	if sigs.V2 != nil {
+		log.I(ctx, "%s has a valid APK Signature Scheme v2 signature by %v", path, sigs.V2.Subject)
+	}
+	return nil
 }
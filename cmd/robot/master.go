@@ -75,7 +75,7 @@ func init() {
 type masterVerb struct {
 	BaseAddr     file.Path `help:"The base path for all robot files"`
 	StashAddr    string    `help:"The address of the stash, defaults to a directory below base"`
-	ShelfAddr    string    `help:"The path to the persisted data, defaults to a directory below base"`
+	ShelfAddr    string    `help:"The url of the persisted data, defaults to a directory below base. Add ?kind=compact to use compacting ledgers"`
 	Port         int       `help:"The port to serve the master on"`
 	StartWorkers bool      `help:"Enables local workers"`
 	StartWeb     bool      `help:"Enables serving the web client"`
@@ -98,12 +98,12 @@ func (v *masterVerb) Run(ctx context.Context, flags flag.FlagSet) error {
 		var shelfURL *url.URL
 		if v.StashAddr == "" {
 			stashURL = v.BaseAddr.Join("stash").URL()
-		} else if shelfURL, err = url.Parse(v.StashAddr); err != nil {
+		} else if stashURL, err = url.Parse(v.StashAddr); err != nil {
 			return log.Errf(ctx, err, "Invalid server location", v.StashAddr)
 		}
 		if v.ShelfAddr == "" {
 			shelfURL = v.BaseAddr.Join("shelf").URL()
-		} else if stashURL, err = url.Parse(v.ShelfAddr); err != nil {
+		} else if shelfURL, err = url.Parse(v.ShelfAddr); err != nil {
 			return log.Errf(ctx, err, "Invalid record shelf location", v.ShelfAddr)
 		}
 		library := record.NewLibrary(ctx)
@@ -14,6 +14,25 @@
 
 package reporting
 
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/google/gapid/core/fault/stacktrace"
+	"github.com/google/gapid/core/fault/stacktrace/crunch"
+)
+
+const (
+	crashStagingURL = "https://clients2.google.com/cr/staging_report"
+	crashProdURL    = "https://clients2.google.com/cr/report"
+	crashURL        = crashProdURL
+)
+
 // Reporter stores the common information sent in a crash report.
 type Reporter struct {
 	AppName    string
@@ -21,3 +40,105 @@ type Reporter struct {
 	OSName     string
 	OSVersion  string
 }
+
+// Report is an encoded crash report, ready to be sent to a crash server.
+type Report struct {
+	// ID is the identifier of the report in a Spool.
+	ID string `json:"-"`
+	// Reporter describes the application that crashed.
+	Reporter Reporter
+	// Time is the time the report was created.
+	Time time.Time
+	// Stacktrace is the stacktrace of the crash, as symbolized by the
+	// application that crashed.
+	Stacktrace string `json:",omitempty"`
+	// Callstack is the crunched callstack of the crash, see Symbolize.
+	Callstack []byte `json:",omitempty"`
+	// Minidump is the name of the minidump held by the report, if any.
+	Minidump string `json:",omitempty"`
+	// ContentType is the MIME type of Body.
+	ContentType string
+	// Body is the encoded report, as sent to the crash server.
+	Body []byte
+}
+
+// Symbolize returns the callstack of the report, symbolized by the running
+// executable. The result is only meaningful if the running executable is the
+// same build as the one that crashed.
+func (r *Report) Symbolize() stacktrace.Callstack {
+	if len(r.Callstack) == 0 {
+		return nil
+	}
+	return crunch.Uncrunch(r.Callstack)
+}
+
+func (r *Report) upload(endpoint string) (string, error) {
+	return r.Reporter.sendReport(bytes.NewReader(r.Body), r.ContentType, endpoint)
+}
+
+func (r Reporter) encoder() encoder {
+	return encoder{
+		appName:    r.AppName,
+		appVersion: r.AppVersion,
+		osName:     r.OSName,
+		osVersion:  r.OSVersion,
+	}
+}
+
+func (r Reporter) stacktraceReport(s stacktrace.Callstack) (*Report, error) {
+	stack := s.String()
+	body, contentType, err := r.encoder().encodeStacktrace(stack)
+	if err != nil {
+		return nil, fmt.Errorf("Couldn't encode crash report: %v", err)
+	}
+	return r.report(body, contentType, &Report{
+		Stacktrace: stack,
+		Callstack:  crunch.Crunch(s),
+	})
+}
+
+func (r Reporter) minidumpReport(minidumpName string, minidumpData []byte) (*Report, error) {
+	body, contentType, err := r.encoder().encodeMinidump(minidumpName, minidumpData)
+	if err != nil {
+		return nil, fmt.Errorf("Couldn't encode minidump crash report: %v", err)
+	}
+	return r.report(body, contentType, &Report{Minidump: minidumpName})
+}
+
+func (r Reporter) report(body io.Reader, contentType string, out *Report) (*Report, error) {
+	data, err := ioutil.ReadAll(body)
+	if err != nil {
+		return nil, err
+	}
+	out.Reporter, out.Time, out.ContentType, out.Body = r, time.Now(), contentType, data
+	return out, nil
+}
+
+func (r Reporter) sendReport(body io.Reader, contentType, endpoint string) (string, error) {
+	appNameAndVersion := r.AppName + ":" + r.AppVersion
+	url := fmt.Sprintf("%v?product=%v&version=%v", endpoint, url.QueryEscape(crashProduct), url.QueryEscape(appNameAndVersion))
+
+	req, err := http.NewRequest("POST", url, body)
+	if err != nil {
+		return "", fmt.Errorf("Couldn't create new crash report request: %v", err)
+	}
+
+	req.Header.Set("Content-Type", contentType)
+
+	client := &http.Client{}
+	res, err := client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("Failed to upload report request: %v", err)
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("Failed to upload report request: %v", res.Status)
+	}
+
+	buf := new(bytes.Buffer)
+	if _, err := buf.ReadFrom(res.Body); err != nil {
+		return "", fmt.Errorf("Failed to write out response buffer: %v", err)
+	}
+
+	return buf.String(), nil
+}
@@ -19,12 +19,8 @@
 package reporting
 
 import (
-	"bytes"
 	"context"
 	"fmt"
-	"io"
-	"net/http"
-	"net/url"
 	"sync"
 
 	"github.com/google/gapid/core/app/crash"
@@ -33,15 +29,11 @@ import (
 	"github.com/google/gapid/core/os/device/host"
 )
 
-const (
-	crashStagingURL = "https://clients2.google.com/cr/staging_report"
-	crashProdURL    = "https://clients2.google.com/cr/report"
-	crashURL        = crashProdURL
-)
-
 var (
 	mutex   sync.Mutex
 	disable func()
+	upload  bool
+	spool   *Spool
 )
 
 // Enable turns on crash reporting if the running processes panics inside a
@@ -49,100 +41,124 @@ var (
 func Enable(ctx context.Context, appName, appVersion string) {
 	mutex.Lock()
 	defer mutex.Unlock()
-	if disable == nil {
-		disable = crash.Register(func(e interface{}, s stacktrace.Callstack) {
-			var osName, osVersion string
-			if h := host.Instance(ctx); h != nil {
-				if os := h.GetConfiguration().GetOS(); os != nil {
-					osName = os.GetName()
-					osVersion = fmt.Sprintf("%v %v.%v.%v", os.GetBuild(), os.GetMajorVersion(), os.GetMinorVersion(), os.GetPointVersion())
-				}
-			}
-			res, err := Reporter{
-				appName,
-				appVersion,
-				osName,
-				osVersion,
-			}.reportStacktrace(s, crashURL)
-			if err != nil {
-				log.E(ctx, "%v", err)
-			} else if res != "" {
-				log.I(ctx, "%v", res)
-			}
-		})
-	}
+	upload = true
+	register(ctx, appName, appVersion)
 }
 
 // Disable turns off crash reporting previously enabled by Enable()
+// Crash reports continue to be spooled if spooling is enabled.
 func Disable() {
 	mutex.Lock()
 	defer mutex.Unlock()
-	if disable != nil {
-		disable()
-		disable = nil
-	}
+	upload = false
+	unregister()
 }
 
-// ReportMinidump encodes and sends a minidump report to the crashURL endpoint.
-func ReportMinidump(r Reporter, minidumpName string, minidumpData []byte) (string, error) {
-	if disable != nil {
-		return r.reportMinidump(minidumpName, minidumpData, crashURL)
-	}
-	return "Error reporting disabled", nil
+// EnableSpooling turns on spooling of crash reports to the directory dir if
+// the running processes panics inside a crash.Go block.
+// Reports are spooled if crash reporting is disabled, or if uploading the
+// report fails. Spooled reports can be uploaded later with Spool.Upload.
+func EnableSpooling(ctx context.Context, appName, appVersion, dir string) {
+	mutex.Lock()
+	defer mutex.Unlock()
+	spool = &Spool{dir}
+	register(ctx, appName, appVersion)
 }
 
-func (r Reporter) sendReport(body io.Reader, contentType, endpoint string) (string, error) {
-	appNameAndVersion := r.AppName + ":" + r.AppVersion
-	url := fmt.Sprintf("%v?product=%v&version=%v", endpoint, url.QueryEscape(crashProduct), url.QueryEscape(appNameAndVersion))
+// DisableSpooling turns off crash report spooling previously enabled by
+// EnableSpooling().
+func DisableSpooling() {
+	mutex.Lock()
+	defer mutex.Unlock()
+	spool = nil
+	unregister()
+}
 
-	req, err := http.NewRequest("POST", url, body)
-	if err != nil {
-		return "", fmt.Errorf("Couldn't create new crash report request: %v", err)
+func register(ctx context.Context, appName, appVersion string) {
+	if disable != nil {
+		return
 	}
+	disable = crash.Register(func(e interface{}, s stacktrace.Callstack) {
+		var osName, osVersion string
+		if h := host.Instance(ctx); h != nil {
+			if os := h.GetConfiguration().GetOS(); os != nil {
+				osName = os.GetName()
+				osVersion = fmt.Sprintf("%v %v.%v.%v", os.GetBuild(), os.GetMajorVersion(), os.GetMinorVersion(), os.GetPointVersion())
+			}
+		}
+		report, err := Reporter{
+			appName,
+			appVersion,
+			osName,
+			osVersion,
+		}.stacktraceReport(s)
+		if err == nil {
+			var res string
+			res, err = deliver(report)
+			if err == nil && res != "" {
+				log.I(ctx, "%v", res)
+			}
+		}
+		if err != nil {
+			log.E(ctx, "%v", err)
+		}
+	})
+}
 
-	req.Header.Set("Content-Type", contentType)
+func unregister() {
+	if disable != nil && !upload && spool == nil {
+		disable()
+		disable = nil
+	}
+}
 
-	client := &http.Client{}
-	res, err := client.Do(req)
-	if err == nil {
-		defer res.Body.Close()
+// deliver uploads the report to the crashURL endpoint if crash reporting is
+// enabled, and spools it if the upload fails or crash reporting is disabled.
+func deliver(r *Report) (string, error) {
+	mutex.Lock()
+	upload, spool := upload, spool
+	mutex.Unlock()
+
+	var uploadErr error
+	if upload {
+		res, err := r.upload(crashURL)
+		if err == nil || spool == nil {
+			return res, err
+		}
+		uploadErr = err
 	}
-	if err != nil || res.StatusCode != http.StatusOK {
-		return "", fmt.Errorf("Failed to upload report request: %v (%v)", err, res.StatusCode)
+	if spool == nil {
+		return "Error reporting disabled", nil
 	}
-
-	buf := new(bytes.Buffer)
-	if _, err := buf.ReadFrom(res.Body); err != nil {
-		return "", fmt.Errorf("Failed to write out response buffer: %v", err)
+	if err := spool.Add(r); err != nil {
+		return "", fmt.Errorf("Couldn't spool crash report: %v", err)
 	}
-
-	return buf.String(), nil
+	if uploadErr != nil {
+		return fmt.Sprintf("%v. Crash report spooled as %v", uploadErr, r.ID), nil
+	}
+	return fmt.Sprintf("Crash report spooled as %v", r.ID), nil
 }
 
-func (r Reporter) reportStacktrace(s stacktrace.Callstack, endpoint string) (string, error) {
-	body, contentType, err := encoder{
-		appName:    r.AppName,
-		appVersion: r.AppVersion,
-		osName:     r.OSName,
-		osVersion:  r.OSVersion,
-	}.encodeStacktrace(s.String())
+// ReportMinidump encodes and sends a minidump report to the crashURL endpoint,
+// or spools it if spooling is enabled and the report cannot be sent.
+func ReportMinidump(r Reporter, minidumpName string, minidumpData []byte) (string, error) {
+	mutex.Lock()
+	enabled := disable != nil
+	mutex.Unlock()
+	if !enabled {
+		return "Error reporting disabled", nil
+	}
+	report, err := r.minidumpReport(minidumpName, minidumpData)
 	if err != nil {
-		return "", fmt.Errorf("Couldn't encode crash report: %v", err)
+		return "", err
 	}
-
-	return r.sendReport(body, contentType, endpoint)
+	return deliver(report)
 }
 
-func (r Reporter) reportMinidump(minidumpName string, minidumpData []byte, endpoint string) (string, error) {
-	body, contentType, err := encoder{
-		appName:    r.AppName,
-		appVersion: r.AppVersion,
-		osName:     r.OSName,
-		osVersion:  r.OSVersion,
-	}.encodeMinidump(minidumpName, minidumpData)
+func (r Reporter) reportStacktrace(s stacktrace.Callstack, endpoint string) (string, error) {
+	report, err := r.stacktraceReport(s)
 	if err != nil {
-		return "", fmt.Errorf("Couldn't encode minidump crash report: %v", err)
+		return "", err
 	}
-
-	return r.sendReport(body, contentType, endpoint)
+	return report.upload(endpoint)
 }
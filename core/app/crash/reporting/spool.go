@@ -0,0 +1,154 @@
+// Copyright (C) 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package reporting
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/google/gapid/core/fault"
+	"github.com/google/gapid/core/log"
+)
+
+// ErrReportNotFound is returned when a report is not in the spool.
+const ErrReportNotFound = fault.Const("Crash report not found")
+
+const spoolExt = ".crash.json"
+
+// Spool is a directory holding crash reports waiting to be uploaded.
+type Spool struct {
+	Dir string
+}
+
+// Add writes the report r to the spool, assigning r.ID.
+func (s Spool) Add(r *Report) error {
+	if err := os.MkdirAll(s.Dir, 0755); err != nil {
+		return err
+	}
+	data, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+	base := r.Time.UTC().Format("20060102-150405.000")
+	for i := 0; ; i++ {
+		id := base
+		if i > 0 {
+			id = fmt.Sprintf("%v-%d", base, i)
+		}
+		f, err := os.OpenFile(s.path(id), os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+		if os.IsExist(err) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		_, err = f.Write(data)
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+		if err != nil {
+			os.Remove(s.path(id))
+			return err
+		}
+		r.ID = id
+		return nil
+	}
+}
+
+// Reports returns all the reports in the spool, oldest first.
+func (s Spool) Reports() ([]*Report, error) {
+	files, err := ioutil.ReadDir(s.Dir)
+	switch {
+	case os.IsNotExist(err):
+		return nil, nil
+	case err != nil:
+		return nil, err
+	}
+	out := []*Report{}
+	for _, f := range files {
+		if f.IsDir() || !strings.HasSuffix(f.Name(), spoolExt) {
+			continue
+		}
+		r, err := s.Get(strings.TrimSuffix(f.Name(), spoolExt))
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, r)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if !out[i].Time.Equal(out[j].Time) {
+			return out[i].Time.Before(out[j].Time)
+		}
+		return out[i].ID < out[j].ID
+	})
+	return out, nil
+}
+
+// Get returns the report with the given identifier.
+func (s Spool) Get(id string) (*Report, error) {
+	data, err := ioutil.ReadFile(s.path(id))
+	if os.IsNotExist(err) {
+		return nil, ErrReportNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	r := &Report{}
+	if err := json.Unmarshal(data, r); err != nil {
+		return nil, fmt.Errorf("Couldn't decode crash report '%v': %v", id, err)
+	}
+	r.ID = id
+	return r, nil
+}
+
+// Remove deletes the report with the given identifier from the spool.
+func (s Spool) Remove(id string) error {
+	err := os.Remove(s.path(id))
+	if os.IsNotExist(err) {
+		return ErrReportNotFound
+	}
+	return err
+}
+
+// Upload sends the reports to the crash server at endpoint, removing each
+// report from the spool once it has been accepted. If endpoint is empty, the
+// reports are sent to the default crash server.
+func (s Spool) Upload(ctx context.Context, endpoint string, reports ...*Report) error {
+	if endpoint == "" {
+		endpoint = crashURL
+	}
+	for _, r := range reports {
+		ctx := log.V{"report": r.ID}.Bind(ctx)
+		res, err := r.upload(endpoint)
+		if err != nil {
+			return log.Err(ctx, err, "Uploading crash report")
+		}
+		log.I(ctx, "Uploaded crash report: %v", res)
+		if err := s.Remove(r.ID); err != nil {
+			return log.Err(ctx, err, "Removing uploaded crash report")
+		}
+	}
+	return nil
+}
+
+func (s Spool) path(id string) string {
+	return filepath.Join(s.Dir, id+spoolExt)
+}
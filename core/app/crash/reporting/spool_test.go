@@ -0,0 +1,78 @@
+// Copyright (C) 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package reporting
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/google/gapid/core/assert"
+	"github.com/google/gapid/core/fault/stacktrace"
+	"github.com/google/gapid/core/log"
+)
+
+func TestSpool(t *testing.T) {
+	ctx := log.Testing(t)
+
+	dir, err := ioutil.TempDir("", "crash-spool")
+	if !assert.For(ctx, "TempDir").ThatError(err).Succeeded() {
+		return
+	}
+	defer os.RemoveAll(dir)
+	spool := Spool{dir}
+
+	reporter := Reporter{AppName: "TestApp", AppVersion: "V1.2.3"}
+	stack := stacktrace.Capture()
+	first, err := reporter.stacktraceReport(stack)
+	assert.For(ctx, "stacktraceReport").ThatError(err).Succeeded()
+	second, err := reporter.minidumpReport("test.dmp", []byte{1, 2, 3})
+	assert.For(ctx, "minidumpReport").ThatError(err).Succeeded()
+	second.Time = first.Time
+
+	assert.For(ctx, "Add").ThatError(spool.Add(first)).Succeeded()
+	assert.For(ctx, "Add").ThatError(spool.Add(second)).Succeeded()
+	assert.For(ctx, "IDs").ThatString(second.ID).Equals(first.ID + "-1")
+
+	reports, err := spool.Reports()
+	assert.For(ctx, "Reports").ThatError(err).Succeeded()
+	if !assert.For(ctx, "Reports").ThatSlice(reports).IsLength(2) {
+		return
+	}
+	got := reports[0]
+	assert.For(ctx, "ID").ThatString(got.ID).Equals(first.ID)
+	assert.For(ctx, "Reporter").That(got.Reporter).Equals(reporter)
+	assert.For(ctx, "Stacktrace").ThatString(got.Stacktrace).Equals(stack.String())
+	assert.For(ctx, "Symbolize").ThatString(got.Symbolize().String()).Equals(stack.String())
+	assert.For(ctx, "Body").ThatSlice(got.Body).Equals(first.Body)
+	assert.For(ctx, "Minidump").ThatString(reports[1].Minidump).Equals("test.dmp")
+
+	uploaded := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		uploaded++
+		w.Write([]byte("report-id"))
+	}))
+	defer server.Close()
+
+	assert.For(ctx, "Upload").ThatError(spool.Upload(ctx, server.URL, reports...)).Succeeded()
+	assert.For(ctx, "uploaded").That(uploaded).Equals(2)
+	reports, err = spool.Reports()
+	assert.For(ctx, "Reports").ThatError(err).Succeeded()
+	assert.For(ctx, "Reports").ThatSlice(reports).IsEmpty()
+	_, err = spool.Get(first.ID)
+	assert.For(ctx, "Get").ThatError(err).Equals(ErrReportNotFound)
+}
@@ -31,6 +31,14 @@ func Enable(ctx context.Context, appName, appVersion string) {}
 // Disable turns off crash reporting previously enabled by Enable()
 func Disable() {}
 
+// EnableSpooling turns on spooling of crash reports to the directory dir if
+// the running processes panics inside a crash.Go block.
+func EnableSpooling(ctx context.Context, appName, appVersion, dir string) {}
+
+// DisableSpooling turns off crash report spooling previously enabled by
+// EnableSpooling().
+func DisableSpooling() {}
+
 // ReportMinidump encodes and sends a minidump report to the crashURL endpoint.
 func ReportMinidump(r Reporter, minidumpName string, minidumpData []byte) (string, error) {
 	return "", nil
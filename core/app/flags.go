@@ -14,7 +14,11 @@
 
 package app
 
-import "github.com/google/gapid/core/log"
+import (
+	"time"
+
+	"github.com/google/gapid/core/log"
+)
 
 type (
 	AppFlags struct {
@@ -23,16 +27,20 @@ type (
 		Profile     ProfileFlags
 		Analytics   string `help:"_If non-empty enable analytics using the specified user-id"`
 		CrashReport bool   `help:"_Automatically send crash reports to Google"`
+		CrashSpool  string `help:"_The directory to store crash reports in when they cannot be sent"`
 		DecodeStack string `help:"_Decode a stackdump generated by this executable"`
 		FullHelp    bool   `help:"_Display the full help"`
 		Args        string `help:"_A single string that will be parsed into extra individual arguments"`
 	}
 	LogFlags struct {
-		Level  log.Severity `help:"_The severity to enable logs at"`
-		Style  log.Style    `help:"_The style to use when printing the log"`
-		Stacks bool         `help:"_If true, stack traces are logged for all errors"`
-		File   string       `help:"_The file to store the logs in"`
-		Status bool         `help:"_Log status updates as they happen"`
+		Level       log.Severity  `help:"_The severity to enable logs at"`
+		Style       log.Style     `help:"_The style to use when printing the log"`
+		Stacks      bool          `help:"_If true, stack traces are logged for all errors"`
+		File        string        `help:"_The file to store the logs in"`
+		FileMaxSize int           `help:"_The size in megabytes at which the log file is rotated, 0 to not rotate by size"`
+		FileMaxAge  time.Duration `help:"_The age at which the log file is rotated, 0 to not rotate by age"`
+		FileBackups int           `help:"_The number of rotated log files to keep, 0 to keep them all"`
+		Status      bool          `help:"_Log status updates as they happen"`
 	}
 	ProfileFlags struct {
 		CPU   string `help:"_write cpu profile to file"`
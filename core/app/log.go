@@ -17,7 +17,6 @@ package app
 import (
 	"context"
 	"os"
-	"path/filepath"
 
 	"github.com/google/gapid/core/log"
 	"github.com/google/gapid/core/os/file"
@@ -31,9 +30,10 @@ var LogHandler log.Indirect
 
 func logDefaults() LogFlags {
 	return LogFlags{
-		Level:  log.Info,
-		Style:  log.Normal,
-		Stacks: true,
+		Level:       log.Info,
+		Style:       log.Normal,
+		Stacks:      true,
+		FileBackups: 5,
 	}
 }
 
@@ -66,18 +66,15 @@ func updateContext(ctx context.Context, flags *LogFlags) context.Context {
 	}
 	if flags.File != "" {
 		// Create the server logfile.
-		os.MkdirAll(filepath.Dir(flags.File), 0755)
-		file, err := os.Create(flags.File)
+		handler, err := log.RotatingFile(flags.File, flags.Style, log.Rotation{
+			MaxSize:    int64(flags.FileMaxSize) * 1024 * 1024,
+			MaxAge:     flags.FileMaxAge,
+			MaxBackups: flags.FileBackups,
+		})
 		if err != nil {
 			panic(err)
 		}
 		log.I(ctx, "Logging to: %v", flags.File)
-		// Build the logging context
-		handler := flags.Style.Handler(func(s string, _ log.Severity) {
-			file.WriteString(s)
-			file.WriteString("\n")
-		})
-		handler = log.OnClosed(handler, func() { file.Close() })
 		handler = wrapHandler(handler)
 		if old, _ := LogHandler.SetTarget(handler, false); old != nil {
 			old.Close()
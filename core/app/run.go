@@ -215,6 +215,10 @@ func Run(main task.Task) {
 		reporting.Enable(ctx, Name, Version.String())
 	}
 
+	if Flags.CrashSpool != "" {
+		reporting.EnableSpooling(ctx, Name, Version.String(), Flags.CrashSpool)
+	}
+
 	if Flags.Log.Status {
 		status.RegisterLogger(time.Second)
 	}
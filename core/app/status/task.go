@@ -73,6 +73,17 @@ func (t *Task) String() string {
 // ID returns the task's unique identifier.
 func (t *Task) ID() uint64 { t.mutex.RLock(); defer t.mutex.RUnlock(); return t.id }
 
+// ParentID returns the unique identifier of the task's parent, or 0 if the
+// task has no parent.
+func (t *Task) ParentID() uint64 {
+	t.mutex.RLock()
+	defer t.mutex.RUnlock()
+	if t.parent == nil {
+		return 0
+	}
+	return t.parent.ID()
+}
+
 // Name returns the task's name.
 func (t *Task) Name() string { t.mutex.RLock(); defer t.mutex.RUnlock(); return t.name }
 
@@ -0,0 +1,24 @@
+// Copyright (C) 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package apitrace provides a reader for the binary .trace files written by
+// apitrace (https://github.com/apitrace/apitrace).
+//
+// A trace file is either a snappy container, starting with the bytes 'a' 't'
+// followed by chunks of a little-endian uint32 length and a snappy block, or a
+// gzip stream. The decompressed stream starts with the format version and is
+// followed by a sequence of call enter and leave events. Function, enum,
+// bitmask and structure signatures are only written in full the first time
+// they are used, and referenced by identifier afterwards.
+package apitrace
@@ -0,0 +1,655 @@
+// Copyright (C) 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package apitrace
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+
+	"github.com/google/gapid/core/fault"
+)
+
+const (
+	// ErrNotATrace is returned by NewReader when the stream is not an apitrace
+	// trace file.
+	ErrNotATrace = fault.Const("Not an apitrace trace file")
+
+	// MaxVersion is the newest version of the trace format supported.
+	MaxVersion = 6
+
+	maxLength = 1 << 30
+)
+
+// Event types.
+const (
+	eventEnter = 0
+	eventLeave = 1
+)
+
+// Call details.
+const (
+	callEnd       = 0
+	callArg       = 1
+	callRet       = 2
+	callThread    = 3
+	callBacktrace = 4
+	callFlags     = 5
+)
+
+// Backtrace frame details.
+const (
+	frameEnd        = 0
+	frameModule     = 1
+	frameFunction   = 2
+	frameFilename   = 3
+	frameLineNumber = 4
+	frameOffset     = 5
+)
+
+// Value types.
+const (
+	typeNull    = 0
+	typeFalse   = 1
+	typeTrue    = 2
+	typeSInt    = 3
+	typeUInt    = 4
+	typeFloat   = 5
+	typeDouble  = 6
+	typeString  = 7
+	typeBlob    = 8
+	typeEnum    = 9
+	typeBitmask = 10
+	typeArray   = 11
+	typeStruct  = 12
+	typeOpaque  = 13
+	typeRepr    = 14
+	typeWString = 15
+)
+
+// Call is a single traced function call.
+type Call struct {
+	// No is the index of the call in the trace.
+	No uint64
+	// Thread is the identifier of the thread that made the call.
+	Thread uint64
+	// Name is the name of the called function.
+	Name string
+	// ArgNames are the names of the function's parameters.
+	ArgNames []string
+	// Args are the argument values of the call, indexed by parameter.
+	Args []Arg
+	// Ret is the return value of the call, or nil for void functions.
+	Ret Value
+	// Flags holds the apitrace call flags.
+	Flags uint64
+	// Incomplete is true if the trace ended before the call returned.
+	Incomplete bool
+}
+
+// Arg is an argument value of a Call.
+type Arg struct {
+	Value Value
+	// Output is true if the value was recorded when the call returned, which
+	// apitrace does for the data written by the call through pointers.
+	Output bool
+}
+
+// Value is a traced value. It is one of nil, bool, int64, uint64, float32,
+// float64, string, []byte, Enum, Bitmask, []Value, Struct or Opaque.
+type Value interface{}
+
+// Enum is a value of an enumerated type.
+type Enum struct {
+	// Name is the name of the enumerator, or empty if the value is not one of
+	// the type's enumerators.
+	Name  string
+	Value int64
+}
+
+// Bitmask is a value of a bitfield type.
+type Bitmask struct {
+	Value uint64
+}
+
+// Struct is a value of a structure type.
+type Struct struct {
+	Name    string
+	Members []string
+	Values  []Value
+}
+
+// Opaque is a pointer value whose pointee was not recorded.
+type Opaque uint64
+
+// IsTrace returns true if header, the first bytes of a file, identifies an
+// apitrace trace file.
+func IsTrace(header []byte) bool {
+	if len(header) < 2 {
+		return false
+	}
+	switch {
+	case header[0] == 'a' && header[1] == 't': // snappy
+		return true
+	case header[0] == 0x1f && header[1] == 0x8b: // gzip
+		return true
+	}
+	return false
+}
+
+type functionSig struct {
+	name     string
+	argNames []string
+}
+
+type enumSig map[int64]string
+
+type structSig struct {
+	name    string
+	members []string
+}
+
+// Reader reads the calls of an apitrace trace.
+type Reader struct {
+	r       *bufio.Reader
+	version uint64
+	// Properties holds the trace properties stored in the header of traces of
+	// version 6 and above.
+	Properties map[string]string
+
+	functions map[uint64]*functionSig
+	enums     map[uint64]enumSig
+	bitmasks  map[uint64]bool
+	structs   map[uint64]*structSig
+	frames    map[uint64]bool
+
+	nextCallNo uint64
+	pending    map[uint64]*Call
+	incomplete []*Call
+}
+
+// NewReader returns a Reader reading the trace from r.
+func NewReader(r io.Reader) (*Reader, error) {
+	br := bufio.NewReader(r)
+	magic, err := br.Peek(2)
+	if err != nil || !IsTrace(magic) {
+		return nil, ErrNotATrace
+	}
+
+	var stream io.Reader
+	if magic[0] == 'a' {
+		br.Discard(2)
+		stream = &snappyReader{r: br}
+	} else {
+		if stream, err = gzip.NewReader(br); err != nil {
+			return nil, err
+		}
+	}
+
+	out := &Reader{
+		r:          bufio.NewReader(stream),
+		Properties: map[string]string{},
+		functions:  map[uint64]*functionSig{},
+		enums:      map[uint64]enumSig{},
+		bitmasks:   map[uint64]bool{},
+		structs:    map[uint64]*structSig{},
+		frames:     map[uint64]bool{},
+		pending:    map[uint64]*Call{},
+	}
+	if out.version, err = out.uint(); err != nil {
+		return nil, err
+	}
+	if out.version > MaxVersion {
+		return nil, fmt.Errorf("Unsupported apitrace trace version %d (newest supported is %d)", out.version, MaxVersion)
+	}
+	if out.version >= 6 {
+		if _, err := out.uint(); err != nil { // Semantic version
+			return nil, err
+		}
+		for {
+			name, err := out.string()
+			if err != nil {
+				return nil, err
+			}
+			if name == "" {
+				break
+			}
+			if out.Properties[name], err = out.string(); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return out, nil
+}
+
+// Version returns the format version of the trace.
+func (r *Reader) Version() uint64 { return r.version }
+
+// Next returns the next call of the trace, in the order the calls returned.
+// Calls that had not returned by the end of the trace are returned last, in
+// the order they were made, with Incomplete set.
+// Next returns io.EOF once all the calls have been returned.
+func (r *Reader) Next() (*Call, error) {
+	for {
+		if r.incomplete != nil {
+			if len(r.incomplete) == 0 {
+				return nil, io.EOF
+			}
+			c := r.incomplete[0]
+			r.incomplete = r.incomplete[1:]
+			return c, nil
+		}
+
+		event, err := r.r.ReadByte()
+		if err == io.EOF {
+			r.flushIncomplete()
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		switch event {
+		case eventEnter:
+			c, err := r.enter()
+			if err != nil {
+				return nil, err
+			}
+			r.pending[c.No] = c
+		case eventLeave:
+			no, err := r.uint()
+			if err != nil {
+				return nil, err
+			}
+			c, ok := r.pending[no]
+			if !ok {
+				return nil, fmt.Errorf("Leave event for unknown call %d", no)
+			}
+			delete(r.pending, no)
+			if err := r.details(c, true); err != nil {
+				return nil, err
+			}
+			return c, nil
+		default:
+			return nil, fmt.Errorf("Unknown event type %d", event)
+		}
+	}
+}
+
+func (r *Reader) flushIncomplete() {
+	r.incomplete = make([]*Call, 0, len(r.pending))
+	for _, c := range r.pending {
+		c.Incomplete = true
+		r.incomplete = append(r.incomplete, c)
+	}
+	sort.Slice(r.incomplete, func(i, j int) bool { return r.incomplete[i].No < r.incomplete[j].No })
+	r.pending = map[uint64]*Call{}
+}
+
+func (r *Reader) enter() (*Call, error) {
+	c := &Call{No: r.nextCallNo}
+	r.nextCallNo++
+
+	if r.version >= 4 {
+		thread, err := r.uint()
+		if err != nil {
+			return nil, err
+		}
+		c.Thread = thread
+	}
+
+	id, err := r.uint()
+	if err != nil {
+		return nil, err
+	}
+	sig, ok := r.functions[id]
+	if !ok {
+		sig = &functionSig{}
+		if sig.name, err = r.string(); err != nil {
+			return nil, err
+		}
+		count, err := r.length()
+		if err != nil {
+			return nil, err
+		}
+		sig.argNames = make([]string, count)
+		for i := range sig.argNames {
+			if sig.argNames[i], err = r.string(); err != nil {
+				return nil, err
+			}
+		}
+		r.functions[id] = sig
+	}
+	c.Name, c.ArgNames = sig.name, sig.argNames
+	c.Args = make([]Arg, len(sig.argNames))
+
+	return c, r.details(c, false)
+}
+
+// details reads the call details of an enter or leave event into c.
+func (r *Reader) details(c *Call, leave bool) error {
+	for {
+		detail, err := r.r.ReadByte()
+		if err != nil {
+			return unexpected(err)
+		}
+		switch detail {
+		case callEnd:
+			return nil
+		case callArg:
+			index, err := r.length()
+			if err != nil {
+				return err
+			}
+			v, err := r.value()
+			if err != nil {
+				return err
+			}
+			for len(c.Args) <= index {
+				c.Args = append(c.Args, Arg{})
+			}
+			c.Args[index] = Arg{Value: v, Output: leave}
+		case callRet:
+			if c.Ret, err = r.value(); err != nil {
+				return err
+			}
+		case callThread:
+			if c.Thread, err = r.uint(); err != nil {
+				return err
+			}
+		case callBacktrace:
+			if err := r.backtrace(); err != nil {
+				return err
+			}
+		case callFlags:
+			if c.Flags, err = r.uint(); err != nil {
+				return err
+			}
+		default:
+			return fmt.Errorf("Unknown call detail %d in call %v", detail, c.Name)
+		}
+	}
+}
+
+// backtrace reads and discards a call backtrace.
+func (r *Reader) backtrace() error {
+	count, err := r.length()
+	if err != nil {
+		return err
+	}
+	for i := 0; i < count; i++ {
+		id, err := r.uint()
+		if err != nil {
+			return err
+		}
+		if r.frames[id] {
+			continue
+		}
+		r.frames[id] = true
+	frame:
+		for {
+			detail, err := r.r.ReadByte()
+			if err != nil {
+				return unexpected(err)
+			}
+			switch detail {
+			case frameEnd:
+				break frame
+			case frameModule, frameFunction, frameFilename:
+				_, err = r.string()
+			case frameLineNumber, frameOffset:
+				_, err = r.uint()
+			default:
+				return fmt.Errorf("Unknown backtrace frame detail %d", detail)
+			}
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (r *Reader) value() (Value, error) {
+	ty, err := r.r.ReadByte()
+	if err != nil {
+		return nil, unexpected(err)
+	}
+	switch ty {
+	case typeNull:
+		return nil, nil
+	case typeFalse:
+		return false, nil
+	case typeTrue:
+		return true, nil
+	case typeSInt:
+		v, err := r.uint()
+		return -int64(v), err
+	case typeUInt:
+		return r.uint()
+	case typeFloat:
+		var b [4]byte
+		if _, err := io.ReadFull(r.r, b[:]); err != nil {
+			return nil, unexpected(err)
+		}
+		return math.Float32frombits(binary.LittleEndian.Uint32(b[:])), nil
+	case typeDouble:
+		var b [8]byte
+		if _, err := io.ReadFull(r.r, b[:]); err != nil {
+			return nil, unexpected(err)
+		}
+		return math.Float64frombits(binary.LittleEndian.Uint64(b[:])), nil
+	case typeString:
+		return r.string()
+	case typeBlob:
+		return r.bytes()
+	case typeEnum:
+		return r.enum()
+	case typeBitmask:
+		return r.bitmask()
+	case typeArray:
+		count, err := r.length()
+		if err != nil {
+			return nil, err
+		}
+		out := make([]Value, count)
+		for i := range out {
+			if out[i], err = r.value(); err != nil {
+				return nil, err
+			}
+		}
+		return out, nil
+	case typeStruct:
+		return r.structure()
+	case typeOpaque:
+		v, err := r.uint()
+		return Opaque(v), err
+	case typeRepr:
+		// A human readable representation followed by the machine value.
+		if _, err := r.value(); err != nil {
+			return nil, err
+		}
+		return r.value()
+	case typeWString:
+		count, err := r.length()
+		if err != nil {
+			return nil, err
+		}
+		out := make([]rune, count)
+		for i := range out {
+			c, err := r.uint()
+			if err != nil {
+				return nil, err
+			}
+			out[i] = rune(c)
+		}
+		return string(out), nil
+	default:
+		return nil, fmt.Errorf("Unknown value type %d", ty)
+	}
+}
+
+func (r *Reader) enum() (Value, error) {
+	id, err := r.uint()
+	if err != nil {
+		return nil, err
+	}
+	sig, ok := r.enums[id]
+	if !ok {
+		count, err := r.length()
+		if err != nil {
+			return nil, err
+		}
+		sig = enumSig{}
+		for i := 0; i < count; i++ {
+			name, err := r.string()
+			if err != nil {
+				return nil, err
+			}
+			v, err := r.sint()
+			if err != nil {
+				return nil, err
+			}
+			if _, dup := sig[v]; !dup {
+				sig[v] = name
+			}
+		}
+		r.enums[id] = sig
+	}
+	v, err := r.sint()
+	if err != nil {
+		return nil, err
+	}
+	return Enum{Name: sig[v], Value: v}, nil
+}
+
+func (r *Reader) bitmask() (Value, error) {
+	id, err := r.uint()
+	if err != nil {
+		return nil, err
+	}
+	if !r.bitmasks[id] {
+		count, err := r.length()
+		if err != nil {
+			return nil, err
+		}
+		for i := 0; i < count; i++ {
+			if _, err := r.string(); err != nil {
+				return nil, err
+			}
+			if _, err := r.uint(); err != nil {
+				return nil, err
+			}
+		}
+		r.bitmasks[id] = true
+	}
+	v, err := r.uint()
+	return Bitmask{Value: v}, err
+}
+
+func (r *Reader) structure() (Value, error) {
+	id, err := r.uint()
+	if err != nil {
+		return nil, err
+	}
+	sig, ok := r.structs[id]
+	if !ok {
+		sig = &structSig{}
+		if sig.name, err = r.string(); err != nil {
+			return nil, err
+		}
+		count, err := r.length()
+		if err != nil {
+			return nil, err
+		}
+		sig.members = make([]string, count)
+		for i := range sig.members {
+			if sig.members[i], err = r.string(); err != nil {
+				return nil, err
+			}
+		}
+		r.structs[id] = sig
+	}
+	out := Struct{Name: sig.name, Members: sig.members, Values: make([]Value, len(sig.members))}
+	for i := range out.Values {
+		if out.Values[i], err = r.value(); err != nil {
+			return nil, err
+		}
+	}
+	return out, nil
+}
+
+// sint reads a signed integer, encoded as a typed SINT or UINT value.
+func (r *Reader) sint() (int64, error) {
+	ty, err := r.r.ReadByte()
+	if err != nil {
+		return 0, unexpected(err)
+	}
+	v, err := r.uint()
+	switch ty {
+	case typeSInt:
+		return -int64(v), err
+	case typeUInt:
+		return int64(v), err
+	default:
+		return 0, fmt.Errorf("Expected signed integer, got value type %d", ty)
+	}
+}
+
+func (r *Reader) uint() (uint64, error) {
+	v, err := binary.ReadUvarint(r.r)
+	return v, unexpected(err)
+}
+
+func (r *Reader) length() (int, error) {
+	v, err := r.uint()
+	if err != nil {
+		return 0, err
+	}
+	if v > maxLength {
+		return 0, fmt.Errorf("Length %d is too large", v)
+	}
+	return int(v), nil
+}
+
+func (r *Reader) bytes() ([]byte, error) {
+	n, err := r.length()
+	if err != nil {
+		return nil, err
+	}
+	out := make([]byte, n)
+	if _, err := io.ReadFull(r.r, out); err != nil {
+		return nil, unexpected(err)
+	}
+	return out, nil
+}
+
+func (r *Reader) string() (string, error) {
+	b, err := r.bytes()
+	return string(b), err
+}
+
+// unexpected converts io.EOF errors to io.ErrUnexpectedEOF, as the trace
+// may only end between events.
+func unexpected(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
@@ -0,0 +1,213 @@
+// Copyright (C) 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package apitrace
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"io"
+	"math"
+	"testing"
+
+	"github.com/google/gapid/core/assert"
+	"github.com/google/gapid/core/log"
+)
+
+// traceWriter encodes an uncompressed trace stream.
+type traceWriter struct{ bytes.Buffer }
+
+func (w *traceWriter) uint(v uint64) {
+	var b [binary.MaxVarintLen64]byte
+	w.Write(b[:binary.PutUvarint(b[:], v)])
+}
+
+func (w *traceWriter) string(s string) {
+	w.uint(uint64(len(s)))
+	w.WriteString(s)
+}
+
+func testTrace() []byte {
+	w := &traceWriter{}
+	w.uint(6) // version
+	w.uint(2) // semantic version
+	w.string("process.name")
+	w.string("app")
+	w.string("")
+
+	// glClearColor(0.5, 0, 0, 1), with the full signature.
+	w.WriteByte(eventEnter)
+	w.uint(1) // thread
+	w.uint(0) // signature id
+	w.string("glClearColor")
+	w.uint(4)
+	for _, n := range []string{"red", "green", "blue", "alpha"} {
+		w.string(n)
+	}
+	for i, v := range []float32{0.5, 0, 0, 1} {
+		w.WriteByte(callArg)
+		w.uint(uint64(i))
+		w.WriteByte(typeFloat)
+		binary.Write(w, binary.LittleEndian, math.Float32bits(v))
+	}
+	w.WriteByte(callEnd)
+
+	// glGenBuffers(1, [7]) entered before glClearColor returns.
+	w.WriteByte(eventEnter)
+	w.uint(1)
+	w.uint(1)
+	w.string("glGenBuffers")
+	w.uint(2)
+	w.string("n")
+	w.string("buffers")
+	w.WriteByte(callArg)
+	w.uint(0)
+	w.WriteByte(typeSInt)
+	w.uint(1) // -1
+	w.WriteByte(callEnd)
+
+	w.WriteByte(eventLeave)
+	w.uint(0)
+	w.WriteByte(callEnd)
+
+	w.WriteByte(eventLeave)
+	w.uint(1)
+	w.WriteByte(callArg)
+	w.uint(1)
+	w.WriteByte(typeArray)
+	w.uint(1)
+	w.WriteByte(typeUInt)
+	w.uint(7)
+	w.WriteByte(callEnd)
+
+	// glEnable(GL_BLEND), reusing the enum signature. It never returns.
+	w.WriteByte(eventEnter)
+	w.uint(2)
+	w.uint(2)
+	w.string("glEnable")
+	w.uint(1)
+	w.string("cap")
+	for i := 0; i < 2; i++ {
+		w.WriteByte(callArg)
+		w.uint(0)
+		w.WriteByte(typeEnum)
+		w.uint(0) // enum signature id
+		if i == 0 {
+			w.uint(1)
+			w.string("GL_BLEND")
+			w.WriteByte(typeUInt)
+			w.uint(0x0BE2)
+		}
+		w.WriteByte(typeUInt)
+		w.uint(0x0BE2)
+	}
+	w.WriteByte(callRet)
+	w.WriteByte(typeOpaque)
+	w.uint(0x1234)
+	w.WriteByte(callEnd)
+
+	return w.Bytes()
+}
+
+// snappyContainer wraps data in a snappy container made of literal blocks.
+func snappyContainer(data []byte) []byte {
+	out := &bytes.Buffer{}
+	out.WriteString("at")
+	block := &traceWriter{}
+	block.uint(uint64(len(data)))
+	n := len(data) - 1
+	block.WriteByte(61 << 2)
+	block.WriteByte(byte(n))
+	block.WriteByte(byte(n >> 8))
+	block.Write(data)
+	binary.Write(out, binary.LittleEndian, uint32(block.Len()))
+	out.Write(block.Bytes())
+	return out.Bytes()
+}
+
+func gzipStream(data []byte) []byte {
+	out := &bytes.Buffer{}
+	w := gzip.NewWriter(out)
+	w.Write(data)
+	w.Close()
+	return out.Bytes()
+}
+
+func TestReader(t *testing.T) {
+	ctx := log.Testing(t)
+	data := testTrace()
+
+	for _, test := range []struct {
+		name string
+		file []byte
+	}{
+		{"snappy", snappyContainer(data)},
+		{"gzip", gzipStream(data)},
+	} {
+		ctx := log.Enter(ctx, test.name)
+		r, err := NewReader(bytes.NewReader(test.file))
+		assert.For(ctx, "NewReader").ThatError(err).Succeeded()
+		assert.For(ctx, "Version").That(r.Version()).Equals(uint64(6))
+		assert.For(ctx, "Properties").That(r.Properties["process.name"]).Equals("app")
+
+		calls := []*Call{}
+		for {
+			c, err := r.Next()
+			if err == io.EOF {
+				break
+			}
+			assert.For(ctx, "Next").ThatError(err).Succeeded()
+			calls = append(calls, c)
+		}
+		assert.For(ctx, "calls").That(len(calls)).Equals(3)
+
+		assert.For(ctx, "call 0").That(calls[0].Name).Equals("glClearColor")
+		assert.For(ctx, "call 0 args").ThatSlice(calls[0].Args).DeepEquals([]Arg{
+			{Value: float32(0.5)}, {Value: float32(0)}, {Value: float32(0)}, {Value: float32(1)},
+		})
+
+		assert.For(ctx, "call 1").That(calls[1].Name).Equals("glGenBuffers")
+		assert.For(ctx, "call 1 args").ThatSlice(calls[1].Args).DeepEquals([]Arg{
+			{Value: int64(-1)}, {Value: []Value{uint64(7)}, Output: true},
+		})
+
+		assert.For(ctx, "call 2").That(calls[2].Name).Equals("glEnable")
+		assert.For(ctx, "call 2 thread").That(calls[2].Thread).Equals(uint64(2))
+		assert.For(ctx, "call 2 incomplete").That(calls[2].Incomplete).Equals(true)
+		assert.For(ctx, "call 2 args").ThatSlice(calls[2].Args).DeepEquals([]Arg{
+			{Value: Enum{Name: "GL_BLEND", Value: 0x0BE2}},
+		})
+		assert.For(ctx, "call 2 ret").That(calls[2].Ret).Equals(Opaque(0x1234))
+	}
+
+	_, err := NewReader(bytes.NewReader([]byte("protopack")))
+	assert.For(ctx, "not a trace").ThatError(err).Equals(ErrNotATrace)
+}
+
+func TestDecodeSnappy(t *testing.T) {
+	ctx := log.Testing(t)
+	block := []byte{
+		11,                    // decoded length
+		2 << 2, 'a', 'b', 'c', // literal "abc"
+		(5-4)<<2 | snappyTagCopy1, 3, // copy 5 bytes from offset 3
+		(3-1)<<2 | snappyTagCopy2, 8, 0, // copy 3 bytes from offset 8
+	}
+	got, err := decodeSnappy(nil, block)
+	assert.For(ctx, "err").ThatError(err).Succeeded()
+	assert.For(ctx, "decoded").That(string(got)).Equals("abcabcababc")
+
+	_, err = decodeSnappy(nil, []byte{4, snappyTagCopy2, 1, 0})
+	assert.For(ctx, "bad offset").ThatError(err).Equals(ErrCorruptSnappy)
+}
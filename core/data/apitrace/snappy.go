@@ -0,0 +1,159 @@
+// Copyright (C) 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package apitrace
+
+import (
+	"encoding/binary"
+	"io"
+
+	"github.com/google/gapid/core/fault"
+)
+
+const (
+	// ErrCorruptSnappy is returned when a snappy block cannot be decoded.
+	ErrCorruptSnappy = fault.Const("Corrupt snappy block")
+
+	snappyTagLiteral = 0
+	snappyTagCopy1   = 1
+	snappyTagCopy2   = 2
+	snappyTagCopy4   = 3
+
+	// maxSnappyChunkSize is the upper bound of the size of a compressed chunk
+	// accepted by the snappy container reader. apitrace writes chunks of at
+	// most 1MB of uncompressed data.
+	maxSnappyChunkSize = 64 << 20
+)
+
+// snappyReader is an io.Reader decompressing the apitrace snappy container.
+type snappyReader struct {
+	r          io.Reader
+	compressed []byte
+	chunk      []byte
+	pos        int
+}
+
+func (s *snappyReader) Read(p []byte) (int, error) {
+	for s.pos >= len(s.chunk) {
+		if err := s.next(); err != nil {
+			return 0, err
+		}
+	}
+	n := copy(p, s.chunk[s.pos:])
+	s.pos += n
+	return n, nil
+}
+
+// next reads and decodes the next chunk of the container.
+func (s *snappyReader) next() error {
+	var length [4]byte
+	if _, err := io.ReadFull(s.r, length[:]); err != nil {
+		return err // Includes io.EOF at the end of the container.
+	}
+	size := binary.LittleEndian.Uint32(length[:])
+	if size > maxSnappyChunkSize {
+		return ErrCorruptSnappy
+	}
+	if cap(s.compressed) < int(size) {
+		s.compressed = make([]byte, size)
+	}
+	s.compressed = s.compressed[:size]
+	if _, err := io.ReadFull(s.r, s.compressed); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return err
+	}
+	chunk, err := decodeSnappy(s.chunk[:0], s.compressed)
+	if err != nil {
+		return err
+	}
+	s.chunk, s.pos = chunk, 0
+	return nil
+}
+
+// decodeSnappy decodes the snappy block src, appending the decoded bytes to
+// dst.
+func decodeSnappy(dst, src []byte) ([]byte, error) {
+	size, n := binary.Uvarint(src)
+	if n <= 0 || size > maxSnappyChunkSize {
+		return nil, ErrCorruptSnappy
+	}
+	src = src[n:]
+	if cap(dst) < int(size) {
+		dst = make([]byte, 0, size)
+	}
+	dst = dst[:0]
+
+	for len(src) > 0 {
+		tag := src[0]
+		var length, offset int
+		switch tag & 3 {
+		case snappyTagLiteral:
+			length = int(tag >> 2)
+			src = src[1:]
+			if length >= 60 {
+				extra := length - 59
+				if len(src) < extra {
+					return nil, ErrCorruptSnappy
+				}
+				length = 0
+				for i := extra - 1; i >= 0; i-- {
+					length = length<<8 | int(src[i])
+				}
+				src = src[extra:]
+			}
+			length++
+			if len(src) < length {
+				return nil, ErrCorruptSnappy
+			}
+			dst = append(dst, src[:length]...)
+			src = src[length:]
+			continue
+		case snappyTagCopy1:
+			if len(src) < 2 {
+				return nil, ErrCorruptSnappy
+			}
+			length = 4 + int(tag>>2)&7
+			offset = int(tag>>5)<<8 | int(src[1])
+			src = src[2:]
+		case snappyTagCopy2:
+			if len(src) < 3 {
+				return nil, ErrCorruptSnappy
+			}
+			length = 1 + int(tag>>2)
+			offset = int(binary.LittleEndian.Uint16(src[1:]))
+			src = src[3:]
+		case snappyTagCopy4:
+			if len(src) < 5 {
+				return nil, ErrCorruptSnappy
+			}
+			length = 1 + int(tag>>2)
+			offset = int(binary.LittleEndian.Uint32(src[1:]))
+			src = src[5:]
+		}
+		if offset <= 0 || offset > len(dst) {
+			return nil, ErrCorruptSnappy
+		}
+		// Copies may overlap their own output, so copy byte by byte.
+		start := len(dst) - offset
+		for i := 0; i < length; i++ {
+			dst = append(dst, dst[start+i])
+		}
+	}
+	if uint64(len(dst)) != size {
+		return nil, ErrCorruptSnappy
+	}
+	return dst, nil
+}
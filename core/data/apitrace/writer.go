@@ -0,0 +1,242 @@
+// Copyright (C) 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package apitrace
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"strings"
+)
+
+// Writer writes calls as a gzip compressed trace, in the newest version of the
+// trace format. It is mostly useful to build traces for tests.
+type Writer struct {
+	gz         *gzip.Writer
+	w          *bufio.Writer
+	functions  map[string]uint64
+	enums      map[Enum]uint64
+	structs    map[string]uint64
+	bitmask    bool
+	nextCallNo uint64
+	err        error
+}
+
+// NewWriter returns a Writer writing the trace to w, with the given trace
+// properties.
+func NewWriter(w io.Writer, properties map[string]string) (*Writer, error) {
+	gz := gzip.NewWriter(w)
+	out := &Writer{
+		gz:        gz,
+		w:         bufio.NewWriter(gz),
+		functions: map[string]uint64{},
+		enums:     map[Enum]uint64{},
+		structs:   map[string]uint64{},
+	}
+	out.uint(MaxVersion)
+	out.uint(MaxVersion) // Semantic version
+	for name, value := range properties {
+		out.string(name)
+		out.string(value)
+	}
+	out.string("")
+	return out, out.err
+}
+
+// Write writes the call c. The call is entered with its input arguments, and
+// left with its output arguments and return value. The number and the
+// incomplete flag of c are ignored.
+// Non-negative int64 values are written as unsigned integers, and are read
+// back as uint64 values.
+func (w *Writer) Write(c *Call) error {
+	no := w.nextCallNo
+	w.nextCallNo++
+
+	w.w.WriteByte(eventEnter)
+	w.uint(c.Thread)
+	names := make([]string, len(c.Args))
+	copy(names, c.ArgNames)
+	key := c.Name + "(" + strings.Join(names, ",") + ")"
+	id, ok := w.functions[key]
+	if ok {
+		w.uint(id)
+	} else {
+		id = uint64(len(w.functions))
+		w.functions[key] = id
+		w.uint(id)
+		w.string(c.Name)
+		w.uint(uint64(len(names)))
+		for _, n := range names {
+			w.string(n)
+		}
+	}
+	w.args(c, false)
+	if c.Flags != 0 {
+		w.w.WriteByte(callFlags)
+		w.uint(c.Flags)
+	}
+	w.w.WriteByte(callEnd)
+
+	w.w.WriteByte(eventLeave)
+	w.uint(no)
+	w.args(c, true)
+	if c.Ret != nil {
+		w.w.WriteByte(callRet)
+		w.value(c.Ret)
+	}
+	w.w.WriteByte(callEnd)
+	return w.err
+}
+
+// Close flushes the trace and closes the compressed stream. It does not close
+// the underlying writer.
+func (w *Writer) Close() error {
+	if err := w.w.Flush(); err != nil && w.err == nil {
+		w.err = err
+	}
+	if err := w.gz.Close(); err != nil && w.err == nil {
+		w.err = err
+	}
+	return w.err
+}
+
+func (w *Writer) args(c *Call, output bool) {
+	for i, a := range c.Args {
+		if a.Output == output {
+			w.w.WriteByte(callArg)
+			w.uint(uint64(i))
+			w.value(a.Value)
+		}
+	}
+}
+
+func (w *Writer) value(v Value) {
+	switch v := v.(type) {
+	case nil:
+		w.w.WriteByte(typeNull)
+	case bool:
+		if v {
+			w.w.WriteByte(typeTrue)
+		} else {
+			w.w.WriteByte(typeFalse)
+		}
+	case int64:
+		w.sint(v)
+	case uint64:
+		w.w.WriteByte(typeUInt)
+		w.uint(v)
+	case float32:
+		w.w.WriteByte(typeFloat)
+		binary.Write(w.w, binary.LittleEndian, math.Float32bits(v))
+	case float64:
+		w.w.WriteByte(typeDouble)
+		binary.Write(w.w, binary.LittleEndian, math.Float64bits(v))
+	case string:
+		w.w.WriteByte(typeString)
+		w.string(v)
+	case []byte:
+		w.w.WriteByte(typeBlob)
+		w.uint(uint64(len(v)))
+		w.w.Write(v)
+	case Enum:
+		w.w.WriteByte(typeEnum)
+		id, ok := w.enums[v]
+		if ok {
+			w.uint(id)
+		} else {
+			id = uint64(len(w.enums))
+			w.enums[v] = id
+			w.uint(id)
+			if v.Name == "" {
+				w.uint(0)
+			} else {
+				w.uint(1)
+				w.string(v.Name)
+				w.sint(v.Value)
+			}
+		}
+		w.sint(v.Value)
+	case Bitmask:
+		w.w.WriteByte(typeBitmask)
+		w.uint(0) // All bitmasks share a signature without flag names.
+		if !w.bitmask {
+			w.bitmask = true
+			w.uint(0)
+		}
+		w.uint(v.Value)
+	case []Value:
+		w.w.WriteByte(typeArray)
+		w.uint(uint64(len(v)))
+		for _, e := range v {
+			w.value(e)
+		}
+	case Struct:
+		w.w.WriteByte(typeStruct)
+		key := v.Name + "{" + strings.Join(v.Members, ",") + "}"
+		id, ok := w.structs[key]
+		if ok {
+			w.uint(id)
+		} else {
+			id = uint64(len(w.structs))
+			w.structs[key] = id
+			w.uint(id)
+			w.string(v.Name)
+			w.uint(uint64(len(v.Members)))
+			for _, m := range v.Members {
+				w.string(m)
+			}
+		}
+		for i := range v.Members {
+			var e Value
+			if i < len(v.Values) {
+				e = v.Values[i]
+			}
+			w.value(e)
+		}
+	case Opaque:
+		w.w.WriteByte(typeOpaque)
+		w.uint(uint64(v))
+	default:
+		if w.err == nil {
+			w.err = fmt.Errorf("Cannot write value of type %T", v)
+		}
+		w.w.WriteByte(typeNull)
+	}
+}
+
+func (w *Writer) sint(v int64) {
+	if v < 0 {
+		w.w.WriteByte(typeSInt)
+		w.uint(uint64(-v))
+	} else {
+		w.w.WriteByte(typeUInt)
+		w.uint(uint64(v))
+	}
+}
+
+func (w *Writer) uint(v uint64) {
+	var b [binary.MaxVarintLen64]byte
+	if _, err := w.w.Write(b[:binary.PutUvarint(b[:], v)]); err != nil && w.err == nil {
+		w.err = err
+	}
+}
+
+func (w *Writer) string(s string) {
+	w.uint(uint64(len(s)))
+	w.w.WriteString(s)
+}
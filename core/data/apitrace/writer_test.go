@@ -0,0 +1,72 @@
+// Copyright (C) 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package apitrace
+
+import (
+	"bytes"
+	"io"
+	"testing"
+
+	"github.com/google/gapid/core/assert"
+	"github.com/google/gapid/core/log"
+)
+
+func TestWriter(t *testing.T) {
+	ctx := log.Testing(t)
+
+	calls := []*Call{
+		{No: 0, Thread: 1, Name: "glClearColor", ArgNames: []string{"red", "green", "blue", "alpha"},
+			Args: []Arg{{Value: float32(0.5)}, {Value: float32(0)}, {Value: float32(0)}, {Value: float32(1)}}},
+		{No: 1, Thread: 1, Name: "glGenBuffers", ArgNames: []string{"n", "buffers"},
+			Args: []Arg{{Value: int64(-1)}, {Value: []Value{uint64(7)}, Output: true}}},
+		{No: 2, Thread: 2, Name: "glEnable", ArgNames: []string{"cap"},
+			Args: []Arg{{Value: Enum{Name: "GL_BLEND", Value: 0x0BE2}}}},
+		{No: 3, Thread: 2, Name: "glEnable", ArgNames: []string{"cap"},
+			Args: []Arg{{Value: Enum{Name: "GL_BLEND", Value: 0x0BE2}}}},
+		{No: 4, Thread: 2, Name: "glClear", ArgNames: []string{"mask"},
+			Args: []Arg{{Value: Bitmask{Value: 0x4100}}}},
+		{No: 5, Thread: 2, Name: "eglGetCurrentContext", ArgNames: []string{},
+			Args: []Arg{}, Ret: Opaque(0x1234)},
+		{No: 6, Thread: 2, Name: "glShaderSource", ArgNames: []string{"shader", "count", "string", "length"},
+			Args: []Arg{{Value: uint64(3)}, {Value: uint64(1)}, {Value: []Value{"void main() {}"}}, {Value: nil}}},
+		{No: 7, Thread: 2, Name: "glBufferData", ArgNames: []string{"target", "size", "data", "usage"},
+			Args: []Arg{{Value: uint64(0x8892)}, {Value: uint64(3)}, {Value: []byte{1, 2, 3}}, {Value: true}}},
+		{No: 8, Thread: 2, Name: "eglGetConfigAttrib", ArgNames: []string{"config"},
+			Args: []Arg{{Value: Struct{Name: "Config", Members: []string{"id", "scale"}, Values: []Value{uint64(1), float64(2)}}}}},
+	}
+
+	buf := &bytes.Buffer{}
+	w, err := NewWriter(buf, map[string]string{"process.name": "app"})
+	assert.For(ctx, "NewWriter").ThatError(err).Succeeded()
+	for _, c := range calls {
+		assert.For(ctx, "Write %v", c.Name).ThatError(w.Write(c)).Succeeded()
+	}
+	assert.For(ctx, "Close").ThatError(w.Close()).Succeeded()
+
+	r, err := NewReader(buf)
+	assert.For(ctx, "NewReader").ThatError(err).Succeeded()
+	assert.For(ctx, "Properties").That(r.Properties["process.name"]).Equals("app")
+	for _, expected := range calls {
+		got, err := r.Next()
+		assert.For(ctx, "Next").ThatError(err).Succeeded()
+		assert.For(ctx, "%v", expected.Name).That(got).DeepEquals(expected)
+	}
+	_, err = r.Next()
+	assert.For(ctx, "EOF").ThatError(err).Equals(io.EOF)
+
+	w, _ = NewWriter(&bytes.Buffer{}, nil)
+	err = w.Write(&Call{Name: "f", Args: []Arg{{Value: 1}}})
+	assert.For(ctx, "bad value").ThatError(err).Failed()
+}
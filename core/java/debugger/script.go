@@ -0,0 +1,238 @@
+// Copyright (C) 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package debugger
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/google/gapid/core/log"
+)
+
+// ScriptHelp describes the commands understood by Run.
+const ScriptHelp = `Commands, one per line ('#' starts a comment):
+  threads                      lists the threads
+  stacks                       dumps the stacks of all the threads
+  stack <thread>               dumps the stack of the named thread
+  break <class> <method>       resumes until the method is entered
+  get <class> <field>          prints the value of a static field
+  call <class> <method> [arg]  invokes a static method on the stopped thread
+  suspend                      suspends all the threads
+  resume                       resumes all the threads
+Arguments are integers, true, false, null or "quoted strings".`
+
+type command struct {
+	args int // Required number of arguments, or -1 for at least 2.
+	run  func(ctx context.Context, s *Session, w io.Writer, args []interface{}) error
+}
+
+var commands = map[string]command{
+	"threads": {0, func(ctx context.Context, s *Session, w io.Writer, args []interface{}) error {
+		threads, err := s.Threads(ctx)
+		if err != nil {
+			return err
+		}
+		for _, t := range threads {
+			printThread(w, t)
+		}
+		return nil
+	}},
+	"stacks": {0, func(ctx context.Context, s *Session, w io.Writer, args []interface{}) error {
+		threads, err := s.Threads(ctx)
+		if err != nil {
+			return err
+		}
+		for _, t := range threads {
+			printThread(w, t)
+			if err := printStack(ctx, s, w, t); err != nil {
+				return err
+			}
+		}
+		return nil
+	}},
+	"stack": {1, func(ctx context.Context, s *Session, w io.Writer, args []interface{}) error {
+		t, err := s.FindThread(ctx, fmt.Sprint(args[0]))
+		if err != nil {
+			return err
+		}
+		return printStack(ctx, s, w, t)
+	}},
+	"break": {2, func(ctx context.Context, s *Session, w io.Writer, args []interface{}) error {
+		thread, err := s.Break(ctx, fmt.Sprint(args[0]), fmt.Sprint(args[1]))
+		if err != nil {
+			return err
+		}
+		name, err := s.conn.GetThreadName(thread)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(w, "Hit %v.%v on thread %q\n", args[0], args[1], name)
+		return nil
+	}},
+	"get": {2, func(ctx context.Context, s *Session, w io.Writer, args []interface{}) error {
+		v, err := s.Get(ctx, fmt.Sprint(args[0]), fmt.Sprint(args[1]))
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(w, "%v.%v = %v\n", args[0], args[1], format(v))
+		return nil
+	}},
+	"call": {-1, func(ctx context.Context, s *Session, w io.Writer, args []interface{}) error {
+		v, err := s.Call(ctx, fmt.Sprint(args[0]), fmt.Sprint(args[1]), args[2:]...)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(w, "%v.%v() = %v\n", args[0], args[1], format(v))
+		return nil
+	}},
+	"suspend": {0, func(ctx context.Context, s *Session, w io.Writer, args []interface{}) error {
+		return s.Suspend(ctx)
+	}},
+	"resume": {0, func(ctx context.Context, s *Session, w io.Writer, args []interface{}) error {
+		return s.Resume(ctx)
+	}},
+}
+
+// Run executes the script read from r on the session, writing the results of
+// the commands to w. Run stops at the first failing command.
+func (s *Session) Run(ctx context.Context, r io.Reader, w io.Writer) error {
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		ctx := log.V{"line": line}.Bind(ctx)
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || text[0] == '#' {
+			continue
+		}
+		if err := s.Exec(ctx, text, w); err != nil {
+			return log.Errf(ctx, err, "Executing '%v'", text)
+		}
+	}
+	return scanner.Err()
+}
+
+// Exec executes a single script command on the session, writing its result to
+// w.
+func (s *Session) Exec(ctx context.Context, text string, w io.Writer) error {
+	words, err := split(text)
+	if err != nil {
+		return err
+	}
+	if len(words) == 0 {
+		return nil
+	}
+	cmd, ok := commands[words[0]]
+	if !ok {
+		return fmt.Errorf("Unknown command '%v'", words[0])
+	}
+	args := make([]interface{}, len(words)-1)
+	for i, word := range words[1:] {
+		args[i] = parseArg(word)
+	}
+	switch {
+	case cmd.args >= 0 && len(args) != cmd.args:
+		return fmt.Errorf("'%v' takes %d arguments, got %d", words[0], cmd.args, len(args))
+	case cmd.args < 0 && len(args) < 2:
+		return fmt.Errorf("'%v' takes at least 2 arguments, got %d", words[0], len(args))
+	}
+	return cmd.run(ctx, s, w, args)
+}
+
+func printThread(w io.Writer, t Thread) {
+	suspended := ""
+	if t.Suspended {
+		suspended = " (suspended)"
+	}
+	fmt.Fprintf(w, "Thread %q: %v%v\n", t.Name, t.Status, suspended)
+}
+
+func printStack(ctx context.Context, s *Session, w io.Writer, t Thread) error {
+	if !t.Suspended {
+		fmt.Fprintf(w, "  <running>\n")
+		return nil
+	}
+	frames, err := s.Stack(ctx, t.ID)
+	if err != nil {
+		return err
+	}
+	for _, f := range frames {
+		fmt.Fprintf(w, "  at %v\n", f)
+	}
+	return nil
+}
+
+// split splits the command text into words, keeping the quotes of quoted
+// strings and dropping any trailing comment.
+func split(text string) ([]string, error) {
+	words := []string{}
+	for text = strings.TrimSpace(text); text != "" && text[0] != '#'; text = strings.TrimSpace(text) {
+		end := strings.IndexAny(text, " \t")
+		if text[0] == '"' {
+			end = -1
+			for i := 1; i < len(text); i++ {
+				if text[i] == '\\' {
+					i++
+				} else if text[i] == '"' {
+					end = i + 1
+					break
+				}
+			}
+			if end < 0 {
+				return nil, fmt.Errorf("Unterminated string: %v", text)
+			}
+		}
+		if end < 0 {
+			end = len(text)
+		}
+		words = append(words, text[:end])
+		text = text[end:]
+	}
+	return words, nil
+}
+
+// parseArg converts the script word to the value passed to the debugger.
+func parseArg(word string) interface{} {
+	if s, err := strconv.Unquote(word); err == nil && word[0] == '"' {
+		return s
+	}
+	if i, err := strconv.ParseInt(word, 0, 32); err == nil {
+		return int(i)
+	}
+	if i, err := strconv.ParseInt(word, 0, 64); err == nil {
+		return i
+	}
+	switch word {
+	case "true":
+		return true
+	case "false":
+		return false
+	case "null":
+		return nil
+	}
+	return word
+}
+
+func format(v interface{}) string {
+	if s, ok := v.(string); ok {
+		return strconv.Quote(s)
+	}
+	if v == nil {
+		return "null"
+	}
+	return fmt.Sprint(v)
+}
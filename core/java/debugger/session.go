@@ -0,0 +1,220 @@
+// Copyright (C) 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package debugger provides high level debugging operations on a JDWP
+// connection, and a simple line based script language to drive them.
+package debugger
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/google/gapid/core/event/task"
+	"github.com/google/gapid/core/fault"
+	"github.com/google/gapid/core/java/jdbg"
+	"github.com/google/gapid/core/java/jdwp"
+)
+
+const (
+	// ErrNoThread is returned when an operation needs a suspended thread but
+	// no breakpoint has been hit yet.
+	ErrNoThread = fault.Const("No suspended thread. Use break to stop a thread first")
+	// ErrThreadNotFound is returned when a thread cannot be found by name.
+	ErrThreadNotFound = fault.Const("Thread not found")
+)
+
+// Session is a debugging session on a JDWP connection.
+type Session struct {
+	conn   *jdwp.Connection
+	thread jdwp.ThreadID
+}
+
+// Thread describes a thread of the debugged VM.
+type Thread struct {
+	ID        jdwp.ThreadID
+	Name      string
+	Status    jdwp.ThreadStatus
+	Suspended bool
+}
+
+// Frame describes a single frame of a thread's stack.
+type Frame struct {
+	Class    string
+	Method   string
+	Location uint64
+}
+
+func (f Frame) String() string {
+	return fmt.Sprintf("%v.%v:%d", f.Class, f.Method, f.Location)
+}
+
+// NewSession returns a new debugging session using the connection conn.
+func NewSession(conn *jdwp.Connection) *Session {
+	return &Session{conn: conn}
+}
+
+// Thread returns the thread stopped by the last breakpoint, or 0 if no
+// breakpoint has been hit.
+func (s *Session) Thread() jdwp.ThreadID { return s.thread }
+
+// Threads returns all the threads of the VM.
+func (s *Session) Threads(ctx context.Context) ([]Thread, error) {
+	ids, err := s.conn.GetAllThreads()
+	if err != nil {
+		return nil, err
+	}
+	out := make([]Thread, len(ids))
+	for i, id := range ids {
+		out[i].ID = id
+		if out[i].Name, err = s.conn.GetThreadName(id); err != nil {
+			return nil, err
+		}
+		status, suspended, err := s.conn.GetThreadStatus(id)
+		if err != nil {
+			return nil, err
+		}
+		out[i].Status, out[i].Suspended = status, suspended == jdwp.Suspended
+	}
+	return out, nil
+}
+
+// FindThread returns the thread with the given name.
+func (s *Session) FindThread(ctx context.Context, name string) (Thread, error) {
+	threads, err := s.Threads(ctx)
+	if err != nil {
+		return Thread{}, err
+	}
+	for _, t := range threads {
+		if t.Name == name {
+			return t, nil
+		}
+	}
+	return Thread{}, ErrThreadNotFound
+}
+
+// Stack returns the call stack of the thread, innermost frame first.
+// The thread must be suspended.
+func (s *Session) Stack(ctx context.Context, thread jdwp.ThreadID) ([]Frame, error) {
+	frames, err := s.conn.GetFrames(thread, 0, -1)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]Frame, len(frames))
+	for i, f := range frames {
+		sig, err := s.conn.GetTypeSignature(jdwp.ReferenceTypeID(f.Location.Class))
+		if err != nil {
+			return nil, err
+		}
+		method, err := s.conn.GetLocationMethodName(f.Location)
+		if err != nil {
+			return nil, err
+		}
+		out[i] = Frame{Class: className(sig), Method: method, Location: f.Location.Location}
+	}
+	return out, nil
+}
+
+// Break resumes the VM and blocks until the named method of the class is
+// entered. All the threads are suspended when Break returns, and the thread
+// that entered the method is used for the following invocations.
+func (s *Session) Break(ctx context.Context, class, method string) (jdwp.ThreadID, error) {
+	c, err := s.conn.GetClassBySignature(classSignature(class))
+	if err != nil {
+		return 0, err
+	}
+	methods, err := s.conn.GetMethods(c.TypeID)
+	if err != nil {
+		return 0, err
+	}
+	var m *jdwp.Method
+	for i := range methods {
+		if methods[i].Name == method {
+			m = &methods[i]
+			break
+		}
+	}
+	if m == nil {
+		return 0, fmt.Errorf("Class '%v' has no method '%v'", class, method)
+	}
+	entry, err := s.conn.WaitForMethodEntry(ctx, c.ClassID(), m.ID)
+	if err != nil {
+		return 0, err
+	}
+	if entry == nil {
+		return 0, task.StopReason(ctx)
+	}
+	s.thread = entry.Thread
+	return entry.Thread, nil
+}
+
+// Suspend suspends all the threads of the VM.
+func (s *Session) Suspend(ctx context.Context) error {
+	return s.conn.SuspendAll()
+}
+
+// Resume resumes all the threads of the VM. The thread stopped by the last
+// breakpoint can no longer be used for invocations.
+func (s *Session) Resume(ctx context.Context) error {
+	s.thread = 0
+	return s.conn.ResumeAll()
+}
+
+// Get returns the value of the static field of the class.
+func (s *Session) Get(ctx context.Context, class, field string) (interface{}, error) {
+	c, err := s.conn.GetClassBySignature(classSignature(class))
+	if err != nil {
+		return nil, err
+	}
+	fields, err := s.conn.GetFields(c.TypeID)
+	if err != nil {
+		return nil, err
+	}
+	f := fields.FindByName(field)
+	if f == nil {
+		return nil, fmt.Errorf("Class '%v' has no field '%v'", class, field)
+	}
+	values, err := s.conn.GetStaticFieldValues(c.TypeID, f.ID)
+	if err != nil {
+		return nil, err
+	}
+	if str, ok := values[0].(jdwp.StringID); ok && str != 0 {
+		return s.conn.GetString(str)
+	}
+	return values[0], nil
+}
+
+// Call invokes the static method of the class with the given arguments on the
+// thread stopped by the last breakpoint, returning the method's result.
+func (s *Session) Call(ctx context.Context, class, method string, args ...interface{}) (interface{}, error) {
+	if s.thread == 0 {
+		return nil, ErrNoThread
+	}
+	var res interface{}
+	err := jdbg.Do(s.conn, s.thread, func(j *jdbg.JDbg) error {
+		res = j.Class(class).Call(method, args...).Get()
+		return nil
+	})
+	return res, err
+}
+
+// classSignature returns the JNI signature of the class with the dotted name.
+func classSignature(name string) string {
+	return "L" + strings.Replace(name, ".", "/", -1) + ";"
+}
+
+// className returns the dotted name of the class with the JNI signature.
+func className(sig string) string {
+	return strings.Replace(strings.TrimSuffix(strings.TrimPrefix(sig, "L"), ";"), "/", ".", -1)
+}
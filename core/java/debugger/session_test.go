@@ -0,0 +1,108 @@
+// Copyright (C) 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package debugger_test
+
+import (
+	"bytes"
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/google/gapid/core/assert"
+	"github.com/google/gapid/core/event/task"
+	"github.com/google/gapid/core/java/debugger"
+	"github.com/google/gapid/core/java/jdwp"
+	"github.com/google/gapid/core/java/jdwp/fake"
+	"github.com/google/gapid/core/log"
+)
+
+func TestScript(t *testing.T) {
+	ctx := log.Testing(t)
+	ctx, cancel := task.WithCancel(ctx)
+	defer cancel()
+
+	loader := &fake.Class{
+		Signature: "Lcom/example/Loader;",
+		Fields: []*fake.Field{
+			{Name: "LIB", Signature: "Ljava/lang/String;", ModBits: jdwp.ModStatic, Value: "libgapii.so"},
+			{Name: "COUNT", Signature: "I", ModBits: jdwp.ModStatic, Value: 3},
+		},
+		Methods: []*fake.Method{
+			{Name: "main", Signature: "()V", ModBits: jdwp.ModStatic},
+			{Name: "onCreate", Signature: "()V", ModBits: jdwp.ModStatic},
+			{Name: "load", Signature: "(Ljava/lang/String;)I", ModBits: jdwp.ModStatic,
+				Invoke: func(args ...interface{}) interface{} { return len(args[0].(string)) },
+			},
+		},
+	}
+	main := &fake.Thread{
+		Name:  "main",
+		Stack: []fake.Frame{{Class: loader, Method: loader.Method("main"), Location: 4}},
+		Calls: []fake.Frame{{Class: loader, Method: loader.Method("onCreate")}},
+	}
+	worker := &fake.Thread{Name: "worker", Status: jdwp.ThreadWait}
+	vm := fake.NewVM([]*fake.Class{loader}, []*fake.Thread{main, worker})
+
+	client, server := net.Pipe()
+	defer client.Close()
+	go vm.Serve(ctx, server)
+
+	conn, err := jdwp.Open(ctx, client)
+	if !assert.For(ctx, "Open").ThatError(err).Succeeded() {
+		return
+	}
+	s := debugger.NewSession(conn)
+
+	script := `
+# Inspect the VM before the app starts.
+threads
+get com.example.Loader LIB
+get com.example.Loader COUNT
+
+break com.example.Loader onCreate
+stack main
+call com.example.Loader load "libfoo.so" # Returns the length.
+`
+	out := &bytes.Buffer{}
+	err = s.Run(ctx, strings.NewReader(script), out)
+	assert.For(ctx, "Run").ThatError(err).Succeeded()
+	assert.For(ctx, "output").ThatString(out.String()).Equals(
+		`Thread "main": Running (suspended)
+Thread "worker": Wait (suspended)
+com.example.Loader.LIB = "libgapii.so"
+com.example.Loader.COUNT = 3
+Hit com.example.Loader.onCreate on thread "main"
+  at com.example.Loader.onCreate:0
+  at com.example.Loader.main:4
+com.example.Loader.load() = 9
+`)
+
+	for _, test := range []struct {
+		cmd string
+		err string
+	}{
+		{"jump", "Unknown command 'jump'"},
+		{"get com.example.Loader", "'get' takes 2 arguments, got 1"},
+		{"get com.example.Loader MISSING", "Class 'com.example.Loader' has no field 'MISSING'"},
+		{"stack ui", debugger.ErrThreadNotFound.Error()},
+	} {
+		err := s.Exec(ctx, test.cmd, out)
+		assert.For(ctx, "%v", test.cmd).ThatError(err).HasMessage(test.err)
+	}
+
+	assert.For(ctx, "Resume").ThatError(s.Resume(ctx)).Succeeded()
+	_, err = s.Call(ctx, "com.example.Loader", "load", "x")
+	assert.For(ctx, "Call after resume").ThatError(err).Equals(debugger.ErrNoThread)
+}
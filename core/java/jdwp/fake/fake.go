@@ -0,0 +1,345 @@
+// Copyright (C) 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package fake implements a JDWP server backed by a scripted virtual machine,
+// so that JDWP clients can be tested without a JVM.
+//
+// The fake virtual machine holds a fixed set of classes and threads. Each
+// thread has a list of method calls it makes when the virtual machine is
+// resumed, raising method entry and breakpoint events as it goes.
+package fake
+
+import (
+	"strings"
+	"sync"
+
+	"github.com/google/gapid/core/java/jdwp"
+)
+
+// VM is a fake Java virtual machine.
+type VM struct {
+	// Classes are the loaded classes, including the java.lang classes added
+	// by NewVM.
+	Classes []*Class
+	// Threads are the running threads.
+	Threads []*Thread
+
+	mutex    sync.Mutex
+	nextID   uint64
+	objects  map[uint64]*object
+	requests map[jdwp.EventRequestID]*request
+	nextReq  jdwp.EventRequestID
+	suspends int
+	conn     *conn
+}
+
+// Class is a class of a fake VM.
+type Class struct {
+	// Signature is the JNI signature of the class, for example
+	// "Landroid/app/Application;".
+	Signature string
+	// Super is the super class, or nil for java.lang.Object.
+	Super   *Class
+	Fields  []*Field
+	Methods []*Method
+
+	id uint64
+}
+
+// Field is a field of a Class.
+type Field struct {
+	Name      string
+	Signature string
+	ModBits   jdwp.ModBits
+	// Value is the value of a static field. Go strings are converted to
+	// java.lang.String objects.
+	Value interface{}
+
+	id uint64
+}
+
+// Method is a method of a Class.
+type Method struct {
+	Name      string
+	Signature string
+	ModBits   jdwp.ModBits
+	// Invoke is called when the method is invoked by the debugger, and
+	// returns the method's result. Go strings arguments and results are
+	// converted from and to java.lang.String objects. If Invoke is nil the
+	// method returns void.
+	Invoke func(args ...interface{}) interface{}
+
+	id uint64
+}
+
+// Thread is a thread of a fake VM.
+type Thread struct {
+	Name   string
+	Status jdwp.ThreadStatus
+	// Stack is the thread's call stack, innermost frame first.
+	Stack []Frame
+	// Calls are the methods the thread will enter when the VM is resumed,
+	// in order.
+	Calls []Frame
+
+	id       uint64
+	suspends int
+}
+
+// Frame is a method call of a Thread.
+type Frame struct {
+	Class    *Class
+	Method   *Method
+	Location uint64
+}
+
+type object struct {
+	class *Class
+	str   string
+}
+
+type request struct {
+	kind     jdwp.EventKind
+	policy   jdwp.SuspendPolicy
+	count    int
+	class    uint64
+	match    []string
+	exclude  []string
+	thread   uint64
+	location *jdwp.Location
+}
+
+// Java classes every VM has, as used by jdbg.
+var javaLangClasses = []string{
+	"Boolean", "Byte", "Character", "Short", "Integer", "Long", "Float", "Double",
+}
+
+// NewVM returns a new fake virtual machine with the given classes and
+// threads, plus the basic java.lang classes.
+// The VM starts suspended, as if started with suspend=y.
+func NewVM(classes []*Class, threads []*Thread) *VM {
+	obj := &Class{Signature: "Ljava/lang/Object;"}
+	number := &Class{Signature: "Ljava/lang/Number;", Super: obj}
+	vm := &VM{
+		Classes: []*Class{
+			obj,
+			number,
+			{Signature: "Ljava/lang/String;", Super: obj},
+			{Signature: "Ljava/lang/Thread;", Super: obj},
+		},
+		Threads:  threads,
+		objects:  map[uint64]*object{},
+		requests: map[jdwp.EventRequestID]*request{},
+		nextReq:  1,
+		suspends: 1,
+	}
+	for _, n := range javaLangClasses {
+		super := number
+		if n == "Boolean" || n == "Character" {
+			super = obj
+		}
+		vm.Classes = append(vm.Classes, &Class{Signature: "Ljava/lang/" + n + ";", Super: super})
+	}
+	for _, c := range classes {
+		if c.Super == nil {
+			c.Super = obj
+		}
+		vm.Classes = append(vm.Classes, c)
+	}
+	for _, c := range vm.Classes {
+		c.id = vm.newID()
+		for _, f := range c.Fields {
+			f.id = vm.newID()
+		}
+		for _, m := range c.Methods {
+			m.id = vm.newID()
+		}
+	}
+	for _, t := range vm.Threads {
+		t.id = vm.newID()
+		if t.Status == 0 {
+			t.Status = jdwp.ThreadRunning
+		}
+	}
+	return vm
+}
+
+// Class returns the class with the given JNI signature, or nil.
+func (vm *VM) Class(signature string) *Class {
+	for _, c := range vm.Classes {
+		if c.Signature == signature {
+			return c
+		}
+	}
+	return nil
+}
+
+// Method returns the method with the given name, or nil.
+func (c *Class) Method(name string) *Method {
+	for _, m := range c.Methods {
+		if m.Name == name {
+			return m
+		}
+	}
+	return nil
+}
+
+// name returns the dotted name of the class, as used by class match
+// patterns.
+func (c *Class) name() string {
+	return strings.Replace(strings.TrimSuffix(strings.TrimPrefix(c.Signature, "L"), ";"), "/", ".", -1)
+}
+
+func (vm *VM) newID() uint64 {
+	vm.nextID++
+	return vm.nextID
+}
+
+func (vm *VM) classByID(id uint64) *Class {
+	for _, c := range vm.Classes {
+		if c.id == id {
+			return c
+		}
+	}
+	return nil
+}
+
+func (vm *VM) threadByID(id uint64) *Thread {
+	for _, t := range vm.Threads {
+		if t.id == id {
+			return t
+		}
+	}
+	return nil
+}
+
+func (c *Class) fieldByID(id uint64) *Field {
+	for _, f := range c.Fields {
+		if f.id == id {
+			return f
+		}
+	}
+	return nil
+}
+
+// methodByID returns the method with the given identifier declared by c or
+// one of its super classes.
+func (c *Class) methodByID(id uint64) *Method {
+	for ; c != nil; c = c.Super {
+		for _, m := range c.Methods {
+			if m.id == id {
+				return m
+			}
+		}
+	}
+	return nil
+}
+
+// newString returns a new java.lang.String object holding s.
+func (vm *VM) newString(s string) uint64 {
+	id := vm.newID()
+	vm.objects[id] = &object{class: vm.Class("Ljava/lang/String;"), str: s}
+	return id
+}
+
+func (t *Thread) suspended(vm *VM) bool {
+	return vm.suspends > 0 || t.suspends > 0
+}
+
+// run makes the calls of the resumed threads, until all the calls are made
+// or an event suspends the threads.
+func (vm *VM) run() {
+	for _, t := range vm.Threads {
+		for len(t.Calls) > 0 && !t.suspended(vm) {
+			call := t.Calls[0]
+			t.Calls = t.Calls[1:]
+			t.Stack = append([]Frame{call}, t.Stack...)
+			vm.raise(jdwp.MethodEntry, t, call)
+			vm.raise(jdwp.Breakpoint, t, call)
+		}
+	}
+}
+
+// raise sends the events of the given kind matching the call on thread t.
+func (vm *VM) raise(kind jdwp.EventKind, t *Thread, call Frame) {
+	policy, ids := jdwp.SuspendNone, []jdwp.EventRequestID{}
+	for id, r := range vm.requests {
+		if r.kind != kind || !r.matches(t, call) {
+			continue
+		}
+		if r.count > 0 {
+			if r.count--; r.count == 0 {
+				delete(vm.requests, id)
+			}
+		}
+		ids = append(ids, id)
+		if r.policy > policy {
+			policy = r.policy
+		}
+	}
+	if len(ids) == 0 {
+		return
+	}
+	switch policy {
+	case jdwp.SuspendAll:
+		vm.suspends++
+	case jdwp.SuspendEventThread:
+		t.suspends++
+	}
+	vm.sendEvents(kind, policy, ids, t, call)
+}
+
+func (r *request) matches(t *Thread, call Frame) bool {
+	if r.thread != 0 && r.thread != t.id {
+		return false
+	}
+	if r.class != 0 {
+		match := false
+		for c := call.Class; c != nil; c = c.Super {
+			match = match || c.id == r.class
+		}
+		if !match {
+			return false
+		}
+	}
+	for _, p := range r.match {
+		if !matchClass(p, call.Class.name()) {
+			return false
+		}
+	}
+	for _, p := range r.exclude {
+		if matchClass(p, call.Class.name()) {
+			return false
+		}
+	}
+	if l := r.location; l != nil {
+		if l.Class != jdwp.ClassID(call.Class.id) || l.Method != jdwp.MethodID(call.Method.id) || l.Location != call.Location {
+			return false
+		}
+	}
+	return true
+}
+
+// matchClass returns true if name matches the class pattern p, which may
+// start or end with a '*' wildcard.
+func matchClass(p, name string) bool {
+	switch {
+	case strings.HasPrefix(p, "*"):
+		return strings.HasSuffix(name, p[1:])
+	case strings.HasSuffix(p, "*"):
+		return strings.HasPrefix(name, p[:len(p)-1])
+	default:
+		return p == name
+	}
+}
@@ -0,0 +1,565 @@
+// Copyright (C) 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fake
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"sort"
+
+	"github.com/google/gapid/core/data/binary"
+	"github.com/google/gapid/core/data/endian"
+	"github.com/google/gapid/core/event/task"
+	"github.com/google/gapid/core/java/jdwp"
+	"github.com/google/gapid/core/os/device"
+)
+
+const (
+	handshake     = "JDWP-Handshake"
+	packetIsReply = 0x80
+	idSize        = 8
+
+	cmdSetEvent       = 64
+	cmdCompositeEvent = 100
+)
+
+// conn is a JDWP connection to a debugger.
+type conn struct {
+	w          binary.Writer
+	nextPacket uint32
+}
+
+// Serve handles the JDWP commands sent by the debugger on rw until the
+// debugger disposes of the VM, the connection is closed or ctx is stopped.
+func (vm *VM) Serve(ctx context.Context, rw io.ReadWriter) error {
+	got := make([]byte, len(handshake))
+	if _, err := io.ReadFull(rw, got); err != nil {
+		return err
+	}
+	if string(got) != handshake {
+		return fmt.Errorf("Bad handshake: %q", got)
+	}
+	if _, err := rw.Write([]byte(handshake)); err != nil {
+		return err
+	}
+
+	r := endian.Reader(rw, device.BigEndian)
+	c := &conn{w: endian.Writer(rw, device.BigEndian), nextPacket: 1 << 31}
+
+	vm.mutex.Lock()
+	vm.conn = c
+	vm.mutex.Unlock()
+	defer func() {
+		vm.mutex.Lock()
+		vm.conn = nil
+		vm.mutex.Unlock()
+	}()
+
+	for !task.Stopped(ctx) {
+		length := r.Uint32()
+		id := r.Uint32()
+		r.Uint8() // flags
+		set, cmd := r.Uint8(), r.Uint8()
+		if err := r.Error(); err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return err
+		}
+		if length < 11 {
+			return fmt.Errorf("Packet length too short (%d)", length)
+		}
+		data := make([]byte, length-11)
+		r.Data(data)
+		if err := r.Error(); err != nil {
+			return err
+		}
+
+		vm.mutex.Lock()
+		reply := &bytes.Buffer{}
+		req := reader{endian.Reader(bytes.NewReader(data), device.BigEndian)}
+		res := writer{endian.Writer(reply, device.BigEndian)}
+		after, jdwpErr := vm.handle(set, cmd, req, res)
+		if jdwpErr != jdwp.ErrNone {
+			reply.Reset()
+		}
+		c.reply(id, jdwpErr, reply.Bytes())
+		if after != nil {
+			after()
+		}
+		err := c.w.Error()
+		vm.mutex.Unlock()
+
+		if err != nil {
+			return err
+		}
+		if set == 1 && cmd == 6 { // VirtualMachine.Dispose
+			return nil
+		}
+	}
+	return nil
+}
+
+func (c *conn) reply(id uint32, err jdwp.Error, data []byte) {
+	c.w.Uint32(11 + uint32(len(data)))
+	c.w.Uint32(id)
+	c.w.Uint8(packetIsReply)
+	c.w.Uint16(uint16(err))
+	c.w.Data(data)
+}
+
+func (c *conn) command(set, cmd uint8, data []byte) {
+	c.w.Uint32(11 + uint32(len(data)))
+	c.w.Uint32(c.nextPacket)
+	c.nextPacket++
+	c.w.Uint8(0)
+	c.w.Uint8(set)
+	c.w.Uint8(cmd)
+	c.w.Data(data)
+}
+
+// sendEvents sends a composite event packet holding an event for each of the
+// requests.
+func (vm *VM) sendEvents(kind jdwp.EventKind, policy jdwp.SuspendPolicy, ids []jdwp.EventRequestID, t *Thread, call Frame) {
+	if vm.conn == nil {
+		return
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	buf := &bytes.Buffer{}
+	w := writer{endian.Writer(buf, device.BigEndian)}
+	w.Uint8(uint8(policy))
+	w.Uint32(uint32(len(ids)))
+	for _, id := range ids {
+		w.Uint8(uint8(kind))
+		w.Int32(int32(id))
+		w.id(t.id)
+		w.location(call)
+	}
+	vm.conn.command(cmdSetEvent, cmdCompositeEvent, buf.Bytes())
+}
+
+// handle decodes the request for the command cmd in the command set set from
+// r and writes the reply to w. The returned function, if not nil, is called
+// after the reply is sent.
+func (vm *VM) handle(set, cmd uint8, r reader, w writer) (func(), jdwp.Error) {
+	switch set {
+	case 1: // VirtualMachine
+		switch cmd {
+		case 1: // Version
+			w.str("Fake JDWP VM")
+			w.Int32(1)
+			w.Int32(6)
+			w.str("1.6")
+			w.str("fake")
+			return nil, jdwp.ErrNone
+		case 2: // ClassesBySignature
+			sig := r.str()
+			l := []*Class{}
+			for _, c := range vm.Classes {
+				if c.Signature == sig {
+					l = append(l, c)
+				}
+			}
+			w.Uint32(uint32(len(l)))
+			for _, c := range l {
+				w.Uint8(uint8(jdwp.Class))
+				w.id(c.id)
+				w.Int32(int32(classStatus))
+			}
+			return nil, jdwp.ErrNone
+		case 3: // AllClasses
+			w.Uint32(uint32(len(vm.Classes)))
+			for _, c := range vm.Classes {
+				w.Uint8(uint8(jdwp.Class))
+				w.id(c.id)
+				w.str(c.Signature)
+				w.Int32(int32(classStatus))
+			}
+			return nil, jdwp.ErrNone
+		case 4: // AllThreads
+			w.Uint32(uint32(len(vm.Threads)))
+			for _, t := range vm.Threads {
+				w.id(t.id)
+			}
+			return nil, jdwp.ErrNone
+		case 6: // Dispose
+			vm.requests = map[jdwp.EventRequestID]*request{}
+			vm.suspends = 0
+			return vm.run, jdwp.ErrNone
+		case 7: // IDSizes
+			for i := 0; i < 5; i++ {
+				w.Int32(idSize)
+			}
+			return nil, jdwp.ErrNone
+		case 8: // Suspend
+			vm.suspends++
+			return nil, jdwp.ErrNone
+		case 9: // Resume
+			if vm.suspends > 0 {
+				vm.suspends--
+			}
+			return vm.run, jdwp.ErrNone
+		case 11: // CreateString
+			w.id(vm.newString(r.str()))
+			return nil, jdwp.ErrNone
+		}
+
+	case 2: // ReferenceType
+		c := vm.classByID(r.id())
+		if c == nil {
+			return nil, jdwp.ErrInvalidClass
+		}
+		switch cmd {
+		case 1: // Signature
+			w.str(c.Signature)
+			return nil, jdwp.ErrNone
+		case 4: // Fields
+			w.Uint32(uint32(len(c.Fields)))
+			for _, f := range c.Fields {
+				w.id(f.id)
+				w.str(f.Name)
+				w.str(f.Signature)
+				w.Int32(int32(f.ModBits))
+			}
+			return nil, jdwp.ErrNone
+		case 5: // Methods
+			w.Uint32(uint32(len(c.Methods)))
+			for _, m := range c.Methods {
+				w.id(m.id)
+				w.str(m.Name)
+				w.str(m.Signature)
+				w.Int32(int32(m.ModBits))
+			}
+			return nil, jdwp.ErrNone
+		case 6: // GetValues
+			count := int(r.Uint32())
+			w.Uint32(uint32(count))
+			for i := 0; i < count; i++ {
+				id := r.id()
+				var f *Field
+				for s := c; s != nil && f == nil; s = s.Super {
+					f = s.fieldByID(id)
+				}
+				if f == nil || f.ModBits&jdwp.ModStatic == 0 {
+					return nil, jdwp.ErrInvalidFieldID
+				}
+				w.value(vm, f.Value)
+			}
+			return nil, jdwp.ErrNone
+		case 10: // Interfaces
+			w.Uint32(0)
+			return nil, jdwp.ErrNone
+		}
+
+	case 3: // ClassType
+		c := vm.classByID(r.id())
+		if c == nil {
+			return nil, jdwp.ErrInvalidClass
+		}
+		switch cmd {
+		case 1: // Superclass
+			if c.Super != nil {
+				w.id(c.Super.id)
+			} else {
+				w.id(0)
+			}
+			return nil, jdwp.ErrNone
+		case 3: // InvokeMethod
+			t := vm.threadByID(r.id())
+			if t == nil {
+				return nil, jdwp.ErrInvalidThread
+			}
+			m := c.methodByID(r.id())
+			if m == nil {
+				return nil, jdwp.ErrInvalidMethodID
+			}
+			args := make([]interface{}, r.Uint32())
+			for i := range args {
+				args[i] = r.value(vm)
+			}
+			r.Int32() // options
+			if err := r.Error(); err != nil {
+				return nil, jdwp.ErrIllegalArgument
+			}
+			if !t.suspended(vm) {
+				return nil, jdwp.ErrThreadNotSuspended
+			}
+			var res interface{}
+			if m.Invoke != nil {
+				res = m.Invoke(args...)
+			}
+			if m.Signature[len(m.Signature)-1] == 'V' {
+				w.Uint8(uint8(jdwp.TagVoid))
+			} else {
+				w.value(vm, res)
+			}
+			w.Uint8(uint8(jdwp.TagObject)) // exception
+			w.id(0)
+			return nil, jdwp.ErrNone
+		}
+
+	case 9: // ObjectReference
+		id := r.id()
+		var class *Class
+		if o, ok := vm.objects[id]; ok {
+			class = o.class
+		} else if vm.threadByID(id) != nil {
+			class = vm.Class("Ljava/lang/Thread;")
+		} else {
+			return nil, jdwp.ErrInvalidObject
+		}
+		switch cmd {
+		case 1: // ReferenceType
+			w.Uint8(uint8(jdwp.Class))
+			w.id(class.id)
+			return nil, jdwp.ErrNone
+		case 7, 8: // DisableCollection, EnableCollection
+			return nil, jdwp.ErrNone
+		}
+
+	case 10: // StringReference
+		switch cmd {
+		case 1: // Value
+			o, ok := vm.objects[r.id()]
+			if !ok || o.class.Signature != "Ljava/lang/String;" {
+				return nil, jdwp.ErrInvalidString
+			}
+			w.str(o.str)
+			return nil, jdwp.ErrNone
+		}
+
+	case 11: // ThreadReference
+		t := vm.threadByID(r.id())
+		if t == nil {
+			return nil, jdwp.ErrInvalidThread
+		}
+		switch cmd {
+		case 1: // Name
+			w.str(t.Name)
+			return nil, jdwp.ErrNone
+		case 2: // Suspend
+			t.suspends++
+			return nil, jdwp.ErrNone
+		case 3: // Resume
+			if t.suspends > 0 {
+				t.suspends--
+			}
+			return vm.run, jdwp.ErrNone
+		case 4: // Status
+			w.Int32(int32(t.Status))
+			if t.suspended(vm) {
+				w.Int32(int32(jdwp.Suspended))
+			} else {
+				w.Int32(int32(jdwp.NotSuspended))
+			}
+			return nil, jdwp.ErrNone
+		case 6: // Frames
+			if !t.suspended(vm) {
+				return nil, jdwp.ErrThreadNotSuspended
+			}
+			start, count := int(r.Int32()), int(r.Int32())
+			if start < 0 || start > len(t.Stack) {
+				return nil, jdwp.ErrInvalidIndex
+			}
+			if count < 0 || start+count > len(t.Stack) {
+				count = len(t.Stack) - start
+			}
+			w.Uint32(uint32(count))
+			for i := start; i < start+count; i++ {
+				w.id(uint64(i + 1)) // frame identifier
+				w.location(t.Stack[i])
+			}
+			return nil, jdwp.ErrNone
+		case 7: // FrameCount
+			if !t.suspended(vm) {
+				return nil, jdwp.ErrThreadNotSuspended
+			}
+			w.Int32(int32(len(t.Stack)))
+			return nil, jdwp.ErrNone
+		case 12: // SuspendCount
+			w.Int32(int32(t.suspends + vm.suspends))
+			return nil, jdwp.ErrNone
+		}
+
+	case 15: // EventRequest
+		switch cmd {
+		case 1: // Set
+			req := &request{
+				kind:   jdwp.EventKind(r.Uint8()),
+				policy: jdwp.SuspendPolicy(r.Uint8()),
+			}
+			for i, count := 0, int(r.Uint32()); i < count; i++ {
+				if err := r.modifier(req); err != jdwp.ErrNone {
+					return nil, err
+				}
+			}
+			if err := r.Error(); err != nil {
+				return nil, jdwp.ErrIllegalArgument
+			}
+			id := vm.nextReq
+			vm.nextReq++
+			vm.requests[id] = req
+			w.Int32(int32(id))
+			return nil, jdwp.ErrNone
+		case 2: // Clear
+			r.Uint8() // kind
+			delete(vm.requests, jdwp.EventRequestID(r.Int32()))
+			return nil, jdwp.ErrNone
+		}
+
+	case 16: // StackFrame
+		switch cmd {
+		case 3: // ThisObject
+			// The fake VM only has static methods.
+			w.Uint8(uint8(jdwp.TagObject))
+			w.id(0)
+			return nil, jdwp.ErrNone
+		}
+	}
+	return nil, jdwp.ErrNotImplemented
+}
+
+// classStatus is the status of all the classes of the VM.
+const classStatus = jdwp.StatusVerified | jdwp.StatusPrepared | jdwp.StatusInitialized
+
+type reader struct{ binary.Reader }
+type writer struct{ binary.Writer }
+
+func (r reader) id() uint64 { return r.Uint64() }
+
+func (r reader) str() string {
+	data := make([]byte, r.Uint32())
+	r.Data(data)
+	return string(data)
+}
+
+// value reads a tagged value, converting strings to Go strings.
+func (r reader) value(vm *VM) interface{} {
+	switch jdwp.Tag(r.Uint8()) {
+	case jdwp.TagByte:
+		return r.Int8()
+	case jdwp.TagChar:
+		return jdwp.Char(r.Int16())
+	case jdwp.TagShort:
+		return r.Int16()
+	case jdwp.TagInt:
+		return int(r.Int32())
+	case jdwp.TagLong:
+		return r.Int64()
+	case jdwp.TagFloat:
+		return r.Float32()
+	case jdwp.TagDouble:
+		return r.Float64()
+	case jdwp.TagBoolean:
+		return r.Bool()
+	case jdwp.TagVoid:
+		return nil
+	case jdwp.TagString:
+		id := r.id()
+		if o, ok := vm.objects[id]; ok {
+			return o.str
+		}
+		return jdwp.ObjectID(id)
+	default:
+		return jdwp.ObjectID(r.id())
+	}
+}
+
+// modifier reads an event modifier, applying it to req.
+func (r reader) modifier(req *request) jdwp.Error {
+	switch kind := r.Uint8(); kind {
+	case 1: // Count
+		req.count = int(r.Int32())
+	case 3: // ThreadOnly
+		req.thread = r.id()
+	case 4: // ClassOnly
+		req.class = r.id()
+	case 5: // ClassMatch
+		req.match = append(req.match, r.str())
+	case 6: // ClassExclude
+		req.exclude = append(req.exclude, r.str())
+	case 7: // LocationOnly
+		req.location = &jdwp.Location{
+			Type:     jdwp.TypeTag(r.Uint8()),
+			Class:    jdwp.ClassID(r.id()),
+			Method:   jdwp.MethodID(r.id()),
+			Location: r.Uint64(),
+		}
+	default:
+		return jdwp.ErrNotImplemented
+	}
+	return jdwp.ErrNone
+}
+
+func (w writer) id(id uint64) { w.Uint64(id) }
+
+func (w writer) str(s string) {
+	w.Uint32(uint32(len(s)))
+	w.Data([]byte(s))
+}
+
+func (w writer) location(f Frame) {
+	w.Uint8(uint8(jdwp.Class))
+	w.id(f.Class.id)
+	w.id(f.Method.id)
+	w.Uint64(f.Location)
+}
+
+// value writes v as a tagged value, converting Go strings to new string
+// objects.
+func (w writer) value(vm *VM, v interface{}) {
+	switch v := v.(type) {
+	case nil:
+		w.Uint8(uint8(jdwp.TagObject))
+		w.id(0)
+	case bool:
+		w.Uint8(uint8(jdwp.TagBoolean))
+		w.Bool(v)
+	case int8:
+		w.Uint8(uint8(jdwp.TagByte))
+		w.Int8(v)
+	case jdwp.Char:
+		w.Uint8(uint8(jdwp.TagChar))
+		w.Int16(int16(v))
+	case int16:
+		w.Uint8(uint8(jdwp.TagShort))
+		w.Int16(v)
+	case int:
+		w.Uint8(uint8(jdwp.TagInt))
+		w.Int32(int32(v))
+	case int32:
+		w.Uint8(uint8(jdwp.TagInt))
+		w.Int32(v)
+	case int64:
+		w.Uint8(uint8(jdwp.TagLong))
+		w.Int64(v)
+	case float32:
+		w.Uint8(uint8(jdwp.TagFloat))
+		w.Float32(v)
+	case float64:
+		w.Uint8(uint8(jdwp.TagDouble))
+		w.Float64(v)
+	case string:
+		w.Uint8(uint8(jdwp.TagString))
+		w.id(vm.newString(v))
+	case jdwp.ObjectID:
+		w.Uint8(uint8(jdwp.TagObject))
+		w.id(uint64(v))
+	default:
+		panic(fmt.Errorf("Unsupported fake value type %T", v))
+	}
+}
@@ -27,14 +27,51 @@ type Command struct {
 	// The identifier of the actual command handler.
 	Command string
 
-	// Arguments that the command handler should be invoked with.
+	// Arguments that the command handler should be invoked with, sent as a
+	// JSON object. Arguments is ignored if ArgumentList is not nil.
 	Arguments map[string]interface{}
+
+	// ArgumentList is the list of arguments that the command handler should be
+	// invoked with, sent as a JSON array.
+	// Arguments of type Position, Range, Location, []Location, TextEditList and
+	// WorkspaceEdit are converted to their protocol representations.
+	ArgumentList []interface{}
 }
 
 func (c Command) toProtocol() protocol.Command {
-	return protocol.Command{
+	out := protocol.Command{
 		Title:     c.Title,
 		Command:   c.Command,
 		Arguments: c.Arguments,
 	}
+	if c.ArgumentList != nil {
+		args := make([]interface{}, len(c.ArgumentList))
+		for i, a := range c.ArgumentList {
+			args[i] = argToProtocol(a)
+		}
+		out.Arguments = args
+	}
+	return out
+}
+
+func argToProtocol(a interface{}) interface{} {
+	switch a := a.(type) {
+	case Position:
+		return a.toProtocol()
+	case Range:
+		return a.toProtocol()
+	case Location:
+		return a.toProtocol()
+	case []Location:
+		out := make([]protocol.Location, len(a))
+		for i, l := range a {
+			out[i] = l.toProtocol()
+		}
+		return out
+	case TextEditList:
+		return a.toProtocol()
+	case WorkspaceEdit:
+		return a.toProtocol()
+	}
+	return a
 }
@@ -0,0 +1,54 @@
+// Copyright (C) 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package langsvr
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/google/gapid/core/assert"
+)
+
+func TestCommandArguments(t *testing.T) {
+	assert := assert.To(t)
+	pos := Position{Line: 2, Column: 3}
+	for _, test := range []struct {
+		name     string
+		cmd      Command
+		expected string
+	}{
+		{
+			"none",
+			Command{Title: "t", Command: "c"},
+			`{"title":"t","command":"c","arguments":null}`,
+		}, {
+			"object",
+			Command{Title: "t", Command: "c", Arguments: map[string]interface{}{"a": 1}},
+			`{"title":"t","command":"c","arguments":{"a":1}}`,
+		}, {
+			"list",
+			Command{Title: "t", Command: "c", ArgumentList: []interface{}{"a", pos}},
+			`{"title":"t","command":"c","arguments":["a",{"line":1,"character":2}]}`,
+		}, {
+			"list wins",
+			Command{Title: "t", Command: "c", Arguments: map[string]interface{}{"a": 1}, ArgumentList: []interface{}{}},
+			`{"title":"t","command":"c","arguments":[]}`,
+		},
+	} {
+		data, err := json.Marshal(test.cmd.toProtocol())
+		assert.For("%v err", test.name).ThatError(err).Succeeded()
+		assert.For("%v", test.name).ThatString(string(data)).Equals(test.expected)
+	}
+}
@@ -16,6 +16,7 @@ package langsvr
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"io"
 	"io/ioutil"
@@ -188,6 +189,7 @@ func Connect(ctx context.Context, stream io.ReadWriter, server Server) error {
 		conn,
 		server,
 		make(map[string]*Document),
+		make(map[string][]CodeLens),
 		"",
 		terminate,
 	}
@@ -225,11 +227,19 @@ func (h logHandler) Close() { h.to.Close() }
 type langsvr struct {
 	conn       *protocol.Connection
 	server     Server
-	documents  map[string]*Document // uri -> Document
+	documents  map[string]*Document  // uri -> Document
+	codeLenses map[string][]CodeLens // uri -> last CodeLenses returned
 	languageID string
 	terminate  func()
 }
 
+// codeLensData is the data attached to each protocol.CodeLens so that the
+// CodeLens can be found again when the client asks for it to be resolved.
+type codeLensData struct {
+	URI   string `json:"uri"`
+	Index int    `json:"index"`
+}
+
 func runesToStrings(in []rune) []string {
 	out := make([]string, len(in))
 	for i, r := range in {
@@ -492,11 +502,12 @@ func (s langsvr) CodeLens(ctx context.Context, docID protocol.TextDocumentIdenti
 	if err != nil {
 		return nil, err
 	}
+	s.codeLenses[docID.URI] = cls
 	out := make([]protocol.CodeLens, len(cls))
 	for i, cl := range cls {
 		out[i] = protocol.CodeLens{
 			Range: cl.Range.toProtocol(),
-			Data:  i,
+			Data:  codeLensData{docID.URI, i},
 		}
 	}
 	return out, nil
@@ -504,6 +515,22 @@ func (s langsvr) CodeLens(ctx context.Context, docID protocol.TextDocumentIdenti
 
 func (s langsvr) CodeLensResolve(ctx context.Context, codelens protocol.CodeLens) (protocol.CodeLens, error) {
 	ctx = log.Enter(ctx, "CodeLensResolve")
+	// Data has been round-tripped through the client, so it is now a generic
+	// JSON object.
+	raw, err := json.Marshal(codelens.Data)
+	if err != nil {
+		return codelens, err
+	}
+	data := codeLensData{}
+	if err := json.Unmarshal(raw, &data); err != nil {
+		return codelens, err
+	}
+	cls := s.codeLenses[data.URI]
+	if data.Index < 0 || data.Index >= len(cls) || cls[data.Index].Resolve == nil {
+		return codelens, nil
+	}
+	cmd := cls[data.Index].Resolve(ctx).toProtocol()
+	codelens.Command = &cmd
 	return codelens, nil
 }
 
@@ -643,6 +670,7 @@ func (s langsvr) OnCloseTextDocument(ctx context.Context, docID protocol.TextDoc
 		return
 	}
 	doc.open = false
+	delete(s.codeLenses, docID.URI)
 	if !doc.watched {
 		s.server.OnDocumentsRemoved(ctx, []*Document{doc})
 		delete(s.documents, docID.URI)
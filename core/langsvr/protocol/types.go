@@ -106,8 +106,9 @@ type Command struct {
 	Command string `json:"command"`
 
 	// Arguments that the command handler should be
-	// invoked with.
-	Arguments map[string]interface{} `json:"arguments"`
+	// invoked with. This is either an array, as specified by the protocol, or
+	// an object for servers that predate array arguments.
+	Arguments interface{} `json:"arguments"`
 }
 
 // TextEdit is a textual edit applicable to a text document.
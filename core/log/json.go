@@ -0,0 +1,87 @@
+// Copyright (C) 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package log
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// jsonMessage is the JSON encoding of a Message.
+type jsonMessage struct {
+	Time     string                     `json:"time,omitempty"`
+	Severity string                     `json:"severity,omitempty"`
+	Tag      string                     `json:"tag,omitempty"`
+	Trace    []string                   `json:"trace,omitempty"`
+	Process  string                     `json:"process,omitempty"`
+	Text     string                     `json:"text"`
+	Values   map[string]json.RawMessage `json:"values,omitempty"`
+	Stack    []string                   `json:"stack,omitempty"`
+}
+
+// printJSON returns msg encoded as a single line JSON object, with the parts
+// of the message enabled by the style.
+func (s Style) printJSON(msg *Message) string {
+	m := jsonMessage{Text: msg.Text}
+	if s.Timestamp && !msg.Time.IsZero() {
+		m.Time = msg.Time.Format(time.RFC3339Nano)
+	}
+	if s.Severity != NoSeverity {
+		m.Severity = s.Severity.print(msg.Severity)
+	}
+	if s.Tag {
+		m.Tag = msg.Tag
+	}
+	if s.Trace {
+		m.Trace = msg.Trace
+	}
+	if s.Process {
+		m.Process = msg.Process
+	}
+	if s.Values != NoValues && len(msg.Values) > 0 {
+		m.Values = make(map[string]json.RawMessage, len(msg.Values))
+		for _, v := range msg.Values {
+			m.Values[v.Name] = jsonValue(v.Value)
+		}
+	}
+	for _, l := range msg.Callstack {
+		m.Stack = append(m.Stack, fmt.Sprintf("%v:%v", l.File, l.Line))
+	}
+	out, err := json.Marshal(m)
+	if err != nil {
+		// Should not happen, as all the values are already encoded.
+		out, _ = json.Marshal(struct {
+			Text string `json:"text"`
+		}{msg.Text})
+	}
+	return string(out)
+}
+
+// jsonValue returns the JSON encoding of the message value v. Values that
+// are not numbers, booleans or strings are encoded as their printed string.
+func jsonValue(v interface{}) json.RawMessage {
+	switch v := v.(type) {
+	case nil, bool, string,
+		int, int8, int16, int32, int64,
+		uint, uint8, uint16, uint32, uint64,
+		float32, float64:
+		if out, err := json.Marshal(v); err == nil {
+			return out
+		}
+	}
+	out, _ := json.Marshal(fmt.Sprint(v))
+	return out
+}
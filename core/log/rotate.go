@@ -0,0 +1,134 @@
+// Copyright (C) 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package log
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// Rotation holds the limits at which a log file is rotated.
+type Rotation struct {
+	// MaxSize is the size in bytes at which the log file is rotated.
+	// 0 disables rotation by size.
+	MaxSize int64
+	// MaxAge is the time after which the log file is rotated.
+	// 0 disables rotation by age.
+	MaxAge time.Duration
+	// MaxBackups is the number of rotated log files to keep.
+	// 0 keeps all the rotated log files.
+	MaxBackups int
+}
+
+// RotatingFile returns a Handler that writes messages printed with style to
+// the file at path.
+// When the file reaches the size or age limits of r, it is renamed to path.1,
+// with older rotated files renamed to path.2, path.3 and so on, and a new file
+// is started. A file already at path when the handler is created is rotated
+// in the same way, so each handler starts with an empty file.
+func RotatingFile(path string, style Style, r Rotation) (Handler, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, err
+	}
+	f := &rotatingFile{path: path, rotation: r}
+	if err := f.rotate(); err != nil {
+		return nil, err
+	}
+	h := style.Handler(f.write)
+	return OnClosed(h, f.close), nil
+}
+
+type rotatingFile struct {
+	path     string
+	rotation Rotation
+	mutex    sync.Mutex
+	file     *os.File
+	size     int64
+	opened   time.Time
+}
+
+func (f *rotatingFile) write(text string, severity Severity) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	r := f.rotation
+	if f.size > 0 && ((r.MaxSize > 0 && f.size+int64(len(text))+1 > r.MaxSize) ||
+		(r.MaxAge > 0 && time.Since(f.opened) > r.MaxAge)) {
+		if err := f.rotate(); err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to rotate log file '%v': %v\n", f.path, err)
+		}
+	}
+	if f.file == nil {
+		return
+	}
+	n, _ := f.file.WriteString(text + "\n")
+	f.size += int64(n)
+}
+
+// rotate closes the current file, shifts the existing files along and
+// starts a new file.
+func (f *rotatingFile) rotate() error {
+	if f.file != nil {
+		f.file.Close()
+		f.file = nil
+	}
+	if info, err := os.Stat(f.path); err == nil && info.Size() > 0 {
+		last := f.rotation.MaxBackups
+		if last == 0 {
+			// Find the first unused name.
+			for last = 1; exists(f.backup(last)); last++ {
+			}
+		} else {
+			os.Remove(f.backup(last))
+		}
+		for i := last - 1; i >= 1; i-- {
+			if exists(f.backup(i)) {
+				if err := os.Rename(f.backup(i), f.backup(i+1)); err != nil {
+					return err
+				}
+			}
+		}
+		if err := os.Rename(f.path, f.backup(1)); err != nil {
+			return err
+		}
+	}
+	file, err := os.Create(f.path)
+	if err != nil {
+		return err
+	}
+	f.file, f.size, f.opened = file, 0, time.Now()
+	return nil
+}
+
+func (f *rotatingFile) close() {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	if f.file != nil {
+		f.file.Close()
+		f.file = nil
+	}
+}
+
+// backup returns the path of the i'th rotated file.
+func (f *rotatingFile) backup(i int) string {
+	return fmt.Sprintf("%v.%d", f.path, i)
+}
+
+func exists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
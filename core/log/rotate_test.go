@@ -0,0 +1,59 @@
+// Copyright (C) 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package log_test
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/gapid/core/assert"
+	"github.com/google/gapid/core/log"
+)
+
+func TestRotatingFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "log")
+	assert.To(t).For("TempDir").ThatError(err).Succeeded()
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "test.log")
+	assert.To(t).For("WriteFile").ThatError(ioutil.WriteFile(path, []byte("old\n"), 0666)).Succeeded()
+
+	h, err := log.RotatingFile(path, log.Raw, log.Rotation{MaxSize: 10, MaxBackups: 2})
+	assert.To(t).For("RotatingFile").ThatError(err).Succeeded()
+
+	ctx := log.PutHandler(context.Background(), h)
+	for _, s := range []string{"one", "two", "three", "four", "five"} {
+		log.I(ctx, "%s", s)
+	}
+	h.Close()
+
+	for _, test := range []struct {
+		path     string
+		expected string
+	}{
+		{path, "four\nfive\n"},
+		{path + ".1", "three\n"},
+		{path + ".2", "one\ntwo\n"},
+	} {
+		data, err := ioutil.ReadFile(test.path)
+		assert.To(t).For("ReadFile(%v)", test.path).ThatError(err).Succeeded()
+		assert.To(t).For("Contents(%v)", test.path).ThatString(string(data)).Equals(test.expected)
+	}
+	_, err = os.Stat(path + ".3")
+	assert.To(t).For("Backups are limited").That(os.IsNotExist(err)).Equals(true)
+}
@@ -32,6 +32,7 @@ type Style struct {
 	Process   bool          // If true, the process will be printed if part of the message.
 	Severity  SeverityStyle // How the severity of the message will be printed.
 	Values    ValueStyle    // How the values of the message will be printed.
+	JSON      bool          // If true, messages are printed as single line JSON objects.
 }
 
 // SeverityStyle is an enumerator of ways that severities can be printed.
@@ -102,6 +103,10 @@ func RegisterStyle(s Style) { styles = append(styles, s) }
 func (s Style) Handler(w Writer) Handler {
 	return handler{
 		handle: func(msg *Message) {
+			if s.JSON {
+				w(s.printJSON(msg), msg.Severity)
+				return
+			}
 			var parts [8]string
 			m := append(parts[:0])
 			if s.Timestamp && !msg.Time.IsZero() {
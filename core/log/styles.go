@@ -61,6 +61,20 @@ var (
 		Severity:  SeverityLong,
 		Values:    ValuesMultiLine,
 	}
+
+	// JSON is a style that prints each message as a single line JSON object
+	// holding the timestamp, tag, trace, process, long severity, values and
+	// callstack of the message, for consumption by log aggregators.
+	JSON = Style{
+		Name:      "json",
+		Timestamp: true,
+		Tag:       true,
+		Trace:     true,
+		Process:   true,
+		Severity:  SeverityLong,
+		Values:    ValuesSingleLine,
+		JSON:      true,
+	}
 )
 
 func init() {
@@ -68,4 +82,5 @@ func init() {
 	RegisterStyle(Brief)
 	RegisterStyle(Normal)
 	RegisterStyle(Detailed)
+	RegisterStyle(JSON)
 }
@@ -32,6 +32,7 @@ func TestStyles(t *testing.T) {
 			{log.Brief, "Brief", test.brief},
 			{log.Normal, "Normal", test.normal},
 			{log.Detailed, "Detailed", test.detailed},
+			{log.JSON, "JSON", test.json},
 		} {
 			w, b := log.Buffer()
 			test.send(s.style.Handler(w))
@@ -15,9 +15,11 @@
 package adb
 
 import (
+	"context"
 	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
 
 	"github.com/google/gapid/core/os/file"
 	"github.com/google/gapid/core/os/shell"
@@ -89,6 +91,16 @@ func (b *binding) prepareADBCommand(cmd shell.Cmd, useShell bool) (shell.Process
 type deviceTarget struct{ b *binding }
 
 func (t deviceTarget) Start(cmd shell.Cmd) (shell.Process, error) {
+	if cmd.Name == "logcat" {
+		// "adb logcat" is a device shell command.
+		if c, err := connect(context.Background()); err == nil {
+			args := make([]string, len(cmd.Args))
+			for i, arg := range cmd.Args {
+				args[i] = escapeArg(arg)
+			}
+			return t.b.startShell(c, cmd, strings.Join(append([]string{"exec logcat"}, args...), " "))
+		}
+	}
 	return t.b.prepareADBCommand(cmd, false)
 }
 
@@ -99,6 +111,9 @@ func (t deviceTarget) String() string {
 type shellTarget struct{ b *binding }
 
 func (t shellTarget) Start(cmd shell.Cmd) (shell.Process, error) {
+	if c, err := connect(context.Background()); err == nil {
+		return t.b.startShell(c, cmd, shellCommand(cmd))
+	}
 	return t.b.prepareADBCommand(cmd, true)
 }
 
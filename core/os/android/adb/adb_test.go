@@ -16,6 +16,7 @@ package adb_test
 
 import (
 	"context"
+	"os"
 	"testing"
 
 	"github.com/google/gapid/core/assert"
@@ -23,6 +24,13 @@ import (
 	"github.com/google/gapid/core/os/android/adb"
 )
 
+func TestMain(m *testing.M) {
+	// Never talk to a real adb server. The tests use the stub adb executable,
+	// or a fake server started by useFakeServer.
+	adb.Server = ""
+	os.Exit(m.Run())
+}
+
 func mustConnect(ctx context.Context, serial string) adb.Device {
 	devices, err := adb.Devices(ctx)
 	if err != nil {
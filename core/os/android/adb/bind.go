@@ -16,6 +16,7 @@ package adb
 
 import (
 	"context"
+	"sync"
 
 	"github.com/google/gapid/core/os/android"
 	"github.com/google/gapid/core/os/device/bind"
@@ -53,6 +54,9 @@ func (l DeviceList) FindBySerial(serial string) Device {
 // binding represents an attached Android device.
 type binding struct {
 	bind.Simple
+
+	featuresMutex sync.Mutex
+	featureSet    map[string]bool // Guarded by featuresMutex.
 }
 
 // verify that binding implements Device
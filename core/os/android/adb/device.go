@@ -80,7 +80,14 @@ func Monitor(ctx context.Context, r *bind.Registry, interval time.Duration) erro
 
 	var lastErrorPrinted time.Time
 	for {
-		if err := scanDevices(ctx); err != nil {
+		var err error
+		if c, e := connect(ctx); e == nil {
+			// Let the adb server push device changes.
+			err = trackDevices(ctx, c)
+		} else {
+			err = scanDevices(ctx)
+		}
+		if err != nil {
 			if time.Since(lastErrorPrinted).Seconds() > printScanErrorsEveryNSeconds {
 				log.E(ctx, "Couldn't scan devices: %v", err)
 				lastErrorPrinted = time.Now()
@@ -197,21 +204,62 @@ func allZero(bytes []byte) bool {
 	return false
 }
 
-// scanDevices returns the list of attached Android devices.
+// scanDevices updates the registry with the list of attached Android devices.
 func scanDevices(ctx context.Context) error {
-	exe, err := adb()
-	if err != nil {
-		return log.Err(ctx, err, "")
+	var parsed map[string]bind.Status
+	if c, err := connect(ctx); err == nil {
+		defer c.Close()
+		list, err := c.query("host:devices-l")
+		if err != nil {
+			return log.Err(ctx, err, "Listing devices")
+		}
+		if parsed, err = parseDeviceList(ctx, list); err != nil {
+			return err
+		}
+	} else {
+		exe, err := adb()
+		if err != nil {
+			return log.Err(ctx, err, "")
+		}
+		stdout, err := shell.Command(exe.System(), "devices").Call(ctx)
+		if err != nil {
+			return err
+		}
+		if parsed, err = parseDevices(ctx, stdout); err != nil {
+			return err
+		}
 	}
-	stdout, err := shell.Command(exe.System(), "devices").Call(ctx)
-	if err != nil {
-		return err
+	return updateDevices(ctx, parsed)
+}
+
+// trackDevices updates the registry with each device list sent by the adb
+// server in response to a host:track-devices request on c. trackDevices
+// returns when the connection is closed or the context is stopped.
+func trackDevices(ctx context.Context, c *conn) error {
+	defer c.Close()
+	if err := c.request("host:track-devices"); err != nil {
+		return log.Err(ctx, err, "Tracking devices")
 	}
-	parsed, err := parseDevices(ctx, stdout)
-	if err != nil {
-		return err
+	for {
+		list, err := c.readString()
+		if err != nil {
+			if task.Stopped(ctx) {
+				return nil
+			}
+			return log.Err(ctx, err, "Tracking devices")
+		}
+		parsed, err := parseDeviceList(ctx, list)
+		if err != nil {
+			return err
+		}
+		if err := updateDevices(ctx, parsed); err != nil {
+			return err
+		}
 	}
+}
 
+// updateDevices updates the registry so that it holds the parsed devices.
+func updateDevices(ctx context.Context, parsed map[string]bind.Status) error {
 	cacheMutex.Lock()
 	defer cacheMutex.Unlock()
 
@@ -260,19 +308,11 @@ func parseDevices(ctx context.Context, out string) (map[string]bind.Status, erro
 		case 0:
 			continue
 		case 2:
-			serial, status := fields[0], fields[1]
-			switch status {
-			case "unknown":
-				devices[serial] = bind.Status_Unknown
-			case "offline":
-				devices[serial] = bind.Status_Offline
-			case "device":
-				devices[serial] = bind.Status_Online
-			case "unauthorized":
-				devices[serial] = bind.Status_Unauthorized
-			default:
-				return nil, log.Errf(ctx, ErrInvalidStatus, "value: %v", status)
+			status, err := parseStatus(ctx, fields[1])
+			if err != nil {
+				return nil, err
 			}
+			devices[fields[0]] = status
 		default:
 			return nil, ErrInvalidDeviceList
 		}
@@ -280,6 +320,45 @@ func parseDevices(ctx context.Context, out string) (map[string]bind.Status, erro
 	return devices, nil
 }
 
+// parseDeviceList parses the device list sent by the adb server in response to
+// the host:devices-l and host:track-devices requests. Each line holds the
+// device serial and status, optionally followed by the device's details.
+func parseDeviceList(ctx context.Context, out string) (map[string]bind.Status, error) {
+	lines := strings.Split(out, "\n")
+	devices := make(map[string]bind.Status, len(lines))
+	for _, line := range lines {
+		fields := strings.Fields(line)
+		switch len(fields) {
+		case 0:
+			continue
+		case 1:
+			return nil, ErrInvalidDeviceList
+		default:
+			status, err := parseStatus(ctx, fields[1])
+			if err != nil {
+				return nil, err
+			}
+			devices[fields[0]] = status
+		}
+	}
+	return devices, nil
+}
+
+func parseStatus(ctx context.Context, status string) (bind.Status, error) {
+	switch status {
+	case "unknown":
+		return bind.Status_Unknown, nil
+	case "offline":
+		return bind.Status_Offline, nil
+	case "device":
+		return bind.Status_Online, nil
+	case "unauthorized":
+		return bind.Status_Unauthorized, nil
+	default:
+		return bind.Status_Unknown, log.Errf(ctx, ErrInvalidStatus, "value: %v", status)
+	}
+}
+
 // NativeBridgeABI returns the native ABI for the given emulated ABI for the
 // device by consulting the ro.dalvik.vm.isa.<emulated_isa>=<native_isa>
 // system properties.
@@ -14,14 +14,89 @@
 
 package adb
 
-import "context"
+import (
+	"context"
+	"os"
+	"path"
+	"path/filepath"
+
+	"github.com/google/gapid/core/log"
+)
 
 // Pushes the local file to the remote one.
 func (b *binding) Push(ctx context.Context, local, remote string) error {
-	return b.Command("push", local, remote).Run(ctx)
+	info, err := os.Stat(local)
+	if err != nil || info.IsDir() {
+		// Leave error reporting and directories to the adb executable.
+		return b.Command("push", local, remote).Run(ctx)
+	}
+	c, err := connect(ctx)
+	if err != nil {
+		return b.Command("push", local, remote).Run(ctx)
+	}
+	defer c.Close()
+	if err := c.transport(b.To.Serial, "sync:"); err != nil {
+		return log.Err(ctx, err, "Opening sync service")
+	}
+	defer c.quit()
+
+	mode, _, err := c.stat(remote)
+	if err != nil {
+		return log.Errf(ctx, err, "Stat of '%v'", remote)
+	}
+	if mode&syncModeType == syncModeDir {
+		remote = path.Join(remote, filepath.Base(local))
+	}
+
+	f, err := os.Open(local)
+	if err != nil {
+		return log.Errf(ctx, err, "Opening '%v'", local)
+	}
+	defer f.Close()
+	if err := c.send(remote, info.Mode(), info.ModTime(), f); err != nil {
+		return log.Errf(ctx, err, "Pushing '%v' to '%v'", local, remote)
+	}
+	return nil
 }
 
 // Pulls the remote file to the local one.
 func (b *binding) Pull(ctx context.Context, remote, local string) error {
-	return b.Command("pull", remote, local).Run(ctx)
+	c, err := connect(ctx)
+	if err != nil {
+		return b.Command("pull", remote, local).Run(ctx)
+	}
+	defer c.Close()
+	if err := c.transport(b.To.Serial, "sync:"); err != nil {
+		return log.Err(ctx, err, "Opening sync service")
+	}
+	defer c.quit()
+
+	mode, _, err := c.stat(remote)
+	switch {
+	case err != nil:
+		return log.Errf(ctx, err, "Stat of '%v'", remote)
+	case mode == 0:
+		return log.Errf(ctx, nil, "Remote object '%v' does not exist", remote)
+	case mode&syncModeType == syncModeDir:
+		// Leave directories to the adb executable.
+		c.Close()
+		return b.Command("pull", remote, local).Run(ctx)
+	}
+
+	if info, err := os.Stat(local); err == nil && info.IsDir() {
+		local = filepath.Join(local, path.Base(remote))
+	}
+	f, err := os.Create(local)
+	if err != nil {
+		return log.Errf(ctx, err, "Creating '%v'", local)
+	}
+	err = c.recv(remote, f)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(local)
+		return log.Errf(ctx, err, "Pulling '%v' to '%v'", remote, local)
+	}
+	return nil
 }
@@ -20,6 +20,7 @@ import (
 	"net"
 
 	"github.com/google/gapid/core/context/keys"
+	"github.com/google/gapid/core/log"
 )
 
 // Port is the interface for sockets ports that can be forwarded from an Android
@@ -83,14 +84,32 @@ func (p Jdwp) adbForwardString() string {
 
 // Forward will forward the specified device Port to the specified local Port.
 func (b *binding) Forward(ctx context.Context, local, device Port) error {
-	return b.Command("forward", local.adbForwardString(), device.adbForwardString()).Run(ctx)
+	c, err := connect(ctx)
+	if err != nil {
+		return b.Command("forward", local.adbForwardString(), device.adbForwardString()).Run(ctx)
+	}
+	defer c.Close()
+	req := fmt.Sprintf("host-serial:%v:forward:%v;%v", b.To.Serial, local.adbForwardString(), device.adbForwardString())
+	if err := c.command(req); err != nil {
+		return log.Err(ctx, err, "Forwarding port")
+	}
+	return nil
 }
 
 // RemoveForward removes a port forward made by Forward.
 func (b *binding) RemoveForward(ctx context.Context, local Port) error {
 	// Clone context to ignore cancellation.
 	ctx = keys.Clone(context.Background(), ctx)
-	return b.Command("forward", "--remove", local.adbForwardString()).Run(ctx)
+	c, err := connect(ctx)
+	if err != nil {
+		return b.Command("forward", "--remove", local.adbForwardString()).Run(ctx)
+	}
+	defer c.Close()
+	req := fmt.Sprintf("host-serial:%v:killforward:%v", b.To.Serial, local.adbForwardString())
+	if err := c.command(req); err != nil {
+		return log.Err(ctx, err, "Removing port forward")
+	}
+	return nil
 }
 
 // SetupLocalPort makes sure that the given port can be accessed on localhost
@@ -0,0 +1,212 @@
+// Copyright (C) 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package adb
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/gapid/core/app/crash"
+	"github.com/google/gapid/core/event/task"
+	"github.com/google/gapid/core/fault"
+)
+
+// ErrNoServer is returned when there is no adb server address to connect to.
+const ErrNoServer = fault.Const("No adb server address")
+
+// ServerTimeout is the maximum time to wait for the adb server to accept a
+// connection or to respond to a request.
+var ServerTimeout = 5 * time.Second
+
+// Server is the address of the adb server. Where possible, requests are sent
+// directly to the server using the adb wire protocol. If Server is empty or
+// the server cannot be reached then the adb executable is used instead, which
+// will also start the server if it is not already running.
+var Server = defaultServer()
+
+func defaultServer() string {
+	port := os.Getenv("ANDROID_ADB_SERVER_PORT")
+	if port == "" {
+		port = "5037"
+	}
+	return net.JoinHostPort("localhost", port)
+}
+
+// conn is a connection to the adb server.
+type conn struct {
+	net.Conn
+	once sync.Once
+	done chan struct{}
+}
+
+// connect opens a new connection to the adb server, waiting at most
+// ServerTimeout for the server to accept it. The connection is closed when the
+// context is stopped.
+func connect(ctx context.Context) (*conn, error) {
+	if Server == "" {
+		return nil, ErrNoServer
+	}
+	dialer := net.Dialer{Timeout: ServerTimeout}
+	nc, err := dialer.DialContext(ctx, "tcp", Server)
+	if err != nil {
+		return nil, err
+	}
+	c := &conn{Conn: nc, done: make(chan struct{})}
+	crash.Go(func() {
+		select {
+		case <-task.ShouldStop(ctx):
+			c.Close()
+		case <-c.done:
+		}
+	})
+	return c, nil
+}
+
+// Close closes the connection. It is safe to call Close more than once.
+func (c *conn) Close() error {
+	var err error
+	c.once.Do(func() {
+		close(c.done)
+		err = c.Conn.Close()
+	})
+	return err
+}
+
+// bounded calls f, failing any read or write of the connection by f that does
+// not complete within ServerTimeout.
+func (c *conn) bounded(f func() error) error {
+	c.SetDeadline(time.Now().Add(ServerTimeout))
+	defer c.SetDeadline(time.Time{})
+	return f()
+}
+
+// request sends the service request req to the server and waits for the
+// server to accept it.
+func (c *conn) request(req string) error {
+	return c.bounded(func() error {
+		if _, err := fmt.Fprintf(c, "%04x%s", len(req), req); err != nil {
+			return err
+		}
+		return c.status(req)
+	})
+}
+
+// status reads the OKAY or FAIL response to the request req.
+func (c *conn) status(req string) error {
+	var id [4]byte
+	if _, err := io.ReadFull(c, id[:]); err != nil {
+		return err
+	}
+	switch string(id[:]) {
+	case "OKAY":
+		return nil
+	case "FAIL":
+		msg, err := c.readString()
+		if err != nil {
+			return err
+		}
+		return fmt.Errorf("adb server failed '%v': %v", req, msg)
+	default:
+		return fmt.Errorf("Unexpected adb server response to '%v': %q", req, id[:])
+	}
+}
+
+// readString reads a string prefixed with its length as four hex digits.
+func (c *conn) readString() (string, error) {
+	var size [4]byte
+	if _, err := io.ReadFull(c, size[:]); err != nil {
+		return "", err
+	}
+	n, err := strconv.ParseUint(string(size[:]), 16, 16)
+	if err != nil {
+		return "", fmt.Errorf("Invalid adb string length %q", size[:])
+	}
+	buf := make([]byte, n)
+	if _, err := io.ReadFull(c, buf); err != nil {
+		return "", err
+	}
+	return string(buf), nil
+}
+
+// query sends the host request req and returns the server's reply.
+func (c *conn) query(req string) (string, error) {
+	if err := c.request(req); err != nil {
+		return "", err
+	}
+	var reply string
+	err := c.bounded(func() error {
+		var err error
+		reply, err = c.readString()
+		return err
+	})
+	return reply, err
+}
+
+// command sends the host request req, which the server acknowledges and then
+// replies to with a second status once the request has been performed.
+func (c *conn) command(req string) error {
+	if err := c.request(req); err != nil {
+		return err
+	}
+	if err := c.bounded(func() error { return c.status(req) }); err != io.EOF {
+		return err
+	}
+	return nil
+}
+
+// transport switches the connection to the device with the given serial and
+// then opens the service on that device.
+func (c *conn) transport(serial, service string) error {
+	if err := c.request("host:transport:" + serial); err != nil {
+		return err
+	}
+	return c.request(service)
+}
+
+// features returns the set of adb features supported by both the adb server
+// and the device. Successful lookups are cached on the binding.
+func (b *binding) features(ctx context.Context) map[string]bool {
+	b.featuresMutex.Lock()
+	defer b.featuresMutex.Unlock()
+	if b.featureSet != nil {
+		return b.featureSet
+	}
+	c, err := connect(ctx)
+	if err != nil {
+		return map[string]bool{}
+	}
+	defer c.Close()
+	list, err := c.query(fmt.Sprintf("host-serial:%v:features", b.To.Serial))
+	if err != nil {
+		return map[string]bool{}
+	}
+	b.featureSet = map[string]bool{}
+	for _, f := range strings.Split(list, ",") {
+		b.featureSet[strings.TrimSpace(f)] = true
+	}
+	return b.featureSet
+}
+
+// escapeArg quotes the argument for the device shell.
+func escapeArg(arg string) string {
+	return "'" + strings.Replace(arg, "'", `'\''`, -1) + "'"
+}
@@ -0,0 +1,167 @@
+// Copyright (C) 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package adb
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strings"
+
+	"github.com/google/gapid/core/app/crash"
+	"github.com/google/gapid/core/event/task"
+	"github.com/google/gapid/core/os/shell"
+)
+
+// Packet identifiers of the shell v2 protocol.
+const (
+	shellStdin      = 0
+	shellStdout     = 1
+	shellStderr     = 2
+	shellExit       = 3
+	shellCloseStdin = 4
+
+	shellChunkSize = 16 * 1024
+)
+
+// shellProcess is a shell.Process for a command running on the device using
+// the adb server's shell service.
+type shellProcess struct {
+	c    *conn
+	v2   bool
+	done chan error
+}
+
+// shellCommand returns the device shell command line for cmd. Like the adb
+// executable, the arguments are joined without escaping.
+func shellCommand(cmd shell.Cmd) string {
+	return strings.Join(append([]string{cmd.Name}, cmd.Args...), " ")
+}
+
+// startShell runs command on the device using the connection c to the adb
+// server. If the device supports it, the shell v2 protocol is used so that
+// stdout and stderr are kept separate and the exit code is returned.
+func (b *binding) startShell(c *conn, cmd shell.Cmd, command string) (shell.Process, error) {
+	v2 := b.features(context.Background())["shell_v2"]
+	service := "shell:" + command
+	if v2 {
+		service = "shell,v2,raw:" + command
+	}
+	if err := c.transport(b.To.Serial, service); err != nil {
+		c.Close()
+		return nil, err
+	}
+	p := &shellProcess{c: c, v2: v2, done: make(chan error, 1)}
+	stdout, stderr := cmd.Stdout, cmd.Stderr
+	if stdout == nil {
+		stdout = ioutil.Discard
+	}
+	if stderr == nil {
+		stderr = ioutil.Discard
+	}
+	if cmd.Stdin != nil {
+		crash.Go(func() { p.writeStdin(cmd.Stdin) })
+	} else if v2 {
+		p.writePacket(shellCloseStdin, nil)
+	}
+	crash.Go(func() { p.done <- p.read(stdout, stderr) })
+	return p, nil
+}
+
+func (p *shellProcess) writePacket(id byte, data []byte) error {
+	buf := make([]byte, 5+len(data))
+	buf[0] = id
+	binary.LittleEndian.PutUint32(buf[1:], uint32(len(data)))
+	copy(buf[5:], data)
+	_, err := p.c.Write(buf)
+	return err
+}
+
+func (p *shellProcess) writeStdin(stdin io.Reader) {
+	if !p.v2 {
+		io.Copy(p.c, stdin)
+		return
+	}
+	buf := make([]byte, shellChunkSize)
+	for {
+		n, err := stdin.Read(buf)
+		if n > 0 {
+			if p.writePacket(shellStdin, buf[:n]) != nil {
+				return
+			}
+		}
+		if err != nil {
+			p.writePacket(shellCloseStdin, nil)
+			return
+		}
+	}
+}
+
+func (p *shellProcess) read(stdout, stderr io.Writer) error {
+	if !p.v2 {
+		_, err := io.Copy(stdout, p.c)
+		return err
+	}
+	for {
+		var header [5]byte
+		if _, err := io.ReadFull(p.c, header[:]); err != nil {
+			if err == io.EOF {
+				return fmt.Errorf("Shell closed without an exit status")
+			}
+			return err
+		}
+		size := int64(binary.LittleEndian.Uint32(header[1:]))
+		switch header[0] {
+		case shellStdout:
+			if _, err := io.CopyN(stdout, p.c, size); err != nil {
+				return err
+			}
+		case shellStderr:
+			if _, err := io.CopyN(stderr, p.c, size); err != nil {
+				return err
+			}
+		case shellExit:
+			var code [1]byte
+			if _, err := io.ReadFull(p.c, code[:]); err != nil {
+				return err
+			}
+			if code[0] != 0 {
+				return fmt.Errorf("exit status %d", code[0])
+			}
+			return nil
+		default:
+			if _, err := io.CopyN(ioutil.Discard, p.c, size); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func (p *shellProcess) Wait(ctx context.Context) error {
+	select {
+	case err := <-p.done:
+		p.c.Close()
+		return err
+	case <-task.ShouldStop(ctx):
+		p.Kill()
+		return task.StopReason(ctx)
+	}
+}
+
+func (p *shellProcess) Kill() error {
+	return p.c.Close()
+}
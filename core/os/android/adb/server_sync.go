@@ -0,0 +1,147 @@
+// Copyright (C) 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package adb
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+	"time"
+)
+
+const (
+	syncChunkSize = 64 * 1024
+
+	// File type bits of the mode returned by STAT.
+	syncModeType    = 0170000
+	syncModeDir     = 0040000
+	syncModeRegular = 0100000
+)
+
+// syncRequest sends a sync service request with the given id and data.
+func (c *conn) syncRequest(id string, data []byte) error {
+	return c.syncHeader(id, uint32(len(data)), data)
+}
+
+// syncHeader sends a sync service packet with the given id, header value and
+// data.
+func (c *conn) syncHeader(id string, value uint32, data []byte) error {
+	buf := make([]byte, 8+len(data))
+	copy(buf, id)
+	binary.LittleEndian.PutUint32(buf[4:], value)
+	copy(buf[8:], data)
+	_, err := c.Write(buf)
+	return err
+}
+
+// syncResponse reads the id and header value of a sync service response.
+func (c *conn) syncResponse() (string, uint32, error) {
+	var buf [8]byte
+	if _, err := io.ReadFull(c, buf[:]); err != nil {
+		return "", 0, err
+	}
+	return string(buf[:4]), binary.LittleEndian.Uint32(buf[4:]), nil
+}
+
+// syncFail reads the message of a FAIL response of the given size.
+func (c *conn) syncFail(size uint32) error {
+	msg := make([]byte, size)
+	if _, err := io.ReadFull(c, msg); err != nil {
+		return err
+	}
+	return fmt.Errorf("adb sync failed: %s", msg)
+}
+
+// stat returns the mode and size of the file at path on the device. A mode of
+// zero means the file does not exist.
+func (c *conn) stat(path string) (mode, size uint32, err error) {
+	if err := c.syncRequest("STAT", []byte(path)); err != nil {
+		return 0, 0, err
+	}
+	var buf [16]byte
+	if _, err := io.ReadFull(c, buf[:]); err != nil {
+		return 0, 0, err
+	}
+	if id := string(buf[:4]); id != "STAT" {
+		return 0, 0, fmt.Errorf("Unexpected adb sync response to STAT: %q", id)
+	}
+	return binary.LittleEndian.Uint32(buf[4:]), binary.LittleEndian.Uint32(buf[8:]), nil
+}
+
+// send writes the contents of r to the file at path on the device.
+func (c *conn) send(path string, mode os.FileMode, mtime time.Time, r io.Reader) error {
+	if err := c.syncRequest("SEND", []byte(fmt.Sprintf("%v,%d", path, syncModeRegular|mode.Perm()))); err != nil {
+		return err
+	}
+	buf := make([]byte, syncChunkSize)
+	for {
+		n, err := r.Read(buf)
+		if n > 0 {
+			if err := c.syncRequest("DATA", buf[:n]); err != nil {
+				return err
+			}
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+	}
+	if err := c.syncHeader("DONE", uint32(mtime.Unix()), nil); err != nil {
+		return err
+	}
+	switch id, size, err := c.syncResponse(); {
+	case err != nil:
+		return err
+	case id == "OKAY":
+		return nil
+	case id == "FAIL":
+		return c.syncFail(size)
+	default:
+		return fmt.Errorf("Unexpected adb sync response to SEND: %q", id)
+	}
+}
+
+// recv writes the contents of the file at path on the device to w.
+func (c *conn) recv(path string, w io.Writer) error {
+	if err := c.syncRequest("RECV", []byte(path)); err != nil {
+		return err
+	}
+	for {
+		id, size, err := c.syncResponse()
+		if err != nil {
+			return err
+		}
+		switch id {
+		case "DATA":
+			if _, err := io.CopyN(w, c, int64(size)); err != nil {
+				return err
+			}
+		case "DONE":
+			return nil
+		case "FAIL":
+			return c.syncFail(size)
+		default:
+			return fmt.Errorf("Unexpected adb sync response to RECV: %q", id)
+		}
+	}
+}
+
+// quit ends the sync session.
+func (c *conn) quit() error {
+	return c.syncRequest("QUIT", nil)
+}
@@ -0,0 +1,364 @@
+// Copyright (C) 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package adb_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/gapid/core/assert"
+	"github.com/google/gapid/core/log"
+	"github.com/google/gapid/core/os/android/adb"
+)
+
+// fakeServer is a minimal adb server with a single device, fake_device.
+type fakeServer struct {
+	listener net.Listener
+	mutex    sync.Mutex
+	files    map[string][]byte
+	forwards map[string]string
+}
+
+// useFakeServer starts a fake adb server and points the adb package at it.
+// The returned function stops the server and restores the previous address.
+func useFakeServer(ctx context.Context) (*fakeServer, func()) {
+	l, err := net.Listen("tcp", "localhost:0")
+	if err != nil {
+		log.F(ctx, true, "Couldn't start fake adb server. Error: %v", err)
+	}
+	s := &fakeServer{
+		listener: l,
+		files:    map[string][]byte{"/system/build.prop": []byte("ro.build.product=hammerhead\n")},
+		forwards: map[string]string{},
+	}
+	go func() {
+		for {
+			c, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(c)
+		}
+	}()
+	old := adb.Server
+	adb.Server = l.Addr().String()
+	return s, func() {
+		adb.Server = old
+		l.Close()
+	}
+}
+
+func readRequest(r io.Reader) (string, error) {
+	var size [4]byte
+	if _, err := io.ReadFull(r, size[:]); err != nil {
+		return "", err
+	}
+	n, err := strconv.ParseUint(string(size[:]), 16, 16)
+	if err != nil {
+		return "", err
+	}
+	buf := make([]byte, n)
+	_, err = io.ReadFull(r, buf)
+	return string(buf), err
+}
+
+func okay(w io.Writer) { io.WriteString(w, "OKAY") }
+
+func fail(w io.Writer, msg string) { fmt.Fprintf(w, "FAIL%04x%s", len(msg), msg) }
+
+func reply(w io.Writer, msg string) { fmt.Fprintf(w, "OKAY%04x%s", len(msg), msg) }
+
+func (s *fakeServer) serve(c net.Conn) {
+	defer c.Close()
+	for {
+		req, err := readRequest(c)
+		if err != nil {
+			return
+		}
+		switch {
+		case req == "host:devices-l":
+			reply(c, "fake_device            device product:hammerhead model:Nexus_5 device:hammerhead transport_id:1\n")
+			return
+		case req == "host-serial:fake_device:features":
+			reply(c, "shell_v2,cmd,stat_v2")
+			return
+		case strings.HasPrefix(req, "host-serial:fake_device:forward:"):
+			parts := strings.SplitN(strings.TrimPrefix(req, "host-serial:fake_device:forward:"), ";", 2)
+			s.mutex.Lock()
+			s.forwards[parts[0]] = parts[1]
+			s.mutex.Unlock()
+			okay(c)
+			okay(c)
+			return
+		case strings.HasPrefix(req, "host-serial:fake_device:killforward:"):
+			local := strings.TrimPrefix(req, "host-serial:fake_device:killforward:")
+			s.mutex.Lock()
+			_, found := s.forwards[local]
+			delete(s.forwards, local)
+			s.mutex.Unlock()
+			okay(c)
+			if !found {
+				fail(c, fmt.Sprintf("listener '%v' not found", local))
+				return
+			}
+			okay(c)
+			return
+		case req == "host:transport:fake_device":
+			okay(c)
+		case strings.HasPrefix(req, "shell,v2,raw:"):
+			okay(c)
+			s.shell(c, strings.TrimPrefix(req, "shell,v2,raw:"))
+			return
+		case req == "sync:":
+			okay(c)
+			s.sync(c)
+			return
+		default:
+			fail(c, "unknown request: "+req)
+			return
+		}
+	}
+}
+
+func writePacket(w io.Writer, id byte, data []byte) {
+	buf := make([]byte, 5+len(data))
+	buf[0] = id
+	binary.LittleEndian.PutUint32(buf[1:], uint32(len(data)))
+	copy(buf[5:], data)
+	w.Write(buf)
+}
+
+// shell runs the fake shell command using the shell v2 protocol.
+func (s *fakeServer) shell(c net.Conn, cmd string) {
+	stdin := &bytes.Buffer{}
+	for {
+		var header [5]byte
+		if _, err := io.ReadFull(c, header[:]); err != nil {
+			return
+		}
+		size := int64(binary.LittleEndian.Uint32(header[1:]))
+		io.CopyN(stdin, c, size)
+		if header[0] == 4 { // Close stdin
+			break
+		}
+	}
+	stdout, stderr, exit := "", "", byte(0)
+	switch {
+	case cmd == "getprop ro.build.product":
+		stdout = "hammerhead\n"
+	case strings.HasPrefix(cmd, "getprop "):
+		stdout = "\n"
+	case strings.HasPrefix(cmd, "echo "):
+		stdout = strings.TrimPrefix(cmd, "echo ") + "\n"
+	case cmd == "cat":
+		stdout = stdin.String()
+	default:
+		stderr, exit = cmd+": not found\n", 127
+	}
+	if stdout != "" {
+		writePacket(c, 1, []byte(stdout))
+	}
+	if stderr != "" {
+		writePacket(c, 2, []byte(stderr))
+	}
+	writePacket(c, 3, []byte{exit})
+}
+
+func syncPacket(w io.Writer, id string, value uint32, data []byte) {
+	buf := make([]byte, 8+len(data))
+	copy(buf, id)
+	binary.LittleEndian.PutUint32(buf[4:], value)
+	copy(buf[8:], data)
+	w.Write(buf)
+}
+
+// sync serves sync service requests from the fake file system.
+func (s *fakeServer) sync(c net.Conn) {
+	for {
+		var header [8]byte
+		if _, err := io.ReadFull(c, header[:]); err != nil {
+			return
+		}
+		arg := make([]byte, binary.LittleEndian.Uint32(header[4:]))
+		if _, err := io.ReadFull(c, arg); err != nil {
+			return
+		}
+		path := string(arg)
+		s.mutex.Lock()
+		data, found := s.files[path]
+		s.mutex.Unlock()
+		switch string(header[:4]) {
+		case "STAT":
+			mode, size := uint32(0), uint32(0)
+			switch {
+			case found:
+				mode, size = 0100644, uint32(len(data))
+			case path == "/sdcard" || path == "/sdcard/":
+				mode = 040771
+			}
+			stat := make([]byte, 8) // size, mtime
+			binary.LittleEndian.PutUint32(stat, size)
+			syncPacket(c, "STAT", mode, stat)
+		case "SEND":
+			name := path[:strings.LastIndex(path, ",")]
+			buf := &bytes.Buffer{}
+			for {
+				if _, err := io.ReadFull(c, header[:]); err != nil {
+					return
+				}
+				if string(header[:4]) == "DONE" {
+					break
+				}
+				io.CopyN(buf, c, int64(binary.LittleEndian.Uint32(header[4:])))
+			}
+			s.mutex.Lock()
+			s.files[name] = buf.Bytes()
+			s.mutex.Unlock()
+			syncPacket(c, "OKAY", 0, nil)
+		case "RECV":
+			if !found {
+				msg := "No such file or directory"
+				syncPacket(c, "FAIL", uint32(len(msg)), []byte(msg))
+				continue
+			}
+			syncPacket(c, "DATA", uint32(len(data)), data)
+			syncPacket(c, "DONE", 0, nil)
+		case "QUIT":
+			return
+		}
+	}
+}
+
+func TestServerDevices(t_ *testing.T) {
+	ctx := log.Testing(t_)
+	_, stop := useFakeServer(ctx)
+	defer stop()
+	d := mustConnect(ctx, "fake_device")
+	assert.For(ctx, "Device").ThatString(d).Equals("hammerhead")
+}
+
+func TestServerShell(t_ *testing.T) {
+	ctx := log.Testing(t_)
+	_, stop := useFakeServer(ctx)
+	defer stop()
+	d := mustConnect(ctx, "fake_device")
+
+	out, err := d.Shell("echo", "hello", "world").Call(ctx)
+	assert.For(ctx, "echo err").ThatError(err).Succeeded()
+	assert.For(ctx, "echo out").ThatString(out).Equals("hello world")
+
+	out, err = d.Shell("cat").Read(strings.NewReader("piped")).Call(ctx)
+	assert.For(ctx, "cat err").ThatError(err).Succeeded()
+	assert.For(ctx, "cat out").ThatString(out).Equals("piped")
+
+	out, err = d.Shell("missing").Call(ctx)
+	assert.For(ctx, "missing err").ThatError(err).HasMessage(`Process returned error
+   Cause: exit status 127`)
+	assert.For(ctx, "missing out").ThatString(out).Equals("missing: not found")
+}
+
+func TestServerPushPull(t_ *testing.T) {
+	ctx := log.Testing(t_)
+	s, stop := useFakeServer(ctx)
+	defer stop()
+	d := mustConnect(ctx, "fake_device")
+
+	dir, err := ioutil.TempDir("", "adb")
+	assert.For(ctx, "TempDir").ThatError(err).Succeeded()
+	defer os.RemoveAll(dir)
+
+	local := filepath.Join(dir, "file.txt")
+	ioutil.WriteFile(local, []byte("file contents"), 0644)
+	err = d.Push(ctx, local, "/sdcard/")
+	assert.For(ctx, "Push err").ThatError(err).Succeeded()
+	assert.For(ctx, "Pushed").ThatString(string(s.files["/sdcard/file.txt"])).Equals("file contents")
+
+	err = d.Pull(ctx, "/system/build.prop", dir)
+	assert.For(ctx, "Pull err").ThatError(err).Succeeded()
+	pulled, _ := ioutil.ReadFile(filepath.Join(dir, "build.prop"))
+	assert.For(ctx, "Pulled").ThatString(string(pulled)).Equals("ro.build.product=hammerhead\n")
+
+	err = d.Pull(ctx, "/missing", dir)
+	assert.For(ctx, "Pull missing").ThatError(err).HasMessage(`Remote object '/missing' does not exist`)
+}
+
+func TestServerForward(t_ *testing.T) {
+	ctx := log.Testing(t_)
+	s, stop := useFakeServer(ctx)
+	defer stop()
+	d := mustConnect(ctx, "fake_device")
+
+	err := d.Forward(ctx, adb.TCPPort(1234), adb.NamedAbstractSocket("gapii"))
+	assert.For(ctx, "Forward err").ThatError(err).Succeeded()
+	assert.For(ctx, "Forwards").That(s.forwards).DeepEquals(map[string]string{"tcp:1234": "localabstract:gapii"})
+
+	err = d.RemoveForward(ctx, adb.TCPPort(1234))
+	assert.For(ctx, "RemoveForward err").ThatError(err).Succeeded()
+	assert.For(ctx, "Forwards").That(s.forwards).DeepEquals(map[string]string{})
+
+	err = d.RemoveForward(ctx, adb.TCPPort(1234))
+	assert.For(ctx, "RemoveForward missing").ThatError(err).Failed()
+}
+
+func TestServerTimeout(t_ *testing.T) {
+	ctx := log.Testing(t_)
+	_, stop := useFakeServer(ctx)
+	defer stop()
+	d := mustConnect(ctx, "fake_device")
+
+	// A server that accepts connections but never responds.
+	l, err := net.Listen("tcp", "localhost:0")
+	assert.For(ctx, "Listen").ThatError(err).Succeeded()
+	defer l.Close()
+	go func() {
+		conns := []net.Conn{}
+		defer func() {
+			for _, c := range conns {
+				c.Close()
+			}
+		}()
+		for {
+			c, err := l.Accept()
+			if err != nil {
+				return
+			}
+			conns = append(conns, c)
+		}
+	}()
+
+	oldServer, oldTimeout := adb.Server, adb.ServerTimeout
+	adb.Server, adb.ServerTimeout = l.Addr().String(), 100*time.Millisecond
+	defer func() { adb.Server, adb.ServerTimeout = oldServer, oldTimeout }()
+
+	start := time.Now()
+	_, err = d.Shell("echo", "hello").Call(ctx)
+	assert.For(ctx, "Shell err").ThatError(err).Failed()
+	assert.For(ctx, "Shell duration").That(time.Since(start) < 5*time.Second).Equals(true)
+
+	err = d.Forward(ctx, adb.TCPPort(1234), adb.NamedAbstractSocket("gapii"))
+	assert.For(ctx, "Forward err").ThatError(err).Failed()
+}
@@ -0,0 +1,330 @@
+// Copyright (C) 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package apk
+
+import (
+	"archive/zip"
+	"bytes"
+	"compress/flate"
+	"context"
+	"encoding/binary"
+	"hash/crc32"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/google/gapid/core/fault"
+	"github.com/google/gapid/core/log"
+)
+
+const (
+	// ErrZip64 is returned when aligning or signing an archive that needs the
+	// zip64 extensions, which are not supported.
+	ErrZip64 = fault.Const("Zip64 archives are not supported")
+
+	// DefaultAlignment is the alignment of uncompressed entries used by
+	// zipalign by default.
+	DefaultAlignment = 4
+	// nativeLibraryAlignment is the alignment of uncompressed native libraries
+	// so that they can be mapped directly from the APK.
+	nativeLibraryAlignment = 4096
+
+	zipLocalHeaderSig  = 0x04034b50
+	zipCentralDirSig   = 0x02014b50
+	zipEndOfCentralSig = 0x06054b50
+	zipLocalHeaderLen  = 30
+	zipCentralDirLen   = 46
+	zipEndOfCentralLen = 22
+	zipDataDescriptor  = 0x8
+	zipAlignmentExtra  = 0xd935
+)
+
+// ZipAlign copies the zip archive at src to dst, aligning the data of all
+// uncompressed entries to alignment bytes from the start of the file.
+// Uncompressed native libraries are aligned to 4096 bytes so that they can be
+// mapped directly from the APK. Compressed entries are copied without being
+// recompressed.
+func ZipAlign(ctx context.Context, src, dst string, alignment int) error {
+	in, err := openZip(src)
+	if err != nil {
+		return log.Errf(ctx, err, "Opening '%v'", src)
+	}
+	defer in.Close()
+
+	out, err := os.Create(dst)
+	if err != nil {
+		return log.Errf(ctx, err, "Creating '%v'", dst)
+	}
+	defer out.Close()
+
+	w := &zipWriter{w: out}
+	for _, f := range in.File {
+		if err := w.copy(f, in.ReaderAt, alignment); err != nil {
+			return log.Errf(ctx, err, "Copying '%v'", f.Name)
+		}
+	}
+	if err := w.close(); err != nil {
+		return log.Err(ctx, err, "Writing central directory")
+	}
+	return nil
+}
+
+// zipFile is an open zip archive.
+type zipFile struct {
+	*zip.Reader
+	io.ReaderAt
+	file *os.File
+	size int64
+}
+
+func openZip(path string) (*zipFile, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	r, err := zip.NewReader(f, info.Size())
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	return &zipFile{Reader: r, ReaderAt: f, file: f, size: info.Size()}, nil
+}
+
+func (z *zipFile) Close() error {
+	return z.file.Close()
+}
+
+// zipEntry is the central directory record of an entry written by zipWriter.
+type zipEntry struct {
+	name           string
+	flags          uint16
+	method         uint16
+	modifiedTime   uint16
+	modifiedDate   uint16
+	crc32          uint32
+	compressed     uint32
+	uncompressed   uint32
+	extra          []byte
+	comment        string
+	creatorVersion uint16
+	readerVersion  uint16
+	externalAttrs  uint32
+	offset         uint32
+}
+
+// zipWriter writes zip archives where the data of uncompressed entries can be
+// aligned. Unlike archive/zip it does not use data descriptors, and can copy
+// compressed entries from another archive as-is.
+type zipWriter struct {
+	w       io.Writer
+	offset  int64
+	entries []*zipEntry
+}
+
+func (z *zipWriter) write(data ...[]byte) error {
+	for _, d := range data {
+		n, err := z.w.Write(d)
+		z.offset += int64(n)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// copy copies the entry f, read from r, to the archive.
+func (z *zipWriter) copy(f *zip.File, r io.ReaderAt, alignment int) error {
+	if f.CompressedSize64 >= 0xffffffff || f.UncompressedSize64 >= 0xffffffff {
+		return ErrZip64
+	}
+	offset, err := f.DataOffset()
+	if err != nil {
+		return err
+	}
+	e := &zipEntry{
+		name:           f.Name,
+		flags:          f.Flags &^ zipDataDescriptor,
+		method:         f.Method,
+		modifiedTime:   f.ModifiedTime,
+		modifiedDate:   f.ModifiedDate,
+		crc32:          f.CRC32,
+		compressed:     uint32(f.CompressedSize64),
+		uncompressed:   uint32(f.UncompressedSize64),
+		extra:          stripAlignment(f.Extra),
+		comment:        f.Comment,
+		creatorVersion: f.CreatorVersion,
+		readerVersion:  f.ReaderVersion,
+		externalAttrs:  f.ExternalAttrs,
+	}
+	return z.add(e, io.NewSectionReader(r, offset, int64(f.CompressedSize64)), alignment)
+}
+
+// create adds a new deflated entry with the given name and contents.
+func (z *zipWriter) create(name string, data []byte) error {
+	buf := &bytes.Buffer{}
+	fw, err := flate.NewWriter(buf, flate.BestCompression)
+	if err != nil {
+		return err
+	}
+	fw.Write(data)
+	if err := fw.Close(); err != nil {
+		return err
+	}
+	e := &zipEntry{
+		name:           name,
+		method:         zip.Deflate,
+		modifiedTime:   0,
+		modifiedDate:   0x21, // 1980-01-01
+		crc32:          crc32.ChecksumIEEE(data),
+		compressed:     uint32(buf.Len()),
+		uncompressed:   uint32(len(data)),
+		creatorVersion: 20,
+		readerVersion:  20,
+	}
+	return z.add(e, buf, 0)
+}
+
+// add writes the local header for e, padded so that the entry's data is
+// aligned if it is uncompressed, followed by the data read from r.
+func (z *zipWriter) add(e *zipEntry, r io.Reader, alignment int) error {
+	if z.offset >= 0xffffffff || len(z.entries) >= 0xffff {
+		return ErrZip64
+	}
+	e.offset = uint32(z.offset)
+
+	extra := e.extra
+	if e.method == zip.Store && alignment > 0 {
+		if strings.HasSuffix(e.name, ".so") {
+			alignment = nativeLibraryAlignment
+		}
+		extra = alignExtra(extra, z.offset+zipLocalHeaderLen+int64(len(e.name)), alignment)
+	}
+
+	header := make([]byte, zipLocalHeaderLen)
+	b := writeBuf(header)
+	b.uint32(zipLocalHeaderSig)
+	b.uint16(e.readerVersion)
+	b.uint16(e.flags)
+	b.uint16(e.method)
+	b.uint16(e.modifiedTime)
+	b.uint16(e.modifiedDate)
+	b.uint32(e.crc32)
+	b.uint32(e.compressed)
+	b.uint32(e.uncompressed)
+	b.uint16(uint16(len(e.name)))
+	b.uint16(uint16(len(extra)))
+	if err := z.write(header, []byte(e.name), extra); err != nil {
+		return err
+	}
+	n, err := io.Copy(z.w, r)
+	z.offset += n
+	if err != nil {
+		return err
+	}
+	z.entries = append(z.entries, e)
+	return nil
+}
+
+// close writes the central directory and end of central directory records.
+func (z *zipWriter) close() error {
+	start := z.offset
+	for _, e := range z.entries {
+		header := make([]byte, zipCentralDirLen)
+		b := writeBuf(header)
+		b.uint32(zipCentralDirSig)
+		b.uint16(e.creatorVersion)
+		b.uint16(e.readerVersion)
+		b.uint16(e.flags)
+		b.uint16(e.method)
+		b.uint16(e.modifiedTime)
+		b.uint16(e.modifiedDate)
+		b.uint32(e.crc32)
+		b.uint32(e.compressed)
+		b.uint32(e.uncompressed)
+		b.uint16(uint16(len(e.name)))
+		b.uint16(uint16(len(e.extra)))
+		b.uint16(uint16(len(e.comment)))
+		b.uint16(0) // Disk number
+		b.uint16(0) // Internal attributes
+		b.uint32(e.externalAttrs)
+		b.uint32(e.offset)
+		if err := z.write(header, []byte(e.name), e.extra, []byte(e.comment)); err != nil {
+			return err
+		}
+	}
+	if z.offset >= 0xffffffff {
+		return ErrZip64
+	}
+	end := make([]byte, zipEndOfCentralLen)
+	b := writeBuf(end)
+	b.uint32(zipEndOfCentralSig)
+	b.uint16(0) // Disk number
+	b.uint16(0) // Disk with the central directory
+	b.uint16(uint16(len(z.entries)))
+	b.uint16(uint16(len(z.entries)))
+	b.uint32(uint32(z.offset - start))
+	b.uint32(uint32(start))
+	b.uint16(0) // Comment length
+	return z.write(end)
+}
+
+// stripAlignment returns extra without any alignment padding, either from
+// zipalign (zeros) or from an alignment extra field.
+func stripAlignment(extra []byte) []byte {
+	out := []byte{}
+	for len(extra) >= 4 {
+		id := binary.LittleEndian.Uint16(extra)
+		size := int(binary.LittleEndian.Uint16(extra[2:]))
+		if id == 0 || 4+size > len(extra) {
+			break // Padding or malformed.
+		}
+		if id != zipAlignmentExtra {
+			out = append(out, extra[:4+size]...)
+		}
+		extra = extra[4+size:]
+	}
+	return out
+}
+
+// alignExtra returns extra with an alignment extra field appended so that
+// data following extra, which starts at offset, is aligned to alignment bytes.
+func alignExtra(extra []byte, offset int64, alignment int) []byte {
+	const fieldLen = 6 // id, size, alignment
+	offset += int64(len(extra) + fieldLen)
+	padding := (alignment - int(offset%int64(alignment))) % alignment
+	field := make([]byte, fieldLen+padding)
+	b := writeBuf(field)
+	b.uint16(zipAlignmentExtra)
+	b.uint16(uint16(2 + padding))
+	b.uint16(uint16(alignment))
+	return append(append([]byte{}, extra...), field...)
+}
+
+type writeBuf []byte
+
+func (b *writeBuf) uint16(v uint16) {
+	binary.LittleEndian.PutUint16(*b, v)
+	*b = (*b)[2:]
+}
+
+func (b *writeBuf) uint32(v uint32) {
+	binary.LittleEndian.PutUint32(*b, v)
+	*b = (*b)[4:]
+}
@@ -22,11 +22,17 @@ import (
 	"github.com/google/gapid/core/log"
 )
 
+// unknownEngine is the engine reported when no engine is identified.
+const unknownEngine = "<unknown>"
+
 // engineSignatures is used to identify the middleware engine used based on
 // files found in the APK.
 var engineSignatures = map[string]string{
 	"libunity.so":         "unity",
+	"libil2cpp.so":        "unity",
 	"libUnrealEngine3.so": "unreal3",
+	"libUE4.so":           "unreal4",
+	"libgodot_android.so": "godot",
 }
 
 // Analyze parses the APK file and returns the APK's information.
@@ -66,5 +72,5 @@ func engine(files []*zip.File) string {
 			}
 		}
 	}
-	return "<unknown>"
+	return unknownEngine
 }
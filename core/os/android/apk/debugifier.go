@@ -23,7 +23,6 @@ import (
 	"os/exec"
 	"os/user"
 	"path/filepath"
-	"regexp"
 	"strings"
 
 	"github.com/google/gapid/core/log"
@@ -33,10 +32,14 @@ import (
 // ApkDebugifier makes an APK debuggable. The fields in the struct
 // are used to configure the various paths and passwords required,
 // as well as providing a log context.
-// Intended use is ApkDebugifier{Ctx: ..., JarSignCmd: "jarsigner", ..., KeyStorePath: "..."}.Run(...).
+// Intended use is ApkDebugifier{KeyPass: ..., ..., KeyStorePath: "..."}.Run(...).
+// By default the APK is signed (v1 and v2) and aligned natively, with a key
+// from a JKS or PKCS#12 keystore. If the keystore does not exist, a new one
+// with a debug key is created. If JarSignCmd is set the APK is instead signed
+// with jarsigner, and aligned with zipalign if ZipAlignCmd is also set.
 type ApkDebugifier struct {
-	JarSignCmd   string // path to the jarsigner binary
-	ZipAlignCmd  string // path to the zipalign binary
+	JarSignCmd   string // path to the jarsigner binary, or empty
+	ZipAlignCmd  string // path to the zipalign binary, or empty
 	KeyPass      string // key passphrase
 	KeyAlias     string // key alias for signing
 	StorePass    string // keystore passphrase
@@ -58,6 +61,18 @@ func (a ApkDebugifier) Run(ctx context.Context, src string, dst string) error {
 		return err
 	}
 
+	if a.JarSignCmd == "" {
+		if a.ZipAlignCmd != "" {
+			return log.Err(ctx, nil, "zipalign can only be used with jarsigner, as the native v2 signature must be made after alignment")
+		}
+		key, err := DebugKey(ctx, expandHomeDir(a.KeyStorePath), a.StorePass, a.KeyAlias, a.KeyPass)
+		if err != nil {
+			return log.Errf(ctx, err, "Cannot sign natively, use jarsigner for keystores other than JKS and PKCS#12")
+		}
+		log.I(ctx, "Signing and aligning %s to %s", tempFile.Name(), dst)
+		return Sign(ctx, tempFile.Name(), dst, key)
+	}
+
 	log.I(ctx, "Signing apk %s", tempFile.Name())
 	err = a.jarSign(ctx, tempFile.Name())
 	if err != nil {
@@ -65,6 +80,9 @@ func (a ApkDebugifier) Run(ctx context.Context, src string, dst string) error {
 	}
 
 	log.I(ctx, "Zipaligning %s to %s", tempFile.Name(), dst)
+	if a.ZipAlignCmd == "" {
+		return ZipAlign(ctx, tempFile.Name(), dst, DefaultAlignment)
+	}
 	err = a.zipAlign(ctx, tempFile.Name(), dst)
 	if err != nil {
 		return err
@@ -124,8 +142,6 @@ func (a ApkDebugifier) makeApkDebuggableAndRemoveSignatureFiles(ctx context.Cont
 	w := zip.NewWriter(outFile)
 	defer w.Close()
 
-	jarSignatureFilePattern := regexp.MustCompile(`META-INF/([^/]*(DSA|RSA|SF)|MANIFEST\.MF)`)
-
 	for _, zf := range inZip.File {
 		if jarSignatureFilePattern.MatchString(zf.Name) {
 			log.I(ctx, "Skipping file %s", zf.Name)
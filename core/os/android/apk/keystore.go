@@ -0,0 +1,367 @@
+// Copyright (C) 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package apk
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha1"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+	"unicode/utf16"
+
+	"github.com/google/gapid/core/fault"
+	"github.com/google/gapid/core/log"
+)
+
+const (
+	// ErrUnknownKeyStore is returned when loading a keystore that is neither in
+	// the JKS nor in the PKCS#12 format.
+	ErrUnknownKeyStore = fault.Const("Not a JKS or PKCS#12 keystore")
+	// ErrKeyStoreTampered is returned when the keystore's integrity check fails,
+	// which usually means the keystore password is wrong.
+	ErrKeyStoreTampered = fault.Const("Keystore was tampered with, or password was incorrect")
+	// ErrKeyNotFound is returned when the keystore has no private key with the
+	// requested alias.
+	ErrKeyNotFound = fault.Const("Key not found in keystore")
+	// ErrWrongKeyPassword is returned when a private key cannot be recovered
+	// with the key password.
+	ErrWrongKeyPassword = fault.Const("Cannot recover key, key password was incorrect")
+
+	jksMagic         = 0xfeedfeed
+	jksVersion       = 2
+	jksPrivateKeyTag = 1
+	jksIntegritySalt = "Mighty Aphrodite"
+	debugKeyValidity = 30 * 365 * 24 * time.Hour
+	debugKeyBits     = 2048
+	debugKeyCommon   = "Android Debug"
+	debugKeyOrg      = "Android"
+	debugKeyCountry  = "US"
+)
+
+var oidJKSKeyProtector = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 42, 2, 17, 1, 1}
+
+// Key is a private key and certificate used to sign APKs.
+type Key struct {
+	Private     *rsa.PrivateKey
+	Certificate *x509.Certificate
+}
+
+// DebugKey returns the key with the given alias from the keystore at path.
+// If there is no file at path then a new keystore is created holding a newly
+// generated self-signed debug key, in the same way as the Android tools do.
+func DebugKey(ctx context.Context, path, storePass, alias, keyPass string) (*Key, error) {
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		log.I(ctx, "Generating debug keystore %v", path)
+		key, err := NewDebugKey()
+		if err != nil {
+			return nil, log.Err(ctx, err, "Generating debug key")
+		}
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			return nil, log.Err(ctx, err, "Creating keystore directory")
+		}
+		if err := WriteKeyStore(path, storePass, alias, keyPass, key); err != nil {
+			return nil, log.Errf(ctx, err, "Writing keystore '%v'", path)
+		}
+		return key, nil
+	}
+	key, err := LoadKey(path, storePass, alias, keyPass)
+	if err != nil {
+		return nil, log.Errf(ctx, err, "Loading key '%v' from '%v'", alias, path)
+	}
+	return key, nil
+}
+
+// NewDebugKey returns a new RSA key with a self-signed certificate, with the
+// same subject and validity as the keys generated by the Android tools.
+func NewDebugKey() (*Key, error) {
+	priv, err := rsa.GenerateKey(rand.Reader, debugKeyBits)
+	if err != nil {
+		return nil, err
+	}
+	serial, err := rand.Int(rand.Reader, big.NewInt(1<<62))
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	template := &x509.Certificate{
+		SerialNumber: serial,
+		Subject: pkix.Name{
+			CommonName:   debugKeyCommon,
+			Organization: []string{debugKeyOrg},
+			Country:      []string{debugKeyCountry},
+		},
+		NotBefore:          now,
+		NotAfter:           now.Add(debugKeyValidity),
+		SignatureAlgorithm: x509.SHA256WithRSA,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &priv.PublicKey, priv)
+	if err != nil {
+		return nil, err
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		return nil, err
+	}
+	return &Key{Private: priv, Certificate: cert}, nil
+}
+
+// LoadKey loads the private key and certificate with the given alias from the
+// JKS or PKCS#12 keystore at path.
+func LoadKey(path, storePass, alias, keyPass string) (*Key, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if isPKCS12(data) {
+		return loadPKCS12Key(data, storePass, alias, keyPass)
+	}
+	if len(data) < 8+sha1.Size || binary.BigEndian.Uint32(data) != jksMagic {
+		return nil, ErrUnknownKeyStore
+	}
+	body, digest := data[:len(data)-sha1.Size], data[len(data)-sha1.Size:]
+	if !bytes.Equal(jksIntegrity(storePass, body), digest) {
+		return nil, ErrKeyStoreTampered
+	}
+
+	r := &jksReader{r: bytes.NewReader(body)}
+	r.uint32() // Magic
+	version := r.uint32()
+	if version != 1 && version != jksVersion {
+		return nil, fmt.Errorf("Unsupported JKS version %d", version)
+	}
+	for i, count := 0, int(r.uint32()); i < count && r.err == nil; i++ {
+		tag := r.uint32()
+		name := r.utf()
+		r.uint64() // Timestamp
+		switch tag {
+		case jksPrivateKeyTag:
+			protected := r.bytes()
+			chain := make([][]byte, r.uint32())
+			for j := range chain {
+				if version == jksVersion {
+					r.utf() // Certificate type
+				}
+				chain[j] = r.bytes()
+			}
+			if r.err != nil || !strings.EqualFold(name, alias) {
+				continue
+			}
+			return decodeJKSKey(protected, chain, keyPass)
+		default: // Trusted certificate
+			if version == jksVersion {
+				r.utf()
+			}
+			r.bytes()
+		}
+	}
+	if r.err != nil {
+		return nil, r.err
+	}
+	return nil, ErrKeyNotFound
+}
+
+// WriteKeyStore writes a new JKS keystore holding the key with the given alias
+// to path.
+func WriteKeyStore(path, storePass, alias, keyPass string, key *Key) error {
+	pkcs8, err := x509.MarshalPKCS8PrivateKey(key.Private)
+	if err != nil {
+		return err
+	}
+	protected, err := asn1.Marshal(encryptedPrivateKeyInfo{
+		Algorithm: algorithmIdentifier{Algorithm: oidJKSKeyProtector, Parameters: asn1.NullRawValue},
+		Data:      jksProtect(keyPass, pkcs8),
+	})
+	if err != nil {
+		return err
+	}
+
+	w := &bytes.Buffer{}
+	binary.Write(w, binary.BigEndian, uint32(jksMagic))
+	binary.Write(w, binary.BigEndian, uint32(jksVersion))
+	binary.Write(w, binary.BigEndian, uint32(1)) // Entry count
+	binary.Write(w, binary.BigEndian, uint32(jksPrivateKeyTag))
+	writeUTF(w, strings.ToLower(alias))
+	binary.Write(w, binary.BigEndian, uint64(time.Now().UnixNano()/int64(time.Millisecond)))
+	binary.Write(w, binary.BigEndian, uint32(len(protected)))
+	w.Write(protected)
+	binary.Write(w, binary.BigEndian, uint32(1)) // Chain length
+	writeUTF(w, "X.509")
+	binary.Write(w, binary.BigEndian, uint32(len(key.Certificate.Raw)))
+	w.Write(key.Certificate.Raw)
+	w.Write(jksIntegrity(storePass, w.Bytes()))
+
+	return ioutil.WriteFile(path, w.Bytes(), 0600)
+}
+
+type algorithmIdentifier struct {
+	Algorithm  asn1.ObjectIdentifier
+	Parameters asn1.RawValue `asn1:"optional"`
+}
+
+type encryptedPrivateKeyInfo struct {
+	Algorithm algorithmIdentifier
+	Data      []byte
+}
+
+func decodeJKSKey(protected []byte, chain [][]byte, keyPass string) (*Key, error) {
+	info := encryptedPrivateKeyInfo{}
+	if _, err := asn1.Unmarshal(protected, &info); err != nil {
+		return nil, err
+	}
+	if !info.Algorithm.Algorithm.Equal(oidJKSKeyProtector) {
+		return nil, fmt.Errorf("Unsupported key protection algorithm %v", info.Algorithm.Algorithm)
+	}
+	pkcs8, err := jksRecover(keyPass, info.Data)
+	if err != nil {
+		return nil, err
+	}
+	rsaKey, err := parseRSAKey(pkcs8)
+	if err != nil {
+		return nil, err
+	}
+	if len(chain) == 0 {
+		return nil, fmt.Errorf("Key has no certificate")
+	}
+	cert, err := x509.ParseCertificate(chain[0])
+	if err != nil {
+		return nil, err
+	}
+	return &Key{Private: rsaKey, Certificate: cert}, nil
+}
+
+// parseRSAKey parses the PKCS#8 encoded RSA private key.
+func parseRSAKey(pkcs8 []byte) (*rsa.PrivateKey, error) {
+	priv, err := x509.ParsePKCS8PrivateKey(pkcs8)
+	if err != nil {
+		return nil, err
+	}
+	rsaKey, ok := priv.(*rsa.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("Unsupported private key type %T", priv)
+	}
+	return rsaKey, nil
+}
+
+// jksPassword returns the password as the big-endian UTF-16 bytes used by the
+// JKS digests.
+func jksPassword(password string) []byte {
+	out := []byte{}
+	for _, c := range utf16.Encode([]rune(password)) {
+		out = append(out, byte(c>>8), byte(c))
+	}
+	return out
+}
+
+func jksIntegrity(password string, data []byte) []byte {
+	h := sha1.New()
+	h.Write(jksPassword(password))
+	h.Write([]byte(jksIntegritySalt))
+	h.Write(data)
+	return h.Sum(nil)
+}
+
+// jksKeystream XORs data with the keystream of the JKS key protector derived
+// from the password and salt.
+func jksKeystream(password string, salt, data []byte) []byte {
+	pass := jksPassword(password)
+	out := make([]byte, len(data))
+	digest := salt
+	for i := 0; i < len(data); i += sha1.Size {
+		h := sha1.New()
+		h.Write(pass)
+		h.Write(digest)
+		digest = h.Sum(nil)
+		for j := 0; j < sha1.Size && i+j < len(data); j++ {
+			out[i+j] = data[i+j] ^ digest[j]
+		}
+	}
+	return out
+}
+
+func jksCheck(password string, plain []byte) []byte {
+	h := sha1.New()
+	h.Write(jksPassword(password))
+	h.Write(plain)
+	return h.Sum(nil)
+}
+
+func jksProtect(password string, plain []byte) []byte {
+	salt := make([]byte, sha1.Size)
+	rand.Read(salt)
+	out := append([]byte{}, salt...)
+	out = append(out, jksKeystream(password, salt, plain)...)
+	return append(out, jksCheck(password, plain)...)
+}
+
+func jksRecover(password string, protected []byte) ([]byte, error) {
+	if len(protected) < 2*sha1.Size {
+		return nil, ErrWrongKeyPassword
+	}
+	salt := protected[:sha1.Size]
+	check := protected[len(protected)-sha1.Size:]
+	plain := jksKeystream(password, salt, protected[sha1.Size:len(protected)-sha1.Size])
+	if !bytes.Equal(jksCheck(password, plain), check) {
+		return nil, ErrWrongKeyPassword
+	}
+	return plain, nil
+}
+
+func writeUTF(w io.Writer, s string) {
+	binary.Write(w, binary.BigEndian, uint16(len(s)))
+	io.WriteString(w, s)
+}
+
+// jksReader reads big-endian JKS fields, holding on to the first error.
+type jksReader struct {
+	r   io.Reader
+	err error
+}
+
+func (r *jksReader) read(n int) []byte {
+	if r.err != nil {
+		return make([]byte, n)
+	}
+	buf := make([]byte, n)
+	_, r.err = io.ReadFull(r.r, buf)
+	return buf
+}
+
+func (r *jksReader) uint32() uint32 { return binary.BigEndian.Uint32(r.read(4)) }
+
+func (r *jksReader) uint64() uint64 { return binary.BigEndian.Uint64(r.read(8)) }
+
+func (r *jksReader) utf() string { return string(r.read(int(binary.BigEndian.Uint16(r.read(2))))) }
+
+func (r *jksReader) bytes() []byte {
+	n := r.uint32()
+	if r.err == nil && n > 1<<24 {
+		r.err = fmt.Errorf("Invalid JKS entry length %d", n)
+		return nil
+	}
+	return r.read(int(n))
+}
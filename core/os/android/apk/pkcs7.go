@@ -0,0 +1,224 @@
+// Copyright (C) 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package apk
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/asn1"
+	"fmt"
+	"math/big"
+)
+
+// The PKCS#7 SignedData structures used by JAR signature block files
+// (META-INF/*.RSA). Only detached signatures are supported.
+
+var (
+	oidData          = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 1}
+	oidSignedData    = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 2}
+	oidMessageDigest = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 4}
+	oidRSA           = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 1, 1}
+	oidSHA1WithRSA   = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 1, 5}
+	oidSHA256WithRSA = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 1, 11}
+	oidSHA512WithRSA = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 1, 13}
+	oidSHA1          = asn1.ObjectIdentifier{1, 3, 14, 3, 2, 26}
+	oidSHA256        = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 2, 1}
+	oidSHA512        = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 2, 3}
+)
+
+type contentInfo struct {
+	ContentType asn1.ObjectIdentifier
+	Content     asn1.RawValue `asn1:"explicit,optional,tag:0"`
+}
+
+type signedData struct {
+	Version          int
+	DigestAlgorithms []algorithmIdentifier `asn1:"set"`
+	ContentInfo      contentInfo
+	Certificates     asn1.RawValue `asn1:"optional,tag:0"`
+	CRLs             asn1.RawValue `asn1:"optional,tag:1"`
+	SignerInfos      []signerInfo  `asn1:"set"`
+}
+
+type issuerAndSerial struct {
+	Issuer asn1.RawValue
+	Serial *big.Int
+}
+
+type attribute struct {
+	Type   asn1.ObjectIdentifier
+	Values []asn1.RawValue `asn1:"set"`
+}
+
+type signerInfo struct {
+	Version                   int
+	IssuerAndSerial           issuerAndSerial
+	DigestAlgorithm           algorithmIdentifier
+	AuthenticatedAttributes   asn1.RawValue `asn1:"optional,tag:0"`
+	DigestEncryptionAlgorithm algorithmIdentifier
+	EncryptedDigest           []byte
+	UnauthenticatedAttributes asn1.RawValue `asn1:"optional,tag:1"`
+}
+
+// signPKCS7 returns the DER encoded PKCS#7 detached signature of content.
+func signPKCS7(key *Key, content []byte) ([]byte, error) {
+	digest := crypto.SHA256.New()
+	digest.Write(content)
+	sig, err := rsa.SignPKCS1v15(rand.Reader, key.Private, crypto.SHA256, digest.Sum(nil))
+	if err != nil {
+		return nil, err
+	}
+	sha256 := algorithmIdentifier{Algorithm: oidSHA256, Parameters: asn1.NullRawValue}
+	sd, err := asn1.Marshal(signedData{
+		Version:          1,
+		DigestAlgorithms: []algorithmIdentifier{sha256},
+		ContentInfo:      contentInfo{ContentType: oidData},
+		Certificates: asn1.RawValue{
+			Class:      asn1.ClassContextSpecific,
+			Tag:        0,
+			IsCompound: true,
+			Bytes:      key.Certificate.Raw,
+		},
+		SignerInfos: []signerInfo{{
+			Version: 1,
+			IssuerAndSerial: issuerAndSerial{
+				Issuer: asn1.RawValue{FullBytes: key.Certificate.RawIssuer},
+				Serial: key.Certificate.SerialNumber,
+			},
+			DigestAlgorithm:           sha256,
+			DigestEncryptionAlgorithm: algorithmIdentifier{Algorithm: oidRSA, Parameters: asn1.NullRawValue},
+			EncryptedDigest:           sig,
+		}},
+	})
+	if err != nil {
+		return nil, err
+	}
+	return asn1.Marshal(contentInfo{
+		ContentType: oidSignedData,
+		Content:     asn1.RawValue{FullBytes: explicitTag(0, sd)},
+	})
+}
+
+// verifyPKCS7 verifies the PKCS#7 detached signature sig of content, and
+// returns the signer's certificate.
+func verifyPKCS7(sig, content []byte) (*x509.Certificate, error) {
+	ci := contentInfo{}
+	if _, err := asn1.Unmarshal(sig, &ci); err != nil {
+		return nil, err
+	}
+	if !ci.ContentType.Equal(oidSignedData) {
+		return nil, fmt.Errorf("Signature block is not PKCS#7 signed data")
+	}
+	sd := signedData{}
+	if _, err := asn1.Unmarshal(ci.Content.Bytes, &sd); err != nil {
+		return nil, err
+	}
+	if len(sd.SignerInfos) != 1 {
+		return nil, fmt.Errorf("Expected one signer, got %d", len(sd.SignerInfos))
+	}
+	certs, err := x509.ParseCertificates(sd.Certificates.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	si := sd.SignerInfos[0]
+	var cert *x509.Certificate
+	for _, c := range certs {
+		if bytes.Equal(c.RawIssuer, si.IssuerAndSerial.Issuer.FullBytes) &&
+			c.SerialNumber.Cmp(si.IssuerAndSerial.Serial) == 0 {
+			cert = c
+		}
+	}
+	if cert == nil {
+		return nil, fmt.Errorf("Signer certificate not found")
+	}
+	pub, ok := cert.PublicKey.(*rsa.PublicKey)
+	if !ok {
+		return nil, fmt.Errorf("Unsupported public key type %T", cert.PublicKey)
+	}
+
+	hash, err := digestHash(si.DigestAlgorithm.Algorithm)
+	if err != nil {
+		return nil, err
+	}
+	switch alg := si.DigestEncryptionAlgorithm.Algorithm; {
+	case alg.Equal(oidRSA), alg.Equal(oidSHA1WithRSA), alg.Equal(oidSHA256WithRSA), alg.Equal(oidSHA512WithRSA):
+	default:
+		return nil, fmt.Errorf("Unsupported signature algorithm %v", alg)
+	}
+
+	h := hash.New()
+	h.Write(content)
+	digest := h.Sum(nil)
+	if len(si.AuthenticatedAttributes.Bytes) > 0 {
+		// The signature is of the attributes, which hold the content digest.
+		signed := append([]byte{}, si.AuthenticatedAttributes.FullBytes...)
+		signed[0] = 0x31 // SET OF
+		attrs := []attribute{}
+		if _, err := asn1.UnmarshalWithParams(signed, &attrs, "set"); err != nil {
+			return nil, err
+		}
+		found := false
+		for _, a := range attrs {
+			if a.Type.Equal(oidMessageDigest) && len(a.Values) == 1 {
+				var md []byte
+				if _, err := asn1.Unmarshal(a.Values[0].FullBytes, &md); err != nil {
+					return nil, err
+				}
+				if !bytes.Equal(md, digest) {
+					return nil, fmt.Errorf("Signed content digest mismatch")
+				}
+				found = true
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("Signed attributes have no message digest")
+		}
+		h := hash.New()
+		h.Write(signed)
+		digest = h.Sum(nil)
+	}
+	if err := rsa.VerifyPKCS1v15(pub, hash, digest, si.EncryptedDigest); err != nil {
+		return nil, err
+	}
+	return cert, nil
+}
+
+// digestHash returns the hash for the digest algorithm identifier.
+func digestHash(oid asn1.ObjectIdentifier) (crypto.Hash, error) {
+	switch {
+	case oid.Equal(oidSHA1):
+		return crypto.SHA1, nil
+	case oid.Equal(oidSHA256):
+		return crypto.SHA256, nil
+	case oid.Equal(oidSHA512):
+		return crypto.SHA512, nil
+	default:
+		return 0, fmt.Errorf("Unsupported digest algorithm %v", oid)
+	}
+}
+
+// explicitTag wraps the DER encoded value in a context specific explicit tag.
+func explicitTag(tag int, der []byte) []byte {
+	out, _ := asn1.Marshal(asn1.RawValue{
+		Class:      asn1.ClassContextSpecific,
+		Tag:        tag,
+		IsCompound: true,
+		Bytes:      der,
+	})
+	return out
+}
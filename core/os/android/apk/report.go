@@ -0,0 +1,246 @@
+// Copyright (C) 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package apk
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"debug/elf"
+	"io/ioutil"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/google/gapid/core/log"
+	"github.com/google/gapid/core/os/android/manifest"
+)
+
+// Graphics APIs reported by Report.
+const (
+	GLES   = "gles"
+	Vulkan = "vulkan"
+)
+
+// graphicsLibraries maps the system libraries to the graphics API they
+// implement.
+var graphicsLibraries = map[string]string{
+	"libEGL.so":       GLES,
+	"libGLESv1_CM.so": GLES,
+	"libGLESv2.so":    GLES,
+	"libGLESv3.so":    GLES,
+	"libvulkan.so":    Vulkan,
+}
+
+// Report is a detailed analysis of an APK.
+type Report struct {
+	Package        string     `json:"package"`
+	VersionCode    int        `json:"versionCode"`
+	VersionName    string     `json:"versionName"`
+	MinSDK         int        `json:"minSdk"`
+	TargetSDK      int        `json:"targetSdk"`
+	Debuggable     bool       `json:"debuggable"`
+	MainActivities []string   `json:"mainActivities"`
+	ABIs           []string   `json:"abis"`
+	Engine         string     `json:"engine"`
+	GraphicsAPIs   []string   `json:"graphicsApis"`
+	Libraries      []*Library `json:"libraries"`
+	Size           SizeReport `json:"size"`
+}
+
+// Library is a native library bundled in an APK.
+type Library struct {
+	Path string `json:"path"`
+	ABI  string `json:"abi"`
+	Size int64  `json:"size"`
+	// Needed are the shared libraries the library depends on.
+	Needed []string `json:"needed"`
+	// GraphicsAPIs are the graphics APIs the library links against or
+	// imports functions of.
+	GraphicsAPIs []string `json:"graphicsApis"`
+}
+
+// SizeReport is the breakdown of the size of an APK.
+type SizeReport struct {
+	// Compressed is the sum of the compressed sizes of the files.
+	Compressed int64 `json:"compressed"`
+	// Uncompressed is the sum of the uncompressed sizes of the files.
+	Uncompressed int64 `json:"uncompressed"`
+	// Categories holds the uncompressed size of the files of each category:
+	// native, dex, resources, assets, metadata and other.
+	Categories map[string]int64 `json:"categories"`
+}
+
+// AnalyzeReport parses the APK file and returns a detailed report of its
+// contents.
+func AnalyzeReport(ctx context.Context, apkData []byte) (*Report, error) {
+	files, err := Read(ctx, apkData)
+	if err != nil {
+		return nil, err
+	}
+	m, err := GetManifest(ctx, files)
+	if err != nil {
+		return nil, err
+	}
+	return NewReport(ctx, m, files)
+}
+
+// NewReport returns the report of the APK with the given manifest and files.
+func NewReport(ctx context.Context, m manifest.Manifest, files []*zip.File) (*Report, error) {
+	r := &Report{
+		Package:        m.Package,
+		VersionCode:    m.VersionCode,
+		VersionName:    m.VersionName,
+		MinSDK:         m.SDK.MinSDK,
+		TargetSDK:      m.SDK.TargetSDK,
+		Debuggable:     m.Application.Debuggable,
+		MainActivities: m.MainActivities(),
+		ABIs:           []string{},
+		Engine:         engine(files),
+		GraphicsAPIs:   []string{},
+		Libraries:      []*Library{},
+		Size:           SizeReport{Categories: map[string]int64{}},
+	}
+	for _, abi := range GatherABIs(files) {
+		r.ABIs = append(r.ABIs, abi.Name)
+	}
+
+	apis := map[string]bool{}
+	for _, f := range files {
+		r.Size.Compressed += int64(f.CompressedSize64)
+		r.Size.Uncompressed += int64(f.UncompressedSize64)
+		r.Size.Categories[category(f.Name)] += int64(f.UncompressedSize64)
+
+		if !isNativeLibrary(f.Name) {
+			continue
+		}
+		lib, err := analyzeLibrary(f)
+		if err != nil {
+			log.W(ctx, "Couldn't analyze native library %v: %v", f.Name, err)
+			continue
+		}
+		r.Libraries = append(r.Libraries, lib)
+		for _, api := range lib.GraphicsAPIs {
+			apis[api] = true
+		}
+		if r.Engine == unknownEngine {
+			for _, needed := range lib.Needed {
+				if e, ok := engineSignatures[needed]; ok {
+					r.Engine = e
+				}
+			}
+		}
+	}
+	for api := range apis {
+		r.GraphicsAPIs = append(r.GraphicsAPIs, api)
+	}
+	sort.Strings(r.GraphicsAPIs)
+	return r, nil
+}
+
+// isNativeLibrary returns true if the APK file at path is a bundled native
+// library.
+func isNativeLibrary(path string) bool {
+	parts := strings.Split(path, "/")
+	return len(parts) == 3 && parts[0] == "lib" && strings.HasSuffix(parts[2], ".so")
+}
+
+// category returns the size category of the APK file at path.
+func category(p string) string {
+	switch {
+	case strings.HasPrefix(p, "lib/"):
+		return "native"
+	case path.Ext(p) == ".dex":
+		return "dex"
+	case strings.HasPrefix(p, "res/"), p == "resources.arsc":
+		return "resources"
+	case strings.HasPrefix(p, "assets/"):
+		return "assets"
+	case strings.HasPrefix(p, "META-INF/"), p == mainfestPath:
+		return "metadata"
+	default:
+		return "other"
+	}
+}
+
+// analyzeLibrary reads the ELF dynamic section of the native library f to
+// find its dependencies and graphics API usage.
+func analyzeLibrary(f *zip.File) (*Library, error) {
+	r, err := f.Open()
+	if err != nil {
+		return nil, err
+	}
+	data, err := ioutil.ReadAll(r)
+	r.Close()
+	if err != nil {
+		return nil, err
+	}
+	e, err := elf.NewFile(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	defer e.Close()
+
+	lib := &Library{
+		Path:         f.Name,
+		ABI:          strings.Split(f.Name, "/")[1],
+		Size:         int64(f.UncompressedSize64),
+		GraphicsAPIs: []string{},
+	}
+	if lib.Needed, err = e.ImportedLibraries(); err != nil {
+		return nil, err
+	}
+	if lib.Needed == nil {
+		lib.Needed = []string{}
+	}
+
+	apis := map[string]bool{}
+	for _, needed := range lib.Needed {
+		if api, ok := graphicsLibraries[needed]; ok {
+			apis[api] = true
+		}
+	}
+	// Libraries that load the graphics driver at runtime (dlopen) don't list
+	// it as a dependency, but may still import some of its functions.
+	if symbols, err := e.DynamicSymbols(); err == nil {
+		for _, s := range symbols {
+			if s.Section != elf.SHN_UNDEF {
+				continue
+			}
+			switch {
+			case hasAPIPrefix(s.Name, "vk"):
+				apis[Vulkan] = true
+			case hasAPIPrefix(s.Name, "gl"), hasAPIPrefix(s.Name, "egl"):
+				apis[GLES] = true
+			}
+		}
+	}
+	for api := range apis {
+		lib.GraphicsAPIs = append(lib.GraphicsAPIs, api)
+	}
+	sort.Strings(lib.GraphicsAPIs)
+	return lib, nil
+}
+
+// hasAPIPrefix returns true if name is prefix followed by an upper case
+// letter, like the graphics API functions glClear, eglSwapBuffers and
+// vkCreateInstance, but unlike glob.
+func hasAPIPrefix(name, prefix string) bool {
+	if !strings.HasPrefix(name, prefix) || len(name) == len(prefix) {
+		return false
+	}
+	c := name[len(prefix)]
+	return c >= 'A' && c <= 'Z'
+}
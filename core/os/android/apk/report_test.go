@@ -0,0 +1,180 @@
+// Copyright (C) 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package apk_test
+
+import (
+	"archive/zip"
+	"bytes"
+	"debug/elf"
+	"encoding/binary"
+	"testing"
+
+	"github.com/google/gapid/core/assert"
+	"github.com/google/gapid/core/log"
+	"github.com/google/gapid/core/os/android/apk"
+	"github.com/google/gapid/core/os/android/manifest"
+)
+
+// testELF returns a minimal 64 bit shared library that depends on the needed
+// libraries.
+func testELF(needed ...string) []byte {
+	return testELFImporting(nil, needed...)
+}
+
+// testELFImporting returns a minimal 64 bit shared library that depends on the
+// needed libraries and has the undefined dynamic symbols imports.
+func testELFImporting(imports []string, needed ...string) []byte {
+	const (
+		headerSize  = 64
+		sectionSize = 64
+	)
+	dynstr := []byte{0}
+	dynamic := []elf.Dyn64{}
+	for _, n := range needed {
+		dynamic = append(dynamic, elf.Dyn64{Tag: int64(elf.DT_NEEDED), Val: uint64(len(dynstr))})
+		dynstr = append(append(dynstr, n...), 0)
+	}
+	dynamic = append(dynamic, elf.Dyn64{Tag: int64(elf.DT_NULL)})
+	dynsym := []elf.Sym64{{}}
+	for _, n := range imports {
+		dynsym = append(dynsym, elf.Sym64{
+			Name:  uint32(len(dynstr)),
+			Info:  elf.ST_INFO(elf.STB_GLOBAL, elf.STT_FUNC),
+			Shndx: uint16(elf.SHN_UNDEF),
+		})
+		dynstr = append(append(dynstr, n...), 0)
+	}
+	shstrtab := []byte("\x00.dynstr\x00.dynamic\x00.shstrtab\x00.dynsym\x00")
+
+	dynamicData := &bytes.Buffer{}
+	binary.Write(dynamicData, binary.LittleEndian, dynamic)
+	dynsymData := &bytes.Buffer{}
+	binary.Write(dynsymData, binary.LittleEndian, dynsym)
+
+	dynstrOff := uint64(headerSize)
+	dynamicOff := dynstrOff + uint64(len(dynstr))
+	dynsymOff := dynamicOff + uint64(dynamicData.Len())
+	shstrtabOff := dynsymOff + uint64(dynsymData.Len())
+	sectionsOff := shstrtabOff + uint64(len(shstrtab))
+
+	out := &bytes.Buffer{}
+	header := elf.Header64{
+		Type:      uint16(elf.ET_DYN),
+		Machine:   uint16(elf.EM_AARCH64),
+		Version:   uint32(elf.EV_CURRENT),
+		Shoff:     sectionsOff,
+		Ehsize:    headerSize,
+		Shentsize: sectionSize,
+		Shnum:     5,
+		Shstrndx:  3,
+	}
+	copy(header.Ident[:], elf.ELFMAG)
+	header.Ident[elf.EI_CLASS] = byte(elf.ELFCLASS64)
+	header.Ident[elf.EI_DATA] = byte(elf.ELFDATA2LSB)
+	header.Ident[elf.EI_VERSION] = byte(elf.EV_CURRENT)
+	binary.Write(out, binary.LittleEndian, header)
+	out.Write(dynstr)
+	out.Write(dynamicData.Bytes())
+	out.Write(dynsymData.Bytes())
+	out.Write(shstrtab)
+	binary.Write(out, binary.LittleEndian, []elf.Section64{
+		{},
+		{Name: 1, Type: uint32(elf.SHT_STRTAB), Off: dynstrOff, Size: uint64(len(dynstr))},
+		{Name: 9, Type: uint32(elf.SHT_DYNAMIC), Off: dynamicOff, Size: uint64(dynamicData.Len()), Link: 1, Entsize: 16},
+		{Name: 18, Type: uint32(elf.SHT_STRTAB), Off: shstrtabOff, Size: uint64(len(shstrtab))},
+		{Name: 28, Type: uint32(elf.SHT_DYNSYM), Off: dynsymOff, Size: uint64(dynsymData.Len()), Link: 1, Info: 1, Entsize: 24},
+	})
+	return out.Bytes()
+}
+
+func TestReport(t *testing.T) {
+	ctx := log.Testing(t)
+
+	buf := &bytes.Buffer{}
+	w := zip.NewWriter(buf)
+	for _, e := range []struct {
+		name string
+		data []byte
+	}{
+		{"AndroidManifest.xml", []byte("manifest")},
+		{"classes.dex", make([]byte, 100)},
+		{"res/layout/main.xml", make([]byte, 20)},
+		{"resources.arsc", make([]byte, 30)},
+		{"assets/level1.dat", make([]byte, 1000)},
+		{"META-INF/MANIFEST.MF", make([]byte, 10)},
+		{"lib/arm64-v8a/libmain.so", testELF("libunity.so", "liblog.so")},
+		{"lib/arm64-v8a/libunity.so", testELF("libGLESv3.so", "libEGL.so", "libc.so")},
+		{"lib/armeabi-v7a/libvk.so", testELF("libvulkan.so")},
+		{"lib/armeabi-v7a/notelf.so", []byte("not an ELF file")},
+		{"lib/x86/libdlgl.so", testELFImporting([]string{"eglGetDisplay", "glob", "malloc"}, "libc.so")},
+		{"lib/x86/libdlvk.so", testELFImporting([]string{"vkCreateInstance", "glob"}, "libc.so")},
+		{"lib/x86/libglob.so", testELFImporting([]string{"glob", "globfree", "vkey", "egl"}, "libc.so")},
+	} {
+		f, err := w.Create(e.name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		f.Write(e.data)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	files, err := apk.Read(ctx, buf.Bytes())
+	if !assert.For(ctx, "Read").ThatError(err).Succeeded() {
+		return
+	}
+
+	m := manifest.Manifest{
+		Package:     "com.example.game",
+		VersionCode: 3,
+		VersionName: "1.2",
+		SDK:         manifest.SDK{MinSDK: 21, TargetSDK: 26},
+		Application: manifest.Application{
+			Debuggable: true,
+			Activities: []manifest.Activity{
+				{Name: "Settings"},
+				{Name: "Game", IntentFilters: []manifest.IntentFilter{
+					{Action: manifest.Action{Name: manifest.ActionMain}},
+				}},
+			},
+		},
+	}
+
+	r, err := apk.NewReport(ctx, m, files)
+	if !assert.For(ctx, "NewReport").ThatError(err).Succeeded() {
+		return
+	}
+	assert.For(ctx, "Package").ThatString(r.Package).Equals("com.example.game")
+	assert.For(ctx, "MinSDK").That(r.MinSDK).Equals(21)
+	assert.For(ctx, "TargetSDK").That(r.TargetSDK).Equals(26)
+	assert.For(ctx, "Debuggable").That(r.Debuggable).Equals(true)
+	assert.For(ctx, "MainActivities").ThatSlice(r.MainActivities).Equals([]string{"Game"})
+	assert.For(ctx, "ABIs").ThatSlice(r.ABIs).Equals([]string{"arm64-v8a", "armeabi-v7a", "x86"})
+	assert.For(ctx, "Engine").ThatString(r.Engine).Equals("unity")
+	assert.For(ctx, "GraphicsAPIs").ThatSlice(r.GraphicsAPIs).Equals([]string{apk.GLES, apk.Vulkan})
+	if assert.For(ctx, "Libraries").ThatSlice(r.Libraries).IsLength(6) {
+		assert.For(ctx, "Needed").ThatSlice(r.Libraries[0].Needed).Equals([]string{"libunity.so", "liblog.so"})
+		assert.For(ctx, "libmain APIs").ThatSlice(r.Libraries[0].GraphicsAPIs).IsEmpty()
+		assert.For(ctx, "libunity APIs").ThatSlice(r.Libraries[1].GraphicsAPIs).Equals([]string{apk.GLES})
+		assert.For(ctx, "libvk ABI").ThatString(r.Libraries[2].ABI).Equals("armeabi-v7a")
+		assert.For(ctx, "libdlgl APIs").ThatSlice(r.Libraries[3].GraphicsAPIs).Equals([]string{apk.GLES})
+		assert.For(ctx, "libdlvk APIs").ThatSlice(r.Libraries[4].GraphicsAPIs).Equals([]string{apk.Vulkan})
+		assert.For(ctx, "libglob APIs").ThatSlice(r.Libraries[5].GraphicsAPIs).IsEmpty()
+	}
+	assert.For(ctx, "dex").That(r.Size.Categories["dex"]).Equals(int64(100))
+	assert.For(ctx, "resources").That(r.Size.Categories["resources"]).Equals(int64(50))
+	assert.For(ctx, "assets").That(r.Size.Categories["assets"]).Equals(int64(1000))
+	assert.For(ctx, "metadata").That(r.Size.Categories["metadata"]).Equals(int64(18))
+}
@@ -0,0 +1,179 @@
+// Copyright (C) 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package apk
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"io"
+	"io/ioutil"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/google/gapid/core/log"
+)
+
+const (
+	manifestPath       = "META-INF/MANIFEST.MF"
+	signatureFilePath  = "META-INF/CERT.SF"
+	signatureBlockPath = "META-INF/CERT.RSA"
+	createdBy          = "1.0 (Android)"
+	manifestLineLength = 72
+)
+
+// jarSignatureFilePattern matches the files of JAR signatures.
+var jarSignatureFilePattern = regexp.MustCompile(`^META-INF/([^/]*\.(DSA|RSA|EC|SF)|MANIFEST\.MF)$`)
+
+// Sign signs the APK at src with key, and writes the signed APK to dst.
+// Any existing signatures are removed. The APK is signed with both a JAR (v1)
+// signature and an APK Signature Scheme v2 signature, using SHA-256 digests,
+// and uncompressed entries are aligned in the same way as zipalign.
+func Sign(ctx context.Context, src, dst string, key *Key) error {
+	in, err := openZip(src)
+	if err != nil {
+		return log.Errf(ctx, err, "Opening '%v'", src)
+	}
+	defer in.Close()
+
+	files := []*zip.File{}
+	for _, f := range in.File {
+		if jarSignatureFilePattern.MatchString(f.Name) {
+			continue
+		}
+		files = append(files, f)
+	}
+
+	manifest, signature, err := jarSignature(files)
+	if err != nil {
+		return log.Err(ctx, err, "Creating JAR signature")
+	}
+	block, err := signPKCS7(key, signature)
+	if err != nil {
+		return log.Err(ctx, err, "Signing JAR signature file")
+	}
+
+	unsigned := &bytes.Buffer{}
+	w := &zipWriter{w: unsigned}
+	for _, f := range []struct {
+		name string
+		data []byte
+	}{
+		{manifestPath, manifest},
+		{signatureFilePath, signature},
+		{signatureBlockPath, block},
+	} {
+		if err := w.create(f.name, f.data); err != nil {
+			return log.Errf(ctx, err, "Adding '%v'", f.name)
+		}
+	}
+	for _, f := range files {
+		if err := w.copy(f, in.ReaderAt, DefaultAlignment); err != nil {
+			return log.Errf(ctx, err, "Copying '%v'", f.Name)
+		}
+	}
+	if err := w.close(); err != nil {
+		return log.Err(ctx, err, "Writing central directory")
+	}
+
+	signed, err := signV2(unsigned.Bytes(), key)
+	if err != nil {
+		return log.Err(ctx, err, "Creating APK Signature Scheme v2 signature")
+	}
+	if err := ioutil.WriteFile(dst, signed, 0644); err != nil {
+		return log.Errf(ctx, err, "Writing '%v'", dst)
+	}
+	return nil
+}
+
+// jarSignature returns the JAR manifest and signature file for the files.
+func jarSignature(files []*zip.File) (manifest, signature []byte, err error) {
+	sorted := append([]*zip.File{}, files...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Name < sorted[j].Name })
+
+	mf := &bytes.Buffer{}
+	writeManifestAttr(mf, "Manifest-Version", "1.0")
+	writeManifestAttr(mf, "Created-By", createdBy)
+	mf.WriteString("\r\n")
+
+	sections := &bytes.Buffer{}
+	for _, f := range sorted {
+		if strings.HasSuffix(f.Name, "/") {
+			continue // Directory
+		}
+		digest, err := fileDigest(f)
+		if err != nil {
+			return nil, nil, err
+		}
+		section := &bytes.Buffer{}
+		writeManifestAttr(section, "Name", f.Name)
+		writeManifestAttr(section, "SHA-256-Digest", digest)
+		section.WriteString("\r\n")
+		mf.Write(section.Bytes())
+
+		writeManifestAttr(sections, "Name", f.Name)
+		writeManifestAttr(sections, "SHA-256-Digest", base64Digest(section.Bytes()))
+		sections.WriteString("\r\n")
+	}
+
+	sf := &bytes.Buffer{}
+	writeManifestAttr(sf, "Signature-Version", "1.0")
+	writeManifestAttr(sf, "Created-By", createdBy)
+	writeManifestAttr(sf, "SHA-256-Digest-Manifest", base64Digest(mf.Bytes()))
+	// Tell verifiers that also support v2 that the v2 signature must be
+	// present, so that it cannot be stripped.
+	writeManifestAttr(sf, "X-Android-APK-Signed", "2")
+	sf.WriteString("\r\n")
+	sf.Write(sections.Bytes())
+
+	return mf.Bytes(), sf.Bytes(), nil
+}
+
+// writeManifestAttr writes the attribute to w, splitting it over lines of at
+// most 72 bytes.
+func writeManifestAttr(w *bytes.Buffer, name, value string) {
+	line := name + ": " + value
+	limit := manifestLineLength
+	for len(line) > limit {
+		w.WriteString(line[:limit])
+		w.WriteString("\r\n ")
+		line = line[limit:]
+		limit = manifestLineLength - 1
+	}
+	w.WriteString(line)
+	w.WriteString("\r\n")
+}
+
+// fileDigest returns the base64 encoded SHA-256 digest of the contents of f.
+func fileDigest(f *zip.File) (string, error) {
+	r, err := f.Open()
+	if err != nil {
+		return "", err
+	}
+	defer r.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, r); err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(h.Sum(nil)), nil
+}
+
+func base64Digest(data []byte) string {
+	digest := sha256.Sum256(data)
+	return base64.StdEncoding.EncodeToString(digest[:])
+}
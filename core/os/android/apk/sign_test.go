@@ -0,0 +1,196 @@
+// Copyright (C) 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package apk_test
+
+import (
+	"archive/zip"
+	"context"
+	"crypto/rsa"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/gapid/core/assert"
+	"github.com/google/gapid/core/log"
+	"github.com/google/gapid/core/os/android/apk"
+)
+
+func writeTestZip(t *testing.T, path string) {
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	w := zip.NewWriter(f)
+	for _, e := range []struct {
+		name   string
+		method uint16
+		data   string
+	}{
+		{"AndroidManifest.xml", zip.Deflate, "<manifest/>"},
+		{"a.txt", zip.Store, "a"},
+		{"lib/arm64-v8a/libfoo.so", zip.Store, "native library"},
+		{"res/raw/b.bin", zip.Store, "bb"},
+	} {
+		fw, err := w.CreateHeader(&zip.FileHeader{Name: e.name, Method: e.method})
+		if err != nil {
+			t.Fatal(err)
+		}
+		fw.Write([]byte(e.data))
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func checkAligned(ctx context.Context, path string) {
+	r, err := zip.OpenReader(path)
+	assert.For(ctx, "err").ThatError(err).Succeeded()
+	defer r.Close()
+	for _, f := range r.File {
+		if f.Method != zip.Store {
+			continue
+		}
+		offset, err := f.DataOffset()
+		assert.For(ctx, "err").ThatError(err).Succeeded()
+		alignment := int64(apk.DefaultAlignment)
+		if filepath.Ext(f.Name) == ".so" {
+			alignment = 4096
+		}
+		assert.For(ctx, "%v offset", f.Name).That(offset % alignment).Equals(int64(0))
+	}
+}
+
+func TestZipAlign(t_ *testing.T) {
+	ctx := log.Testing(t_)
+	dir, err := ioutil.TempDir("", "apk")
+	assert.For(ctx, "err").ThatError(err).Succeeded()
+	defer os.RemoveAll(dir)
+
+	src, dst := filepath.Join(dir, "in.apk"), filepath.Join(dir, "out.apk")
+	writeTestZip(t_, src)
+	assert.For(ctx, "err").ThatError(apk.ZipAlign(ctx, src, dst, apk.DefaultAlignment)).Succeeded()
+	checkAligned(ctx, dst)
+}
+
+func TestKeyStore(t_ *testing.T) {
+	ctx := log.Testing(t_)
+	dir, err := ioutil.TempDir("", "apk")
+	assert.For(ctx, "err").ThatError(err).Succeeded()
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "debug.keystore")
+	key, err := apk.DebugKey(ctx, path, "android", "androiddebugkey", "android")
+	assert.For(ctx, "err").ThatError(err).Succeeded()
+
+	loaded, err := apk.LoadKey(path, "android", "androiddebugkey", "android")
+	assert.For(ctx, "err").ThatError(err).Succeeded()
+	assert.For(ctx, "certificate").That(loaded.Certificate.Equal(key.Certificate)).Equals(true)
+	assert.For(ctx, "private key").That(loaded.Private.D.Cmp(key.Private.D)).Equals(0)
+
+	unknown := filepath.Join(dir, "unknown.keystore")
+	ioutil.WriteFile(unknown, []byte("not a keystore"), 0644)
+	_, err = apk.LoadKey(unknown, "android", "androiddebugkey", "android")
+	assert.For(ctx, "unknown keystore").ThatError(err).Equals(apk.ErrUnknownKeyStore)
+
+	_, err = apk.LoadKey(path, "wrong", "androiddebugkey", "android")
+	assert.For(ctx, "wrong store password").ThatError(err).Failed()
+	_, err = apk.LoadKey(path, "android", "missing", "android")
+	assert.For(ctx, "missing alias").ThatError(err).Failed()
+}
+
+func TestPKCS12KeyStore(t_ *testing.T) {
+	ctx := log.Testing(t_)
+	// The keystores were exported by OpenSSL with the default protection of
+	// newer keytool versions (PBES2 with AES-256 and an SHA-256 MAC), and with
+	// the legacy protection (3DES, RC2 and an SHA-1 MAC).
+	for _, path := range []string{"testdata/debug_pbes2.p12", "testdata/debug_legacy.p12"} {
+		key, err := apk.LoadKey(path, "android", "androiddebugkey", "android")
+		if !assert.For(ctx, "%v", path).ThatError(err).Succeeded() {
+			continue
+		}
+		assert.For(ctx, "%v subject", path).ThatString(key.Certificate.Subject.CommonName).Equals("Android Debug")
+		assert.For(ctx, "%v key", path).That(key.Private.PublicKey.N.Cmp(
+			key.Certificate.PublicKey.(*rsa.PublicKey).N)).Equals(0)
+
+		_, err = apk.LoadKey(path, "android", "AndroidDebugKey", "wrong")
+		assert.For(ctx, "%v store password fallback", path).ThatError(err).Succeeded()
+		_, err = apk.LoadKey(path, "wrong", "androiddebugkey", "android")
+		assert.For(ctx, "%v wrong store password", path).ThatError(err).Equals(apk.ErrKeyStoreTampered)
+		_, err = apk.LoadKey(path, "android", "missing", "android")
+		assert.For(ctx, "%v missing alias", path).ThatError(err).Equals(apk.ErrKeyNotFound)
+	}
+}
+
+func TestSignAndVerify(t_ *testing.T) {
+	ctx := log.Testing(t_)
+	dir, err := ioutil.TempDir("", "apk")
+	assert.For(ctx, "err").ThatError(err).Succeeded()
+	defer os.RemoveAll(dir)
+
+	key, err := apk.NewDebugKey()
+	assert.For(ctx, "err").ThatError(err).Succeeded()
+
+	src, dst := filepath.Join(dir, "in.apk"), filepath.Join(dir, "out.apk")
+	writeTestZip(t_, src)
+
+	sigs, err := apk.VerifySignatures(ctx, src)
+	assert.For(ctx, "err").ThatError(err).Succeeded()
+	assert.For(ctx, "unsigned v1").That(sigs.V1 == nil).Equals(true)
+	assert.For(ctx, "unsigned v2").That(sigs.V2 == nil).Equals(true)
+
+	assert.For(ctx, "err").ThatError(apk.Sign(ctx, src, dst, key)).Succeeded()
+	checkAligned(ctx, dst)
+
+	sigs, err = apk.VerifySignatures(ctx, dst)
+	assert.For(ctx, "err").ThatError(err).Succeeded()
+	assert.For(ctx, "v1").That(sigs.V1 != nil && sigs.V1.Equal(key.Certificate)).Equals(true)
+	assert.For(ctx, "v2").That(sigs.V2 != nil && sigs.V2.Equal(key.Certificate)).Equals(true)
+
+	// Re-signing replaces the existing signatures.
+	resigned := filepath.Join(dir, "resigned.apk")
+	assert.For(ctx, "err").ThatError(apk.Sign(ctx, dst, resigned, key)).Succeeded()
+	_, err = apk.VerifySignatures(ctx, resigned)
+	assert.For(ctx, "resigned").ThatError(err).Succeeded()
+
+	// Flip a byte of the stored a.txt entry.
+	data, err := ioutil.ReadFile(dst)
+	assert.For(ctx, "err").ThatError(err).Succeeded()
+	r, err := zip.OpenReader(dst)
+	assert.For(ctx, "err").ThatError(err).Succeeded()
+	for _, f := range r.File {
+		if f.Name == "a.txt" {
+			offset, _ := f.DataOffset()
+			data[offset] ^= 0xff
+		}
+	}
+	r.Close()
+	tampered := filepath.Join(dir, "tampered.apk")
+	assert.For(ctx, "err").ThatError(ioutil.WriteFile(tampered, data, 0644)).Succeeded()
+	_, err = apk.VerifySignatures(ctx, tampered)
+	assert.For(ctx, "tampered").ThatError(err).Failed()
+}
+
+func TestVerifyIndependentSignature(t_ *testing.T) {
+	ctx := log.Testing(t_)
+	// v1_openssl.apk has a JAR signature created with the OpenSSL command line
+	// tools, rather than by this package.
+	sigs, err := apk.VerifySignatures(ctx, "testdata/v1_openssl.apk")
+	assert.For(ctx, "err").ThatError(err).Succeeded()
+	assert.For(ctx, "v1").That(sigs.V1 != nil).Equals(true)
+	assert.For(ctx, "v1 subject").ThatString(sigs.V1.Subject.CommonName).Equals("OpenSSL Fixture")
+	assert.For(ctx, "v2").That(sigs.V2 == nil).Equals(true)
+}
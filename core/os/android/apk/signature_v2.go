@@ -0,0 +1,393 @@
+// Copyright (C) 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package apk
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/binary"
+	"fmt"
+	"math/big"
+
+	"github.com/google/gapid/core/fault"
+)
+
+// See https://source.android.com/security/apksigning/v2 for the format of the
+// APK Signature Scheme v2.
+
+const (
+	// ErrNoSigningBlock is returned when the APK has no APK Signing Block.
+	ErrNoSigningBlock = fault.Const("APK has no APK Signing Block")
+
+	signingBlockMagic  = "APK Sig Block 42"
+	signatureSchemeV2  = 0x7109871a
+	v2ChunkSize        = 1024 * 1024
+	sigRSAPSSSHA256    = 0x0101
+	sigRSAPSSSHA512    = 0x0102
+	sigRSAPKCS1SHA256  = 0x0103
+	sigRSAPKCS1SHA512  = 0x0104
+	sigECDSASHA256     = 0x0201
+	sigECDSASHA512     = 0x0202
+	eocdCentralDirSize = 12
+	eocdCentralDirOff  = 16
+)
+
+// zipSections holds the offsets of the parts of a zip archive that are
+// covered by the v2 signature.
+type zipSections struct {
+	centralDir int64 // Offset of the central directory.
+	eocd       int64 // Offset of the end of central directory record.
+}
+
+// findZipSections returns the offsets of the central directory and end of
+// central directory record of the zip archive data.
+func findZipSections(data []byte) (zipSections, error) {
+	const maxComment = 0xffff
+	for i := len(data) - zipEndOfCentralLen; i >= 0 && i >= len(data)-zipEndOfCentralLen-maxComment; i-- {
+		if binary.LittleEndian.Uint32(data[i:]) != zipEndOfCentralSig {
+			continue
+		}
+		comment := int(binary.LittleEndian.Uint16(data[i+20:]))
+		if i+zipEndOfCentralLen+comment != len(data) {
+			continue
+		}
+		cd := int64(binary.LittleEndian.Uint32(data[i+eocdCentralDirOff:]))
+		size := int64(binary.LittleEndian.Uint32(data[i+eocdCentralDirSize:]))
+		if cd+size != int64(i) {
+			return zipSections{}, fmt.Errorf("Invalid central directory offset")
+		}
+		return zipSections{centralDir: cd, eocd: int64(i)}, nil
+	}
+	return zipSections{}, fmt.Errorf("End of central directory not found")
+}
+
+// signV2 returns the zip archive apk with an APK Signing Block holding a v2
+// signature inserted before the central directory.
+func signV2(apk []byte, key *Key) ([]byte, error) {
+	s, err := findZipSections(apk)
+	if err != nil {
+		return nil, err
+	}
+	digest := v2Digest(apk, s, s.centralDir, crypto.SHA256)
+
+	pub, err := x509.MarshalPKIXPublicKey(&key.Private.PublicKey)
+	if err != nil {
+		return nil, err
+	}
+	signedData := lengthPrefixed(
+		lengthPrefixed(lengthPrefixed(uint32LE(sigRSAPKCS1SHA256), lengthPrefixed(digest))),
+		lengthPrefixed(lengthPrefixed(key.Certificate.Raw)),
+		lengthPrefixed(), // No additional attributes
+	)
+	h := crypto.SHA256.New()
+	h.Write(signedData[4:])
+	sig, err := rsa.SignPKCS1v15(rand.Reader, key.Private, crypto.SHA256, h.Sum(nil))
+	if err != nil {
+		return nil, err
+	}
+	signer := lengthPrefixed(
+		signedData,
+		lengthPrefixed(lengthPrefixed(uint32LE(sigRSAPKCS1SHA256), lengthPrefixed(sig))),
+		lengthPrefixed(pub),
+	)
+	block := signingBlock(signatureSchemeV2, lengthPrefixed(signer))
+
+	out := bytes.Buffer{}
+	out.Write(apk[:s.centralDir])
+	out.Write(block)
+	out.Write(apk[s.centralDir:s.eocd])
+	eocd := append([]byte{}, apk[s.eocd:]...)
+	binary.LittleEndian.PutUint32(eocd[eocdCentralDirOff:], uint32(s.centralDir+int64(len(block))))
+	out.Write(eocd)
+	return out.Bytes(), nil
+}
+
+// signingBlock returns an APK Signing Block holding the single ID-value pair.
+func signingBlock(id uint32, value []byte) []byte {
+	pair := append(uint32LE(id), value...)
+	pairs := append(uint64LE(uint64(len(pair))), pair...)
+	size := uint64LE(uint64(len(pairs) + 8 + len(signingBlockMagic)))
+	block := append(append([]byte{}, size...), pairs...)
+	block = append(block, size...)
+	return append(block, signingBlockMagic...)
+}
+
+// v2Digest returns the digest of the contents of the zip archive, the central
+// directory and end of central directory record of apk, with the central
+// directory offset replaced by blockOffset.
+func v2Digest(apk []byte, s zipSections, blockOffset int64, hash crypto.Hash) []byte {
+	eocd := append([]byte{}, apk[s.eocd:]...)
+	binary.LittleEndian.PutUint32(eocd[eocdCentralDirOff:], uint32(blockOffset))
+
+	chunks := [][]byte{}
+	for _, section := range [][]byte{apk[:blockOffset], apk[s.centralDir:s.eocd], eocd} {
+		for len(section) > 0 {
+			n := len(section)
+			if n > v2ChunkSize {
+				n = v2ChunkSize
+			}
+			h := hash.New()
+			h.Write([]byte{0xa5})
+			h.Write(uint32LE(uint32(n)))
+			h.Write(section[:n])
+			chunks = append(chunks, h.Sum(nil))
+			section = section[n:]
+		}
+	}
+	h := hash.New()
+	h.Write([]byte{0x5a})
+	h.Write(uint32LE(uint32(len(chunks))))
+	for _, c := range chunks {
+		h.Write(c)
+	}
+	return h.Sum(nil)
+}
+
+// verifyV2 verifies the v2 signature of apk, returning the signer's
+// certificate. ErrNoSigningBlock is returned if the APK does not have an APK
+// Signing Block, or nil if the block has no v2 signature.
+func verifyV2(apk []byte) (*x509.Certificate, error) {
+	s, err := findZipSections(apk)
+	if err != nil {
+		return nil, err
+	}
+	magic := s.centralDir - int64(len(signingBlockMagic))
+	if magic < 8 || string(apk[magic:s.centralDir]) != signingBlockMagic {
+		return nil, ErrNoSigningBlock
+	}
+	size := int64(binary.LittleEndian.Uint64(apk[magic-8:]))
+	start := s.centralDir - size - 8
+	if size < 24 || start < 0 || int64(binary.LittleEndian.Uint64(apk[start:])) != size {
+		return nil, fmt.Errorf("Invalid APK Signing Block size")
+	}
+
+	var value []byte
+	for pairs := apk[start+8 : magic-8]; len(pairs) > 0; {
+		if len(pairs) < 12 {
+			return nil, fmt.Errorf("Invalid APK Signing Block")
+		}
+		n := binary.LittleEndian.Uint64(pairs)
+		if n < 4 || n > uint64(len(pairs)-8) {
+			return nil, fmt.Errorf("Invalid APK Signing Block entry size")
+		}
+		if binary.LittleEndian.Uint32(pairs[8:]) == signatureSchemeV2 {
+			value = pairs[12 : 8+n]
+		}
+		pairs = pairs[8+n:]
+	}
+	if value == nil {
+		return nil, nil
+	}
+
+	r := &lpReader{data: value}
+	signers := r.next()
+	var cert *x509.Certificate
+	for signers.more() {
+		c, err := verifyV2Signer(apk, s, start, signers.next())
+		if err != nil {
+			return nil, err
+		}
+		if cert == nil {
+			cert = c
+		}
+	}
+	if err := r.err(); err != nil {
+		return nil, err
+	}
+	if cert == nil {
+		return nil, fmt.Errorf("No signers in v2 signature")
+	}
+	return cert, nil
+}
+
+func verifyV2Signer(apk []byte, s zipSections, blockOffset int64, signer *lpReader) (*x509.Certificate, error) {
+	signedData := signer.next()
+	signatures := signer.next()
+	pubDER := signer.next().data
+	if err := signer.err(); err != nil {
+		return nil, err
+	}
+	pub, err := x509.ParsePKIXPublicKey(pubDER)
+	if err != nil {
+		return nil, err
+	}
+
+	// Verify the strongest supported signature.
+	best, bestSig := uint32(0), []byte(nil)
+	for signatures.more() {
+		sig := signatures.next()
+		alg := sig.uint32()
+		data := sig.next().data
+		if _, ok := v2Hash(alg); ok && v2Strength(alg) > v2Strength(best) {
+			best, bestSig = alg, data
+		}
+	}
+	if err := signatures.err(); err != nil {
+		return nil, err
+	}
+	if best == 0 {
+		return nil, fmt.Errorf("No supported v2 signature algorithm")
+	}
+	hash, _ := v2Hash(best)
+	h := hash.New()
+	h.Write(signedData.data)
+	if err := v2VerifySignature(pub, best, hash, h.Sum(nil), bestSig); err != nil {
+		return nil, err
+	}
+
+	digests := signedData.next()
+	certs := signedData.next()
+	if err := signedData.err(); err != nil {
+		return nil, err
+	}
+	var expected []byte
+	for digests.more() {
+		d := digests.next()
+		if d.uint32() == best {
+			expected = d.next().data
+		}
+	}
+	if expected == nil {
+		return nil, fmt.Errorf("No digest for v2 signature algorithm 0x%x", best)
+	}
+	if got := v2Digest(apk, s, blockOffset, hash); !bytes.Equal(got, expected) {
+		return nil, fmt.Errorf("APK contents do not match the v2 signature digest")
+	}
+
+	if !certs.more() {
+		return nil, fmt.Errorf("No certificates in v2 signer")
+	}
+	cert, err := x509.ParseCertificate(certs.next().data)
+	if err != nil {
+		return nil, err
+	}
+	if !bytes.Equal(cert.RawSubjectPublicKeyInfo, pubDER) {
+		return nil, fmt.Errorf("v2 signer public key does not match its certificate")
+	}
+	return cert, nil
+}
+
+func v2Hash(alg uint32) (crypto.Hash, bool) {
+	switch alg {
+	case sigRSAPSSSHA256, sigRSAPKCS1SHA256, sigECDSASHA256:
+		return crypto.SHA256, true
+	case sigRSAPSSSHA512, sigRSAPKCS1SHA512, sigECDSASHA512:
+		return crypto.SHA512, true
+	}
+	return 0, false
+}
+
+func v2Strength(alg uint32) int {
+	if h, ok := v2Hash(alg); ok && h == crypto.SHA512 {
+		return 2
+	} else if ok {
+		return 1
+	}
+	return 0
+}
+
+func v2VerifySignature(pub interface{}, alg uint32, hash crypto.Hash, digest, sig []byte) error {
+	switch alg {
+	case sigRSAPKCS1SHA256, sigRSAPKCS1SHA512, sigRSAPSSSHA256, sigRSAPSSSHA512:
+		key, ok := pub.(*rsa.PublicKey)
+		if !ok {
+			return fmt.Errorf("v2 signature algorithm 0x%x needs an RSA key", alg)
+		}
+		if alg == sigRSAPSSSHA256 || alg == sigRSAPSSSHA512 {
+			return rsa.VerifyPSS(key, hash, digest, sig, &rsa.PSSOptions{SaltLength: hash.Size()})
+		}
+		return rsa.VerifyPKCS1v15(key, hash, digest, sig)
+	default:
+		key, ok := pub.(*ecdsa.PublicKey)
+		if !ok {
+			return fmt.Errorf("v2 signature algorithm 0x%x needs an EC key", alg)
+		}
+		var rs struct{ R, S *big.Int }
+		if _, err := asn1.Unmarshal(sig, &rs); err != nil {
+			return err
+		}
+		if !ecdsa.Verify(key, digest, rs.R, rs.S) {
+			return fmt.Errorf("Invalid ECDSA signature")
+		}
+		return nil
+	}
+}
+
+func uint32LE(v uint32) []byte {
+	b := make([]byte, 4)
+	binary.LittleEndian.PutUint32(b, v)
+	return b
+}
+
+func uint64LE(v uint64) []byte {
+	b := make([]byte, 8)
+	binary.LittleEndian.PutUint64(b, v)
+	return b
+}
+
+// lengthPrefixed returns the concatenation of parts prefixed with its 32-bit
+// little-endian length.
+func lengthPrefixed(parts ...[]byte) []byte {
+	out := []byte{0, 0, 0, 0}
+	for _, p := range parts {
+		out = append(out, p...)
+	}
+	binary.LittleEndian.PutUint32(out, uint32(len(out)-4))
+	return out
+}
+
+// lpReader reads length-prefixed values, holding on to the first error.
+type lpReader struct {
+	data  []byte
+	error error
+}
+
+func (r *lpReader) more() bool { return r.error == nil && len(r.data) > 0 }
+
+func (r *lpReader) err() error { return r.error }
+
+func (r *lpReader) uint32() uint32 {
+	if r.error != nil || len(r.data) < 4 {
+		r.fail()
+		return 0
+	}
+	v := binary.LittleEndian.Uint32(r.data)
+	r.data = r.data[4:]
+	return v
+}
+
+// next returns a reader of the next length-prefixed value. Errors are
+// propagated to the returned reader.
+func (r *lpReader) next() *lpReader {
+	n := r.uint32()
+	if r.error != nil || uint64(n) > uint64(len(r.data)) {
+		r.fail()
+		return &lpReader{error: r.error}
+	}
+	v := r.data[:n]
+	r.data = r.data[n:]
+	return &lpReader{data: v}
+}
+
+func (r *lpReader) fail() {
+	if r.error == nil {
+		r.error = fmt.Errorf("Invalid length-prefixed value")
+	}
+}
@@ -0,0 +1,279 @@
+// Copyright (C) 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package apk
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"crypto"
+	_ "crypto/sha512" // Register SHA-384 and SHA-512
+	"crypto/x509"
+	"encoding/base64"
+	"fmt"
+	"hash"
+	"io"
+	"io/ioutil"
+	"path"
+	"strings"
+
+	"github.com/google/gapid/core/fault"
+	"github.com/google/gapid/core/log"
+)
+
+// ErrInvalidSignature is returned when an APK has a signature that does not
+// verify.
+const ErrInvalidSignature = fault.Const("Invalid APK signature")
+
+// Signatures holds the signers of the signatures of an APK. A nil certificate
+// means that the APK does not have a signature of that kind.
+type Signatures struct {
+	V1 *x509.Certificate // Signer of the JAR signature.
+	V2 *x509.Certificate // Signer of the APK Signature Scheme v2 signature.
+}
+
+// VerifySignatures verifies the JAR (v1) and APK Signature Scheme v2
+// signatures of the APK at path. An error is returned if any of the APK's
+// signatures are invalid.
+func VerifySignatures(ctx context.Context, path string) (Signatures, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return Signatures{}, log.Errf(ctx, err, "Reading '%v'", path)
+	}
+	r, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return Signatures{}, log.Errf(ctx, err, "Opening '%v'", path)
+	}
+
+	out := Signatures{}
+	v1, v2Required, err := verifyV1(r.File)
+	if err != nil {
+		return Signatures{}, log.Errf(ctx, ErrInvalidSignature, "JAR signature: %v", err)
+	}
+	out.V1 = v1
+
+	v2, err := verifyV2(data)
+	switch {
+	case err == ErrNoSigningBlock:
+	case err != nil:
+		return Signatures{}, log.Errf(ctx, ErrInvalidSignature, "APK Signature Scheme v2: %v", err)
+	default:
+		out.V2 = v2
+	}
+	if v2Required && out.V2 == nil {
+		return Signatures{}, log.Errf(ctx, ErrInvalidSignature, "APK Signature Scheme v2 signature has been stripped")
+	}
+	return out, nil
+}
+
+// manifestSection is a section of a JAR manifest or signature file.
+type manifestSection struct {
+	raw   []byte // The section's bytes, including the terminating blank line.
+	attrs map[string]string
+}
+
+// parseManifest splits the JAR manifest or signature file into sections.
+func parseManifest(data []byte) []manifestSection {
+	sections := []manifestSection{}
+	start, attrs, last := 0, map[string]string{}, ""
+	for pos := 0; pos < len(data); {
+		next := len(data)
+		if i := bytes.IndexByte(data[pos:], '\n'); i >= 0 {
+			next = pos + i + 1
+		}
+		line := strings.TrimRight(string(data[pos:next]), "\r\n")
+		switch {
+		case line == "":
+			if len(attrs) > 0 {
+				sections = append(sections, manifestSection{raw: data[start:next], attrs: attrs})
+			}
+			start, attrs = next, map[string]string{}
+		case line[0] == ' ':
+			attrs[last] += line[1:]
+		default:
+			if i := strings.Index(line, ": "); i > 0 {
+				last = line[:i]
+				attrs[last] = line[i+2:]
+			}
+		}
+		pos = next
+	}
+	if len(attrs) > 0 {
+		sections = append(sections, manifestSection{raw: data[start:], attrs: attrs})
+	}
+	return sections
+}
+
+// manifestDigests returns the digest attributes with the given suffix of the
+// section, keyed by hash.
+func manifestDigests(attrs map[string]string, suffix string) map[crypto.Hash]string {
+	out := map[crypto.Hash]string{}
+	for k, v := range attrs {
+		if !strings.HasSuffix(k, suffix) {
+			continue
+		}
+		switch strings.ToUpper(strings.TrimSuffix(k, suffix)) {
+		case "SHA1", "SHA-1":
+			out[crypto.SHA1] = v
+		case "SHA-256":
+			out[crypto.SHA256] = v
+		case "SHA-384":
+			out[crypto.SHA384] = v
+		case "SHA-512":
+			out[crypto.SHA512] = v
+		}
+	}
+	return out
+}
+
+// checkDigests returns an error if any of the digests do not match data.
+// At least one digest must be present.
+func checkDigests(digests map[crypto.Hash]string, data io.Reader) error {
+	if len(digests) == 0 {
+		return fmt.Errorf("No supported digests")
+	}
+	hashes := map[crypto.Hash]hash.Hash{}
+	writers := []io.Writer{}
+	for alg := range digests {
+		h := alg.New()
+		hashes[alg] = h
+		writers = append(writers, h)
+	}
+	if _, err := io.Copy(io.MultiWriter(writers...), data); err != nil {
+		return err
+	}
+	for alg, expected := range digests {
+		got := base64.StdEncoding.EncodeToString(hashes[alg].Sum(nil))
+		if got != expected {
+			return fmt.Errorf("Digest mismatch")
+		}
+	}
+	return nil
+}
+
+// verifyV1 verifies the JAR signature of the files, returning the signer's
+// certificate, or nil if there is no JAR signature. v2Required is true if
+// the signature file says that the APK must also have a v2 signature.
+func verifyV1(files []*zip.File) (cert *x509.Certificate, v2Required bool, err error) {
+	byName := map[string]*zip.File{}
+	for _, f := range files {
+		byName[f.Name] = f
+	}
+	read := func(name string) ([]byte, error) {
+		f, ok := byName[name]
+		if !ok {
+			return nil, fmt.Errorf("'%v' not found", name)
+		}
+		r, err := f.Open()
+		if err != nil {
+			return nil, err
+		}
+		defer r.Close()
+		return ioutil.ReadAll(r)
+	}
+
+	var sfName string
+	for _, f := range files {
+		if strings.HasPrefix(f.Name, "META-INF/") && strings.HasSuffix(f.Name, ".SF") && path.Dir(f.Name) == "META-INF" {
+			if sfName != "" {
+				return nil, false, fmt.Errorf("APKs with multiple signers are not supported")
+			}
+			sfName = f.Name
+		}
+	}
+	if sfName == "" {
+		return nil, false, nil
+	}
+
+	sf, err := read(sfName)
+	if err != nil {
+		return nil, false, err
+	}
+	base := strings.TrimSuffix(sfName, ".SF")
+	for _, ext := range []string{".RSA", ".DSA", ".EC"} {
+		if block, err := read(base + ext); err == nil {
+			if cert, err = verifyPKCS7(block, sf); err != nil {
+				return nil, false, err
+			}
+			break
+		}
+	}
+	if cert == nil {
+		return nil, false, fmt.Errorf("No signature block file for '%v'", sfName)
+	}
+
+	manifest, err := read(manifestPath)
+	if err != nil {
+		return nil, false, err
+	}
+	mfSections := parseManifest(manifest)
+	sfSections := parseManifest(sf)
+	if len(mfSections) == 0 || len(sfSections) == 0 {
+		return nil, false, fmt.Errorf("Empty manifest or signature file")
+	}
+	for _, v := range strings.Split(sfSections[0].attrs["X-Android-APK-Signed"], ",") {
+		if strings.TrimSpace(v) == "2" {
+			v2Required = true
+		}
+	}
+
+	// Check the signature file covers the manifest.
+	mfByName := map[string]manifestSection{}
+	for _, s := range mfSections[1:] {
+		mfByName[s.attrs["Name"]] = s
+	}
+	if err := checkDigests(manifestDigests(sfSections[0].attrs, "-Digest-Manifest"), bytes.NewReader(manifest)); err != nil {
+		// Fall back to checking each section of the manifest.
+		if len(sfSections)-1 != len(mfByName) {
+			return nil, false, fmt.Errorf("Signature file does not cover the manifest")
+		}
+		for _, s := range sfSections[1:] {
+			name := s.attrs["Name"]
+			mf, ok := mfByName[name]
+			if !ok {
+				return nil, false, fmt.Errorf("'%v' is signed but not in the manifest", name)
+			}
+			if err := checkDigests(manifestDigests(s.attrs, "-Digest"), bytes.NewReader(mf.raw)); err != nil {
+				return nil, false, fmt.Errorf("Manifest section for '%v': %v", name, err)
+			}
+		}
+	}
+
+	// Check the manifest covers every file.
+	for _, f := range files {
+		if strings.HasSuffix(f.Name, "/") || jarSignatureFilePattern.MatchString(f.Name) {
+			continue
+		}
+		s, ok := mfByName[f.Name]
+		if !ok {
+			return nil, false, fmt.Errorf("'%v' is not signed", f.Name)
+		}
+		r, err := f.Open()
+		if err != nil {
+			return nil, false, err
+		}
+		err = checkDigests(manifestDigests(s.attrs, "-Digest"), r)
+		r.Close()
+		if err != nil {
+			return nil, false, fmt.Errorf("'%v': %v", f.Name, err)
+		}
+	}
+	for name := range mfByName {
+		if _, ok := byName[name]; !ok {
+			return nil, false, fmt.Errorf("'%v' is in the manifest but not the APK", name)
+		}
+	}
+	return cert, v2Required, nil
+}
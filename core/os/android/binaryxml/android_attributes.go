@@ -0,0 +1,57 @@
+// Copyright (C) 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package binaryxml
+
+// AndroidNamespace is the URI of the android XML namespace.
+const AndroidNamespace = "http://schemas.android.com/apk/res/android"
+
+// androidAttributes maps the names of the framework attributes commonly used
+// in manifests to their resource identifiers (android.R.attr).
+var androidAttributes = map[string]uint32{
+	"theme":                 0x01010000,
+	"label":                 0x01010001,
+	"icon":                  0x01010002,
+	"name":                  0x01010003,
+	"permission":            0x01010006,
+	"hasCode":               0x0101000c,
+	"enabled":               0x0101000e,
+	"debuggable":            debuggableAttr,
+	"exported":              0x01010010,
+	"process":               0x01010011,
+	"value":                 0x01010024,
+	"resource":              0x01010025,
+	"minSdkVersion":         0x0101020c,
+	"versionCode":           0x0101021b,
+	"versionName":           0x0101021c,
+	"targetSdkVersion":      0x01010270,
+	"maxSdkVersion":         0x01010271,
+	"allowBackup":           0x01010280,
+	"glEsVersion":           0x01010281,
+	"required":              0x0101028e,
+	"extractNativeLibs":     0x010104ea,
+	"usesCleartextTraffic":  0x010104ec,
+	"networkSecurityConfig": 0x01010527,
+}
+
+// AndroidAttribute returns the attribute in the android namespace with the
+// given name. It returns false if the attribute's resource identifier is not
+// known.
+func AndroidAttribute(name string) (Attribute, bool) {
+	id, ok := androidAttributes[name]
+	if !ok {
+		return Attribute{}, false
+	}
+	return Attribute{Namespace: AndroidNamespace, Name: name, ResourceID: id}, true
+}
@@ -0,0 +1,396 @@
+// Copyright (C) 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package binaryxml
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/google/gapid/core/fault"
+	"github.com/google/gapid/core/log"
+	"github.com/pkg/errors"
+)
+
+const (
+	// ErrElementNotFound is returned when an element to be edited is no
+	// longer part of its document.
+	ErrElementNotFound = fault.Const("Element not found in document")
+	// ErrResourceIDMismatch is returned when an attribute's resource
+	// identifier is already mapped to a different attribute name.
+	ErrResourceIDMismatch = fault.Const("Attribute resource identifier is mapped to a different name")
+)
+
+// Document is an editable binary Android XML document.
+type Document struct {
+	tree *xmlTree
+}
+
+// Element is an element of a Document.
+type Element struct {
+	doc   *Document
+	start *xmlStartElement
+}
+
+// Attribute identifies an attribute of an element. Attributes in the android
+// namespace are identified by their resource identifier, see
+// AndroidAttribute.
+type Attribute struct {
+	Namespace  string // The namespace URI, or empty.
+	Name       string
+	ResourceID uint32 // The framework resource identifier, or 0.
+}
+
+func (a Attribute) String() string {
+	if a.Namespace == "" {
+		return a.Name
+	}
+	return a.Namespace + ":" + a.Name
+}
+
+// Value is the value of an attribute.
+type Value interface {
+	// value returns the raw string and the typed value of the value in xml.
+	value(xml *xmlTree) (stringPoolRef, typedValue)
+}
+
+type stringValue string
+type boolValue bool
+type intValue int32
+type hexValue uint32
+type referenceValue uint32
+
+func (v stringValue) value(xml *xmlTree) (stringPoolRef, typedValue) {
+	ref := xml.strings.ref(string(v))
+	return ref, valStringID(ref)
+}
+func (v boolValue) value(*xmlTree) (stringPoolRef, typedValue) {
+	return invalidStringPoolRef, valIntBoolean(v)
+}
+func (v intValue) value(*xmlTree) (stringPoolRef, typedValue) {
+	return invalidStringPoolRef, valIntDec(v)
+}
+func (v hexValue) value(*xmlTree) (stringPoolRef, typedValue) {
+	return invalidStringPoolRef, valIntHex(v)
+}
+func (v referenceValue) value(*xmlTree) (stringPoolRef, typedValue) {
+	return invalidStringPoolRef, valReference(v)
+}
+
+// StringValue returns a string attribute value.
+func StringValue(s string) Value { return stringValue(s) }
+
+// BoolValue returns a boolean attribute value.
+func BoolValue(b bool) Value { return boolValue(b) }
+
+// IntValue returns a decimal integer attribute value.
+func IntValue(i int32) Value { return intValue(i) }
+
+// HexValue returns a hexadecimal integer attribute value.
+func HexValue(u uint32) Value { return hexValue(u) }
+
+// ReferenceValue returns an attribute value that references the resource
+// with the given identifier, for example @xml/network_security_config.
+func ReferenceValue(id uint32) Value { return referenceValue(id) }
+
+// ParseDocument decodes the binary Android XML document in data.
+func ParseDocument(ctx context.Context, data []byte) (*Document, error) {
+	tree, err := decodeXmlTree(bytes.NewReader(data))
+	if err != nil {
+		return nil, log.Err(ctx, err, "Decoding binary XML")
+	}
+	return &Document{tree}, nil
+}
+
+// Encode returns the document encoded as binary Android XML.
+func (d *Document) Encode() []byte {
+	return d.tree.encode()
+}
+
+// WriteTo writes the binary encoding of the document to w.
+func (d *Document) WriteTo(w io.Writer) (int64, error) {
+	n, err := w.Write(d.Encode())
+	return int64(n), err
+}
+
+// String returns the document as XML text.
+func (d *Document) String() string {
+	return d.tree.toXmlString()
+}
+
+// Root returns the root element of the document, or nil if the document is
+// empty.
+func (d *Document) Root() *Element {
+	for _, c := range d.tree.chunks {
+		if se, ok := c.(*xmlStartElement); ok {
+			return &Element{d, se}
+		}
+	}
+	return nil
+}
+
+// Find returns all the elements with the given path from the root of the
+// document, for example "manifest/application/activity".
+func (d *Document) Find(path string) []*Element {
+	out := []*Element{}
+	d.tree.visit(startElementVisitor(path, func(ctx *xmlContext, xse *xmlStartElement) {
+		out = append(out, &Element{d, xse})
+	}))
+	return out
+}
+
+// Name returns the element's name.
+func (e *Element) Name() string {
+	return e.start.name.get()
+}
+
+// Namespace returns the element's namespace URI, or an empty string.
+func (e *Element) Namespace() string {
+	if !e.start.namespace.isValid() {
+		return ""
+	}
+	return e.start.namespace.get()
+}
+
+// SetName renames the element.
+func (e *Element) SetName(name string) error {
+	_, end, err := e.bounds()
+	if err != nil {
+		return err
+	}
+	ref := e.doc.tree.strings.ref(name)
+	e.start.name = ref
+	e.doc.tree.chunks[end].(*xmlEndElement).name = ref
+	return nil
+}
+
+// Children returns the direct child elements of the element.
+func (e *Element) Children() []*Element {
+	start, end, err := e.bounds()
+	if err != nil {
+		return nil
+	}
+	out := []*Element{}
+	depth := 0
+	for _, c := range e.doc.tree.chunks[start+1 : end] {
+		switch c := c.(type) {
+		case *xmlStartElement:
+			if depth == 0 {
+				out = append(out, &Element{e.doc, c})
+			}
+			depth++
+		case *xmlEndElement:
+			depth--
+		}
+	}
+	return out
+}
+
+// Find returns all the descendants of the element with the given path
+// relative to the element, for example "application/activity".
+func (e *Element) Find(path string) []*Element {
+	out := []*Element{e}
+	for _, name := range strings.Split(path, "/") {
+		next := []*Element{}
+		for _, p := range out {
+			for _, c := range p.Children() {
+				if c.Name() == name {
+					next = append(next, c)
+				}
+			}
+		}
+		out = next
+	}
+	return out
+}
+
+// AddChild appends a new element with the given name to the element's
+// children, and returns it.
+func (e *Element) AddChild(name string) (*Element, error) {
+	_, end, err := e.bounds()
+	if err != nil {
+		return nil, err
+	}
+	xml := e.doc.tree
+	ref := xml.strings.ref(name)
+	start := &xmlStartElement{
+		lineNumber: e.start.lineNumber,
+		comment:    invalidStringPoolRef,
+		namespace:  invalidStringPoolRef,
+		name:       ref,
+	}
+	start.setRoot(xml)
+	close := &xmlEndElement{
+		lineNumber: e.start.lineNumber,
+		comment:    invalidStringPoolRef,
+		namespace:  invalidStringPoolRef,
+		name:       ref,
+	}
+	close.setRoot(xml)
+	xml.chunks = append(xml.chunks[:end], append([]chunk{start, close}, xml.chunks[end:]...)...)
+	return &Element{e.doc, start}, nil
+}
+
+// Remove removes the element and all its descendants from the document.
+func (e *Element) Remove() error {
+	start, end, err := e.bounds()
+	if err != nil {
+		return err
+	}
+	xml := e.doc.tree
+	xml.chunks = append(xml.chunks[:start], xml.chunks[end+1:]...)
+	return nil
+}
+
+// Attributes returns the attributes of the element.
+func (e *Element) Attributes() []Attribute {
+	out := make([]Attribute, len(e.start.attributes))
+	for i, at := range e.start.attributes {
+		out[i] = e.doc.tree.attribute(at)
+	}
+	return out
+}
+
+// Attribute returns the value of the attribute a as a string, and whether
+// the element has the attribute.
+func (e *Element) Attribute(a Attribute) (string, bool) {
+	if at := e.find(a); at != nil {
+		if at.rawValue.isValid() {
+			return at.rawValue.get(), true
+		}
+		return at.typedValue.String(), true
+	}
+	return "", false
+}
+
+// SetAttribute sets the value of the attribute a, adding the attribute to
+// the element if it does not already have it. The string pool and resource
+// map are updated as needed.
+func (e *Element) SetAttribute(a Attribute, v Value) error {
+	xml := e.doc.tree
+	raw, typed := v.value(xml)
+	if at := e.find(a); at != nil {
+		at.rawValue, at.typedValue = raw, typed
+		return nil
+	}
+	name, err := xml.attributeName(a)
+	if err != nil {
+		return err
+	}
+	namespace := invalidStringPoolRef
+	if a.Namespace != "" {
+		namespace = xml.strings.ref(a.Namespace)
+	}
+	e.start.addAttribute(&xmlAttribute{
+		namespace:  namespace,
+		name:       name,
+		rawValue:   raw,
+		typedValue: typed,
+	})
+	return nil
+}
+
+// RemoveAttribute removes the attribute a from the element, returning true if
+// the element had the attribute.
+func (e *Element) RemoveAttribute(a Attribute) bool {
+	for i := range e.start.attributes {
+		if e.doc.tree.attribute(e.start.attributes[i]).matches(a) {
+			e.start.attributes = append(e.start.attributes[:i], e.start.attributes[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+func (e *Element) find(a Attribute) *xmlAttribute {
+	for i := range e.start.attributes {
+		if e.doc.tree.attribute(e.start.attributes[i]).matches(a) {
+			return &e.start.attributes[i]
+		}
+	}
+	return nil
+}
+
+// bounds returns the indices of the start and end chunks of the element.
+func (e *Element) bounds() (start, end int, err error) {
+	chunks := e.doc.tree.chunks
+	start = -1
+	for i, c := range chunks {
+		if c == chunk(e.start) {
+			start = i
+			break
+		}
+	}
+	if start < 0 {
+		return 0, 0, ErrElementNotFound
+	}
+	depth := 0
+	for i := start; i < len(chunks); i++ {
+		switch chunks[i].(type) {
+		case *xmlStartElement:
+			depth++
+		case *xmlEndElement:
+			depth--
+			if depth == 0 {
+				return start, i, nil
+			}
+		}
+	}
+	return 0, 0, fmt.Errorf("Element '%v' is not closed", e.Name())
+}
+
+// matches returns true if a identifies the same attribute as o.
+func (a Attribute) matches(o Attribute) bool {
+	if a.ResourceID != 0 || o.ResourceID != 0 {
+		return a.ResourceID == o.ResourceID
+	}
+	return a.Namespace == o.Namespace && a.Name == o.Name
+}
+
+// attribute returns the Attribute identifying at.
+func (xml *xmlTree) attribute(at xmlAttribute) Attribute {
+	out := Attribute{Name: at.name.get()}
+	if at.namespace.isValid() {
+		out.Namespace = at.namespace.get()
+	}
+	if idx := at.name.stringPoolIndex(); idx < uint32(len(xml.resourceMap.ids)) {
+		out.ResourceID = xml.resourceMap.ids[idx]
+	}
+	return out
+}
+
+// attributeName returns the string pool reference to use for the name of the
+// attribute a. Names of attributes with a resource identifier are mapped to
+// the identifier in the resource map. Names of other attributes must not be
+// mapped to any resource identifier.
+func (xml *xmlTree) attributeName(a Attribute) (stringPoolRef, error) {
+	if a.ResourceID != 0 {
+		if idx, ok := xml.resourceMap.indexOf(a.ResourceID); ok {
+			if ref, ok := xml.strings.findFromStringPoolIndex(idx); !ok || ref.get() != a.Name {
+				return invalidStringPoolRef, errors.Wrapf(ErrResourceIDMismatch, "0x%x: %v", a.ResourceID, a.Name)
+			}
+		}
+		return xml.ensureAttributeNameMapsToResource(a.ResourceID, a.Name), nil
+	}
+	mapped := uint32(len(xml.resourceMap.ids))
+	for i, ptr := range xml.strings.ptrs {
+		if uint32(ptr) >= mapped && xml.strings.strings[ptr] == a.Name {
+			return stringPoolRef{xml.strings, uint32(i)}, nil
+		}
+	}
+	return xml.strings.insertStringAtIndex(a.Name, len(xml.strings.strings)), nil
+}
@@ -0,0 +1,140 @@
+// Copyright (C) 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package binaryxml
+
+import (
+	"context"
+	"io/ioutil"
+	"testing"
+
+	"github.com/google/gapid/core/assert"
+)
+
+func TestEditManifest(t *testing.T) {
+	assert := assert.To(t)
+	ctx := context.Background()
+	extractNativeLibs, _ := AndroidAttribute("extractNativeLibs")
+	networkSecurityConfig, _ := AndroidAttribute("networkSecurityConfig")
+	custom := Attribute{Name: "tracing"}
+	for _, fn := range []string{
+		"testdata/manifest1.binxml",
+		"testdata/manifest2.binxml",
+		"testdata/manifest4.binxml",
+		"testdata/manifest6.binxml",
+	} {
+		data, err := ioutil.ReadFile(fn)
+		assert.For("err").ThatError(err).Succeeded()
+		doc, err := ParseDocument(ctx, data)
+		assert.For("err").ThatError(err).Succeeded()
+
+		assert.For("add permission").ThatError(doc.AddUsesPermission("android.permission.INTERNET")).Succeeded()
+		assert.For("add permission twice").ThatError(doc.AddUsesPermission("android.permission.INTERNET")).Succeeded()
+		assert.For("extractNativeLibs").ThatError(doc.SetApplicationAttribute(extractNativeLibs, BoolValue(true))).Succeeded()
+		assert.For("networkSecurityConfig").ThatError(doc.SetApplicationAttribute(networkSecurityConfig, ReferenceValue(0x7f0f0001))).Succeeded()
+		assert.For("custom").ThatError(doc.SetApplicationAttribute(custom, StringValue("enabled"))).Succeeded()
+
+		// Make sure the edited document still parses.
+		doc, err = ParseDocument(ctx, doc.Encode())
+		assert.For("err").ThatError(err).Succeeded()
+
+		manifest, err := doc.Manifest()
+		assert.For("err").ThatError(err).Succeeded()
+		permissions := 0
+		for _, e := range manifest.Find("uses-permission") {
+			if name, _ := e.Attribute(nameAttr); name == "android.permission.INTERNET" {
+				permissions++
+			}
+		}
+		assert.For("%v permissions", fn).That(permissions).Equals(1)
+
+		app, err := doc.Application()
+		assert.For("err").ThatError(err).Succeeded()
+		v, ok := app.Attribute(extractNativeLibs)
+		assert.For("extractNativeLibs").That(ok).Equals(true)
+		assert.For("extractNativeLibs").ThatString(v).Equals("true")
+		v, _ = app.Attribute(networkSecurityConfig)
+		assert.For("networkSecurityConfig").ThatString(v).Equals("@0x7f0f0001")
+		v, _ = app.Attribute(custom)
+		assert.For("custom").ThatString(v).Equals("enabled")
+		assert.For("xml").ThatString(doc.String()).Contains(`android:extractNativeLibs="true"`)
+
+		assert.For("remove").That(app.RemoveAttribute(custom)).Equals(true)
+		assert.For("remove twice").That(app.RemoveAttribute(custom)).Equals(false)
+		assert.For("remove application").ThatError(app.Remove()).Succeeded()
+		assert.For("applications").That(len(doc.Find("manifest/application"))).Equals(0)
+		_, err = ParseDocument(ctx, doc.Encode())
+		assert.For("err").ThatError(err).Succeeded()
+	}
+}
+
+func TestEncodeXML(t *testing.T) {
+	assert := assert.To(t)
+	ctx := context.Background()
+	text := `<?xml version="1.0" encoding="utf-8"?>
+<manifest xmlns:android="http://schemas.android.com/apk/res/android"
+    package="com.example.app"
+    android:versionCode="3"
+    android:versionName="1.0.3">
+  <uses-sdk android:minSdkVersion="21" android:targetSdkVersion="26"/>
+  <uses-feature android:glEsVersion="0x00030000" android:required="true"/>
+  <application android:label="Example" android:debuggable="false" android:icon="@0x7f020000">
+    <meta-data android:name="answer" android:value="42"/>
+  </application>
+</manifest>
+`
+	data, err := EncodeXML(ctx, []byte(text))
+	assert.For("err").ThatError(err).Succeeded()
+
+	doc, err := ParseDocument(ctx, data)
+	assert.For("err").ThatError(err).Succeeded()
+	xml := doc.String()
+	for _, s := range []string{
+		`<manifest`,
+		`xmlns:android="http://schemas.android.com/apk/res/android"`,
+		`package="com.example.app"`,
+		`android:versionCode="3"`,
+		`android:versionName="1.0.3"`,
+		`android:minSdkVersion="21"`,
+		`android:glEsVersion="0x30000"`,
+		`android:required="true"`,
+		`android:label="Example"`,
+		`android:debuggable="false"`,
+		`android:icon="@0x7f020000"`,
+		`android:value="42"`,
+	} {
+		assert.For("xml").ThatString(xml).Contains(s)
+	}
+
+	// Attribute names with resource identifiers must come first in the pool.
+	for i, id := range doc.tree.resourceMap.ids {
+		name := doc.tree.strings.strings[i]
+		assert.For("resource 0x%x", id).That(androidAttributes[name]).Equals(id)
+	}
+
+	// The document should be editable like a decoded one.
+	assert.For("set debuggable").That(setManifestApplicationDebuggableAttributeToTrue(doc.tree)).Equals(true)
+	assert.For("xml").ThatString(doc.String()).Contains(`android:debuggable="true"`)
+
+	for _, bad := range []string{
+		`<manifest xmlns:android="http://schemas.android.com/apk/res/android" android:unknownAttribute="1"/>`,
+		`<manifest xmlns:android="http://schemas.android.com/apk/res/android" android:label="@string/name"/>`,
+		`<manifest xmlns="http://example.com"/>`,
+		`<manifest>`,
+		``,
+	} {
+		_, err := EncodeXML(ctx, []byte(bad))
+		assert.For("%v", bad).ThatError(err).Failed()
+	}
+}
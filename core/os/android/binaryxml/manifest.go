@@ -0,0 +1,81 @@
+// Copyright (C) 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package binaryxml
+
+import "github.com/google/gapid/core/fault"
+
+const (
+	// ErrNoManifest is returned when editing a document that has no
+	// <manifest/> root element.
+	ErrNoManifest = fault.Const("Document has no manifest element")
+	// ErrNoApplication is returned when editing a manifest that has no
+	// <application/> element.
+	ErrNoApplication = fault.Const("Manifest has no application element")
+)
+
+var nameAttr = Attribute{Namespace: AndroidNamespace, Name: "name", ResourceID: androidAttributes["name"]}
+
+// Manifest returns the <manifest/> root element of an AndroidManifest.xml
+// document.
+func (d *Document) Manifest() (*Element, error) {
+	root := d.Root()
+	if root == nil || root.Name() != "manifest" {
+		return nil, ErrNoManifest
+	}
+	return root, nil
+}
+
+// Application returns the <application/> element of an AndroidManifest.xml
+// document.
+func (d *Document) Application() (*Element, error) {
+	manifest, err := d.Manifest()
+	if err != nil {
+		return nil, err
+	}
+	apps := manifest.Find("application")
+	if len(apps) == 0 {
+		return nil, ErrNoApplication
+	}
+	return apps[0], nil
+}
+
+// AddUsesPermission adds a <uses-permission android:name="permission"/>
+// element to the manifest, unless the manifest already uses the permission.
+func (d *Document) AddUsesPermission(permission string) error {
+	manifest, err := d.Manifest()
+	if err != nil {
+		return err
+	}
+	for _, e := range manifest.Find("uses-permission") {
+		if name, _ := e.Attribute(nameAttr); name == permission {
+			return nil
+		}
+	}
+	e, err := manifest.AddChild("uses-permission")
+	if err != nil {
+		return err
+	}
+	return e.SetAttribute(nameAttr, StringValue(permission))
+}
+
+// SetApplicationAttribute sets the attribute a of the manifest's
+// <application/> element to v.
+func (d *Document) SetApplicationAttribute(a Attribute, v Value) error {
+	app, err := d.Application()
+	if err != nil {
+		return err
+	}
+	return app.SetAttribute(a, v)
+}
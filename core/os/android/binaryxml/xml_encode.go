@@ -0,0 +1,199 @@
+// Copyright (C) 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package binaryxml
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/google/gapid/core/log"
+)
+
+// EncodeXML encodes the XML text in data as binary Android XML.
+// See ParseXMLDocument for the supported subset of XML.
+func EncodeXML(ctx context.Context, data []byte) ([]byte, error) {
+	doc, err := ParseXMLDocument(ctx, data)
+	if err != nil {
+		return nil, err
+	}
+	return doc.Encode(), nil
+}
+
+// ParseXMLDocument parses the XML text in data into a Document.
+// Attributes in the android namespace must be known to AndroidAttribute.
+// Attribute values are typed in the same way as aapt does for attributes
+// that accept any format: "true" and "false" are booleans, decimal and 0x
+// prefixed numbers are integers, @0x prefixed numbers are resource references
+// and anything else is a string. Symbolic resource references, such as
+// @string/app_name, cannot be resolved and are not supported.
+func ParseXMLDocument(ctx context.Context, data []byte) (*Document, error) {
+	tree := &xmlTree{
+		strings:     &stringPool{},
+		resourceMap: &xmlResourceMap{},
+	}
+	tree.strings.setRoot(tree)
+	tree.resourceMap.setRoot(tree)
+	doc := &Document{tree}
+
+	type openElement struct {
+		start      *xmlStartElement
+		namespaces []*xmlStartNamespace
+	}
+	stack := []openElement{}
+	hasRoot := false
+
+	d := xml.NewDecoder(bytes.NewReader(data))
+	for {
+		line := uint32(bytes.Count(data[:d.InputOffset()], []byte{'\n'}) + 1)
+		tok, err := d.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, log.Err(ctx, err, "Parsing XML")
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			if len(stack) == 0 && hasRoot {
+				return nil, log.Errf(ctx, nil, "Multiple root elements")
+			}
+			hasRoot = true
+			open := openElement{}
+			for _, a := range t.Attr {
+				switch {
+				case a.Name.Space == "xmlns":
+					ns := &xmlStartNamespace{
+						lineNumber:      line,
+						comment:         invalidStringPoolRef,
+						namespacePrefix: tree.strings.ref(a.Name.Local),
+						namespaceURI:    tree.strings.ref(a.Value),
+					}
+					ns.setRoot(tree)
+					tree.chunks = append(tree.chunks, ns)
+					open.namespaces = append(open.namespaces, ns)
+				case a.Name.Space == "" && a.Name.Local == "xmlns":
+					return nil, log.Errf(ctx, nil, "Default namespaces are not supported")
+				}
+			}
+
+			open.start = &xmlStartElement{
+				lineNumber: line,
+				comment:    invalidStringPoolRef,
+				namespace:  invalidStringPoolRef,
+				name:       tree.strings.ref(t.Name.Local),
+			}
+			if t.Name.Space != "" {
+				open.start.namespace = tree.strings.ref(t.Name.Space)
+			}
+			open.start.setRoot(tree)
+			tree.chunks = append(tree.chunks, open.start)
+			stack = append(stack, open)
+
+			elem := &Element{doc, open.start}
+			for _, a := range t.Attr {
+				if a.Name.Space == "xmlns" {
+					continue
+				}
+				attr := Attribute{Namespace: a.Name.Space, Name: a.Name.Local}
+				if attr.Namespace == AndroidNamespace {
+					var ok bool
+					if attr, ok = AndroidAttribute(a.Name.Local); !ok {
+						return nil, log.Errf(ctx, nil, "Line %d: unknown android attribute '%v'", line, a.Name.Local)
+					}
+				}
+				v, err := parseValue(a.Value)
+				if err != nil {
+					return nil, log.Errf(ctx, err, "Line %d: attribute '%v'", line, attr)
+				}
+				if err := elem.SetAttribute(attr, v); err != nil {
+					return nil, log.Errf(ctx, err, "Line %d: attribute '%v'", line, attr)
+				}
+			}
+
+		case xml.EndElement:
+			open := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			end := &xmlEndElement{
+				lineNumber: line,
+				comment:    invalidStringPoolRef,
+				namespace:  open.start.namespace,
+				name:       open.start.name,
+			}
+			end.setRoot(tree)
+			tree.chunks = append(tree.chunks, end)
+			for i := len(open.namespaces) - 1; i >= 0; i-- {
+				ns := open.namespaces[i]
+				end := &xmlEndNamespace{
+					lineNumber:      line,
+					comment:         invalidStringPoolRef,
+					namespacePrefix: ns.namespacePrefix,
+					namespaceURI:    ns.namespaceURI,
+				}
+				end.setRoot(tree)
+				tree.chunks = append(tree.chunks, end)
+			}
+
+		case xml.CharData:
+			text := strings.TrimSpace(string(t))
+			if text == "" || len(stack) == 0 {
+				continue
+			}
+			cdata := &xmlCData{
+				lineNumber: line,
+				comment:    invalidStringPoolRef,
+				data:       tree.strings.ref(text),
+				typedValue: valNull(0),
+			}
+			cdata.setRoot(tree)
+			tree.chunks = append(tree.chunks, cdata)
+		}
+	}
+	if !hasRoot {
+		return nil, log.Errf(ctx, nil, "No root element")
+	}
+	return doc, nil
+}
+
+// parseValue returns the typed value for the attribute value s.
+func parseValue(s string) (Value, error) {
+	switch {
+	case s == "true":
+		return BoolValue(true), nil
+	case s == "false":
+		return BoolValue(false), nil
+	case strings.HasPrefix(s, "@0x"):
+		id, err := strconv.ParseUint(s[3:], 16, 32)
+		if err != nil {
+			return nil, err
+		}
+		return ReferenceValue(uint32(id)), nil
+	case strings.HasPrefix(s, "@") && s != "@":
+		return nil, fmt.Errorf("Cannot resolve resource reference '%v'", s)
+	case strings.HasPrefix(s, "0x"):
+		if u, err := strconv.ParseUint(s[2:], 16, 32); err == nil {
+			return HexValue(uint32(u)), nil
+		}
+	default:
+		if i, err := strconv.ParseInt(s, 10, 32); err == nil {
+			return IntValue(int32(i)), nil
+		}
+	}
+	return StringValue(s), nil
+}
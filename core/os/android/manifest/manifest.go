@@ -44,6 +44,7 @@ type Manifest struct {
 	Package     string       `xml:"package,attr"`
 	VersionCode int          `xml:"versionCode,attr"`
 	VersionName string       `xml:"versionName,attr"`
+	SDK         SDK          `xml:"uses-sdk"`
 	Application Application  `xml:"application"`
 	Features    []Feature    `xml:"uses-feature"`
 	Permissions []Permission `xml:"uses-permission"`
@@ -58,6 +59,12 @@ func Parse(ctx context.Context, s string) (Manifest, error) {
 	return m, nil
 }
 
+// SDK represents the Android API levels an APK is compatible with.
+type SDK struct {
+	MinSDK    int `xml:"minSdkVersion,attr"`
+	TargetSDK int `xml:"targetSdkVersion,attr"`
+}
+
 // Application represents an application declared in an APK.
 type Application struct {
 	Activities []Activity `xml:"activity"`
@@ -98,6 +105,21 @@ type Permission struct {
 	Name string `xml:"name,attr"`
 }
 
+// MainActivities returns the names of all the activities handling the MAIN
+// action, in declaration order.
+func (m Manifest) MainActivities() []string {
+	out := []string{}
+	for _, a := range m.Application.Activities {
+		for _, i := range a.IntentFilters {
+			if i.Action.Name == ActionMain {
+				out = append(out, a.Name)
+				break
+			}
+		}
+	}
+	return out
+}
+
 func (m Manifest) MainActivity(ctx context.Context) (activity, action string, err error) {
 	search := func(category string) (activity, action string, ok bool) {
 		for _, a := range m.Application.Activities {
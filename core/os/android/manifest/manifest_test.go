@@ -65,6 +65,10 @@ func TestParseManifest(_t *testing.T) {
 		Package:     "com.bobgames.bobsgame",
 		VersionCode: 11,
 		VersionName: "1.0",
+		SDK: manifest.SDK{
+			MinSDK:    15,
+			TargetSDK: 21,
+		},
 		Application: manifest.Application{
 			Activities: []manifest.Activity{
 				{
@@ -106,4 +110,5 @@ func TestParseManifest(_t *testing.T) {
 		},
 	}
 	assert.For(ctx, "got").That(got).DeepEquals(expected)
+	assert.For(ctx, "MainActivities").ThatSlice(got.MainActivities()).Equals([]string{"BobsGame"})
 }
@@ -16,10 +16,30 @@ package remotessh
 
 import (
 	"encoding/json"
+	"fmt"
 	"io"
 	"os/user"
 )
 
+// HostKeyCheck is the mode used to verify the host keys of SSH servers.
+type HostKeyCheck string
+
+const (
+	// StrictHostKeyCheck only accepts the host keys listed in the known_hosts
+	// file.
+	StrictHostKeyCheck = HostKeyCheck("strict")
+	// TrustOnFirstUse accepts the keys of hosts that are not listed in the
+	// known_hosts file, adding them to the file. Hosts listed with a
+	// different key are rejected.
+	TrustOnFirstUse = HostKeyCheck("tofu")
+	// NoHostKeyCheck accepts any host key. This is insecure.
+	NoHostKeyCheck = HostKeyCheck("none")
+)
+
+// DefaultConnectTimeout is the default number of seconds to wait for an SSH
+// connection to be established.
+const DefaultConnectTimeout = 30
+
 // Configuration represents a configuration for connecting
 // to an SSH remote client.
 // The SSH agent is first used to attempt connection,
@@ -41,6 +61,22 @@ type Configuration struct {
 	KnownHosts string `json:"knownHostsPath"`
 	// Environment variables to set on the connection
 	Env []string
+	// JumpHosts is a comma separated list of [user@]host[:port] SSH servers to
+	// connect through, in order, like the ProxyJump option of OpenSSH.
+	// The jump hosts are authenticated with the same keys and known_hosts file
+	// as the device.
+	JumpHosts string `json:"jumpHosts"`
+	// ForwardAgent forwards the local SSH agent to the device.
+	ForwardAgent bool `json:"forwardAgent"`
+	// HostKeyCheck is the host key verification mode. Defaults to
+	// StrictHostKeyCheck.
+	HostKeyCheck HostKeyCheck `json:"hostKeyCheck"`
+	// ConnectTimeout is the number of seconds to wait for the connection to
+	// each host to be established, or 0 to wait forever.
+	ConnectTimeout uint `json:"connectTimeout"`
+	// KeepAlive is the number of seconds between the keepalive messages sent
+	// to each host, or 0 to not send any.
+	KeepAlive uint `json:"keepAlive"`
 }
 
 // ReadConfigurations reads a set of configurations from then
@@ -58,16 +94,26 @@ func ReadConfigurations(r io.Reader) ([]Configuration, error) {
 	}
 	for d.More() {
 		cfg := Configuration{
-			Name:       "",
-			Host:       "",
-			User:       u.Username,
-			Port:       22,
-			Keyfile:    u.HomeDir + "/.ssh/id_rsa",
-			KnownHosts: u.HomeDir + "/.ssh/known_hosts",
+			Name:           "",
+			Host:           "",
+			User:           u.Username,
+			Port:           22,
+			Keyfile:        u.HomeDir + "/.ssh/id_rsa",
+			KnownHosts:     u.HomeDir + "/.ssh/known_hosts",
+			HostKeyCheck:   StrictHostKeyCheck,
+			ConnectTimeout: DefaultConnectTimeout,
 		}
 		if err := d.Decode(&cfg); err != nil {
 			return nil, err
 		}
+		switch cfg.HostKeyCheck {
+		case StrictHostKeyCheck, TrustOnFirstUse, NoHostKeyCheck:
+		default:
+			return nil, fmt.Errorf("Invalid hostKeyCheck '%v' for SSH connection %v", cfg.HostKeyCheck, cfg.Name)
+		}
+		if _, err := cfg.jumpHosts(); err != nil {
+			return nil, err
+		}
 		cfgs = append(cfgs, cfg)
 	}
 	if _, err := d.Token(); err != nil {
@@ -31,26 +31,31 @@ func TestReadConfiguration(t *testing.T) {
 	{
 		"Name": "name",
 		"Host": "localhost",
-		"Port": 22,
+		"Port": "22",
 		"User": "me",
-		"Keyfile": "~/.ssh/id_rsa",
-		"KnownHosts": "~/.ssh/known_hosts",
+		"keyPath": "~/.ssh/id_rsa",
+		"knownHostsPath": "~/.ssh/known_hosts",
 		"UseSSHAgent": true
 	},
 	{
 		"Name": "FirstConnection",
 		"User": "me",
 		"Host": "example.com",
-		"Port": 443,
-		"Keyfile": "~/.ssh/id_rsa",
-		"KnownHosts": "~/.ssh/known_hosts"
+		"Port": "443",
+		"keyPath": "~/.ssh/id_rsa",
+		"knownHostsPath": "~/.ssh/known_hosts"
 	},
 	{
 		"Name": "Connection2",
-		"Keyfile": "id_dsa",
-		"KnownHosts": "someFile",
+		"keyPath": "id_dsa",
+		"knownHostsPath": "someFile",
 		"UseSSHAgent": false,
-		"User": "me"
+		"User": "me",
+		"jumpHosts": "gateway,admin@bastion:2222",
+		"forwardAgent": true,
+		"hostKeyCheck": "tofu",
+		"connectTimeout": 5,
+		"keepAlive": 10
 	}
 ]
 `
@@ -58,33 +63,46 @@ func TestReadConfiguration(t *testing.T) {
 	configs, err := remotessh.ReadConfigurations(reader)
 
 	assert.For(ctx, "err").ThatError(err).Succeeded()
+	assert.For(ctx, "len").That(len(configs)).Equals(3)
 
 	for i, test := range []remotessh.Configuration{
 		remotessh.Configuration{
-			Name:       "name",
-			Host:       "localhost",
-			User:       "me",
-			Port:       22,
-			Keyfile:    "~/.ssh/id_rsa",
-			KnownHosts: "~/.ssh/known_hosts",
+			Name:           "name",
+			Host:           "localhost",
+			User:           "me",
+			Port:           22,
+			Keyfile:        "~/.ssh/id_rsa",
+			KnownHosts:     "~/.ssh/known_hosts",
+			HostKeyCheck:   remotessh.StrictHostKeyCheck,
+			ConnectTimeout: remotessh.DefaultConnectTimeout,
 		},
 		remotessh.Configuration{
-			Name:       "FirstConnection",
-			Host:       "example.com",
-			User:       "me",
-			Port:       443,
-			Keyfile:    "~/.ssh/id_rsa",
-			KnownHosts: "~/.ssh/known_hosts",
+			Name:           "FirstConnection",
+			Host:           "example.com",
+			User:           "me",
+			Port:           443,
+			Keyfile:        "~/.ssh/id_rsa",
+			KnownHosts:     "~/.ssh/known_hosts",
+			HostKeyCheck:   remotessh.StrictHostKeyCheck,
+			ConnectTimeout: remotessh.DefaultConnectTimeout,
 		},
 		remotessh.Configuration{
-			Name:       "Connection2",
-			User:       "me",
-			Host:       "",
-			Port:       22,
-			Keyfile:    "id_dsa",
-			KnownHosts: "someFile",
+			Name:           "Connection2",
+			User:           "me",
+			Host:           "",
+			Port:           22,
+			Keyfile:        "id_dsa",
+			KnownHosts:     "someFile",
+			JumpHosts:      "gateway,admin@bastion:2222",
+			ForwardAgent:   true,
+			HostKeyCheck:   remotessh.TrustOnFirstUse,
+			ConnectTimeout: 5,
+			KeepAlive:      10,
 		},
 	} {
 		assert.For(ctx, "configs[%v]", i).That(configs[i]).DeepEquals(test)
 	}
+
+	_, err = remotessh.ReadConfigurations(bytes.NewReader([]byte(`[{"Name": "bad", "hostKeyCheck": "maybe"}]`)))
+	assert.For(ctx, "bad hostKeyCheck").ThatError(err).Failed()
 }
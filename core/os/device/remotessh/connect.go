@@ -0,0 +1,235 @@
+// Copyright (C) 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package remotessh
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/gapid/core/app/crash"
+	"github.com/google/gapid/core/log"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+// hop is a single SSH server on the way to the device.
+type hop struct {
+	user string
+	host string
+	port uint16
+}
+
+func (h hop) addr() string {
+	return net.JoinHostPort(h.host, strconv.Itoa(int(h.port)))
+}
+
+// jumpHosts parses the JumpHosts list of the configuration.
+func (c Configuration) jumpHosts() ([]hop, error) {
+	out := []hop{}
+	if strings.TrimSpace(c.JumpHosts) == "" {
+		return out, nil
+	}
+	for _, s := range strings.Split(c.JumpHosts, ",") {
+		h := hop{user: c.User, port: 22}
+		s = strings.TrimSpace(s)
+		if i := strings.LastIndex(s, "@"); i >= 0 {
+			h.user, s = s[:i], s[i+1:]
+		}
+		h.host = s
+		if host, port, err := net.SplitHostPort(s); err == nil {
+			p, err := strconv.ParseUint(port, 10, 16)
+			if err != nil {
+				return nil, fmt.Errorf("Invalid port in jump host '%v' for SSH connection %v", s, c.Name)
+			}
+			h.host, h.port = host, uint16(p)
+		}
+		if h.host == "" || h.user == "" {
+			return nil, fmt.Errorf("Invalid jump host '%v' for SSH connection %v", s, c.Name)
+		}
+		out = append(out, h)
+	}
+	return out, nil
+}
+
+// knownHostsMutex guards the updates of the known_hosts files.
+var knownHostsMutex sync.Mutex
+
+// hostKeyCallback returns the host key verification function for the
+// configuration's HostKeyCheck mode.
+func hostKeyCallback(ctx context.Context, c Configuration) (ssh.HostKeyCallback, error) {
+	switch c.HostKeyCheck {
+	case NoHostKeyCheck:
+		log.W(ctx, "Host key verification is disabled for SSH connection %s", c.Name)
+		return ssh.InsecureIgnoreHostKey(), nil
+	case TrustOnFirstUse:
+		return func(hostname string, remote net.Addr, key ssh.PublicKey) error {
+			knownHostsMutex.Lock()
+			defer knownHostsMutex.Unlock()
+			hosts, err := knownhosts.New(c.KnownHosts)
+			switch {
+			case os.IsNotExist(err):
+				err = &knownhosts.KeyError{}
+			case err == nil:
+				err = hosts(hostname, remote, key)
+			}
+			if keyErr, ok := err.(*knownhosts.KeyError); ok && len(keyErr.Want) == 0 {
+				log.W(ctx, "Adding the %v key of unknown host %v to %v", key.Type(), hostname, c.KnownHosts)
+				return addKnownHost(c.KnownHosts, hostname, key)
+			}
+			return err
+		}, nil
+	default:
+		hosts, err := knownhosts.New(c.KnownHosts)
+		if err != nil {
+			return nil, log.Errf(ctx, err, "Could not read known hosts")
+		}
+		return hosts, nil
+	}
+}
+
+// addKnownHost appends the key of the host to the known_hosts file at path,
+// creating the file if needed.
+func addKnownHost(path, hostname string, key ssh.PublicKey) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintln(f, knownhosts.Line([]string{knownhosts.Normalize(hostname)}, key))
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// dial connects to the device of the configuration, going through its jump
+// hosts.
+func dial(ctx context.Context, c Configuration, auths []ssh.AuthMethod) (*ssh.Client, error) {
+	hostKeys, err := hostKeyCallback(ctx, c)
+	if err != nil {
+		return nil, err
+	}
+	hops, err := c.jumpHosts()
+	if err != nil {
+		return nil, err
+	}
+	hops = append(hops, hop{user: c.User, host: c.Host, port: c.Port})
+
+	timeout := time.Duration(c.ConnectTimeout) * time.Second
+	clients := []*ssh.Client{}
+	closeAll := func() {
+		for i := len(clients) - 1; i >= 0; i-- {
+			clients[i].Close()
+		}
+	}
+	for _, h := range hops {
+		client, err := dialHop(h, clients, &ssh.ClientConfig{
+			User:            h.user,
+			Auth:            auths,
+			HostKeyCallback: hostKeys,
+			Timeout:         timeout,
+		})
+		if err != nil {
+			closeAll()
+			return nil, log.Errf(ctx, err, "Connecting to %s@%s for SSH connection %s", h.user, h.addr(), c.Name)
+		}
+		clients = append(clients, client)
+		if c.KeepAlive > 0 {
+			keepAlive(ctx, client, time.Duration(c.KeepAlive)*time.Second)
+		}
+	}
+
+	device := clients[len(clients)-1]
+	if len(clients) > 1 {
+		// Close the jump host connections with the device connection.
+		crash.Go(func() {
+			device.Wait()
+			closeAll()
+		})
+	}
+	return device, nil
+}
+
+// dialHop connects to the SSH server h, through the last of the already
+// connected jump hosts if any.
+func dialHop(h hop, jumps []*ssh.Client, cfg *ssh.ClientConfig) (*ssh.Client, error) {
+	var conn net.Conn
+	var err error
+	if len(jumps) == 0 {
+		conn, err = net.DialTimeout("tcp", h.addr(), cfg.Timeout)
+	} else {
+		conn, err = jumps[len(jumps)-1].Dial("tcp", h.addr())
+	}
+	if err != nil {
+		return nil, err
+	}
+	if cfg.Timeout > 0 {
+		// The SSH handshake does not time out by itself.
+		timer := time.AfterFunc(cfg.Timeout, func() { conn.Close() })
+		defer timer.Stop()
+	}
+	clientConn, chans, reqs, err := ssh.NewClientConn(conn, h.addr(), cfg)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return ssh.NewClient(clientConn, chans, reqs), nil
+}
+
+// keepAlive sends a keepalive request to the server of client every interval,
+// closing the client if the server does not answer within the interval.
+func keepAlive(ctx context.Context, client *ssh.Client, interval time.Duration) {
+	done := make(chan struct{})
+	crash.Go(func() {
+		client.Wait()
+		close(done)
+	})
+	crash.Go(func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				res := make(chan error, 1)
+				crash.Go(func() {
+					_, _, err := client.SendRequest("keepalive@openssh.com", true, nil)
+					res <- err
+				})
+				select {
+				case err := <-res:
+					if err == nil {
+						continue
+					}
+					log.W(ctx, "SSH keepalive to %v failed: %v", client.RemoteAddr(), err)
+				case <-time.After(interval):
+					log.W(ctx, "SSH keepalive to %v timed out", client.RemoteAddr())
+				}
+				client.Close()
+				return
+			}
+		}
+	})
+}
@@ -0,0 +1,239 @@
+// Copyright (C) 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package remotessh
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"testing"
+
+	"github.com/google/gapid/core/assert"
+	"github.com/google/gapid/core/log"
+	"golang.org/x/crypto/ed25519"
+	"golang.org/x/crypto/ssh"
+)
+
+// testServer is an in-process SSH server that accepts the client key, answers
+// global requests and forwards direct-tcpip channels.
+type testServer struct {
+	listener net.Listener
+	config   *ssh.ServerConfig
+	port     uint16
+
+	mutex sync.Mutex
+	users []string
+}
+
+func newTestServer(t *testing.T, client ssh.PublicKey) *testServer {
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hostKey, err := ssh.NewSignerFromKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &testServer{listener: l, port: uint16(l.Addr().(*net.TCPAddr).Port)}
+	s.config = &ssh.ServerConfig{
+		PublicKeyCallback: func(c ssh.ConnMetadata, key ssh.PublicKey) (*ssh.Permissions, error) {
+			if !bytes.Equal(key.Marshal(), client.Marshal()) {
+				return nil, fmt.Errorf("Unknown key")
+			}
+			s.mutex.Lock()
+			s.users = append(s.users, c.User())
+			s.mutex.Unlock()
+			return nil, nil
+		},
+	}
+	s.config.AddHostKey(hostKey)
+	go s.serve()
+	return s
+}
+
+func (s *testServer) serve() {
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+		go func() {
+			_, chans, reqs, err := ssh.NewServerConn(conn, s.config)
+			if err != nil {
+				return
+			}
+			go func() {
+				for r := range reqs {
+					r.Reply(true, nil)
+				}
+			}()
+			for c := range chans {
+				if c.ChannelType() != "direct-tcpip" {
+					c.Reject(ssh.UnknownChannelType, "unsupported")
+					continue
+				}
+				go forward(c)
+			}
+		}()
+	}
+}
+
+func forward(c ssh.NewChannel) {
+	// host string, port uint32, origin string, origin port uint32
+	data := c.ExtraData()
+	n := binary.BigEndian.Uint32(data)
+	host, port := string(data[4:4+n]), binary.BigEndian.Uint32(data[4+n:])
+	conn, err := net.Dial("tcp", net.JoinHostPort(host, strconv.Itoa(int(port))))
+	if err != nil {
+		c.Reject(ssh.ConnectionFailed, err.Error())
+		return
+	}
+	ch, reqs, err := c.Accept()
+	if err != nil {
+		conn.Close()
+		return
+	}
+	go ssh.DiscardRequests(reqs)
+	go func() {
+		io.Copy(ch, conn)
+		ch.CloseWrite()
+	}()
+	io.Copy(conn, ch)
+	conn.Close()
+}
+
+func (s *testServer) close() { s.listener.Close() }
+
+func (s *testServer) loggedIn() []string {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return append([]string{}, s.users...)
+}
+
+func TestDial(t *testing.T) {
+	ctx := log.Testing(t)
+
+	dir, err := ioutil.TempDir("", "remotessh")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signer, err := ssh.NewSignerFromKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	auths := []ssh.AuthMethod{ssh.PublicKeys(signer)}
+
+	gateway := newTestServer(t, signer.PublicKey())
+	defer gateway.close()
+	target := newTestServer(t, signer.PublicKey())
+	defer target.close()
+
+	cfg := Configuration{
+		Name:           "lab",
+		Host:           "127.0.0.1",
+		User:           "me",
+		Port:           target.port,
+		KnownHosts:     filepath.Join(dir, "ssh", "known_hosts"),
+		JumpHosts:      fmt.Sprintf("jumper@127.0.0.1:%d", gateway.port),
+		HostKeyCheck:   StrictHostKeyCheck,
+		ConnectTimeout: 5,
+		KeepAlive:      1,
+	}
+
+	_, err = dial(ctx, cfg, auths)
+	assert.For(ctx, "strict, no known_hosts").ThatError(err).Failed()
+
+	cfg.HostKeyCheck = TrustOnFirstUse
+	client, err := dial(ctx, cfg, auths)
+	if !assert.For(ctx, "tofu").ThatError(err).Succeeded() {
+		return
+	}
+	ok, _, err := client.SendRequest("ping", true, nil)
+	assert.For(ctx, "ping err").ThatError(err).Succeeded()
+	assert.For(ctx, "ping").That(ok).Equals(true)
+	client.Close()
+
+	assert.For(ctx, "gateway users").ThatSlice(gateway.loggedIn()).Equals([]string{"jumper"})
+	assert.For(ctx, "target users").ThatSlice(target.loggedIn()).Equals([]string{"me"})
+	known, err := ioutil.ReadFile(cfg.KnownHosts)
+	assert.For(ctx, "read known_hosts").ThatError(err).Succeeded()
+	assert.For(ctx, "known hosts").ThatSlice(bytes.Split(bytes.TrimSpace(known), []byte("\n"))).IsLength(2)
+
+	cfg.HostKeyCheck = StrictHostKeyCheck
+	client, err = dial(ctx, cfg, auths)
+	if assert.For(ctx, "strict, known hosts").ThatError(err).Succeeded() {
+		client.Close()
+	}
+
+	// A host already in known_hosts with another key is rejected.
+	other := newTestServer(t, signer.PublicKey())
+	defer other.close()
+	if err := appendKnownHost(cfg.KnownHosts, other.port); err != nil {
+		t.Fatal(err)
+	}
+	cfg.Port = other.port
+	cfg.HostKeyCheck = TrustOnFirstUse
+	_, err = dial(ctx, cfg, auths)
+	assert.For(ctx, "tofu, changed key").ThatError(err).Failed()
+}
+
+// appendKnownHost records a random host key for the local port in the
+// known_hosts file.
+func appendKnownHost(path string, port uint16) error {
+	pub, _, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return err
+	}
+	key, err := ssh.NewPublicKey(pub)
+	if err != nil {
+		return err
+	}
+	return addKnownHost(path, net.JoinHostPort("127.0.0.1", strconv.Itoa(int(port))), key)
+}
+
+func TestJumpHosts(t *testing.T) {
+	ctx := log.Testing(t)
+
+	cfg := Configuration{Name: "lab", User: "me", JumpHosts: "gateway, admin@bastion:2222,[::1]:23"}
+	hops, err := cfg.jumpHosts()
+	assert.For(ctx, "err").ThatError(err).Succeeded()
+	assert.For(ctx, "hops").ThatSlice(hops).Equals([]hop{
+		{user: "me", host: "gateway", port: 22},
+		{user: "admin", host: "bastion", port: 2222},
+		{user: "me", host: "::1", port: 23},
+	})
+
+	cfg.JumpHosts = "admin@bastion:ssh"
+	_, err = cfg.jumpHosts()
+	assert.For(ctx, "bad port").ThatError(err).Failed()
+}
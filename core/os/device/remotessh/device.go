@@ -32,7 +32,6 @@ import (
 	"github.com/google/gapid/core/os/shell"
 	"golang.org/x/crypto/ssh"
 	"golang.org/x/crypto/ssh/agent"
-	"golang.org/x/crypto/ssh/knownhosts"
 )
 
 // Device extends the bind.Device interface with capabilities specific to
@@ -118,6 +117,10 @@ func (b *binding) newPooledSession() (*pooledSession, error) {
 		err = fmt.Errorf("New SSH Session Error: %v, Current maximum number of ssh connections GAPID can issue to each remote device is: %v", err, MaxNumberOfSSHConnections)
 		return nil, err
 	}
+	if b.configuration.ForwardAgent {
+		// Failures are ignored, as most commands do not need the agent.
+		agent.RequestAgentForwarding(session)
+	}
 	return &pooledSession{
 		ch:      b.ch,
 		session: session,
@@ -144,10 +147,10 @@ func Devices(ctx context.Context, configuration io.Reader) ([]bind.Device, error
 	return devices, nil
 }
 
-// getSSHAgent returns a connection to a local SSH agent, if one exists.
-func getSSHAgent() ssh.AuthMethod {
+// getSSHAgent returns a client of the local SSH agent, if one exists.
+func getSSHAgent() agent.ExtendedAgent {
 	if sshAgent, err := net.Dial("unix", os.Getenv("SSH_AUTH_SOCK")); err == nil {
-		return ssh.PublicKeysCallback(agent.NewClient(sshAgent).Signers)
+		return agent.NewClient(sshAgent)
 	}
 	return nil
 }
@@ -179,28 +182,25 @@ func GetConnectedDevice(ctx context.Context, c Configuration) (Device, error) {
 		}
 	}
 
-	if agent := getSSHAgent(); agent != nil {
-		auths = append(auths, agent)
+	sshAgent := getSSHAgent()
+	if sshAgent != nil {
+		auths = append(auths, ssh.PublicKeysCallback(sshAgent.Signers))
 	}
 
 	if len(auths) == 0 {
 		return nil, log.Errf(ctx, nil, "No valid authentication method for SSH connection %s", c.Name)
 	}
 
-	hosts, err := knownhosts.New(c.KnownHosts)
+	connection, err := dial(ctx, c, auths)
 	if err != nil {
-		return nil, log.Errf(ctx, err, "Could not read known hosts")
-	}
-
-	sshConfig := &ssh.ClientConfig{
-		User:            c.User,
-		Auth:            auths,
-		HostKeyCallback: hosts,
+		return nil, err
 	}
-
-	connection, err := ssh.Dial("tcp", fmt.Sprintf("%s:%d", c.Host, c.Port), sshConfig)
-	if err != nil {
-		return nil, log.Errf(ctx, err, "Dial tcp: %s:%d with sshConfig: %v failed", c.Host, c.Port, sshConfig)
+	if c.ForwardAgent {
+		if sshAgent == nil {
+			log.W(ctx, "No SSH agent to forward for SSH connection %s", c.Name)
+		} else if err := agent.ForwardToAgent(connection, sshAgent); err != nil {
+			return nil, log.Errf(ctx, err, "Forwarding SSH agent")
+		}
 	}
 	env := shell.NewEnv()
 
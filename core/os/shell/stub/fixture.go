@@ -0,0 +1,97 @@
+// Copyright (C) 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package stub
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+
+	"github.com/google/gapid/core/os/shell"
+)
+
+// Exchange is a single recorded execution of a command.
+type Exchange struct {
+	// Name is the name of the command that was run.
+	Name string `json:"name"`
+	// Args is the arguments handed to the command.
+	Args []string `json:"args,omitempty"`
+	// Dir is the working directory of the command, if set.
+	Dir string `json:"dir,omitempty"`
+	// Stdin is the data the command read from its standard input.
+	Stdin string `json:"stdin,omitempty"`
+	// Stdout is the data the command wrote to its standard output.
+	Stdout string `json:"stdout,omitempty"`
+	// Stderr is the data the command wrote to its standard error.
+	Stderr string `json:"stderr,omitempty"`
+	// ExitCode is the exit status of a command that failed, or 0 if it
+	// succeeded or its exit status is not known.
+	ExitCode int `json:"exitCode,omitempty"`
+	// StartError is the error returned when starting the command, if any.
+	StartError string `json:"startError,omitempty"`
+	// WaitError is the error returned when waiting for the command, if any.
+	WaitError string `json:"waitError,omitempty"`
+}
+
+// Cmd returns the command of the exchange.
+func (e Exchange) Cmd() shell.Cmd {
+	return shell.Command(e.Name, e.Args...).In(e.Dir)
+}
+
+func (e Exchange) String() string {
+	return fmt.Sprint(e.Cmd())
+}
+
+// Fixture is an ordered list of exchanges, recorded by a Recorder and served
+// by a Playback target.
+type Fixture struct {
+	// Exchanges are the exchanges in the order in which the commands were started.
+	Exchanges []Exchange `json:"exchanges"`
+}
+
+// LoadFixture reads the fixture from the JSON file at path.
+func LoadFixture(path string) (*Fixture, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	f := &Fixture{}
+	if err := json.Unmarshal(data, f); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// Save writes the fixture as a JSON file to path.
+func (f *Fixture) Save(path string) error {
+	data, err := json.MarshalIndent(f, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, append(data, '\n'), 0666)
+}
+
+// ExitError is the error returned by a played back process that exited with
+// a non-zero status.
+type ExitError struct {
+	// Code is the exit status of the process.
+	Code int
+	// Message is the recorded error message.
+	Message string
+}
+
+func (e ExitError) Error() string {
+	return e.Message
+}
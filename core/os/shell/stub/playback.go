@@ -0,0 +1,163 @@
+// Copyright (C) 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package stub
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"regexp"
+	"sync"
+
+	"github.com/google/gapid/core/os/shell"
+)
+
+// Playback is an implementation of Target that serves the exchanges of a
+// Fixture, typically recorded by a Recorder, in place of running the
+// commands.
+// Each exchange is played back at most once. Commands that do not match an
+// exchange are refused with an UnhandledCmdError, so a Playback can be
+// combined with other stub targets using OneOf.
+type Playback struct {
+	// Fixture holds the exchanges to play back.
+	Fixture *Fixture
+	// Strict requires commands to be started in the same order as they were
+	// recorded. If false, the first unplayed exchange with a matching command
+	// is played back.
+	Strict bool
+	// Normalize, if set, is applied to both the recorded and the started
+	// command lines before comparing them. This can be used to ignore parts of
+	// commands that vary from run to run, such as ports or temporary paths.
+	Normalize func(string) string
+	// Repeat allows, if no unplayed exchange matches, the last played exchange
+	// with a matching command to be played back again. It is ignored if Strict
+	// is set.
+	Repeat bool
+
+	mutex  sync.Mutex
+	played []bool
+	last   map[string]int
+}
+
+// PlaybackFile returns a Playback target that strictly serves the exchanges of
+// the fixture file at path in the order they were recorded.
+func PlaybackFile(path string) (*Playback, error) {
+	f, err := LoadFixture(path)
+	if err != nil {
+		return nil, err
+	}
+	return &Playback{Fixture: f, Strict: true}, nil
+}
+
+var numbers = regexp.MustCompile(`[0-9]+`)
+
+// IgnoreNumbers is a Playback normalization function that treats all
+// numbers in command lines as equal.
+func IgnoreNumbers(cmd string) string {
+	return numbers.ReplaceAllString(cmd, "#")
+}
+
+func (t *Playback) Start(cmd shell.Cmd) (shell.Process, error) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	if t.played == nil {
+		t.played = make([]bool, len(t.Fixture.Exchanges))
+		t.last = map[string]int{}
+	}
+
+	want := t.normalize(fmt.Sprint(cmd.On(nil)))
+	match := -1
+	for i, e := range t.Fixture.Exchanges {
+		if t.played[i] {
+			continue
+		}
+		if t.normalize(e.String()) == want {
+			match = i
+		}
+		if match >= 0 || t.Strict {
+			break
+		}
+	}
+	if match < 0 {
+		i, ok := t.last[want]
+		if !ok || !t.Repeat || t.Strict {
+			return nil, UnhandledCmdError(cmd)
+		}
+		match = i
+	}
+	t.played[match] = true
+	t.last[want] = match
+
+	e := t.Fixture.Exchanges[match]
+	if e.StartError != "" {
+		return nil, errors.New(e.StartError)
+	}
+	return &playbackProcess{cmd: cmd, exchange: e}, nil
+}
+
+// Unplayed returns the exchanges that have not been played back yet.
+func (t *Playback) Unplayed() []Exchange {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	out := []Exchange{}
+	for i, e := range t.Fixture.Exchanges {
+		if t.played == nil || !t.played[i] {
+			out = append(out, e)
+		}
+	}
+	return out
+}
+
+func (t *Playback) normalize(s string) string {
+	if t.Normalize == nil {
+		return s
+	}
+	return t.Normalize(s)
+}
+
+func (*Playback) String() string { return "playback" }
+
+type playbackProcess struct {
+	once     sync.Once
+	cmd      shell.Cmd
+	exchange Exchange
+}
+
+func (p *playbackProcess) Wait(ctx context.Context) error {
+	p.once.Do(func() {
+		if p.cmd.Stdin != nil {
+			io.Copy(ioutil.Discard, p.cmd.Stdin)
+		}
+		if p.cmd.Stdout != nil {
+			io.WriteString(p.cmd.Stdout, p.exchange.Stdout)
+		}
+		if p.cmd.Stderr != nil {
+			io.WriteString(p.cmd.Stderr, p.exchange.Stderr)
+		}
+	})
+	switch {
+	case p.exchange.ExitCode != 0:
+		return ExitError{Code: p.exchange.ExitCode, Message: p.exchange.WaitError}
+	case p.exchange.WaitError != "":
+		return errors.New(p.exchange.WaitError)
+	}
+	return nil
+}
+
+func (p *playbackProcess) Kill() error {
+	return nil
+}
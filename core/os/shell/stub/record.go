@@ -0,0 +1,159 @@
+// Copyright (C) 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package stub
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os/exec"
+	"sync"
+
+	"github.com/google/gapid/core/os/shell"
+)
+
+// Recorder is an implementation of Target that runs commands on another
+// target, and records every command along with its input, output and exit
+// status so that they can be played back later by a Playback target.
+type Recorder struct {
+	// Target is the target that runs the commands.
+	Target shell.Target
+
+	mutex     sync.Mutex
+	exchanges []*Exchange
+}
+
+// Record returns a Recorder that records the commands run on target.
+func Record(target shell.Target) *Recorder {
+	return &Recorder{Target: target}
+}
+
+func (r *Recorder) Start(cmd shell.Cmd) (shell.Process, error) {
+	e := &Exchange{Name: cmd.Name, Args: append([]string{}, cmd.Args...), Dir: cmd.Dir}
+	r.mutex.Lock()
+	r.exchanges = append(r.exchanges, e)
+	r.mutex.Unlock()
+
+	p := &recordedProcess{recorder: r, exchange: e}
+	if cmd.Stdin != nil {
+		cmd.Stdin = io.TeeReader(cmd.Stdin, &p.stdin)
+	}
+	cmd.Stdout = teeWriter(cmd.Stdout, &p.stdout)
+	cmd.Stderr = teeWriter(cmd.Stderr, &p.stderr)
+
+	process, err := r.Target.Start(cmd)
+	if err != nil {
+		r.mutex.Lock()
+		e.StartError = err.Error()
+		r.mutex.Unlock()
+		return nil, err
+	}
+	p.process = process
+	return p, nil
+}
+
+// Fixture returns a fixture holding the exchanges recorded so far.
+func (r *Recorder) Fixture() *Fixture {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	f := &Fixture{Exchanges: make([]Exchange, len(r.exchanges))}
+	for i, e := range r.exchanges {
+		f.Exchanges[i] = *e
+	}
+	return f
+}
+
+// Save writes the exchanges recorded so far as a fixture file to path.
+func (r *Recorder) Save(path string) error {
+	return r.Fixture().Save(path)
+}
+
+func (r *Recorder) String() string { return "record" }
+
+type recordedProcess struct {
+	recorder *Recorder
+	exchange *Exchange
+	process  shell.Process
+	once     sync.Once
+	stdin    lockedBuffer
+	stdout   lockedBuffer
+	stderr   lockedBuffer
+}
+
+func (p *recordedProcess) Wait(ctx context.Context) error {
+	err := p.process.Wait(ctx)
+	p.once.Do(func() {
+		p.recorder.mutex.Lock()
+		defer p.recorder.mutex.Unlock()
+		p.exchange.Stdin = p.stdin.String()
+		p.exchange.Stdout = p.stdout.String()
+		p.exchange.Stderr = p.stderr.String()
+		if err != nil {
+			p.exchange.ExitCode = exitCode(err)
+			p.exchange.WaitError = err.Error()
+		}
+	})
+	return err
+}
+
+func (p *recordedProcess) Kill() error {
+	return p.process.Kill()
+}
+
+// exitCode returns the exit status of a process that failed with err, or 0 if
+// the exit status is not known.
+func exitCode(err error) int {
+	switch err := err.(type) {
+	case *exec.ExitError:
+		if status, ok := err.Sys().(interface {
+			ExitStatus() int
+		}); ok {
+			return status.ExitStatus()
+		}
+	case ExitError:
+		return err.Code
+	}
+	// Remote targets report the exit status in the error message.
+	code := 0
+	if n, _ := fmt.Sscanf(err.Error(), "exit status %d", &code); n == 1 {
+		return code
+	}
+	return 0
+}
+
+func teeWriter(w io.Writer, buf io.Writer) io.Writer {
+	if w == nil {
+		return buf
+	}
+	return io.MultiWriter(w, buf)
+}
+
+type lockedBuffer struct {
+	mutex sync.Mutex
+	buf   bytes.Buffer
+}
+
+func (b *lockedBuffer) Write(data []byte) (int, error) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	return b.buf.Write(data)
+}
+
+func (b *lockedBuffer) String() string {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	return b.buf.String()
+}
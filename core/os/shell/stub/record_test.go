@@ -0,0 +1,103 @@
+// Copyright (C) 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package stub_test
+
+import (
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/gapid/core/assert"
+	"github.com/google/gapid/core/log"
+	"github.com/google/gapid/core/os/shell"
+	"github.com/google/gapid/core/os/shell/stub"
+)
+
+// cat is a target that copies its standard input to its standard output.
+type cat struct{}
+
+func (cat) Start(cmd shell.Cmd) (shell.Process, error) {
+	io.Copy(cmd.Stdout, cmd.Stdin)
+	return (&stub.Response{}).Start(cmd)
+}
+
+func TestRecordPlayback(t_ *testing.T) {
+	ctx := log.Testing(t_)
+	dir, err := ioutil.TempDir("", "stub")
+	assert.For(ctx, "err").ThatError(err).Succeeded()
+	defer os.RemoveAll(dir)
+
+	device := stub.OneOf(
+		stub.RespondTo("adb devices", "List of devices attached\nserial1 device"),
+		stub.Match("adb -s serial1 shell cat /missing", &stub.Response{
+			Stderr:  "No such file or directory",
+			WaitErr: stub.ExitError{Code: 1, Message: "exit status 1"},
+		}),
+		stub.Match("adb -s serial1 forward tcp:1234 localabstract:gapii", stub.Respond("")),
+		stub.Match("cat", cat{}),
+		stub.Echo{},
+	)
+	recorder := stub.Record(device)
+	run := func(target shell.Target, name string, args ...string) (string, error) {
+		return shell.Command(name, args...).On(target).Call(ctx)
+	}
+
+	out, err := run(recorder, "adb", "devices")
+	assert.For(ctx, "devices").ThatError(err).Succeeded()
+	assert.For(ctx, "devices").ThatString(out).Contains("serial1")
+	_, err = run(recorder, "adb", "-s", "serial1", "shell", "cat", "/missing")
+	assert.For(ctx, "cat").ThatError(err).Failed()
+	_, err = run(recorder, "adb", "-s", "serial1", "forward", "tcp:1234", "localabstract:gapii")
+	assert.For(ctx, "forward").ThatError(err).Succeeded()
+	out, err = shell.Command("cat").On(recorder).Read(strings.NewReader("input")).Call(ctx)
+	assert.For(ctx, "stdin").ThatError(err).Succeeded()
+
+	path := filepath.Join(dir, "fixture.json")
+	assert.For(ctx, "save").ThatError(recorder.Save(path)).Succeeded()
+	fixture, err := stub.LoadFixture(path)
+	assert.For(ctx, "load").ThatError(err).Succeeded()
+	assert.For(ctx, "exchanges").That(len(fixture.Exchanges)).Equals(4)
+	assert.For(ctx, "exit code").That(fixture.Exchanges[1].ExitCode).Equals(1)
+	assert.For(ctx, "stderr").ThatString(fixture.Exchanges[1].Stderr).Equals("No such file or directory")
+	assert.For(ctx, "stdin").ThatString(fixture.Exchanges[3].Stdin).Equals("input")
+
+	// Strict playback serves the exchanges in order.
+	strict, err := stub.PlaybackFile(path)
+	assert.For(ctx, "err").ThatError(err).Succeeded()
+	_, err = run(strict, "adb", "-s", "serial1", "shell", "cat", "/missing")
+	assert.For(ctx, "out of order").ThatError(err).Failed()
+	out, err = run(strict, "adb", "devices")
+	assert.For(ctx, "devices").ThatError(err).Succeeded()
+	assert.For(ctx, "devices").ThatString(out).Equals("List of devices attached\nserial1 device")
+	_, err = run(strict, "adb", "-s", "serial1", "shell", "cat", "/missing")
+	assert.For(ctx, "cat").ThatError(err).Failed()
+	assert.For(ctx, "unplayed").That(len(strict.Unplayed())).Equals(2)
+
+	// Fuzzy playback serves matching exchanges in any order.
+	fuzzy := &stub.Playback{Fixture: fixture, Normalize: stub.IgnoreNumbers, Repeat: true}
+	_, err = run(fuzzy, "adb", "-s", "serial1", "forward", "tcp:4321", "localabstract:gapii")
+	assert.For(ctx, "forward").ThatError(err).Succeeded()
+	_, err = run(fuzzy, "adb", "devices")
+	assert.For(ctx, "devices").ThatError(err).Succeeded()
+	out, err = run(fuzzy, "adb", "devices")
+	assert.For(ctx, "repeat").ThatError(err).Succeeded()
+	assert.For(ctx, "repeat").ThatString(out).Contains("serial1")
+	_, err = run(fuzzy, "adb", "kill-server")
+	assert.For(ctx, "unrecorded").ThatError(err).Failed()
+	assert.For(ctx, "unplayed").That(len(fuzzy.Unplayed())).Equals(2)
+}
@@ -63,7 +63,7 @@ func (p *responseProcess) Wait(ctx context.Context) error {
 			io.WriteString(p.cmd.Stdout, p.response.Stdout)
 		}
 		if p.cmd.Stderr != nil {
-			io.WriteString(p.cmd.Stdout, p.response.Stderr)
+			io.WriteString(p.cmd.Stderr, p.response.Stderr)
 		}
 	})
 	return p.response.WaitErr
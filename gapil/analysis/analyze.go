@@ -31,6 +31,10 @@ func Analyze(api *semantic.API, mappings *semantic.Mappings) *Results {
 			unknowns: map[semantic.Type]Value{},
 			defaults: map[semantic.Type]Value{},
 			reached:  map[ast.Node]struct{}{},
+
+			mapIndices:            map[*semantic.MapIndex]Possibility{},
+			casts:                 map[*semantic.Cast]Value{},
+			uninitializedAccesses: map[*semantic.Member]struct{}{},
 		},
 		locals:     map[*semantic.Local]Value{},
 		parameters: map[*semantic.Parameter]Value{},
@@ -66,6 +70,7 @@ func Analyze(api *semantic.API, mappings *semantic.Mappings) *Results {
 	// conditionals for the next pass.
 	// TODO: Don't use a hard-coded number of passes.
 	for i := 0; i < maxPasses; i++ {
+		s.shared.final = i == maxPasses-1
 		semantic.Visit(api, s.traverse)
 	}
 
@@ -79,6 +84,10 @@ func Analyze(api *semantic.API, mappings *semantic.Mappings) *Results {
 		Globals:      s.globals,
 		Parameters:   s.parameters,
 		Instances:    s.instances,
+
+		MapIndices:            s.shared.mapIndices,
+		Casts:                 s.shared.casts,
+		UninitializedAccesses: s.shared.uninitializedAccesses,
 	}
 }
 
@@ -206,15 +215,18 @@ func (s *scope) traverse(n semantic.Node) {
 		if m, ok := m.(*MapValue); ok {
 			set(m.Put(k, v))
 		}
+		s.addGuard(n.To.Map, n.To.Index, true)
 
 	case *semantic.MapRemove:
 		// TODO: Put an entry that says the map did not contain the key.
+		s.addGuard(n.Map, n.Key, false)
 
 	case *semantic.MapClear:
 		m, set := s.valueOf(n.Map)
 		if m, ok := m.(*MapValue); ok {
 			set(m.Clear())
 		}
+		s.addGuard(n.Map, nil, false)
 
 	case *semantic.Abort:
 		s.abort = n
@@ -432,6 +444,7 @@ func (s *scope) considerTrue(n semantic.Expression) {
 
 	case *semantic.MapContains:
 		// TODO: Put an entry that says the map contained the key.
+		s.addGuard(n.Map, n.Key, true)
 
 	case *semantic.UnaryOp:
 		switch n.Operator {
@@ -487,6 +500,7 @@ func (s *scope) considerFalse(n semantic.Expression) {
 
 	case *semantic.MapContains:
 		// TODO: Put an entry that says the map did not contain the key.
+		s.addGuard(n.Map, n.Key, false)
 
 	case *semantic.UnaryOp:
 		switch n.Operator {
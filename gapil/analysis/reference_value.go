@@ -40,6 +40,9 @@ type ReferenceValue struct {
 	Unknown Value
 	// Assignments is the set of all assignments made to this value.
 	Assignments map[*semantic.Create]struct{}
+	// MaybeNil is true if the value may be a reference that was never
+	// assigned.
+	MaybeNil bool
 }
 
 func (v *ReferenceValue) String() string {
@@ -126,6 +129,7 @@ func (v *ReferenceValue) Union(o Value) Value {
 	for s := range b.Assignments {
 		out.Assignments[s] = struct{}{}
 	}
+	out.MaybeNil = a.MaybeNil || b.MaybeNil
 	return out
 }
 
@@ -140,6 +144,7 @@ func (v *ReferenceValue) Intersect(o Value) Value {
 			delete(out.Assignments, s)
 		}
 	}
+	out.MaybeNil = a.MaybeNil && b.MaybeNil
 	return out
 }
 
@@ -149,6 +154,8 @@ func (v *ReferenceValue) Intersect(o Value) Value {
 func (v *ReferenceValue) Difference(o Value) Value {
 	a, b := v, o.(*ReferenceValue)
 	out := &ReferenceValue{Ty: v.Ty, Unknown: v.Unknown, Assignments: map[*semantic.Create]struct{}{}}
+	// Only a value that is always nil removes nil from v.
+	out.MaybeNil = a.MaybeNil && !(b.MaybeNil && len(b.Assignments) == 0)
 	for s := range a.Assignments {
 		if _, ok := b.Assignments[s]; !ok {
 			out.Assignments[s] = struct{}{}
@@ -159,7 +166,7 @@ func (v *ReferenceValue) Difference(o Value) Value {
 
 // Clone returns a copy of v with a unique pointer.
 func (v *ReferenceValue) Clone() Value {
-	out := &ReferenceValue{Ty: v.Ty, Unknown: v.Unknown, Assignments: map[*semantic.Create]struct{}{}}
+	out := &ReferenceValue{Ty: v.Ty, Unknown: v.Unknown, Assignments: map[*semantic.Create]struct{}{}, MaybeNil: v.MaybeNil}
 	for s := range v.Assignments {
 		out.Assignments[s] = struct{}{}
 	}
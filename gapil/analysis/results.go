@@ -33,6 +33,16 @@ type Results struct {
 	// Instances is the map of semantic create statements to the possible values
 	// for those instances.
 	Instances map[*semantic.Create]Value
+	// MapIndices is the map of semantic map index expressions to the
+	// possibility of the map containing the key when the expression is
+	// evaluated.
+	MapIndices map[*semantic.MapIndex]Possibility
+	// Casts is the map of semantic integer casts to the possible values of the
+	// expression being cast.
+	Casts map[*semantic.Cast]Value
+	// UninitializedAccesses is the set of field accesses that can be made
+	// through a reference that has not been assigned.
+	UninitializedAccesses map[*semantic.Member]struct{}
 }
 
 // Unreachable represents an unreachable block or statement.
@@ -30,6 +30,7 @@ type scope struct {
 	instances  map[*semantic.Create]Value
 	abort      *semantic.Abort
 	returnVal  Value
+	guards     []mapGuard
 }
 
 // shared is the common data shared between all scopes.
@@ -39,6 +40,56 @@ type shared struct {
 	unknowns map[semantic.Type]Value
 	defaults map[semantic.Type]Value
 	reached  map[ast.Node]struct{}
+
+	// final is true when performing the last pass over the API.
+	// The fields below are only populated on the final pass.
+	final                 bool
+	mapIndices            map[*semantic.MapIndex]Possibility
+	casts                 map[*semantic.Cast]Value
+	uninitializedAccesses map[*semantic.Member]struct{}
+}
+
+// mapGuard records that a map is known to contain, or not contain, a key.
+// Guards do not affect the analysed values, they are only used to report map
+// indices.
+type mapGuard struct {
+	m, k     semantic.Expression
+	contains bool
+}
+
+// addGuard records in s that the map m is known to contain (or not contain)
+// the key k.
+func (s *scope) addGuard(m, k semantic.Expression, contains bool) {
+	s.guards = append(s.guards, mapGuard{m, k, contains})
+}
+
+// guard returns whether the map m is known to contain the key k in s, and if
+// it is known.
+func (s *scope) guard(m, k semantic.Expression) (contains, known bool) {
+	for ; s != nil; s = s.parent {
+		for i := len(s.guards) - 1; i >= 0; i-- {
+			if g := s.guards[i]; sameExpr(g.m, m) && (g.k == nil || sameExpr(g.k, k)) {
+				return g.contains, true
+			}
+		}
+	}
+	return false, false
+}
+
+// sameExpr returns true if a and b are expressions of the same variable.
+func sameExpr(a, b semantic.Expression) bool {
+	if a == b {
+		return true
+	}
+	switch a := a.(type) {
+	case *semantic.Member:
+		b, ok := b.(*semantic.Member)
+		return ok && a.Field == b.Field && sameExpr(a.Object, b.Object)
+	case *semantic.MapIndex:
+		b, ok := b.(*semantic.MapIndex)
+		return ok && sameExpr(a.Map, b.Map) && sameExpr(a.Index, b.Index)
+	}
+	return false
 }
 
 // push returns a new child scope with a copy of the s's values.
@@ -333,6 +333,7 @@ func (s *scope) defaultOf(ty semantic.Type) (out Value) {
 			Ty:          ty.To,
 			Unknown:     s.unknownOf(ty.To),
 			Assignments: map[*semantic.Create]struct{}{},
+			MaybeNil:    true,
 		}
 
 	case *semantic.Builtin:
@@ -423,6 +424,9 @@ func (s *scope) valueOf(n semantic.Expression) (out Value, setter func(Value)) {
 		v, _ := s.valueOf(n.Object)
 		to := semantic.Underlying(n.Type)
 		if from, ok := v.(*UintValue); ok && semantic.IsInteger(to) {
+			if s.shared.final {
+				s.shared.casts[n] = UnionOf(s.shared.casts[n], from)
+			}
 			return &UintValue{Ty: to.(*semantic.Builtin), Ranges: from.Ranges}, nil
 		}
 
@@ -456,6 +460,9 @@ func (s *scope) valueOf(n semantic.Expression) (out Value, setter func(Value)) {
 			panic(fmt.Errorf("Attempted to index field on type %T", obj))
 		}
 		name := n.Field.Name()
+		if r, ok := obj.(*ReferenceValue); ok && s.shared.final && r.MaybeNil {
+			s.shared.uninitializedAccesses[n] = struct{}{}
+		}
 		v := m.field(s, name)
 		if set != nil {
 			return v, func(v Value) { set(m.setField(s, name, v)) }
@@ -483,6 +490,19 @@ func (s *scope) valueOf(n semantic.Expression) (out Value, setter func(Value)) {
 	case *semantic.MapIndex:
 		m, set := s.valueOf(n.Map)
 		k, _ := s.valueOf(n.Index)
+		if s.shared.final {
+			contains := m.(*MapValue).ContainsKey(k)
+			if guarded, known := s.guard(n.Map, n.Index); known {
+				contains = False
+				if guarded {
+					contains = True
+				}
+			}
+			if p, ok := s.shared.mapIndices[n]; ok {
+				contains = p.Union(contains)
+			}
+			s.shared.mapIndices[n] = contains
+		}
 		return m.(*MapValue).Get(s, k), func(v Value) {
 			set(m.(*MapValue).Put(k, v))
 		}
@@ -0,0 +1,392 @@
+// Copyright (C) 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package apidiff compares two resolved versions of an API, reporting the
+// differences in the commands, types and annotations they declare.
+package apidiff
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/google/gapid/gapil/semantic"
+	"github.com/google/gapid/gapil/semantic/printer"
+)
+
+// Kind is the kind of a Change.
+type Kind string
+
+const (
+	// Added means the entity is only present in the new API.
+	Added = Kind("added")
+	// Removed means the entity is only present in the old API.
+	Removed = Kind("removed")
+	// Changed means the entity is present in both APIs, but differs.
+	Changed = Kind("changed")
+)
+
+// The entities that can be reported in a Change.
+const (
+	Command    = "command"
+	Parameter  = "parameter"
+	Enum       = "enum"
+	EnumEntry  = "enum entry"
+	Class      = "class"
+	Field      = "field"
+	Pseudonym  = "type"
+	Annotation = "annotation"
+)
+
+// Change describes a single difference between two APIs.
+type Change struct {
+	Kind   Kind   `json:"kind"`
+	Entity string `json:"entity"`
+	// Path is the fully qualified name of the entity, for example
+	// "glDrawArrays.count" for a parameter or "glDrawArrays@threadsafe" for an
+	// annotation.
+	Path string `json:"path"`
+	Old  string `json:"old,omitempty"`
+	New  string `json:"new,omitempty"`
+}
+
+func (c Change) String() string {
+	switch c.Kind {
+	case Added:
+		return fmt.Sprintf("+ %v %v: %v", c.Entity, c.Path, c.New)
+	case Removed:
+		return fmt.Sprintf("- %v %v: %v", c.Entity, c.Path, c.Old)
+	default:
+		return fmt.Sprintf("~ %v %v: %v -> %v", c.Entity, c.Path, c.Old, c.New)
+	}
+}
+
+// Changes is a list of changes between two APIs.
+type Changes []Change
+
+func (l Changes) String() string {
+	lines := make([]string, len(l))
+	for i, c := range l {
+		lines[i] = c.String()
+	}
+	return strings.Join(lines, "\n")
+}
+
+func (l *Changes) add(kind Kind, entity, path, old, new string) {
+	*l = append(*l, Change{Kind: kind, Entity: entity, Path: path, Old: old, New: new})
+}
+
+// Compare returns the list of semantic differences from the old API to the
+// new API.
+// The changes are grouped by entity, with each group in the order the
+// entities are declared in the new API, followed by removed entities in the
+// order they were declared in the old API.
+func Compare(old, new *semantic.API) Changes {
+	changes := Changes{}
+	changes.pseudonyms(old.Pseudonyms, new.Pseudonyms)
+	changes.enums(old.Enums, new.Enums)
+	changes.classes(old.Classes, new.Classes)
+	changes.commands(old.Functions, new.Functions)
+	return changes
+}
+
+func (l *Changes) pseudonyms(old, new []*semantic.Pseudonym) {
+	oldByName := map[string]*semantic.Pseudonym{}
+	for _, p := range old {
+		oldByName[p.Name()] = p
+	}
+	newByName := map[string]*semantic.Pseudonym{}
+	for _, n := range new {
+		newByName[n.Name()] = n
+		o, ok := oldByName[n.Name()]
+		if !ok {
+			l.add(Added, Pseudonym, n.Name(), "", typeName(n.To))
+			continue
+		}
+		if a, b := typeName(o.To), typeName(n.To); a != b {
+			l.add(Changed, Pseudonym, n.Name(), a, b)
+		}
+		l.annotations(n.Name(), o.Annotations, n.Annotations)
+	}
+	for _, o := range old {
+		if _, ok := newByName[o.Name()]; !ok {
+			l.add(Removed, Pseudonym, o.Name(), typeName(o.To), "")
+		}
+	}
+}
+
+func (l *Changes) enums(old, new []*semantic.Enum) {
+	oldByName := map[string]*semantic.Enum{}
+	for _, e := range old {
+		oldByName[e.Name()] = e
+	}
+	newByName := map[string]*semantic.Enum{}
+	for _, n := range new {
+		newByName[n.Name()] = n
+		o, ok := oldByName[n.Name()]
+		if !ok {
+			l.add(Added, Enum, n.Name(), "", enumSummary(n))
+			continue
+		}
+		if a, b := enumKind(o), enumKind(n); a != b {
+			l.add(Changed, Enum, n.Name(), a, b)
+		}
+		l.annotations(n.Name(), o.Annotations, n.Annotations)
+		l.enumEntries(n.Name(), o.Entries, n.Entries)
+	}
+	for _, o := range old {
+		if _, ok := newByName[o.Name()]; !ok {
+			l.add(Removed, Enum, o.Name(), enumSummary(o), "")
+		}
+	}
+}
+
+func (l *Changes) enumEntries(owner string, old, new []*semantic.EnumEntry) {
+	oldByName := map[string]*semantic.EnumEntry{}
+	for _, e := range old {
+		oldByName[e.Name()] = e
+	}
+	newByName := map[string]*semantic.EnumEntry{}
+	for _, n := range new {
+		newByName[n.Name()] = n
+		path := owner + "." + n.Name()
+		o, ok := oldByName[n.Name()]
+		if !ok {
+			l.add(Added, EnumEntry, path, "", expression(n.Value))
+			continue
+		}
+		if a, b := expression(o.Value), expression(n.Value); a != b {
+			l.add(Changed, EnumEntry, path, a, b)
+		}
+	}
+	for _, o := range old {
+		if _, ok := newByName[o.Name()]; !ok {
+			l.add(Removed, EnumEntry, owner+"."+o.Name(), expression(o.Value), "")
+		}
+	}
+}
+
+func (l *Changes) classes(old, new []*semantic.Class) {
+	oldByName := map[string]*semantic.Class{}
+	for _, c := range old {
+		oldByName[c.Name()] = c
+	}
+	newByName := map[string]*semantic.Class{}
+	for _, n := range new {
+		newByName[n.Name()] = n
+		o, ok := oldByName[n.Name()]
+		if !ok {
+			l.add(Added, Class, n.Name(), "", fieldList(n.Fields))
+			continue
+		}
+		l.annotations(n.Name(), o.Annotations, n.Annotations)
+		l.fields(n.Name(), o.Fields, n.Fields)
+	}
+	for _, o := range old {
+		if _, ok := newByName[o.Name()]; !ok {
+			l.add(Removed, Class, o.Name(), fieldList(o.Fields), "")
+		}
+	}
+}
+
+func (l *Changes) fields(owner string, old, new []*semantic.Field) {
+	oldByName := map[string]*semantic.Field{}
+	for _, f := range old {
+		oldByName[f.Name()] = f
+	}
+	newByName := map[string]*semantic.Field{}
+	for _, n := range new {
+		newByName[n.Name()] = n
+		path := owner + "." + n.Name()
+		o, ok := oldByName[n.Name()]
+		if !ok {
+			l.add(Added, Field, path, "", typeName(n.Type))
+			continue
+		}
+		if a, b := typeName(o.Type), typeName(n.Type); a != b {
+			l.add(Changed, Field, path, a, b)
+		}
+		l.annotations(path, o.Annotations, n.Annotations)
+	}
+	for _, o := range old {
+		if _, ok := newByName[o.Name()]; !ok {
+			l.add(Removed, Field, owner+"."+o.Name(), typeName(o.Type), "")
+		}
+	}
+	if a, b := fieldOrder(old, newByName), fieldOrder(new, oldByName); a != b {
+		l.add(Changed, Field, owner+" (order)", a, b)
+	}
+}
+
+func (l *Changes) commands(old, new []*semantic.Function) {
+	oldByName := map[string]*semantic.Function{}
+	for _, f := range old {
+		oldByName[f.Name()] = f
+	}
+	newByName := map[string]*semantic.Function{}
+	for _, n := range new {
+		newByName[n.Name()] = n
+		o, ok := oldByName[n.Name()]
+		if !ok {
+			l.add(Added, Command, n.Name(), "", signature(n))
+			continue
+		}
+		if a, b := typeName(o.Return.Type), typeName(n.Return.Type); a != b {
+			l.add(Changed, Parameter, n.Name()+".result", a, b)
+		}
+		l.annotations(n.Name(), o.Annotations, n.Annotations)
+		l.parameters(n.Name(), o.CallParameters(), n.CallParameters())
+	}
+	for _, o := range old {
+		if _, ok := newByName[o.Name()]; !ok {
+			l.add(Removed, Command, o.Name(), signature(o), "")
+		}
+	}
+}
+
+func (l *Changes) parameters(owner string, old, new []*semantic.Parameter) {
+	oldByName := map[string]*semantic.Parameter{}
+	for _, p := range old {
+		oldByName[p.Name()] = p
+	}
+	newByName := map[string]*semantic.Parameter{}
+	for _, n := range new {
+		newByName[n.Name()] = n
+		path := owner + "." + n.Name()
+		o, ok := oldByName[n.Name()]
+		if !ok {
+			l.add(Added, Parameter, path, "", typeName(n.Type))
+			continue
+		}
+		if a, b := typeName(o.Type), typeName(n.Type); a != b {
+			l.add(Changed, Parameter, path, a, b)
+		}
+		l.annotations(path, o.Annotations, n.Annotations)
+	}
+	for _, o := range old {
+		if _, ok := newByName[o.Name()]; !ok {
+			l.add(Removed, Parameter, owner+"."+o.Name(), typeName(o.Type), "")
+		}
+	}
+	// Reordering parameters changes the encoding of the command, even if no
+	// parameter was added or removed.
+	oldOrder, newOrder := []string{}, []string{}
+	for _, p := range old {
+		if _, ok := newByName[p.Name()]; ok {
+			oldOrder = append(oldOrder, p.Name())
+		}
+	}
+	for _, p := range new {
+		if _, ok := oldByName[p.Name()]; ok {
+			newOrder = append(newOrder, p.Name())
+		}
+	}
+	if a, b := strings.Join(oldOrder, ", "), strings.Join(newOrder, ", "); a != b {
+		l.add(Changed, Parameter, owner+" (order)", a, b)
+	}
+}
+
+func (l *Changes) annotations(owner string, old, new semantic.Annotations) {
+	oldByName := map[string]string{}
+	for _, a := range old {
+		oldByName[a.Name()] = annotation(a)
+	}
+	newByName := map[string]string{}
+	for _, a := range new {
+		newByName[a.Name()] = annotation(a)
+	}
+	for _, name := range sortedKeys(newByName) {
+		path := owner + "@" + name
+		o, ok := oldByName[name]
+		switch {
+		case !ok:
+			l.add(Added, Annotation, path, "", newByName[name])
+		case o != newByName[name]:
+			l.add(Changed, Annotation, path, o, newByName[name])
+		}
+	}
+	for _, name := range sortedKeys(oldByName) {
+		if _, ok := newByName[name]; !ok {
+			l.add(Removed, Annotation, owner+"@"+name, oldByName[name], "")
+		}
+	}
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func typeName(t semantic.Type) string {
+	return printer.New().WriteType(t).String()
+}
+
+func expression(e semantic.Expression) string {
+	return printer.New().WriteExpression(e).String()
+}
+
+func annotation(a *semantic.Annotation) string {
+	if len(a.Arguments) == 0 {
+		return "@" + a.Name()
+	}
+	args := make([]string, len(a.Arguments))
+	for i, arg := range a.Arguments {
+		args[i] = expression(arg)
+	}
+	return fmt.Sprintf("@%v(%v)", a.Name(), strings.Join(args, ", "))
+}
+
+func signature(f *semantic.Function) string {
+	params := f.CallParameters()
+	parts := make([]string, len(params))
+	for i, p := range params {
+		parts[i] = typeName(p.Type) + " " + p.Name()
+	}
+	return fmt.Sprintf("%v %v(%v)", typeName(f.Return.Type), f.Name(), strings.Join(parts, ", "))
+}
+
+func enumKind(e *semantic.Enum) string {
+	if e.IsBitfield {
+		return "bitfield : " + typeName(e.NumberType)
+	}
+	return "enum : " + typeName(e.NumberType)
+}
+
+func enumSummary(e *semantic.Enum) string {
+	return fmt.Sprintf("%v (%d entries)", enumKind(e), len(e.Entries))
+}
+
+func fieldList(fields []*semantic.Field) string {
+	parts := make([]string, len(fields))
+	for i, f := range fields {
+		parts[i] = typeName(f.Type) + " " + f.Name()
+	}
+	return "{" + strings.Join(parts, ", ") + "}"
+}
+
+// fieldOrder returns the names of the fields in declaration order, filtered to
+// those present in other.
+func fieldOrder(fields []*semantic.Field, other map[string]*semantic.Field) string {
+	names := []string{}
+	for _, f := range fields {
+		if _, ok := other[f.Name()]; ok {
+			names = append(names, f.Name())
+		}
+	}
+	return strings.Join(names, ", ")
+}
@@ -0,0 +1,118 @@
+// Copyright (C) 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package apidiff_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/google/gapid/core/assert"
+	"github.com/google/gapid/core/log"
+	"github.com/google/gapid/gapil"
+	"github.com/google/gapid/gapil/apidiff"
+	"github.com/google/gapid/gapil/ast"
+	"github.com/google/gapid/gapil/parser"
+	"github.com/google/gapid/gapil/resolver"
+	"github.com/google/gapid/gapil/semantic"
+)
+
+const maxErrors = 10
+
+func compile(t *testing.T, source string) *semantic.API {
+	m := &semantic.Mappings{}
+	parsed, errs := parser.Parse("apidiff_test.api", source, &m.AST)
+	if err := gapil.CheckErrors(source, errs, maxErrors); err != nil {
+		t.Fatal(err)
+	}
+	compiled, errs := resolver.Resolve([]*ast.API{parsed}, m, resolver.Options{})
+	if err := gapil.CheckErrors(source, errs, maxErrors); err != nil {
+		t.Fatal(err)
+	}
+	return compiled
+}
+
+func TestCompare(t *testing.T) {
+	ctx := log.Testing(t)
+
+	for _, test := range []struct {
+		name     string
+		old, new string
+		expected string
+	}{
+		{"identical",
+			`cmd void f(u32 a) {}`,
+			`cmd void f(u32 a) {}`,
+			``},
+		{"commands",
+			`cmd void f() {}
+       cmd void g() {}`,
+			`cmd void f() {}
+       cmd u32 h(bool b) { return 0 }`,
+			`
++ command h: u32 h(bool b)
+- command g: void g()`},
+		{"parameters",
+			`cmd void f(u32 a, u32 b, u32 c) {}`,
+			`cmd void f(u64 a, u32 c, u32 b, u8 d) {}`,
+			`
+~ parameter f.a: u32 -> u64
++ parameter f.d: u8
+~ parameter f (order): a, b, c -> a, c, b`},
+		{"results",
+			`cmd u32 f() { return 0 }`,
+			`cmd s32 f() { return 0 }`,
+			`~ parameter f.result: u32 -> s32`},
+		{"annotations",
+			`@a @b(1) cmd void f(@c u32 x) {}`,
+			`@b(2) @d cmd void f(u32 x) {}`,
+			`
+~ annotation f@b: @b(1) -> @b(2)
++ annotation f@d: @d
+- annotation f@a: @a
+- annotation f.x@c: @c`},
+		{"enums",
+			`enum E : u32 { A = 1, B = 2 }`,
+			`bitfield E : u32 { A = 1, B = 4, C = 8 }
+       enum F { X = 1 }`,
+			`
+~ enum E: enum : u32 -> bitfield : u32
+~ enum entry E.B: 2 -> 4
++ enum entry E.C: 8
++ enum F: enum : u32 (1 entries)`},
+		{"classes",
+			`class C { u32 a  u32 b }
+       class D { u8 x }`,
+			`class C { u32 b  u64 a  bool c }`,
+			`
+~ field C.a: u32 -> u64
++ field C.c: bool
+~ field C (order): a, b -> b, a
+- class D: {u8 x}`},
+		{"pseudonyms",
+			`type u32 T
+       type u8 U`,
+			`type u64 T
+       type u8 V`,
+			`
+~ type T: u32 -> u64
++ type V: u8
+- type U: u8`},
+	} {
+		ctx := log.Enter(ctx, test.name)
+		old, new := compile(t, test.old), compile(t, test.new)
+		got := apidiff.Compare(old, new).String()
+		assert.For(ctx, "changes").ThatString(got).Equals(strings.TrimSpace(test.expected))
+	}
+}
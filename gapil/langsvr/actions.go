@@ -0,0 +1,339 @@
+// Copyright (C) 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"unicode"
+
+	ls "github.com/google/gapid/core/langsvr"
+	"github.com/google/gapid/gapil/ast"
+	"github.com/google/gapid/gapil/semantic"
+	"github.com/google/gapid/gapil/validate"
+)
+
+const (
+	// applyEditCommand is the client command that applies the WorkspaceEdit
+	// passed as its only argument. It is registered by vscode/extension.js.
+	applyEditCommand = "gfxapi.applyEdit"
+
+	// showReferencesCommand is the client command that shows the list of
+	// locations passed as its third argument, at the document URI and position
+	// passed as the first and second arguments. It is registered by
+	// vscode/extension.js.
+	showReferencesCommand = "gfxapi.showReferences"
+)
+
+// reMissingIdentifier matches the resolver errors for unknown identifiers.
+var reMissingIdentifier = regexp.MustCompile(`^(?:Unknown identifier (\S+)|Type (\S+) not found)$`)
+
+// CodeActions compute commands for a given document and range.
+// The request is triggered when the user moves the cursor into an problem
+// marker in the editor or presses the lightbulb associated with a marker.
+func (s *server) CodeActions(ctx context.Context, doc *ls.Document, rng ls.Range, diags []ls.Diagnostic) ([]ls.Command, error) {
+	da, err := s.docAnalysis(ctx, doc)
+	if da == nil || err != nil {
+		return []ls.Command{}, err
+	}
+
+	cmds := []ls.Command{}
+	for _, e := range da.errs {
+		if !overlaps(fragRange(doc, e.At), rng) {
+			continue
+		}
+		if m := reMissingIdentifier.FindStringSubmatch(e.Message); m != nil {
+			name := m[1] + m[2]
+			cmds = append(cmds, s.addImportActions(da, name)...)
+		}
+	}
+	for _, issue := range da.issues {
+		if !overlaps(fragRange(doc, issue.At), rng) {
+			continue
+		}
+		if unused, ok := issue.Problem.(validate.ErrUnusedDeclaration); ok {
+			if cmd, ok := s.removeDeclarationAction(da, unused); ok {
+				cmds = append(cmds, cmd)
+			}
+		}
+	}
+	if cmd, ok := s.switchDefaultAction(da, rng); ok {
+		cmds = append(cmds, cmd)
+	}
+	return cmds, nil
+}
+
+// addImportActions returns the commands that import an API file that declares
+// name into the document of da.
+func (s *server) addImportActions(da *docAnalysis, name string) []ls.Command {
+	paths := []string{}
+	for path, other := range da.full.docs {
+		if other != da && other.ast != nil && declares(other.ast, name) {
+			paths = append(paths, path)
+		}
+	}
+	sort.Strings(paths)
+
+	cmds := []ls.Command{}
+	for _, path := range paths {
+		rel, err := filepath.Rel(filepath.Dir(da.doc.Path()), path)
+		if err != nil {
+			continue
+		}
+		rel = filepath.ToSlash(rel)
+		pos, text := s.importInsertion(da)
+		edit := ls.WorkspaceEdit{}
+		edit.Add(ls.Location{URI: da.doc.URI(), Range: ls.Range{Start: pos, End: pos}},
+			fmt.Sprintf(text, rel))
+		cmds = append(cmds, ls.Command{
+			Title:        fmt.Sprintf(`Import "%s"`, rel),
+			Command:      applyEditCommand,
+			ArgumentList: []interface{}{edit},
+		})
+	}
+	return cmds
+}
+
+// importInsertion returns the position and format string for a new import in
+// the document of da. New imports are placed after the last existing import,
+// or before the first declaration if there are no imports.
+func (s *server) importInsertion(da *docAnalysis) (ls.Position, string) {
+	body := da.doc.Body()
+	if n := len(da.ast.Imports); n > 0 {
+		end := da.full.mappings.AST.CST(da.ast.Imports[n-1]).Tok().End
+		return ls.Position{Line: body.Position(end).Line + 1, Column: 1}, "import \"%s\"\n"
+	}
+	first := -1
+	ast.Visit(da.ast, func(n ast.Node) {
+		if c := da.full.mappings.AST.CST(n); c != nil {
+			if start := c.Tok().Start; first < 0 || start < first {
+				first = start
+			}
+		}
+	})
+	if first < 0 {
+		first = 0
+	}
+	return ls.Position{Line: body.Position(first).Line, Column: 1}, "import \"%s\"\n\n"
+}
+
+// declares returns true if the API declares a top-level name.
+func declares(api *ast.API, name string) bool {
+	for _, n := range api.Classes {
+		if n.Name.Value == name {
+			return true
+		}
+	}
+	for _, n := range api.Enums {
+		if n.Name.Value == name {
+			return true
+		}
+	}
+	for _, n := range api.Pseudonyms {
+		if n.Name.Value == name {
+			return true
+		}
+	}
+	for _, n := range api.Definitions {
+		if n.Name.Value == name {
+			return true
+		}
+	}
+	for _, n := range api.Fields {
+		if n.Name.Value == name {
+			return true
+		}
+	}
+	for _, l := range [][]*ast.Function{api.Externs, api.Commands, api.Subroutines} {
+		for _, n := range l {
+			if n.Generic.Name.Value == name {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// removeDeclarationAction returns the command that deletes the unused
+// declaration reported by issue.
+func (s *server) removeDeclarationAction(da *docAnalysis, issue validate.ErrUnusedDeclaration) (ls.Command, bool) {
+	var decl ast.Node
+	var name string
+	switch n := issue.Decl.(type) {
+	case *semantic.Class:
+		decl, name = n.AST, n.Name()
+	case *semantic.Enum:
+		decl, name = n.AST, n.Name()
+	case *semantic.Pseudonym:
+		decl, name = n.AST, n.Name()
+	case *semantic.Field:
+		decl, name = n.AST, n.Name()
+	}
+	if decl == nil || !da.contains(decl) {
+		return ls.Command{}, false
+	}
+	doc := da.doc
+	edit := ls.WorkspaceEdit{}
+	edit.Add(ls.Location{URI: doc.URI(), Range: wholeLines(doc.Body(), da.full.nodeRange(doc, decl))}, "")
+	return ls.Command{
+		Title:        fmt.Sprintf("Remove unused %s", name),
+		Command:      applyEditCommand,
+		ArgumentList: []interface{}{edit},
+	}, true
+}
+
+// switchDefaultAction returns the command that adds an empty default case to
+// the switch statement enclosing rng, if it doesn't already have one.
+func (s *server) switchDefaultAction(da *docAnalysis, rng ls.Range) (ls.Command, bool) {
+	doc := da.doc
+	body := doc.Body()
+	for _, n := range da.walkUp(body.Offset(rng.Start)) {
+		sw, ok := n.ast.(*ast.Switch)
+		if !ok {
+			continue
+		}
+		if _, ok := n.sem.(*semantic.Switch); !ok || sw.Default != nil {
+			// Select expressions need a value for the default, so leave
+			// those alone.
+			return ls.Command{}, false
+		}
+		tok := da.full.mappings.AST.CST(sw).Tok()
+		indent := lineIndent(body, tok.Start) + "  "
+		if len(sw.Cases) > 0 {
+			indent = lineIndent(body, da.full.mappings.AST.CST(sw.Cases[0]).Tok().Start)
+		}
+		// Insert on a new line before the closing brace.
+		pos := body.Position(tok.End - 1)
+		pos.Column = 1
+		text := indent + "default: {}\n"
+		if lineIndent(body, tok.End-1) != string(body.Runes()[body.Offset(pos):tok.End-1]) {
+			// The closing brace shares the line with something else.
+			pos = body.Position(tok.End - 1)
+			text = "\n" + indent + "default: {}\n" + lineIndent(body, tok.Start)
+		}
+		edit := ls.WorkspaceEdit{}
+		edit.Add(ls.Location{URI: doc.URI(), Range: ls.Range{Start: pos, End: pos}}, text)
+		return ls.Command{
+			Title:        "Add default case",
+			Command:      applyEditCommand,
+			ArgumentList: []interface{}{edit},
+		}, true
+	}
+	return ls.Command{}, false
+}
+
+// CodeLenses returns a list of CodeLens for the specified document.
+func (s *server) CodeLenses(ctx context.Context, doc *ls.Document) ([]ls.CodeLens, error) {
+	da, err := s.docAnalysis(ctx, doc)
+	if da == nil || err != nil {
+		return nil, err
+	}
+	lenses := []ls.CodeLens{}
+	add := func(decl ast.Node, name *ast.Identifier) {
+		sems := da.full.mappings.ASTToSemantic[decl]
+		if len(sems) == 0 {
+			return
+		}
+		sem := sems[0]
+		rng := da.full.nodeRange(doc, name)
+		lenses = append(lenses, ls.CodeLens{
+			Range: rng,
+			Resolve: func(context.Context) ls.Command {
+				locations := s.references(da.full, sem, name)
+				title := fmt.Sprintf("%d references", len(locations))
+				if len(locations) == 1 {
+					title = "1 reference"
+				}
+				cmd := ls.Command{Title: title}
+				if len(locations) > 0 {
+					cmd.Command = showReferencesCommand
+					cmd.ArgumentList = []interface{}{doc.URI(), rng.Start, locations}
+				}
+				return cmd
+			},
+		})
+	}
+	for _, n := range da.ast.Commands {
+		add(n, n.Generic.Name)
+	}
+	for _, n := range da.ast.Subroutines {
+		add(n, n.Generic.Name)
+	}
+	for _, n := range da.ast.Classes {
+		add(n, n.Name)
+	}
+	return lenses, nil
+}
+
+// references returns the locations of all the identifiers that refer to sem,
+// excluding the declaring identifier decl.
+func (s *server) references(fa *fullAnalysis, sem semantic.Node, decl *ast.Identifier) []ls.Location {
+	locations := []ls.Location{}
+	for _, n := range fa.mappings.SemanticToAST[sem] {
+		if id, isIdent := n.(*ast.Identifier); isIdent && id != decl {
+			locations = append(locations, s.nodeLocation(fa, n))
+		}
+	}
+	return locations
+}
+
+// overlaps returns true if the ranges a and b share any position.
+func overlaps(a, b ls.Range) bool {
+	return !before(a.End, b.Start) && !before(b.End, a.Start)
+}
+
+// before returns true if a comes before b.
+func before(a, b ls.Position) bool {
+	return a.Line < b.Line || (a.Line == b.Line && a.Column < b.Column)
+}
+
+// lineIndent returns the leading whitespace of the line holding offset.
+func lineIndent(body ls.Body, offset int) string {
+	runes := body.Runes()
+	start := body.Offset(ls.Position{Line: body.Position(offset).Line, Column: 1})
+	end := start
+	for end < len(runes) && runes[end] != '\n' && unicode.IsSpace(runes[end]) {
+		end++
+	}
+	return string(runes[start:end])
+}
+
+// wholeLines expands rng to include the full lines it spans, including the
+// trailing new line, if there is only whitespace either side of rng on those
+// lines.
+func wholeLines(body ls.Body, rng ls.Range) ls.Range {
+	runes := body.Runes()
+	start, end := body.Offset(rng.Start), body.Offset(rng.End)
+	s, e := start, end
+	for s > 0 && runes[s-1] != '\n' {
+		if !unicode.IsSpace(runes[s-1]) {
+			return rng
+		}
+		s--
+	}
+	for e < len(runes) && runes[e] != '\n' {
+		if !unicode.IsSpace(runes[e]) {
+			return rng
+		}
+		e++
+	}
+	if e < len(runes) {
+		e++ // Include the new line.
+	}
+	return body.Range(s, e)
+}
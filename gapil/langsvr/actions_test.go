@@ -0,0 +1,114 @@
+// Copyright (C) 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"testing"
+
+	"github.com/google/gapid/core/assert"
+	ls "github.com/google/gapid/core/langsvr"
+	"github.com/google/gapid/core/log"
+	"github.com/google/gapid/gapil/ast"
+	"github.com/google/gapid/gapil/parser"
+)
+
+func TestMissingIdentifier(t *testing.T) {
+	ctx := log.Testing(t)
+	for _, test := range []struct {
+		msg  string
+		name string
+	}{
+		{"Unknown identifier foo", "foo"},
+		{"Type Bar not found", "Bar"},
+		{"Unknown identifier foo bar", ""},
+		{"Something else", ""},
+	} {
+		name := ""
+		if m := reMissingIdentifier.FindStringSubmatch(test.msg); m != nil {
+			name = m[1] + m[2]
+		}
+		assert.For(ctx, "%v", test.msg).That(name).Equals(test.name)
+	}
+}
+
+func TestDeclares(t *testing.T) {
+	ctx := log.Testing(t)
+	api, errs := parser.Parse("test.api", `
+class C {}
+enum E { V = 1 }
+type u32 P
+define D 1
+int F
+extern void X()
+cmd void Y() {}
+sub void Z() {}
+`, &ast.Mappings{})
+	assert.For(ctx, "errs").That(len(errs)).Equals(0)
+	for _, name := range []string{"C", "E", "P", "D", "F", "X", "Y", "Z"} {
+		assert.For(ctx, "%v", name).That(declares(api, name)).Equals(true)
+	}
+	for _, name := range []string{"V", "u32", "W"} {
+		assert.For(ctx, "%v", name).That(declares(api, name)).Equals(false)
+	}
+}
+
+func TestOverlaps(t *testing.T) {
+	ctx := log.Testing(t)
+	rng := func(l1, c1, l2, c2 int) ls.Range {
+		return ls.Range{Start: ls.Position{Line: l1, Column: c1}, End: ls.Position{Line: l2, Column: c2}}
+	}
+	for _, test := range []struct {
+		a, b     ls.Range
+		expected bool
+	}{
+		{rng(1, 1, 1, 5), rng(1, 3, 1, 3), true},
+		{rng(1, 1, 1, 5), rng(1, 5, 2, 1), true},
+		{rng(1, 1, 1, 5), rng(1, 6, 2, 1), false},
+		{rng(2, 1, 3, 1), rng(1, 1, 1, 9), false},
+		{rng(2, 1, 3, 1), rng(1, 1, 4, 1), true},
+	} {
+		assert.For(ctx, "%v %v", test.a, test.b).That(overlaps(test.a, test.b)).Equals(test.expected)
+		assert.For(ctx, "%v %v", test.b, test.a).That(overlaps(test.b, test.a)).Equals(test.expected)
+	}
+}
+
+func TestLineIndent(t *testing.T) {
+	ctx := log.Testing(t)
+	body := ls.NewBody("a\n  b\n\t\tc\n")
+	assert.For(ctx, "a").That(lineIndent(body, 0)).Equals("")
+	assert.For(ctx, "b").That(lineIndent(body, 4)).Equals("  ")
+	assert.For(ctx, "c").That(lineIndent(body, 8)).Equals("\t\t")
+}
+
+func TestWholeLines(t *testing.T) {
+	ctx := log.Testing(t)
+	body := ls.NewBody("" +
+		/*  0 */ "a\n" +
+		/*  2 */ "  class C {}\n" +
+		/* 15 */ "b class D {}\n" +
+		/* 28 */ "class E {}")
+	for _, test := range []struct {
+		name       string
+		start, end int
+		expected   ls.Range
+	}{
+		{"indented", 4, 14, body.Range(2, 15)},
+		{"shared line", 17, 27, body.Range(17, 27)},
+		{"last line", 28, 38, body.Range(28, 38)},
+	} {
+		got := wholeLines(body, body.Range(test.start, test.end))
+		assert.For(ctx, "%v", test.name).That(got).Equals(test.expected)
+	}
+}
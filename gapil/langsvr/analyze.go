@@ -176,7 +176,11 @@ func (a *analyzer) begin(ctx context.Context, s *server) error {
 		}
 	}
 	va := validate.Options{
-		CheckUnused: s.config.CheckUnused,
+		CheckUnused:         s.config.CheckUnused,
+		CheckMapIndices:     s.config.CheckMapIndices,
+		CheckUninitialized:  s.config.CheckUninitialized,
+		CheckNarrowingCasts: s.config.CheckNarrowingCasts,
+		CheckPointerParams:  s.config.CheckPointerParams,
 	}
 
 	// Setup the new done signal and cancellation function.
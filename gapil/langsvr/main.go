@@ -30,7 +30,7 @@ import (
 
 	ls "github.com/google/gapid/core/langsvr"
 	"github.com/google/gapid/core/log"
-	"github.com/google/gapid/core/text/parse"
+	"github.com/google/gapid/core/text/parse/cst"
 	"github.com/google/gapid/gapil/analysis"
 	"github.com/google/gapid/gapil/ast"
 	"github.com/google/gapid/gapil/format"
@@ -87,10 +87,14 @@ var (
 // Config is is the configuration data sent from the client, held in the
 // "gfxapi" group.
 type Config struct {
-	Debug       bool     `json:"debug"`
-	LogToFiles  bool     `json:"logToFiles"`
-	IgnorePaths []string `json:"ignorePaths"`
-	CheckUnused bool     `json:"checkUnused"`
+	Debug               bool     `json:"debug"`
+	LogToFiles          bool     `json:"logToFiles"`
+	IgnorePaths         []string `json:"ignorePaths"`
+	CheckUnused         bool     `json:"checkUnused"`
+	CheckMapIndices     bool     `json:"checkMapIndices"`
+	CheckUninitialized  bool     `json:"checkUninitialized"`
+	CheckNarrowingCasts bool     `json:"checkNarrowingCasts"`
+	CheckPointerParams  bool     `json:"checkPointerParams"`
 }
 
 type server struct {
@@ -222,11 +226,6 @@ func (s *server) OnDocumentSaved(ctx context.Context, doc *ls.Document) error {
 	return nil
 }
 
-// CodeLenses returns a list of CodeLens for the specified document.
-func (s *server) CodeLenses(ctx context.Context, doc *ls.Document) ([]ls.CodeLens, error) {
-	return nil, nil
-}
-
 // Completions returns completion items at a given cursor position.
 // Completion items are presented in the IntelliSense user interface.
 func (s *server) Completions(ctx context.Context, doc *ls.Document, pos ls.Position) (ls.CompletionList, error) {
@@ -310,7 +309,7 @@ func (s *server) Signatures(ctx context.Context, doc *ls.Document, pos ls.Positi
 			params.Add(p.Name(), da.full.documentation(p.AST))
 		}
 		for i, a := range call.AST.Arguments {
-			if tok := da.full.mappings.AST.CST(a).Token(); offset >= tok.Start {
+			if tok := da.full.mappings.AST.CST(a).Tok(); offset >= tok.Start {
 				paramIndex = i
 			}
 		}
@@ -384,9 +383,9 @@ func (s *server) Highlights(ctx context.Context, doc *ls.Document, pos ls.Positi
 
 // Format returns a list of edits required to format the the entire document.
 func (s *server) Format(ctx context.Context, doc *ls.Document, opts ls.FormattingOptions) (ls.TextEditList, error) {
-	m := parse.NewCSTMap()
-	ast, err := parser.Parse("", doc.Body().Text(), m)
-	if err != nil {
+	m := &ast.Mappings{}
+	ast, errs := parser.Parse("", doc.Body().Text(), m)
+	if len(errs) > 0 {
 		// Reformatting ASTs with parse errors?
 		// You're going to have a bad time.
 		return ls.TextEditList{}, nil
@@ -463,7 +462,7 @@ func (s *server) Hover(ctx context.Context, doc *ls.Document, pos ls.Position) (
 			}
 			if len(branch.Children) < 5 {
 				for _, n := range branch.Children {
-					tok := n.Token()
+					tok := n.Tok()
 					lines := strings.Split(tok.String(), "\n")
 					cnt := len(lines)
 					if cnt == 1 {
@@ -555,13 +554,6 @@ func (s *server) WorkspaceSymbols(ctx context.Context) (ls.SymbolList, error) {
 	return syms, nil
 }
 
-// CodeActions compute commands for a given document and range.
-// The request is triggered when the user moves the cursor into an problem
-// marker in the editor or presses the lightbulb associated with a marker.
-func (s *server) CodeActions(context.Context, *ls.Document, ls.Range, []ls.Diagnostic) ([]ls.Command, error) {
-	return []ls.Command{}, nil
-}
-
 func findAPIs(root string) []string {
 	apis := []string{}
 	filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
@@ -650,7 +642,7 @@ func astChildAt(da *docAnalysis, parent ast.Node, offset int) ast.Node {
 		if cst == nil {
 			return
 		}
-		if tok := cst.Token(); offset >= tok.Start && offset <= tok.End {
+		if tok := cst.Tok(); offset >= tok.Start && offset <= tok.End {
 			child = n
 		}
 	})
@@ -686,11 +678,11 @@ func (fa *fullAnalysis) nodeLocation(doc *ls.Document, n ast.Node) ls.Location {
 }
 
 func (fa *fullAnalysis) nodeRange(doc *ls.Document, n ast.Node) ls.Range {
-	return tokRange(doc, fa.mappings.AST.CST(n).Token())
+	return tokRange(doc, fa.mappings.AST.CST(n).Tok())
 }
 
 func (fa *fullAnalysis) nodePosition(doc *ls.Document, n ast.Node) ls.Position {
-	return tokRange(doc, fa.mappings.AST.CST(n).Token()).Start
+	return tokRange(doc, fa.mappings.AST.CST(n).Tok()).Start
 }
 
 func tokRange(doc *ls.Document, tok cst.Token) ls.Range {
@@ -701,14 +693,14 @@ func fragRange(doc *ls.Document, f cst.Fragment) ls.Range {
 	if f == nil {
 		return doc.Body().Range(0, 0)
 	}
-	return tokRange(doc, f.Token())
+	return tokRange(doc, f.Tok())
 }
 
 func (fa *fullAnalysis) documentation(n ast.Node) string {
 	buf := &bytes.Buffer{}
 	cst := fa.mappings.AST.CST(n)
-	cst.Prefix().WriteTo(buf)
-	cst.Suffix().WriteTo(buf)
+	cst.Prefix().Write(buf)
+	cst.Suffix().Write(buf)
 	lines := strings.Split(buf.String(), "\n")
 	for i, line := range lines {
 		line = strings.TrimSpace(lines[i])
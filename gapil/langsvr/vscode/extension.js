@@ -45,11 +45,23 @@ function activate(context) {
 	}
 
 	// Create the language client and start the client.
-	let disposable = new LanguageClient('gfxapi', serverOptions, clientOptions).start();
+	let client = new LanguageClient('gfxapi', serverOptions, clientOptions);
+	let disposable = client.start();
 
 	// Push the disposable to the context's subscriptions so that the
 	// client can be deactivated on extension deactivation
 	context.subscriptions.push(disposable);
+
+	// Commands used by the server's code actions and code lenses.
+	// Arguments are sent in their language server protocol form.
+	let conv = client.protocol2CodeConverter;
+	context.subscriptions.push(vscode.commands.registerCommand('gfxapi.applyEdit', function (edit) {
+		return vscode.workspace.applyEdit(conv.asWorkspaceEdit(edit));
+	}));
+	context.subscriptions.push(vscode.commands.registerCommand('gfxapi.showReferences', function (uri, position, locations) {
+		return vscode.commands.executeCommand('editor.action.showReferences',
+			conv.asUri(uri), conv.asPosition(position), locations.map(l => conv.asLocation(l)));
+	}));
 }
 exports.activate = activate;
 
@@ -16,6 +16,7 @@ package validate
 
 import (
 	"fmt"
+	"math/big"
 	"reflect"
 
 	"github.com/google/gapid/gapil/analysis"
@@ -40,18 +41,112 @@ func (e ErrUnreachable) Error() string {
 	return fmt.Sprintf("Unreachable %v", reflect.TypeOf(e.Node).Name())
 }
 
+// ErrUnguardedMapIndex is the error raised for a map index that is not
+// guarded by a check that the map contains the key.
+type ErrUnguardedMapIndex struct {
+	Index *semantic.MapIndex
+	// Contains is the possibility of the map containing the key.
+	Contains analysis.Possibility
+}
+
+func (e ErrUnguardedMapIndex) Error() string {
+	if e.Contains == analysis.False {
+		return "Map index of a key that is never in the map"
+	}
+	return "Map index is not guarded by a check for the key"
+}
+
+// ErrUninitializedAccess is the error raised for a field access through a
+// reference that may not have been assigned on some path.
+type ErrUninitializedAccess struct {
+	Member *semantic.Member
+}
+
+func (e ErrUninitializedAccess) Error() string {
+	return fmt.Sprintf("Field %v accessed through a possibly uninitialized reference", e.Member.Field.Name())
+}
+
+// ErrNarrowingCast is the error raised for an integer cast to a smaller type
+// where the value being cast may not fit in the new type.
+type ErrNarrowingCast struct {
+	Cast  *semantic.Cast
+	Value analysis.Value
+}
+
+func (e ErrNarrowingCast) Error() string {
+	return fmt.Sprintf("Cast from %v to %v may overflow (value: %v)",
+		e.Value.Type().Name(), e.Cast.Type.Name(), e.Value)
+}
+
 // Inspect checks the following:
 // * There are no unreachable blocks.
-// TODO: Check map indices are properly guarded by a check.
+// The following are also checked if enabled by the options:
+// * Map indices are guarded by a check that the map contains the key.
+// * Fields are not accessed through possibly uninitialized references.
+// * Narrowing integer casts cannot overflow.
 func Inspect(api *semantic.API, mappings *semantic.Mappings) Issues {
 	res := analysis.Analyze(api, mappings)
-	return inspect(api, mappings, res)
+	return inspect(api, mappings, &Options{}, res)
 }
 
-func inspect(api *semantic.API, mappings *semantic.Mappings, res *analysis.Results) Issues {
+func inspect(api *semantic.API, mappings *semantic.Mappings, options *Options, res *analysis.Results) Issues {
 	issues := Issues{}
 	for _, unreachable := range res.Unreachables {
 		issues.add(unreachable.At, ErrUnreachable{unreachable})
 	}
+	if options.CheckMapIndices {
+		for n, contains := range res.MapIndices {
+			if contains != analysis.True {
+				issues.add(mappings.CST(n), ErrUnguardedMapIndex{n, contains})
+			}
+		}
+	}
+	if options.CheckUninitialized {
+		for n := range res.UninitializedAccesses {
+			issues.add(mappings.CST(n), ErrUninitializedAccess{n})
+		}
+	}
+	if options.CheckNarrowingCasts {
+		for n, v := range res.Casts {
+			if v, ok := v.(*analysis.UintValue); ok && mayOverflow(v, semantic.Underlying(n.Type)) {
+				issues.add(mappings.CST(n), ErrNarrowingCast{n, v})
+			}
+		}
+	}
 	return issues
 }
+
+// mayOverflow returns true if to is smaller than the type of v and v holds
+// values that cannot be represented by to.
+func mayOverflow(v *analysis.UintValue, to semantic.Type) bool {
+	fromBits, toBits := semantic.IntegerSizeInBits(v.Ty), semantic.IntegerSizeInBits(to)
+	if fromBits == 0 || toBits == 0 || toBits >= fromBits || len(v.Ranges) == 0 {
+		return false
+	}
+	min, max := integerLimits(to, toBits)
+	lo := integerValue(v.Ty, fromBits, v.Ranges[0].Start)
+	hi := integerValue(v.Ty, fromBits, v.Ranges[len(v.Ranges)-1].End-1)
+	return lo.Cmp(min) < 0 || hi.Cmp(max) > 0
+}
+
+// integerLimits returns the smallest and largest values of the integer type ty.
+func integerLimits(ty semantic.Type, bits int) (min, max *big.Int) {
+	one := big.NewInt(1)
+	if semantic.IsUnsigned(ty) {
+		max := new(big.Int).Lsh(one, uint(bits))
+		return big.NewInt(0), max.Sub(max, one)
+	}
+	max = new(big.Int).Lsh(one, uint(bits-1))
+	min = new(big.Int).Neg(max)
+	return min, max.Sub(max, one)
+}
+
+// integerValue returns the integer value of the analysis representation v.
+// Signed values are held in the unsigned range, biased by half the range.
+func integerValue(ty semantic.Type, bits int, v uint64) *big.Int {
+	out := new(big.Int).SetUint64(v)
+	if !semantic.IsUnsigned(ty) {
+		out.Sub(out, new(big.Int).Lsh(big.NewInt(1), uint(bits-1)))
+	}
+	return out
+}
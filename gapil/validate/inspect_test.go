@@ -252,47 +252,6 @@ no_unreachables_test.api:6:21 Unreachable block
 		///////////////////////////////////////////////////////
 		// Map indexing tests
 		///////////////////////////////////////////////////////
-		/* TODO: Map tests.
-				{
-					`map!(u32, f32) M
-		       f32 V
-		       cmd void foo() {
-		         if (5 in M) && true {
-		           V = M[5]
-		         }
-		       }`, ``,
-				}, {
-					`map!(u32, f32) M
-		       f32 V
-		       cmd void foo() {
-		         if !(5 in M) {
-		           abort
-		         }
-		         V = M[5]
-		       }`, ``,
-				}, {
-					`map!(u32, f32) M
-		       f32 V
-		       cmd void foo() {
-		         M[5] = 1
-		         V = M[5]
-		       }`, ``,
-				}, {
-					`map!(u32, f32) M
-		       f32 V
-		       cmd void foo() {
-		         V = M[5]
-		       }`, ``,
-				}, {
-					`map!(u32, f32) M
-		       f32 V
-		       cmd void foo() {
-		         M[5] = 1
-		         delete(M, 5)
-		         V = M[5]
-		       }`, ``,
-				},
-		*/
 	} {
 		api, mappings, err := compile(ctx, test.source)
 		ok := true
@@ -306,3 +265,147 @@ no_unreachables_test.api:6:21 Unreachable block
 		}
 	}
 }
+
+func TestChecks(t *testing.T) {
+	ctx := log.Testing(t)
+
+	for _, test := range []struct {
+		name     string
+		options  validate.Options
+		source   string
+		expected string
+	}{
+		///////////////////////////////////////////////////////
+		// Map index tests
+		///////////////////////////////////////////////////////
+		{"map guarded by condition", validate.Options{CheckMapIndices: true},
+			`map!(u32, f32) M
+       f32 V
+       cmd void put(u32 k, f32 v) { M[k] = v }
+       cmd void get(u32 k) {
+         if (k in M) && true {
+           V = M[k]
+         }
+       }`, ``},
+		{"map guarded by abort", validate.Options{CheckMapIndices: true},
+			`map!(u32, f32) M
+       f32 V
+       cmd void put(u32 k, f32 v) { M[k] = v }
+       cmd void get(u32 k) {
+         if !(k in M) {
+           abort
+         }
+         V = M[k]
+       }`, ``},
+		{"map guarded by assignment", validate.Options{CheckMapIndices: true},
+			`map!(u32, f32) M
+       f32 V
+       cmd void foo() {
+         M[5] = 1
+         V = M[5]
+       }`, ``},
+		{"map unguarded", validate.Options{CheckMapIndices: true},
+			`map!(u32, f32) M
+       f32 V
+       cmd void put(u32 k, f32 v) { M[k] = v }
+       cmd void get(u32 k) {
+         V = M[k]
+       }`, `no_unreachables_test.api:5:14 Map index is not guarded by a check for the key`},
+		{"map never contains key", validate.Options{CheckMapIndices: true},
+			`map!(u32, f32) M
+       f32 V
+       cmd void foo() {
+         V = M[5]
+       }`, `no_unreachables_test.api:4:14 Map index of a key that is never in the map`},
+		{"map key removed", validate.Options{CheckMapIndices: true},
+			`map!(u32, f32) M
+       f32 V
+       cmd void foo() {
+         M[5] = 1
+         delete(M, 5)
+         V = M[5]
+       }`, `no_unreachables_test.api:6:14 Map index of a key that is never in the map`},
+		///////////////////////////////////////////////////////
+		// Uninitialized access tests
+		///////////////////////////////////////////////////////
+		{"initialized reference", validate.Options{CheckUninitialized: true},
+			`class C { u32 x }
+       ref!C R
+       u32 V
+       cmd void foo() {
+         R = new!C(x: 1)
+         V = R.x
+       }`, ``},
+		{"uninitialized reference", validate.Options{CheckUninitialized: true},
+			`class C { u32 x }
+       ref!C R
+       u32 V
+       cmd void foo() {
+         V = R.x
+       }`, `no_unreachables_test.api:5:14 Field x accessed through a possibly uninitialized reference`},
+		{"possibly uninitialized reference", validate.Options{CheckUninitialized: true},
+			`class C { u32 x }
+       ref!C R
+       u32 V
+       cmd void foo(bool b) {
+         R = null
+         if b { R = new!C(x: 1) }
+         V = R.x
+       }`, `no_unreachables_test.api:7:14 Field x accessed through a possibly uninitialized reference`},
+		{"reference checked for null", validate.Options{CheckUninitialized: true},
+			`class C { u32 x }
+       ref!C R
+       u32 V
+       cmd void foo(bool b) {
+         R = null
+         if b { R = new!C(x: 1) }
+         if R != null { V = R.x }
+       }`, ``},
+		///////////////////////////////////////////////////////
+		// Narrowing cast tests
+		///////////////////////////////////////////////////////
+		{"widening cast", validate.Options{CheckNarrowingCasts: true},
+			`u64 V
+       cmd void foo(u32 a) { V = as!u64(a) }`, ``},
+		{"narrowing cast in range", validate.Options{CheckNarrowingCasts: true},
+			`u8 V
+       cmd void foo(u32 a) {
+         if a < 256 { V = as!u8(a) }
+       }`, ``},
+		{"narrowing cast out of range", validate.Options{CheckNarrowingCasts: true},
+			`u8 V
+       cmd void foo(u32 a) {
+         V = as!u8(a)
+       }`, `no_unreachables_test.api:3:14 Cast from u32 to u8 may overflow (value: [0x0-0xffffffff])`},
+		{"narrowing signed cast", validate.Options{CheckNarrowingCasts: true},
+			`s8 V
+       cmd void foo(s32 a) {
+         if (a >= -128) && (a < 128) { V = as!s8(a) }
+         if a < 128 { V = as!s8(a) }
+       }`, `no_unreachables_test.api:4:27 Cast from s32 to s8 may overflow (value: [-0x80000000-0x7f])`},
+		///////////////////////////////////////////////////////
+		// Pointer parameter tests
+		///////////////////////////////////////////////////////
+		{"pointer parameters", validate.Options{CheckPointerParams: true},
+			`u32 V
+       cmd void foo(u32* a, u32* b, u32* c, @unused u32* d, @unused u32* e) {
+         if b != null { V = c[0] }
+         V = e[0]
+       }`, `
+no_unreachables_test.api:2:21 Pointer parameter a of foo is never read or written
+no_unreachables_test.api:2:29 Pointer parameter b of foo is never read or written
+no_unreachables_test.api:2:61 Redundant annotation`},
+	} {
+		ctx := log.Enter(ctx, test.name)
+		api, mappings, err := compile(ctx, test.source)
+		ok := true
+		ok = assert.For(ctx, "err").ThatError(err).Succeeded() && ok
+		ok = assert.For(ctx, "api").Critical().That(api).IsNotNil() && ok
+		got := fmt.Sprint(validate.Validate(api, mappings, &test.options))
+		expected := strings.TrimSpace(test.expected)
+		ok = assert.For(ctx, "got").ThatString(got).Equals(expected) && ok
+		if !ok {
+			log.E(ctx, "test failed.\n  source: %v\n  got:  %v", test.source, got)
+		}
+	}
+}
@@ -15,12 +15,31 @@
 package validate
 
 import (
+	"fmt"
+
 	"github.com/google/gapid/core/text/parse/cst"
 	"github.com/google/gapid/gapil/semantic"
 )
 
 type fieldUsage struct{ read, written bool }
 
+// ErrUnusedDeclaration is the error raised for a type or field that is
+// declared but never used.
+type ErrUnusedDeclaration struct {
+	// Decl is the unused semantic.Type or *semantic.Field.
+	Decl semantic.Node
+}
+
+func (e ErrUnusedDeclaration) Error() string {
+	switch d := e.Decl.(type) {
+	case *semantic.Field:
+		return fmt.Sprintf("Field %s.%s never used", d.Owner().Name(), d.Name())
+	case semantic.Type:
+		return fmt.Sprintf("Type %s declared but never used", d.Name())
+	}
+	return fmt.Sprintf("%v never used", e.Decl)
+}
+
 const annoUnused = "unused"
 
 // noUnused verifies that all declared types and fields are used.
@@ -193,7 +212,7 @@ func noUnused(api *semantic.API, mappings *semantic.Mappings) Issues {
 			}
 		}
 		if !used {
-			issues.addf(mappings.CST(t), "Type %s declared but never used", t.Name())
+			issues.add(mappings.CST(t), ErrUnusedDeclaration{t})
 		}
 	}
 	for f, usage := range fields {
@@ -210,7 +229,11 @@ func noUnused(api *semantic.API, mappings *semantic.Mappings) Issues {
 		unused := len(msg) > 0
 		fiu, ciu := f.GetAnnotation(annoUnused), class.GetAnnotation(annoUnused)
 		if unused && fiu == nil && ciu == nil {
-			issues.addf(mappings.AST.CST(f.AST), msg, f.Owner().Name(), f.Name())
+			if !usage.read && !usage.written {
+				issues.add(mappings.AST.CST(f.AST), ErrUnusedDeclaration{f})
+			} else {
+				issues.addf(mappings.AST.CST(f.AST), msg, f.Owner().Name(), f.Name())
+			}
 		}
 		if !unused && fiu != nil && ciu == nil {
 			issues.addf(mappings.AST.CST(fiu.AST), "Redundant annotation")
@@ -0,0 +1,99 @@
+// Copyright (C) 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package validate
+
+import (
+	"fmt"
+
+	"github.com/google/gapid/gapil/ast"
+	"github.com/google/gapid/gapil/semantic"
+)
+
+// ErrUnusedPointer is the error raised for a command pointer parameter that
+// is never read or written by the command.
+type ErrUnusedPointer struct {
+	Param *semantic.Parameter
+}
+
+func (e ErrUnusedPointer) Error() string {
+	return fmt.Sprintf("Pointer parameter %v of %v is never read or written",
+		e.Param.Name(), e.Param.Function.Name())
+}
+
+// noUnusedPointers verifies that all command pointer parameters are used for
+// something other than comparisons.
+func noUnusedPointers(api *semantic.API, mappings *semantic.Mappings) Issues {
+	issues := Issues{}
+	for _, f := range api.Functions {
+		if f.Subroutine || f.Extern {
+			continue
+		}
+		pointers := map[*semantic.Parameter]bool{}
+		for _, p := range f.CallParameters() {
+			if _, ok := semantic.Underlying(p.Type).(*semantic.Pointer); ok {
+				pointers[p] = false
+			}
+		}
+		if len(pointers) == 0 {
+			continue
+		}
+
+		var traverse func(n semantic.Node)
+		traverse = func(n semantic.Node) {
+			switch n := n.(type) {
+			case *semantic.Parameter:
+				if _, ok := pointers[n]; ok {
+					pointers[n] = true
+				}
+				return
+			case *semantic.BinaryOp:
+				switch n.Operator {
+				case ast.OpEQ, ast.OpNE, ast.OpLT, ast.OpLE, ast.OpGT, ast.OpGE:
+					// Comparing a pointer does not access the pointed-to memory.
+					if _, ok := n.LHS.(*semantic.Parameter); !ok {
+						traverse(n.LHS)
+					}
+					if _, ok := n.RHS.(*semantic.Parameter); !ok {
+						traverse(n.RHS)
+					}
+					return
+				}
+			case semantic.Type, *semantic.Callable:
+				return // Don't traverse into these.
+			}
+			semantic.Visit(n, traverse)
+		}
+		if f.Block != nil {
+			traverse(f.Block)
+		}
+
+		for _, p := range f.CallParameters() {
+			used, ok := pointers[p]
+			if !ok {
+				continue
+			}
+			if anno := p.GetAnnotation(annoUnused); anno != nil {
+				if used {
+					issues.addf(mappings.AST.CST(anno.AST), "Redundant annotation")
+				}
+				continue
+			}
+			if !used {
+				issues.add(mappings.AST.CST(p.AST), ErrUnusedPointer{p})
+			}
+		}
+	}
+	return issues
+}
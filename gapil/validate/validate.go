@@ -27,12 +27,22 @@ import (
 
 // Options controls the validation that's performed.
 type Options struct {
-	CheckUnused bool // Should unused types, fields, etc be reported?
+	CheckUnused         bool // Should unused types, fields, etc be reported?
+	CheckMapIndices     bool // Should unguarded map indices be reported?
+	CheckUninitialized  bool // Should accesses through possibly uninitialized references be reported?
+	CheckNarrowingCasts bool // Should integer casts that may overflow be reported?
+	CheckPointerParams  bool // Should unused command pointer parameters be reported?
+}
+
+// defaultValidation is the set of options used when no options are provided.
+// The other checks are opt-in as existing API files do not pass them.
+var defaultValidation = Options{
+	CheckUnused: true,
 }
 
 // Validate performs a number of checks on the api file for correctness.
 // If any problems are found then they are returned as errors.
-// If options is nil then full validation is performed.
+// If options is nil then only the default checks are performed.
 func Validate(api *semantic.API, mappings *semantic.Mappings, options *Options) Issues {
 	res := analysis.Analyze(api, mappings)
 	return WithAnalysis(api, mappings, options, res)
@@ -41,16 +51,19 @@ func Validate(api *semantic.API, mappings *semantic.Mappings, options *Options)
 // WithAnalysis performs a number of checks on the api file for
 // correctness using pre-built analysis results.
 // If any problems are found then they are returned as errors.
-// If options is nil then full validation is performed.
+// If options is nil then only the default checks are performed.
 func WithAnalysis(api *semantic.API, mappings *semantic.Mappings, options *Options, analysis *analysis.Results) Issues {
 	if options == nil {
-		options = &Options{true}
+		options = &defaultValidation
 	}
 	issues := Issues{}
 	if options.CheckUnused {
 		issues = append(issues, noUnused(api, mappings)...)
 	}
-	issues = append(issues, inspect(api, mappings, analysis)...)
+	if options.CheckPointerParams {
+		issues = append(issues, noUnusedPointers(api, mappings)...)
+	}
+	issues = append(issues, inspect(api, mappings, options, analysis)...)
 	sort.Sort(issues)
 	return issues
 }
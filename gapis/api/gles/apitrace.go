@@ -0,0 +1,473 @@
+// Copyright (C) 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gles
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+
+	"github.com/google/gapid/core/data/apitrace"
+	"github.com/google/gapid/core/data/endian"
+	"github.com/google/gapid/core/log"
+	"github.com/google/gapid/core/memory/arena"
+	"github.com/google/gapid/core/os/device"
+	"github.com/google/gapid/gapis/api"
+	"github.com/google/gapid/gapis/capture"
+	"github.com/google/gapid/gapis/database"
+	"github.com/google/gapid/gapis/memory"
+	"github.com/google/gapid/gapis/replay/value"
+	"github.com/google/gapid/gapis/service/path"
+)
+
+const (
+	eglWidth                = 0x3057
+	eglHeight               = 0x3056
+	eglContextClientVersion = 0x3098
+	eglNone                 = 0x3038
+
+	// The backbuffer size used for the surfaces whose size cannot be deduced
+	// from the trace.
+	apitraceDefaultWidth  = 1280
+	apitraceDefaultHeight = 720
+)
+
+var apitracePointerTy = reflect.TypeOf((*memory.Pointer)(nil)).Elem()
+
+// ImportAPITrace reads the apitrace trace from r and stores its commands as a
+// new capture with the given name.
+//
+// The data apitrace recorded for pointer arguments is placed in fresh memory
+// and attached to the commands as read observations, or write observations
+// for the data recorded when the call returned. Opaque pointers keep their
+// traced address. As apitrace does not record the context state, the
+// eglMakeCurrent commands are given the static and dynamic context state
+// extras deduced from the trace. Calls to functions that are not part of the
+// GLES API are dropped.
+func ImportAPITrace(ctx context.Context, name string, r io.Reader) (*path.Capture, error) {
+	ctx = log.Enter(ctx, "ImportAPITrace")
+
+	tr, err := apitrace.NewReader(r)
+	if err != nil {
+		return nil, err
+	}
+
+	abi := device.AndroidARM64v8a
+	i := &apitraceImporter{
+		arena:       arena.New(),
+		layout:      abi.MemoryLayout,
+		allocator:   memory.NewBasicAllocator(value.ValidMemoryRanges),
+		contexts:    map[uint64]int64{},
+		surfaces:    map[uint64]apitraceSurface{},
+		current:     map[uint64]uint64{},
+		skipped:     map[string]int{},
+		badArgs:     map[string]bool{},
+		makeCurrent: []apitraceMakeCurrent{},
+	}
+
+	for {
+		call, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err == io.ErrUnexpectedEOF {
+			log.W(ctx, "The trace is truncated, ignoring the incomplete last call")
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if err := i.add(ctx, call); err != nil {
+			return nil, err
+		}
+	}
+	i.applyContextState()
+
+	if len(i.skipped) > 0 {
+		names := make([]string, 0, len(i.skipped))
+		for n := range i.skipped {
+			names = append(names, n)
+		}
+		sort.Strings(names)
+		for _, n := range names {
+			log.W(ctx, "Dropped %d calls to %v, which is not a GLES or EGL function", i.skipped[n], n)
+		}
+	}
+
+	header := &capture.Header{
+		Device: &device.Instance{
+			Name: "apitrace",
+			Configuration: &device.Configuration{
+				OS:   &device.OS{Kind: device.Android},
+				ABIs: []*device.ABI{abi},
+			},
+		},
+		ABI: abi,
+	}
+	return capture.New(ctx, i.arena, name, header, nil, i.cmds)
+}
+
+type apitraceImporter struct {
+	arena     arena.Arena
+	layout    *device.MemoryLayout
+	allocator memory.Allocator
+	cmds      []api.Cmd
+
+	contexts    map[uint64]int64 // EGLContext -> client major version
+	surfaces    map[uint64]apitraceSurface
+	current     map[uint64]uint64 // thread -> EGLSurface
+	makeCurrent []apitraceMakeCurrent
+
+	skipped map[string]int
+	badArgs map[string]bool
+}
+
+type apitraceSurface struct {
+	width, height int64
+	// queried is true if the size was returned by eglQuerySurface, false if it
+	// was deduced from the first viewport set while the surface was current.
+	queried bool
+}
+
+type apitraceMakeCurrent struct {
+	cmd     *EglMakeCurrent
+	surface uint64
+	context uint64
+}
+
+// add converts the call to a command, appending it to the commands.
+func (i *apitraceImporter) add(ctx context.Context, call *apitrace.Call) error {
+	cmd := API{}.CreateCmd(i.arena, call.Name)
+	if cmd == nil {
+		i.skipped[call.Name]++
+		return nil
+	}
+	cmd.SetCaller(api.CmdNoID)
+	cmd.SetThread(call.Thread)
+
+	for idx, p := range cmd.CmdParams() {
+		if idx >= len(call.Args) {
+			break
+		}
+		arg := call.Args[idx]
+		if err := i.set(ctx, cmd, p, arg.Value, arg.Output); err != nil {
+			return err
+		}
+	}
+	if p := cmd.CmdResult(); p != nil {
+		if err := i.set(ctx, cmd, p, call.Ret, true); err != nil {
+			return err
+		}
+	}
+
+	i.track(cmd, call)
+	i.cmds = append(i.cmds, cmd)
+	return nil
+}
+
+// track records the information needed to build the context state extras.
+func (i *apitraceImporter) track(cmd api.Cmd, call *apitrace.Call) {
+	switch cmd := cmd.(type) {
+	case *EglCreateContext:
+		version := int64(1)
+		if attribs, ok := argValue(call, 3).([]apitrace.Value); ok {
+			for j := 0; j+1 < len(attribs); j += 2 {
+				n, ok := apitraceInt(attribs[j])
+				if !ok || n == eglNone {
+					break
+				}
+				if n == eglContextClientVersion {
+					version, _ = apitraceInt(attribs[j+1])
+				}
+			}
+		}
+		i.contexts[cmd.Result().Address()] = version
+
+	case *EglMakeCurrent:
+		i.current[cmd.Thread()] = cmd.Draw().Address()
+		if !cmd.Context().IsNullptr() {
+			i.makeCurrent = append(i.makeCurrent, apitraceMakeCurrent{
+				cmd:     cmd,
+				surface: cmd.Draw().Address(),
+				context: cmd.Context().Address(),
+			})
+		}
+
+	case *EglQuerySurface:
+		values, ok := argValue(call, 3).([]apitrace.Value)
+		if !ok || len(values) == 0 {
+			break
+		}
+		v, _ := apitraceInt(values[0])
+		s := i.surfaces[cmd.Surface().Address()]
+		if !s.queried {
+			s = apitraceSurface{queried: true}
+		}
+		switch cmd.Attribute() {
+		case eglWidth:
+			s.width = v
+		case eglHeight:
+			s.height = v
+		default:
+			return
+		}
+		i.surfaces[cmd.Surface().Address()] = s
+
+	case *GlViewport:
+		surface, ok := i.current[cmd.Thread()]
+		if !ok || surface == 0 {
+			break
+		}
+		if _, known := i.surfaces[surface]; !known {
+			i.surfaces[surface] = apitraceSurface{
+				width:  int64(cmd.Width()),
+				height: int64(cmd.Height()),
+			}
+		}
+	}
+}
+
+// applyContextState adds the context state extras to the eglMakeCurrent
+// commands.
+func (i *apitraceImporter) applyContextState() {
+	for _, mc := range i.makeCurrent {
+		width, height := int64(apitraceDefaultWidth), int64(apitraceDefaultHeight)
+		if s, ok := i.surfaces[mc.surface]; ok && s.width > 0 && s.height > 0 {
+			width, height = s.width, s.height
+		}
+
+		constants := DefaultConstants30(i.arena)
+		if version, ok := i.contexts[mc.context]; ok && version < 3 {
+			constants.SetMajorVersion(2)
+			constants.SetVersion("OpenGL ES 2.0")
+		}
+
+		mc.cmd.Extras().Add(
+			NewStaticContextState(i.arena,
+				constants, // Constants
+				"",        // ThreadName
+			),
+			NewDynamicContextState(i.arena,
+				GLsizei(width),             // BackbufferWidth
+				GLsizei(height),            // BackbufferHeight
+				GLenum_GL_RGBA8,            // BackbufferColorFmt
+				GLenum_GL_DEPTH24_STENCIL8, // BackbufferDepthFmt
+				GLenum_GL_DEPTH24_STENCIL8, // BackbufferStencilFmt
+				false,                      // PreserveBuffersOnSwap
+				8,                          // RedSize
+				8,                          // GreenSize
+				8,                          // BlueSize
+				8,                          // AlphaSize
+				24,                         // DepthSize
+				8,                          // StencilSize
+			),
+		)
+	}
+}
+
+// set assigns the traced value v to the command parameter or result p.
+func (i *apitraceImporter) set(ctx context.Context, cmd api.Cmd, p *api.Property, v apitrace.Value, output bool) error {
+	if p.Type.Implements(apitracePointerTy) {
+		addr, err := i.pointer(ctx, cmd, p.Type, v, output)
+		if err != nil {
+			return err
+		}
+		p.Set(reflect.ValueOf(addr).Convert(p.Type).Interface())
+		return nil
+	}
+	val, err := apitraceConvert(v, p.Type)
+	if err != nil {
+		i.badArgument(ctx, cmd, p, err)
+		return nil
+	}
+	p.Set(val.Interface())
+	return nil
+}
+
+// pointer returns the address for the traced value v of the pointer type ty.
+// Pointee data recorded in the trace is stored in newly allocated memory,
+// observed by cmd.
+func (i *apitraceImporter) pointer(ctx context.Context, cmd api.Cmd, ty reflect.Type, v apitrace.Value, output bool) (uint64, error) {
+	switch v := v.(type) {
+	case nil:
+		return 0, nil
+	case apitrace.Opaque:
+		return uint64(v), nil
+	case int64:
+		return uint64(v), nil
+	case uint64:
+		return v, nil
+	}
+
+	elTy := reflect.Zero(ty).Interface().(interface {
+		ElementType() reflect.Type
+	}).ElementType()
+	data, err := i.data(ctx, cmd, elTy, v, output)
+	if err != nil {
+		return 0, err
+	}
+
+	buf := &bytes.Buffer{}
+	memory.Write(memory.NewEncoder(endian.Writer(buf, i.layout.GetEndian()), i.layout), data)
+	size := uint64(buf.Len())
+	base, err := i.allocator.Alloc(size+1, 8) // +1 to never allocate an empty range.
+	if err != nil {
+		return 0, err
+	}
+	if size > 0 {
+		id, err := database.Store(ctx, buf.Bytes())
+		if err != nil {
+			return 0, err
+		}
+		rng := memory.Range{Base: base, Size: size}
+		if output {
+			cmd.Extras().GetOrAppendObservations().AddWrite(rng, id)
+		} else {
+			cmd.Extras().GetOrAppendObservations().AddRead(rng, id)
+		}
+	}
+	return base, nil
+}
+
+// data returns the value to write to memory for the traced pointee value v of
+// elements of type elTy.
+func (i *apitraceImporter) data(ctx context.Context, cmd api.Cmd, elTy reflect.Type, v apitrace.Value, output bool) (interface{}, error) {
+	switch v := v.(type) {
+	case []byte:
+		return v, nil
+	case string:
+		return append([]byte(v), 0), nil
+	case []apitrace.Value:
+		if elTy.Size() == 1 && len(v) > 0 {
+			// void pointers report byte elements. Write the values with their
+			// traced types instead.
+			if _, isFloat := v[0].(float32); isFloat {
+				elTy = reflect.TypeOf(float32(0))
+			}
+		}
+		out := reflect.MakeSlice(reflect.SliceOf(elTy), len(v), len(v))
+		for j, e := range v {
+			el, err := i.element(ctx, cmd, elTy, e, output)
+			if err != nil {
+				return nil, err
+			}
+			out.Index(j).Set(el)
+		}
+		return out.Interface(), nil
+	default:
+		el, err := i.element(ctx, cmd, elTy, v, output)
+		if err != nil {
+			return nil, err
+		}
+		return el.Interface(), nil
+	}
+}
+
+// element returns the traced value v converted to the element type elTy.
+func (i *apitraceImporter) element(ctx context.Context, cmd api.Cmd, elTy reflect.Type, v apitrace.Value, output bool) (reflect.Value, error) {
+	if elTy.Implements(apitracePointerTy) {
+		addr, err := i.pointer(ctx, cmd, elTy, v, output)
+		if err != nil {
+			return reflect.Value{}, err
+		}
+		return reflect.ValueOf(addr).Convert(elTy), nil
+	}
+	val, err := apitraceConvert(v, elTy)
+	if err != nil {
+		i.badArgument(ctx, cmd, nil, err)
+		return reflect.Zero(elTy), nil
+	}
+	return val, nil
+}
+
+// badArgument logs the traced value that could not be converted, once per
+// command.
+func (i *apitraceImporter) badArgument(ctx context.Context, cmd api.Cmd, p *api.Property, err error) {
+	if i.badArgs[cmd.CmdName()] {
+		return
+	}
+	i.badArgs[cmd.CmdName()] = true
+	if p != nil {
+		log.W(ctx, "Ignoring the %v argument of %v: %v", p.Name, cmd.CmdName(), err)
+	} else {
+		log.W(ctx, "Ignoring pointee data of %v: %v", cmd.CmdName(), err)
+	}
+}
+
+// apitraceConvert converts the scalar traced value v to the type ty.
+func apitraceConvert(v apitrace.Value, ty reflect.Type) (reflect.Value, error) {
+	if s, ok := v.(string); ok && ty.Kind() == reflect.String {
+		return reflect.ValueOf(s).Convert(ty), nil
+	}
+	var n interface{}
+	switch v := v.(type) {
+	case nil:
+		n = uint64(0)
+	case bool:
+		n = uint64(0)
+		if v {
+			n = uint64(1)
+		}
+	case int64, uint64, float32, float64:
+		n = v
+	case apitrace.Enum:
+		n = v.Value
+	case apitrace.Bitmask:
+		n = v.Value
+	case apitrace.Opaque:
+		n = uint64(v)
+	default:
+		return reflect.Value{}, fmt.Errorf("Cannot convert %T to %v", v, ty)
+	}
+
+	switch ty.Kind() {
+	case reflect.Bool:
+		f := reflect.ValueOf(n).Convert(reflect.TypeOf(float64(0))).Float()
+		return reflect.ValueOf(f != 0).Convert(ty), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return reflect.ValueOf(n).Convert(ty), nil
+	default:
+		return reflect.Value{}, fmt.Errorf("Cannot convert %T to %v", v, ty)
+	}
+}
+
+// apitraceInt returns the traced integer value v as an int64.
+func apitraceInt(v apitrace.Value) (int64, bool) {
+	switch v := v.(type) {
+	case int64:
+		return v, true
+	case uint64:
+		return int64(v), true
+	case apitrace.Enum:
+		return v.Value, true
+	case apitrace.Bitmask:
+		return int64(v.Value), true
+	}
+	return 0, false
+}
+
+// argValue returns the value of the argument at index idx of the call, or nil
+// if the argument was not recorded.
+func argValue(call *apitrace.Call, idx int) apitrace.Value {
+	if idx < len(call.Args) {
+		return call.Args[idx].Value
+	}
+	return nil
+}
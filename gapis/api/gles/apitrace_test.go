@@ -0,0 +1,152 @@
+// Copyright (C) 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gles_test
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/google/gapid/core/assert"
+	"github.com/google/gapid/core/data/apitrace"
+	"github.com/google/gapid/core/log"
+	"github.com/google/gapid/core/os/device/bind"
+	"github.com/google/gapid/gapis/api"
+	"github.com/google/gapid/gapis/api/gles"
+	"github.com/google/gapid/gapis/capture"
+	"github.com/google/gapid/gapis/database"
+)
+
+// apitraceTestTrace returns a trace creating a GLES 3 context, uploading a
+// 2x2 texture and clearing the backbuffer, whose size is only known from the
+// first viewport.
+func apitraceTestTrace() []byte {
+	display, surface, eglContext := apitrace.Opaque(0x10), apitrace.Opaque(0x20), apitrace.Opaque(0x30)
+	in := func(v ...apitrace.Value) []apitrace.Arg {
+		out := make([]apitrace.Arg, len(v))
+		for i, v := range v {
+			out[i] = apitrace.Arg{Value: v}
+		}
+		return out
+	}
+	enum := func(name string, v int64) apitrace.Enum { return apitrace.Enum{Name: name, Value: v} }
+
+	genTextures := in(int64(1), nil)
+	genTextures[1] = apitrace.Arg{Value: []apitrace.Value{uint64(5)}, Output: true}
+	pixels := []byte{
+		0xff, 0x00, 0x00, 0xff, 0x00, 0xff, 0x00, 0xff,
+		0x00, 0x00, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff,
+	}
+
+	buf := &bytes.Buffer{}
+	w, err := apitrace.NewWriter(buf, nil)
+	if err != nil {
+		panic(err)
+	}
+	for _, c := range []*apitrace.Call{
+		{Name: "eglCreateContext", Args: in(display, apitrace.Opaque(0x40), nil,
+			[]apitrace.Value{uint64(0x3098), uint64(3), uint64(0x3038)}), Ret: eglContext},
+		{Name: "eglMakeCurrent", Args: in(display, surface, surface, eglContext), Ret: true},
+		{Name: "glViewport", Args: in(int64(0), int64(0), int64(320), int64(240))},
+		{Name: "glGenTextures", Args: genTextures},
+		{Name: "glBindTexture", Args: in(enum("GL_TEXTURE_2D", 0x0DE1), uint64(5))},
+		{Name: "glTexImage2D", Args: in(enum("GL_TEXTURE_2D", 0x0DE1), int64(0), enum("GL_RGBA8", 0x8058),
+			int64(2), int64(2), int64(0), enum("GL_RGBA", 0x1908), enum("GL_UNSIGNED_BYTE", 0x1401), pixels)},
+		{Name: "glClearColor", Args: in(float32(0.5), float32(0), float32(0), float32(1))},
+		{Name: "glXSwapBuffers", Args: in(apitrace.Opaque(0x50), apitrace.Opaque(0x60))},
+		{Name: "glClear", Args: in(apitrace.Bitmask{Value: 0x4000})},
+		{Name: "eglSwapBuffers", Args: in(display, surface), Ret: true},
+	} {
+		if err := w.Write(c); err != nil {
+			panic(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		panic(err)
+	}
+	return buf.Bytes()
+}
+
+func TestImportAPITrace(t *testing.T) {
+	ctx := log.Testing(t)
+	ctx = bind.PutRegistry(ctx, bind.NewRegistry())
+	ctx = database.Put(ctx, database.NewInMemory(ctx))
+
+	p, err := gles.ImportAPITrace(ctx, "apitrace", bytes.NewReader(apitraceTestTrace()))
+	if !assert.For(ctx, "ImportAPITrace").ThatError(err).Succeeded() {
+		return
+	}
+	ctx = capture.Put(ctx, p)
+	c, err := capture.Resolve(ctx)
+	if !assert.For(ctx, "Resolve").ThatError(err).Succeeded() {
+		return
+	}
+
+	names := make([]string, len(c.Commands))
+	for i, cmd := range c.Commands {
+		names[i] = cmd.CmdName()
+	}
+	assert.For(ctx, "commands").ThatSlice(names).Equals([]string{
+		"eglCreateContext",
+		"eglMakeCurrent",
+		"glViewport",
+		"glGenTextures",
+		"glBindTexture",
+		"glTexImage2D",
+		"glClearColor",
+		"glClear",
+		"eglSwapBuffers",
+	})
+
+	// The texture names written by glGenTextures and the pixels read by
+	// glTexImage2D are observations.
+	genTextures := c.Commands[3].Extras().Observations()
+	if assert.For(ctx, "glGenTextures observations").That(genTextures).IsNotNil() {
+		assert.For(ctx, "glGenTextures reads").That(len(genTextures.Reads)).Equals(0)
+		assert.For(ctx, "glGenTextures writes").That(len(genTextures.Writes)).Equals(1)
+		assert.For(ctx, "glGenTextures write size").That(genTextures.Writes[0].Range.Size).Equals(uint64(4))
+	}
+	texImage := c.Commands[5].Extras().Observations()
+	if assert.For(ctx, "glTexImage2D observations").That(texImage).IsNotNil() {
+		assert.For(ctx, "glTexImage2D reads").That(len(texImage.Reads)).Equals(1)
+		assert.For(ctx, "glTexImage2D read size").That(texImage.Reads[0].Range.Size).Equals(uint64(16))
+	}
+
+	s := c.NewState(ctx)
+	err = api.ForeachCmd(ctx, c.Commands, func(ctx context.Context, id api.CmdID, cmd api.Cmd) error {
+		return cmd.Mutate(ctx, id, s, nil, nil)
+	})
+	if !assert.For(ctx, "Mutate").ThatError(err).Succeeded() {
+		return
+	}
+
+	glesCtx := gles.GetContext(s, 0)
+	if !assert.For(ctx, "context").That(glesCtx.IsNil()).Equals(false) {
+		return
+	}
+	assert.For(ctx, "major version").That(glesCtx.Constants().MajorVersion()).Equals(gles.GLint(3))
+	backbuffer := glesCtx.Objects().Framebuffers().Get(0).ColorAttachments().Get(0).Renderbuffer().Image()
+	assert.For(ctx, "backbuffer width").That(backbuffer.Width()).Equals(gles.GLsizei(320))
+	assert.For(ctx, "backbuffer height").That(backbuffer.Height()).Equals(gles.GLsizei(240))
+
+	tex := glesCtx.Objects().Textures().Get(5)
+	if assert.For(ctx, "texture").That(tex.IsNil()).Equals(false) {
+		img := tex.Levels().Get(0).Layers().Get(0)
+		assert.For(ctx, "texture width").That(img.Width()).Equals(gles.GLsizei(2))
+		assert.For(ctx, "texture height").That(img.Height()).Equals(gles.GLsizei(2))
+	}
+	clear := glesCtx.Pixel().ColorClearValue()
+	assert.For(ctx, "clear color").That(clear.Get(0)).Equals(gles.GLfloat(0.5))
+}
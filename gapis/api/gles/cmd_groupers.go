@@ -0,0 +1,95 @@
+// Copyright (C) 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gles
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/google/gapid/gapis/api"
+	"github.com/google/gapid/gapis/extensions"
+	"github.com/google/gapid/gapis/resolve/cmdgrouper"
+	"github.com/google/gapid/gapis/service/path"
+)
+
+func init() {
+	extensions.Register(extensions.Extension{
+		Name:        "GLES render passes",
+		CmdGroupers: newCmdGroupers,
+	})
+}
+
+// passKey identifies the framebuffer drawn to by the commands of a context.
+type passKey struct {
+	context     ContextID
+	framebuffer FramebufferId
+}
+
+// pipelineKey identifies the program used by the commands of a context to draw
+// to a framebuffer.
+type pipelineKey struct {
+	pass    passKey
+	program ProgramId
+}
+
+// newCmdGroupers returns the groupers clustering commands by framebuffer and
+// program. Program groups are broken at framebuffer changes so they nest
+// within the framebuffer groups.
+func newCmdGroupers(ctx context.Context, p *path.CommandTree, r *path.ResolveConfig) []cmdgrouper.Grouper {
+	out := []cmdgrouper.Grouper{}
+	if p.GroupByRenderPass {
+		out = append(out, cmdgrouper.FrameRun(func(cmd api.Cmd, s *api.GlobalState) (interface{}, string) {
+			key, ok := currentPass(cmd, s)
+			if !ok {
+				return nil, ""
+			}
+			if key.framebuffer == 0 {
+				return key, "Default framebuffer"
+			}
+			return key, fmt.Sprintf("Framebuffer %d", key.framebuffer)
+		}))
+	}
+	if p.GroupByPipeline {
+		out = append(out, cmdgrouper.FrameRun(func(cmd api.Cmd, s *api.GlobalState) (interface{}, string) {
+			pass, ok := currentPass(cmd, s)
+			if !ok {
+				return nil, ""
+			}
+			program := GetContext(s, cmd.Thread()).Bound().Program()
+			if program.IsNil() || program.ID() == 0 {
+				return nil, ""
+			}
+			return pipelineKey{pass, program.ID()}, fmt.Sprintf("Program %d", program.ID())
+		}))
+	}
+	return out
+}
+
+// currentPass returns the key of the framebuffer bound for drawing by the
+// context of the GLES command cmd.
+func currentPass(cmd api.Cmd, s *api.GlobalState) (passKey, bool) {
+	if a := cmd.API(); a == nil || a.ID() != ID {
+		return passKey{}, false
+	}
+	c := GetContext(s, cmd.Thread())
+	if c.IsNil() {
+		return passKey{}, false
+	}
+	fb := c.Bound().DrawFramebuffer()
+	if fb.IsNil() {
+		return passKey{c.Identifier(), 0}, true
+	}
+	return passKey{c.Identifier(), fb.ID()}, true
+}
@@ -0,0 +1,133 @@
+// Copyright (C) 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gles_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/gapid/core/assert"
+	"github.com/google/gapid/core/log"
+	"github.com/google/gapid/core/memory/arena"
+	"github.com/google/gapid/core/os/device"
+	"github.com/google/gapid/core/os/device/bind"
+	"github.com/google/gapid/gapis/api"
+	"github.com/google/gapid/gapis/api/gles"
+	"github.com/google/gapid/gapis/capture"
+	"github.com/google/gapid/gapis/database"
+	"github.com/google/gapid/gapis/extensions"
+	"github.com/google/gapid/gapis/memory"
+	"github.com/google/gapid/gapis/resolve/cmdgrouper"
+	"github.com/google/gapid/gapis/service/path"
+)
+
+func TestCmdGroupers(t *testing.T) {
+	ctx := log.Testing(t)
+	ctx = bind.PutRegistry(ctx, bind.NewRegistry())
+	ctx = database.Put(ctx, database.NewInMemory(ctx))
+
+	a := arena.New()
+	defer a.Dispose()
+
+	programInfo := gles.MakeLinkProgramExtra(a)
+	programInfo.SetLinkStatus(gles.GLboolean_GL_TRUE)
+	programInfo.SetActiveResources(gles.MakeActiveProgramResourcesʳ(a))
+
+	ctxHandle := memory.BytePtr(1)
+	displayHandle := memory.BytePtr(2)
+	surfaceHandle := memory.BytePtr(3)
+	cb := gles.CommandBuilder{Thread: 0, Arena: a}
+	prologue := []api.Cmd{
+		cb.EglCreateContext(displayHandle, surfaceHandle, surfaceHandle, memory.Nullptr, ctxHandle),
+		api.WithExtras(
+			cb.EglMakeCurrent(displayHandle, surfaceHandle, surfaceHandle, ctxHandle, 0),
+			gles.NewStaticContextStateForTest(a), gles.NewDynamicContextStateForTest(a, 64, 64, false)),
+		cb.GlCreateProgram(1),
+		api.WithExtras(cb.GlLinkProgram(1), programInfo),
+		cb.GlCreateProgram(2),
+		api.WithExtras(cb.GlLinkProgram(2), programInfo),
+	}
+	draw := func() api.Cmd { return cb.GlDrawArrays(gles.GLenum_GL_TRIANGLES, 0, 3) }
+	cmds := []api.Cmd{
+		// Program 1 drawing to the default framebuffer.
+		cb.GlUseProgram(1),
+		draw(),
+		cb.GlBindRenderbuffer(gles.GLenum_GL_RENDERBUFFER, 1),
+		cb.GlRenderbufferStorage(gles.GLenum_GL_RENDERBUFFER, gles.GLenum_GL_RGBA8, 64, 64),
+		// Programs 1 then 2 drawing to framebuffer 1, to the end of the frame.
+		cb.GlBindFramebuffer(gles.GLenum_GL_DRAW_FRAMEBUFFER, 1),
+		cb.GlFramebufferRenderbuffer(gles.GLenum_GL_DRAW_FRAMEBUFFER, gles.GLenum_GL_COLOR_ATTACHMENT0,
+			gles.GLenum_GL_RENDERBUFFER, 1),
+		draw(),
+		cb.GlUseProgram(2),
+		draw(),
+		cb.EglSwapBuffers(displayHandle, surfaceHandle, gles.EGLBoolean(1)),
+		// Program 2 drawing to framebuffer 1 in the next frame.
+		draw(),
+	}
+
+	var newGroupers func(context.Context, *path.CommandTree, *path.ResolveConfig) []cmdgrouper.Grouper
+	for _, e := range extensions.Get() {
+		if e.Name == "GLES render passes" {
+			newGroupers = e.CmdGroupers
+		}
+	}
+	if !assert.For(ctx, "extension").That(newGroupers != nil).Equals(true) {
+		return
+	}
+	groupers := newGroupers(ctx, &path.CommandTree{GroupByRenderPass: true, GroupByPipeline: true}, nil)
+	if !assert.For(ctx, "groupers").That(len(groupers)).Equals(2) {
+		return
+	}
+
+	all := append(append([]api.Cmd{}, prologue...), cmds...)
+	h := &capture.Header{ABI: device.WindowsX86_64}
+	p, err := capture.New(ctx, a, "groupers", h, nil, all)
+	if !assert.For(ctx, "capture").ThatError(err).Succeeded() {
+		return
+	}
+	ctx = capture.Put(ctx, p)
+	c, err := capture.Resolve(ctx)
+	if !assert.For(ctx, "resolve").ThatError(err).Succeeded() {
+		return
+	}
+
+	// The commands are processed with their index in cmds, once mutated.
+	s := c.NewState(ctx)
+	api.ForeachCmd(ctx, all, func(ctx context.Context, id api.CmdID, cmd api.Cmd) error {
+		if err := cmd.Mutate(ctx, id, s, nil, nil); err != nil {
+			log.W(ctx, "Command %v %v: %v", id, cmd, err)
+		}
+		if id >= api.CmdID(len(prologue)) {
+			for _, g := range groupers {
+				g.Process(ctx, id-api.CmdID(len(prologue)), cmd, s)
+			}
+		}
+		return nil
+	})
+
+	end := api.CmdID(len(cmds))
+	assert.For(ctx, "render passes").ThatSlice(groupers[0].Build(end)).Equals([]cmdgrouper.Group{
+		{Start: 0, End: 4, Name: "Default framebuffer"},
+		{Start: 4, End: 10, Name: "Framebuffer 1"},
+		{Start: 10, End: 11, Name: "Framebuffer 1"},
+	})
+	assert.For(ctx, "programs").ThatSlice(groupers[1].Build(end)).Equals([]cmdgrouper.Group{
+		{Start: 0, End: 4, Name: "Program 1"},
+		{Start: 4, End: 7, Name: "Program 1"},
+		{Start: 7, End: 10, Name: "Program 2"},
+		{Start: 10, End: 11, Name: "Program 2"},
+	})
+}
@@ -1362,12 +1362,20 @@ func canUsePrecompiledShader(c Contextʳ, d *device.OpenGLDriver) bool {
 // vertex attribute array that has no array data when drawing. AFAICT, this
 // particular behavior is undefined according to the spec.
 func disableUnusedAttribArrays(ctx context.Context, t *tweaker) {
-	p := t.c.Bound().Program()
+	for _, l := range unusedAttribArrays(t.c) {
+		t.glDisableVertexAttribArray(ctx, l)
+	}
+}
+
+// unusedAttribArrays returns the locations of the enabled vertex attribute
+// arrays of the context c that are not used by its bound program.
+func unusedAttribArrays(c Contextʳ) []AttributeLocation {
+	p := c.Bound().Program()
 	if p.IsNil() || p.ActiveResources().IsNil() {
-		return
+		return nil
 	}
 	inputs := p.ActiveResources().ProgramInputs()
-	used := make([]bool, t.c.Constants().MaxVertexAttribBindings())
+	used := make([]bool, c.Constants().MaxVertexAttribBindings())
 	for _, input := range inputs.All() {
 		for _, l := range input.Locations().All() {
 			if l >= 0 && l < GLint(len(used)) {
@@ -1376,11 +1384,13 @@ func disableUnusedAttribArrays(ctx context.Context, t *tweaker) {
 		}
 	}
 
-	for l, arr := range t.c.Bound().VertexArray().VertexAttributeArrays().All() {
+	unused := []AttributeLocation{}
+	for l, arr := range c.Bound().VertexArray().VertexAttributeArrays().All() {
 		if arr.Enabled() == GLboolean_GL_TRUE && l < AttributeLocation(len(used)) && !used[l] {
-			t.glDisableVertexAttribArray(ctx, l)
+			unused = append(unused, l)
 		}
 	}
+	return unused
 }
 
 // It is a no-op to delete objects that do not exist.
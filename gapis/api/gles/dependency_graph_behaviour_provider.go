@@ -29,12 +29,14 @@ type uniformKey struct {
 }
 
 func (k uniformKey) Parent() dependencygraph.StateKey { return uniformGroupKey{k.program} }
+func (k uniformKey) Resource() api.Resource           { return programResource(k.program) }
 
 type uniformGroupKey struct {
 	program Programʳ
 }
 
 func (k uniformGroupKey) Parent() dependencygraph.StateKey { return nil }
+func (k uniformGroupKey) Resource() api.Resource           { return programResource(k.program) }
 
 type vertexAttribKey struct {
 	vertexArray VertexArrayʳ
@@ -77,6 +79,7 @@ type textureDataKey struct {
 func (k textureDataKey) Parent() dependencygraph.StateKey {
 	return textureDataGroupKey{k.texture, k.id}
 }
+func (k textureDataKey) Resource() api.Resource { return textureResource(k.texture) }
 
 // represents data for all levels and layers in texture
 type textureDataGroupKey struct {
@@ -85,6 +88,7 @@ type textureDataGroupKey struct {
 }
 
 func (k textureDataGroupKey) Parent() dependencygraph.StateKey { return nil }
+func (k textureDataGroupKey) Resource() api.Resource           { return textureResource(k.texture) }
 
 type textureSizeKey struct {
 	texture Textureʳ
@@ -107,6 +111,22 @@ type eglImageSizeKey struct {
 
 func (k eglImageSizeKey) Parent() dependencygraph.StateKey { return nil }
 
+// programResource returns the program p as a resource, or nil if p is nil.
+func programResource(p Programʳ) api.Resource {
+	if p.IsNil() {
+		return nil
+	}
+	return p
+}
+
+// textureResource returns the texture t as a resource, or nil if t is nil.
+func textureResource(t Textureʳ) api.Resource {
+	if t.IsNil() {
+		return nil
+	}
+	return t
+}
+
 type GlesDependencyGraphBehaviourProvider struct{}
 
 func newGlesDependencyGraphBehaviourProvider() *GlesDependencyGraphBehaviourProvider {
@@ -126,7 +146,6 @@ func newGlesDependencyGraphBehaviourProvider() *GlesDependencyGraphBehaviourProv
 // Reads: For each state write, all commands that could possibly read it must be
 // implemented. This makes it more difficult to do only partial implementations.
 // It is fine to overestimate reads, or to read parent state (i.e. superset).
-//
 func (*GlesDependencyGraphBehaviourProvider) GetBehaviourForCommand(
 	ctx context.Context, s *api.GlobalState, id api.CmdID, cmd api.Cmd, g *dependencygraph.DependencyGraph) dependencygraph.CmdBehaviour {
 	b := dependencygraph.CmdBehaviour{}
@@ -271,3 +271,9 @@ func (API) Mesh(ctx context.Context, o interface{}, p *path.Mesh, r *path.Resolv
 func (API) GetDependencyGraphBehaviourProvider(ctx context.Context) dependencygraph.BehaviourProvider {
 	return newGlesDependencyGraphBehaviourProvider()
 }
+
+// MutateResourceUses implements the api.ResourceUseReporter interface, using
+// the dependency graph behaviour of the command.
+func (API) MutateResourceUses(ctx context.Context, id api.CmdID, cmd api.Cmd, s *api.GlobalState) (reads, writes []api.Resource, err error) {
+	return dependencygraph.ResourceUses(ctx, s, id, cmd, newGlesDependencyGraphBehaviourProvider())
+}
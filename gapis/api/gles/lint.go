@@ -0,0 +1,301 @@
+// Copyright (C) 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gles
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/google/gapid/core/log"
+	"github.com/google/gapid/gapis/api"
+	"github.com/google/gapid/gapis/messages"
+)
+
+const (
+	// lintMaxGetErrorsPerFrame is the number of glGetError calls in a single
+	// frame above which they are reported.
+	lintMaxGetErrorsPerFrame = 16
+	// lintBufferRecreationFrames is the number of consecutive frames a buffer
+	// must be re-created in to be reported.
+	lintBufferRecreationFrames = 3
+)
+
+var _ = api.Linter(API{})
+
+// Lint returns a new lint pass for GLES commands.
+// Implements api.Linter.
+func (API) Lint(ctx context.Context, report api.LintReporter) api.LintPass {
+	return &lint{
+		report:         report,
+		buffers:        map[lintBuffer]*lintBufferData{},
+		pendingClears:  map[lintFramebuffer]lintClear{},
+		unusedReported: map[lintAttribute]bool{},
+	}
+}
+
+// lint is the api.LintPass reporting redundant state changes, rebinding of
+// bound objects, buffers re-created every frame, frequent glGetError calls,
+// unused vertex attribute arrays and clears repeated, or overwritten by a
+// blit, before the framebuffer is drawn to or read from.
+type lint struct {
+	report api.LintReporter
+	frame  uint64
+
+	getErrors     uint64
+	firstGetError api.CmdID
+
+	buffers        map[lintBuffer]*lintBufferData
+	pendingClears  map[lintFramebuffer]lintClear
+	unusedReported map[lintAttribute]bool
+}
+
+type lintBuffer struct {
+	context ContextID
+	buffer  BufferId
+}
+
+type lintBufferData struct {
+	lastFrame uint64
+	frames    uint64
+	reported  bool
+}
+
+type lintFramebuffer struct {
+	context     ContextID
+	framebuffer FramebufferId
+}
+
+type lintClear struct {
+	id   api.CmdID
+	mask GLbitfield
+}
+
+type lintAttribute struct {
+	context  ContextID
+	program  ProgramId
+	location AttributeLocation
+}
+
+func (l *lint) redundantState(id api.CmdID, cmd api.Cmd) {
+	l.report(id, log.Warning, messages.WarnRedundantStateChange(cmd.CmdName()))
+}
+
+func (l *lint) redundantBind(id api.CmdID, cmd api.Cmd, object string, name interface{}) {
+	l.report(id, log.Warning, messages.WarnRedundantBind(cmd.CmdName(), fmt.Sprintf("%v %v", object, name)))
+}
+
+// PreMutate implements api.LintPass.
+func (l *lint) PreMutate(ctx context.Context, id api.CmdID, cmd api.Cmd, s *api.GlobalState) {
+	if a := cmd.API(); a == nil || a.ID() != ID {
+		return
+	}
+	c := GetContext(s, cmd.Thread())
+	if c.IsNil() || !c.Other().Initialized() {
+		return
+	}
+
+	// The state queries below must not report errors for the command, as the
+	// command's mutation reports them.
+	newMessage, onError := s.NewMessage, s.OnError
+	s.NewMessage, s.OnError = nil, nil
+	defer func() { s.NewMessage, s.OnError = newMessage, onError }()
+
+	flags := cmd.CmdFlags(ctx, id, s)
+	if flags.IsDrawCall() {
+		l.lintDraw(id, c)
+	}
+	if flags.IsDrawCall() || flags.IsEndOfFrame() {
+		l.pendingClears = map[lintFramebuffer]lintClear{}
+	}
+
+	switch cmd := cmd.(type) {
+	case *GlUseProgram:
+		if p := c.Bound().Program(); (p.IsNil() && cmd.Program() == 0) ||
+			(!p.IsNil() && p.ID() == cmd.Program()) {
+			l.redundantBind(id, cmd, "program", cmd.Program())
+		}
+
+	case *GlBindVertexArray:
+		if c.Bound().VertexArray().ID() == cmd.Array() {
+			l.redundantBind(id, cmd, "vertex array", cmd.Array())
+		}
+
+	case *GlBindTexture:
+		t, err := subGetBoundTextureOrErrorInvalidEnum(ctx, cmd, id, nil, s, GetState(s), cmd.Thread(), nil, nil, cmd.Target())
+		if err == nil && !t.IsNil() && t.ID() == cmd.Texture() {
+			l.redundantBind(id, cmd, "texture", cmd.Texture())
+		}
+
+	case *GlBindBuffer:
+		b, err := subGetBoundBuffer(ctx, cmd, id, nil, s, GetState(s), cmd.Thread(), nil, nil, cmd.Target())
+		if err == nil && ((b.IsNil() && cmd.Buffer() == 0) || (!b.IsNil() && b.ID() == cmd.Buffer())) {
+			l.redundantBind(id, cmd, "buffer", cmd.Buffer())
+		}
+
+	case *GlBindFramebuffer:
+		draw, read := c.Bound().DrawFramebuffer(), c.Bound().ReadFramebuffer()
+		isBound := func(fb Framebufferʳ) bool { return !fb.IsNil() && fb.ID() == cmd.Framebuffer() }
+		redundant := false
+		switch cmd.Target() {
+		case GLenum_GL_FRAMEBUFFER:
+			redundant = isBound(draw) && isBound(read)
+		case GLenum_GL_DRAW_FRAMEBUFFER:
+			redundant = isBound(draw)
+		case GLenum_GL_READ_FRAMEBUFFER:
+			redundant = isBound(read)
+		}
+		if redundant {
+			l.redundantBind(id, cmd, "framebuffer", cmd.Framebuffer())
+		}
+
+	case *GlEnable:
+		if v, err := subGetCapability(ctx, cmd, id, nil, s, GetState(s), cmd.Thread(), nil, nil, cmd.Capability(), 0); err == nil && v == GLboolean_GL_TRUE {
+			l.redundantState(id, cmd)
+		}
+
+	case *GlDisable:
+		if v, err := subGetCapability(ctx, cmd, id, nil, s, GetState(s), cmd.Thread(), nil, nil, cmd.Capability(), 0); err == nil && v == GLboolean_GL_FALSE {
+			l.redundantState(id, cmd)
+		}
+
+	case *GlViewport:
+		if c.Rasterization().Viewport().EqualTo(cmd.X(), cmd.Y(), cmd.Width(), cmd.Height()) {
+			l.redundantState(id, cmd)
+		}
+
+	case *GlBufferData:
+		b, err := subGetBoundBuffer(ctx, cmd, id, nil, s, GetState(s), cmd.Thread(), nil, nil, cmd.Target())
+		if err != nil || b.IsNil() {
+			break
+		}
+		key := lintBuffer{c.Identifier(), b.ID()}
+		data, ok := l.buffers[key]
+		switch {
+		case !ok:
+			l.buffers[key] = &lintBufferData{lastFrame: l.frame, frames: 1}
+		case data.lastFrame+1 == l.frame:
+			data.lastFrame, data.frames = l.frame, data.frames+1
+			if data.frames >= lintBufferRecreationFrames && !data.reported {
+				data.reported = true
+				l.report(id, log.Warning, messages.WarnBufferRecreatedEveryFrame(
+					fmt.Sprint(b.ID()), cmd.CmdName(), data.frames))
+			}
+		case data.lastFrame != l.frame:
+			data.lastFrame, data.frames = l.frame, 1
+		}
+
+	case *GlGetError:
+		if l.getErrors == 0 {
+			l.firstGetError = id
+		}
+		l.getErrors++
+
+	case *GlClear:
+		fb := c.Bound().DrawFramebuffer()
+		if fb.IsNil() {
+			break
+		}
+		key := lintFramebuffer{c.Identifier(), fb.ID()}
+		scissor, err := subGetCapability(ctx, cmd, id, nil, s, GetState(s), cmd.Thread(), nil, nil, GLenum_GL_SCISSOR_TEST, 0)
+		if err != nil || scissor == GLboolean_GL_TRUE {
+			delete(l.pendingClears, key)
+			break
+		}
+		if prev, ok := l.pendingClears[key]; ok && prev.mask&^cmd.Mask() == 0 {
+			l.report(prev.id, log.Warning, messages.WarnRepeatedClear(cmd.CmdName()))
+		}
+		l.pendingClears[key] = lintClear{id, cmd.Mask()}
+
+	case *GlBlitFramebuffer:
+		l.lintBlit(ctx, id, cmd, c, s)
+
+	case *GlReadPixels, *GlCopyTexImage2D, *GlCopyTexSubImage2D, *GlCopyTexSubImage3D:
+		// These read the framebuffer, making any pending clear visible.
+		l.pendingClears = map[lintFramebuffer]lintClear{}
+	}
+}
+
+// PostMutate implements api.LintPass.
+func (l *lint) PostMutate(ctx context.Context, id api.CmdID, cmd api.Cmd, s *api.GlobalState) {
+	if a := cmd.API(); a == nil || a.ID() != ID || !cmd.CmdFlags(ctx, id, s).IsEndOfFrame() {
+		return
+	}
+	if l.getErrors > lintMaxGetErrorsPerFrame {
+		l.report(l.firstGetError, log.Warning, messages.WarnFrequentGetError("glGetError", l.getErrors))
+	}
+	l.getErrors = 0
+	l.frame++
+}
+
+// lintBlit reports the pending clear of the draw framebuffer if the blit
+// overwrites all of the cleared buffers. The blit reads the read framebuffer,
+// making its pending clear visible.
+func (l *lint) lintBlit(ctx context.Context, id api.CmdID, cmd *GlBlitFramebuffer, c Contextʳ, s *api.GlobalState) {
+	read, draw := c.Bound().ReadFramebuffer(), c.Bound().DrawFramebuffer()
+	if !read.IsNil() {
+		delete(l.pendingClears, lintFramebuffer{c.Identifier(), read.ID()})
+	}
+	if draw.IsNil() {
+		return
+	}
+	key := lintFramebuffer{c.Identifier(), draw.ID()}
+	prev, ok := l.pendingClears[key]
+	if !ok {
+		return
+	}
+	delete(l.pendingClears, key)
+	scissor, err := subGetCapability(ctx, cmd, id, nil, s, GetState(s), cmd.Thread(), nil, nil, GLenum_GL_SCISSOR_TEST, 0)
+	if err != nil || scissor == GLboolean_GL_TRUE || prev.mask&^cmd.Mask() != 0 {
+		return
+	}
+	width, height, ok := framebufferSize(s, cmd.Thread(), draw.ID())
+	covers := func(a, b GLint, size uint32) bool {
+		if a > b {
+			a, b = b, a
+		}
+		return a <= 0 && int64(b) >= int64(size)
+	}
+	if ok && covers(cmd.DstX0(), cmd.DstX1(), width) && covers(cmd.DstY0(), cmd.DstY1(), height) {
+		l.report(prev.id, log.Warning, messages.WarnOverwrittenClear(cmd.CmdName()))
+	}
+}
+
+// framebufferSize returns the size of the first attachment of the framebuffer.
+func framebufferSize(s *api.GlobalState, thread uint64, fb FramebufferId) (width, height uint32, ok bool) {
+	for _, att := range []GLenum{GLenum_GL_COLOR_ATTACHMENT0, GLenum_GL_DEPTH_ATTACHMENT, GLenum_GL_STENCIL_ATTACHMENT} {
+		if info, err := GetState(s).getFramebufferAttachmentInfo(thread, fb, att); err == nil {
+			return info.width, info.height, true
+		}
+	}
+	return 0, 0, false
+}
+
+// lintDraw reports the enabled vertex attribute arrays not used by the program
+// of the draw call with the given identifier. Each array is only reported once
+// per program.
+func (l *lint) lintDraw(id api.CmdID, c Contextʳ) {
+	p := c.Bound().Program()
+	if p.IsNil() {
+		return
+	}
+	for _, loc := range unusedAttribArrays(c) {
+		key := lintAttribute{c.Identifier(), p.ID(), loc}
+		if l.unusedReported[key] {
+			continue
+		}
+		l.unusedReported[key] = true
+		l.report(id, log.Warning, messages.WarnUnusedVertexAttribute(uint64(loc), uint64(p.ID())))
+	}
+}
@@ -0,0 +1,176 @@
+// Copyright (C) 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gles_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/gapid/core/assert"
+	"github.com/google/gapid/core/log"
+	"github.com/google/gapid/core/memory/arena"
+	"github.com/google/gapid/core/os/device"
+	"github.com/google/gapid/core/os/device/bind"
+	"github.com/google/gapid/gapis/api"
+	"github.com/google/gapid/gapis/api/gles"
+	"github.com/google/gapid/gapis/capture"
+	"github.com/google/gapid/gapis/database"
+	"github.com/google/gapid/gapis/memory"
+	"github.com/google/gapid/gapis/stringtable"
+)
+
+type lintIssue struct {
+	id  api.CmdID
+	msg string
+}
+
+func TestLint(t *testing.T) {
+	ctx := log.Testing(t)
+	ctx = bind.PutRegistry(ctx, bind.NewRegistry())
+	ctx = database.Put(ctx, database.NewInMemory(ctx))
+
+	a := arena.New()
+	defer a.Dispose()
+
+	programInfo := gles.MakeLinkProgramExtra(a)
+	programInfo.SetLinkStatus(gles.GLboolean_GL_TRUE)
+	programInfo.SetActiveResources(gles.MakeActiveProgramResourcesʳ(a))
+
+	ctxHandle := memory.BytePtr(1)
+	displayHandle := memory.BytePtr(2)
+	surfaceHandle := memory.BytePtr(3)
+	cb := gles.CommandBuilder{Thread: 0, Arena: a}
+	prologue := []api.Cmd{
+		cb.EglCreateContext(displayHandle, surfaceHandle, surfaceHandle, memory.Nullptr, ctxHandle),
+		api.WithExtras(
+			cb.EglMakeCurrent(displayHandle, surfaceHandle, surfaceHandle, ctxHandle, 0),
+			gles.NewStaticContextStateForTest(a), gles.NewDynamicContextStateForTest(a, 64, 64, false)),
+		cb.GlCreateProgram(1),
+		api.WithExtras(cb.GlLinkProgram(1), programInfo),
+	}
+
+	color := gles.GLbitfield_GL_COLOR_BUFFER_BIT
+	colorDepth := gles.GLbitfield_GL_COLOR_BUFFER_BIT | gles.GLbitfield_GL_DEPTH_BUFFER_BIT
+	getErrors := make([]api.Cmd, 17)
+	for i := range getErrors {
+		getErrors[i] = cb.GlGetError(0)
+	}
+
+	// drawFramebuffer binds a 64x64 framebuffer object for drawing, leaving
+	// the default framebuffer bound for reading.
+	drawFramebuffer := []api.Cmd{
+		cb.GlBindRenderbuffer(gles.GLenum_GL_RENDERBUFFER, 1),
+		cb.GlRenderbufferStorage(gles.GLenum_GL_RENDERBUFFER, gles.GLenum_GL_RGBA8, 64, 64),
+		cb.GlBindFramebuffer(gles.GLenum_GL_DRAW_FRAMEBUFFER, 1),
+		cb.GlFramebufferRenderbuffer(gles.GLenum_GL_DRAW_FRAMEBUFFER, gles.GLenum_GL_COLOR_ATTACHMENT0,
+			gles.GLenum_GL_RENDERBUFFER, 1),
+	}
+
+	for _, test := range []struct {
+		name     string
+		cmds     []api.Cmd
+		expected []lintIssue
+	}{
+		{"redundant program bind", []api.Cmd{
+			cb.GlUseProgram(1),
+			cb.GlUseProgram(1),
+			cb.GlUseProgram(0),
+		}, []lintIssue{{1, "WARN_REDUNDANT_BIND"}}},
+		{"redundant state", []api.Cmd{
+			cb.GlEnable(gles.GLenum_GL_BLEND),
+			cb.GlEnable(gles.GLenum_GL_BLEND),
+			cb.GlDisable(gles.GLenum_GL_BLEND),
+			cb.GlViewport(0, 0, 32, 32),
+			cb.GlViewport(0, 0, 32, 32),
+		}, []lintIssue{{1, "WARN_REDUNDANT_STATE_CHANGE"}, {4, "WARN_REDUNDANT_STATE_CHANGE"}}},
+		{"repeated clear", []api.Cmd{
+			cb.GlClear(color),
+			cb.GlClear(colorDepth),
+		}, []lintIssue{{0, "WARN_REPEATED_CLEAR"}}},
+		{"partially repeated clear", []api.Cmd{
+			cb.GlClear(colorDepth),
+			cb.GlClear(color),
+		}, []lintIssue{}},
+		{"clear drawn to", []api.Cmd{
+			cb.GlClear(color),
+			cb.GlDrawArrays(gles.GLenum_GL_TRIANGLES, 0, 0),
+			cb.GlClear(color),
+		}, []lintIssue{}},
+		{"scissored clear", []api.Cmd{
+			cb.GlClear(color),
+			cb.GlEnable(gles.GLenum_GL_SCISSOR_TEST),
+			cb.GlClear(color),
+		}, []lintIssue{}},
+		{"clear overwritten by blit", append(append([]api.Cmd{}, drawFramebuffer...),
+			cb.GlClear(color),
+			cb.GlBlitFramebuffer(0, 0, 64, 64, 0, 0, 64, 64, color, gles.GLenum_GL_NEAREST),
+		), []lintIssue{{4, "WARN_OVERWRITTEN_CLEAR"}}},
+		{"flipped blit", append(append([]api.Cmd{}, drawFramebuffer...),
+			cb.GlClear(color),
+			cb.GlBlitFramebuffer(0, 0, 64, 64, 64, 64, 0, 0, color, gles.GLenum_GL_NEAREST),
+		), []lintIssue{{4, "WARN_OVERWRITTEN_CLEAR"}}},
+		{"partial blit", append(append([]api.Cmd{}, drawFramebuffer...),
+			cb.GlClear(color),
+			cb.GlBlitFramebuffer(0, 0, 32, 32, 0, 0, 32, 32, color, gles.GLenum_GL_NEAREST),
+		), []lintIssue{}},
+		{"blit of other buffers", append(append([]api.Cmd{}, drawFramebuffer...),
+			cb.GlClear(colorDepth),
+			cb.GlBlitFramebuffer(0, 0, 64, 64, 0, 0, 64, 64, color, gles.GLenum_GL_NEAREST),
+		), []lintIssue{}},
+		{"blit reading the clear", []api.Cmd{
+			cb.GlClear(color),
+			cb.GlBlitFramebuffer(0, 0, 64, 64, 0, 0, 64, 64, color, gles.GLenum_GL_NEAREST),
+		}, []lintIssue{}},
+		{"frequent glGetError", append(getErrors,
+			cb.EglSwapBuffers(displayHandle, surfaceHandle, gles.EGLBoolean(1)),
+			cb.GlGetError(0),
+		), []lintIssue{{0, "WARN_FREQUENT_GET_ERROR"}}},
+	} {
+		issues := lintCmds(ctx, a, prologue, test.cmds)
+		assert.For(ctx, "%v", test.name).ThatSlice(issues).Equals(test.expected)
+	}
+}
+
+// lintCmds mutates the commands of prologue followed by cmds with a GLES lint
+// pass, and returns the issues reported for cmds, indexed from the first
+// command of cmds.
+func lintCmds(ctx context.Context, a arena.Arena, prologue, cmds []api.Cmd) []lintIssue {
+	all := append(append([]api.Cmd{}, prologue...), cmds...)
+	h := &capture.Header{ABI: device.WindowsX86_64}
+	p, err := capture.New(ctx, a, "lint", h, nil, all)
+	if err != nil {
+		panic(err)
+	}
+	ctx = capture.Put(ctx, p)
+	c, err := capture.Resolve(ctx)
+	if err != nil {
+		panic(err)
+	}
+
+	issues := []lintIssue{}
+	l := gles.API{}.Lint(ctx, func(id api.CmdID, severity log.Severity, msg *stringtable.Msg) {
+		issues = append(issues, lintIssue{id - api.CmdID(len(prologue)), msg.Identifier})
+	})
+	s := c.NewState(ctx)
+	api.ForeachCmd(ctx, all, func(ctx context.Context, id api.CmdID, cmd api.Cmd) error {
+		l.PreMutate(ctx, id, cmd, s)
+		if err := cmd.Mutate(ctx, id, s, nil, nil); err != nil {
+			log.W(ctx, "Command %v %v: %v", id, cmd, err)
+		}
+		l.PostMutate(ctx, id, cmd, s)
+		return nil
+	})
+	return issues
+}
@@ -0,0 +1,93 @@
+// Copyright (C) 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gles
+
+import (
+	"sort"
+
+	"github.com/google/gapid/core/data/id"
+	"github.com/google/gapid/gapis/api"
+	"github.com/google/gapid/gapis/service/path"
+)
+
+// Interface compliance test
+var (
+	_ = api.MemoryBreakdownProvider(API{})
+)
+
+// MemoryBreakdown implements api.MemoryBreakdownProvider.
+// GLES does not expose the memory backing its objects, so each buffer and
+// texture alive in the state is reported as an allocation of the size of its
+// data. Objects shared between contexts are reported once.
+func (API) MemoryBreakdown(st *api.GlobalState) (*api.MemoryBreakdown, error) {
+	s := GetState(st)
+
+	buffers := map[Bufferʳ]struct{}{}
+	textures := map[Textureʳ]struct{}{}
+	for _, c := range s.EGLContexts().All() {
+		for _, b := range c.Objects().Buffers().All() {
+			if b.IsResource() {
+				buffers[b] = struct{}{}
+			}
+		}
+		for _, t := range c.Objects().Textures().All() {
+			if t.IsResource() {
+				textures[t] = struct{}{}
+			}
+		}
+	}
+
+	allocations := make([]*api.MemoryAllocation, 0, len(buffers)+len(textures))
+	for b := range buffers {
+		allocations = append(allocations, &api.MemoryAllocation{
+			Handle: uint64(b.ID()),
+			Name:   b.ResourceHandle(),
+			Size:   uint64(b.Size()),
+		})
+	}
+	sort.Slice(allocations, func(i, j int) bool { return allocations[i].Handle < allocations[j].Handle })
+
+	textureAllocations := make([]*api.MemoryAllocation, 0, len(textures))
+	for t := range textures {
+		textureAllocations = append(textureAllocations, &api.MemoryAllocation{
+			Handle: uint64(t.ID()),
+			Name:   t.ResourceHandle(),
+			Size:   t.dataSize(),
+		})
+	}
+	sort.Slice(textureAllocations, func(i, j int) bool {
+		return textureAllocations[i].Handle < textureAllocations[j].Handle
+	})
+
+	return &api.MemoryBreakdown{
+		API:                  path.NewAPI(id.ID(ID)),
+		Allocations:          append(allocations, textureAllocations...),
+		AllocationFlagsIndex: -1,
+	}, nil
+}
+
+// dataSize returns the number of bytes of the images of all the levels and
+// layers of the texture.
+func (t Textureʳ) dataSize() uint64 {
+	size := uint64(0)
+	for _, level := range t.Levels().All() {
+		for _, layer := range level.Layers().All() {
+			if !layer.IsNil() {
+				size += uint64(layer.Data().Size())
+			}
+		}
+	}
+	return size
+}
@@ -221,12 +221,137 @@ func (t Textureʳ) SetResourceData(
 	ctx context.Context,
 	at *path.Command,
 	data *api.ResourceData,
-	resources api.ResourceMap,
+	resourceIDs api.ResourceMap,
 	edits api.ReplaceCallback,
 	mutate api.MutateInitialState,
 	r *path.ResolveConfig) error {
 
-	return fmt.Errorf("SetResourceData is not supported for Texture")
+	cmdIdx := at.Indices[0]
+	if len(at.Indices) > 1 {
+		return fmt.Errorf("Subcommands currently not supported for GLES resources") // TODO: Subcommands
+	}
+
+	texture := data.GetTexture().GetTexture_2D()
+	if texture == nil {
+		return fmt.Errorf("SetResourceData is only supported for 2D textures")
+	}
+
+	resources, err := resolve.Resources(ctx, at.Capture, r)
+	if err != nil {
+		return err
+	}
+	resource, err := resources.Find(t.ResourceType(ctx), resourceIDs[t])
+	if err != nil {
+		return err
+	}
+
+	c, err := capture.ResolveFromPath(ctx, at.Capture)
+	if err != nil {
+		return err
+	}
+
+	// Replace the last command uploading the whole of each level with one
+	// uploading the new data. Levels are uploaded with glTexImage2D, or with
+	// glTexSubImage2D for textures allocated with glTexStorage2D. Compressed
+	// levels cannot be replaced as the new data cannot be compressed.
+	for level, info := range texture.Levels {
+		if info == nil {
+			continue
+		}
+		found := false
+		for j := len(resource.Accesses) - 1; j >= 0 && !found; j-- {
+			i := resource.Accesses[j].Indices[0] // TODO: Subcommands
+			if i > cmdIdx {
+				continue
+			}
+			var cmd api.Cmd
+			switch a := c.Commands[i].(type) {
+			case *GlTexImage2D:
+				if a.Target() != GLenum_GL_TEXTURE_2D || a.Level() != GLint(level) {
+					continue
+				}
+				cmd, err = a.Replace(ctx, c, info)
+			case *GlTexSubImage2D:
+				if a.Target() != GLenum_GL_TEXTURE_2D || a.Level() != GLint(level) {
+					continue
+				}
+				if a.Xoffset() != 0 || a.Yoffset() != 0 ||
+					uint32(a.Width()) != info.Width || uint32(a.Height()) != info.Height {
+					return fmt.Errorf("Level %d of %v is partially updated by command %d",
+						level, t.ResourceHandle(), i)
+				}
+				cmd, err = a.Replace(ctx, c, info)
+			case *GlCompressedTexImage2D:
+				if a.Target() == GLenum_GL_TEXTURE_2D && a.Level() == GLint(level) {
+					return fmt.Errorf("Level %d of %v is compressed", level, t.ResourceHandle())
+				}
+				continue
+			case *GlCompressedTexSubImage2D:
+				if a.Target() == GLenum_GL_TEXTURE_2D && a.Level() == GLint(level) {
+					return fmt.Errorf("Level %d of %v is compressed", level, t.ResourceHandle())
+				}
+				continue
+			default:
+				continue
+			}
+			if err != nil {
+				return err
+			}
+			edits(uint64(i), cmd)
+			found = true
+		}
+		if !found {
+			return fmt.Errorf("No command uploads the whole of level %d of %v", level, t.ResourceHandle())
+		}
+	}
+	return nil
+}
+
+// Replace returns a copy of the glTexImage2D command uploading the image data
+// instead of the original data. The data is converted to the format and type
+// of the original command, and is expected to be read with the default unpack
+// state and no pixel unpack buffer bound.
+func (a *GlTexImage2D) Replace(ctx context.Context, c *capture.Capture, data *image.Info) (api.Cmd, error) {
+	state, pixels, err := replacementPixels(ctx, c, a.Format(), a.Type(), data)
+	if err != nil {
+		return nil, err
+	}
+	cb := CommandBuilder{Thread: a.Thread(), Arena: state.Arena}
+	return cb.GlTexImage2D(a.Target(), a.Level(), a.Internalformat(), a.Width(), a.Height(),
+		a.Border(), a.Format(), a.Type(), pixels.Ptr()).
+		AddRead(pixels.Data()), nil
+}
+
+// Replace returns a copy of the glTexSubImage2D command uploading the image
+// data instead of the original data, with the same expectations as
+// GlTexImage2D.Replace.
+func (a *GlTexSubImage2D) Replace(ctx context.Context, c *capture.Capture, data *image.Info) (api.Cmd, error) {
+	state, pixels, err := replacementPixels(ctx, c, a.Format(), a.Type(), data)
+	if err != nil {
+		return nil, err
+	}
+	cb := CommandBuilder{Thread: a.Thread(), Arena: state.Arena}
+	return cb.GlTexSubImage2D(a.Target(), a.Level(), a.Xoffset(), a.Yoffset(), a.Width(), a.Height(),
+		a.Format(), a.Type(), pixels.Ptr()).
+		AddRead(pixels.Data()), nil
+}
+
+// replacementPixels converts data to the given format and type, and allocates
+// it in a new state of the capture c.
+func replacementPixels(ctx context.Context, c *capture.Capture, format, ty GLenum, data *image.Info) (*api.GlobalState, api.AllocResult, error) {
+	f, err := getImageFormat(format, ty)
+	if err != nil {
+		return nil, api.AllocResult{}, err
+	}
+	if data, err = data.Convert(ctx, f); err != nil {
+		return nil, api.AllocResult{}, err
+	}
+	img, err := data.Data(ctx)
+	if err != nil {
+		return nil, api.AllocResult{}, err
+	}
+	state := c.NewState(ctx)
+	return state, state.AllocDataOrPanic(ctx, img.Bytes), nil
 }
 
 // ImageInfo returns the Image as a image.Info.
@@ -637,3 +762,122 @@ func (p Programʳ) SetResourceData(
 
 	return fmt.Errorf("SetResourceData is not supported for Program")
 }
+
+var _ api.Resource = Bufferʳ{}
+
+// IsResource returns true if this instance should be considered as a resource.
+func (b Bufferʳ) IsResource() bool {
+	return b.ID() != 0
+}
+
+// ResourceHandle returns the UI identity for the resource.
+func (b Bufferʳ) ResourceHandle() string {
+	return fmt.Sprintf("Buffer<%d>", b.ID())
+}
+
+// ResourceLabel returns an optional debug label for the resource.
+func (b Bufferʳ) ResourceLabel() string {
+	return b.Label()
+}
+
+// Order returns an integer used to sort the resources for presentation.
+func (b Bufferʳ) Order() uint64 {
+	return uint64(b.ID())
+}
+
+// ResourceType returns the type of this resource.
+func (b Bufferʳ) ResourceType(ctx context.Context) api.ResourceType {
+	return api.ResourceType_BufferResource
+}
+
+// ResourceData returns the resource data given the current state.
+func (b Bufferʳ) ResourceData(ctx context.Context, s *api.GlobalState) (*api.ResourceData, error) {
+	out := &api.Buffer{Size: uint64(b.Size())}
+	if b.Data().Size() > 0 {
+		out.Data = path.NewID(b.Data().ResourceID(ctx, s))
+	}
+	return api.NewResourceData(out), nil
+}
+
+// SetResourceData sets resource data in a new capture.
+func (b Bufferʳ) SetResourceData(
+	ctx context.Context,
+	at *path.Command,
+	data *api.ResourceData,
+	resourceIDs api.ResourceMap,
+	edits api.ReplaceCallback,
+	mutate api.MutateInitialState,
+	r *path.ResolveConfig) error {
+
+	cmdIdx := at.Indices[0]
+	if len(at.Indices) > 1 {
+		return fmt.Errorf("Subcommands currently not supported for GLES resources") // TODO: Subcommands
+	}
+
+	buffer := data.GetBuffer()
+	if buffer == nil {
+		return fmt.Errorf("Expected buffer data for %v, got %T", b.ResourceHandle(), data.Data)
+	}
+	bytes, err := buffer.Bytes(ctx)
+	if err != nil {
+		return err
+	}
+
+	resources, err := resolve.Resources(ctx, at.Capture, r)
+	if err != nil {
+		return err
+	}
+	resource, err := resources.Find(b.ResourceType(ctx), resourceIDs[b])
+	if err != nil {
+		return err
+	}
+
+	c, err := capture.ResolveFromPath(ctx, at.Capture)
+	if err != nil {
+		return err
+	}
+
+	// Replace the last command uploading the whole of the buffer, either the
+	// glBufferData allocating it or a glBufferSubData covering all of it.
+	for j := len(resource.Accesses) - 1; j >= 0; j-- {
+		i := resource.Accesses[j].Indices[0] // TODO: Subcommands
+		if i > cmdIdx {
+			continue
+		}
+		switch a := c.Commands[i].(type) {
+		case *GlBufferData:
+			if uint64(a.Size()) != buffer.Size {
+				return fmt.Errorf("%v is reallocated with %d bytes by command %d", b.ResourceHandle(), a.Size(), i)
+			}
+			edits(uint64(i), a.Replace(ctx, c, bytes))
+			return nil
+		case *GlBufferSubData:
+			if a.Offset() != 0 || uint64(a.Size()) != buffer.Size {
+				return fmt.Errorf("%v is partially updated by command %d", b.ResourceHandle(), i)
+			}
+			edits(uint64(i), a.Replace(ctx, c, bytes))
+			return nil
+		}
+	}
+	return fmt.Errorf("No command uploads the whole of %v", b.ResourceHandle())
+}
+
+// Replace returns a copy of the glBufferData command uploading data instead
+// of the original data.
+func (a *GlBufferData) Replace(ctx context.Context, c *capture.Capture, data []byte) api.Cmd {
+	state := c.NewState(ctx)
+	d := state.AllocDataOrPanic(ctx, data)
+	cb := CommandBuilder{Thread: a.Thread(), Arena: state.Arena}
+	return cb.GlBufferData(a.Target(), a.Size(), d.Ptr(), a.Usage()).
+		AddRead(d.Data())
+}
+
+// Replace returns a copy of the glBufferSubData command uploading data
+// instead of the original data.
+func (a *GlBufferSubData) Replace(ctx context.Context, c *capture.Capture, data []byte) api.Cmd {
+	state := c.NewState(ctx)
+	d := state.AllocDataOrPanic(ctx, data)
+	cb := CommandBuilder{Thread: a.Thread(), Arena: state.Arena}
+	return cb.GlBufferSubData(a.Target(), a.Offset(), a.Size(), d.Ptr()).
+		AddRead(d.Data())
+}
@@ -0,0 +1,45 @@
+// Copyright (C) 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package api
+
+import (
+	"context"
+
+	"github.com/google/gapid/core/log"
+	"github.com/google/gapid/gapis/stringtable"
+)
+
+// Linter is the interface implemented by APIs that can analyze their commands
+// for performance anti-patterns without replaying them.
+type Linter interface {
+	// Lint returns a new LintPass to analyze the commands of a single capture.
+	// Issues found by the pass are reported with report.
+	Lint(ctx context.Context, report LintReporter) LintPass
+}
+
+// LintReporter is the function used by a LintPass to report an issue found
+// with the command with the given identifier. The command may precede the
+// command currently being analyzed.
+type LintReporter func(id CmdID, severity log.Severity, msg *stringtable.Msg)
+
+// LintPass analyzes the commands of a capture, in order.
+// The commands of all the APIs of the capture are passed to the pass.
+type LintPass interface {
+	// PreMutate is called before the command is mutated on s.
+	PreMutate(ctx context.Context, id CmdID, cmd Cmd, s *GlobalState)
+
+	// PostMutate is called after the command has been mutated on s.
+	PostMutate(ctx context.Context, id CmdID, cmd Cmd, s *GlobalState)
+}
@@ -0,0 +1,169 @@
+// Copyright (C) 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package api
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strconv"
+
+	"github.com/google/gapid/core/data/endian"
+	"github.com/google/gapid/core/fault"
+	"github.com/google/gapid/core/log"
+	"github.com/google/gapid/core/os/device"
+	"github.com/google/gapid/core/stream"
+	"github.com/google/gapid/core/stream/fmts"
+	"github.com/google/gapid/gapis/service/path"
+	"github.com/google/gapid/gapis/vertex"
+)
+
+// ErrMeshHasNoPositions is returned when exporting a mesh without a position
+// stream to a format that requires one.
+const ErrMeshHasNoPositions = fault.Const("Mesh has no position stream")
+
+// Export encodes the mesh in the file format f.
+func (m *Mesh) Export(ctx context.Context, f path.MeshExportFormat) ([]byte, error) {
+	switch f {
+	case path.MeshExportFormat_GLTF:
+		return m.GLTF(ctx)
+	case path.MeshExportFormat_OBJ:
+		return m.OBJ(ctx)
+	default:
+		return nil, log.Errf(ctx, nil, "Unsupported mesh export format: %v", f)
+	}
+}
+
+// OBJ encodes the mesh as a Wavefront OBJ file. Only the position stream, the
+// first texture coordinate stream and the normal stream are exported, other
+// streams are ignored.
+func (m *Mesh) OBJ(ctx context.Context) ([]byte, error) {
+	pos := m.stream(vertex.Semantic_Position)
+	if pos == nil {
+		return nil, ErrMeshHasNoPositions
+	}
+	positions, err := floats(ctx, pos, fmts.XYZ_F32)
+	if err != nil {
+		return nil, err
+	}
+	var texcoords, normals []float32
+	if s := m.stream(vertex.Semantic_Texcoord); s != nil {
+		if texcoords, err = floats(ctx, s, fmts.XY_F32); err != nil {
+			return nil, err
+		}
+	}
+	if s := m.stream(vertex.Semantic_Normal); s != nil {
+		if normals, err = floats(ctx, s, fmts.XYZ_F32); err != nil {
+			return nil, err
+		}
+	}
+
+	buf := &bytes.Buffer{}
+	fmt.Fprintln(buf, "# Exported by GAPID")
+	writeFloats := func(prefix string, data []float32, n int) {
+		for i := 0; i+n <= len(data); i += n {
+			buf.WriteString(prefix)
+			for _, f := range data[i : i+n] {
+				buf.WriteByte(' ')
+				buf.WriteString(strconv.FormatFloat(float64(f), 'g', -1, 32))
+			}
+			buf.WriteByte('\n')
+		}
+	}
+	writeFloats("v", positions, 3)
+	writeFloats("vt", texcoords, 2)
+	writeFloats("vn", normals, 3)
+
+	// OBJ indices are 1-based.
+	vert := func(i uint32) string {
+		i++
+		switch {
+		case texcoords != nil && normals != nil:
+			return fmt.Sprintf("%d/%d/%d", i, i, i)
+		case texcoords != nil:
+			return fmt.Sprintf("%d/%d", i, i)
+		case normals != nil:
+			return fmt.Sprintf("%d//%d", i, i)
+		default:
+			return fmt.Sprint(i)
+		}
+	}
+
+	var indices []uint32
+	if m.IndexBuffer != nil {
+		indices = m.IndexBuffer.Indices
+	}
+	switch m.DrawPrimitive {
+	case DrawPrimitive_Points:
+		for _, i := range indices {
+			fmt.Fprintln(buf, "p", vert(i))
+		}
+	case DrawPrimitive_Lines:
+		for i := 0; i+1 < len(indices); i += 2 {
+			fmt.Fprintln(buf, "l", vert(indices[i]), vert(indices[i+1]))
+		}
+	case DrawPrimitive_LineStrip, DrawPrimitive_LineLoop:
+		if len(indices) > 1 {
+			buf.WriteString("l")
+			for _, i := range indices {
+				fmt.Fprint(buf, " ", vert(i))
+			}
+			if m.DrawPrimitive == DrawPrimitive_LineLoop {
+				fmt.Fprint(buf, " ", vert(indices[0]))
+			}
+			buf.WriteByte('\n')
+		}
+	default:
+		for t, n := 0, m.TriangleCount(); t < n; t++ {
+			a, b, c := m.Triangle(t)
+			fmt.Fprintln(buf, "f", vert(a), vert(b), vert(c))
+		}
+	}
+	return buf.Bytes(), nil
+}
+
+// stream returns the vertex stream with the semantic type t and the lowest
+// semantic index, or nil if the mesh has no such stream.
+func (m *Mesh) stream(t vertex.Semantic_Type) *vertex.Stream {
+	var out *vertex.Stream
+	for _, s := range m.VertexBuffer.GetStreams() {
+		if s.Semantic.GetType() != t {
+			continue
+		}
+		if out == nil || s.Semantic.Index < out.Semantic.Index {
+			out = s
+		}
+	}
+	return out
+}
+
+// floats returns the data of the stream s converted to f, which must only
+// have 32 bit float components.
+func floats(ctx context.Context, s *vertex.Stream, f *stream.Format) ([]float32, error) {
+	data, err := stream.Convert(f, s.Format, s.Data)
+	if err != nil {
+		return nil, log.Errf(ctx, err, "Couldn't convert stream '%v'", s.Name)
+	}
+	return bytesToFloats(data), nil
+}
+
+func bytesToFloats(data []byte) []float32 {
+	r := endian.Reader(bytes.NewReader(data), device.LittleEndian)
+	out := make([]float32, len(data)/4)
+	for i := range out {
+		out[i] = r.Float32()
+	}
+	return out
+}
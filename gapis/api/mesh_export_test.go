@@ -0,0 +1,146 @@
+// Copyright (C) 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package api_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/google/gapid/core/assert"
+	"github.com/google/gapid/core/data/endian"
+	"github.com/google/gapid/core/log"
+	"github.com/google/gapid/core/os/device"
+	"github.com/google/gapid/core/stream/fmts"
+	"github.com/google/gapid/gapis/api"
+	"github.com/google/gapid/gapis/service/path"
+	"github.com/google/gapid/gapis/vertex"
+)
+
+func testMesh() *api.Mesh {
+	positions := &bytes.Buffer{}
+	w := endian.Writer(positions, device.LittleEndian)
+	for _, f := range []float32{0, 0, 0, 1, 0, 0, 0, 2, -1} {
+		w.Float32(f)
+	}
+	weights := &bytes.Buffer{}
+	w = endian.Writer(weights, device.LittleEndian)
+	for _, u := range []uint32{1, 2, 3} {
+		w.Uint32(u)
+	}
+	return &api.Mesh{
+		DrawPrimitive: api.DrawPrimitive_Triangles,
+		VertexBuffer: &vertex.Buffer{Streams: []*vertex.Stream{
+			{
+				Name:     "a_position",
+				Data:     positions.Bytes(),
+				Format:   fmts.XYZ_F32,
+				Semantic: &vertex.Semantic{Type: vertex.Semantic_Position},
+			}, {
+				Name:     "a_uv",
+				Data:     []byte{0, 0, 255, 0, 0, 255},
+				Format:   fmts.XY_U8_NORM,
+				Semantic: &vertex.Semantic{Type: vertex.Semantic_Texcoord},
+			}, {
+				Name:     "a_weights",
+				Data:     weights.Bytes(),
+				Format:   fmts.X_U32,
+				Semantic: &vertex.Semantic{Type: vertex.Semantic_Unknown},
+			},
+		}},
+		IndexBuffer: &api.IndexBuffer{Indices: []uint32{0, 1, 2}},
+	}
+}
+
+func TestMeshGLTF(t *testing.T) {
+	ctx := log.Testing(t)
+
+	data, err := testMesh().Export(ctx, path.MeshExportFormat_GLTF)
+	if !assert.For(ctx, "Export").ThatError(err).Succeeded() {
+		return
+	}
+
+	doc := struct {
+		Meshes []struct {
+			Primitives []struct {
+				Attributes map[string]int
+				Indices    int
+				Mode       int
+			}
+		}
+		BufferViews []struct {
+			ByteOffset int
+			ByteLength int
+			ByteStride int
+		}
+		Accessors []struct {
+			BufferView    int
+			ComponentType int
+			Normalized    bool
+			Count         int
+			Type          string
+			Min, Max      []float32
+		}
+	}{}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		t.Fatal(err)
+	}
+	prim := doc.Meshes[0].Primitives[0]
+	assert.For(ctx, "mode").That(prim.Mode).Equals(4)
+	assert.For(ctx, "attributes").That(prim.Attributes).DeepEquals(map[string]int{
+		"POSITION":   0,
+		"TEXCOORD_0": 1,
+		"_A_WEIGHTS": 2,
+	})
+	assert.For(ctx, "indices").That(prim.Indices).Equals(3)
+
+	pos, uv, weights, indices := doc.Accessors[0], doc.Accessors[1], doc.Accessors[2], doc.Accessors[3]
+	assert.For(ctx, "position type").That(pos.Type).Equals("VEC3")
+	assert.For(ctx, "position min").ThatSlice(pos.Min).Equals([]float32{0, 0, -1})
+	assert.For(ctx, "position max").ThatSlice(pos.Max).Equals([]float32{1, 2, 0})
+	assert.For(ctx, "uv component").That(uv.ComponentType).Equals(5121)
+	assert.For(ctx, "uv normalized").That(uv.Normalized).Equals(true)
+	assert.For(ctx, "uv stride").That(doc.BufferViews[uv.BufferView].ByteStride).Equals(4)
+	assert.For(ctx, "weights component").That(weights.ComponentType).Equals(5126)
+	assert.For(ctx, "weights type").That(weights.Type).Equals("SCALAR")
+	assert.For(ctx, "indices component").That(indices.ComponentType).Equals(5123)
+	assert.For(ctx, "indices count").That(indices.Count).Equals(3)
+	for i, v := range doc.BufferViews {
+		assert.For(ctx, "view %d offset", i).That(v.ByteOffset % 4).Equals(0)
+	}
+}
+
+func TestMeshOBJ(t *testing.T) {
+	ctx := log.Testing(t)
+
+	data, err := testMesh().Export(ctx, path.MeshExportFormat_OBJ)
+	if !assert.For(ctx, "Export").ThatError(err).Succeeded() {
+		return
+	}
+	assert.For(ctx, "obj").ThatString(string(data)).Equals(`# Exported by GAPID
+v 0 0 0
+v 1 0 0
+v 0 2 -1
+vt 0 0
+vt 1 0
+vt 0 1
+f 1/1 2/2 3/3
+`)
+
+	m := testMesh()
+	m.VertexBuffer.Streams = m.VertexBuffer.Streams[1:]
+	_, err = m.Export(ctx, path.MeshExportFormat_OBJ)
+	assert.For(ctx, "no positions").ThatError(err).Equals(api.ErrMeshHasNoPositions)
+}
@@ -0,0 +1,345 @@
+// Copyright (C) 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math"
+	"strings"
+
+	"github.com/google/gapid/core/data/endian"
+	"github.com/google/gapid/core/log"
+	"github.com/google/gapid/core/os/device"
+	"github.com/google/gapid/core/stream"
+	"github.com/google/gapid/core/stream/fmts"
+	"github.com/google/gapid/gapis/vertex"
+)
+
+// glTF 2.0 enumerators.
+const (
+	gltfByte          = 5120
+	gltfUnsignedByte  = 5121
+	gltfShort         = 5122
+	gltfUnsignedShort = 5123
+	gltfUnsignedInt   = 5125
+	gltfFloat         = 5126
+
+	gltfArrayBuffer        = 34962
+	gltfElementArrayBuffer = 34963
+)
+
+var gltfModes = map[DrawPrimitive]int{
+	DrawPrimitive_Points:        0,
+	DrawPrimitive_Lines:         1,
+	DrawPrimitive_LineLoop:      2,
+	DrawPrimitive_LineStrip:     3,
+	DrawPrimitive_Triangles:     4,
+	DrawPrimitive_TriangleStrip: 5,
+	DrawPrimitive_TriangleFan:   6,
+}
+
+var gltfTypes = []string{"", "SCALAR", "VEC2", "VEC3", "VEC4"}
+
+type gltfDocument struct {
+	Asset       gltfAsset        `json:"asset"`
+	Scene       int              `json:"scene"`
+	Scenes      []gltfScene      `json:"scenes"`
+	Nodes       []gltfNode       `json:"nodes"`
+	Meshes      []gltfMesh       `json:"meshes"`
+	Buffers     []gltfBuffer     `json:"buffers"`
+	BufferViews []gltfBufferView `json:"bufferViews"`
+	Accessors   []gltfAccessor   `json:"accessors"`
+}
+
+type gltfAsset struct {
+	Version   string `json:"version"`
+	Generator string `json:"generator"`
+}
+
+type gltfScene struct {
+	Nodes []int `json:"nodes"`
+}
+
+type gltfNode struct {
+	Mesh int `json:"mesh"`
+}
+
+type gltfMesh struct {
+	Primitives []gltfPrimitive `json:"primitives"`
+}
+
+type gltfPrimitive struct {
+	Attributes map[string]int `json:"attributes"`
+	Indices    *int           `json:"indices,omitempty"`
+	Mode       int            `json:"mode"`
+}
+
+type gltfBuffer struct {
+	ByteLength int    `json:"byteLength"`
+	URI        string `json:"uri"`
+}
+
+type gltfBufferView struct {
+	Buffer     int `json:"buffer"`
+	ByteOffset int `json:"byteOffset"`
+	ByteLength int `json:"byteLength"`
+	ByteStride int `json:"byteStride,omitempty"`
+	Target     int `json:"target"`
+}
+
+type gltfAccessor struct {
+	BufferView    int       `json:"bufferView"`
+	ComponentType int       `json:"componentType"`
+	Normalized    bool      `json:"normalized,omitempty"`
+	Count         int       `json:"count"`
+	Type          string    `json:"type"`
+	Name          string    `json:"name,omitempty"`
+	Min           []float32 `json:"min,omitempty"`
+	Max           []float32 `json:"max,omitempty"`
+}
+
+// GLTF encodes the mesh as a glTF 2.0 JSON file holding a single mesh with a
+// single primitive, with the buffer embedded as a base64 data URI.
+// Streams with a semantic glTF defines are exported as the matching attribute,
+// converted to float if glTF does not support their format. Other streams are
+// exported as custom attributes named after the stream, keeping their format.
+func (m *Mesh) GLTF(ctx context.Context) ([]byte, error) {
+	doc := &gltfDocument{
+		Asset:  gltfAsset{Version: "2.0", Generator: "GAPID"},
+		Scenes: []gltfScene{{Nodes: []int{0}}},
+		Nodes:  []gltfNode{{Mesh: 0}},
+	}
+	prim := gltfPrimitive{Attributes: map[string]int{}, Mode: gltfModes[m.DrawPrimitive]}
+	buf := &bytes.Buffer{}
+
+	addView := func(data []byte, stride, target int) int {
+		for buf.Len()%4 != 0 {
+			buf.WriteByte(0)
+		}
+		doc.BufferViews = append(doc.BufferViews, gltfBufferView{
+			ByteOffset: buf.Len(),
+			ByteLength: len(data),
+			ByteStride: stride,
+			Target:     target,
+		})
+		buf.Write(data)
+		return len(doc.BufferViews) - 1
+	}
+
+	for _, s := range m.VertexBuffer.GetStreams() {
+		if c := len(s.Format.GetComponents()); c == 0 || c > 4 {
+			log.W(ctx, "Skipping stream '%v' with %d components", s.Name, c)
+			continue
+		}
+		name, f := gltfAttribute(s, prim.Attributes)
+		data, err := stream.Convert(f, s.Format, s.Data)
+		if err != nil {
+			return nil, log.Errf(ctx, err, "Couldn't convert stream '%v'", s.Name)
+		}
+		componentType, normalized, _ := gltfComponentType(f)
+		stride, count := f.Stride(), len(data)/f.Stride()
+		var min, max []float32
+		if name == "POSITION" && count > 0 {
+			// Position accessors must declare their bounds.
+			min, max = bounds(bytesToFloats(data), 3)
+		}
+
+		// Each vertex attribute element must be aligned to 4 bytes.
+		byteStride := 0
+		if stride%4 != 0 {
+			byteStride = (stride + 3) &^ 3
+			padded := make([]byte, count*byteStride)
+			for i := 0; i < count; i++ {
+				copy(padded[i*byteStride:], data[i*stride:(i+1)*stride])
+			}
+			data = padded
+		}
+
+		accessor := gltfAccessor{
+			BufferView:    addView(data, byteStride, gltfArrayBuffer),
+			ComponentType: componentType,
+			Normalized:    normalized,
+			Count:         count,
+			Type:          gltfTypes[len(f.Components)],
+			Name:          s.Name,
+			Min:           min,
+			Max:           max,
+		}
+		prim.Attributes[name] = len(doc.Accessors)
+		doc.Accessors = append(doc.Accessors, accessor)
+	}
+
+	if m.IndexBuffer != nil && len(m.IndexBuffer.Indices) > 0 {
+		max := uint32(0)
+		for _, i := range m.IndexBuffer.Indices {
+			if i > max {
+				max = i
+			}
+		}
+		data := &bytes.Buffer{}
+		w := endian.Writer(data, device.LittleEndian)
+		componentType := gltfUnsignedInt
+		// 0xffff is reserved for primitive restart.
+		if max < 0xffff {
+			componentType = gltfUnsignedShort
+		}
+		for _, i := range m.IndexBuffer.Indices {
+			if componentType == gltfUnsignedShort {
+				w.Uint16(uint16(i))
+			} else {
+				w.Uint32(i)
+			}
+		}
+		indices := len(doc.Accessors)
+		prim.Indices = &indices
+		doc.Accessors = append(doc.Accessors, gltfAccessor{
+			BufferView:    addView(data.Bytes(), 0, gltfElementArrayBuffer),
+			ComponentType: componentType,
+			Count:         len(m.IndexBuffer.Indices),
+			Type:          "SCALAR",
+		})
+	}
+
+	doc.Meshes = []gltfMesh{{Primitives: []gltfPrimitive{prim}}}
+	doc.Buffers = []gltfBuffer{{
+		ByteLength: buf.Len(),
+		URI:        "data:application/octet-stream;base64," + base64.StdEncoding.EncodeToString(buf.Bytes()),
+	}}
+	return json.MarshalIndent(doc, "", "  ")
+}
+
+// gltfAttribute returns the glTF attribute name for the stream s, that is not
+// already used, and the format to export the stream data with.
+func gltfAttribute(s *vertex.Stream, used map[string]int) (string, *stream.Format) {
+	name, f := "", (*stream.Format)(nil)
+	components := len(s.Format.Components)
+	_, _, supported := gltfComponentType(s.Format)
+	switch s.Semantic.GetType() {
+	case vertex.Semantic_Position:
+		name, f = "POSITION", fmts.XYZ_F32
+	case vertex.Semantic_Normal:
+		name, f = "NORMAL", fmts.XYZ_F32
+	case vertex.Semantic_Tangent:
+		name, f = "TANGENT", fmts.XYZW_F32
+	case vertex.Semantic_Texcoord:
+		name, f = fmt.Sprintf("TEXCOORD_%d", s.Semantic.Index), fmts.XY_F32
+		if components == 2 && supported && gltfFloatOrUnorm(s.Format) {
+			f = s.Format
+		}
+	case vertex.Semantic_Color:
+		name, f = fmt.Sprintf("COLOR_%d", s.Semantic.Index), fmts.XYZW_F32
+		if components == 3 {
+			f = fmts.XYZ_F32
+		}
+		if (components == 3 || components == 4) && supported && gltfFloatOrUnorm(s.Format) {
+			f = s.Format
+		}
+	}
+	if _, taken := used[name]; name == "" || taken {
+		// Application specific attributes must start with an underscore.
+		name = "_" + strings.ToUpper(strings.Map(func(r rune) rune {
+			switch {
+			case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+				return r
+			default:
+				return '_'
+			}
+		}, s.Name))
+		for i, base := 1, name; ; i++ {
+			if _, taken := used[name]; !taken {
+				break
+			}
+			name = fmt.Sprintf("%s_%d", base, i)
+		}
+		f = s.Format
+		if !supported {
+			f = floatFormat(s.Format)
+		}
+	}
+	return name, f
+}
+
+// gltfComponentType returns the glTF component type and normalization of the
+// vertex format f, and whether glTF supports f as a vertex attribute format.
+func gltfComponentType(f *stream.Format) (componentType int, normalized, ok bool) {
+	if len(f.Components) == 0 || len(f.Components) > 4 {
+		return 0, false, false
+	}
+	first := f.Components[0]
+	for _, c := range f.Components[1:] {
+		if !c.DataType.Is(*first.DataType) || c.IsNormalized() != first.IsNormalized() {
+			return 0, false, false
+		}
+	}
+	normalized = first.IsNormalized()
+	switch t := *first.DataType; {
+	case t.Is(stream.S8):
+		return gltfByte, normalized, true
+	case t.Is(stream.U8):
+		return gltfUnsignedByte, normalized, true
+	case t.Is(stream.S16):
+		return gltfShort, normalized, true
+	case t.Is(stream.U16):
+		return gltfUnsignedShort, normalized, true
+	case t.Is(stream.F32):
+		return gltfFloat, false, true
+	default:
+		return 0, false, false
+	}
+}
+
+// gltfFloatOrUnorm returns true if f has float or normalized unsigned
+// components, the only formats glTF allows for texture coordinates and colors.
+func gltfFloatOrUnorm(f *stream.Format) bool {
+	t, normalized, _ := gltfComponentType(f)
+	return t == gltfFloat || (normalized && (t == gltfUnsignedByte || t == gltfUnsignedShort))
+}
+
+// floatFormat returns the format with the channels of f and 32 bit float
+// components.
+func floatFormat(f *stream.Format) *stream.Format {
+	out := &stream.Format{}
+	for _, c := range f.Components {
+		out.Components = append(out.Components, &stream.Component{
+			DataType: &stream.F32,
+			Sampling: stream.Linear,
+			Channel:  c.Channel,
+		})
+	}
+	return out
+}
+
+// bounds returns the per component minimum and maximum of the vectors of n
+// components in data.
+func bounds(data []float32, n int) (min, max []float32) {
+	min, max = make([]float32, n), make([]float32, n)
+	for i := range min {
+		min[i], max[i] = math.MaxFloat32, -math.MaxFloat32
+	}
+	for i, v := range data {
+		c := i % n
+		if v < min[c] {
+			min[c] = v
+		}
+		if v > max[c] {
+			max[c] = v
+		}
+	}
+	return min, max
+}
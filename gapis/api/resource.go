@@ -21,6 +21,7 @@ import (
 	"github.com/google/gapid/core/data/id"
 	"github.com/google/gapid/core/data/protoutil"
 	"github.com/google/gapid/core/image"
+	"github.com/google/gapid/gapis/database"
 	"github.com/google/gapid/gapis/service/path"
 )
 
@@ -56,10 +57,20 @@ type Resource interface {
 		r *path.ResolveConfig) error
 }
 
+// ResourceUseReporter is the interface implemented by APIs that can tell which
+// of the resources accessed by a command are read and which are written.
+type ResourceUseReporter interface {
+	// MutateResourceUses mutates the command cmd on the state s, and returns
+	// the resources read by the command, and the resources written or
+	// modified by the command.
+	MutateResourceUses(ctx context.Context, id CmdID, cmd Cmd, s *GlobalState) (reads, writes []Resource, err error)
+}
+
 // ResourceMeta represents resource with a state information obtained during building.
 type ResourceMeta struct {
-	Resources []Resource  // Resolved resource.
-	IDMap     ResourceMap // Map for resolved resources to ids.
+	Resources []Resource      // Resolved resource.
+	IDMap     ResourceMap     // Map for resolved resources to ids.
+	Data      []*ResourceData // Current data of the resources, nil if unavailable.
 }
 
 // ReplaceCallback is called from SetResourceData to propagate changes to current command stream.
@@ -106,11 +117,32 @@ func NewResourceData(data interface{}) *ResourceData {
 		return &ResourceData{Data: &ResourceData_Shader{data}}
 	case *Program:
 		return &ResourceData{Data: &ResourceData_Program{data}}
+	case *Buffer:
+		return &ResourceData{Data: &ResourceData_Buffer{data}}
 	default:
 		panic(fmt.Errorf("%T is not a ResourceData type", data))
 	}
 }
 
+// Bytes returns the data of the buffer, checking it holds Size bytes.
+func (b *Buffer) Bytes(ctx context.Context) ([]byte, error) {
+	if b.Data == nil {
+		return nil, fmt.Errorf("No buffer data given")
+	}
+	boxed, err := database.Resolve(ctx, b.Data.ID())
+	if err != nil {
+		return nil, err
+	}
+	data, ok := boxed.([]byte)
+	if !ok {
+		return nil, fmt.Errorf("Expected buffer data as bytes, got %T", boxed)
+	}
+	if uint64(len(data)) != b.Size {
+		return nil, fmt.Errorf("Expected %d bytes of buffer data, got %d", b.Size, len(data))
+	}
+	return data, nil
+}
+
 // NewMultiResourceData returns a new *MultiResourceData with the specified resources.
 func NewMultiResourceData(resources []*ResourceData) *MultiResourceData {
 	return &MultiResourceData{Resources: resources}
@@ -0,0 +1,189 @@
+// Copyright (C) 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package vulkan
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/google/gapid/gapis/api"
+	"github.com/google/gapid/gapis/extensions"
+	"github.com/google/gapid/gapis/resolve/cmdgrouper"
+	"github.com/google/gapid/gapis/service/path"
+)
+
+func init() {
+	extensions.Register(extensions.Extension{
+		Name:        "Vulkan render passes",
+		CmdGroupers: newCmdGroupers,
+	})
+}
+
+func newCmdGroupers(ctx context.Context, p *path.CommandTree, r *path.ResolveConfig) []cmdgrouper.Grouper {
+	out := []cmdgrouper.Grouper{}
+	if p.GroupByRenderPass {
+		out = append(out, &renderPassGrouper{passes: map[VkCommandBuffer]*recordedPass{}})
+	}
+	if p.GroupByPipeline {
+		out = append(out, &pipelineGrouper{open: map[VkCommandBuffer]*boundPipeline{}})
+	}
+	return out
+}
+
+// renderPassGrouper groups the commands recorded into a command buffer between
+// vkCmdBeginRenderPass and vkCmdEndRenderPass, with a sub-group per subpass if
+// the render pass has more than one.
+// Groups of command buffers recorded concurrently overlap and are dropped
+// from the command tree.
+type renderPassGrouper struct {
+	passes map[VkCommandBuffer]*recordedPass
+	out    []cmdgrouper.Group
+}
+
+// recordedPass is a render pass being recorded into a command buffer.
+type recordedPass struct {
+	group        cmdgrouper.Group
+	subpasses    []cmdgrouper.Group
+	subpassStart api.CmdID
+}
+
+func (g *renderPassGrouper) Process(ctx context.Context, id api.CmdID, cmd api.Cmd, s *api.GlobalState) {
+	switch cmd := cmd.(type) {
+	case *VkCmdBeginRenderPass:
+		info := cmd.PRenderPassBegin().MustRead(ctx, cmd, s, nil)
+		g.passes[cmd.CommandBuffer()] = &recordedPass{
+			group: cmdgrouper.Group{
+				Start: id,
+				Name:  fmt.Sprintf("Render pass %v", info.RenderPass()),
+			},
+			subpassStart: id,
+		}
+
+	case *VkCmdNextSubpass:
+		if p, ok := g.passes[cmd.CommandBuffer()]; ok {
+			p.endSubpass(id)
+		}
+
+	case *VkCmdEndRenderPass:
+		if p, ok := g.passes[cmd.CommandBuffer()]; ok {
+			end := id + 1 // +1 to include vkCmdEndRenderPass
+			if len(p.subpasses) > 0 {
+				p.endSubpass(end)
+				g.out = append(g.out, p.subpasses...)
+			}
+			p.group.End = end
+			g.out = append(g.out, p.group)
+			delete(g.passes, cmd.CommandBuffer())
+		}
+
+	case *VkBeginCommandBuffer:
+		delete(g.passes, cmd.CommandBuffer())
+	}
+}
+
+func (p *recordedPass) endSubpass(end api.CmdID) {
+	p.subpasses = append(p.subpasses, cmdgrouper.Group{
+		Start: p.subpassStart,
+		End:   end,
+		Name:  fmt.Sprintf("Subpass %d", len(p.subpasses)),
+	})
+	p.subpassStart = end
+}
+
+func (g *renderPassGrouper) Build(end api.CmdID) []cmdgrouper.Group {
+	out := g.out
+	g.passes, g.out = map[VkCommandBuffer]*recordedPass{}, nil
+	return out
+}
+
+// pipelineGrouper groups the commands recorded into a command buffer while the
+// same pipeline is bound. The groups are broken at render pass and subpass
+// boundaries so they nest within the render pass groups.
+type pipelineGrouper struct {
+	open map[VkCommandBuffer]*boundPipeline
+	out  []cmdgrouper.Group
+}
+
+// boundPipeline is the group of a pipeline bound to a command buffer.
+type boundPipeline struct {
+	group cmdgrouper.Group
+	used  bool // True if a command was recorded using the pipeline.
+}
+
+// recordedCmd is the interface implemented by the commands recorded into a
+// command buffer.
+type recordedCmd interface {
+	CommandBuffer() VkCommandBuffer
+}
+
+func (g *pipelineGrouper) Process(ctx context.Context, id api.CmdID, cmd api.Cmd, s *api.GlobalState) {
+	switch cmd := cmd.(type) {
+	case *VkCmdBindPipeline:
+		g.end(cmd.CommandBuffer(), id)
+		g.open[cmd.CommandBuffer()] = &boundPipeline{
+			group: cmdgrouper.Group{
+				Start: id,
+				Name:  fmt.Sprintf("Pipeline %v", cmd.Pipeline()),
+			},
+		}
+
+	case *VkCmdBeginRenderPass:
+		g.split(cmd.CommandBuffer(), id)
+
+	case *VkCmdNextSubpass:
+		g.split(cmd.CommandBuffer(), id)
+
+	case *VkCmdEndRenderPass:
+		g.split(cmd.CommandBuffer(), id)
+
+	case *VkEndCommandBuffer:
+		g.end(cmd.CommandBuffer(), id)
+		delete(g.open, cmd.CommandBuffer())
+
+	case *VkBeginCommandBuffer:
+		delete(g.open, cmd.CommandBuffer())
+
+	case recordedCmd:
+		if p, ok := g.open[cmd.CommandBuffer()]; ok {
+			p.used = true
+		}
+	}
+}
+
+// end closes the group of the command buffer before the command id, if any
+// command was recorded using the pipeline.
+func (g *pipelineGrouper) end(cb VkCommandBuffer, id api.CmdID) {
+	if p, ok := g.open[cb]; ok && p.used {
+		p.group.End = id
+		g.out = append(g.out, p.group)
+	}
+}
+
+// split closes the group of the command buffer before the render pass command
+// id, and continues it after the command, as the pipeline remains bound.
+func (g *pipelineGrouper) split(cb VkCommandBuffer, id api.CmdID) {
+	if p, ok := g.open[cb]; ok {
+		g.end(cb, id)
+		g.open[cb] = &boundPipeline{
+			group: cmdgrouper.Group{Start: id + 1, Name: p.group.Name},
+		}
+	}
+}
+
+func (g *pipelineGrouper) Build(end api.CmdID) []cmdgrouper.Group {
+	out := g.out
+	g.open, g.out = map[VkCommandBuffer]*boundPipeline{}, nil
+	return out
+}
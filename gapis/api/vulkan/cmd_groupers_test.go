@@ -0,0 +1,133 @@
+// Copyright (C) 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package vulkan
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/google/gapid/core/assert"
+	"github.com/google/gapid/core/log"
+	"github.com/google/gapid/core/memory/arena"
+	"github.com/google/gapid/core/os/device"
+	"github.com/google/gapid/gapis/api"
+	"github.com/google/gapid/gapis/database"
+	"github.com/google/gapid/gapis/memory"
+	"github.com/google/gapid/gapis/resolve/cmdgrouper"
+)
+
+func TestCmdGroupers(t *testing.T) {
+	ctx := log.Testing(t)
+	ctx = database.Put(ctx, database.NewInMemory(ctx))
+	a := arena.New()
+	defer a.Dispose()
+	cb := CommandBuilder{Arena: a}
+
+	const (
+		first  = VkCommandBuffer(1)
+		second = VkCommandBuffer(2)
+	)
+	graphics := VkPipelineBindPoint_VK_PIPELINE_BIND_POINT_GRAPHICS
+	inline := VkSubpassContents_VK_SUBPASS_CONTENTS_INLINE
+	draw := func(commandBuffer VkCommandBuffer) api.Cmd {
+		return cb.VkCmdDraw(commandBuffer, 3, 1, 0, 0)
+	}
+
+	// Two command buffers recorded concurrently: the first one has a render
+	// pass of two subpasses, with a pipeline rebound in the second subpass.
+	cmds := func(s *api.GlobalState) []api.Cmd {
+		return []api.Cmd{
+			cb.VkBeginCommandBuffer(first, memory.Nullptr, VkResult_VK_SUCCESS),
+			cb.VkBeginCommandBuffer(second, memory.Nullptr, VkResult_VK_SUCCESS),
+			cb.VkCmdBindPipeline(first, graphics, VkPipeline(1)),
+			// [3] First subpass of the first command buffer.
+			groupersBeginRenderPass(ctx, cb, s, first, VkRenderPass(10)),
+			draw(first),
+			groupersBeginRenderPass(ctx, cb, s, second, VkRenderPass(20)),
+			cb.VkCmdBindPipeline(second, graphics, VkPipeline(3)),
+			// [7] Second subpass of the first command buffer.
+			cb.VkCmdNextSubpass(first, inline),
+			draw(first),
+			draw(second),
+			cb.VkCmdBindPipeline(first, graphics, VkPipeline(2)),
+			draw(first),
+			// [12] End of the render passes and command buffers.
+			cb.VkCmdEndRenderPass(first),
+			cb.VkCmdEndRenderPass(second),
+			cb.VkEndCommandBuffer(first, VkResult_VK_SUCCESS),
+			cb.VkEndCommandBuffer(second, VkResult_VK_SUCCESS),
+		}
+	}
+
+	renderPass := func(rp VkRenderPass) string { return fmt.Sprintf("Render pass %v", rp) }
+	pipeline := func(p VkPipeline) string { return fmt.Sprintf("Pipeline %v", p) }
+	for _, test := range []struct {
+		name     string
+		grouper  cmdgrouper.Grouper
+		expected []cmdgrouper.Group
+	}{
+		{"render passes", &renderPassGrouper{passes: map[VkCommandBuffer]*recordedPass{}}, []cmdgrouper.Group{
+			{Start: 3, End: 7, Name: "Subpass 0"},
+			{Start: 7, End: 13, Name: "Subpass 1"},
+			{Start: 3, End: 13, Name: renderPass(10)},
+			{Start: 5, End: 14, Name: renderPass(20)},
+		}},
+		{"pipelines", &pipelineGrouper{open: map[VkCommandBuffer]*boundPipeline{}}, []cmdgrouper.Group{
+			{Start: 4, End: 7, Name: pipeline(1)},
+			{Start: 8, End: 10, Name: pipeline(1)},
+			{Start: 10, End: 12, Name: pipeline(2)},
+			{Start: 6, End: 13, Name: pipeline(3)},
+		}},
+	} {
+		// Build resets the groupers, so the commands are processed twice to
+		// check the groups do not depend on the previous build.
+		for pass := 0; pass < 2; pass++ {
+			s := api.NewStateWithEmptyAllocator(device.Little32)
+			list := cmds(s)
+			for i, cmd := range list {
+				// Only apply the observations, as the commands do not have
+				// the state they need to be mutated.
+				if o := cmd.Extras().Observations(); o != nil {
+					o.ApplyReads(s.Memory.ApplicationPool())
+				}
+				test.grouper.Process(ctx, api.CmdID(i), cmd, s)
+			}
+			assert.For(ctx, "%v (pass %d)", test.name, pass).
+				ThatSlice(test.grouper.Build(api.CmdID(len(list)))).Equals(test.expected)
+		}
+	}
+}
+
+// groupersBeginRenderPass returns a command beginning the render pass
+// renderPass, with the begin info observed in the application memory of s.
+func groupersBeginRenderPass(ctx context.Context, cb CommandBuilder, s *api.GlobalState,
+	commandBuffer VkCommandBuffer, renderPass VkRenderPass) api.Cmd {
+
+	begin := s.AllocDataOrPanic(ctx, NewVkRenderPassBeginInfo(cb.Arena,
+		VkStructureType_VK_STRUCTURE_TYPE_RENDER_PASS_BEGIN_INFO, // sType
+		0,                      // pNext
+		renderPass,             // renderPass
+		VkFramebuffer(1),       // framebuffer
+		MakeVkRect2D(cb.Arena), // renderArea
+		0,                      // clearValueCount
+		0,                      // pClearValues
+	))
+	return cb.VkCmdBeginRenderPass(
+		commandBuffer,
+		begin.Ptr(),
+		VkSubpassContents_VK_SUBPASS_CONTENTS_INLINE,
+	).AddRead(begin.Data())
+}
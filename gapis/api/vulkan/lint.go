@@ -0,0 +1,131 @@
+// Copyright (C) 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package vulkan
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/google/gapid/core/log"
+	"github.com/google/gapid/gapis/api"
+	"github.com/google/gapid/gapis/messages"
+)
+
+var _ = api.Linter(API{})
+
+// Lint returns a new lint pass for Vulkan commands.
+// Implements api.Linter.
+func (API) Lint(ctx context.Context, report api.LintReporter) api.LintPass {
+	return &lint{
+		report:    report,
+		pipelines: map[lintBindPoint]VkPipeline{},
+	}
+}
+
+// lint is the api.LintPass reporting pipeline barriers synchronizing more than
+// necessary and rebinding of the bound pipeline in a command buffer.
+type lint struct {
+	report    api.LintReporter
+	pipelines map[lintBindPoint]VkPipeline
+}
+
+type lintBindPoint struct {
+	commandBuffer VkCommandBuffer
+	bindPoint     VkPipelineBindPoint
+}
+
+// PreMutate implements api.LintPass.
+func (l *lint) PreMutate(ctx context.Context, id api.CmdID, cmd api.Cmd, s *api.GlobalState) {
+	switch cmd := cmd.(type) {
+	case *VkBeginCommandBuffer:
+		for k := range l.pipelines {
+			if k.commandBuffer == cmd.CommandBuffer() {
+				delete(l.pipelines, k)
+			}
+		}
+
+	case *VkCmdBindPipeline:
+		key := lintBindPoint{cmd.CommandBuffer(), cmd.PipelineBindPoint()}
+		if p, ok := l.pipelines[key]; ok && p == cmd.Pipeline() {
+			l.report(id, log.Warning, messages.WarnRedundantBind(
+				cmd.CmdName(), fmt.Sprintf("pipeline %v", cmd.Pipeline())))
+		}
+		l.pipelines[key] = cmd.Pipeline()
+	}
+}
+
+// PostMutate implements api.LintPass.
+func (l *lint) PostMutate(ctx context.Context, id api.CmdID, cmd api.Cmd, s *api.GlobalState) {
+	// The barriers are read once the command has applied its read
+	// observations to the application memory.
+	if cmd, ok := cmd.(*VkCmdPipelineBarrier); ok {
+		if mask, err := broadBarrierMask(ctx, cmd, s); err == nil && mask != "" {
+			l.report(id, log.Warning, messages.WarnBroadPipelineBarrier(cmd.CmdName(), mask))
+		}
+	}
+}
+
+// broadBarrierMask returns the names of the stage and access flags of the
+// pipeline barrier cmd that synchronize with every command or every memory
+// access, or an empty string if there are none. The barriers are read from the
+// application memory of s.
+func broadBarrierMask(ctx context.Context, cmd *VkCmdPipelineBarrier, s *api.GlobalState) (string, error) {
+	allCommands := VkPipelineStageFlags(VkPipelineStageFlagBits_VK_PIPELINE_STAGE_ALL_COMMANDS_BIT)
+	memoryRead := VkAccessFlags(VkAccessFlagBits_VK_ACCESS_MEMORY_READ_BIT)
+	memoryWrite := VkAccessFlags(VkAccessFlagBits_VK_ACCESS_MEMORY_WRITE_BIT)
+
+	names := []string{}
+	if cmd.SrcStageMask()&allCommands != 0 {
+		names = append(names, "srcStageMask: VK_PIPELINE_STAGE_ALL_COMMANDS_BIT")
+	}
+	if cmd.DstStageMask()&allCommands != 0 {
+		names = append(names, "dstStageMask: VK_PIPELINE_STAGE_ALL_COMMANDS_BIT")
+	}
+
+	access := VkAccessFlags(0)
+	l := s.MemoryLayout
+	count := uint64(cmd.MemoryBarrierCount())
+	memoryBarriers, err := cmd.PMemoryBarriers().Slice(0, count, l).Read(ctx, cmd, s, nil)
+	if err != nil {
+		return "", err
+	}
+	for _, b := range memoryBarriers {
+		access |= b.SrcAccessMask() | b.DstAccessMask()
+	}
+	count = uint64(cmd.BufferMemoryBarrierCount())
+	bufferBarriers, err := cmd.PBufferMemoryBarriers().Slice(0, count, l).Read(ctx, cmd, s, nil)
+	if err != nil {
+		return "", err
+	}
+	for _, b := range bufferBarriers {
+		access |= b.SrcAccessMask() | b.DstAccessMask()
+	}
+	count = uint64(cmd.ImageMemoryBarrierCount())
+	imageBarriers, err := cmd.PImageMemoryBarriers().Slice(0, count, l).Read(ctx, cmd, s, nil)
+	if err != nil {
+		return "", err
+	}
+	for _, b := range imageBarriers {
+		access |= b.SrcAccessMask() | b.DstAccessMask()
+	}
+	if access&memoryRead != 0 {
+		names = append(names, "accessMask: VK_ACCESS_MEMORY_READ_BIT")
+	}
+	if access&memoryWrite != 0 {
+		names = append(names, "accessMask: VK_ACCESS_MEMORY_WRITE_BIT")
+	}
+	return strings.Join(names, ", "), nil
+}
@@ -0,0 +1,118 @@
+// Copyright (C) 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package vulkan
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/gapid/core/assert"
+	"github.com/google/gapid/core/log"
+	"github.com/google/gapid/core/memory/arena"
+	"github.com/google/gapid/core/os/device"
+	"github.com/google/gapid/gapis/api"
+	"github.com/google/gapid/gapis/database"
+	"github.com/google/gapid/gapis/memory"
+	"github.com/google/gapid/gapis/stringtable"
+)
+
+type lintIssue struct {
+	id  api.CmdID
+	msg string
+}
+
+func TestLint(t *testing.T) {
+	ctx := log.Testing(t)
+	ctx = database.Put(ctx, database.NewInMemory(ctx))
+	a := arena.New()
+	defer a.Dispose()
+	cb := CommandBuilder{Arena: a}
+
+	const commandBuffer = VkCommandBuffer(1)
+	graphics := VkPipelineBindPoint_VK_PIPELINE_BIND_POINT_GRAPHICS
+	fragment := VkPipelineStageFlags(VkPipelineStageFlagBits_VK_PIPELINE_STAGE_FRAGMENT_SHADER_BIT)
+	allCommands := VkPipelineStageFlags(VkPipelineStageFlagBits_VK_PIPELINE_STAGE_ALL_COMMANDS_BIT)
+	shaderRead := VkAccessFlags(VkAccessFlagBits_VK_ACCESS_SHADER_READ_BIT)
+	colorWrite := VkAccessFlags(VkAccessFlagBits_VK_ACCESS_COLOR_ATTACHMENT_WRITE_BIT)
+	memoryWrite := VkAccessFlags(VkAccessFlagBits_VK_ACCESS_MEMORY_WRITE_BIT)
+
+	for _, test := range []struct {
+		name     string
+		cmds     func(s *api.GlobalState) []api.Cmd
+		expected []lintIssue
+	}{
+		{"pipeline rebind", func(s *api.GlobalState) []api.Cmd {
+			return []api.Cmd{
+				cb.VkBeginCommandBuffer(commandBuffer, memory.Nullptr, VkResult_VK_SUCCESS),
+				cb.VkCmdBindPipeline(commandBuffer, graphics, VkPipeline(1)),
+				cb.VkCmdBindPipeline(commandBuffer, graphics, VkPipeline(2)),
+				cb.VkCmdBindPipeline(commandBuffer, graphics, VkPipeline(2)),
+				cb.VkBeginCommandBuffer(commandBuffer, memory.Nullptr, VkResult_VK_SUCCESS),
+				cb.VkCmdBindPipeline(commandBuffer, graphics, VkPipeline(2)),
+			}
+		}, []lintIssue{{3, "WARN_REDUNDANT_BIND"}}},
+		{"narrow barrier", func(s *api.GlobalState) []api.Cmd {
+			return []api.Cmd{lintBarrier(ctx, cb, s, commandBuffer, fragment, colorWrite, shaderRead)}
+		}, []lintIssue{}},
+		{"broad stage barrier", func(s *api.GlobalState) []api.Cmd {
+			return []api.Cmd{lintBarrier(ctx, cb, s, commandBuffer, allCommands, colorWrite, shaderRead)}
+		}, []lintIssue{{0, "WARN_BROAD_PIPELINE_BARRIER"}}},
+		{"broad access barrier", func(s *api.GlobalState) []api.Cmd {
+			return []api.Cmd{lintBarrier(ctx, cb, s, commandBuffer, fragment, memoryWrite, shaderRead)}
+		}, []lintIssue{{0, "WARN_BROAD_PIPELINE_BARRIER"}}},
+	} {
+		s := api.NewStateWithEmptyAllocator(device.Little32)
+		issues := []lintIssue{}
+		l := API{}.Lint(ctx, func(id api.CmdID, severity log.Severity, msg *stringtable.Msg) {
+			issues = append(issues, lintIssue{id, msg.Identifier})
+		})
+		for i, cmd := range test.cmds(s) {
+			id := api.CmdID(i)
+			l.PreMutate(ctx, id, cmd, s)
+			// Only apply the observations, as the commands do not have the
+			// state they need to be mutated.
+			if o := cmd.Extras().Observations(); o != nil {
+				o.ApplyReads(s.Memory.ApplicationPool())
+			}
+			l.PostMutate(ctx, id, cmd, s)
+		}
+		assert.For(ctx, "%v", test.name).ThatSlice(issues).Equals(test.expected)
+	}
+}
+
+// lintBarrier returns a pipeline barrier command with a single memory barrier,
+// observed in the application memory of s.
+func lintBarrier(ctx context.Context, cb CommandBuilder, s *api.GlobalState, commandBuffer VkCommandBuffer,
+	srcStageMask VkPipelineStageFlags, srcAccessMask, dstAccessMask VkAccessFlags) api.Cmd {
+
+	barrier := s.AllocDataOrPanic(ctx, NewVkMemoryBarrier(cb.Arena,
+		VkStructureType_VK_STRUCTURE_TYPE_MEMORY_BARRIER, // sType
+		0,             // pNext
+		srcAccessMask, // srcAccessMask
+		dstAccessMask, // dstAccessMask
+	))
+	return cb.VkCmdPipelineBarrier(
+		commandBuffer,
+		srcStageMask,
+		VkPipelineStageFlags(VkPipelineStageFlagBits_VK_PIPELINE_STAGE_FRAGMENT_SHADER_BIT),
+		VkDependencyFlags(0),
+		uint32(1),
+		barrier.Ptr(),
+		uint32(0),
+		memory.Nullptr,
+		uint32(0),
+		memory.Nullptr,
+	).AddRead(barrier.Data())
+}
@@ -795,6 +795,119 @@ func (t ImageObjectʳ) SetResourceData(
 	return fmt.Errorf("SetResourceData is not supported for ImageObject")
 }
 
+var _ api.Resource = BufferObjectʳ{}
+
+// IsResource returns true if this instance should be considered as a resource.
+func (b BufferObjectʳ) IsResource() bool {
+	return b.VulkanHandle() != 0
+}
+
+// ResourceHandle returns the UI identity for the resource.
+func (b BufferObjectʳ) ResourceHandle() string {
+	return fmt.Sprintf("Buffer<0x%x>", b.VulkanHandle())
+}
+
+// ResourceLabel returns an optional debug label for the resource.
+func (b BufferObjectʳ) ResourceLabel() string {
+	if b.DebugInfo().IsNil() {
+		return ""
+	}
+	if b.DebugInfo().ObjectName() != "" {
+		return b.DebugInfo().ObjectName()
+	}
+	return fmt.Sprintf("<%d:%v>", b.DebugInfo().TagName(), b.DebugInfo().Tag())
+}
+
+// Order returns an integer used to sort the resources for presentation.
+func (b BufferObjectʳ) Order() uint64 {
+	return uint64(b.VulkanHandle())
+}
+
+// ResourceType returns the type of this resource.
+func (b BufferObjectʳ) ResourceType(ctx context.Context) api.ResourceType {
+	return api.ResourceType_BufferResource
+}
+
+// ResourceData returns the resource data given the current state. The data
+// of buffers that are not bound to memory, or only bound to sparse memory, is
+// not returned.
+func (b BufferObjectʳ) ResourceData(ctx context.Context, s *api.GlobalState) (*api.ResourceData, error) {
+	size := uint64(b.Info().Size())
+	out := &api.Buffer{Size: size}
+	if mem := b.Memory(); !mem.IsNil() {
+		offset := uint64(b.MemoryOffset())
+		out.Data = path.NewID(mem.Data().Slice(offset, offset+size).ResourceID(ctx, s))
+	}
+	return api.NewResourceData(out), nil
+}
+
+// SetResourceData sets resource data in a new capture. Only the contents of
+// buffers filled by a vkCmdUpdateBuffer covering the whole buffer can be
+// replaced, as the data written through mapped memory is not tracked per
+// buffer.
+func (b BufferObjectʳ) SetResourceData(
+	ctx context.Context,
+	at *path.Command,
+	data *api.ResourceData,
+	resourceIDs api.ResourceMap,
+	edits api.ReplaceCallback,
+	mutate api.MutateInitialState,
+	r *path.ResolveConfig) error {
+
+	ctx = log.Enter(ctx, "BufferObject.SetResourceData()")
+
+	cmdIdx := at.Indices[0] // TODO: Subcommands
+
+	buffer := data.GetBuffer()
+	if buffer == nil {
+		return fmt.Errorf("Expected buffer data for %v, got %T", b.ResourceHandle(), data.Data)
+	}
+	bytes, err := buffer.Bytes(ctx)
+	if err != nil {
+		return err
+	}
+
+	resources, err := resolve.Resources(ctx, at.Capture, r)
+	if err != nil {
+		return err
+	}
+	resource, err := resources.Find(b.ResourceType(ctx), resourceIDs[b])
+	if err != nil {
+		return err
+	}
+
+	c, err := capture.ResolveFromPath(ctx, at.Capture)
+	if err != nil {
+		return err
+	}
+
+	// vkCmdUpdateBuffer does not access the buffer object, so search the
+	// commands back to the creation of the buffer.
+	created := int(resource.Accesses[0].Indices[0])
+	for i := int(cmdIdx); i >= created; i-- {
+		a, ok := c.Commands[i].(*VkCmdUpdateBuffer)
+		if !ok || a.DstBuffer() != b.VulkanHandle() {
+			continue
+		}
+		if a.DstOffset() != 0 || uint64(a.DataSize()) != buffer.Size {
+			return fmt.Errorf("%v is partially updated by command %d", b.ResourceHandle(), i)
+		}
+		edits(uint64(i), a.Replace(ctx, c, bytes))
+		return nil
+	}
+	return fmt.Errorf("No vkCmdUpdateBuffer uploads the whole of %v", b.ResourceHandle())
+}
+
+// Replace returns a copy of the vkCmdUpdateBuffer command recording data
+// instead of the original data.
+func (a *VkCmdUpdateBuffer) Replace(ctx context.Context, c *capture.Capture, data []byte) api.Cmd {
+	state := c.NewState(ctx)
+	d := state.AllocDataOrPanic(ctx, data)
+	cb := CommandBuilder{Thread: a.Thread(), Arena: state.Arena}
+	return cb.VkCmdUpdateBuffer(a.CommandBuffer(), a.DstBuffer(), a.DstOffset(), a.DataSize(), d.Ptr()).
+		AddRead(d.Data())
+}
+
 // IsResource returns true if this instance should be considered as a resource.
 func (s ShaderModuleObjectʳ) IsResource() bool {
 	return true
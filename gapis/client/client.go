@@ -17,6 +17,7 @@ package client
 import (
 	"context"
 	"io"
+	"time"
 
 	"github.com/google/gapid/core/event"
 	"github.com/google/gapid/core/event/task"
@@ -111,6 +112,17 @@ func (c *client) Set(ctx context.Context, p *path.Any, v interface{}, r *path.Re
 	return res.GetPath(), nil
 }
 
+func (c *client) UploadBlob(ctx context.Context, data []byte) (*path.Blob, error) {
+	res, err := c.client.UploadBlob(ctx, &service.UploadBlobRequest{Data: data})
+	if err != nil {
+		return nil, err
+	}
+	if err := res.GetError(); err != nil {
+		return nil, err.Get()
+	}
+	return res.GetBlob(), nil
+}
+
 func (c *client) Follow(ctx context.Context, p *path.Any, r *path.ResolveConfig) (*path.Any, error) {
 	res, err := c.client.Follow(ctx, &service.FollowRequest{
 		Path:   p,
@@ -360,6 +372,18 @@ func (c *client) GetLogStream(ctx context.Context, handler log.Handler) error {
 	return event.Feed(ctx, event.AsHandler(ctx, h), grpcutil.ToProducer(stream))
 }
 
+func (c *client) GetStatus(ctx context.Context, memorySnapshotInterval, statusUpdateFrequency time.Duration, handler service.StatusHandler) error {
+	stream, err := c.client.GetStatus(ctx, &service.GetStatusRequest{
+		MemorySnapshotInterval: uint32(memorySnapshotInterval / time.Millisecond),
+		StatusUpdateFrequency:  uint32(statusUpdateFrequency / time.Millisecond),
+	})
+	if err != nil {
+		return err
+	}
+	h := func(ctx context.Context, m *service.ServerStatusResponse) error { return handler(m) }
+	return event.Feed(ctx, event.AsHandler(ctx, h), grpcutil.ToProducer(stream))
+}
+
 func (c *client) Find(ctx context.Context, req *service.FindRequest, handler service.FindHandler) error {
 	stream, err := c.client.Find(ctx, req)
 	if err != nil {
@@ -0,0 +1,76 @@
+// Copyright (C) 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package engine
+
+// builtins are the rule sets registered by default.
+var builtins = mustParse(unrealRules, godotRules)
+
+// unrealRules matches the state reset of the Unreal OpenGL ES renderer, which
+// restores its cached render state to the defaults.
+const unrealRules = `[
+	{
+		"name": "Unreal state reset",
+		"rules": [
+			{ "cmd": "glBindFramebuffer", "params": { "framebuffer": 0 }, "optional": true },
+			{ "cmd": "glViewport", "optional": true },
+			{ "cmd": "glDepthRangef", "params": { "near": 0, "far": 1 }, "optional": true },
+			{ "cmd": "glDisable", "params": { "capability": "GL_SCISSOR_TEST" } },
+			{ "cmd": "glDisable", "params": { "capability": "GL_CULL_FACE" } },
+			{ "cmd": "glDisable", "params": { "capability": "GL_POLYGON_OFFSET_FILL" }, "optional": true },
+			{ "cmd": "glEnable", "params": { "capability": "GL_DEPTH_TEST" }, "optional": true },
+			{ "cmd": "glDepthMask", "params": { "enabled": 1 } },
+			{ "cmd": "glDisable", "params": { "capability": "GL_STENCIL_TEST" } },
+			{ "cmd": "glStencilMask", "params": { "mask": 4294967295 }, "optional": true },
+			{ "cmd": "glDisable", "params": { "capability": "GL_BLEND" } },
+			{ "cmd": "glColorMask", "params": { "red": 1, "green": 1, "blue": 1, "alpha": 1 } },
+			{ "cmd": "glUseProgram", "params": { "program": 0 }, "optional": true },
+			{ "cmd": "glDisableVertexAttribArray", "increments": "location", "repeats": true, "optional": true }
+		]
+	}
+]`
+
+// godotRules matches the state setup of the Godot GLES3 renderer at the start
+// of the 2D canvas rendering.
+const godotRules = `[
+	{
+		"name": "Godot canvas begin",
+		"rules": [
+			{ "cmd": "glBindFramebuffer", "optional": true },
+			{ "cmd": "glDisable", "params": { "capability": "GL_DEPTH_TEST" } },
+			{ "cmd": "glDisable", "params": { "capability": "GL_SCISSOR_TEST" } },
+			{ "cmd": "glDisable", "params": { "capability": "GL_DITHER" } },
+			{ "cmd": "glEnable", "params": { "capability": "GL_BLEND" } },
+			{ "cmd": "glBlendEquation", "params": { "equation": "GL_FUNC_ADD" } },
+			{ "cmd": "glBlendFuncSeparate", "params": { "src_factor_rgb": "GL_SRC_ALPHA", "dst_factor_rgb": "GL_ONE_MINUS_SRC_ALPHA" } },
+			{ "cmd": "glBindBufferBase", "params": { "target": "GL_UNIFORM_BUFFER" }, "repeats": true, "optional": true },
+			{ "cmd": "glBindVertexArray", "params": { "array": 0 }, "optional": true },
+			{ "any": [ { "cmd": "glActiveTexture" }, { "cmd": "glBindTexture" } ], "repeats": true }
+		]
+	}
+]`
+
+// mustParse returns the rule sets of all the JSON documents, panicking on
+// error.
+func mustParse(docs ...string) []RuleSet {
+	out := []RuleSet{}
+	for _, doc := range docs {
+		sets, err := Parse([]byte(doc))
+		if err != nil {
+			panic(err)
+		}
+		out = append(out, sets...)
+	}
+	return out
+}
@@ -0,0 +1,252 @@
+// Copyright (C) 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package engine provides a GAPIS extension that groups the recurring command
+// sequences issued by game engines, described by data-driven rule sets.
+//
+// Rule sets are JSON documents of the form:
+//
+//	[
+//		{
+//			"name": "Engine state reset",
+//			"rules": [
+//				{ "cmd": "glDisable", "params": { "capability": "GL_DEPTH_TEST" } },
+//				{ "cmd": "glDepthMask", "params": { "enabled": 0 }, "optional": true },
+//				{ "cmd": "glDisableVertexAttribArray", "increments": "location", "repeats": true },
+//				{ "any": [ { "cmd": "glActiveTexture" }, { "cmd": "glBindTexture" } ], "repeats": true }
+//			]
+//		}
+//	]
+//
+// String parameter values are compared to the formatted parameter, which for
+// enumerators is the enumerator name. Numerical values are compared to the
+// numerical value of the parameter.
+package engine
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"reflect"
+	"sync"
+
+	"github.com/google/gapid/gapis/api"
+	"github.com/google/gapid/gapis/extensions"
+	"github.com/google/gapid/gapis/resolve/cmdgrouper"
+	"github.com/google/gapid/gapis/service/path"
+)
+
+var (
+	ruleSets []RuleSet
+	mutex    sync.Mutex
+)
+
+func init() {
+	for _, rs := range builtins {
+		Register(rs)
+	}
+	extensions.Register(extensions.Extension{
+		Name: "Engine rule sets",
+		CmdGroupers: func(ctx context.Context, p *path.CommandTree, r *path.ResolveConfig) []cmdgrouper.Grouper {
+			out := []cmdgrouper.Grouper{}
+			for _, rs := range Get() {
+				out = append(out, rs.Grouper())
+			}
+			return out
+		},
+	})
+}
+
+// RuleSet is a named sequence of rules matching a block of engine commands.
+type RuleSet struct {
+	// Name of the groups of the matched commands.
+	Name string `json:"name"`
+	// Rules matching the commands, in order.
+	Rules []Rule `json:"rules"`
+}
+
+// Rule is a single rule of a rule set.
+// It is the data-driven equivalent of a cmdgrouper.Rule.
+type Rule struct {
+	Matcher
+	// Any is a list of alternative matchers, used instead of the rule's own
+	// matcher if not empty.
+	Any []Matcher `json:"any"`
+	// Repeats is true if the rule should repeat until it no longer passes.
+	Repeats bool `json:"repeats"`
+	// Optional is true if the rule can be skipped.
+	Optional bool `json:"optional"`
+}
+
+// Matcher matches a command by name and parameter values.
+type Matcher struct {
+	// Cmd is the name of the command.
+	Cmd string `json:"cmd"`
+	// Params is the map of parameter name to the required parameter value.
+	Params map[string]interface{} `json:"params"`
+	// Increments is the name of a parameter that has to be one more than the
+	// parameter of the previous command, if it was the same command.
+	Increments string `json:"increments"`
+}
+
+// Register registers the rule set rs.
+func Register(rs RuleSet) {
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	ruleSets = append(ruleSets, rs)
+}
+
+// Get returns the full list of registered rule sets.
+func Get() []RuleSet {
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	out := make([]RuleSet, len(ruleSets))
+	copy(out, ruleSets)
+	return out
+}
+
+// Parse returns the rule sets of the JSON document data.
+func Parse(data []byte) ([]RuleSet, error) {
+	out := []RuleSet{}
+	if err := json.Unmarshal(data, &out); err != nil {
+		return nil, err
+	}
+	for _, rs := range out {
+		if err := rs.validate(); err != nil {
+			return nil, err
+		}
+	}
+	return out, nil
+}
+
+// LoadFile parses and registers the rule sets of the JSON file filename.
+func LoadFile(filename string) error {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return err
+	}
+	sets, err := Parse(data)
+	if err != nil {
+		return fmt.Errorf("%v: %v", filename, err)
+	}
+	for _, rs := range sets {
+		Register(rs)
+	}
+	return nil
+}
+
+func (rs RuleSet) validate() error {
+	if rs.Name == "" {
+		return fmt.Errorf("Rule set has no name")
+	}
+	if len(rs.Rules) == 0 {
+		return fmt.Errorf("Rule set '%v' has no rules", rs.Name)
+	}
+	for i, r := range rs.Rules {
+		if r.Cmd == "" && len(r.Any) == 0 {
+			return fmt.Errorf("Rule %d of rule set '%v' has no command", i, rs.Name)
+		}
+		for _, m := range r.Any {
+			if m.Cmd == "" {
+				return fmt.Errorf("Rule %d of rule set '%v' has an alternative with no command", i, rs.Name)
+			}
+		}
+	}
+	return nil
+}
+
+// Grouper returns the sequence grouper of the rule set.
+func (rs RuleSet) Grouper() cmdgrouper.Grouper {
+	rules := make([]cmdgrouper.Rule, len(rs.Rules))
+	for i, r := range rs.Rules {
+		rules[i] = cmdgrouper.Rule{Pred: r.pred(), Repeats: r.Repeats, Optional: r.Optional}
+	}
+	return cmdgrouper.Sequence(rs.Name, rules...)
+}
+
+func (r Rule) pred() func(cmd, prev api.Cmd) bool {
+	matchers := r.Any
+	if len(matchers) == 0 {
+		matchers = []Matcher{r.Matcher}
+	}
+	return func(cmd, prev api.Cmd) bool {
+		for _, m := range matchers {
+			if m.match(cmd, prev) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+func (m Matcher) match(cmd, prev api.Cmd) bool {
+	if cmd.CmdName() != m.Cmd {
+		return false
+	}
+	for name, want := range m.Params {
+		v, err := api.GetParameter(cmd, name)
+		if err != nil || !matchValue(v, want) {
+			return false
+		}
+	}
+	if m.Increments != "" && prev != nil && prev.CmdName() == m.Cmd {
+		v, err := api.GetParameter(cmd, m.Increments)
+		if err != nil {
+			return false
+		}
+		p, err := api.GetParameter(prev, m.Increments)
+		if err != nil {
+			return false
+		}
+		n, ok := number(v)
+		pn, pok := number(p)
+		return ok && pok && n == pn+1
+	}
+	return true
+}
+
+// matchValue returns true if the parameter value v matches the value want
+// decoded from JSON.
+func matchValue(v, want interface{}) bool {
+	switch want := want.(type) {
+	case string:
+		return fmt.Sprint(v) == want
+	case float64:
+		n, ok := number(v)
+		return ok && n == want
+	case bool:
+		b, ok := v.(bool)
+		return ok && b == want
+	default:
+		return false
+	}
+}
+
+// number returns the value of the integer or floating-point number v.
+func number(v interface{}) (float64, bool) {
+	r := reflect.ValueOf(v)
+	switch r.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(r.Int()), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return float64(r.Uint()), true
+	case reflect.Float32, reflect.Float64:
+		return r.Float(), true
+	default:
+		return 0, false
+	}
+}
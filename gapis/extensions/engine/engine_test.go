@@ -0,0 +1,111 @@
+// Copyright (C) 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package engine
+
+import (
+	"testing"
+
+	"github.com/google/gapid/core/assert"
+	"github.com/google/gapid/core/log"
+	"github.com/google/gapid/gapis/api"
+	"github.com/google/gapid/gapis/resolve/cmdgrouper"
+)
+
+type testEnum uint32
+
+func (e testEnum) String() string {
+	if e == 1 {
+		return "ONE"
+	}
+	return "OTHER"
+}
+
+// testCmd is a command with a single parameter named "value".
+type testCmd struct {
+	api.Cmd
+	name  string
+	value testEnum
+}
+
+func (c *testCmd) CmdName() string { return c.name }
+func (c *testCmd) CmdParams() api.Properties {
+	return api.Properties{
+		api.NewProperty("value", func() testEnum { return c.value }, nil),
+	}
+}
+
+func TestParse(t *testing.T) {
+	ctx := log.Testing(t)
+
+	for _, test := range []struct {
+		name  string
+		doc   string
+		valid bool
+	}{
+		{"valid", `[{"name": "A", "rules": [{"cmd": "a"}, {"any": [{"cmd": "b"}]}]}]`, true},
+		{"no name", `[{"rules": [{"cmd": "a"}]}]`, false},
+		{"no rules", `[{"name": "A"}]`, false},
+		{"no cmd", `[{"name": "A", "rules": [{"repeats": true}]}]`, false},
+		{"no any cmd", `[{"name": "A", "rules": [{"any": [{}]}]}]`, false},
+		{"bad json", `{"name": "A"}`, false},
+	} {
+		_, err := Parse([]byte(test.doc))
+		assert.For(ctx, "%v", test.name).That(err == nil).Equals(test.valid)
+	}
+
+	assert.For(ctx, "builtins").That(len(builtins)).Equals(2)
+}
+
+func TestRuleSetGrouper(t *testing.T) {
+	ctx := log.Testing(t)
+
+	sets, err := Parse([]byte(`[{
+		"name": "Reset",
+		"rules": [
+			{ "cmd": "begin", "params": { "value": "ONE" } },
+			{ "cmd": "skip", "optional": true },
+			{ "cmd": "next", "increments": "value", "repeats": true },
+			{ "any": [ { "cmd": "end", "params": { "value": 1 } }, { "cmd": "finish" } ] }
+		]
+	}]`))
+	assert.For(ctx, "err").ThatError(err).Succeeded()
+
+	cmds := []api.Cmd{
+		&testCmd{name: "begin", value: 1},  // 0: Reset
+		&testCmd{name: "next", value: 4},   // 1
+		&testCmd{name: "next", value: 5},   // 2
+		&testCmd{name: "end", value: 1},    // 3
+		&testCmd{name: "begin", value: 2},  // 4: not ONE
+		&testCmd{name: "next", value: 0},   // 5
+		&testCmd{name: "finish", value: 0}, // 6
+		&testCmd{name: "begin", value: 1},  // 7: Reset
+		&testCmd{name: "skip", value: 0},   // 8
+		&testCmd{name: "next", value: 0},   // 9
+		&testCmd{name: "finish", value: 0}, // 10
+		&testCmd{name: "begin", value: 1},  // 11: not incrementing
+		&testCmd{name: "next", value: 1},   // 12
+		&testCmd{name: "next", value: 1},   // 13
+		&testCmd{name: "end", value: 1},    // 14
+	}
+
+	g := sets[0].Grouper()
+	for i, cmd := range cmds {
+		g.Process(ctx, api.CmdID(i), cmd, nil)
+	}
+	assert.For(ctx, "groups").ThatSlice(g.Build(api.CmdID(len(cmds)))).Equals([]cmdgrouper.Group{
+		{Start: 0, End: 4, Name: "Reset"},
+		{Start: 7, End: 11, Name: "Reset"},
+	})
+}
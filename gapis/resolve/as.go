@@ -42,6 +42,11 @@ func As(ctx context.Context, p *path.As, r *path.ResolveConfig) (interface{}, er
 		case *api.Mesh:
 			return o.ConvertTo(ctx, f)
 		}
+	case *path.As_MeshExportFormat:
+		switch o := o.(type) {
+		case *api.Mesh:
+			return o.Export(ctx, to.MeshExportFormat)
+		}
 	}
 	return nil, &service.ErrDataUnavailable{Reason: messages.ErrUnsupportedConversion()}
 }
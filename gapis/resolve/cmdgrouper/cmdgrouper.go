@@ -77,6 +77,44 @@ func (g *run) Build(end api.CmdID) []Group {
 	return out
 }
 
+// FrameRun returns a grouper that groups commands together that form a run,
+// like Run, but also ends the groups at the end of each frame so they can be
+// nested within frame groups.
+func FrameRun(pred RunPred) Grouper {
+	g := &frameRun{}
+	g.run.f = func(cmd api.Cmd, s *api.GlobalState) (interface{}, string) {
+		val, name := pred(cmd, s)
+		if val == nil {
+			return nil, name
+		}
+		return frameRunValue{g.frame, val}, name
+	}
+	return g
+}
+
+// frameRun is a grouper that groups consecutive runs of commands of a frame.
+type frameRun struct {
+	run
+	frame int
+}
+
+type frameRunValue struct {
+	frame int
+	value interface{}
+}
+
+func (g *frameRun) Process(ctx context.Context, id api.CmdID, cmd api.Cmd, s *api.GlobalState) {
+	g.run.Process(ctx, id, cmd, s)
+	if cmd.CmdFlags(ctx, id, s).IsEndOfFrame() {
+		g.frame++
+	}
+}
+
+func (g *frameRun) Build(end api.CmdID) []Group {
+	g.frame = 0
+	return g.run.Build(end)
+}
+
 // Marker returns a grouper that groups based on user marker commands.
 func Marker() Grouper {
 	return &marker{}
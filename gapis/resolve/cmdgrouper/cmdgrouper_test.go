@@ -0,0 +1,94 @@
+// Copyright (C) 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmdgrouper
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/gapid/core/assert"
+	"github.com/google/gapid/core/log"
+	"github.com/google/gapid/gapis/api"
+)
+
+// testCmd is a command grouped by its value, which may end a frame.
+type testCmd struct {
+	api.Cmd
+	value interface{}
+	flags api.CmdFlags
+}
+
+func (c *testCmd) CmdFlags(context.Context, api.CmdID, *api.GlobalState) api.CmdFlags {
+	return c.flags
+}
+
+func TestFrameRun(t *testing.T) {
+	ctx := log.Testing(t)
+
+	cmd := func(value interface{}) api.Cmd { return &testCmd{value: value} }
+	eof := func(value interface{}) api.Cmd { return &testCmd{value: value, flags: api.EndOfFrame} }
+
+	// The same grouper is used for all the tests to check Build resets it.
+	g := FrameRun(func(cmd api.Cmd, s *api.GlobalState) (interface{}, string) {
+		value := cmd.(*testCmd).value
+		if value == nil {
+			return nil, ""
+		}
+		return value, value.(string)
+	})
+
+	for _, test := range []struct {
+		name     string
+		cmds     []api.Cmd
+		expected []Group
+	}{
+		{"runs", []api.Cmd{
+			cmd("A"), cmd("A"), cmd("B"), cmd("B"),
+		}, []Group{
+			{Start: 0, End: 2, Name: "A"},
+			{Start: 2, End: 4, Name: "B"},
+		}},
+		{"split at end of frame", []api.Cmd{
+			cmd("A"), eof("A"), cmd("A"), cmd("A"),
+		}, []Group{
+			{Start: 0, End: 2, Name: "A"},
+			{Start: 2, End: 4, Name: "A"},
+		}},
+		{"ungrouped commands", []api.Cmd{
+			cmd("A"), cmd(nil), eof(nil), cmd("A"),
+		}, []Group{
+			{Start: 0, End: 1, Name: "A"},
+			{Start: 3, End: 4, Name: "A"},
+		}},
+		{"ends with the frame", []api.Cmd{
+			cmd("A"), eof("A"),
+		}, []Group{
+			{Start: 0, End: 2, Name: "A"},
+		}},
+		{"change at end of frame", []api.Cmd{
+			cmd("A"), eof("B"), cmd("B"),
+		}, []Group{
+			{Start: 0, End: 1, Name: "A"},
+			{Start: 1, End: 2, Name: "B"},
+			{Start: 2, End: 3, Name: "B"},
+		}},
+		{"empty", []api.Cmd{}, nil},
+	} {
+		for i, cmd := range test.cmds {
+			g.Process(ctx, api.CmdID(i), cmd, nil)
+		}
+		assert.For(ctx, "%v", test.name).ThatSlice(g.Build(api.CmdID(len(test.cmds)))).Equals(test.expected)
+	}
+}
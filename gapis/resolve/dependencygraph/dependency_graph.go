@@ -96,6 +96,15 @@ type StateKey interface {
 	Parent() StateKey
 }
 
+// ResourceKey is the interface implemented by the state keys of the data of a
+// resource.
+type ResourceKey interface {
+	StateKey
+
+	// Resource returns the resource holding the state.
+	Resource() api.Resource
+}
+
 type CmdBehaviour struct {
 	Reads     []StateAddress // States read by a command.
 	Modifies  []StateAddress // States read and written by a command.
@@ -111,6 +120,14 @@ type addressMapping struct {
 	parent  map[StateAddress]StateAddress
 }
 
+func newAddressMapping() addressMapping {
+	return addressMapping{
+		address: map[StateKey]StateAddress{nil: NullStateAddress},
+		key:     map[StateAddress]StateKey{NullStateAddress: nil},
+		parent:  map[StateAddress]StateAddress{NullStateAddress: NullStateAddress},
+	}
+}
+
 func (m *addressMapping) addressOf(state StateKey) StateAddress {
 	if a, ok := m.address[state]; ok {
 		return a
@@ -148,6 +165,41 @@ type BehaviourProvider interface {
 	GetBehaviourForCommand(context.Context, *api.GlobalState, api.CmdID, api.Cmd, *DependencyGraph) CmdBehaviour
 }
 
+// ResourceUses mutates the command cmd on the state s using the behaviour
+// provider bp, and returns the resources whose state the command reads, and the
+// resources whose state the command modifies or writes.
+func ResourceUses(ctx context.Context, s *api.GlobalState, id api.CmdID, cmd api.Cmd, bp BehaviourProvider) (reads, writes []api.Resource, err error) {
+	g := &DependencyGraph{
+		Roots:      map[StateAddress]bool{},
+		addressMap: newAddressMapping(),
+	}
+	b := bp.GetBehaviourForCommand(ctx, s, id, cmd, g)
+	if b.Aborted {
+		return nil, nil, fmt.Errorf("Command %v %v aborted", id, cmd)
+	}
+	reads = g.resources(b.Reads)
+	writes = g.resources(append(append([]StateAddress{}, b.Modifies...), b.Writes...))
+	return reads, writes, nil
+}
+
+// resources returns the distinct resources holding the states at addresses.
+func (g *DependencyGraph) resources(addresses []StateAddress) []api.Resource {
+	out := []api.Resource{}
+	seen := map[api.Resource]bool{}
+	for _, a := range addresses {
+		for k := g.addressMap.key[a]; k != nil; k = k.Parent() {
+			if rk, ok := k.(ResourceKey); ok {
+				if r := rk.Resource(); r != nil && !seen[r] {
+					seen[r] = true
+					out = append(out, r)
+				}
+				break
+			}
+		}
+	}
+	return out
+}
+
 func GetDependencyGraph(ctx context.Context, device *path.Device) (*DependencyGraph, error) {
 	r, err := database.Build(ctx, &DependencyGraphResolvable{
 		Capture: capture.Get(ctx),
@@ -180,11 +232,7 @@ func (r *DependencyGraphResolvable) Resolve(ctx context.Context) (interface{}, e
 		Commands:           cmds,
 		Behaviours:         make([]CmdBehaviour, len(cmds)),
 		Roots:              map[StateAddress]bool{},
-		addressMap: addressMapping{
-			address: map[StateKey]StateAddress{nil: NullStateAddress},
-			key:     map[StateAddress]StateKey{NullStateAddress: nil},
-			parent:  map[StateAddress]StateAddress{NullStateAddress: NullStateAddress},
-		},
+		addressMap:         newAddressMapping(),
 	}
 
 	s := c.NewUninitializedState(ctx).ReserveMemory(ranges)
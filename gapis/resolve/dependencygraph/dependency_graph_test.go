@@ -0,0 +1,90 @@
+// Copyright (C) 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dependencygraph_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/gapid/core/assert"
+	"github.com/google/gapid/core/log"
+	"github.com/google/gapid/gapis/api"
+	"github.com/google/gapid/gapis/resolve/dependencygraph"
+)
+
+type dummyResource struct {
+	api.Resource
+	name string
+}
+
+// dummyDataKey is the key of the data of a resource.
+type dummyDataKey struct{ r api.Resource }
+
+func (k dummyDataKey) Parent() dependencygraph.StateKey { return nil }
+func (k dummyDataKey) Resource() api.Resource           { return k.r }
+
+// dummyLevelKey is the key of a part of the data of a resource.
+type dummyLevelKey struct {
+	r     api.Resource
+	level int
+}
+
+func (k dummyLevelKey) Parent() dependencygraph.StateKey { return dummyDataKey{k.r} }
+
+// dummyStateKey is the key of state not held by a resource.
+type dummyStateKey struct{}
+
+func (k dummyStateKey) Parent() dependencygraph.StateKey { return nil }
+
+// dummyBehaviourProvider returns the behaviour of the command without
+// mutating it.
+type dummyBehaviourProvider struct {
+	reads, modifies, writes []dependencygraph.StateKey
+	aborted                 bool
+}
+
+func (p dummyBehaviourProvider) GetBehaviourForCommand(ctx context.Context, s *api.GlobalState, id api.CmdID, cmd api.Cmd, g *dependencygraph.DependencyGraph) dependencygraph.CmdBehaviour {
+	b := dependencygraph.CmdBehaviour{Aborted: p.aborted}
+	for _, k := range p.reads {
+		b.Read(g, k)
+	}
+	for _, k := range p.modifies {
+		b.Modify(g, k)
+	}
+	for _, k := range p.writes {
+		b.Write(g, k)
+	}
+	return b
+}
+
+func TestResourceUses(t *testing.T) {
+	ctx := log.Testing(t)
+
+	a := &dummyResource{name: "a"}
+	b := &dummyResource{name: "b"}
+	c := &dummyResource{name: "c"}
+
+	reads, writes, err := dependencygraph.ResourceUses(ctx, nil, 0, nil, dummyBehaviourProvider{
+		reads:    []dependencygraph.StateKey{dummyLevelKey{a, 0}, dummyLevelKey{a, 1}, dummyStateKey{}},
+		modifies: []dependencygraph.StateKey{dummyDataKey{b}},
+		writes:   []dependencygraph.StateKey{dummyLevelKey{c, 2}, dummyDataKey{b}},
+	})
+	assert.For(ctx, "err").ThatError(err).Succeeded()
+	assert.For(ctx, "reads").ThatSlice(reads).Equals([]api.Resource{a})
+	assert.For(ctx, "writes").ThatSlice(writes).Equals([]api.Resource{b, c})
+
+	_, _, err = dependencygraph.ResourceUses(ctx, nil, 0, nil, dummyBehaviourProvider{aborted: true})
+	assert.For(ctx, "aborted").ThatError(err).Failed()
+}
@@ -0,0 +1,205 @@
+// Copyright (C) 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package resolve
+
+import (
+	"context"
+	"sort"
+
+	"github.com/google/gapid/core/math/interval"
+	"github.com/google/gapid/gapis/api"
+	"github.com/google/gapid/gapis/capture"
+	"github.com/google/gapid/gapis/database"
+	"github.com/google/gapid/gapis/memory"
+	"github.com/google/gapid/gapis/service"
+	"github.com/google/gapid/gapis/service/path"
+)
+
+// defaultMemoryReportGranularity is the size of the heatmap address ranges
+// used when the path does not specify one.
+const defaultMemoryReportGranularity = 4096
+
+// MemoryReport resolves the analysis of the memory observations of the capture
+// specified by p.
+func MemoryReport(ctx context.Context, p *path.MemoryReport, r *path.ResolveConfig) (*service.MemoryReport, error) {
+	obj, err := database.Build(ctx, &MemoryReportResolvable{Path: p, Config: r})
+	if err != nil {
+		return nil, err
+	}
+	return obj.(*service.MemoryReport), nil
+}
+
+// Resolve implements the database.Resolver interface.
+func (r *MemoryReportResolvable) Resolve(ctx context.Context) (interface{}, error) {
+	ctx = SetupContext(ctx, r.Path.Capture, r.Config)
+
+	c, err := capture.ResolveFromPath(ctx, r.Path.Capture)
+	if err != nil {
+		return nil, err
+	}
+
+	granularity := r.Path.Granularity
+	if granularity == 0 {
+		granularity = defaultMemoryReportGranularity
+	}
+
+	return memoryReport(ctx, c.Commands, c.APIs, c.NewState(ctx), granularity)
+}
+
+// memoryReport mutates cmds on the state s, and returns the report of their
+// memory observations. The allocations never freed are those remaining in s,
+// for the APIs of apis that can report their memory allocations.
+func memoryReport(ctx context.Context, cmds []api.Cmd, apis []api.API, s *api.GlobalState, granularity uint64) (*service.MemoryReport, error) {
+	m := newMemoryObservations(granularity)
+	err := api.ForeachCmd(ctx, cmds, func(ctx context.Context, id api.CmdID, cmd api.Cmd) error {
+		// Reads are observed before the command executes, and writes after.
+		if o := cmd.Extras().Observations(); o != nil {
+			for _, read := range o.Reads {
+				m.read(read.Pool, read.Range)
+			}
+		}
+		cmd.Mutate(ctx, id, s, nil, nil)
+		if o := cmd.Extras().Observations(); o != nil {
+			for _, write := range o.Writes {
+				m.write(write.Pool, write.Range)
+			}
+		}
+		m.endCommand(cmd.CmdFlags(ctx, id, s).IsEndOfFrame())
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	out := m.report()
+	for _, a := range apis {
+		if p, ok := a.(api.MemoryBreakdownProvider); ok {
+			breakdown, err := p.MemoryBreakdown(s)
+			if err != nil {
+				return nil, err
+			}
+			out.Unfreed = append(out.Unfreed, breakdown.Allocations...)
+		}
+	}
+	return out, nil
+}
+
+// memoryObservations accumulates the memory observations of a command stream.
+type memoryObservations struct {
+	granularity uint64
+	frame       int  // The index of the current frame.
+	pending     bool // True if commands were seen since the last end of frame.
+	pools       map[memory.PoolID]*poolObservations
+}
+
+// poolObservations holds the accumulated observations of a single pool.
+type poolObservations struct {
+	report *service.MemoryPoolReport
+	unread memory.RangeList               // Written ranges not read since.
+	heat   map[uint64]*service.MemoryHeat // Keyed by range base address.
+}
+
+func newMemoryObservations(granularity uint64) *memoryObservations {
+	return &memoryObservations{
+		granularity: granularity,
+		pools:       map[memory.PoolID]*poolObservations{},
+	}
+}
+
+func (m *memoryObservations) pool(id memory.PoolID) *poolObservations {
+	p, ok := m.pools[id]
+	if !ok {
+		p = &poolObservations{
+			report: &service.MemoryPoolReport{Pool: uint32(id)},
+			heat:   map[uint64]*service.MemoryHeat{},
+		}
+		m.pools[id] = p
+	}
+	return p
+}
+
+func (m *memoryObservations) read(id memory.PoolID, rng memory.Range) {
+	p := m.pool(id)
+	p.report.ReadBytes += rng.Size
+	interval.Remove(&p.unread, rng.Span())
+	m.forEachHeat(p, rng, func(h *service.MemoryHeat, size uint64) {
+		h.Reads[m.frame] += size
+	})
+}
+
+func (m *memoryObservations) write(id memory.PoolID, rng memory.Range) {
+	p := m.pool(id)
+	p.report.WrittenBytes += rng.Size
+	interval.Merge(&p.unread, rng.Span(), true)
+	m.forEachHeat(p, rng, func(h *service.MemoryHeat, size uint64) {
+		h.Writes[m.frame] += size
+	})
+}
+
+// forEachHeat calls f with the heatmap entry of the current frame of each
+// address range overlapped by rng, along with the size of the overlap.
+func (m *memoryObservations) forEachHeat(p *poolObservations, rng memory.Range, f func(*service.MemoryHeat, uint64)) {
+	if rng.Size == 0 {
+		return
+	}
+	for base := rng.Base - rng.Base%m.granularity; base < rng.End(); base += m.granularity {
+		h, ok := p.heat[base]
+		if !ok {
+			h = &service.MemoryHeat{Base: base}
+			p.heat[base] = h
+		}
+		for len(h.Reads) <= m.frame {
+			h.Reads, h.Writes = append(h.Reads, 0), append(h.Writes, 0)
+		}
+		f(h, rng.Intersect(memory.Range{Base: base, Size: m.granularity}).Size)
+	}
+}
+
+func (m *memoryObservations) endCommand(endOfFrame bool) {
+	m.pending = !endOfFrame
+	if endOfFrame {
+		m.frame++
+	}
+}
+
+// report returns the report of the observations, with the heatmaps extended to
+// all the frames.
+func (m *memoryObservations) report() *service.MemoryReport {
+	frames := m.frame
+	if m.pending {
+		frames++
+	}
+	out := &service.MemoryReport{
+		Granularity: m.granularity,
+		Frames:      uint64(frames),
+		Pools:       make([]*service.MemoryPoolReport, 0, len(m.pools)),
+	}
+	for _, p := range m.pools {
+		p.report.Unread = service.NewMemoryRanges(p.unread)
+		p.report.Heatmap = make([]*service.MemoryHeat, 0, len(p.heat))
+		for _, h := range p.heat {
+			for len(h.Reads) < frames {
+				h.Reads, h.Writes = append(h.Reads, 0), append(h.Writes, 0)
+			}
+			p.report.Heatmap = append(p.report.Heatmap, h)
+		}
+		sort.Slice(p.report.Heatmap, func(i, j int) bool {
+			return p.report.Heatmap[i].Base < p.report.Heatmap[j].Base
+		})
+		out.Pools = append(out.Pools, p.report)
+	}
+	sort.Slice(out.Pools, func(i, j int) bool { return out.Pools[i].Pool < out.Pools[j].Pool })
+	return out
+}
@@ -0,0 +1,145 @@
+// Copyright (C) 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package resolve
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/gapid/core/assert"
+	"github.com/google/gapid/core/log"
+	"github.com/google/gapid/core/os/device"
+	"github.com/google/gapid/gapis/api"
+	"github.com/google/gapid/gapis/memory"
+	"github.com/google/gapid/gapis/replay/builder"
+	"github.com/google/gapid/gapis/service"
+)
+
+// memoryTestAPI is an API reporting the allocations remaining in the state.
+type memoryTestAPI struct {
+	useTestAPI
+	allocations *[]*api.MemoryAllocation
+}
+
+func (a memoryTestAPI) MemoryBreakdown(*api.GlobalState) (*api.MemoryBreakdown, error) {
+	return &api.MemoryBreakdown{Allocations: *a.allocations}, nil
+}
+
+// memoryTestCmd is a command allocating and freeing the allocations of its
+// API.
+type memoryTestCmd struct {
+	useTestCmd
+	alloc []*api.MemoryAllocation
+	free  []*api.MemoryAllocation
+}
+
+func (c *memoryTestCmd) Mutate(ctx context.Context, id api.CmdID, s *api.GlobalState, b *builder.Builder, w api.StateWatcher) error {
+	allocations := c.a.(memoryTestAPI).allocations
+	*allocations = append(*allocations, c.alloc...)
+	for _, f := range c.free {
+		for i, a := range *allocations {
+			if a == f {
+				*allocations = append((*allocations)[:i], (*allocations)[i+1:]...)
+				break
+			}
+		}
+	}
+	return nil
+}
+
+func TestMemoryObservations(t *testing.T) {
+	ctx := log.Testing(t)
+
+	m := newMemoryObservations(0x100)
+	// Frame 0
+	m.write(0, memory.Range{Base: 0x1f0, Size: 0x20})
+	m.endCommand(false)
+	m.read(0, memory.Range{Base: 0x200, Size: 0x10})
+	m.endCommand(true)
+	// Frame 1
+	m.write(1, memory.Range{Base: 0x80, Size: 0x10})
+	m.endCommand(false)
+
+	report := m.report()
+	assert.For(ctx, "frames").That(report.Frames).Equals(uint64(2))
+	assert.For(ctx, "pools").That(len(report.Pools)).Equals(2)
+
+	app := report.Pools[0]
+	assert.For(ctx, "pool").That(app.Pool).Equals(uint32(0))
+	assert.For(ctx, "read").That(app.ReadBytes).Equals(uint64(0x10))
+	assert.For(ctx, "written").That(app.WrittenBytes).Equals(uint64(0x20))
+	assert.For(ctx, "unread").ThatSlice(app.Unread).DeepEquals([]*service.MemoryRange{
+		{Base: 0x1f0, Size: 0x10},
+	})
+	assert.For(ctx, "heatmap").ThatSlice(app.Heatmap).DeepEquals([]*service.MemoryHeat{
+		{Base: 0x100, Reads: []uint64{0, 0}, Writes: []uint64{0x10, 0}},
+		{Base: 0x200, Reads: []uint64{0x10, 0}, Writes: []uint64{0x10, 0}},
+	})
+
+	other := report.Pools[1]
+	assert.For(ctx, "other heatmap").ThatSlice(other.Heatmap).DeepEquals([]*service.MemoryHeat{
+		{Base: 0x0, Reads: []uint64{0, 0}, Writes: []uint64{0, 0x10}},
+	})
+}
+
+func TestMemoryReport(t *testing.T) {
+	ctx := log.Testing(t)
+
+	reporter := memoryTestAPI{allocations: &[]*api.MemoryAllocation{}}
+	plain := useTestAPI{}
+	freed := &api.MemoryAllocation{Handle: 1, Name: "Buffer<1>", Size: 0x10}
+	leaked := &api.MemoryAllocation{Handle: 2, Name: "Buffer<2>", Size: 0x20}
+
+	observations := func(reads, writes []memory.Range) api.CmdExtras {
+		o := &api.CmdObservations{}
+		for _, r := range reads {
+			o.Reads = append(o.Reads, api.CmdObservation{Range: r})
+		}
+		for _, w := range writes {
+			o.Writes = append(o.Writes, api.CmdObservation{Range: w})
+		}
+		return api.CmdExtras{o}
+	}
+
+	cmds := []api.Cmd{
+		// Frame 0
+		&memoryTestCmd{
+			useTestCmd: useTestCmd{a: reporter, extras: observations(nil, []memory.Range{{Base: 0x100, Size: 0x10}})},
+			alloc:      []*api.MemoryAllocation{freed, leaked},
+		},
+		&useTestCmd{a: plain, flags: api.EndOfFrame},
+		// Frame 1
+		&memoryTestCmd{
+			useTestCmd: useTestCmd{a: reporter, extras: observations([]memory.Range{{Base: 0x100, Size: 0x10}}, nil)},
+			free:       []*api.MemoryAllocation{freed},
+		},
+	}
+
+	s := api.NewStateWithEmptyAllocator(device.Little32)
+	report, err := memoryReport(ctx, cmds, []api.API{plain, reporter}, s, 0x100)
+	if !assert.For(ctx, "memoryReport").ThatError(err).Succeeded() {
+		return
+	}
+	assert.For(ctx, "frames").That(report.Frames).Equals(uint64(2))
+	assert.For(ctx, "pools").ThatSlice(report.Pools).DeepEquals([]*service.MemoryPoolReport{{
+		ReadBytes:    0x10,
+		WrittenBytes: 0x10,
+		Unread:       []*service.MemoryRange{},
+		Heatmap: []*service.MemoryHeat{
+			{Base: 0x100, Reads: []uint64{0, 0x10}, Writes: []uint64{0x10, 0}},
+		},
+	}})
+	assert.For(ctx, "unfreed").ThatSlice(report.Unfreed).DeepEquals([]*api.MemoryAllocation{leaked})
+}
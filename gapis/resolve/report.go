@@ -16,6 +16,7 @@ package resolve
 
 import (
 	"context"
+	"sort"
 
 	"github.com/google/gapid/core/app/analytics"
 	"github.com/google/gapid/core/log"
@@ -85,6 +86,23 @@ func (r *ReportResolvable) Resolve(ctx context.Context) (interface{}, error) {
 
 	issues := map[api.CmdID][]replay.Issue{}
 
+	// Build the lint passes of the APIs that support them. Their items are
+	// added once all the commands have been analyzed, as a pass may report an
+	// issue with an earlier command.
+	lints := []api.LintPass{}
+	lintItems := map[api.CmdID][]*service.ReportItemRaw{}
+	lintFiltered := map[api.CmdID]bool{}
+	if r.Path.Lint {
+		report := func(id api.CmdID, s log.Severity, m *stringtable.Msg) {
+			lintItems[id] = append(lintItems[id], r.newReportItem(s, uint64(id), m))
+		}
+		for _, a := range c.APIs {
+			if l, ok := a.(api.Linter); ok {
+				lints = append(lints, l.Lint(ctx, report))
+			}
+		}
+	}
+
 	if r.Path.Device != nil {
 		// Request is for a replay report too.
 		intent := replay.Intent{
@@ -127,6 +145,10 @@ func (r *ReportResolvable) Resolve(ctx context.Context) (interface{}, error) {
 				messages.ErrTraceAssert(as.Reason)))
 		}
 
+		for _, l := range lints {
+			l.PreMutate(ctx, id, cmd, state)
+		}
+
 		if err := cmd.Mutate(ctx, id, state, nil /* builder */, nil /* watcher */); err != nil {
 			if !api.IsErrCmdAborted(err) {
 				items = append(items, r.newReportItem(log.Error, uint64(id),
@@ -134,7 +156,14 @@ func (r *ReportResolvable) Resolve(ctx context.Context) (interface{}, error) {
 			}
 		}
 
+		for _, l := range lints {
+			l.PostMutate(ctx, id, cmd, state)
+		}
+
 		if filter(id, cmd, state) {
+			if len(lints) > 0 {
+				lintFiltered[id] = true
+			}
 			for _, item := range items {
 				item.Tags = append(item.Tags, getCommandNameTag(cmd))
 				builder.Add(ctx, item)
@@ -151,6 +180,20 @@ func (r *ReportResolvable) Resolve(ctx context.Context) (interface{}, error) {
 		return nil
 	})
 
+	lintIDs := make([]api.CmdID, 0, len(lintItems))
+	for id := range lintItems {
+		if lintFiltered[id] {
+			lintIDs = append(lintIDs, id)
+		}
+	}
+	sort.Slice(lintIDs, func(i, j int) bool { return lintIDs[i] < lintIDs[j] })
+	for _, id := range lintIDs {
+		for _, item := range lintItems[id] {
+			item.Tags = append(item.Tags, getCommandNameTag(c.Commands[id]), messages.TagPerformance())
+			builder.Add(ctx, item)
+		}
+	}
+
 	return builder.Build(), nil
 }
 
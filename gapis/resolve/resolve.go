@@ -347,6 +347,8 @@ func ResolveInternal(ctx context.Context, p path.Node, r *path.ResolveConfig) (i
 		return MapIndex(ctx, p, r)
 	case *path.Memory:
 		return Memory(ctx, p, r)
+	case *path.MemoryReport:
+		return MemoryReport(ctx, p, r)
 	case *path.Metrics:
 		return Metrics(ctx, p, r)
 	case *path.Mesh:
@@ -361,6 +363,8 @@ func ResolveInternal(ctx context.Context, p path.Node, r *path.ResolveConfig) (i
 		return ResourceData(ctx, p, r)
 	case *path.Resources:
 		return Resources(ctx, p.Capture, r)
+	case *path.ResourceUsage:
+		return ResourceUsage(ctx, p.Capture, r)
 	case *path.Result:
 		return Result(ctx, p, r)
 	case *path.Slice:
@@ -373,6 +377,8 @@ func ResolveInternal(ctx context.Context, p path.Node, r *path.ResolveConfig) (i
 		return StateTreeNode(ctx, p, r)
 	case *path.StateTreeNodeForPath:
 		return StateTreeNodeForPath(ctx, p, r)
+	case *path.StateTreeDiff:
+		return StateTreeDiff(ctx, p, r)
 	case *path.Thumbnail:
 		return Thumbnail(ctx, p, r)
 	case *path.Stats:
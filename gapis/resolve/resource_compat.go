@@ -0,0 +1,200 @@
+// Copyright (C) 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package resolve
+
+import (
+	"fmt"
+
+	"github.com/google/gapid/core/image"
+	"github.com/google/gapid/gapis/api"
+)
+
+// checkResourceData returns an error if data cannot be used to replace a
+// resource currently holding old. Shaders must keep their type, buffers must
+// keep their size and textures must keep their kind, layer count and level
+// dimensions. Texel formats are
+// not compared here as the API converts the new data to the format used by
+// the replaced commands.
+func checkResourceData(old, data *api.ResourceData) error {
+	if data == nil {
+		return fmt.Errorf("No resource data given")
+	}
+	if old == nil {
+		return nil // The current data could not be resolved, nothing to compare.
+	}
+	switch old := old.Data.(type) {
+	case *api.ResourceData_Shader:
+		shader := data.GetShader()
+		if shader == nil {
+			return fmt.Errorf("Expected shader data, got %T", data.Data)
+		}
+		if shader.Type != old.Shader.Type {
+			return fmt.Errorf("Expected a %v shader, got a %v shader", old.Shader.Type, shader.Type)
+		}
+	case *api.ResourceData_Texture:
+		texture := data.GetTexture()
+		if texture == nil {
+			return fmt.Errorf("Expected texture data, got %T", data.Data)
+		}
+		return checkTexture(old.Texture, texture)
+	case *api.ResourceData_Buffer:
+		buffer := data.GetBuffer()
+		if buffer == nil {
+			return fmt.Errorf("Expected buffer data, got %T", data.Data)
+		}
+		if buffer.Size != old.Buffer.Size {
+			return fmt.Errorf("Expected %d bytes of buffer data, got %d", old.Buffer.Size, buffer.Size)
+		}
+	}
+	return nil
+}
+
+func checkTexture(old, t *api.Texture) error {
+	switch old := old.Type.(type) {
+	case *api.Texture_Texture_1D:
+		if t := t.GetTexture_1D(); t != nil {
+			return checkLevels(old.Texture_1D.Levels, t.Levels)
+		}
+	case *api.Texture_Texture_1DArray:
+		if t := t.GetTexture_1DArray(); t != nil {
+			if err := checkLayers(len(old.Texture_1DArray.Layers), len(t.Layers)); err != nil {
+				return err
+			}
+			for i, l := range t.Layers {
+				if err := checkLevels(old.Texture_1DArray.Layers[i].Levels, l.Levels); err != nil {
+					return fmt.Errorf("Layer %d: %v", i, err)
+				}
+			}
+			return nil
+		}
+	case *api.Texture_Texture_2D:
+		if t := t.GetTexture_2D(); t != nil {
+			return checkLevels(old.Texture_2D.Levels, t.Levels)
+		}
+	case *api.Texture_Texture_2DArray:
+		if t := t.GetTexture_2DArray(); t != nil {
+			if err := checkLayers(len(old.Texture_2DArray.Layers), len(t.Layers)); err != nil {
+				return err
+			}
+			for i, l := range t.Layers {
+				if err := checkLevels(old.Texture_2DArray.Layers[i].Levels, l.Levels); err != nil {
+					return fmt.Errorf("Layer %d: %v", i, err)
+				}
+			}
+			return nil
+		}
+	case *api.Texture_Texture_3D:
+		if t := t.GetTexture_3D(); t != nil {
+			return checkLevels(old.Texture_3D.Levels, t.Levels)
+		}
+	case *api.Texture_Cubemap:
+		if t := t.GetCubemap(); t != nil {
+			return checkCubemap(old.Cubemap, t)
+		}
+	case *api.Texture_CubemapArray:
+		if t := t.GetCubemapArray(); t != nil {
+			if err := checkLayers(len(old.CubemapArray.Layers), len(t.Layers)); err != nil {
+				return err
+			}
+			for i, l := range t.Layers {
+				if err := checkCubemap(old.CubemapArray.Layers[i], l); err != nil {
+					return fmt.Errorf("Layer %d: %v", i, err)
+				}
+			}
+			return nil
+		}
+	}
+	return fmt.Errorf("Expected a %v, got a %v", textureKind(old), textureKind(t))
+}
+
+func textureKind(t *api.Texture) string {
+	switch t.Type.(type) {
+	case *api.Texture_Texture_1D:
+		return "1D texture"
+	case *api.Texture_Texture_1DArray:
+		return "1D texture array"
+	case *api.Texture_Texture_2D:
+		return "2D texture"
+	case *api.Texture_Texture_2DArray:
+		return "2D texture array"
+	case *api.Texture_Texture_3D:
+		return "3D texture"
+	case *api.Texture_Cubemap:
+		return "cubemap"
+	case *api.Texture_CubemapArray:
+		return "cubemap array"
+	}
+	return "texture of unknown kind"
+}
+
+func checkLayers(old, count int) error {
+	if count != old {
+		return fmt.Errorf("Expected %d layers, got %d", old, count)
+	}
+	return nil
+}
+
+func checkCubemap(old, c *api.Cubemap) error {
+	if len(c.Levels) > len(old.Levels) {
+		return fmt.Errorf("Expected at most %d levels, got %d", len(old.Levels), len(c.Levels))
+	}
+	for i, l := range c.Levels {
+		o := old.Levels[i]
+		for _, face := range []struct {
+			name     string
+			old, new *image.Info
+		}{
+			{"negative X", o.GetNegativeX(), l.GetNegativeX()},
+			{"positive X", o.GetPositiveX(), l.GetPositiveX()},
+			{"negative Y", o.GetNegativeY(), l.GetNegativeY()},
+			{"positive Y", o.GetPositiveY(), l.GetPositiveY()},
+			{"negative Z", o.GetNegativeZ(), l.GetNegativeZ()},
+			{"positive Z", o.GetPositiveZ(), l.GetPositiveZ()},
+		} {
+			if err := checkLevel(face.old, face.new); err != nil {
+				return fmt.Errorf("Level %d %s face: %v", i, face.name, err)
+			}
+		}
+	}
+	return nil
+}
+
+// checkLevels checks that the mip-map levels can replace the old levels.
+// Fewer levels than the original may be given, in which case the remaining
+// levels are left untouched.
+func checkLevels(old, levels []*image.Info) error {
+	if len(levels) > len(old) {
+		return fmt.Errorf("Expected at most %d levels, got %d", len(old), len(levels))
+	}
+	for i, l := range levels {
+		if err := checkLevel(old[i], l); err != nil {
+			return fmt.Errorf("Level %d: %v", i, err)
+		}
+	}
+	return nil
+}
+
+func checkLevel(old, l *image.Info) error {
+	switch {
+	case l == nil:
+		return nil // Level is not replaced.
+	case old == nil:
+		return fmt.Errorf("Level has no data to replace")
+	case l.Width != old.Width || l.Height != old.Height || l.Depth != old.Depth:
+		return fmt.Errorf("Expected size %dx%dx%d, got %dx%dx%d",
+			old.Width, old.Height, old.Depth, l.Width, l.Height, l.Depth)
+	}
+	return nil
+}
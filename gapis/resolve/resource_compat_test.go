@@ -0,0 +1,74 @@
+// Copyright (C) 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package resolve
+
+import (
+	"testing"
+
+	"github.com/google/gapid/core/assert"
+	"github.com/google/gapid/core/image"
+	"github.com/google/gapid/core/log"
+	"github.com/google/gapid/gapis/api"
+)
+
+func TestCheckResourceData(t *testing.T) {
+	ctx := log.Testing(t)
+
+	shader := func(ty api.ShaderType) *api.ResourceData {
+		return api.NewResourceData(&api.Shader{Type: ty, Source: "void main() {}"})
+	}
+	level := func(w, h uint32) *image.Info {
+		return &image.Info{Format: image.RGBA_U8_NORM, Width: w, Height: h, Depth: 1}
+	}
+	tex2D := func(levels ...*image.Info) *api.ResourceData {
+		return api.NewResourceData(api.NewTexture(&api.Texture2D{Levels: levels}))
+	}
+	buffer := func(size uint64) *api.ResourceData {
+		return api.NewResourceData(&api.Buffer{Size: size})
+	}
+	png := level(8, 8)
+	png.Format = image.PNG
+
+	old := tex2D(level(8, 8), level(4, 4))
+	for _, test := range []struct {
+		name string
+		old  *api.ResourceData
+		data *api.ResourceData
+		ok   bool
+	}{
+		{"shader", shader(api.ShaderType_Fragment), shader(api.ShaderType_Fragment), true},
+		{"shader type", shader(api.ShaderType_Fragment), shader(api.ShaderType_Vertex), false},
+		{"shader kind", shader(api.ShaderType_Fragment), old, false},
+		{"unknown old data", nil, shader(api.ShaderType_Vertex), true},
+		{"no data", old, nil, false},
+		{"all levels", old, tex2D(level(8, 8), level(4, 4)), true},
+		{"top level", old, tex2D(png), true},
+		{"too many levels", old, tex2D(level(8, 8), level(4, 4), level(2, 2)), false},
+		{"level size", old, tex2D(level(8, 4)), false},
+		{"texture kind", old, api.NewResourceData(api.NewTexture(&api.Texture3D{
+			Levels: []*image.Info{level(8, 8)},
+		})), false},
+		{"buffer", buffer(16), buffer(16), true},
+		{"buffer size", buffer(16), buffer(8), false},
+		{"buffer kind", buffer(16), old, false},
+	} {
+		err := checkResourceData(test.old, test.data)
+		if test.ok {
+			assert.For(ctx, "%v", test.name).ThatError(err).Succeeded()
+		} else {
+			assert.For(ctx, "%v", test.name).ThatError(err).Failed()
+		}
+	}
+}
@@ -44,16 +44,19 @@ func (r *ResourceMetaResolvable) Resolve(ctx context.Context) (interface{}, erro
 	}
 	ids := r.IDs
 	values := make([]api.Resource, len(ids))
+	data := make([]*api.ResourceData, len(ids))
 	for i, id := range ids {
 		val, ok := res.resources[id.ID()]
 		if !ok {
 			return nil, fmt.Errorf("Could not find resource %v", id.ID())
 		}
 		values[i] = val
+		data[i], _ = res.resourceData[id.ID()].(*api.ResourceData)
 	}
 	result := &api.ResourceMeta{
 		IDMap:     res.resourceMap,
 		Resources: values,
+		Data:      data,
 	}
 	return result, nil
 }
@@ -0,0 +1,65 @@
+// Copyright (C) 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package resolve
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/google/gapid/gapis/database"
+	"github.com/google/gapid/gapis/service"
+	"github.com/google/gapid/gapis/service/path"
+)
+
+// ResourceUsage resolves the usage timelines of all the resources used by the
+// specified capture.
+func ResourceUsage(ctx context.Context, c *path.Capture, r *path.ResolveConfig) (*service.ResourceUsages, error) {
+	obj, err := database.Build(ctx, &ResourceUsageResolvable{Capture: c, Config: r})
+	if err != nil {
+		return nil, err
+	}
+	return obj.(*service.ResourceUsages), nil
+}
+
+// Resolve implements the database.Resolver interface.
+func (r *ResourceUsageResolvable) Resolve(ctx context.Context) (interface{}, error) {
+	ctx = SetupContext(ctx, r.Capture, r.Config)
+
+	resources, err := trackResources(ctx, r.Capture, true)
+	if err != nil {
+		return nil, err
+	}
+
+	out := &service.ResourceUsages{
+		Resources: make([]*service.ResourceUsage, len(resources)),
+	}
+	for i, tr := range resources {
+		u := &service.ResourceUsage{
+			ID:     path.NewID(tr.id),
+			Type:   tr.resource.ResourceType(ctx),
+			Handle: tr.resource.ResourceHandle() + fmt.Sprintf("<%d>", tr.created),
+			Label:  tr.resource.ResourceLabel(),
+			Uses:   make([]*service.ResourceUse, len(tr.uses)),
+		}
+		for j, use := range tr.uses {
+			u.Uses[j] = &service.ResourceUse{
+				Command: r.Capture.Command(use.cmd),
+				Kind:    use.kind,
+			}
+		}
+		out.Resources[i] = u
+	}
+	return out, nil
+}
@@ -41,12 +41,42 @@ func Resources(ctx context.Context, c *path.Capture, r *path.ResolveConfig) (*se
 func (r *ResourcesResolvable) Resolve(ctx context.Context) (interface{}, error) {
 	ctx = SetupContext(ctx, r.Capture, r.Config)
 
+	resources, err := trackResources(ctx, r.Capture, false)
+	if err != nil {
+		return nil, err
+	}
+
+	types := map[api.ResourceType]*service.ResourcesByType{}
+	for _, tr := range resources {
+		ty := tr.resource.ResourceType(ctx)
+		b := types[ty]
+		if b == nil {
+			b = &service.ResourcesByType{Type: ty}
+			types[ty] = b
+		}
+		b.Resources = append(b.Resources, tr.asService(r.Capture))
+	}
+
+	out := &service.Resources{Types: make([]*service.ResourcesByType, 0, len(types))}
+	for _, v := range types {
+		out.Types = append(out.Types, v)
+	}
+
+	return out, nil
+}
+
+// trackResources mutates the commands of the capture p, and returns the
+// resources created by the capture and its initial state. Temporary resources
+// destroyed while reconstructing the initial state are not returned.
+// If uses is true, the kind of each use of the resources is also tracked, per
+// resource for the APIs implementing api.ResourceUseReporter.
+func trackResources(ctx context.Context, p *path.Capture, uses bool) ([]*trackedResource, error) {
 	c, err := capture.Resolve(ctx)
 	if err != nil {
 		return nil, err
 	}
 
-	resources := []trackedResource{}
+	resources := []*trackedResource{}
 	seen := map[api.Resource]int{}
 
 	var currentCmdIndex uint64
@@ -54,7 +84,7 @@ func (r *ResourcesResolvable) Resolve(ctx context.Context) (interface{}, error)
 	var currentThread uint64
 	var currentAPI api.API
 	// If the capture contains initial state, build the necessary commands to recreate it.
-	initialCmds, ranges, err := initialcmds.InitialCommands(ctx, r.Capture)
+	initialCmds, ranges, err := initialcmds.InitialCommands(ctx, p)
 	if err != nil {
 		return nil, err
 	}
@@ -63,13 +93,14 @@ func (r *ResourcesResolvable) Resolve(ctx context.Context) (interface{}, error)
 		currentCmdResourceCount++
 		seen[res] = len(seen)
 		context := currentAPI.Context(ctx, state, currentThread)
-		resources = append(resources, trackedResource{
+		resources = append(resources, &trackedResource{
 			resource: res,
 			id:       genResourceID(currentCmdIndex, currentCmdResourceCount),
-			context:  r.Capture.Context(id.ID(context.ID())),
+			context:  p.Context(id.ID(context.ID())),
 			accesses: []uint64{currentCmdIndex},
 			created:  currentCmdIndex,
 		})
+		resources[len(resources)-1].use(currentCmdIndex, service.ResourceUseKind_Create)
 	}
 	state.OnResourceAccessed = func(r api.Resource) {
 		if index, ok := seen[r]; ok { // Update the list of accesses
@@ -98,6 +129,7 @@ func (r *ResourcesResolvable) Resolve(ctx context.Context) (interface{}, error)
 	state.OnResourceDestroyed = func(r api.Resource) {
 		if index, ok := seen[r]; ok {
 			resources[index].deleted = currentCmdIndex
+			resources[index].use(currentCmdIndex, service.ResourceUseKind_Delete)
 		}
 	}
 
@@ -106,30 +138,98 @@ func (r *ResourcesResolvable) Resolve(ctx context.Context) (interface{}, error)
 		currentCmdIndex = uint64(id)
 		currentThread = cmd.Thread()
 		currentAPI = cmd.API()
-		cmd.Mutate(ctx, id, state, nil, nil)
+		if !uses {
+			cmd.Mutate(ctx, id, state, nil, nil)
+			return nil
+		}
+		for res, kind := range mutateResourceUses(ctx, id, cmd, state) {
+			if index, ok := seen[res]; ok {
+				resources[index].use(currentCmdIndex, kind)
+			}
+		}
 		return nil
 	})
 
-	types := map[api.ResourceType]*service.ResourcesByType{}
+	out := make([]*trackedResource, 0, len(seen))
 	for _, tr := range resources {
-		if _, ok := seen[tr.resource]; !ok {
-			continue
+		if _, ok := seen[tr.resource]; ok {
+			out = append(out, tr)
 		}
-		ty := tr.resource.ResourceType(ctx)
-		b := types[ty]
-		if b == nil {
-			b = &service.ResourcesByType{Type: ty}
-			types[ty] = b
+	}
+	return out, nil
+}
+
+// resourceUseKind returns the kind of use of the resources accessed by the
+// command cmd of an API that does not implement api.ResourceUseReporter, based
+// on the command's flags and observations. Draw calls read the resources they
+// access, clears and commands reading application memory modify them, and any
+// other command only binds them.
+func resourceUseKind(ctx context.Context, id api.CmdID, cmd api.Cmd, s *api.GlobalState) service.ResourceUseKind {
+	switch f := cmd.CmdFlags(ctx, id, s); {
+	case f.IsClear():
+		return service.ResourceUseKind_Modify
+	case f.IsDrawCall(), f.IsExecutedDraw(), f.IsTransformFeedback():
+		return service.ResourceUseKind_Read
+	}
+	if o := cmd.Extras().Observations(); o != nil && len(o.Reads) > 0 {
+		return service.ResourceUseKind_Modify
+	}
+	return service.ResourceUseKind_Bind
+}
+
+// mutateResourceUses mutates the command cmd on the state s, and returns the
+// kind of use of each resource accessed by the command. If the command's API
+// implements api.ResourceUseReporter, the resources read or written by the
+// command are classified individually.
+func mutateResourceUses(ctx context.Context, id api.CmdID, cmd api.Cmd, s *api.GlobalState) map[api.Resource]service.ResourceUseKind {
+	accessed := []api.Resource{}
+	onAccessed := s.OnResourceAccessed
+	s.OnResourceAccessed = func(r api.Resource) {
+		accessed = append(accessed, r)
+		if onAccessed != nil {
+			onAccessed(r)
 		}
-		b.Resources = append(b.Resources, tr.asService(r.Capture))
 	}
+	defer func() { s.OnResourceAccessed = onAccessed }()
 
-	out := &service.Resources{Types: make([]*service.ResourcesByType, 0, len(types))}
-	for _, v := range types {
-		out.Types = append(out.Types, v)
+	out := map[api.Resource]service.ResourceUseKind{}
+	r, ok := cmd.API().(api.ResourceUseReporter)
+	if !ok {
+		kind := resourceUseKind(ctx, id, cmd, s)
+		cmd.Mutate(ctx, id, s, nil, nil)
+		for _, res := range accessed {
+			out[res] = kind
+		}
+		return out
 	}
 
-	return out, nil
+	kind := unreportedUseKind(ctx, id, cmd, s)
+	reads, writes, _ := r.MutateResourceUses(ctx, id, cmd, s)
+	for _, res := range accessed {
+		out[res] = kind
+	}
+	for _, res := range reads {
+		out[res] = service.ResourceUseKind_Read
+	}
+	for _, res := range writes {
+		out[res] = service.ResourceUseKind_Modify
+	}
+	return out
+}
+
+// unreportedUseKind returns the kind of use of the resources accessed by the
+// command cmd of an api.ResourceUseReporter API, that the API reports as
+// neither read nor written. Commands reading application memory, other than
+// draw calls and clears, modify them, and any other command only binds them.
+func unreportedUseKind(ctx context.Context, id api.CmdID, cmd api.Cmd, s *api.GlobalState) service.ResourceUseKind {
+	switch f := cmd.CmdFlags(ctx, id, s); {
+	case f.IsClear(), f.IsDrawCall(), f.IsExecutedDraw(), f.IsTransformFeedback():
+		return service.ResourceUseKind_Bind
+	}
+	if o := cmd.Extras().Observations(); o != nil && len(o.Reads) > 0 {
+		return service.ResourceUseKind_Modify
+	}
+	return service.ResourceUseKind_Bind
 }
 
 type trackedResource struct {
@@ -140,6 +240,23 @@ type trackedResource struct {
 	accesses []uint64
 	deleted  uint64
 	created  uint64
+	uses     []resourceUse
+}
+
+type resourceUse struct {
+	cmd  uint64
+	kind service.ResourceUseKind
+}
+
+// use records the use of the resource by the command at index cmd, unless the
+// command has already used it in the same way.
+func (r *trackedResource) use(cmd uint64, kind service.ResourceUseKind) {
+	for i := len(r.uses) - 1; i >= 0 && r.uses[i].cmd == cmd; i-- {
+		if r.uses[i].kind == kind {
+			return
+		}
+	}
+	r.uses = append(r.uses, resourceUse{cmd, kind})
 }
 
 func (r trackedResource) asService(p *path.Capture) *service.Resource {
@@ -0,0 +1,119 @@
+// Copyright (C) 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package resolve
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/gapid/core/assert"
+	"github.com/google/gapid/core/log"
+	"github.com/google/gapid/core/os/device"
+	"github.com/google/gapid/gapis/api"
+	"github.com/google/gapid/gapis/replay/builder"
+	"github.com/google/gapid/gapis/service"
+)
+
+type useTestResource struct {
+	api.Resource
+	name string
+}
+
+// useTestAPI is an API that does not report the resource uses of its commands.
+type useTestAPI struct {
+	api.API
+}
+
+// useTestReporterAPI is an API that reports the resources read and written by
+// its useTestCmd commands.
+type useTestReporterAPI struct {
+	useTestAPI
+}
+
+func (useTestReporterAPI) MutateResourceUses(ctx context.Context, id api.CmdID, cmd api.Cmd, s *api.GlobalState) (reads, writes []api.Resource, err error) {
+	c := cmd.(*useTestCmd)
+	return c.reads, c.writes, c.Mutate(ctx, id, s, nil, nil)
+}
+
+// useTestCmd is a command accessing the resources accessed, and reading and
+// writing the resources reads and writes.
+type useTestCmd struct {
+	api.Cmd
+	a        api.API
+	flags    api.CmdFlags
+	extras   api.CmdExtras
+	accessed []api.Resource
+	reads    []api.Resource
+	writes   []api.Resource
+}
+
+func (c *useTestCmd) API() api.API { return c.a }
+
+func (c *useTestCmd) CmdFlags(context.Context, api.CmdID, *api.GlobalState) api.CmdFlags {
+	return c.flags
+}
+
+func (c *useTestCmd) Extras() *api.CmdExtras { return &c.extras }
+
+func (c *useTestCmd) Mutate(ctx context.Context, id api.CmdID, s *api.GlobalState, b *builder.Builder, w api.StateWatcher) error {
+	for _, r := range c.accessed {
+		s.OnResourceAccessed(r)
+	}
+	return nil
+}
+
+func TestMutateResourceUses(t *testing.T) {
+	ctx := log.Testing(t)
+
+	sampled := &useTestResource{name: "sampled"}
+	target := &useTestResource{name: "target"}
+	bound := &useTestResource{name: "bound"}
+	all := []api.Resource{sampled, target, bound}
+
+	observed := api.CmdExtras{&api.CmdObservations{Reads: []api.CmdObservation{{}}}}
+
+	plain, reporter := useTestAPI{}, useTestReporterAPI{}
+	type uses map[api.Resource]service.ResourceUseKind
+	for _, test := range []struct {
+		name     string
+		cmd      *useTestCmd
+		expected uses
+	}{
+		{"draw", &useTestCmd{a: reporter, flags: api.DrawCall, accessed: all, reads: []api.Resource{sampled}, writes: []api.Resource{target}},
+			uses{sampled: service.ResourceUseKind_Read, target: service.ResourceUseKind_Modify, bound: service.ResourceUseKind_Bind}},
+		{"clear", &useTestCmd{a: reporter, flags: api.Clear, accessed: all, writes: []api.Resource{target}},
+			uses{sampled: service.ResourceUseKind_Bind, target: service.ResourceUseKind_Modify, bound: service.ResourceUseKind_Bind}},
+		{"unaccessed write", &useTestCmd{a: reporter, flags: api.DrawCall, accessed: []api.Resource{sampled}, reads: []api.Resource{sampled}, writes: []api.Resource{target}},
+			uses{sampled: service.ResourceUseKind_Read, target: service.ResourceUseKind_Modify}},
+		{"upload", &useTestCmd{a: reporter, extras: observed, accessed: []api.Resource{bound}},
+			uses{bound: service.ResourceUseKind_Modify}},
+		{"bind", &useTestCmd{a: reporter, accessed: []api.Resource{bound}},
+			uses{bound: service.ResourceUseKind_Bind}},
+		{"unreported draw", &useTestCmd{a: plain, flags: api.DrawCall, accessed: all},
+			uses{sampled: service.ResourceUseKind_Read, target: service.ResourceUseKind_Read, bound: service.ResourceUseKind_Read}},
+		{"unreported clear", &useTestCmd{a: plain, flags: api.Clear, accessed: []api.Resource{target}},
+			uses{target: service.ResourceUseKind_Modify}},
+	} {
+		s := api.NewStateWithEmptyAllocator(device.Little32)
+		accesses := 0
+		s.OnResourceAccessed = func(api.Resource) { accesses++ }
+
+		got := mutateResourceUses(ctx, 0, test.cmd, s)
+		assert.For(ctx, "%v uses", test.name).That(uses(got)).DeepEquals(test.expected)
+		assert.For(ctx, "%v accesses", test.name).That(accesses).Equals(len(test.cmd.accessed))
+		s.OnResourceAccessed(nil)
+		assert.For(ctx, "%v restored", test.name).That(accesses).Equals(len(test.cmd.accessed) + 1)
+	}
+}
@@ -299,6 +299,15 @@ func changeResources(ctx context.Context, a arena.Arena, after *path.Command, id
 	if len(meta.Resources) != len(ids) {
 		return nil, fmt.Errorf("Expected %d resource(s), got %d", len(ids), len(meta.Resources))
 	}
+	if len(data) != len(ids) {
+		return nil, fmt.Errorf("Expected data for %d resource(s), got %d", len(ids), len(data))
+	}
+	for i, resource := range meta.Resources {
+		if err := checkResourceData(meta.Data[i], data[i]); err != nil {
+			return nil, fmt.Errorf("Cannot replace %v %v: %v",
+				resource.ResourceType(ctx), resource.ResourceHandle(), err)
+		}
+	}
 
 	cmdIdx := after.Indices[0]
 	// If we change resource data, subcommands do not affect this, so change
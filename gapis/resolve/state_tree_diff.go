@@ -0,0 +1,158 @@
+// Copyright (C) 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package resolve
+
+import (
+	"context"
+	"reflect"
+
+	"github.com/google/gapid/core/event/task"
+	"github.com/google/gapid/gapis/database"
+	"github.com/google/gapid/gapis/service"
+	"github.com/google/gapid/gapis/service/path"
+)
+
+// StateTreeDiff resolves the differences between the state trees of the two
+// states of the specified path.
+func StateTreeDiff(ctx context.Context, p *path.StateTreeDiff, r *path.ResolveConfig) (*service.StateTreeDiff, error) {
+	from, err := resolveStateTree(ctx, p.From, r)
+	if err != nil {
+		return nil, err
+	}
+	to, err := resolveStateTree(ctx, p.To, r)
+	if err != nil {
+		return nil, err
+	}
+	d := &stateTreeDiff{
+		from:   from,
+		to:     to,
+		depth:  int(p.Depth),
+		filter: p.Filter,
+		out:    &service.StateTreeDiff{},
+	}
+	if err := d.diff(ctx, from.root, to.root, nil); err != nil {
+		return nil, err
+	}
+	return d.out, nil
+}
+
+// resolveStateTree returns the ungrouped state tree for the state p.
+func resolveStateTree(ctx context.Context, p *path.State, r *path.ResolveConfig) (*stateTree, error) {
+	id, err := database.Store(ctx, &StateTreeResolvable{Path: p, Config: r})
+	if err != nil {
+		return nil, err
+	}
+	boxed, err := database.Resolve(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	return boxed.(*stateTree), nil
+}
+
+type stateTreeDiff struct {
+	from, to *stateTree
+	depth    int
+	filter   []string
+	out      *service.StateTreeDiff
+}
+
+// diff compares the children of the nodes a and b, which have the given names
+// from the child of the root node, and appends the differences to d.out.
+func (d *stateTreeDiff) diff(ctx context.Context, a, b *stn, names []string) error {
+	if task.Stopped(ctx) {
+		return task.StopReason(ctx)
+	}
+
+	a.buildChildren(ctx, d.from)
+	b.buildChildren(ctx, d.to)
+
+	depth := len(names) + 1
+	matches := func(name string) bool {
+		if depth > len(d.filter) {
+			return true
+		}
+		return d.filter[depth-1] == "*" || d.filter[depth-1] == name
+	}
+
+	byName := make(map[string]*stn, len(b.children))
+	for _, c := range b.children {
+		if _, ok := byName[c.name]; !ok {
+			byName[c.name] = c
+		}
+	}
+
+	seen := make(map[string]bool, len(a.children))
+	for _, ac := range a.children {
+		if seen[ac.name] || !matches(ac.name) {
+			continue
+		}
+		seen[ac.name] = true
+		childNames := append(names[:len(names):len(names)], ac.name)
+		bc, ok := byName[ac.name]
+		if !ok {
+			d.add(service.StateTreeChange_Removed, childNames, ac, ac, nil)
+			continue
+		}
+		if d.depth > 0 && depth >= d.depth {
+			if !reflect.DeepEqual(ac.value.Interface(), bc.value.Interface()) {
+				d.add(service.StateTreeChange_Changed, childNames, bc, ac, bc)
+			}
+			continue
+		}
+		if err := d.diffNode(ctx, ac, bc, childNames); err != nil {
+			return err
+		}
+	}
+	for _, bc := range b.children {
+		if seen[bc.name] || !matches(bc.name) {
+			continue
+		}
+		seen[bc.name] = true
+		d.add(service.StateTreeChange_Added, append(names[:len(names):len(names)], bc.name), bc, nil, bc)
+	}
+	return nil
+}
+
+// diffNode compares the nodes a and b, reporting a change if they are leaves
+// with different values, or recursing into their children otherwise.
+func (d *stateTreeDiff) diffNode(ctx context.Context, a, b *stn, names []string) error {
+	a.buildChildren(ctx, d.from)
+	b.buildChildren(ctx, d.to)
+	if len(a.children) > 0 || len(b.children) > 0 {
+		return d.diff(ctx, a, b, names)
+	}
+	if !reflect.DeepEqual(a.value.Interface(), b.value.Interface()) {
+		d.add(service.StateTreeChange_Changed, names, b, a, b)
+	}
+	return nil
+}
+
+// add appends a difference for the node n to d.out, with the preview values of
+// the nodes before and after the change, if any.
+func (d *stateTreeDiff) add(change service.StateTreeChange, names []string, n, before, after *stn) {
+	diff := &service.StateTreeNodeDiff{
+		Change:    change,
+		Names:     names,
+		ValuePath: n.path.Path(),
+		Constants: n.consts,
+	}
+	if before != nil {
+		diff.OldValue, _ = stateValuePreview(before.value)
+	}
+	if after != nil {
+		diff.NewValue, _ = stateValuePreview(after.value)
+	}
+	d.out.Changes = append(d.out.Changes, diff)
+}
@@ -0,0 +1,130 @@
+// Copyright (C) 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package resolve
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/google/gapid/core/assert"
+	"github.com/google/gapid/core/log"
+	"github.com/google/gapid/gapis/api"
+	"github.com/google/gapid/gapis/service"
+	"github.com/google/gapid/gapis/service/path"
+)
+
+type diffTestObject struct {
+	Name  string
+	Count int
+}
+
+func (o diffTestObject) Properties() api.Properties {
+	return api.Properties{
+		api.NewProperty("Name", func() string { return o.Name }, nil),
+		api.NewProperty("Count", func() int { return o.Count }, nil),
+	}
+}
+
+type diffTestState struct {
+	Enabled bool
+	Objects map[int]*diffTestObject
+	Values  []int
+}
+
+func (s diffTestState) Properties() api.Properties {
+	return api.Properties{
+		api.NewProperty("Enabled", func() bool { return s.Enabled }, nil),
+		api.NewProperty("Objects", func() map[int]*diffTestObject { return s.Objects }, nil),
+		api.NewProperty("Values", func() []int { return s.Values }, nil),
+	}
+}
+
+func TestStateTreeDiff(t *testing.T) {
+	ctx := log.Testing(t)
+
+	from := diffTestState{
+		Enabled: false,
+		Objects: map[int]*diffTestObject{
+			1: {Name: "a", Count: 1},
+			2: {Name: "b", Count: 2},
+		},
+		Values: []int{1, 2},
+	}
+	to := diffTestState{
+		Enabled: true,
+		Objects: map[int]*diffTestObject{
+			1: {Name: "a", Count: 5},
+			3: {Name: "c", Count: 3},
+		},
+		Values: []int{1, 2, 3},
+	}
+
+	tree := func(s diffTestState) *stateTree {
+		root := &stn{
+			name:  "root",
+			value: reflect.ValueOf(s),
+			path:  (&path.Capture{}).Command(0).StateAfter(),
+		}
+		return &stateTree{root: root}
+	}
+
+	for _, test := range []struct {
+		name     string
+		depth    int
+		filter   []string
+		expected []string
+	}{
+		{"all", 0, nil, []string{
+			"~ Enabled",
+			"~ Objects.1.Count",
+			"- Objects.2",
+			"+ Objects.3",
+			"+ Values.2",
+		}},
+		{"depth", 2, nil, []string{
+			"~ Enabled",
+			"~ Objects.1",
+			"- Objects.2",
+			"+ Objects.3",
+			"+ Values.2",
+		}},
+		{"filter", 0, []string{"Objects", "*", "Count"}, []string{
+			"~ Objects.1.Count",
+			"- Objects.2",
+			"+ Objects.3",
+		}},
+		{"filter none", 0, []string{"Missing"}, []string{}},
+	} {
+		d := &stateTreeDiff{
+			from:   tree(from),
+			to:     tree(to),
+			depth:  test.depth,
+			filter: test.filter,
+			out:    &service.StateTreeDiff{},
+		}
+		assert.For(ctx, "%v err", test.name).ThatError(d.diff(ctx, d.from.root, d.to.root, nil)).Succeeded()
+		got := []string{}
+		for _, c := range d.out.Changes {
+			prefix := map[service.StateTreeChange]string{
+				service.StateTreeChange_Changed: "~",
+				service.StateTreeChange_Added:   "+",
+				service.StateTreeChange_Removed: "-",
+			}[c.Change]
+			got = append(got, prefix+" "+strings.Join(c.Names, "."))
+		}
+		assert.For(ctx, "%v changes", test.name).ThatSlice(got).Equals(test.expected)
+	}
+}
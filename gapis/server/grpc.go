@@ -210,6 +210,15 @@ func (s *grpcServer) Set(ctx xctx.Context, req *service.SetRequest) (*service.Se
 	return &service.SetResponse{Res: &service.SetResponse_Path{Path: res}}, nil
 }
 
+func (s *grpcServer) UploadBlob(ctx xctx.Context, req *service.UploadBlobRequest) (*service.UploadBlobResponse, error) {
+	defer s.inRPC()()
+	blob, err := s.handler.UploadBlob(s.bindCtx(ctx), req.Data)
+	if err := service.NewError(err); err != nil {
+		return &service.UploadBlobResponse{Res: &service.UploadBlobResponse_Error{Error: err}}, nil
+	}
+	return &service.UploadBlobResponse{Res: &service.UploadBlobResponse_Blob{Blob: blob}}, nil
+}
+
 func (s *grpcServer) Follow(ctx xctx.Context, req *service.FollowRequest) (*service.FollowResponse, error) {
 	defer s.inRPC()()
 	res, err := s.handler.Follow(s.bindCtx(ctx), req.Path, req.Config)
@@ -483,6 +492,16 @@ func (s *grpcServer) GetLogStream(req *service.GetLogStreamRequest, server servi
 	return s.handler.GetLogStream(s.bindCtx(ctx), h)
 }
 
+func (s *grpcServer) GetStatus(req *service.GetStatusRequest, server service.Gapid_GetStatusServer) error {
+	// defer s.inRPC()() -- don't consider the status stream an inflight RPC.
+	ctx, cancel := task.WithCancel(server.Context())
+	defer s.addInterrupter(cancel)()
+
+	memorySnapshotInterval := time.Duration(req.MemorySnapshotInterval) * time.Millisecond
+	statusUpdateFrequency := time.Duration(req.StatusUpdateFrequency) * time.Millisecond
+	return s.handler.GetStatus(s.bindCtx(ctx), memorySnapshotInterval, statusUpdateFrequency, server.Send)
+}
+
 func (s *grpcServer) Find(req *service.FindRequest, server service.Gapid_FindServer) error {
 	defer s.inRPC()()
 	ctx := server.Context()
@@ -36,14 +36,17 @@ import (
 	"github.com/google/gapid/core/app/auth"
 	"github.com/google/gapid/core/app/benchmark"
 	"github.com/google/gapid/core/app/status"
+	"github.com/google/gapid/core/data/apitrace"
 	"github.com/google/gapid/core/event/task"
 	"github.com/google/gapid/core/log"
 	"github.com/google/gapid/core/os/android/adb"
 	"github.com/google/gapid/core/os/device/bind"
 	"github.com/google/gapid/core/os/file"
 	"github.com/google/gapid/gapis/api"
+	"github.com/google/gapid/gapis/api/gles"
 	"github.com/google/gapid/gapis/capture"
 	"github.com/google/gapid/gapis/config"
+	"github.com/google/gapid/gapis/database"
 	"github.com/google/gapid/gapis/messages"
 	"github.com/google/gapid/gapis/replay"
 	"github.com/google/gapid/gapis/replay/devices"
@@ -184,7 +187,7 @@ func (s *server) ImportCapture(ctx context.Context, name string, data []uint8) (
 	defer status.Finish(ctx)
 	ctx = log.Enter(ctx, "ImportCapture")
 	src := &capture.Blob{Data: data}
-	p, err := capture.Import(ctx, name, src)
+	p, err := importCapture(ctx, name, src)
 	if err != nil {
 		return nil, err
 	}
@@ -224,6 +227,36 @@ func ReadFile(f *os.File) ([]byte, error) {
 	return in, nil
 }
 
+// importCapture imports the capture data from src, converting apitrace
+// traces to GLES captures.
+func importCapture(ctx context.Context, name string, src capture.Source) (*path.Capture, error) {
+	isTrace, err := isAPITrace(src)
+	if err != nil {
+		return nil, err
+	}
+	if !isTrace {
+		return capture.Import(ctx, name, src)
+	}
+	r, err := src.ReadCloser()
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	return gles.ImportAPITrace(ctx, name, r)
+}
+
+// isAPITrace returns true if the data of src starts with an apitrace header.
+func isAPITrace(src capture.Source) (bool, error) {
+	r, err := src.ReadCloser()
+	if err != nil {
+		return false, err
+	}
+	defer r.Close()
+	header := make([]byte, 2)
+	n, _ := io.ReadFull(r, header)
+	return apitrace.IsTrace(header[:n]), nil
+}
+
 func (s *server) LoadCapture(ctx context.Context, path string) (*path.Capture, error) {
 	ctx = status.Start(ctx, "RPC LoadCapture")
 	defer status.Finish(ctx)
@@ -234,7 +267,7 @@ func (s *server) LoadCapture(ctx context.Context, path string) (*path.Capture, e
 	name := filepath.Base(path)
 
 	src := &capture.File{Path: path}
-	p, err := capture.Import(ctx, name, src)
+	p, err := importCapture(ctx, name, src)
 	if err != nil {
 		return nil, err
 	}
@@ -407,6 +440,17 @@ func (s *server) Set(ctx context.Context, p *path.Any, v interface{}, r *path.Re
 	return resolve.Set(ctx, p, v, r)
 }
 
+func (s *server) UploadBlob(ctx context.Context, data []byte) (*path.Blob, error) {
+	ctx = status.Start(ctx, "RPC UploadBlob")
+	defer status.Finish(ctx)
+	ctx = log.Enter(ctx, "UploadBlob")
+	id, err := database.Store(ctx, data)
+	if err != nil {
+		return nil, err
+	}
+	return path.NewBlob(id), nil
+}
+
 func (s *server) Follow(ctx context.Context, p *path.Any, r *path.ResolveConfig) (*path.Any, error) {
 	ctx = status.Start(ctx, "RPC Follow")
 	defer status.Finish(ctx)
@@ -0,0 +1,151 @@
+// Copyright (C) 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"bytes"
+	"io"
+	"testing"
+
+	"github.com/google/gapid/core/assert"
+	"github.com/google/gapid/core/data/apitrace"
+	"github.com/google/gapid/core/log"
+	"github.com/google/gapid/core/memory/arena"
+	"github.com/google/gapid/core/os/device"
+	"github.com/google/gapid/core/os/device/bind"
+	"github.com/google/gapid/gapis/api"
+	"github.com/google/gapid/gapis/api/gles"
+	"github.com/google/gapid/gapis/capture"
+	"github.com/google/gapid/gapis/database"
+)
+
+// trackedSource is a capture.Source counting the readers opened and closed.
+type trackedSource struct {
+	src            capture.Source
+	opened, closed int
+}
+
+type trackedReader struct {
+	io.Reader
+	src *trackedSource
+}
+
+func (s *trackedSource) ReadCloser() (io.ReadCloser, error) {
+	r, err := s.src.ReadCloser()
+	if err != nil {
+		return nil, err
+	}
+	s.opened++
+	return trackedReader{r, s}, nil
+}
+
+func (r trackedReader) Close() error {
+	r.src.closed++
+	return nil
+}
+
+func TestImportCaptureAPITrace(t *testing.T) {
+	ctx := log.Testing(t)
+	ctx = bind.PutRegistry(ctx, bind.NewRegistry())
+	ctx = database.Put(ctx, database.NewInMemory(ctx))
+
+	display, surface, eglContext := apitrace.Opaque(0x10), apitrace.Opaque(0x20), apitrace.Opaque(0x30)
+	buf := &bytes.Buffer{}
+	w, err := apitrace.NewWriter(buf, nil)
+	assert.For(ctx, "NewWriter").ThatError(err).Succeeded()
+	for _, c := range []*apitrace.Call{
+		{Name: "eglCreateContext", Args: []apitrace.Arg{
+			{Value: display}, {Value: apitrace.Opaque(0x40)}, {Value: nil}, {Value: nil}}, Ret: eglContext},
+		{Name: "eglMakeCurrent", Args: []apitrace.Arg{
+			{Value: display}, {Value: surface}, {Value: surface}, {Value: eglContext}}, Ret: true},
+		{Name: "glClear", Args: []apitrace.Arg{{Value: apitrace.Bitmask{Value: 0x4000}}}},
+	} {
+		assert.For(ctx, "Write").ThatError(w.Write(c)).Succeeded()
+	}
+	assert.For(ctx, "Close").ThatError(w.Close()).Succeeded()
+
+	src := &trackedSource{src: &capture.Blob{Data: buf.Bytes()}}
+	p, err := importCapture(ctx, "apitrace", src)
+	if !assert.For(ctx, "importCapture").ThatError(err).Succeeded() {
+		return
+	}
+	assert.For(ctx, "readers opened").That(src.opened).Equals(2)
+	assert.For(ctx, "readers closed").That(src.closed).Equals(2)
+
+	c, err := capture.ResolveFromPath(ctx, p)
+	if !assert.For(ctx, "ResolveFromPath").ThatError(err).Succeeded() {
+		return
+	}
+	names := make([]string, len(c.Commands))
+	for i, cmd := range c.Commands {
+		names[i] = cmd.CmdName()
+	}
+	assert.For(ctx, "commands").ThatSlice(names).Equals([]string{
+		"eglCreateContext",
+		"eglMakeCurrent",
+		"glClear",
+	})
+}
+
+func TestImportCapturePack(t *testing.T) {
+	ctx := log.Testing(t)
+	ctx = bind.PutRegistry(ctx, bind.NewRegistry())
+	ctx = database.Put(ctx, database.NewInMemory(ctx))
+
+	a := arena.New()
+	defer a.Dispose()
+	cb := gles.CommandBuilder{Thread: 0, Arena: a}
+	cmds := []api.Cmd{
+		cb.GlClearColor(0.5, 0, 0, 1),
+		cb.GlClear(gles.GLbitfield_GL_COLOR_BUFFER_BIT),
+	}
+	h := &capture.Header{ABI: device.WindowsX86_64}
+	original, err := capture.New(ctx, a, "pack", h, nil, cmds)
+	if !assert.For(ctx, "New").ThatError(err).Succeeded() {
+		return
+	}
+	buf := &bytes.Buffer{}
+	if !assert.For(ctx, "Export").ThatError(capture.Export(ctx, original, buf)).Succeeded() {
+		return
+	}
+
+	isTrace, err := isAPITrace(&capture.Blob{Data: buf.Bytes()})
+	assert.For(ctx, "isAPITrace").ThatError(err).Succeeded()
+	assert.For(ctx, "isAPITrace").That(isTrace).Equals(false)
+
+	p, err := importCapture(ctx, "pack", &capture.Blob{Data: buf.Bytes()})
+	if !assert.For(ctx, "importCapture").ThatError(err).Succeeded() {
+		return
+	}
+	c, err := capture.ResolveFromPath(ctx, p)
+	if !assert.For(ctx, "ResolveFromPath").ThatError(err).Succeeded() {
+		return
+	}
+	assert.For(ctx, "commands").That(len(c.Commands)).Equals(len(cmds))
+}
+
+func TestUploadBlob(t *testing.T) {
+	ctx := log.Testing(t)
+	ctx = database.Put(ctx, database.NewInMemory(ctx))
+
+	data := []byte{1, 2, 3, 4}
+	blob, err := (&server{}).UploadBlob(ctx, data)
+	if !assert.For(ctx, "UploadBlob").ThatError(err).Succeeded() {
+		return
+	}
+	got, err := database.Resolve(ctx, blob.ID.ID())
+	assert.For(ctx, "Resolve").ThatError(err).Succeeded()
+	assert.For(ctx, "data").That(got).DeepEquals(data)
+}
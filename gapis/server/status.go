@@ -0,0 +1,243 @@
+// Copyright (C) 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"context"
+	"runtime"
+	"sync"
+	"time"
+
+	"github.com/google/gapid/core/app/status"
+	"github.com/google/gapid/core/context/keys"
+	"github.com/google/gapid/core/event/task"
+	"github.com/google/gapid/core/log"
+	"github.com/google/gapid/gapis/service"
+)
+
+const (
+	// statusBufferSize is the number of status updates that can be queued for
+	// a client before progress updates and memory snapshots are dropped. Task
+	// start and finish updates are never dropped.
+	statusBufferSize = 256
+
+	// memorySnapshotTolerance is how early a memory snapshot can arrive and
+	// still be forwarded to a client.
+	memorySnapshotTolerance = 10 * time.Millisecond
+)
+
+func (s *server) GetStatus(ctx context.Context, memorySnapshotInterval, statusUpdateFrequency time.Duration, handler service.StatusHandler) error {
+	// Deliberately not a status task, as it would report itself.
+	ctx = log.Enter(ctx, "GetStatus")
+
+	l := &statusForwarder{
+		ready:              make(chan struct{}, 1),
+		lastProgressUpdate: map[*status.Task]time.Time{},
+		progressUpdateFreq: statusUpdateFrequency,
+		memoryInterval:     memorySnapshotInterval,
+	}
+
+	unregister := status.RegisterListener(l)
+	defer unregister()
+
+	// Report the tasks that were already running. Tasks started since the
+	// listener was registered may be reported twice.
+	running := []*status.Task{}
+	for _, t := range status.Tasks() {
+		running = append(running, t)
+		t.Traverse(func(t *status.Task) { running = append(running, t) })
+	}
+	for _, t := range running {
+		if err := handler(l.task(service.TaskStatus_STARTING, t)); err != nil {
+			return err
+		}
+	}
+
+	if memorySnapshotInterval > 0 {
+		defer snapshotter.add(ctx, l, memorySnapshotInterval)()
+	}
+
+	for {
+		select {
+		case <-l.ready:
+			for _, u := range l.take() {
+				if err := handler(u); err != nil {
+					return err
+				}
+			}
+		case <-task.ShouldStop(ctx):
+			return task.StopReason(ctx)
+		}
+	}
+}
+
+// statusForwarder is a status.Listener that queues the status updates to be
+// sent to a client. The listener methods never block, so a slow client cannot
+// hold up the tasks being reported.
+type statusForwarder struct {
+	mutex              sync.Mutex
+	pending            []*service.ServerStatusResponse
+	ready              chan struct{}
+	lastProgressUpdate map[*status.Task]time.Time
+	progressUpdateFreq time.Duration
+	memoryInterval     time.Duration
+	lastMemory         time.Time
+}
+
+// send queues the update and wakes the stream. If droppable is true then the
+// update is dropped if the client has fallen statusBufferSize updates behind.
+func (l *statusForwarder) send(u *service.ServerStatusResponse, droppable bool) {
+	l.mutex.Lock()
+	if droppable && len(l.pending) >= statusBufferSize {
+		l.mutex.Unlock()
+		return
+	}
+	l.pending = append(l.pending, u)
+	l.mutex.Unlock()
+	select {
+	case l.ready <- struct{}{}:
+	default:
+	}
+}
+
+// take returns and clears the queued updates.
+func (l *statusForwarder) take() []*service.ServerStatusResponse {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	out := l.pending
+	l.pending = nil
+	return out
+}
+
+func (l *statusForwarder) task(s service.TaskStatus, t *status.Task) *service.ServerStatusResponse {
+	return &service.ServerStatusResponse{
+		Res: &service.ServerStatusResponse_Task{
+			Task: &service.TaskUpdate{
+				Status:          s,
+				Id:              t.ID(),
+				Parent:          t.ParentID(),
+				Name:            t.Name(),
+				CompletePercent: int32(t.Completion()),
+				Elapsed:         uint64(t.TimeSinceStart() / time.Millisecond),
+			},
+		},
+	}
+}
+
+func (l *statusForwarder) OnTaskStart(ctx context.Context, t *status.Task) {
+	l.send(l.task(service.TaskStatus_STARTING, t), false)
+}
+
+func (l *statusForwarder) OnTaskProgress(ctx context.Context, t *status.Task) {
+	l.mutex.Lock()
+	if time.Since(l.lastProgressUpdate[t]) < l.progressUpdateFreq {
+		l.mutex.Unlock()
+		return
+	}
+	l.lastProgressUpdate[t] = time.Now()
+	l.mutex.Unlock()
+	l.send(l.task(service.TaskStatus_PROGRESS, t), true)
+}
+
+func (l *statusForwarder) OnTaskFinish(ctx context.Context, t *status.Task) {
+	l.mutex.Lock()
+	delete(l.lastProgressUpdate, t)
+	l.mutex.Unlock()
+	l.send(l.task(service.TaskStatus_FINISHED, t), false)
+}
+
+func (l *statusForwarder) OnEvent(context.Context, *status.Task, string, status.EventScope) {}
+
+func (l *statusForwarder) OnMemorySnapshot(ctx context.Context, stats runtime.MemStats) {
+	// Snapshots are taken at the shortest interval requested by any client.
+	l.mutex.Lock()
+	if l.memoryInterval <= 0 || time.Since(l.lastMemory) < l.memoryInterval-memorySnapshotTolerance {
+		l.mutex.Unlock()
+		return
+	}
+	l.lastMemory = time.Now()
+	l.mutex.Unlock()
+	l.send(&service.ServerStatusResponse{
+		Res: &service.ServerStatusResponse_Memory{
+			Memory: &service.MemoryStatus{
+				TotalHeap: stats.Alloc,
+				Sys:       stats.Sys,
+				NumGc:     stats.NumGC,
+			},
+		},
+	}, true)
+}
+
+// snapshotter is the memory snapshotter shared by all the status streams.
+var snapshotter = memorySnapshotter{intervals: map[*statusForwarder]time.Duration{}}
+
+// memorySnapshotter takes memory snapshots at the shortest interval requested
+// by the status streams, using a single ticker.
+type memorySnapshotter struct {
+	mutex     sync.Mutex
+	intervals map[*statusForwarder]time.Duration
+	interval  time.Duration
+	stop      func() error
+}
+
+// add requests memory snapshots for l at the given interval, returning the
+// function that removes the request.
+func (m *memorySnapshotter) add(ctx context.Context, l *statusForwarder, interval time.Duration) func() {
+	ctx = keys.Clone(context.Background(), ctx)
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.intervals[l] = interval
+	m.update(ctx)
+	return func() {
+		m.mutex.Lock()
+		defer m.mutex.Unlock()
+		delete(m.intervals, l)
+		m.update(ctx)
+	}
+}
+
+// update restarts the ticker if the shortest requested interval has changed.
+// update must be called with the mutex locked.
+func (m *memorySnapshotter) update(ctx context.Context) {
+	var interval time.Duration
+	for _, i := range m.intervals {
+		if interval == 0 || i < interval {
+			interval = i
+		}
+	}
+	if interval == m.interval {
+		return
+	}
+	if m.stop != nil {
+		m.stop()
+		m.stop = nil
+	}
+	m.interval = interval
+	if interval == 0 {
+		return
+	}
+	m.stop = task.Async(ctx, func(ctx context.Context) error {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-task.ShouldStop(ctx):
+				return nil
+			case <-ticker.C:
+				status.SnapshotMemory(ctx)
+			}
+		}
+	})
+}
@@ -0,0 +1,120 @@
+// Copyright (C) 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/gapid/core/app/status"
+	"github.com/google/gapid/core/assert"
+	"github.com/google/gapid/core/event/task"
+	"github.com/google/gapid/core/log"
+	"github.com/google/gapid/gapis/service"
+)
+
+func TestGetStatusStalledHandler(t *testing.T) {
+	ctx := log.Testing(t)
+	ctx, cancel := task.WithCancel(ctx)
+	defer cancel()
+
+	const count = statusBufferSize * 4
+
+	registered, stall := make(chan struct{}), make(chan struct{})
+	var once sync.Once
+	var mutex sync.Mutex
+	started, finished := 0, 0
+	handler := func(u *service.ServerStatusResponse) error {
+		once.Do(func() { close(registered) })
+		<-stall
+		if t := u.GetTask(); t != nil && t.Name == "stalled" {
+			mutex.Lock()
+			defer mutex.Unlock()
+			switch t.Status {
+			case service.TaskStatus_STARTING:
+				started++
+			case service.TaskStatus_FINISHED:
+				finished++
+			}
+		}
+		return nil
+	}
+
+	done := make(chan error, 1)
+	go func() { done <- (&server{}).GetStatus(ctx, time.Millisecond, 0, handler) }()
+
+	// The first memory snapshot is sent once the listener is registered.
+	select {
+	case <-registered:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Timeout waiting for the status stream")
+	}
+
+	// The tasks must not wait for the stalled handler.
+	tasksDone := make(chan struct{})
+	go func() {
+		for i := 0; i < count; i++ {
+			ctx := status.Start(ctx, "stalled")
+			status.UpdateProgress(ctx, 1, 2)
+			status.Finish(ctx)
+		}
+		close(tasksDone)
+	}()
+	select {
+	case <-tasksDone:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Tasks blocked by the stalled status handler")
+	}
+
+	// Once the handler resumes, no task start or finish is lost.
+	close(stall)
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); {
+		mutex.Lock()
+		all := started == count && finished == count
+		mutex.Unlock()
+		if all {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	mutex.Lock()
+	assert.For(ctx, "started").That(started).Equals(count)
+	assert.For(ctx, "finished").That(finished).Equals(count)
+	mutex.Unlock()
+
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Timeout waiting for GetStatus to return")
+	}
+}
+
+func TestMemorySnapshotter(t *testing.T) {
+	ctx := log.Testing(t)
+	m := memorySnapshotter{intervals: map[*statusForwarder]time.Duration{}}
+	a, b := &statusForwarder{}, &statusForwarder{}
+
+	removeA := m.add(ctx, a, time.Second)
+	assert.For(ctx, "a").That(m.interval).Equals(time.Second)
+	removeB := m.add(ctx, b, time.Millisecond)
+	assert.For(ctx, "a, b").That(m.interval).Equals(time.Millisecond)
+	removeB()
+	assert.For(ctx, "a").That(m.interval).Equals(time.Second)
+	removeA()
+	assert.For(ctx, "none").That(m.interval).Equals(time.Duration(0))
+	assert.For(ctx, "stopped").That(m.stop == nil).Equals(true)
+}
@@ -79,6 +79,7 @@ func (n *GlobalState) Path() *Any               { return &Any{Path: &Any_GlobalS
 func (n *ImageInfo) Path() *Any                 { return &Any{Path: &Any_ImageInfo{n}} }
 func (n *MapIndex) Path() *Any                  { return &Any{Path: &Any_MapIndex{n}} }
 func (n *Memory) Path() *Any                    { return &Any{Path: &Any_Memory{n}} }
+func (n *MemoryReport) Path() *Any              { return &Any{Path: &Any_MemoryReport{n}} }
 func (n *Mesh) Path() *Any                      { return &Any{Path: &Any_Mesh{n}} }
 func (n *Metrics) Path() *Any                   { return &Any{Path: &Any_Metrics{n}} }
 func (n *Parameter) Path() *Any                 { return &Any{Path: &Any_Parameter{n}} }
@@ -87,12 +88,14 @@ func (n *ResourceData) Path() *Any              { return &Any{Path: &Any_Resourc
 func (n *Messages) Path() *Any                  { return &Any{Path: &Any_Messages{n}} }
 func (n *MultiResourceData) Path() *Any         { return &Any{Path: &Any_MultiResourceData{n}} }
 func (n *Resources) Path() *Any                 { return &Any{Path: &Any_Resources{n}} }
+func (n *ResourceUsage) Path() *Any             { return &Any{Path: &Any_ResourceUsage{n}} }
 func (n *Result) Path() *Any                    { return &Any{Path: &Any_Result{n}} }
 func (n *Slice) Path() *Any                     { return &Any{Path: &Any_Slice{n}} }
 func (n *State) Path() *Any                     { return &Any{Path: &Any_State{n}} }
 func (n *StateTree) Path() *Any                 { return &Any{Path: &Any_StateTree{n}} }
 func (n *StateTreeNode) Path() *Any             { return &Any{Path: &Any_StateTreeNode{n}} }
 func (n *StateTreeNodeForPath) Path() *Any      { return &Any{Path: &Any_StateTreeNodeForPath{n}} }
+func (n *StateTreeDiff) Path() *Any             { return &Any{Path: &Any_StateTreeDiff{n}} }
 func (n *Stats) Path() *Any                     { return &Any{Path: &Any_Stats{n}} }
 func (n *Thumbnail) Path() *Any                 { return &Any{Path: &Any_Thumbnail{n}} }
 
@@ -118,6 +121,7 @@ func (n GlobalState) Parent() Node               { return n.After }
 func (n ImageInfo) Parent() Node                 { return nil }
 func (n MapIndex) Parent() Node                  { return oneOfNode(n.Map) }
 func (n Memory) Parent() Node                    { return n.After }
+func (n MemoryReport) Parent() Node              { return n.Capture }
 func (n Mesh) Parent() Node                      { return oneOfNode(n.Object) }
 func (n Metrics) Parent() Node                   { return n.Command }
 func (n Messages) Parent() Node                  { return n.Capture }
@@ -126,12 +130,14 @@ func (n Report) Parent() Node                    { return n.Capture }
 func (n ResourceData) Parent() Node              { return n.After }
 func (n MultiResourceData) Parent() Node         { return n.After }
 func (n Resources) Parent() Node                 { return n.Capture }
+func (n ResourceUsage) Parent() Node             { return n.Capture }
 func (n Result) Parent() Node                    { return n.Command }
 func (n Slice) Parent() Node                     { return oneOfNode(n.Array) }
 func (n State) Parent() Node                     { return n.After }
 func (n StateTree) Parent() Node                 { return n.State }
 func (n StateTreeNode) Parent() Node             { return nil }
 func (n StateTreeNodeForPath) Parent() Node      { return nil }
+func (n StateTreeDiff) Parent() Node             { return n.To }
 func (n Stats) Parent() Node                     { return n.Capture }
 func (n Thumbnail) Parent() Node                 { return oneOfNode(n.Object) }
 
@@ -153,6 +159,7 @@ func (n *FramebufferObservation) SetParent(p Node)    { n.Command, _ = p.(*Comma
 func (n *GlobalState) SetParent(p Node)               { n.After, _ = p.(*Command) }
 func (n *ImageInfo) SetParent(p Node)                 {}
 func (n *Memory) SetParent(p Node)                    { n.After, _ = p.(*Command) }
+func (n *MemoryReport) SetParent(p Node)              { n.Capture, _ = p.(*Capture) }
 func (n *Metrics) SetParent(p Node)                   { n.Command, _ = p.(*Command) }
 func (n *Messages) SetParent(p Node)                  { n.Capture, _ = p.(*Capture) }
 func (n *Parameter) SetParent(p Node)                 { n.Command, _ = p.(*Command) }
@@ -160,11 +167,13 @@ func (n *Report) SetParent(p Node)                    { n.Capture, _ = p.(*Captu
 func (n *ResourceData) SetParent(p Node)              { n.After, _ = p.(*Command) }
 func (n *MultiResourceData) SetParent(p Node)         { n.After, _ = p.(*Command) }
 func (n *Resources) SetParent(p Node)                 { n.Capture, _ = p.(*Capture) }
+func (n *ResourceUsage) SetParent(p Node)             { n.Capture, _ = p.(*Capture) }
 func (n *Result) SetParent(p Node)                    { n.Command, _ = p.(*Command) }
 func (n *State) SetParent(p Node)                     { n.After, _ = p.(*Command) }
 func (n *StateTree) SetParent(p Node)                 { n.State, _ = p.(*State) }
 func (n *StateTreeNode) SetParent(p Node)             {}
 func (n *StateTreeNodeForPath) SetParent(p Node)      {}
+func (n *StateTreeDiff) SetParent(p Node)             { n.To, _ = p.(*State) }
 func (n *Stats) SetParent(p Node)                     { n.Capture, _ = p.(*Capture) }
 
 // Format implements fmt.Formatter to print the version.
@@ -241,6 +250,9 @@ func (n MapIndex) Format(f fmt.State, c rune) { fmt.Fprintf(f, "%v[%x]", n.Paren
 // Format implements fmt.Formatter to print the version.
 func (n Memory) Format(f fmt.State, c rune) { fmt.Fprintf(f, "%v.memory-after", n.Parent()) }
 
+// Format implements fmt.Formatter to print the version.
+func (n MemoryReport) Format(f fmt.State, c rune) { fmt.Fprintf(f, "%v.memory-report", n.Parent()) }
+
 // Format implements fmt.Formatter to print the message path.
 func (n Messages) Format(f fmt.State, c rune) { fmt.Fprintf(f, "%v.messages", n.Parent()) }
 
@@ -266,6 +278,9 @@ func (n MultiResourceData) Format(f fmt.State, c rune) {
 // Format implements fmt.Formatter to print the version.
 func (n Resources) Format(f fmt.State, c rune) { fmt.Fprintf(f, "%v.resources", n.Parent()) }
 
+// Format implements fmt.Formatter to print the version.
+func (n ResourceUsage) Format(f fmt.State, c rune) { fmt.Fprintf(f, "%v.resource-usage", n.Parent()) }
+
 // Format implements fmt.Formatter to print the version.
 func (n Result) Format(f fmt.State, c rune) { fmt.Fprintf(f, "%v.result", n.Parent()) }
 
@@ -292,6 +307,11 @@ func (n StateTreeNodeForPath) Format(f fmt.State, c rune) {
 	fmt.Fprintf(f, "state-tree-for<%v, %v>", n.Tree, n.Member)
 }
 
+// Format implements fmt.Formatter to print the version.
+func (n StateTreeDiff) Format(f fmt.State, c rune) {
+	fmt.Fprintf(f, "%v.tree-diff<%v>", n.To, n.From)
+}
+
 // Format implements fmt.Formatter to print the version.
 func (n Stats) Format(f fmt.State, c rune) { fmt.Fprintf(f, "%v.stats", n.Parent()) }
 
@@ -538,6 +558,17 @@ func (n *Capture) Resources() *Resources {
 	return &Resources{Capture: n}
 }
 
+// MemoryReport returns the path node to the analysis of the capture's memory
+// observations, aggregated over address ranges of granularity bytes.
+func (n *Capture) MemoryReport(granularity uint64) *MemoryReport {
+	return &MemoryReport{Capture: n, Granularity: granularity}
+}
+
+// ResourceUsage returns the path node to the usage of the capture's resources.
+func (n *Capture) ResourceUsage() *ResourceUsage {
+	return &ResourceUsage{Capture: n}
+}
+
 // Report returns the path node to the capture's report.
 func (n *Capture) Report(d *Device, f *CommandFilter, display bool) *Report {
 	return &Report{Capture: n, Device: d, Filter: f, DisplayToSurface: display}
@@ -594,6 +625,15 @@ func (n *CommandTreeNode) Child(i uint64) *CommandTreeNode {
 	return &CommandTreeNode{Tree: n.Tree, Indices: newIndices}
 }
 
+// Mesh returns the path node to the mesh of the last draw call of this
+// CommandTreeNode.
+func (n *CommandTreeNode) Mesh(options *MeshOptions) *Mesh {
+	return &Mesh{
+		Options: options,
+		Object:  &Mesh_CommandTreeNode{n},
+	}
+}
+
 // Command returns the path node to a single command in the capture.
 func (n *Capture) Command(i uint64, subidx ...uint64) *Command {
 	indices := append([]uint64{i}, subidx...)
@@ -647,6 +687,14 @@ func (n *Command) Mesh(options *MeshOptions) *Mesh {
 	}
 }
 
+// As requests the mesh exported in the specified file format.
+func (n *Mesh) As(f MeshExportFormat) *As {
+	return &As{
+		To:   &As_MeshExportFormat{f},
+		From: &As_Mesh{n},
+	}
+}
+
 // NewMeshOptions returns a new MeshOptions object.
 func NewMeshOptions(faceted bool) *MeshOptions {
 	return &MeshOptions{
@@ -710,6 +758,12 @@ func (n *State) Tree() *StateTree {
 	return &StateTree{State: n}
 }
 
+// TreeDiff returns the path node to the differences between the state tree of
+// from and the state tree for this state.
+func (n *State) TreeDiff(from *State) *StateTreeDiff {
+	return &StateTreeDiff{From: from, To: n}
+}
+
 func (n *GlobalState) Field(name string) *Field       { return NewField(name, n) }
 func (n *State) Field(name string) *Field             { return NewField(name, n) }
 func (n *Parameter) ArrayIndex(i uint64) *ArrayIndex  { return NewArrayIndex(i, n) }
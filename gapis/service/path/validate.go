@@ -223,6 +223,11 @@ func (n *Memory) Validate() error {
 	return checkNotNilAndValidate(n, n.After, "after")
 }
 
+// Validate checks the path is valid.
+func (n *MemoryReport) Validate() error {
+	return checkNotNilAndValidate(n, n.Capture, "capture")
+}
+
 // Validate checks the path is valid.
 func (n *Mesh) Validate() error {
 	return checkNotNilAndValidate(n, protoutil.OneOf(n.Object), "object")
@@ -277,6 +282,11 @@ func (n *Resources) Validate() error {
 	return checkNotNilAndValidate(n, n.Capture, "capture")
 }
 
+// Validate checks the path is valid.
+func (n *ResourceUsage) Validate() error {
+	return checkNotNilAndValidate(n, n.Capture, "capture")
+}
+
 // Validate checks the path is valid.
 func (n *Result) Validate() error {
 	return checkNotNilAndValidate(n, n.Command, "command")
@@ -310,6 +320,14 @@ func (n *StateTreeNodeForPath) Validate() error {
 	)
 }
 
+// Validate checks the path is valid.
+func (n *StateTreeDiff) Validate() error {
+	return anyErr(
+		checkNotNilAndValidate(n, n.From, "from"),
+		checkNotNilAndValidate(n, n.To, "to"),
+	)
+}
+
 // Validate checks the path is valid.
 func (n *Stats) Validate() error {
 	return checkNotNilAndValidate(n, n.Capture, "capture")
@@ -18,6 +18,7 @@ import (
 	"context"
 	"fmt"
 	"io"
+	"time"
 
 	"github.com/google/gapid/core/data/id"
 	"github.com/google/gapid/core/data/protoutil"
@@ -122,6 +123,9 @@ type Service interface {
 	// the base changed to refer to the new capture.
 	Set(ctx context.Context, p *path.Any, v interface{}, c *path.ResolveConfig) (*path.Any, error)
 
+	// UploadBlob stores data on the server, returning the path to the new blob.
+	UploadBlob(ctx context.Context, data []byte) (*path.Blob, error)
+
 	// Follow returns the path to the object that the value at p links to.
 	// If the value at p does not link to anything then nil is returned.
 	Follow(ctx context.Context, p *path.Any, c *path.ResolveConfig) (*path.Any, error)
@@ -145,6 +149,12 @@ type Service interface {
 	// context is cancelled.
 	GetLogStream(context.Context, log.Handler) error
 
+	// GetStatus calls the handler with the start, progress and completion of
+	// the server's tasks, and with a snapshot of the server's memory every
+	// memorySnapshotInterval, until the context is cancelled.
+	// Progress updates of a task are sent at most every statusUpdateFrequency.
+	GetStatus(ctx context.Context, memorySnapshotInterval, statusUpdateFrequency time.Duration, h StatusHandler) error
+
 	// Find performs a search using req, streaming the results to h.
 	Find(ctx context.Context, req *FindRequest, h FindHandler) error
 
@@ -176,6 +186,9 @@ type TraceHandler interface {
 // FindHandler is the handler of found items using Service.Find.
 type FindHandler func(*FindResponse) error
 
+// StatusHandler is the handler of status updates using Service.GetStatus.
+type StatusHandler func(*ServerStatusResponse) error
+
 // NewError attempts to box and return err into an Error.
 // If err cannot be boxed into an Error then nil is returned.
 func NewError(err error) *Error {
@@ -238,6 +251,8 @@ func NewValue(v interface{}) *Value {
 		return &Value{Val: &Value_Events{v}}
 	case *Memory:
 		return &Value{Val: &Value_Memory{v}}
+	case *MemoryReport:
+		return &Value{Val: &Value_MemoryReport{v}}
 	case *path.Any:
 		return &Value{Val: &Value_Path{v}}
 	case path.Node:
@@ -246,12 +261,16 @@ func NewValue(v interface{}) *Value {
 		return &Value{Val: &Value_Report{v}}
 	case *Resources:
 		return &Value{Val: &Value_Resources{v}}
+	case *ResourceUsages:
+		return &Value{Val: &Value_ResourceUsages{v}}
 	case *Messages:
 		return &Value{Val: &Value_Messages{v}}
 	case *StateTree:
 		return &Value{Val: &Value_StateTree{v}}
 	case *StateTreeNode:
 		return &Value{Val: &Value_StateTreeNode{v}}
+	case *StateTreeDiff:
+		return &Value{Val: &Value_StateTreeDiff{v}}
 	case *Stats:
 		return &Value{Val: &Value_Stats{v}}
 	case *api.Command:
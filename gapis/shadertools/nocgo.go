@@ -0,0 +1,110 @@
+// Copyright (C) 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// +build !cgo
+
+package shadertools
+
+// This file contains the implementations of the shadertools package used when
+// cgo is unavailable. SPIR-V disassembly and reflection use the pure Go spirv
+// package, GLSL conversion, compilation and SPIR-V assembly are unsupported.
+
+import (
+	"github.com/google/gapid/core/fault"
+	"github.com/google/gapid/gapis/shadertools/spirv"
+)
+
+// ErrNoCgo is returned by the functions that require the C++ shader libraries
+// when the package is built without cgo.
+const ErrNoCgo = fault.Const("shadertools was built without cgo")
+
+// ConvertGlsl modifies the given GLSL according to the options specified via
+// o. It always fails when built without cgo.
+func ConvertGlsl(source string, o *ConvertOptions) (CodeWithDebugInfo, error) {
+	return CodeWithDebugInfo{}, ErrNoCgo
+}
+
+// DisassembleSpirvBinary disassembles the given SPIR-V binary words and returns
+// the disassembly. Returns an empty string if diassembling fails.
+func DisassembleSpirvBinary(words []uint32) string {
+	source, err := spirv.Disassemble(words)
+	if err != nil {
+		return ""
+	}
+	return source
+}
+
+// AssembleSpirvText assembles the given SPIR-V text chars. It always returns
+// nil when built without cgo.
+func AssembleSpirvText(chars string) []uint32 {
+	return nil
+}
+
+// OpcodeToString converts opcode number to human readable string.
+func OpcodeToString(opcode uint32) string {
+	return spirv.Opcode(opcode).String()
+}
+
+// CompileGlsl compiles GLSL source code to SPIR-V binary words. It always
+// fails when built without cgo.
+func CompileGlsl(source string, o CompileOptions) ([]uint32, error) {
+	return nil, ErrNoCgo
+}
+
+// ParseDescriptorSets determines what descriptor sets are implied by the shader
+func ParseDescriptorSets(shader []uint32, entryPoint string) (DescriptorSets, error) {
+	m, err := spirv.Parse(shader)
+	if err != nil {
+		return nil, err
+	}
+	r, err := m.Reflect(entryPoint)
+	if err != nil {
+		return nil, err
+	}
+	return descriptorSets(r), nil
+}
+
+// ParseAllDescriptorSets determines what descriptor sets are implied by each
+// entry point of the shader
+func ParseAllDescriptorSets(shader []uint32) (map[string]DescriptorSets, error) {
+	m, err := spirv.Parse(shader)
+	if err != nil {
+		return nil, err
+	}
+	out := make(map[string]DescriptorSets)
+	for _, e := range m.EntryPoints() {
+		r, err := m.Reflect(e.Name)
+		if err != nil {
+			return nil, err
+		}
+		out[e.Name] = descriptorSets(r)
+	}
+	return out, nil
+}
+
+func descriptorSets(r *spirv.Reflection) DescriptorSets {
+	res := DescriptorSets{}
+	// The reflected bindings are already sorted with descriptorBindingLess.
+	for _, b := range r.DescriptorBindings {
+		res[b.Set] = append(res[b.Set], DescriptorBinding{
+			Set:             b.Set,
+			Binding:         b.Binding,
+			SpirvId:         b.ID,
+			DescriptorType:  b.DescriptorType,
+			DescriptorCount: b.Count,
+			ShaderStage:     r.ExecutionModel.StageFlag(),
+		})
+	}
+	return res
+}
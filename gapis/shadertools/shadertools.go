@@ -12,7 +12,8 @@
 // See the License for the specific language governing permissions and
 // limitations under the License.
 
-// Package shadertools wraps around external C code for manipulating shaders.
+// +build cgo
+
 package shadertools
 
 //#include "cc/libmanager.h"
@@ -21,7 +22,6 @@ package shadertools
 import "C"
 
 import (
-	"bytes"
 	"fmt"
 	"sort"
 	"strings"
@@ -34,115 +34,6 @@ import (
 
 var mutex sync.Mutex
 
-// Instruction represents a SPIR-V instruction.
-type Instruction struct {
-	ID     uint32   // Result identifer.
-	Opcode uint32   // Opcode.
-	Words  []uint32 // Operands.
-	Name   string   // Optional symbol name.
-}
-
-// CodeWithDebugInfo is the result returned by ConvertGlsl.
-type CodeWithDebugInfo struct {
-	SourceCode        string        // Modified GLSL.
-	DisassemblyString string        // Diassembly of modified GLSL.
-	Info              []Instruction // A set of SPIR-V debug instructions.
-}
-
-// FormatDebugInfo returns the instructions as a string.
-func FormatDebugInfo(insts []Instruction, linePrefix string) string {
-	var buffer bytes.Buffer
-	for _, inst := range insts {
-		buffer.WriteString(linePrefix)
-		if inst.ID != 0 {
-			buffer.WriteString(fmt.Sprintf("%%%-5v = ", inst.ID))
-		} else {
-			buffer.WriteString(fmt.Sprintf("       = "))
-		}
-		buffer.WriteString("Op")
-		buffer.WriteString(OpcodeToString(inst.Opcode))
-		for _, word := range inst.Words {
-			buffer.WriteString(fmt.Sprintf(" %v", word))
-		}
-		if inst.Name != "" {
-			buffer.WriteString(fmt.Sprintf(" \"%v\"", inst.Name))
-		}
-		buffer.WriteString("\n")
-	}
-	return buffer.String()
-}
-
-// ShaderType is the enumerator of shader types.
-type ShaderType int
-
-const (
-	TypeVertex         = ShaderType(C.VERTEX)
-	TypeTessControl    = ShaderType(C.TESS_CONTROL)
-	TypeTessEvaluation = ShaderType(C.TESS_EVALUATION)
-	TypeGeometry       = ShaderType(C.GEOMETRY)
-	TypeFragment       = ShaderType(C.FRAGMENT)
-	TypeCompute        = ShaderType(C.COMPUTE)
-)
-
-// ClientType is the enumerator of client types.
-type ClientType int
-
-const (
-	OpenGL   = ClientType(C.OPENGL)
-	OpenGLES = ClientType(C.OPENGLES)
-	Vulkan   = ClientType(C.VULKAN)
-)
-
-func (t ShaderType) String() string {
-	switch t {
-	case TypeVertex:
-		return "Vertex"
-	case TypeTessControl:
-		return "TessControl"
-	case TypeTessEvaluation:
-		return "TessEvaluation"
-	case TypeGeometry:
-		return "Geometry"
-	case TypeFragment:
-		return "Fragment"
-	case TypeCompute:
-		return "Compute"
-	default:
-		return "Unknown"
-	}
-}
-
-// ConvertOptions controls how ConvertGlsl converts its passed-in GLSL source code.
-type ConvertOptions struct {
-	// The type of shader.
-	ShaderType ShaderType
-	// The target GLSL version (default 330).
-	TargetGLSLVersion int
-	// Shader source preamble.
-	Preamble string
-	// Whether to add prefix to all non-builtin symbols.
-	PrefixNames bool
-	// The name prefix to be added to all non-builtin symbols.
-	NamesPrefix string /* optional */
-	// Whether to create a corresponding output variable for each input variable.
-	AddOutputsForInputs bool
-	// The name prefix of added output variables.
-	OutputPrefix string /* optional */
-	// Whether to make the generated GLSL code debuggable.
-	MakeDebuggable bool
-	// Whether to check the generated GLSL code compiles again.
-	CheckAfterChanges bool
-	// Whether to disassemble the generated GLSL code.
-	Disassemble bool
-	// If true, let some minor invalid statements compile.
-	Relaxed bool
-	// If true, optimizations that require high-end GL versions, or extensions
-	// will be stripped. These optimizations should have no impact on the end
-	// result of the shader, but may impact performance.
-	// Example: Early Fragment Test.
-	StripOptimizations bool
-}
-
 // ConvertGlsl modifies the given GLSL according to the options specified via
 // o and returns the modification status and result. Possible modifications
 // includes creating output variables for input variables, prefixing all
@@ -260,16 +151,6 @@ func OpcodeToString(opcode uint32) string {
 	return C.GoString(C.opcodeToString(C.uint32_t(opcode)))
 }
 
-// CompileOptions controls how CompileGlsl compile its passed-in GLSL source code.
-type CompileOptions struct {
-	// The type of shader.
-	ShaderType ShaderType
-	// Either OpenGL, OpenGLES or Vulkan
-	ClientType ClientType
-	// Shader source preamble.
-	Preamble string
-}
-
 // CompileGlsl compiles GLSL source code to SPIR-V binary words.
 func CompileGlsl(source string, o CompileOptions) ([]uint32, error) {
 	toFree := []unsafe.Pointer{}
@@ -317,29 +198,6 @@ func CompileGlsl(source string, o CompileOptions) ([]uint32, error) {
 	return words, fault.Const(strings.Join(msg, "\n"))
 }
 
-type DescriptorSets map[uint32]DescriptorSet
-type DescriptorSet []DescriptorBinding
-
-type DescriptorBinding struct {
-	Set             uint32
-	Binding         uint32
-	SpirvId         uint32
-	DescriptorType  uint32
-	DescriptorCount uint32
-	ShaderStage     uint32
-}
-
-func descriptorBindingLess(a DescriptorBinding, b DescriptorBinding) bool {
-	if a.Set != b.Set {
-		return a.Set < b.Set
-	}
-	if a.Binding != b.Binding {
-		return a.Binding < b.Binding
-	}
-	// SpirvId is a unique identifier so we don't need to keep comparing after this
-	return a.SpirvId < b.SpirvId
-}
-
 // ParseDescriptorSets determines what descriptor sets are implied by the shader
 func ParseDescriptorSets(shader []uint32, entryPoint string) (DescriptorSets, error) {
 	spvReflectErr := func(res C.SpvReflectResult) error {
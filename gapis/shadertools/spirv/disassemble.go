@@ -0,0 +1,344 @@
+// Copyright (C) 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package spirv
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// indent is the column instructions are aligned to, after the result id.
+const indent = 15
+
+// Disassemble parses the SPIR-V binary module in words and returns its
+// disassembly.
+func Disassemble(words []uint32) (string, error) {
+	m, err := Parse(words)
+	if err != nil {
+		return "", err
+	}
+	return m.Disassemble(), nil
+}
+
+// Disassemble returns the disassembly of the module in the text form produced
+// by spirv-dis with friendly names and indentation.
+func (m *Module) Disassemble() string {
+	mod := newModule(m)
+	names := mod.friendlyNames()
+	name := func(id uint32) string {
+		if n, ok := names[id]; ok {
+			return n
+		}
+		return fmt.Sprint(id)
+	}
+	extInstSets := map[uint32]string{}
+	for _, i := range m.Instructions {
+		if i.Opcode == OpExtInstImport && len(i.Operands) > 1 {
+			extInstSets[i.Operands[0]], _ = decodeString(i.Operands[1:])
+		}
+	}
+
+	buf := &bytes.Buffer{}
+	fmt.Fprintln(buf, "; SPIR-V")
+	fmt.Fprintf(buf, "; Version: %d.%d\n", (m.Version>>16)&0xff, (m.Version>>8)&0xff)
+	if vendor, ok := generators[m.Generator>>16]; ok {
+		fmt.Fprintf(buf, "; Generator: %v; %d\n", vendor, m.Generator&0xffff)
+	} else {
+		fmt.Fprintf(buf, "; Generator: Unknown(%d); %d\n", m.Generator>>16, m.Generator&0xffff)
+	}
+	fmt.Fprintf(buf, "; Bound: %d\n", m.Bound)
+	fmt.Fprintf(buf, "; Schema: %d\n", m.Schema)
+
+	for _, i := range m.Instructions {
+		if id := i.Result(); id != 0 {
+			n := name(id)
+			buf.WriteString(strings.Repeat(" ", max(0, indent-4-len(n))))
+			fmt.Fprintf(buf, "%%%v = ", n)
+		} else {
+			buf.WriteString(strings.Repeat(" ", indent))
+		}
+		buf.WriteString("Op")
+		buf.WriteString(i.Opcode.String())
+		if t := i.ResultType(); t != 0 {
+			fmt.Fprintf(buf, " %%%v", name(t))
+		}
+		for _, o := range mod.operands(i) {
+			buf.WriteByte(' ')
+			switch o.kind {
+			case idOperand:
+				buf.WriteString("%" + name(o.words[0]))
+			case literalOperand:
+				fmt.Fprint(buf, o.words[0])
+			case stringOperand:
+				buf.WriteString(quote(o.str))
+			case constantOperand:
+				buf.WriteString(mod.formatLiteral(i.ResultType(), o.words))
+			case switchOperand:
+				buf.WriteString(mod.formatLiteral(mod.defs[i.Operands[0]].ResultType(), o.words))
+			case extInstOperand:
+				buf.WriteString(extInstName(extInstSets[i.Operands[2]], o.words[0]))
+			case opcodeOperand:
+				buf.WriteString(Opcode(o.words[0]).String())
+			case enumOperand:
+				buf.WriteString(enums[o.enum].format(o.words[0]))
+			}
+		}
+		buf.WriteByte('\n')
+	}
+	return buf.String()
+}
+
+// friendlyNames returns the names of the ids of the module, derived from the
+// debug names and the types and values of the instructions defining them, as
+// spirv-dis does. Ids without a friendly name map to their number.
+func (m *module) friendlyNames() map[uint32]string {
+	names, used := map[uint32]string{}, map[string]bool{}
+	name := func(id uint32) string {
+		if n, ok := names[id]; ok {
+			return n
+		}
+		return fmt.Sprint(id)
+	}
+	save := func(id uint32, suggested string) {
+		if _, ok := names[id]; ok {
+			return
+		}
+		n := sanitize(suggested)
+		for i := 0; used[n]; i++ {
+			n = fmt.Sprintf("%v_%d", sanitize(suggested), i)
+		}
+		names[id], used[n] = n, true
+	}
+
+	for _, i := range m.Instructions {
+		ops := i.Operands
+		switch i.Opcode {
+		case OpName:
+			if len(ops) > 1 {
+				s, _ := decodeString(ops[1:])
+				save(ops[0], s)
+			}
+			continue
+		case OpTypeVoid:
+			if len(ops) > 0 {
+				save(ops[0], "void")
+			}
+		case OpTypeBool:
+			if len(ops) > 0 {
+				save(ops[0], "bool")
+			}
+		case OpTypeInt:
+			if len(ops) < 3 {
+				break
+			}
+			root, sign := "", ""
+			switch ops[1] {
+			case 8:
+				root = "char"
+			case 16:
+				root = "short"
+			case 32:
+				root = "int"
+			case 64:
+				root = "long"
+			default:
+				root, sign = fmt.Sprint(ops[1]), "i"
+			}
+			if ops[2] == 0 {
+				sign = "u"
+			}
+			save(ops[0], sign+root)
+		case OpTypeFloat:
+			if len(ops) < 2 {
+				break
+			}
+			switch ops[1] {
+			case 16:
+				save(ops[0], "half")
+			case 32:
+				save(ops[0], "float")
+			case 64:
+				save(ops[0], "double")
+			default:
+				save(ops[0], fmt.Sprintf("fp%d", ops[1]))
+			}
+		case OpTypeVector:
+			if len(ops) > 2 {
+				save(ops[0], fmt.Sprintf("v%d%v", ops[2], name(ops[1])))
+			}
+		case OpTypeMatrix:
+			if len(ops) > 2 {
+				save(ops[0], fmt.Sprintf("mat%d%v", ops[2], name(ops[1])))
+			}
+		case OpTypeArray:
+			if len(ops) > 2 {
+				save(ops[0], fmt.Sprintf("_arr_%v_%v", name(ops[1]), name(ops[2])))
+			}
+		case OpTypeRuntimeArray:
+			if len(ops) > 1 {
+				save(ops[0], "_runtimearr_"+name(ops[1]))
+			}
+		case OpTypePointer:
+			if len(ops) > 2 {
+				save(ops[0], fmt.Sprintf("_ptr_%v_%v", StorageClass(ops[1]), name(ops[2])))
+			}
+		case OpTypeStruct:
+			if len(ops) > 0 {
+				save(ops[0], fmt.Sprintf("_struct_%d", ops[0]))
+			}
+		case OpConstantTrue:
+			if len(ops) > 1 {
+				save(ops[1], "true")
+			}
+		case OpConstantFalse:
+			if len(ops) > 1 {
+				save(ops[1], "false")
+			}
+		case OpConstant:
+			if len(ops) > 2 {
+				value := strings.Replace(m.formatLiteral(ops[0], ops[2:]), "-", "n", -1)
+				save(ops[1], name(ops[0])+"_"+value)
+			}
+		}
+		if id := i.Result(); id != 0 {
+			save(id, fmt.Sprint(id))
+		}
+	}
+	return names
+}
+
+// sanitize replaces the characters of s that are not valid in an id name.
+func sanitize(s string) string {
+	if s == "" {
+		return "_"
+	}
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '_':
+			return r
+		default:
+			return '_'
+		}
+	}, s)
+}
+
+// formatLiteral formats the literal words of a value of the type with the given
+// id.
+func (m *module) formatLiteral(typ uint32, words []uint32) string {
+	if len(words) == 0 {
+		return ""
+	}
+	v := uint64(words[0])
+	if len(words) > 1 {
+		v |= uint64(words[1]) << 32
+	}
+	t, ok := m.defs[typ]
+	if !ok || len(t.Operands) < 2 {
+		return fmt.Sprint(v)
+	}
+	width := t.Operands[1]
+	switch t.Opcode {
+	case OpTypeInt:
+		if signed := len(t.Operands) > 2 && t.Operands[2] != 0; !signed {
+			return fmt.Sprint(v)
+		}
+		// Sign extend the value from its width.
+		shift := uint(64 - width)
+		if width > 64 {
+			shift = 0
+		}
+		return fmt.Sprint(int64(v<<shift) >> shift)
+	case OpTypeFloat:
+		switch width {
+		case 16:
+			return formatFloat(float64(halfToFloat(uint16(v))), 5, 16)
+		case 32:
+			return formatFloat(float64(math.Float32frombits(uint32(v))), 9, 32)
+		case 64:
+			return formatFloat(math.Float64frombits(v), 17, 64)
+		}
+	}
+	return fmt.Sprint(v)
+}
+
+// formatFloat formats f with enough digits to reproduce it, and infinities,
+// NaNs and denormals as hexadecimal floats.
+func formatFloat(f float64, digits, bits int) string {
+	switch {
+	case math.IsInf(f, 1):
+		return fmt.Sprintf("0x1p+%d", maxExponent(bits)+1)
+	case math.IsInf(f, -1):
+		return fmt.Sprintf("-0x1p+%d", maxExponent(bits)+1)
+	case math.IsNaN(f):
+		return fmt.Sprintf("0x1.8p+%d", maxExponent(bits)+1)
+	case f != 0 && math.Abs(f) < math.Ldexp(1, 1-maxExponent(bits)):
+		return strconv.FormatFloat(f, 'x', -1, 64)
+	}
+	return strconv.FormatFloat(f, 'g', digits, 64)
+}
+
+func maxExponent(bits int) int {
+	switch bits {
+	case 16:
+		return 15
+	case 32:
+		return 127
+	default:
+		return 1023
+	}
+}
+
+func halfToFloat(h uint16) float32 {
+	sign := uint32(h>>15) << 31
+	exp := uint32(h>>10) & 0x1f
+	mantissa := uint32(h) & 0x3ff
+	switch {
+	case exp == 0x1f:
+		return math.Float32frombits(sign | 0x7f800000 | mantissa<<13)
+	case exp == 0:
+		f := float32(math.Ldexp(float64(mantissa), -24))
+		if sign != 0 {
+			f = -f
+		}
+		return f
+	default:
+		return math.Float32frombits(sign | (exp+112)<<23 | mantissa<<13)
+	}
+}
+
+// quote returns s in double quotes, with quotes and backslashes escaped.
+func quote(s string) string {
+	r := strings.NewReplacer(`\`, `\\`, `"`, `\"`)
+	return `"` + r.Replace(s) + `"`
+}
+
+// extInstName returns the name of the extended instruction n of the given
+// instruction set, or n as a number if it is unknown.
+func extInstName(set string, n uint32) string {
+	if names := extInstNames[set]; int(n) < len(names) && names[n] != "" {
+		return names[n]
+	}
+	return fmt.Sprint(n)
+}
+
+func max(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
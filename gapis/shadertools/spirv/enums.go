@@ -0,0 +1,361 @@
+// Copyright (C) 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package spirv
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// ExecutionModel is the SPIR-V execution model of an entry point.
+type ExecutionModel uint32
+
+const (
+	Vertex                 ExecutionModel = 0
+	TessellationControl    ExecutionModel = 1
+	TessellationEvaluation ExecutionModel = 2
+	Geometry               ExecutionModel = 3
+	Fragment               ExecutionModel = 4
+	GLCompute              ExecutionModel = 5
+	Kernel                 ExecutionModel = 6
+)
+
+func (m ExecutionModel) String() string { return enums["ExecutionModel"].format(uint32(m)) }
+
+// StageFlag returns the VkShaderStageFlagBits value of the stage of the
+// execution model, or 0 if the model is not a Vulkan stage.
+func (m ExecutionModel) StageFlag() uint32 {
+	if m < Kernel {
+		return 1 << m
+	}
+	return 0
+}
+
+// StorageClass is the SPIR-V storage class of a variable or pointer.
+type StorageClass uint32
+
+const (
+	UniformConstant StorageClass = 0
+	Input           StorageClass = 1
+	Uniform         StorageClass = 2
+	Output          StorageClass = 3
+	Workgroup       StorageClass = 4
+	CrossWorkgroup  StorageClass = 5
+	Private         StorageClass = 6
+	Function        StorageClass = 7
+	Generic         StorageClass = 8
+	PushConstant    StorageClass = 9
+	AtomicCounter   StorageClass = 10
+	Image           StorageClass = 11
+	StorageBuffer   StorageClass = 12
+)
+
+func (c StorageClass) String() string { return enums["StorageClass"].format(uint32(c)) }
+
+// The decorations the package inspects when reflecting modules.
+const (
+	decorationSpecID        = 1
+	decorationBlock         = 2
+	decorationBufferBlock   = 3
+	decorationRowMajor      = 4
+	decorationArrayStride   = 6
+	decorationMatrixStride  = 7
+	decorationBuiltIn       = 11
+	decorationLocation      = 30
+	decorationBinding       = 33
+	decorationDescriptorSet = 34
+	decorationOffset        = 35
+)
+
+// The image dimensionalities the package inspects when reflecting modules.
+const (
+	dimBuffer      = 5
+	dimSubpassData = 6
+)
+
+// enum is a table of the names of the values of a SPIR-V operand kind.
+type enum struct {
+	mask   bool // Values are bit masks that can be combined with |.
+	values map[uint32]string
+}
+
+// format returns the name of v, or v as a number if it has no name.
+func (e enum) format(v uint32) string {
+	if !e.mask {
+		if name, ok := e.values[v]; ok {
+			return name
+		}
+		return fmt.Sprint(v)
+	}
+	if v == 0 {
+		return "None"
+	}
+	bits := []uint32{}
+	for bit := range e.values {
+		if v&bit != 0 {
+			bits = append(bits, bit)
+		}
+	}
+	sort.Slice(bits, func(i, j int) bool { return bits[i] < bits[j] })
+	names := []string{}
+	for _, bit := range bits {
+		names, v = append(names, e.values[bit]), v&^bit
+	}
+	if v != 0 {
+		names = append(names, fmt.Sprintf("0x%x", v))
+	}
+	return strings.Join(names, "|")
+}
+
+var enums = map[string]enum{
+	"SourceLanguage": {values: map[uint32]string{
+		0: "Unknown", 1: "ESSL", 2: "GLSL", 3: "OpenCL_C", 4: "OpenCL_CPP", 5: "HLSL",
+	}},
+	"ExecutionModel": {values: map[uint32]string{
+		0: "Vertex", 1: "TessellationControl", 2: "TessellationEvaluation",
+		3: "Geometry", 4: "Fragment", 5: "GLCompute", 6: "Kernel",
+	}},
+	"AddressingModel": {values: map[uint32]string{
+		0: "Logical", 1: "Physical32", 2: "Physical64",
+	}},
+	"MemoryModel": {values: map[uint32]string{
+		0: "Simple", 1: "GLSL450", 2: "OpenCL", 3: "VulkanKHR",
+	}},
+	"ExecutionMode": {values: map[uint32]string{
+		0: "Invocations", 1: "SpacingEqual", 2: "SpacingFractionalEven",
+		3: "SpacingFractionalOdd", 4: "VertexOrderCw", 5: "VertexOrderCcw",
+		6: "PixelCenterInteger", 7: "OriginUpperLeft", 8: "OriginLowerLeft",
+		9: "EarlyFragmentTests", 10: "PointMode", 11: "Xfb", 12: "DepthReplacing",
+		14: "DepthGreater", 15: "DepthLess", 16: "DepthUnchanged", 17: "LocalSize",
+		18: "LocalSizeHint", 19: "InputPoints", 20: "InputLines",
+		21: "InputLinesAdjacency", 22: "Triangles", 23: "InputTrianglesAdjacency",
+		24: "Quads", 25: "Isolines", 26: "OutputVertices", 27: "OutputPoints",
+		28: "OutputLineStrip", 29: "OutputTriangleStrip", 30: "VecTypeHint",
+		31: "ContractionOff", 33: "Initializer", 34: "Finalizer",
+		35: "SubgroupSize", 36: "SubgroupsPerWorkgroup",
+		37: "SubgroupsPerWorkgroupId", 38: "LocalSizeId", 39: "LocalSizeHintId",
+		4446: "PostDepthCoverage", 5027: "StencilRefReplacingEXT",
+	}},
+	"StorageClass": {values: map[uint32]string{
+		0: "UniformConstant", 1: "Input", 2: "Uniform", 3: "Output",
+		4: "Workgroup", 5: "CrossWorkgroup", 6: "Private", 7: "Function",
+		8: "Generic", 9: "PushConstant", 10: "AtomicCounter", 11: "Image",
+		12: "StorageBuffer",
+	}},
+	"Dim": {values: map[uint32]string{
+		0: "1D", 1: "2D", 2: "3D", 3: "Cube", 4: "Rect", 5: "Buffer", 6: "SubpassData",
+	}},
+	"SamplerAddressingMode": {values: map[uint32]string{
+		0: "None", 1: "ClampToEdge", 2: "Clamp", 3: "Repeat", 4: "RepeatMirrored",
+	}},
+	"SamplerFilterMode": {values: map[uint32]string{
+		0: "Nearest", 1: "Linear",
+	}},
+	"ImageFormat": {values: map[uint32]string{
+		0: "Unknown", 1: "Rgba32f", 2: "Rgba16f", 3: "R32f", 4: "Rgba8",
+		5: "Rgba8Snorm", 6: "Rg32f", 7: "Rg16f", 8: "R11fG11fB10f", 9: "R16f",
+		10: "Rgba16", 11: "Rgb10A2", 12: "Rg16", 13: "Rg8", 14: "R16", 15: "R8",
+		16: "Rgba16Snorm", 17: "Rg16Snorm", 18: "Rg8Snorm", 19: "R16Snorm",
+		20: "R8Snorm", 21: "Rgba32i", 22: "Rgba16i", 23: "Rgba8i", 24: "R32i",
+		25: "Rg32i", 26: "Rg16i", 27: "Rg8i", 28: "R16i", 29: "R8i",
+		30: "Rgba32ui", 31: "Rgba16ui", 32: "Rgba8ui", 33: "R32ui",
+		34: "Rgb10a2ui", 35: "Rg32ui", 36: "Rg16ui", 37: "Rg8ui", 38: "R16ui",
+		39: "R8ui",
+	}},
+	"AccessQualifier": {values: map[uint32]string{
+		0: "ReadOnly", 1: "WriteOnly", 2: "ReadWrite",
+	}},
+	"FunctionParameterAttribute": {values: map[uint32]string{
+		0: "Zext", 1: "Sext", 2: "ByVal", 3: "Sret", 4: "NoAlias",
+		5: "NoCapture", 6: "NoWrite", 7: "NoReadWrite",
+	}},
+	"FPRoundingMode": {values: map[uint32]string{
+		0: "RTE", 1: "RTZ", 2: "RTP", 3: "RTN",
+	}},
+	"LinkageType": {values: map[uint32]string{
+		0: "Export", 1: "Import",
+	}},
+	"Decoration": {values: map[uint32]string{
+		0: "RelaxedPrecision", 1: "SpecId", 2: "Block", 3: "BufferBlock",
+		4: "RowMajor", 5: "ColMajor", 6: "ArrayStride", 7: "MatrixStride",
+		8: "GLSLShared", 9: "GLSLPacked", 10: "CPacked", 11: "BuiltIn",
+		13: "NoPerspective", 14: "Flat", 15: "Patch", 16: "Centroid",
+		17: "Sample", 18: "Invariant", 19: "Restrict", 20: "Aliased",
+		21: "Volatile", 22: "Constant", 23: "Coherent", 24: "NonWritable",
+		25: "NonReadable", 26: "Uniform", 28: "SaturatedConversion",
+		29: "Stream", 30: "Location", 31: "Component", 32: "Index",
+		33: "Binding", 34: "DescriptorSet", 35: "Offset", 36: "XfbBuffer",
+		37: "XfbStride", 38: "FuncParamAttr", 39: "FPRoundingMode",
+		40: "FPFastMathMode", 41: "LinkageAttributes", 42: "NoContraction",
+		43: "InputAttachmentIndex", 44: "Alignment", 45: "MaxByteOffset",
+		46: "AlignmentId", 47: "MaxByteOffsetId", 4999: "ExplicitInterpAMD",
+		5248: "OverrideCoverageNV", 5250: "PassthroughNV",
+		5252: "ViewportRelativeNV", 5256: "SecondaryViewportRelativeNV",
+		5634: "HlslCounterBufferGOOGLE", 5635: "HlslSemanticGOOGLE",
+	}},
+	"BuiltIn": {values: map[uint32]string{
+		0: "Position", 1: "PointSize", 3: "ClipDistance", 4: "CullDistance",
+		5: "VertexId", 6: "InstanceId", 7: "PrimitiveId", 8: "InvocationId",
+		9: "Layer", 10: "ViewportIndex", 11: "TessLevelOuter",
+		12: "TessLevelInner", 13: "TessCoord", 14: "PatchVertices",
+		15: "FragCoord", 16: "PointCoord", 17: "FrontFacing", 18: "SampleId",
+		19: "SamplePosition", 20: "SampleMask", 22: "FragDepth",
+		23: "HelperInvocation", 24: "NumWorkgroups", 25: "WorkgroupSize",
+		26: "WorkgroupId", 27: "LocalInvocationId", 28: "GlobalInvocationId",
+		29: "LocalInvocationIndex", 30: "WorkDim", 31: "GlobalSize",
+		32: "EnqueuedWorkgroupSize", 33: "GlobalOffset", 34: "GlobalLinearId",
+		36: "SubgroupSize", 37: "SubgroupMaxSize", 38: "NumSubgroups",
+		39: "NumEnqueuedSubgroups", 40: "SubgroupId",
+		41: "SubgroupLocalInvocationId", 42: "VertexIndex", 43: "InstanceIndex",
+		4416: "SubgroupEqMask", 4417: "SubgroupGeMask", 4418: "SubgroupGtMask",
+		4419: "SubgroupLeMask", 4420: "SubgroupLtMask", 4424: "BaseVertex",
+		4425: "BaseInstance", 4426: "DrawIndex", 4438: "DeviceIndex",
+		4440: "ViewIndex",
+	}},
+	"GroupOperation": {values: map[uint32]string{
+		0: "Reduce", 1: "InclusiveScan", 2: "ExclusiveScan", 3: "ClusteredReduce",
+	}},
+	"Capability": {values: map[uint32]string{
+		0: "Matrix", 1: "Shader", 2: "Geometry", 3: "Tessellation",
+		4: "Addresses", 5: "Linkage", 6: "Kernel", 7: "Vector16",
+		8: "Float16Buffer", 9: "Float16", 10: "Float64", 11: "Int64",
+		12: "Int64Atomics", 13: "ImageBasic", 14: "ImageReadWrite",
+		15: "ImageMipmap", 17: "Pipes", 18: "Groups", 19: "DeviceEnqueue",
+		20: "LiteralSampler", 21: "AtomicStorage", 22: "Int16",
+		23: "TessellationPointSize", 24: "GeometryPointSize",
+		25: "ImageGatherExtended", 27: "StorageImageMultisample",
+		28: "UniformBufferArrayDynamicIndexing",
+		29: "SampledImageArrayDynamicIndexing",
+		30: "StorageBufferArrayDynamicIndexing",
+		31: "StorageImageArrayDynamicIndexing", 32: "ClipDistance",
+		33: "CullDistance", 34: "ImageCubeArray", 35: "SampleRateShading",
+		36: "ImageRect", 37: "SampledRect", 38: "GenericPointer", 39: "Int8",
+		40: "InputAttachment", 41: "SparseResidency", 42: "MinLod",
+		43: "Sampled1D", 44: "Image1D", 45: "SampledCubeArray",
+		46: "SampledBuffer", 47: "ImageBuffer", 48: "ImageMSArray",
+		49: "StorageImageExtendedFormats", 50: "ImageQuery",
+		51: "DerivativeControl", 52: "InterpolationFunction",
+		53: "TransformFeedback", 54: "GeometryStreams",
+		55: "StorageImageReadWithoutFormat", 56: "StorageImageWriteWithoutFormat",
+		57: "MultiViewport", 58: "SubgroupDispatch", 59: "NamedBarrier",
+		60: "PipeStorage", 61: "GroupNonUniform", 62: "GroupNonUniformVote",
+		63: "GroupNonUniformArithmetic", 64: "GroupNonUniformBallot",
+		65: "GroupNonUniformShuffle", 66: "GroupNonUniformShuffleRelative",
+		67: "GroupNonUniformClustered", 68: "GroupNonUniformQuad",
+		4423: "SubgroupBallotKHR", 4427: "DrawParameters",
+		4431: "SubgroupVoteKHR", 4433: "StorageBuffer16BitAccess",
+		4434: "UniformAndStorageBuffer16BitAccess", 4435: "StoragePushConstant16",
+		4436: "StorageInputOutput16", 4437: "DeviceGroup", 4439: "MultiView",
+		4441: "VariablePointersStorageBuffer", 4442: "VariablePointers",
+		4445: "AtomicStorageOps", 4447: "SampleMaskPostDepthCoverage",
+		4448: "StorageBuffer8BitAccess", 4449: "UniformAndStorageBuffer8BitAccess",
+		4450: "StoragePushConstant8",
+	}},
+	"ImageOperands": {mask: true, values: map[uint32]string{
+		0x1: "Bias", 0x2: "Lod", 0x4: "Grad", 0x8: "ConstOffset",
+		0x10: "Offset", 0x20: "ConstOffsets", 0x40: "Sample", 0x80: "MinLod",
+	}},
+	"FPFastMathMode": {mask: true, values: map[uint32]string{
+		0x1: "NotNaN", 0x2: "NotInf", 0x4: "NSZ", 0x8: "AllowRecip", 0x10: "Fast",
+	}},
+	"SelectionControl": {mask: true, values: map[uint32]string{
+		0x1: "Flatten", 0x2: "DontFlatten",
+	}},
+	"LoopControl": {mask: true, values: map[uint32]string{
+		0x1: "Unroll", 0x2: "DontUnroll", 0x4: "DependencyInfinite",
+		0x8: "DependencyLength",
+	}},
+	"FunctionControl": {mask: true, values: map[uint32]string{
+		0x1: "Inline", 0x2: "DontInline", 0x4: "Pure", 0x8: "Const",
+	}},
+	"MemoryAccess": {mask: true, values: map[uint32]string{
+		0x1: "Volatile", 0x2: "Aligned", 0x4: "Nontemporal",
+	}},
+}
+
+// maskOperands is the layout of the operands following the masks that have
+// some. Each set bit of these masks is followed by its own operands, in bit
+// order, which are all ids or all literals.
+var maskOperands = map[string]string{
+	"ImageOperands": "i*",
+	"LoopControl":   "l*",
+	"MemoryAccess":  "l*",
+}
+
+// decorationOperands is the layout of the operands following the decorations
+// that have some. Other decorations are followed by literal numbers, if any.
+var decorationOperands = map[uint32]string{
+	11:   "BuiltIn",
+	38:   "FunctionParameterAttribute",
+	39:   "FPRoundingMode",
+	40:   "FPFastMathMode",
+	41:   "s LinkageType",
+	46:   "i",
+	47:   "i",
+	5634: "i",
+	5635: "s",
+}
+
+// executionModeOperands is the layout of the operands following the execution
+// modes taking ids. Other modes are followed by literal numbers, if any.
+var executionModeOperands = map[uint32]string{
+	37: "i",
+	38: "i i i",
+	39: "i i i",
+}
+
+// extInstNames holds the instruction names of the extended instruction sets.
+var extInstNames = map[string][]string{
+	"GLSL.std.450": {
+		"", "Round", "RoundEven", "Trunc", "FAbs", "SAbs", "FSign", "SSign",
+		"Floor", "Ceil", "Fract", "Radians", "Degrees", "Sin", "Cos", "Tan",
+		"Asin", "Acos", "Atan", "Sinh", "Cosh", "Tanh", "Asinh", "Acosh",
+		"Atanh", "Atan2", "Pow", "Exp", "Log", "Exp2", "Log2", "Sqrt",
+		"InverseSqrt", "Determinant", "MatrixInverse", "Modf", "ModfStruct",
+		"FMin", "UMin", "SMin", "FMax", "UMax", "SMax", "FClamp", "UClamp",
+		"SClamp", "FMix", "IMix", "Step", "SmoothStep", "Fma", "Frexp",
+		"FrexpStruct", "Ldexp", "PackSnorm4x8", "PackUnorm4x8",
+		"PackSnorm2x16", "PackUnorm2x16", "PackHalf2x16", "PackDouble2x32",
+		"UnpackSnorm2x16", "UnpackUnorm2x16", "UnpackHalf2x16",
+		"UnpackSnorm4x8", "UnpackUnorm4x8", "UnpackDouble2x32", "Length",
+		"Distance", "Cross", "Normalize", "FaceForward", "Reflect", "Refract",
+		"FindILsb", "FindSMsb", "FindUMsb", "InterpolateAtCentroid",
+		"InterpolateAtSample", "InterpolateAtOffset", "NMin", "NMax", "NClamp",
+	},
+}
+
+// generators holds the vendor and tool names of the registered SPIR-V
+// generators.
+var generators = map[uint32]string{
+	0:  "Khronos",
+	1:  "LunarG",
+	2:  "Valve",
+	3:  "Codeplay",
+	4:  "NVIDIA",
+	5:  "ARM",
+	6:  "Khronos LLVM/SPIR-V Translator",
+	7:  "Khronos SPIR-V Tools Assembler",
+	8:  "Khronos Glslang Reference Front End",
+	9:  "Qualcomm",
+	10: "AMD",
+	11: "Intel",
+	12: "Imagination",
+	13: "Google Shaderc over Glslang",
+	14: "Google spiregg",
+	15: "Google rspirv",
+	16: "X-LEGEND Mesa-IR/SPIR-V Translator",
+	17: "Khronos SPIR-V Tools Linker",
+}
@@ -0,0 +1,422 @@
+// Copyright (C) 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package spirv
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Opcode is a SPIR-V instruction opcode.
+type Opcode uint32
+
+// The opcodes the package inspects when reflecting modules.
+const (
+	OpName                  Opcode = 5
+	OpMemberName            Opcode = 6
+	OpExtInstImport         Opcode = 11
+	OpEntryPoint            Opcode = 15
+	OpTypeVoid              Opcode = 19
+	OpTypeBool              Opcode = 20
+	OpTypeInt               Opcode = 21
+	OpTypeFloat             Opcode = 22
+	OpTypeVector            Opcode = 23
+	OpTypeMatrix            Opcode = 24
+	OpTypeImage             Opcode = 25
+	OpTypeSampler           Opcode = 26
+	OpTypeSampledImage      Opcode = 27
+	OpTypeArray             Opcode = 28
+	OpTypeRuntimeArray      Opcode = 29
+	OpTypeStruct            Opcode = 30
+	OpTypePointer           Opcode = 32
+	OpConstantTrue          Opcode = 41
+	OpConstantFalse         Opcode = 42
+	OpConstant              Opcode = 43
+	OpSpecConstantTrue      Opcode = 48
+	OpSpecConstantFalse     Opcode = 49
+	OpSpecConstant          Opcode = 50
+	OpSpecConstantComposite Opcode = 51
+	OpSpecConstantOp        Opcode = 52
+	OpFunction              Opcode = 54
+	OpFunctionEnd           Opcode = 56
+	OpFunctionCall          Opcode = 57
+	OpVariable              Opcode = 59
+	OpDecorate              Opcode = 71
+	OpMemberDecorate        Opcode = 72
+	OpSwitch                Opcode = 251
+)
+
+// String returns the name of the opcode, without the Op prefix, as returned
+// by spvOpcodeString.
+func (o Opcode) String() string {
+	if info, ok := opcodes[o]; ok {
+		return info.name
+	}
+	return fmt.Sprintf("Unknown(%d)", uint32(o))
+}
+
+// opcodeInfo describes the operands of an opcode.
+type opcodeInfo struct {
+	name      string
+	hasType   bool
+	hasResult bool
+	operands  []string
+}
+
+// opcodes is built from the layouts of opcodeLayouts.
+var opcodes = map[Opcode]opcodeInfo{}
+
+// opcodeLayouts holds the name and operand layout of each opcode.
+// The layout is a space separated list of operands:
+//
+//	T    the result type id
+//	R    the result id
+//	i    an id
+//	l    a literal number
+//	s    a literal string
+//	c    a literal of the result type, for constants
+//	x    an extended instruction number followed by ids
+//	o    an opcode followed by the operands of that opcode
+//	w    literal and label id pairs of OpSwitch
+//	g    id and literal pairs
+//	D    a decoration followed by its operands
+//	E    an execution mode followed by its operands
+//	Name an operand of the enum Name
+//
+// The suffix ? marks an optional operand, * an operand repeated until the end
+// of the instruction.
+var opcodeLayouts = []struct {
+	op     Opcode
+	name   string
+	layout string
+}{
+	{0, "Nop", ""},
+	{1, "Undef", "T R"},
+	{2, "SourceContinued", "s"},
+	{3, "Source", "SourceLanguage l i? s?"},
+	{4, "SourceExtension", "s"},
+	{5, "Name", "i s"},
+	{6, "MemberName", "i l s"},
+	{7, "String", "R s"},
+	{8, "Line", "i l l"},
+	{10, "Extension", "s"},
+	{11, "ExtInstImport", "R s"},
+	{12, "ExtInst", "T R i x"},
+	{14, "MemoryModel", "AddressingModel MemoryModel"},
+	{15, "EntryPoint", "ExecutionModel i s i*"},
+	{16, "ExecutionMode", "i E"},
+	{17, "Capability", "Capability"},
+	{19, "TypeVoid", "R"},
+	{20, "TypeBool", "R"},
+	{21, "TypeInt", "R l l"},
+	{22, "TypeFloat", "R l"},
+	{23, "TypeVector", "R i l"},
+	{24, "TypeMatrix", "R i l"},
+	{25, "TypeImage", "R i Dim l l l l ImageFormat AccessQualifier?"},
+	{26, "TypeSampler", "R"},
+	{27, "TypeSampledImage", "R i"},
+	{28, "TypeArray", "R i i"},
+	{29, "TypeRuntimeArray", "R i"},
+	{30, "TypeStruct", "R i*"},
+	{31, "TypeOpaque", "R s"},
+	{32, "TypePointer", "R StorageClass i"},
+	{33, "TypeFunction", "R i i*"},
+	{34, "TypeEvent", "R"},
+	{35, "TypeDeviceEvent", "R"},
+	{36, "TypeReserveId", "R"},
+	{37, "TypeQueue", "R"},
+	{38, "TypePipe", "R AccessQualifier"},
+	{39, "TypeForwardPointer", "i StorageClass"},
+	{41, "ConstantTrue", "T R"},
+	{42, "ConstantFalse", "T R"},
+	{43, "Constant", "T R c"},
+	{44, "ConstantComposite", "T R i*"},
+	{45, "ConstantSampler", "T R SamplerAddressingMode l SamplerFilterMode"},
+	{46, "ConstantNull", "T R"},
+	{48, "SpecConstantTrue", "T R"},
+	{49, "SpecConstantFalse", "T R"},
+	{50, "SpecConstant", "T R c"},
+	{51, "SpecConstantComposite", "T R i*"},
+	{52, "SpecConstantOp", "T R o"},
+	{54, "Function", "T R FunctionControl i"},
+	{55, "FunctionParameter", "T R"},
+	{56, "FunctionEnd", ""},
+	{57, "FunctionCall", "T R i i*"},
+	{59, "Variable", "T R StorageClass i?"},
+	{60, "ImageTexelPointer", "T R i i i"},
+	{61, "Load", "T R i MemoryAccess?"},
+	{62, "Store", "i i MemoryAccess?"},
+	{63, "CopyMemory", "i i MemoryAccess?"},
+	{64, "CopyMemorySized", "i i i MemoryAccess?"},
+	{65, "AccessChain", "T R i i*"},
+	{66, "InBoundsAccessChain", "T R i i*"},
+	{67, "PtrAccessChain", "T R i i i*"},
+	{68, "ArrayLength", "T R i l"},
+	{69, "GenericPtrMemSemantics", "T R i"},
+	{70, "InBoundsPtrAccessChain", "T R i i i*"},
+	{71, "Decorate", "i D"},
+	{72, "MemberDecorate", "i l D"},
+	{73, "DecorationGroup", "R"},
+	{74, "GroupDecorate", "i i*"},
+	{75, "GroupMemberDecorate", "i g"},
+	{77, "VectorExtractDynamic", "T R i i"},
+	{78, "VectorInsertDynamic", "T R i i i"},
+	{79, "VectorShuffle", "T R i i l*"},
+	{80, "CompositeConstruct", "T R i*"},
+	{81, "CompositeExtract", "T R i l*"},
+	{82, "CompositeInsert", "T R i i l*"},
+	{83, "CopyObject", "T R i"},
+	{84, "Transpose", "T R i"},
+	{86, "SampledImage", "T R i i"},
+	{87, "ImageSampleImplicitLod", "T R i i ImageOperands?"},
+	{88, "ImageSampleExplicitLod", "T R i i ImageOperands"},
+	{89, "ImageSampleDrefImplicitLod", "T R i i i ImageOperands?"},
+	{90, "ImageSampleDrefExplicitLod", "T R i i i ImageOperands"},
+	{91, "ImageSampleProjImplicitLod", "T R i i ImageOperands?"},
+	{92, "ImageSampleProjExplicitLod", "T R i i ImageOperands"},
+	{93, "ImageSampleProjDrefImplicitLod", "T R i i i ImageOperands?"},
+	{94, "ImageSampleProjDrefExplicitLod", "T R i i i ImageOperands"},
+	{95, "ImageFetch", "T R i i ImageOperands?"},
+	{96, "ImageGather", "T R i i i ImageOperands?"},
+	{97, "ImageDrefGather", "T R i i i ImageOperands?"},
+	{98, "ImageRead", "T R i i ImageOperands?"},
+	{99, "ImageWrite", "i i i ImageOperands?"},
+	{100, "Image", "T R i"},
+	{101, "ImageQueryFormat", "T R i"},
+	{102, "ImageQueryOrder", "T R i"},
+	{103, "ImageQuerySizeLod", "T R i i"},
+	{104, "ImageQuerySize", "T R i"},
+	{105, "ImageQueryLod", "T R i i"},
+	{106, "ImageQueryLevels", "T R i"},
+	{107, "ImageQuerySamples", "T R i"},
+	{109, "ConvertFToU", "T R i"},
+	{110, "ConvertFToS", "T R i"},
+	{111, "ConvertSToF", "T R i"},
+	{112, "ConvertUToF", "T R i"},
+	{113, "UConvert", "T R i"},
+	{114, "SConvert", "T R i"},
+	{115, "FConvert", "T R i"},
+	{116, "QuantizeToF16", "T R i"},
+	{117, "ConvertPtrToU", "T R i"},
+	{118, "SatConvertSToU", "T R i"},
+	{119, "SatConvertUToS", "T R i"},
+	{120, "ConvertUToPtr", "T R i"},
+	{121, "PtrCastToGeneric", "T R i"},
+	{122, "GenericCastToPtr", "T R i"},
+	{123, "GenericCastToPtrExplicit", "T R i StorageClass"},
+	{124, "Bitcast", "T R i"},
+	{126, "SNegate", "T R i"},
+	{127, "FNegate", "T R i"},
+	{128, "IAdd", "T R i i"},
+	{129, "FAdd", "T R i i"},
+	{130, "ISub", "T R i i"},
+	{131, "FSub", "T R i i"},
+	{132, "IMul", "T R i i"},
+	{133, "FMul", "T R i i"},
+	{134, "UDiv", "T R i i"},
+	{135, "SDiv", "T R i i"},
+	{136, "FDiv", "T R i i"},
+	{137, "UMod", "T R i i"},
+	{138, "SRem", "T R i i"},
+	{139, "SMod", "T R i i"},
+	{140, "FRem", "T R i i"},
+	{141, "FMod", "T R i i"},
+	{142, "VectorTimesScalar", "T R i i"},
+	{143, "MatrixTimesScalar", "T R i i"},
+	{144, "VectorTimesMatrix", "T R i i"},
+	{145, "MatrixTimesVector", "T R i i"},
+	{146, "MatrixTimesMatrix", "T R i i"},
+	{147, "OuterProduct", "T R i i"},
+	{148, "Dot", "T R i i"},
+	{149, "IAddCarry", "T R i i"},
+	{150, "ISubBorrow", "T R i i"},
+	{151, "UMulExtended", "T R i i"},
+	{152, "SMulExtended", "T R i i"},
+	{154, "Any", "T R i"},
+	{155, "All", "T R i"},
+	{156, "IsNan", "T R i"},
+	{157, "IsInf", "T R i"},
+	{158, "IsFinite", "T R i"},
+	{159, "IsNormal", "T R i"},
+	{160, "SignBitSet", "T R i"},
+	{161, "LessOrGreater", "T R i i"},
+	{162, "Ordered", "T R i i"},
+	{163, "Unordered", "T R i i"},
+	{164, "LogicalEqual", "T R i i"},
+	{165, "LogicalNotEqual", "T R i i"},
+	{166, "LogicalOr", "T R i i"},
+	{167, "LogicalAnd", "T R i i"},
+	{168, "LogicalNot", "T R i"},
+	{169, "Select", "T R i i i"},
+	{170, "IEqual", "T R i i"},
+	{171, "INotEqual", "T R i i"},
+	{172, "UGreaterThan", "T R i i"},
+	{173, "SGreaterThan", "T R i i"},
+	{174, "UGreaterThanEqual", "T R i i"},
+	{175, "SGreaterThanEqual", "T R i i"},
+	{176, "ULessThan", "T R i i"},
+	{177, "SLessThan", "T R i i"},
+	{178, "ULessThanEqual", "T R i i"},
+	{179, "SLessThanEqual", "T R i i"},
+	{180, "FOrdEqual", "T R i i"},
+	{181, "FUnordEqual", "T R i i"},
+	{182, "FOrdNotEqual", "T R i i"},
+	{183, "FUnordNotEqual", "T R i i"},
+	{184, "FOrdLessThan", "T R i i"},
+	{185, "FUnordLessThan", "T R i i"},
+	{186, "FOrdGreaterThan", "T R i i"},
+	{187, "FUnordGreaterThan", "T R i i"},
+	{188, "FOrdLessThanEqual", "T R i i"},
+	{189, "FUnordLessThanEqual", "T R i i"},
+	{190, "FOrdGreaterThanEqual", "T R i i"},
+	{191, "FUnordGreaterThanEqual", "T R i i"},
+	{194, "ShiftRightLogical", "T R i i"},
+	{195, "ShiftRightArithmetic", "T R i i"},
+	{196, "ShiftLeftLogical", "T R i i"},
+	{197, "BitwiseOr", "T R i i"},
+	{198, "BitwiseXor", "T R i i"},
+	{199, "BitwiseAnd", "T R i i"},
+	{200, "Not", "T R i"},
+	{201, "BitFieldInsert", "T R i i i i"},
+	{202, "BitFieldSExtract", "T R i i i"},
+	{203, "BitFieldUExtract", "T R i i i"},
+	{204, "BitReverse", "T R i"},
+	{205, "BitCount", "T R i"},
+	{207, "DPdx", "T R i"},
+	{208, "DPdy", "T R i"},
+	{209, "Fwidth", "T R i"},
+	{210, "DPdxFine", "T R i"},
+	{211, "DPdyFine", "T R i"},
+	{212, "FwidthFine", "T R i"},
+	{213, "DPdxCoarse", "T R i"},
+	{214, "DPdyCoarse", "T R i"},
+	{215, "FwidthCoarse", "T R i"},
+	{218, "EmitVertex", ""},
+	{219, "EndPrimitive", ""},
+	{220, "EmitStreamVertex", "i"},
+	{221, "EndStreamPrimitive", "i"},
+	{224, "ControlBarrier", "i i i"},
+	{225, "MemoryBarrier", "i i"},
+	{227, "AtomicLoad", "T R i i i"},
+	{228, "AtomicStore", "i i i i"},
+	{229, "AtomicExchange", "T R i i i i"},
+	{230, "AtomicCompareExchange", "T R i i i i i i"},
+	{231, "AtomicCompareExchangeWeak", "T R i i i i i i"},
+	{232, "AtomicIIncrement", "T R i i i"},
+	{233, "AtomicIDecrement", "T R i i i"},
+	{234, "AtomicIAdd", "T R i i i i"},
+	{235, "AtomicISub", "T R i i i i"},
+	{236, "AtomicSMin", "T R i i i i"},
+	{237, "AtomicUMin", "T R i i i i"},
+	{238, "AtomicSMax", "T R i i i i"},
+	{239, "AtomicUMax", "T R i i i i"},
+	{240, "AtomicAnd", "T R i i i i"},
+	{241, "AtomicOr", "T R i i i i"},
+	{242, "AtomicXor", "T R i i i i"},
+	{245, "Phi", "T R i*"},
+	{246, "LoopMerge", "i i LoopControl"},
+	{247, "SelectionMerge", "i SelectionControl"},
+	{248, "Label", "R"},
+	{249, "Branch", "i"},
+	{250, "BranchConditional", "i i i l*"},
+	{251, "Switch", "i i w"},
+	{252, "Kill", ""},
+	{253, "Return", ""},
+	{254, "ReturnValue", "i"},
+	{255, "Unreachable", ""},
+	{256, "LifetimeStart", "i l"},
+	{257, "LifetimeStop", "i l"},
+	{259, "GroupAsyncCopy", "T R i i i i i i"},
+	{260, "GroupWaitEvents", "i i i"},
+	{261, "GroupAll", "T R i i"},
+	{262, "GroupAny", "T R i i"},
+	{263, "GroupBroadcast", "T R i i i"},
+	{264, "GroupIAdd", "T R i GroupOperation i"},
+	{265, "GroupFAdd", "T R i GroupOperation i"},
+	{266, "GroupFMin", "T R i GroupOperation i"},
+	{267, "GroupUMin", "T R i GroupOperation i"},
+	{268, "GroupSMin", "T R i GroupOperation i"},
+	{269, "GroupFMax", "T R i GroupOperation i"},
+	{270, "GroupUMax", "T R i GroupOperation i"},
+	{271, "GroupSMax", "T R i GroupOperation i"},
+	{305, "ImageSparseSampleImplicitLod", "T R i i ImageOperands?"},
+	{306, "ImageSparseSampleExplicitLod", "T R i i ImageOperands"},
+	{307, "ImageSparseSampleDrefImplicitLod", "T R i i i ImageOperands?"},
+	{308, "ImageSparseSampleDrefExplicitLod", "T R i i i ImageOperands"},
+	{313, "ImageSparseFetch", "T R i i ImageOperands?"},
+	{314, "ImageSparseGather", "T R i i i ImageOperands?"},
+	{315, "ImageSparseDrefGather", "T R i i i ImageOperands?"},
+	{316, "ImageSparseTexelsResident", "T R i"},
+	{317, "NoLine", ""},
+	{320, "ImageSparseRead", "T R i i ImageOperands?"},
+	{330, "ModuleProcessed", "s"},
+	{331, "ExecutionModeId", "i E"},
+	{332, "DecorateId", "i D"},
+	{333, "GroupNonUniformElect", "T R i"},
+	{334, "GroupNonUniformAll", "T R i i"},
+	{335, "GroupNonUniformAny", "T R i i"},
+	{336, "GroupNonUniformAllEqual", "T R i i"},
+	{337, "GroupNonUniformBroadcast", "T R i i i"},
+	{338, "GroupNonUniformBroadcastFirst", "T R i i"},
+	{339, "GroupNonUniformBallot", "T R i i"},
+	{340, "GroupNonUniformInverseBallot", "T R i i"},
+	{341, "GroupNonUniformBallotBitExtract", "T R i i i"},
+	{342, "GroupNonUniformBallotBitCount", "T R i GroupOperation i"},
+	{343, "GroupNonUniformBallotFindLSB", "T R i i"},
+	{344, "GroupNonUniformBallotFindMSB", "T R i i"},
+	{345, "GroupNonUniformShuffle", "T R i i i"},
+	{346, "GroupNonUniformShuffleXor", "T R i i i"},
+	{347, "GroupNonUniformShuffleUp", "T R i i i"},
+	{348, "GroupNonUniformShuffleDown", "T R i i i"},
+	{349, "GroupNonUniformIAdd", "T R i GroupOperation i i?"},
+	{350, "GroupNonUniformFAdd", "T R i GroupOperation i i?"},
+	{351, "GroupNonUniformIMul", "T R i GroupOperation i i?"},
+	{352, "GroupNonUniformFMul", "T R i GroupOperation i i?"},
+	{353, "GroupNonUniformSMin", "T R i GroupOperation i i?"},
+	{354, "GroupNonUniformUMin", "T R i GroupOperation i i?"},
+	{355, "GroupNonUniformFMin", "T R i GroupOperation i i?"},
+	{356, "GroupNonUniformSMax", "T R i GroupOperation i i?"},
+	{357, "GroupNonUniformUMax", "T R i GroupOperation i i?"},
+	{358, "GroupNonUniformFMax", "T R i GroupOperation i i?"},
+	{359, "GroupNonUniformBitwiseAnd", "T R i GroupOperation i i?"},
+	{360, "GroupNonUniformBitwiseOr", "T R i GroupOperation i i?"},
+	{361, "GroupNonUniformBitwiseXor", "T R i GroupOperation i i?"},
+	{362, "GroupNonUniformLogicalAnd", "T R i GroupOperation i i?"},
+	{363, "GroupNonUniformLogicalOr", "T R i GroupOperation i i?"},
+	{364, "GroupNonUniformLogicalXor", "T R i GroupOperation i i?"},
+	{365, "GroupNonUniformQuadBroadcast", "T R i i i"},
+	{366, "GroupNonUniformQuadSwap", "T R i i i"},
+	{4421, "SubgroupBallotKHR", "T R i"},
+	{4422, "SubgroupFirstInvocationKHR", "T R i"},
+	{4428, "SubgroupAllKHR", "T R i"},
+	{4429, "SubgroupAnyKHR", "T R i"},
+	{4430, "SubgroupAllEqualKHR", "T R i"},
+	{4432, "SubgroupReadInvocationKHR", "T R i i"},
+	{5632, "DecorateStringGOOGLE", "i D"},
+	{5633, "MemberDecorateStringGOOGLE", "i l D"},
+}
+
+func init() {
+	for _, l := range opcodeLayouts {
+		info := opcodeInfo{name: l.name, operands: strings.Fields(l.layout)}
+		if len(info.operands) > 0 && info.operands[0] == "T" {
+			info.hasType, info.operands = true, info.operands[1:]
+		}
+		if len(info.operands) > 0 && info.operands[0] == "R" {
+			info.hasResult, info.operands = true, info.operands[1:]
+		}
+		opcodes[l.op] = info
+	}
+}
@@ -0,0 +1,176 @@
+// Copyright (C) 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package spirv
+
+import "strings"
+
+type operandKind int
+
+const (
+	idOperand       operandKind = iota // An id.
+	literalOperand                     // A literal number.
+	stringOperand                      // A literal string.
+	constantOperand                    // A literal number of the result type.
+	switchOperand                      // A literal number of the OpSwitch selector type.
+	extInstOperand                     // An extended instruction number.
+	opcodeOperand                      // An OpSpecConstantOp opcode.
+	enumOperand                        // A value of an enum.
+)
+
+// operand is a decoded instruction operand.
+type operand struct {
+	kind  operandKind
+	enum  string   // The enum name, for enumOperand.
+	words []uint32 // The operand words.
+	str   string   // The decoded string, for stringOperand.
+}
+
+// module holds the definitions of a module ids, needed to decode the
+// operands of instructions that depend on other instructions.
+type module struct {
+	*Module
+	defs map[uint32]Instruction // Result id to defining instruction.
+}
+
+func newModule(m *Module) *module {
+	out := &module{Module: m, defs: map[uint32]Instruction{}}
+	for _, i := range m.Instructions {
+		if id := i.Result(); id != 0 {
+			out.defs[id] = i
+		}
+	}
+	return out
+}
+
+// literalWords returns the number of words of literals of the type with the
+// given id, 2 for 64 bit numbers and 1 otherwise.
+func (m *module) literalWords(typ uint32) int {
+	t, ok := m.defs[typ]
+	if ok && (t.Opcode == OpTypeInt || t.Opcode == OpTypeFloat) && len(t.Operands) > 1 && t.Operands[1] > 32 {
+		return 2
+	}
+	return 1
+}
+
+// operands decodes the operands of the instruction i, excluding its result
+// type and result ids. Operands that do not match the layout of the opcode are
+// decoded as literal numbers.
+func (m *module) operands(i Instruction) []operand {
+	info, ok := opcodes[i.Opcode]
+	words := i.Operands
+	if info.hasType && len(words) > 0 {
+		words = words[1:]
+	}
+	if info.hasResult && len(words) > 0 {
+		words = words[1:]
+	}
+	d := &decoder{m: m, inst: i, words: words}
+	if ok {
+		d.decode(info.operands)
+	}
+	for _, w := range d.words {
+		d.out = append(d.out, operand{kind: literalOperand, words: []uint32{w}})
+	}
+	return d.out
+}
+
+type decoder struct {
+	m     *module
+	inst  Instruction
+	words []uint32
+	out   []operand
+}
+
+func (d *decoder) take(kind operandKind, n int) []uint32 {
+	if n > len(d.words) {
+		n = len(d.words)
+	}
+	words := d.words[:n]
+	d.words = d.words[n:]
+	d.out = append(d.out, operand{kind: kind, words: words})
+	return words
+}
+
+func (d *decoder) decode(layout []string) {
+	for _, l := range layout {
+		if base := strings.TrimSuffix(l, "*"); base != l {
+			for len(d.words) > 0 {
+				d.decodeOne(base)
+			}
+			continue
+		}
+		if len(d.words) == 0 {
+			return // Either optional, or a malformed instruction.
+		}
+		d.decodeOne(strings.TrimSuffix(l, "?"))
+	}
+}
+
+func (d *decoder) decodeOne(l string) {
+	switch l {
+	case "i":
+		d.take(idOperand, 1)
+	case "l":
+		d.take(literalOperand, 1)
+	case "s":
+		str, n := decodeString(d.words)
+		d.take(stringOperand, n)
+		d.out[len(d.out)-1].str = str
+	case "c":
+		d.take(constantOperand, len(d.words))
+	case "x":
+		d.take(extInstOperand, 1)
+		d.decode([]string{"i*"})
+	case "o":
+		op := Opcode(d.take(opcodeOperand, 1)[0])
+		d.decode(opcodes[op].operands)
+	case "w":
+		n := 1
+		if len(d.inst.Operands) > 0 {
+			n = d.m.literalWords(d.m.defs[d.inst.Operands[0]].ResultType())
+		}
+		for len(d.words) > 0 {
+			d.take(switchOperand, n)
+			d.take(idOperand, 1)
+		}
+	case "g":
+		for len(d.words) > 0 {
+			d.take(idOperand, 1)
+			d.take(literalOperand, 1)
+		}
+	case "D":
+		d.decodeEnum("Decoration", decorationOperands)
+	case "E":
+		d.decodeEnum("ExecutionMode", executionModeOperands)
+	default:
+		d.take(enumOperand, 1)
+		d.out[len(d.out)-1].enum = l
+		if layout, ok := maskOperands[l]; ok {
+			d.decode(strings.Fields(layout))
+		}
+	}
+}
+
+// decodeEnum decodes a value of the enum name followed by its operands, which
+// are described by layouts, or are literal numbers.
+func (d *decoder) decodeEnum(name string, layouts map[uint32]string) {
+	v := d.take(enumOperand, 1)[0]
+	d.out[len(d.out)-1].enum = name
+	if layout, ok := layouts[v]; ok {
+		d.decode(strings.Fields(layout))
+	} else {
+		d.decode([]string{"l*"})
+	}
+}
@@ -0,0 +1,444 @@
+// Copyright (C) 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package spirv
+
+import (
+	"fmt"
+	"sort"
+)
+
+// VkDescriptorType values of descriptor bindings.
+const (
+	DescriptorTypeSampler              = 0
+	DescriptorTypeCombinedImageSampler = 1
+	DescriptorTypeSampledImage         = 2
+	DescriptorTypeStorageImage         = 3
+	DescriptorTypeUniformTexelBuffer   = 4
+	DescriptorTypeStorageTexelBuffer   = 5
+	DescriptorTypeUniformBuffer        = 6
+	DescriptorTypeStorageBuffer        = 7
+	DescriptorTypeInputAttachment      = 10
+)
+
+// EntryPoint is an entry point of a module.
+type EntryPoint struct {
+	Name           string
+	ExecutionModel ExecutionModel
+	Function       uint32   // The id of the entry point function.
+	Interface      []uint32 // The ids of the entry point interface variables.
+}
+
+// DescriptorBinding is a resource variable bound to a descriptor set binding.
+type DescriptorBinding struct {
+	ID             uint32 // The id of the variable.
+	Name           string
+	Set            uint32
+	Binding        uint32
+	DescriptorType uint32 // The VkDescriptorType of the binding.
+	Count          uint32 // The number of descriptors, greater than 1 for arrays.
+}
+
+// Variable is an input, output or push constant variable.
+type Variable struct {
+	ID           uint32 // The id of the variable.
+	Name         string
+	StorageClass StorageClass
+	Type         string // The friendly name of the type of the variable.
+	Location     uint32 // The location of the variable, if it is not built-in.
+	BuiltIn      string // The built-in of the variable, or of its first block member.
+	Size         uint32 // The size in bytes of the variable, for push constants.
+}
+
+// SpecConstant is a specialization constant.
+type SpecConstant struct {
+	ID      uint32 // The id of the constant.
+	Name    string
+	SpecID  uint32
+	Type    string   // The friendly name of the type of the constant.
+	Default []uint32 // The words of the default value.
+}
+
+// Reflection is the interface of an entry point of a module.
+type Reflection struct {
+	EntryPoint
+	// The bindings statically used by the entry point, sorted by set, binding
+	// and id.
+	DescriptorBindings []DescriptorBinding
+	// The push constant variables statically used by the entry point.
+	PushConstants []Variable
+	// The input and output variables of the entry point interface.
+	Inputs, Outputs []Variable
+	// The specialization constants of the module, sorted by specialization id.
+	SpecConstants []SpecConstant
+}
+
+// EntryPoints returns the entry points of the module.
+func (m *Module) EntryPoints() []EntryPoint {
+	out := []EntryPoint{}
+	for _, i := range m.Instructions {
+		if i.Opcode != OpEntryPoint || len(i.Operands) < 3 {
+			continue
+		}
+		name, n := decodeString(i.Operands[2:])
+		out = append(out, EntryPoint{
+			Name:           name,
+			ExecutionModel: ExecutionModel(i.Operands[0]),
+			Function:       i.Operands[1],
+			Interface:      append([]uint32{}, i.Operands[2+n:]...),
+		})
+	}
+	return out
+}
+
+// Reflect returns the interface of the entry point with the given name.
+func (m *Module) Reflect(entryPoint string) (*Reflection, error) {
+	for _, e := range m.EntryPoints() {
+		if e.Name == entryPoint {
+			return newReflector(m).reflect(e), nil
+		}
+	}
+	return nil, fmt.Errorf("Entry point %v not found", entryPoint)
+}
+
+// reflector holds the debug names, decorations and functions of a module.
+type reflector struct {
+	*module
+	names             map[uint32]string
+	typeNames         map[uint32]string
+	decorations       map[uint32]map[uint32][]uint32
+	memberDecorations map[uint32]map[uint32]map[uint32][]uint32
+	functions         map[uint32][]Instruction
+	variables         []Instruction // The global variables.
+}
+
+func newReflector(m *Module) *reflector {
+	r := &reflector{
+		module:            newModule(m),
+		names:             map[uint32]string{},
+		decorations:       map[uint32]map[uint32][]uint32{},
+		memberDecorations: map[uint32]map[uint32]map[uint32][]uint32{},
+		functions:         map[uint32][]Instruction{},
+	}
+	r.typeNames = r.friendlyNames()
+	function := uint32(0)
+	for _, i := range m.Instructions {
+		ops := i.Operands
+		switch {
+		case i.Opcode == OpName && len(ops) > 1:
+			r.names[ops[0]], _ = decodeString(ops[1:])
+		case i.Opcode == OpDecorate && len(ops) > 1:
+			if r.decorations[ops[0]] == nil {
+				r.decorations[ops[0]] = map[uint32][]uint32{}
+			}
+			r.decorations[ops[0]][ops[1]] = ops[2:]
+		case i.Opcode == OpMemberDecorate && len(ops) > 2:
+			members := r.memberDecorations[ops[0]]
+			if members == nil {
+				members = map[uint32]map[uint32][]uint32{}
+				r.memberDecorations[ops[0]] = members
+			}
+			if members[ops[1]] == nil {
+				members[ops[1]] = map[uint32][]uint32{}
+			}
+			members[ops[1]][ops[2]] = ops[3:]
+		case i.Opcode == OpFunction && len(ops) > 1:
+			function = ops[1]
+		case i.Opcode == OpFunctionEnd:
+			function = 0
+		case function != 0:
+			r.functions[function] = append(r.functions[function], i)
+		case i.Opcode == OpVariable:
+			r.variables = append(r.variables, i)
+		}
+	}
+	return r
+}
+
+func (r *reflector) reflect(e EntryPoint) *Reflection {
+	out := &Reflection{EntryPoint: e}
+	used := r.staticallyUsed(e.Function)
+	inInterface := map[uint32]bool{}
+	for _, id := range e.Interface {
+		inInterface[id] = true
+	}
+
+	for _, v := range r.variables {
+		if len(v.Operands) < 3 {
+			continue
+		}
+		id, class := v.Operands[1], StorageClass(v.Operands[2])
+		switch class {
+		case Input, Output:
+			if !inInterface[id] {
+				continue
+			}
+			variable := r.variable(v)
+			if class == Input {
+				out.Inputs = append(out.Inputs, variable)
+			} else {
+				out.Outputs = append(out.Outputs, variable)
+			}
+		case PushConstant:
+			if used[id] {
+				variable := r.variable(v)
+				variable.Size = r.size(r.pointee(v.ResultType()), nil)
+				out.PushConstants = append(out.PushConstants, variable)
+			}
+		case UniformConstant, Uniform, StorageBuffer:
+			if !used[id] {
+				continue
+			}
+			if binding, ok := r.descriptorBinding(v); ok {
+				out.DescriptorBindings = append(out.DescriptorBindings, binding)
+			}
+		}
+	}
+	sort.Slice(out.DescriptorBindings, func(i, j int) bool {
+		a, b := out.DescriptorBindings[i], out.DescriptorBindings[j]
+		switch {
+		case a.Set != b.Set:
+			return a.Set < b.Set
+		case a.Binding != b.Binding:
+			return a.Binding < b.Binding
+		default:
+			return a.ID < b.ID
+		}
+	})
+
+	for _, i := range r.Instructions {
+		switch i.Opcode {
+		case OpSpecConstantTrue, OpSpecConstantFalse, OpSpecConstant:
+		default:
+			continue
+		}
+		id := i.Result()
+		specID, ok := r.decorations[id][decorationSpecID]
+		if !ok || len(specID) == 0 {
+			continue
+		}
+		c := SpecConstant{
+			ID:     id,
+			Name:   r.names[id],
+			SpecID: specID[0],
+			Type:   r.typeNames[i.ResultType()],
+		}
+		switch i.Opcode {
+		case OpSpecConstantTrue:
+			c.Default = []uint32{1}
+		case OpSpecConstantFalse:
+			c.Default = []uint32{0}
+		default:
+			c.Default = append([]uint32{}, i.Operands[2:]...)
+		}
+		out.SpecConstants = append(out.SpecConstants, c)
+	}
+	sort.Slice(out.SpecConstants, func(i, j int) bool {
+		return out.SpecConstants[i].SpecID < out.SpecConstants[j].SpecID
+	})
+	return out
+}
+
+// staticallyUsed returns the ids referenced by the function with the given id
+// and by the functions it calls.
+func (r *reflector) staticallyUsed(function uint32) map[uint32]bool {
+	used := map[uint32]bool{}
+	visited := map[uint32]bool{}
+	for pending := []uint32{function}; len(pending) > 0; {
+		f := pending[len(pending)-1]
+		pending = pending[:len(pending)-1]
+		if visited[f] {
+			continue
+		}
+		visited[f] = true
+		for _, i := range r.functions[f] {
+			if i.Opcode == OpFunctionCall && len(i.Operands) > 2 {
+				pending = append(pending, i.Operands[2])
+			}
+			for _, o := range r.operands(i) {
+				if o.kind == idOperand {
+					used[o.words[0]] = true
+				}
+			}
+		}
+	}
+	return used
+}
+
+// variable returns the reflection of the global variable instruction v.
+func (r *reflector) variable(v Instruction) Variable {
+	id, typ := v.Operands[1], r.pointee(v.ResultType())
+	out := Variable{
+		ID:           id,
+		Name:         r.names[id],
+		StorageClass: StorageClass(v.Operands[2]),
+		Type:         r.typeNames[typ],
+	}
+	if l, ok := r.decorations[id][decorationLocation]; ok && len(l) > 0 {
+		out.Location = l[0]
+	}
+	builtIn, ok := r.decorations[id][decorationBuiltIn]
+	if !ok {
+		// Blocks of built-ins, like gl_PerVertex, decorate their members.
+		builtIn, ok = r.memberDecorations[r.stripArrays(typ)][0][decorationBuiltIn]
+	}
+	if ok && len(builtIn) > 0 {
+		out.BuiltIn = enums["BuiltIn"].format(builtIn[0])
+	}
+	return out
+}
+
+// descriptorBinding returns the descriptor binding of the resource variable v,
+// or false if v is not bound to a descriptor.
+func (r *reflector) descriptorBinding(v Instruction) (DescriptorBinding, bool) {
+	id := v.Operands[1]
+	set, ok := r.decorations[id][decorationDescriptorSet]
+	if !ok || len(set) == 0 {
+		return DescriptorBinding{}, false
+	}
+	binding, ok := r.decorations[id][decorationBinding]
+	if !ok || len(binding) == 0 {
+		return DescriptorBinding{}, false
+	}
+	out := DescriptorBinding{
+		ID:      id,
+		Name:    r.names[id],
+		Set:     set[0],
+		Binding: binding[0],
+		Count:   1,
+	}
+
+	typ := r.pointee(v.ResultType())
+	for t := r.defs[typ]; t.Opcode == OpTypeArray && len(t.Operands) > 2; t = r.defs[typ] {
+		out.Count *= r.constant(t.Operands[2])
+		typ = t.Operands[1]
+	}
+	typ = r.stripArrays(typ)
+
+	t := r.defs[typ]
+	switch t.Opcode {
+	case OpTypeSampler:
+		out.DescriptorType = DescriptorTypeSampler
+	case OpTypeSampledImage:
+		out.DescriptorType = DescriptorTypeCombinedImageSampler
+	case OpTypeImage:
+		if len(t.Operands) < 7 {
+			return DescriptorBinding{}, false
+		}
+		dim, sampled := t.Operands[2], t.Operands[6]
+		switch {
+		case dim == dimSubpassData:
+			out.DescriptorType = DescriptorTypeInputAttachment
+		case dim == dimBuffer && sampled == 2:
+			out.DescriptorType = DescriptorTypeStorageTexelBuffer
+		case dim == dimBuffer:
+			out.DescriptorType = DescriptorTypeUniformTexelBuffer
+		case sampled == 2:
+			out.DescriptorType = DescriptorTypeStorageImage
+		default:
+			out.DescriptorType = DescriptorTypeSampledImage
+		}
+	case OpTypeStruct:
+		_, bufferBlock := r.decorations[typ][decorationBufferBlock]
+		if bufferBlock || StorageClass(v.Operands[2]) == StorageBuffer {
+			out.DescriptorType = DescriptorTypeStorageBuffer
+		} else {
+			out.DescriptorType = DescriptorTypeUniformBuffer
+		}
+	default:
+		return DescriptorBinding{}, false
+	}
+	return out, true
+}
+
+// pointee returns the type pointed to by the pointer type with the given id.
+func (r *reflector) pointee(ptr uint32) uint32 {
+	if t := r.defs[ptr]; t.Opcode == OpTypePointer && len(t.Operands) > 2 {
+		return t.Operands[2]
+	}
+	return 0
+}
+
+// stripArrays returns the element type of the array type with the given id,
+// recursively, or typ if it is not an array.
+func (r *reflector) stripArrays(typ uint32) uint32 {
+	for t := r.defs[typ]; (t.Opcode == OpTypeArray || t.Opcode == OpTypeRuntimeArray) && len(t.Operands) > 1; t = r.defs[typ] {
+		typ = t.Operands[1]
+	}
+	return typ
+}
+
+// constant returns the value of the 32 bit integer constant with the given id.
+func (r *reflector) constant(id uint32) uint32 {
+	if c := r.defs[id]; (c.Opcode == OpConstant || c.Opcode == OpSpecConstant) && len(c.Operands) > 2 {
+		return c.Operands[2]
+	}
+	return 0
+}
+
+// size returns the size in bytes of the type with the given id, using the
+// member decorations of the struct holding the type, if any, for the strides
+// of matrices.
+func (r *reflector) size(typ uint32, member map[uint32][]uint32) uint32 {
+	t := r.defs[typ]
+	ops := t.Operands
+	switch t.Opcode {
+	case OpTypeBool:
+		return 4
+	case OpTypeInt, OpTypeFloat:
+		if len(ops) > 1 {
+			return ops[1] / 8
+		}
+	case OpTypeVector:
+		if len(ops) > 2 {
+			return ops[2] * r.size(ops[1], nil)
+		}
+	case OpTypeMatrix:
+		if len(ops) < 3 {
+			return 0
+		}
+		if stride, ok := member[decorationMatrixStride]; ok && len(stride) > 0 {
+			vectors := ops[2]
+			if _, rowMajor := member[decorationRowMajor]; rowMajor {
+				if column := r.defs[ops[1]]; len(column.Operands) > 2 {
+					vectors = column.Operands[2]
+				}
+			}
+			return vectors * stride[0]
+		}
+		return ops[2] * r.size(ops[1], nil)
+	case OpTypeArray:
+		if len(ops) < 3 {
+			return 0
+		}
+		if stride, ok := r.decorations[typ][decorationArrayStride]; ok && len(stride) > 0 {
+			return r.constant(ops[2]) * stride[0]
+		}
+		return r.constant(ops[2]) * r.size(ops[1], member)
+	case OpTypeStruct:
+		size := uint32(0)
+		for i, m := range ops[1:] {
+			decorations := r.memberDecorations[typ][uint32(i)]
+			offset := size
+			if o, ok := decorations[decorationOffset]; ok && len(o) > 0 {
+				offset = o[0]
+			}
+			if end := offset + r.size(m, decorations); end > size {
+				size = end
+			}
+		}
+		return size
+	}
+	return 0
+}
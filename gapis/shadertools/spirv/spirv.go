@@ -0,0 +1,155 @@
+// Copyright (C) 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package spirv is a pure Go parser for SPIR-V binary modules.
+//
+// It can disassemble modules to the text form produced by spirv-dis and
+// reflect the interface of the module entry points, without depending on any
+// C++ library.
+package spirv
+
+import (
+	"fmt"
+
+	"github.com/google/gapid/core/fault"
+)
+
+const (
+	// Magic is the first word of a SPIR-V module.
+	Magic = 0x07230203
+
+	headerWords = 5
+)
+
+const (
+	// ErrInvalidMagic is returned when parsing words that do not start with the
+	// SPIR-V magic number, in either endianness.
+	ErrInvalidMagic = fault.Const("Invalid SPIR-V magic number")
+	// ErrTruncated is returned when the module ends in the middle of the header
+	// or of an instruction.
+	ErrTruncated = fault.Const("Truncated SPIR-V module")
+	// ErrInvalidWordCount is returned for instructions declaring a word count of
+	// zero.
+	ErrInvalidWordCount = fault.Const("Invalid SPIR-V instruction word count")
+)
+
+// Header is the header of a SPIR-V module.
+type Header struct {
+	Version   uint32 // Version number, 0x00MMmm00 for version MM.mm.
+	Generator uint32 // Generator vendor id in the high 16 bits, version in the low.
+	Bound     uint32 // All ids of the module are less than Bound.
+	Schema    uint32 // Instruction schema, reserved.
+}
+
+// Instruction is a single SPIR-V instruction.
+type Instruction struct {
+	Opcode   Opcode
+	Operands []uint32 // The words following the opcode word.
+}
+
+// Module is a parsed SPIR-V module.
+type Module struct {
+	Header
+	Instructions []Instruction
+}
+
+// Parse parses the SPIR-V binary module in words. Modules of either endianness
+// are accepted.
+func Parse(words []uint32) (*Module, error) {
+	if len(words) < headerWords {
+		if len(words) > 0 && words[0] != Magic && swap(words[0]) != Magic {
+			return nil, ErrInvalidMagic
+		}
+		return nil, ErrTruncated
+	}
+	switch {
+	case words[0] == Magic:
+	case swap(words[0]) == Magic:
+		swapped := make([]uint32, len(words))
+		for i, w := range words {
+			swapped[i] = swap(w)
+		}
+		words = swapped
+	default:
+		return nil, ErrInvalidMagic
+	}
+
+	m := &Module{Header: Header{
+		Version:   words[1],
+		Generator: words[2],
+		Bound:     words[3],
+		Schema:    words[4],
+	}}
+	for i := headerWords; i < len(words); {
+		count, op := int(words[i]>>16), Opcode(words[i]&0xffff)
+		if count == 0 {
+			return nil, fmt.Errorf("%v: %v at word %d", ErrInvalidWordCount, op, i)
+		}
+		if i+count > len(words) {
+			return nil, fmt.Errorf("%v: %v at word %d needs %d words, %d left", ErrTruncated, op, i, count, len(words)-i)
+		}
+		m.Instructions = append(m.Instructions, Instruction{
+			Opcode:   op,
+			Operands: words[i+1 : i+count],
+		})
+		i += count
+	}
+	return m, nil
+}
+
+// ResultType returns the result type id of the instruction, or 0 if the
+// instruction has no result type.
+func (i Instruction) ResultType() uint32 {
+	if info, ok := opcodes[i.Opcode]; ok && info.hasType && len(i.Operands) > 0 {
+		return i.Operands[0]
+	}
+	return 0
+}
+
+// Result returns the result id of the instruction, or 0 if the instruction has
+// no result.
+func (i Instruction) Result() uint32 {
+	info, ok := opcodes[i.Opcode]
+	if !ok || !info.hasResult {
+		return 0
+	}
+	idx := 0
+	if info.hasType {
+		idx = 1
+	}
+	if idx < len(i.Operands) {
+		return i.Operands[idx]
+	}
+	return 0
+}
+
+// decodeString decodes the nul terminated UTF-8 string literal at the start of
+// words, returning the string and the number of words it occupies.
+func decodeString(words []uint32) (string, int) {
+	bytes := make([]byte, 0, len(words)*4)
+	for i, w := range words {
+		for b := uint(0); b < 4; b++ {
+			c := byte(w >> (b * 8))
+			if c == 0 {
+				return string(bytes), i + 1
+			}
+			bytes = append(bytes, c)
+		}
+	}
+	return string(bytes), len(words)
+}
+
+func swap(w uint32) uint32 {
+	return w>>24 | (w>>8)&0xff00 | (w<<8)&0xff0000 | w<<24
+}
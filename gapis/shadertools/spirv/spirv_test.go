@@ -0,0 +1,301 @@
+// Copyright (C) 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package spirv_test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/google/gapid/core/assert"
+	"github.com/google/gapid/core/log"
+	"github.com/google/gapid/gapis/shadertools/spirv"
+)
+
+// module assembles a SPIR-V module from instructions given as an opcode
+// followed by operands, which are either uint32 or string.
+func module(bound uint32, insts ...[]interface{}) []uint32 {
+	words := []uint32{spirv.Magic, 0x10000, 8<<16 | 1, bound, 0}
+	for _, inst := range insts {
+		operands := []uint32{}
+		for _, o := range inst[1:] {
+			switch o := o.(type) {
+			case int:
+				operands = append(operands, uint32(o))
+			case string:
+				b := append([]byte(o), make([]byte, 4-len(o)%4)...)
+				for i := 0; i < len(b); i += 4 {
+					operands = append(operands, uint32(b[i])|uint32(b[i+1])<<8|uint32(b[i+2])<<16|uint32(b[i+3])<<24)
+				}
+			}
+		}
+		words = append(words, uint32(len(operands)+1)<<16|uint32(inst[0].(int)))
+		words = append(words, operands...)
+	}
+	return words
+}
+
+func inst(operands ...interface{}) []interface{} { return operands }
+
+// The ids of the test module.
+const (
+	idGLSL = iota + 1
+	idMain
+	idHelper
+	idVoid
+	idFn
+	idFloat
+	idV4float
+	idPtrInV4float
+	idInPos
+	idPerVertex
+	idPtrOutPerVertex
+	idOutPerVertex
+	idImage
+	idSampledImage
+	idUint
+	idUint3
+	idArr
+	idPtrTex
+	idTex
+	idUnused
+	idMat4
+	idPushBlock
+	idPtrPushBlock
+	idPush
+	idSpec
+	idLabel
+	idCall
+	idLoaded
+	idLabel2
+	idBound
+)
+
+func testModule() []uint32 {
+	return module(idBound,
+		inst(17, 1),                      // OpCapability Shader
+		inst(11, idGLSL, "GLSL.std.450"), // OpExtInstImport
+		inst(14, 0, 1),                   // OpMemoryModel Logical GLSL450
+		inst(15, 0, idMain, "main", idInPos, idOutPerVertex), // OpEntryPoint Vertex
+		inst(5, idMain, "main"),                              // OpName
+		inst(5, idInPos, "in_pos"),
+		inst(5, idTex, "tex"),
+		inst(5, idPush, "pc"),
+		inst(5, idSpec, "count"),
+		inst(71, idInPos, 30, 0),               // OpDecorate Location 0
+		inst(72, idPerVertex, 0, 11, 0),        // OpMemberDecorate BuiltIn Position
+		inst(71, idPerVertex, 2),               // OpDecorate Block
+		inst(71, idTex, 34, 1),                 // OpDecorate DescriptorSet 1
+		inst(71, idTex, 33, 2),                 // OpDecorate Binding 2
+		inst(71, idUnused, 34, 0),              // OpDecorate DescriptorSet 0
+		inst(71, idUnused, 33, 0),              // OpDecorate Binding 0
+		inst(71, idPushBlock, 2),               // OpDecorate Block
+		inst(72, idPushBlock, 0, 35, 0),        // OpMemberDecorate Offset 0
+		inst(72, idPushBlock, 0, 7, 16),        // OpMemberDecorate MatrixStride 16
+		inst(72, idPushBlock, 1, 35, 64),       // OpMemberDecorate Offset 64
+		inst(71, idSpec, 1, 3),                 // OpDecorate SpecId 3
+		inst(19, idVoid),                       // OpTypeVoid
+		inst(33, idFn, idVoid),                 // OpTypeFunction
+		inst(22, idFloat, 32),                  // OpTypeFloat
+		inst(23, idV4float, idFloat, 4),        // OpTypeVector
+		inst(32, idPtrInV4float, 1, idV4float), // OpTypePointer Input
+		inst(59, idPtrInV4float, idInPos, 1),   // OpVariable Input
+		inst(30, idPerVertex, idV4float),       // OpTypeStruct
+		inst(32, idPtrOutPerVertex, 3, idPerVertex),
+		inst(59, idPtrOutPerVertex, idOutPerVertex, 3),
+		inst(25, idImage, idFloat, 1, 0, 0, 0, 1, 0), // OpTypeImage 2D sampled
+		inst(27, idSampledImage, idImage),
+		inst(21, idUint, 32, 0),      // OpTypeInt
+		inst(43, idUint, idUint3, 3), // OpConstant
+		inst(28, idArr, idSampledImage, idUint3),
+		inst(32, idPtrTex, 0, idArr), // OpTypePointer UniformConstant
+		inst(59, idPtrTex, idTex, 0),
+		inst(59, idPtrTex, idUnused, 0),
+		inst(24, idMat4, idV4float, 4), // OpTypeMatrix
+		inst(30, idPushBlock, idMat4, idFloat),
+		inst(32, idPtrPushBlock, 9, idPushBlock), // OpTypePointer PushConstant
+		inst(59, idPtrPushBlock, idPush, 9),
+		inst(50, idUint, idSpec, 7),        // OpSpecConstant
+		inst(54, idVoid, idMain, 0, idFn),  // OpFunction
+		inst(248, idLabel),                 // OpLabel
+		inst(57, idVoid, idCall, idHelper), // OpFunctionCall
+		inst(253),                          // OpReturn
+		inst(56),                           // OpFunctionEnd
+		inst(54, idVoid, idHelper, 0, idFn),
+		inst(248, idLabel2),
+		inst(61, idArr, idLoaded, idTex),       // OpLoad
+		inst(61, idPushBlock, 0, idPush, 2, 4), // OpLoad Aligned 4
+		inst(253),
+		inst(56),
+	)
+}
+
+func TestParse(t *testing.T) {
+	ctx := log.Testing(t)
+
+	words := testModule()
+	m, err := spirv.Parse(words)
+	if !assert.For(ctx, "Parse").ThatError(err).Succeeded() {
+		return
+	}
+	assert.For(ctx, "bound").That(m.Bound).Equals(uint32(idBound))
+	assert.For(ctx, "instructions").That(len(m.Instructions)).Equals(54)
+	assert.For(ctx, "result").That(m.Instructions[1].Result()).Equals(uint32(idGLSL))
+	assert.For(ctx, "type").That(m.Instructions[1].ResultType()).Equals(uint32(0))
+
+	swapped := make([]uint32, len(words))
+	for i, w := range words {
+		swapped[i] = w>>24 | (w>>8)&0xff00 | (w<<8)&0xff0000 | w<<24
+	}
+	s, err := spirv.Parse(swapped)
+	if assert.For(ctx, "Parse swapped").ThatError(err).Succeeded() {
+		assert.For(ctx, "swapped").That(s).DeepEquals(m)
+	}
+
+	_, err = spirv.Parse([]uint32{1, 2, 3, 4, 5})
+	assert.For(ctx, "magic").ThatError(err).Equals(spirv.ErrInvalidMagic)
+	_, err = spirv.Parse(append(words, 2<<16|62))
+	assert.For(ctx, "truncated").ThatError(err).HasMessage(fmt.Sprintf(
+		"Truncated SPIR-V module: Store at word %d needs 2 words, 1 left", len(words)))
+}
+
+func TestDisassemble(t *testing.T) {
+	ctx := log.Testing(t)
+
+	words := module(20,
+		inst(17, 1),
+		inst(11, 1, "GLSL.std.450"),
+		inst(14, 0, 1),
+		inst(15, 4, 2, "main", 3),
+		inst(16, 2, 7),          // OpExecutionMode OriginUpperLeft
+		inst(5, 3, "out color"), // Name needing sanitization.
+		inst(71, 3, 30, 0),
+		inst(19, 4),
+		inst(33, 5, 4),
+		inst(22, 6, 32),
+		inst(23, 7, 6, 4),
+		inst(32, 8, 3, 7),
+		inst(59, 8, 3, 3),
+		inst(43, 6, 9, 0xbf000000), // -0.5
+		inst(21, 10, 32, 1),
+		inst(43, 10, 11, 0xffffffff), // -1
+		inst(54, 4, 2, 0, 5),
+		inst(248, 12),
+		inst(12, 6, 13, 1, 31, 9), // OpExtInst Sqrt
+		inst(80, 7, 14, 13, 13, 13, 13),
+		inst(62, 3, 14),
+		inst(251, 11, 15, 1, 16), // OpSwitch
+		inst(248, 15),
+		inst(248, 16),
+		inst(253),
+		inst(56),
+	)
+	text, err := spirv.Disassemble(words)
+	if !assert.For(ctx, "Disassemble").ThatError(err).Succeeded() {
+		return
+	}
+	assert.For(ctx, "text").ThatString(text).Equals(`; SPIR-V
+; Version: 1.0
+; Generator: Khronos Glslang Reference Front End; 1
+; Bound: 20
+; Schema: 0
+               OpCapability Shader
+          %1 = OpExtInstImport "GLSL.std.450"
+               OpMemoryModel Logical GLSL450
+               OpEntryPoint Fragment %2 "main" %out_color
+               OpExecutionMode %2 OriginUpperLeft
+               OpName %out_color "out color"
+               OpDecorate %out_color Location 0
+       %void = OpTypeVoid
+          %5 = OpTypeFunction %void
+      %float = OpTypeFloat 32
+    %v4float = OpTypeVector %float 4
+%_ptr_Output_v4float = OpTypePointer Output %v4float
+  %out_color = OpVariable %_ptr_Output_v4float Output
+ %float_n0_5 = OpConstant %float -0.5
+        %int = OpTypeInt 32 1
+     %int_n1 = OpConstant %int -1
+          %2 = OpFunction %void None %5
+         %12 = OpLabel
+         %13 = OpExtInst %float %1 Sqrt %float_n0_5
+         %14 = OpCompositeConstruct %v4float %13 %13 %13 %13
+               OpStore %out_color %14
+               OpSwitch %int_n1 %15 1 %16
+         %15 = OpLabel
+         %16 = OpLabel
+               OpReturn
+               OpFunctionEnd
+`)
+	assert.For(ctx, "opcode").That(spirv.Opcode(61).String()).Equals("Load")
+}
+
+func TestReflect(t *testing.T) {
+	ctx := log.Testing(t)
+
+	m, err := spirv.Parse(testModule())
+	if !assert.For(ctx, "Parse").ThatError(err).Succeeded() {
+		return
+	}
+	entryPoints := m.EntryPoints()
+	assert.For(ctx, "entry points").That(entryPoints).DeepEquals([]spirv.EntryPoint{{
+		Name:           "main",
+		ExecutionModel: spirv.Vertex,
+		Function:       idMain,
+		Interface:      []uint32{idInPos, idOutPerVertex},
+	}})
+
+	r, err := m.Reflect("main")
+	if !assert.For(ctx, "Reflect").ThatError(err).Succeeded() {
+		return
+	}
+	// The idUnused binding is not referenced by idMain, and idTex is referenced by
+	// the function idMain calls.
+	assert.For(ctx, "bindings").That(r.DescriptorBindings).DeepEquals([]spirv.DescriptorBinding{{
+		ID:             idTex,
+		Name:           "tex",
+		Set:            1,
+		Binding:        2,
+		DescriptorType: spirv.DescriptorTypeCombinedImageSampler,
+		Count:          3,
+	}})
+	assert.For(ctx, "push constants").That(r.PushConstants).DeepEquals([]spirv.Variable{{
+		ID:           idPush,
+		Name:         "pc",
+		StorageClass: spirv.PushConstant,
+		Type:         "_struct_22",
+		Size:         68,
+	}})
+	assert.For(ctx, "inputs").That(r.Inputs).DeepEquals([]spirv.Variable{{
+		ID:           idInPos,
+		Name:         "in_pos",
+		StorageClass: spirv.Input,
+		Type:         "v4float",
+	}})
+	assert.For(ctx, "outputs").That(r.Outputs).DeepEquals([]spirv.Variable{{
+		ID:           idOutPerVertex,
+		StorageClass: spirv.Output,
+		Type:         "_struct_10",
+		BuiltIn:      "Position",
+	}})
+	assert.For(ctx, "spec constants").That(r.SpecConstants).DeepEquals([]spirv.SpecConstant{{
+		ID:      idSpec,
+		Name:    "count",
+		SpecID:  3,
+		Type:    "uint",
+		Default: []uint32{7},
+	}})
+
+	_, err = m.Reflect("missing")
+	assert.For(ctx, "missing").ThatError(err).Failed()
+}
@@ -0,0 +1,168 @@
+// Copyright (C) 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package shadertools wraps around external C code for manipulating shaders.
+// When built without cgo, the SPIR-V disassembly and reflection functions fall
+// back to the pure Go implementations of the spirv package, and the functions
+// needing glslang or SPIRV-Tools fail.
+package shadertools
+
+import (
+	"bytes"
+	"fmt"
+)
+
+// Instruction represents a SPIR-V instruction.
+type Instruction struct {
+	ID     uint32   // Result identifer.
+	Opcode uint32   // Opcode.
+	Words  []uint32 // Operands.
+	Name   string   // Optional symbol name.
+}
+
+// CodeWithDebugInfo is the result returned by ConvertGlsl.
+type CodeWithDebugInfo struct {
+	SourceCode        string        // Modified GLSL.
+	DisassemblyString string        // Diassembly of modified GLSL.
+	Info              []Instruction // A set of SPIR-V debug instructions.
+}
+
+// FormatDebugInfo returns the instructions as a string.
+func FormatDebugInfo(insts []Instruction, linePrefix string) string {
+	var buffer bytes.Buffer
+	for _, inst := range insts {
+		buffer.WriteString(linePrefix)
+		if inst.ID != 0 {
+			buffer.WriteString(fmt.Sprintf("%%%-5v = ", inst.ID))
+		} else {
+			buffer.WriteString(fmt.Sprintf("       = "))
+		}
+		buffer.WriteString("Op")
+		buffer.WriteString(OpcodeToString(inst.Opcode))
+		for _, word := range inst.Words {
+			buffer.WriteString(fmt.Sprintf(" %v", word))
+		}
+		if inst.Name != "" {
+			buffer.WriteString(fmt.Sprintf(" \"%v\"", inst.Name))
+		}
+		buffer.WriteString("\n")
+	}
+	return buffer.String()
+}
+
+// ShaderType is the enumerator of shader types.
+type ShaderType int
+
+// The values match shader_type in cc/libmanager.h.
+const (
+	TypeVertex ShaderType = iota
+	TypeTessControl
+	TypeTessEvaluation
+	TypeGeometry
+	TypeFragment
+	TypeCompute
+)
+
+// ClientType is the enumerator of client types.
+type ClientType int
+
+// The values match client_type in cc/libmanager.h.
+const (
+	OpenGL ClientType = iota
+	OpenGLES
+	Vulkan
+)
+
+func (t ShaderType) String() string {
+	switch t {
+	case TypeVertex:
+		return "Vertex"
+	case TypeTessControl:
+		return "TessControl"
+	case TypeTessEvaluation:
+		return "TessEvaluation"
+	case TypeGeometry:
+		return "Geometry"
+	case TypeFragment:
+		return "Fragment"
+	case TypeCompute:
+		return "Compute"
+	default:
+		return "Unknown"
+	}
+}
+
+// ConvertOptions controls how ConvertGlsl converts its passed-in GLSL source code.
+type ConvertOptions struct {
+	// The type of shader.
+	ShaderType ShaderType
+	// The target GLSL version (default 330).
+	TargetGLSLVersion int
+	// Shader source preamble.
+	Preamble string
+	// Whether to add prefix to all non-builtin symbols.
+	PrefixNames bool
+	// The name prefix to be added to all non-builtin symbols.
+	NamesPrefix string /* optional */
+	// Whether to create a corresponding output variable for each input variable.
+	AddOutputsForInputs bool
+	// The name prefix of added output variables.
+	OutputPrefix string /* optional */
+	// Whether to make the generated GLSL code debuggable.
+	MakeDebuggable bool
+	// Whether to check the generated GLSL code compiles again.
+	CheckAfterChanges bool
+	// Whether to disassemble the generated GLSL code.
+	Disassemble bool
+	// If true, let some minor invalid statements compile.
+	Relaxed bool
+	// If true, optimizations that require high-end GL versions, or extensions
+	// will be stripped. These optimizations should have no impact on the end
+	// result of the shader, but may impact performance.
+	// Example: Early Fragment Test.
+	StripOptimizations bool
+}
+
+// CompileOptions controls how CompileGlsl compile its passed-in GLSL source code.
+type CompileOptions struct {
+	// The type of shader.
+	ShaderType ShaderType
+	// Either OpenGL, OpenGLES or Vulkan
+	ClientType ClientType
+	// Shader source preamble.
+	Preamble string
+}
+
+type DescriptorSets map[uint32]DescriptorSet
+type DescriptorSet []DescriptorBinding
+
+type DescriptorBinding struct {
+	Set             uint32
+	Binding         uint32
+	SpirvId         uint32
+	DescriptorType  uint32
+	DescriptorCount uint32
+	ShaderStage     uint32
+}
+
+func descriptorBindingLess(a DescriptorBinding, b DescriptorBinding) bool {
+	if a.Set != b.Set {
+		return a.Set < b.Set
+	}
+	if a.Binding != b.Binding {
+		return a.Binding < b.Binding
+	}
+	// SpirvId is a unique identifier so we don't need to keep comparing after this
+	return a.SpirvId < b.SpirvId
+}
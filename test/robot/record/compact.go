@@ -0,0 +1,444 @@
+// Copyright (C) 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package record
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"hash/crc32"
+	"io"
+	"io/ioutil"
+	"os"
+	"sync"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/google/gapid/core/event"
+	"github.com/google/gapid/core/fault"
+	"github.com/google/gapid/core/log"
+)
+
+const (
+	// compactFrameHeaderSize is the size of the length and checksum that precede every record.
+	compactFrameHeaderSize = 8
+	// compactMaxFrameSize is the largest record payload accepted when reading a ledger.
+	compactMaxFrameSize = 64 << 20
+	// compactMinFrames is the number of records a ledger must hold before it is considered for compaction.
+	compactMinFrames = 1024
+	// compactRatio is the ratio of records to distinct ids that triggers a compaction.
+	compactRatio = 4
+	// compactIndexInterval is the number of appended records between index rewrites.
+	compactIndexInterval = 256
+
+	compactIndexExt     = ".idx"
+	compactTempExt      = ".tmp"
+	compactIndexMagic   = "RLIX"
+	compactIndexVersion = 1
+
+	errTruncatedRecord = fault.Const("Truncated record")
+	errRecordTooLarge  = fault.Const("Record too large")
+	errRecordChecksum  = fault.Const("Record checksum mismatch")
+)
+
+// identifiable is the interface that records stored in a compact ledger must implement.
+// All the record types stored by the robot have an id field, which generates this method.
+type identifiable interface {
+	proto.Message
+	GetId() string
+}
+
+// compactFileType is an implementation of fileType that stores it's records in checksummed
+// binary proto format.
+// Records with the same id are merged together when the ledger is compacted, and an index
+// file is kept alongside the ledger so that opening it does not need to verify every record.
+type compactFileType struct{}
+
+type compactHandler struct {
+	mu      sync.Mutex
+	path    string
+	f       *os.File
+	null    proto.Message
+	end     int64            // the offset just past the last valid record
+	frames  int              // the number of records in the file
+	offsets map[string]int64 // the offset of the latest record for each id
+	pending int              // the number of records appended since the index was written
+}
+
+type compactReader struct {
+	f    *os.File
+	r    io.Reader
+	buf  []byte
+	null proto.Message
+}
+
+func (compactFileType) Ext() string { return ".pbc" }
+
+func (compactFileType) Open(ctx context.Context, f *os.File, null interface{}) (LedgerInstance, error) {
+	m, ok := null.(identifiable)
+	if !ok {
+		f.Close()
+		return nil, log.Err(ctx, nil, "Cannot create compact ledger with a type that has no id")
+	}
+	h := &compactHandler{path: f.Name(), f: f, null: m, offsets: map[string]int64{}}
+	// A temporary file can only exist if we crashed during a compaction, and it was not
+	// yet renamed into place, so the original ledger is still intact.
+	os.Remove(h.path + compactTempExt)
+	if err := h.load(ctx); err != nil {
+		f.Close()
+		return nil, err
+	}
+	if err := h.maybeCompact(ctx); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return h, nil
+}
+
+func (h *compactHandler) Write(ctx context.Context, record interface{}) error {
+	m, ok := record.(identifiable)
+	if !ok {
+		return log.Err(ctx, nil, "Cannot write record with no id to compact ledger")
+	}
+	frame, err := encodeFrame(m)
+	if err != nil {
+		return err
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	// The frame is written with a single call and synced before the end offset is moved, so
+	// that readers never see a partial record, and a crash leaves at most a truncated tail
+	// that is discarded on the next open.
+	if _, err := h.f.Write(frame); err != nil {
+		return err
+	}
+	if err := h.f.Sync(); err != nil {
+		return err
+	}
+	h.offsets[m.GetId()] = h.end
+	h.end += int64(len(frame))
+	h.frames++
+	h.pending++
+	if err := h.maybeCompact(ctx); err != nil {
+		return err
+	}
+	if h.pending >= compactIndexInterval {
+		return h.writeIndex(ctx)
+	}
+	return nil
+}
+
+func (h *compactHandler) Reader(ctx context.Context) event.Source {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	// Each reader gets it's own handle, so that a compaction that replaces the file does not
+	// invalidate readers that are still running.
+	f, err := os.Open(h.path)
+	if err != nil {
+		log.E(ctx, "Could not open compact ledger for reading. Error: %v", err)
+		return &compactReader{r: &bytes.Reader{}, null: h.null}
+	}
+	return &compactReader{
+		f:    f,
+		r:    bufio.NewReader(io.LimitReader(f, h.end)),
+		null: h.null,
+	}
+}
+
+func (h *compactHandler) Close(ctx context.Context) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.pending > 0 {
+		if err := h.writeIndex(ctx); err != nil {
+			log.W(ctx, "Failed to write compact ledger index. Error: %v", err)
+		}
+	}
+	h.f.Close()
+}
+
+func (h *compactHandler) New(ctx context.Context) interface{} {
+	return proto.Clone(h.null)
+}
+
+// load restores the handler state from the index file, and then verifies any records that
+// were appended after the index was written.
+// If the tail of the file is corrupt, it is assumed to be the result of an interrupted write
+// and is truncated.
+func (h *compactHandler) load(ctx context.Context) error {
+	info, err := h.f.Stat()
+	if err != nil {
+		return err
+	}
+	if !h.readIndex(ctx, info.Size()) {
+		h.end, h.frames, h.offsets = 0, 0, map[string]int64{}
+	}
+	if h.end == info.Size() {
+		return nil
+	}
+	r := &compactReader{r: bufio.NewReader(&readAt{f: h.f, offset: h.end}), null: h.null}
+	for {
+		m, size, err := r.read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			log.W(ctx, "Truncating corrupt compact ledger %v at %d of %d. Error: %v", h.path, h.end, info.Size(), err)
+			if err := h.f.Truncate(h.end); err != nil {
+				return err
+			}
+			break
+		}
+		h.offsets[m.(identifiable).GetId()] = h.end
+		h.end += size
+		h.frames++
+		h.pending++
+	}
+	if h.pending > 0 {
+		return h.writeIndex(ctx)
+	}
+	return nil
+}
+
+// maybeCompact compacts the ledger if it has grown large enough relative to the number of
+// distinct ids it holds.
+func (h *compactHandler) maybeCompact(ctx context.Context) error {
+	if h.frames < compactMinFrames || h.frames <= compactRatio*len(h.offsets) {
+		return nil
+	}
+	return h.compact(ctx)
+}
+
+// compact rewrites the ledger so that it holds only one record per id, formed by merging all
+// the records for that id in order.
+// The new ledger is written to a temporary file which is then renamed over the original, so a
+// crash at any point leaves either the old or the new ledger intact.
+func (h *compactHandler) compact(ctx context.Context) error {
+	ctx = log.V{"ledger": h.path}.Bind(ctx)
+	order := []string{}
+	byID := map[string]proto.Message{}
+	r := &compactReader{r: bufio.NewReader(io.LimitReader(&readAt{f: h.f}, h.end)), null: h.null}
+	for {
+		m, _, err := r.read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return log.Err(ctx, err, "Failed to read ledger for compaction")
+		}
+		id := m.(identifiable).GetId()
+		if existing, ok := byID[id]; ok {
+			proto.Merge(existing, m)
+		} else {
+			byID[id] = m
+			order = append(order, id)
+		}
+	}
+
+	tempPath := h.path + compactTempExt
+	out, err := os.OpenFile(tempPath, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0660)
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(out)
+	offsets := make(map[string]int64, len(order))
+	end := int64(0)
+	for _, id := range order {
+		frame, err := encodeFrame(byID[id])
+		if err == nil {
+			_, err = w.Write(frame)
+		}
+		if err != nil {
+			out.Close()
+			os.Remove(tempPath)
+			return err
+		}
+		offsets[id] = end
+		end += int64(len(frame))
+	}
+	if err := w.Flush(); err != nil {
+		out.Close()
+		os.Remove(tempPath)
+		return err
+	}
+	if err := out.Sync(); err != nil {
+		out.Close()
+		os.Remove(tempPath)
+		return err
+	}
+	out.Close()
+
+	// The old index must be gone before the file is replaced, as it would not match the new file.
+	if err := os.Remove(h.path + compactIndexExt); err != nil && !os.IsNotExist(err) {
+		os.Remove(tempPath)
+		return err
+	}
+	if err := os.Rename(tempPath, h.path); err != nil {
+		os.Remove(tempPath)
+		return err
+	}
+	f, err := os.OpenFile(h.path, os.O_RDWR|os.O_APPEND, 0660)
+	if err != nil {
+		return err
+	}
+	h.f.Close()
+	log.I(ctx, "Compacted record ledger from %d to %d records", h.frames, len(order))
+	h.f = f
+	h.end = end
+	h.frames = len(order)
+	h.offsets = offsets
+	return h.writeIndex(ctx)
+}
+
+// readIndex loads the index file if it is present and consistent with a ledger of the
+// specified size.
+func (h *compactHandler) readIndex(ctx context.Context, size int64) bool {
+	data, err := ioutil.ReadFile(h.path + compactIndexExt)
+	if err != nil {
+		return false
+	}
+	if len(data) < 4 || crc32.ChecksumIEEE(data[:len(data)-4]) != binary.LittleEndian.Uint32(data[len(data)-4:]) {
+		log.W(ctx, "Ignoring corrupt compact ledger index for %v", h.path)
+		return false
+	}
+	r := bytes.NewReader(data[:len(data)-4])
+	magic := make([]byte, len(compactIndexMagic))
+	if _, err := io.ReadFull(r, magic); err != nil || string(magic) != compactIndexMagic {
+		return false
+	}
+	header := struct {
+		Version uint32
+		End     int64
+		Frames  uint32
+		Count   uint32
+	}{}
+	if err := binary.Read(r, binary.LittleEndian, &header); err != nil {
+		return false
+	}
+	if header.Version != compactIndexVersion || header.End > size {
+		return false
+	}
+	offsets := make(map[string]int64, header.Count)
+	for i := uint32(0); i < header.Count; i++ {
+		length := uint32(0)
+		if err := binary.Read(r, binary.LittleEndian, &length); err != nil || int(length) > r.Len() {
+			return false
+		}
+		id := make([]byte, length)
+		io.ReadFull(r, id)
+		offset := int64(0)
+		if err := binary.Read(r, binary.LittleEndian, &offset); err != nil {
+			return false
+		}
+		offsets[string(id)] = offset
+	}
+	h.end = header.End
+	h.frames = int(header.Frames)
+	h.offsets = offsets
+	return true
+}
+
+// writeIndex atomically replaces the index file with the current handler state.
+func (h *compactHandler) writeIndex(ctx context.Context) error {
+	buf := &bytes.Buffer{}
+	buf.WriteString(compactIndexMagic)
+	binary.Write(buf, binary.LittleEndian, struct {
+		Version uint32
+		End     int64
+		Frames  uint32
+		Count   uint32
+	}{compactIndexVersion, h.end, uint32(h.frames), uint32(len(h.offsets))})
+	for id, offset := range h.offsets {
+		binary.Write(buf, binary.LittleEndian, uint32(len(id)))
+		buf.WriteString(id)
+		binary.Write(buf, binary.LittleEndian, offset)
+	}
+	binary.Write(buf, binary.LittleEndian, crc32.ChecksumIEEE(buf.Bytes()))
+
+	indexPath := h.path + compactIndexExt
+	tempPath := indexPath + compactTempExt
+	if err := ioutil.WriteFile(tempPath, buf.Bytes(), 0660); err != nil {
+		return err
+	}
+	if err := os.Rename(tempPath, indexPath); err != nil {
+		os.Remove(tempPath)
+		return err
+	}
+	h.pending = 0
+	return nil
+}
+
+// encodeFrame returns the on disk form of a record, a little endian size and crc32 followed
+// by the binary proto.
+func encodeFrame(record proto.Message) ([]byte, error) {
+	payload, err := proto.Marshal(record)
+	if err != nil {
+		return nil, err
+	}
+	frame := make([]byte, compactFrameHeaderSize+len(payload))
+	binary.LittleEndian.PutUint32(frame[0:], uint32(len(payload)))
+	binary.LittleEndian.PutUint32(frame[4:], crc32.ChecksumIEEE(payload))
+	copy(frame[compactFrameHeaderSize:], payload)
+	return frame, nil
+}
+
+// read decodes the next record, returning it along with the number of bytes it occupied.
+// It returns io.EOF only if there were no more bytes, a partial record is an error.
+func (r *compactReader) read() (proto.Message, int64, error) {
+	header := [compactFrameHeaderSize]byte{}
+	if _, err := io.ReadFull(r.r, header[:]); err != nil {
+		if err == io.ErrUnexpectedEOF {
+			return nil, 0, errTruncatedRecord
+		}
+		return nil, 0, err
+	}
+	size := binary.LittleEndian.Uint32(header[0:])
+	sum := binary.LittleEndian.Uint32(header[4:])
+	if size > compactMaxFrameSize {
+		return nil, 0, errRecordTooLarge
+	}
+	if cap(r.buf) < int(size) {
+		r.buf = make([]byte, size)
+	}
+	r.buf = r.buf[:size]
+	if _, err := io.ReadFull(r.r, r.buf); err != nil {
+		return nil, 0, errTruncatedRecord
+	}
+	if crc32.ChecksumIEEE(r.buf) != sum {
+		return nil, 0, errRecordChecksum
+	}
+	message := proto.Clone(r.null)
+	message.Reset()
+	if err := proto.Unmarshal(r.buf, message); err != nil {
+		return nil, 0, err
+	}
+	return message, int64(compactFrameHeaderSize) + int64(size), nil
+}
+
+func (r *compactReader) Next(ctx context.Context) interface{} {
+	message, _, err := r.read()
+	if err != nil {
+		if err != io.EOF {
+			log.E(ctx, "Invalid record in compact ledger. Error: %v", err)
+		}
+		return nil
+	}
+	return message
+}
+
+func (r *compactReader) Close(ctx context.Context) {
+	if r.f != nil {
+		r.f.Close()
+	}
+}
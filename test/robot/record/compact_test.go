@@ -0,0 +1,151 @@
+// Copyright (C) 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package record
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/google/gapid/core/assert"
+	"github.com/google/gapid/core/event"
+	"github.com/google/gapid/core/log"
+	"github.com/google/gapid/core/os/file"
+)
+
+type testRecord struct {
+	Id    string `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	Value int32  `protobuf:"varint,2,opt,name=value,proto3" json:"value,omitempty"`
+	Name  string `protobuf:"bytes,3,opt,name=name,proto3" json:"name,omitempty"`
+}
+
+func (m *testRecord) Reset()         { *m = testRecord{} }
+func (m *testRecord) String() string { return proto.CompactTextString(m) }
+func (*testRecord) ProtoMessage()    {}
+func (m *testRecord) GetId() string  { return m.Id }
+
+func newCompactShelf(ctx context.Context, assert assert.Manager) (*FileShelf, file.Path) {
+	dir, err := ioutil.TempDir("", "record")
+	assert.For("temp dir").ThatError(err).Succeeded()
+	shelf, err := NewFileShelf(ctx, file.Abs(dir))
+	assert.For("shelf").ThatError(err).Succeeded()
+	s := shelf.(*FileShelf)
+	s.Kind = Compact
+	return s, file.Abs(dir)
+}
+
+func readAll(ctx context.Context, assert assert.Manager, l Ledger) []*testRecord {
+	got := []*testRecord{}
+	err := l.Read(ctx, event.AsHandler(ctx, func(ctx context.Context, r *testRecord) error {
+		got = append(got, r)
+		return nil
+	}))
+	assert.For("read").ThatError(err).Succeeded()
+	return got
+}
+
+func TestCompactLedgerReopen(t *testing.T) {
+	ctx := log.Testing(t)
+	assert := assert.To(t)
+	shelf, dir := newCompactShelf(ctx, assert)
+	defer os.RemoveAll(dir.System())
+
+	l, err := shelf.Create(ctx, "test", &testRecord{})
+	assert.For("create").ThatError(err).Succeeded()
+	for i := 0; i < 10; i++ {
+		assert.For("add").ThatError(l.Add(ctx, &testRecord{Id: fmt.Sprint(i), Value: int32(i + 1)})).Succeeded()
+	}
+	l.Close(ctx)
+	assert.For("index").That(dir.Join("test.pbc.idx").Exists()).Equals(true)
+
+	l, err = shelf.Open(ctx, "test", &testRecord{})
+	assert.For("open").ThatError(err).Succeeded()
+	defer l.Close(ctx)
+	got := readAll(ctx, assert, l)
+	assert.For("count").That(len(got)).Equals(10)
+	for i, r := range got {
+		assert.For("record").That(r.Id).Equals(fmt.Sprint(i))
+		assert.For("value").That(r.Value).Equals(int32(i + 1))
+	}
+}
+
+func TestCompactLedgerCompaction(t *testing.T) {
+	ctx := log.Testing(t)
+	assert := assert.To(t)
+	shelf, dir := newCompactShelf(ctx, assert)
+	defer os.RemoveAll(dir.System())
+
+	l, err := shelf.Create(ctx, "test", &testRecord{})
+	assert.For("create").ThatError(err).Succeeded()
+	for i := 0; i < compactMinFrames*2; i++ {
+		r := &testRecord{Id: fmt.Sprint(i % 4), Value: int32(i + 1)}
+		if i < 4 {
+			r.Name = fmt.Sprint("record ", i)
+		}
+		assert.For("add").ThatError(l.Add(ctx, r)).Succeeded()
+	}
+	l.Close(ctx)
+
+	l, err = shelf.Open(ctx, "test", &testRecord{})
+	assert.For("open").ThatError(err).Succeeded()
+	defer l.Close(ctx)
+	got := readAll(ctx, assert, l)
+	assert.For("count").ThatInteger(len(got)).IsAtMost(compactRatio * 4)
+	latest := map[string]*testRecord{}
+	for _, r := range got {
+		if existing, ok := latest[r.Id]; ok {
+			proto.Merge(existing, r)
+		} else {
+			latest[r.Id] = r
+		}
+	}
+	assert.For("ids").That(len(latest)).Equals(4)
+	for i := 0; i < 4; i++ {
+		r := latest[fmt.Sprint(i)]
+		assert.For("name").That(r.Name).Equals(fmt.Sprint("record ", i))
+		assert.For("value").That(r.Value).Equals(int32(compactMinFrames*2 - 3 + i))
+	}
+}
+
+func TestCompactLedgerTruncatedTail(t *testing.T) {
+	ctx := log.Testing(t)
+	assert := assert.To(t)
+	shelf, dir := newCompactShelf(ctx, assert)
+	defer os.RemoveAll(dir.System())
+
+	l, err := shelf.Create(ctx, "test", &testRecord{})
+	assert.For("create").ThatError(err).Succeeded()
+	for i := 0; i < 3; i++ {
+		assert.For("add").ThatError(l.Add(ctx, &testRecord{Id: fmt.Sprint(i), Value: 1})).Succeeded()
+	}
+	l.Close(ctx)
+
+	// Simulate a crash part way through appending a record.
+	path := dir.Join("test.pbc").System()
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0660)
+	assert.For("append").ThatError(err).Succeeded()
+	f.Write([]byte{20, 0, 0, 0, 1, 2, 3, 4, 5})
+	f.Close()
+
+	l, err = shelf.Open(ctx, "test", &testRecord{})
+	assert.For("open").ThatError(err).Succeeded()
+	assert.For("count").That(len(readAll(ctx, assert, l))).Equals(3)
+	assert.For("add").ThatError(l.Add(ctx, &testRecord{Id: "3", Value: 1})).Succeeded()
+	assert.For("count").That(len(readAll(ctx, assert, l))).Equals(4)
+	l.Close(ctx)
+}
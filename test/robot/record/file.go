@@ -23,7 +23,7 @@ import (
 )
 
 var (
-	fileSearchOrder = []Kind{JSON, Text, Proto}
+	fileSearchOrder = []Kind{JSON, Text, Proto, Compact}
 )
 
 // FileShelf is an implementation of Shelf that stores it's ledgers in files.
@@ -50,9 +50,10 @@ type readAt struct {
 var (
 	// fileTypes holds the map of file handlers for the various kinds.
 	fileTypes = map[Kind]fileType{
-		JSON:  jsonFileType{},
-		Text:  pbtxtFileType{},
-		Proto: pbbFileType{},
+		JSON:    jsonFileType{},
+		Text:    pbtxtFileType{},
+		Proto:   pbbFileType{},
+		Compact: compactFileType{},
 	}
 )
 
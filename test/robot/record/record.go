@@ -20,4 +20,5 @@ const (
 	Proto   = Kind_Proto
 	Text    = Kind_Text
 	JSON    = Kind_JSON
+	Compact = Kind_Compact
 )
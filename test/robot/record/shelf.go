@@ -37,6 +37,8 @@ type Shelf interface {
 
 // NewShelf returns a new record shelf from the supplied url.
 // The type of shelf will depend on the url given.
+// For file shelves, the kind of newly created ledgers can be selected with a
+// kind query parameter, for example file:///path/to/shelf?kind=compact
 func NewShelf(ctx context.Context, shelfURL *url.URL) (Shelf, error) {
 	ctx = log.V{"ShelfURL": shelfURL.Path}.Bind(ctx)
 	switch shelfURL.Scheme {
@@ -53,7 +55,18 @@ func NewShelf(ctx context.Context, shelfURL *url.URL) (Shelf, error) {
 			shelfURL.Path = strings.TrimPrefix(shelfURL.Path, "/")
 		}
 		log.I(ctx, "Build a file record shelf on %s", shelfURL.Path)
-		return NewFileShelf(ctx, file.Abs(shelfURL.Path))
+		shelf, err := NewFileShelf(ctx, file.Abs(shelfURL.Path))
+		if err != nil {
+			return nil, err
+		}
+		if name := shelfURL.Query().Get("kind"); name != "" {
+			kind, ok := parseFileKind(name)
+			if !ok {
+				return nil, log.Errf(ctx, nil, "Invalid file shelf kind %v", name)
+			}
+			shelf.(*FileShelf).Kind = kind
+		}
+		return shelf, nil
 	case "memory":
 		log.I(ctx, "Start an in memory record shelf")
 		return NewNullShelf(ctx)
@@ -61,3 +74,15 @@ func NewShelf(ctx context.Context, shelfURL *url.URL) (Shelf, error) {
 		return nil, log.Err(ctx, nil, "Unknown record shelf url type")
 	}
 }
+
+// parseFileKind returns the Kind with the specified name, if it can be used for
+// file shelves.
+func parseFileKind(name string) (Kind, bool) {
+	for value, kindName := range Kind_name {
+		kind := Kind(value)
+		if _, ok := fileTypes[kind]; ok && strings.EqualFold(name, kindName) {
+			return kind, true
+		}
+	}
+	return Unknown, false
+}
@@ -234,6 +234,11 @@ func newView() *view {
 	body.Append(v.objView)
 	body.Append(v.div)
 
+	compare := dom.NewA()
+	compare.Set("href", "compare.html")
+	compare.Append("Compare packages")
+	v.div.Append(compare)
+
 	return v
 }
 
@@ -0,0 +1,290 @@
+// Copyright (C) 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package web
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+
+	"github.com/google/gapid/test/robot/build"
+	"github.com/google/gapid/test/robot/job"
+	"github.com/google/gapid/test/robot/replay"
+	"github.com/google/gapid/test/robot/report"
+	q "github.com/google/gapid/test/robot/search/query"
+	"github.com/google/gapid/test/robot/trace"
+)
+
+// The stages of the pipeline that are compared, in pipeline order.
+const (
+	traceStage  = "trace"
+	reportStage = "report"
+	replayStage = "replay"
+)
+
+// reportSummaryPattern matches the last line written by `gapit report`.
+var reportSummaryPattern = regexp.MustCompile(`(?m)^(\d+|No) issues found\s*$`)
+
+// stageResult is the outcome of a single stage of a subject on a target, in one package.
+type stageResult struct {
+	Action string
+	Status job.Status
+	// Log is the url of the log of the action, if there is one.
+	Log string
+	// Issues is the number of issues in the report, or -1 if it is not known.
+	Issues int
+}
+
+// stageChange is a difference in a stage between the base and head packages.
+// Either side may be nil if the stage was not run for that package.
+type stageChange struct {
+	Stage string
+	Base  *stageResult
+	Head  *stageResult
+}
+
+// subjectChange holds all the changes for a single subject on a single target.
+type subjectChange struct {
+	Subject string
+	Target  string
+	Changes []stageChange
+	// IssueDelta is the change in the number of report issues from base to head.
+	IssueDelta int
+}
+
+type comparison struct {
+	Base     string
+	Head     string
+	Subjects []subjectChange
+}
+
+// subjectKey identifies the results of a subject on a single target.
+type subjectKey struct {
+	subject string
+	target  string
+}
+
+// packageResults holds the stage results for all subjects of a package.
+type packageResults map[subjectKey]map[string]*stageResult
+
+func (s *Server) handleCompare(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	head := r.FormValue("head")
+	if head == "" {
+		writeError(w, 404, errors.New("The head parameter is required"))
+		return
+	}
+	base := r.FormValue("base")
+	if base == "" {
+		if err := s.Build.SearchPackages(ctx, q.Name("Id").Equal(q.String(head)).Query(), func(ctx context.Context, entry *build.Package) error {
+			base = entry.Parent
+			return nil
+		}); err != nil {
+			writeError(w, 500, err)
+			return
+		}
+		if base == "" {
+			writeError(w, 404, fmt.Errorf("Package '%s' has no parent to compare with", head))
+			return
+		}
+	}
+
+	baseResults, err := s.packageResults(ctx, base)
+	if err != nil {
+		writeError(w, 500, err)
+		return
+	}
+	headResults, err := s.packageResults(ctx, head)
+	if err != nil {
+		writeError(w, 500, err)
+		return
+	}
+
+	json.NewEncoder(w).Encode(comparison{
+		Base:     base,
+		Head:     head,
+		Subjects: compareResults(baseResults, headResults),
+	})
+}
+
+// packageResults collects the results of all the actions run for a package.
+// Actions are searched in the order they were created, so when an action was
+// retried the later action replaces the earlier one. Report and replay results
+// are only collected for the latest trace of each subject and target.
+func (s *Server) packageResults(ctx context.Context, pkg string) (packageResults, error) {
+	pkgQuery := q.Name("Input").Member("Package").Equal(q.String(pkg)).Query()
+	results := packageResults{}
+	set := func(key subjectKey, stage string, result *stageResult) {
+		stages := results[key]
+		if stages == nil {
+			stages = map[string]*stageResult{}
+			results[key] = stages
+		}
+		stages[stage] = result
+	}
+
+	traceToKey := map[string]subjectKey{}
+	latestTrace := map[subjectKey]string{}
+	if err := s.Trace.Search(ctx, pkgQuery, func(ctx context.Context, entry *trace.Action) error {
+		key := subjectKey{entry.Input.Subject, entry.Target}
+		result := &stageResult{Action: entry.Id, Status: entry.Status, Issues: -1}
+		latestTrace[key] = ""
+		if entry.Output != nil {
+			traceToKey[entry.Output.Trace] = key
+			latestTrace[key] = entry.Output.Trace
+			result.Log = entityURL(entry.Output.Log)
+		}
+		set(key, traceStage, result)
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+	// subjectOf returns the subject of the trace, if it is the latest trace of
+	// its subject and target.
+	subjectOf := func(id string) (string, bool) {
+		key, ok := traceToKey[id]
+		if !ok || latestTrace[key] != id {
+			return "", false
+		}
+		return key.subject, true
+	}
+
+	if err := s.Report.Search(ctx, pkgQuery, func(ctx context.Context, entry *report.Action) error {
+		subj, ok := subjectOf(entry.Input.Trace)
+		if !ok {
+			return nil
+		}
+		result := &stageResult{Action: entry.Id, Status: entry.Status, Issues: -1}
+		if entry.Output != nil {
+			result.Log = entityURL(entry.Output.Log)
+			if entry.Status == job.Status_Succeeded {
+				result.Issues = s.reportIssues(ctx, entry.Output.Report)
+			}
+		}
+		set(subjectKey{subj, entry.Target}, reportStage, result)
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+
+	if err := s.Replay.Search(ctx, pkgQuery, func(ctx context.Context, entry *replay.Action) error {
+		subj, ok := subjectOf(entry.Input.Trace)
+		if !ok {
+			return nil
+		}
+		result := &stageResult{Action: entry.Id, Status: entry.Status, Issues: -1}
+		if entry.Output != nil {
+			result.Log = entityURL(entry.Output.Log)
+		}
+		set(subjectKey{subj, entry.Target}, replayStage, result)
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+// reportIssues returns the number of issues in a report stored in the stash,
+// or -1 if the report could not be read.
+func (s *Server) reportIssues(ctx context.Context, id string) int {
+	if id == "" {
+		return -1
+	}
+	data, err := s.Stash.Read(ctx, id)
+	if err != nil {
+		return -1
+	}
+	m := reportSummaryPattern.FindAllSubmatch(data, -1)
+	if len(m) == 0 {
+		return -1
+	}
+	count := m[len(m)-1][1]
+	if bytes.Equal(count, []byte("No")) {
+		return 0
+	}
+	n, err := strconv.Atoi(string(count))
+	if err != nil {
+		return -1
+	}
+	return n
+}
+
+// compareResults returns the subjects whose results differ between base and head,
+// ordered by subject and target.
+func compareResults(base, head packageResults) []subjectChange {
+	keys := []subjectKey{}
+	for k := range head {
+		keys = append(keys, k)
+	}
+	for k := range base {
+		if _, ok := head[k]; !ok {
+			keys = append(keys, k)
+		}
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].subject != keys[j].subject {
+			return keys[i].subject < keys[j].subject
+		}
+		return keys[i].target < keys[j].target
+	})
+
+	result := []subjectChange{}
+	for _, k := range keys {
+		change := subjectChange{Subject: k.subject, Target: k.target}
+		for _, stage := range []string{traceStage, reportStage, replayStage} {
+			b, h := base[k][stage], head[k][stage]
+			if stageChanged(b, h) {
+				change.Changes = append(change.Changes, stageChange{Stage: stage, Base: b, Head: h})
+			}
+		}
+		if b, h := stageIssues(base[k][reportStage]), stageIssues(head[k][reportStage]); b >= 0 && h >= 0 {
+			change.IssueDelta = h - b
+		}
+		if len(change.Changes) > 0 {
+			result = append(result, change)
+		}
+	}
+	return result
+}
+
+// stageChanged returns true if the status or issue count differs between two results.
+func stageChanged(base, head *stageResult) bool {
+	if base == nil || head == nil {
+		return base != head
+	}
+	return base.Status != head.Status || base.Issues != head.Issues
+}
+
+func stageIssues(r *stageResult) int {
+	if r == nil {
+		return -1
+	}
+	return r.Issues
+}
+
+// entityURL returns the url that serves the stash entity with the given id.
+func entityURL(id string) string {
+	if id == "" {
+		return ""
+	}
+	return "/entities/" + id
+}
@@ -79,6 +79,7 @@ func Create(ctx context.Context, config Config) (*Server, error) {
 	http.HandleFunc("/entities/", server.handleEntities)
 	http.HandleFunc("/status/", server.handleStatus)
 	http.HandleFunc("/gridData/", server.handleGrid)
+	http.HandleFunc("/compareData/", server.handleCompare)
 	server.listener = listener{l.(*net.TCPListener)}
 	return server, nil
 }
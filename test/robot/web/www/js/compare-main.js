@@ -0,0 +1,167 @@
+/*
+ * Copyright (C) 2018 Google Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+"use strict";
+
+// This is the main entry point for the Robot package comparison UI.
+
+requirejs.config({
+    baseUrl: "js",
+});
+
+require([
+  "comparedata",
+  "packages",
+  "selection",
+  "subjects",
+  "tracks",
+],
+function(
+  comparedata,
+  packages,
+  selection,
+  subjects,
+  tracks,
+) {
+  var subjectNames = {};
+  var chain = [];
+
+  function main() {
+    registerEventHandlers();
+    subjects.getAll()
+      .then(updateSubjects)
+      .then(() => tracks.getAll())
+      .then(updateTracks)
+      .then(loadPackages)
+      .then(loadComparison);
+  }
+
+  // Registers the DOM and selection event handlers.
+  function registerEventHandlers() {
+    $("#tracks").change(function() {
+      selection.update(s => s.track = this.value);
+    });
+    $("#packages").change(function() {
+      selection.update(s => s.pkg = this.value);
+    });
+    $("#base").change(loadComparison);
+
+    selection.listen(() => {
+      if ($("#tracks").data("loaded") != selection.track) {
+        loadPackages().then(loadComparison);
+      } else if ($("#packages").data("loaded") != selection.pkg) {
+        updateBase();
+        loadComparison();
+      }
+    });
+  }
+
+  function updateSubjects(subjs) {
+    subjs.forEach(subj => subjectNames[subj.id] = subj.name);
+  }
+
+  // Updates the track selection UI with the loaded tracks.
+  function updateTracks(ts) {
+    var sel = $("#tracks");
+    sel.empty();
+    selection.trackDefault = "";
+    ts.forEach(track => {
+      if (track.name == "master") {
+        selection.trackDefault = track.id;
+      }
+      sel.append($("<option>").attr("value", track.id).text(track.name));
+    });
+    sel.val(selection.track);
+  }
+
+  // Loads the package chain of the currently selected track.
+  async function loadPackages() {
+    var trackId = selection.track;
+    $("#tracks").data("loaded", trackId);
+    var track = await tracks.get(trackId);
+    chain = await packages.getChain(track.head);
+    var sel = $("#packages");
+    sel.empty();
+    selection.pkgDefault = track.head;
+    chain.forEach(pkg => sel.append($("<option>").attr("value", pkg.id).text(pkg.sha)));
+    sel.val(selection.pkg);
+    updateBase();
+  }
+
+  // Updates the base selection UI with the ancestors of the selected package,
+  // defaulting to its parent.
+  function updateBase() {
+    var sel = $("#base");
+    sel.empty();
+    var idx = chain.findIndex(pkg => pkg.id == selection.pkg);
+    chain.slice(idx + 1).forEach(pkg => sel.append($("<option>").attr("value", pkg.id).text(pkg.sha)));
+  }
+
+  // Loads the comparison of the selected packages.
+  function loadComparison() {
+    var head = selection.pkg, base = $("#base").val();
+    $("#packages").data("loaded", head);
+    if (!head || !base) {
+      updateComparison(null);
+      return;
+    }
+    comparedata.get(head, base).then(updateComparison);
+  }
+
+  function statusCell(result) {
+    var td = $("<td>").text(result.status);
+    if (result.issues >= 0) {
+      td.append(" (" + result.issues + " issues)");
+    }
+    if (result.log) {
+      td.append(" ").append($("<a>").attr("href", result.log).attr("target", "_blank").text("log"));
+    }
+    return td;
+  }
+
+  // Updates the comparison table with the loaded data.
+  function updateComparison(cmp) {
+    var table = $("#compare");
+    table.find("tr:gt(0)").remove();
+    if (!cmp) {
+      $("#summary").text("Select two packages to compare.");
+      return;
+    }
+    var regressions = 0;
+    cmp.forEachSubject(subj => {
+      subj.forEachChange((change, idx) => {
+        var row = $("<tr>").appendTo(table);
+        if (change.regressed) {
+          regressions++;
+          row.css("background-color", "#fdd");
+        }
+        if (idx == 0) {
+          row.append($("<td>").addClass("subject").text(subjectNames[subj.id] || subj.id));
+          row.append($("<td>").text(subj.target));
+        } else {
+          row.append($("<td>")).append($("<td>"));
+        }
+        row.append($("<td>").text(change.stage));
+        row.append(statusCell(change.base));
+        row.append(statusCell(change.head));
+        var delta = subj.issueDelta;
+        row.append($("<td>").text(change.stage == "report" && delta != 0 ? (delta > 0 ? "+" : "") + delta : ""));
+      });
+    });
+    $("#summary").text(cmp.count + " subjects changed, " + regressions + " regressions.");
+  }
+
+  main();
+});
@@ -0,0 +1,130 @@
+/*
+ * Copyright (C) 2018 Google Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+"use strict";
+
+// The comparedata module handles loading the differences between two packages.
+define(["xhr"],
+function(xhr) {
+  var statusNames = ["scheduled", "running", "succeeded", "failed"];
+
+  class Result {
+    constructor(json) {
+      this.json_ = json;
+    }
+
+    get present() {
+      return this.json_ != null;
+    }
+
+    get status() {
+      return this.present ? statusNames[this.json_.Status || 0] : "missing";
+    }
+
+    get log() {
+      return this.present && this.json_.Log || "";
+    }
+
+    get issues() {
+      return this.present && this.json_.Issues != undefined ? this.json_.Issues : -1;
+    }
+  }
+
+  class Change {
+    constructor(json) {
+      this.json_ = json;
+    }
+
+    get stage() {
+      return this.json_.Stage || "";
+    }
+
+    get base() {
+      return new Result(this.json_.Base);
+    }
+
+    get head() {
+      return new Result(this.json_.Head);
+    }
+
+    // Returns true if the change is a regression, i.e. the stage no longer
+    // succeeds, or reports more issues than before.
+    get regressed() {
+      var b = this.base, h = this.head;
+      if (b.status == "succeeded" && h.status != "succeeded") {
+        return true;
+      }
+      return b.issues >= 0 && h.issues > b.issues;
+    }
+  }
+
+  class Subject {
+    constructor(json) {
+      this.json_ = json;
+    }
+
+    get id() {
+      return this.json_.Subject || "";
+    }
+
+    get target() {
+      return this.json_.Target || "";
+    }
+
+    get issueDelta() {
+      return this.json_.IssueDelta || 0;
+    }
+
+    forEachChange(f) {
+      this.json_.Changes && this.json_.Changes.forEach((c, idx) => f(new Change(c), idx));
+    }
+  }
+
+  class Comparison {
+    constructor(json) {
+      this.json_ = json;
+    }
+
+    get base() {
+      return this.json_.Base || "";
+    }
+
+    get head() {
+      return this.json_.Head || "";
+    }
+
+    get count() {
+      return this.json_.Subjects && this.json_.Subjects.length || 0;
+    }
+
+    forEachSubject(f) {
+      this.json_.Subjects && this.json_.Subjects.forEach((s, idx) => f(new Subject(s), idx));
+    }
+  }
+
+  // Returns the comparison of the head package against the base package. If
+  // base is empty, the parent of head is used.
+  function get(head, base) {
+    var url = "/compareData/?head=" + encodeURIComponent(head);
+    if (base) {
+      url += "&base=" + encodeURIComponent(base);
+    }
+    return xhr.getJson(url).then(json => new Comparison(json || {}));
+  }
+
+  return {
+    get: get,
+  }
+});